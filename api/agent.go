@@ -0,0 +1,467 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/http1/resp"
+)
+
+// ========== 交易分析助手 ==========
+// POST /tool/agent 组装实时上下文，经 fast/deep 模型路由生成结构化建议动作，可选 SSE 流式输出
+// 建议动作先经 kill switch / CheckRisk / CheckPortfolioRisk 校验，POST /tool/agent/execute 执行前再次校验
+
+const (
+	agentTimeout     = 90 * time.Second
+	agentResultTTL   = 30 * time.Minute
+	agentMaxLeverage = 20
+	agentSource      = "agent"
+)
+
+// 建议动作类型
+const (
+	AgentActionOpen   = "open"
+	AgentActionAdd    = "add"
+	AgentActionReduce = "reduce"
+	AgentActionClose  = "close"
+	AgentActionSetSL  = "set_sl"
+	AgentActionSetTP  = "set_tp"
+	AgentActionWait   = "wait"
+)
+
+const agentSystemPrompt = `你是专业的加密货币 U 本位合约交易顾问。根据用户问题和提供的实时账户与市场上下文（JSON）给出建议。
+只输出一个 JSON 对象，不要输出其他内容：
+{"summary":"简要分析","actions":[{"type":"open|add|reduce|close|set_sl|set_tp|wait","symbol":"BTCUSDT","side":"LONG|SHORT","amountUSDT":0,"leverage":0,"percent":0,"price":0,"confidence":0.0,"reason":"理由"}]}
+规则：
+- open/add 必须给出 side、amountUSDT（保证金 USDT）与 leverage；add 仅用于已有同向持仓
+- reduce 用 percent（0-100）表示减仓比例；close 平掉该币种持仓
+- set_sl/set_tp 的 price 为触发价，须位于当前价格的正确一侧
+- 没有把握或风控状态不允许时输出 wait
+- 必须尊重上下文中的风控、熔断与 VaR 状态`
+
+// AgentRequest 分析请求
+type AgentRequest struct {
+	Symbol   string `json:"symbol"`   // 默认 BTCUSDT
+	Question string `json:"question"` // 用户问题，可选
+	Deep     bool   `json:"deep"`     // 使用深度模型（未配置时回退快速模型）
+	Stream   bool   `json:"stream"`   // SSE 流式输出
+}
+
+// AgentAction 结构化建议动作
+type AgentAction struct {
+	Type       string  `json:"type"`
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side,omitempty"`       // LONG / SHORT
+	AmountUSDT float64 `json:"amountUSDT,omitempty"` // open / add 保证金
+	Leverage   int     `json:"leverage,omitempty"`
+	Percent    float64 `json:"percent,omitempty"` // reduce 比例
+	Price      float64 `json:"price,omitempty"`   // set_sl / set_tp 触发价
+	Confidence float64 `json:"confidence,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+	Valid      bool    `json:"valid"`              // 是否通过校验
+	Rejected   string  `json:"rejected,omitempty"` // 未通过原因
+	ExecutedAt string  `json:"executedAt,omitempty"`
+}
+
+// AgentAnalysis 一次分析结果
+type AgentAnalysis struct {
+	ID        string        `json:"id"`
+	Symbol    string        `json:"symbol"`
+	Question  string        `json:"question,omitempty"`
+	Model     string        `json:"model"`
+	Summary   string        `json:"summary"`
+	Actions   []AgentAction `json:"actions"`
+	Raw       string        `json:"raw,omitempty"` // 回复无法解析时的原文
+	Context   *AgentContext `json:"context"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+var agentStore = struct {
+	sync.Mutex
+	items map[string]*AgentAnalysis
+}{items: make(map[string]*AgentAnalysis)}
+
+func saveAgentAnalysis(a *AgentAnalysis) {
+	agentStore.Lock()
+	defer agentStore.Unlock()
+	for id, x := range agentStore.items {
+		if time.Since(x.CreatedAt) > agentResultTTL {
+			delete(agentStore.items, id)
+		}
+	}
+	agentStore.items[a.ID] = a
+}
+
+// RunAgentAnalysis 组装上下文并调用模型；onDelta 非空时流式回调模型输出
+func RunAgentAnalysis(ctx context.Context, req AgentRequest, onDelta func(string)) (*AgentAnalysis, error) {
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	if req.Symbol == "" {
+		req.Symbol = "BTCUSDT"
+	}
+
+	var model *LLMModel
+	if req.Deep {
+		model = resolveLLMModel("deep")
+	}
+	if model == nil {
+		model = resolveLLMModel("fast")
+	}
+	if model == nil {
+		return nil, fmt.Errorf("llm not configured")
+	}
+
+	ac := BuildAgentContext(ctx, req.Symbol)
+	ctxJSON, err := json.Marshal(ac)
+	if err != nil {
+		return nil, fmt.Errorf("encode context: %w", err)
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		question = fmt.Sprintf("请分析 %s 当前是否适合交易，并给出具体操作建议。", req.Symbol)
+	}
+	messages := []llmMessage{
+		{Role: "system", Content: agentSystemPrompt},
+		{Role: "user", Content: question + "\n\n上下文：\n" + string(ctxJSON)},
+	}
+
+	var content string
+	if onDelta != nil {
+		content, err = llmChatStream(ctx, model, messages, agentTimeout, onDelta)
+	} else {
+		content, err = llmChat(ctx, model, messages, agentTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("llm %s: %w", model.Model, err)
+	}
+
+	analysis := &AgentAnalysis{
+		ID:        fmt.Sprintf("agent_%d", time.Now().UnixNano()),
+		Symbol:    req.Symbol,
+		Question:  req.Question,
+		Model:     model.Model,
+		Context:   ac,
+		CreatedAt: time.Now(),
+	}
+	summary, actions, err := parseAgentReply(content)
+	if err != nil {
+		analysis.Raw = content
+		analysis.Summary = err.Error()
+	} else {
+		analysis.Summary = summary
+		for i := range actions {
+			validateAgentAction(&actions[i], req.Symbol, ac.Positions, ac.MarkPrice)
+		}
+		analysis.Actions = actions
+	}
+	saveAgentAnalysis(analysis)
+	log.Printf("[Agent] %s analysis %s via %s: %d actions", req.Symbol, analysis.ID, model.Model, len(analysis.Actions))
+	return analysis, nil
+}
+
+// parseAgentReply 解析模型回复中的 summary 与 actions
+func parseAgentReply(content string) (string, []AgentAction, error) {
+	obj, ok := extractJSONObject(content)
+	if !ok {
+		return "", nil, fmt.Errorf("no json object in llm reply")
+	}
+	var out struct {
+		Summary string        `json:"summary"`
+		Actions []AgentAction `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(obj), &out); err != nil {
+		return "", nil, fmt.Errorf("decode llm reply: %w", err)
+	}
+	return out.Summary, out.Actions, nil
+}
+
+func findAgentPosition(positions []AgentPosition, symbol string) *AgentPosition {
+	for i := range positions {
+		if positions[i].Symbol == symbol && positions[i].Qty > 0 {
+			return &positions[i]
+		}
+	}
+	return nil
+}
+
+// validateAgentAction 规范化并校验建议动作，结果写入 Valid / Rejected
+// 所有动作须通过 kill switch；开仓/加仓另需通过 CheckRisk 与 CheckPortfolioRisk
+func validateAgentAction(a *AgentAction, defaultSymbol string, positions []AgentPosition, markPrice float64) {
+	a.Type = strings.ToLower(strings.TrimSpace(a.Type))
+	a.Symbol = strings.ToUpper(strings.TrimSpace(a.Symbol))
+	a.Side = strings.ToUpper(strings.TrimSpace(a.Side))
+	if a.Symbol == "" {
+		a.Symbol = defaultSymbol
+	}
+	a.Valid, a.Rejected = false, ""
+	reject := func(format string, args ...interface{}) {
+		a.Rejected = fmt.Sprintf(format, args...)
+	}
+
+	if a.Type == AgentActionWait {
+		a.Valid = true
+		return
+	}
+	pos := findAgentPosition(positions, a.Symbol)
+
+	switch a.Type {
+	case AgentActionOpen, AgentActionAdd:
+		if a.Side != "LONG" && a.Side != "SHORT" {
+			reject("side must be LONG or SHORT")
+			return
+		}
+		if a.AmountUSDT <= 0 {
+			reject("amountUSDT must be positive")
+			return
+		}
+		if a.Leverage <= 0 {
+			a.Leverage = 5
+		}
+		if a.Leverage > agentMaxLeverage {
+			reject("leverage %d exceeds max %d", a.Leverage, agentMaxLeverage)
+			return
+		}
+		if a.Type == AgentActionAdd && (pos == nil || pos.Side != a.Side) {
+			reject("add requires an existing %s position on %s", a.Side, a.Symbol)
+			return
+		}
+		if a.Type == AgentActionOpen && pos != nil && pos.Side != a.Side {
+			reject("opposite %s position exists on %s, close it first", pos.Side, a.Symbol)
+			return
+		}
+	case AgentActionReduce, AgentActionClose, AgentActionSetSL, AgentActionSetTP:
+		if pos == nil {
+			reject("no open position on %s", a.Symbol)
+			return
+		}
+		if a.Side == "" {
+			a.Side = pos.Side
+		}
+		if a.Side != pos.Side {
+			reject("side %s does not match %s position", a.Side, pos.Side)
+			return
+		}
+		if a.Type == AgentActionReduce && (a.Percent <= 0 || a.Percent > 100) {
+			reject("percent must be within (0, 100]")
+			return
+		}
+		if a.Type == AgentActionSetSL || a.Type == AgentActionSetTP {
+			if a.Price <= 0 {
+				reject("price must be positive")
+				return
+			}
+			mark := pos.MarkPrice
+			if mark <= 0 {
+				mark = markPrice
+			}
+			if mark > 0 {
+				below := a.Price < mark
+				wantBelow := (a.Type == AgentActionSetSL) == (pos.Side == "LONG")
+				if below != wantBelow {
+					reject("%s price %.6f is on the wrong side of mark %.6f for %s", a.Type, a.Price, mark, pos.Side)
+					return
+				}
+			}
+		}
+	default:
+		reject("unknown action type %q", a.Type)
+		return
+	}
+
+	if err := CheckKillSwitch(agentSource, a.Symbol); err != nil {
+		reject("%v", err)
+		return
+	}
+	if a.Type == AgentActionOpen || a.Type == AgentActionAdd {
+		// 仅校验建议：用不计指标的锁检查，执行时由下单网关统一拦截
+		if err := checkDailyLossLock(); err != nil {
+			reject("%v", err)
+			return
+		}
+		if err := checkDrawdownLocks(); err != nil {
+			reject("%v", err)
+			return
+		}
+		if err := CheckPortfolioRisk(a.Symbol, a.AmountUSDT*float64(a.Leverage), a.Side == "LONG"); err != nil {
+			reject("%v", err)
+			return
+		}
+	}
+	a.Valid = true
+}
+
+// ExecuteAgentAction 执行已生成的建议动作：重新读取持仓并再次校验，每个动作只能执行一次
+func ExecuteAgentAction(ctx context.Context, id string, index int) (*AgentAction, interface{}, error) {
+	agentStore.Lock()
+	analysis, ok := agentStore.items[id]
+	if !ok || index < 0 || index >= len(analysis.Actions) {
+		agentStore.Unlock()
+		return nil, nil, fmt.Errorf("agent action %s#%d not found or expired", id, index)
+	}
+	if analysis.Actions[index].ExecutedAt != "" {
+		agentStore.Unlock()
+		return nil, nil, fmt.Errorf("agent action %s#%d already executed", id, index)
+	}
+	action := analysis.Actions[index]
+	// 先占位，防止并发重复执行
+	analysis.Actions[index].ExecutedAt = "pending"
+	agentStore.Unlock()
+
+	result, err := executeAgentAction(ctx, &action, analysis.Symbol)
+
+	agentStore.Lock()
+	if err != nil {
+		analysis.Actions[index].ExecutedAt = ""
+	} else {
+		action.ExecutedAt = time.Now().Format(time.RFC3339)
+		analysis.Actions[index] = action
+	}
+	agentStore.Unlock()
+	return &action, result, err
+}
+
+func executeAgentAction(ctx context.Context, a *AgentAction, defaultSymbol string) (interface{}, error) {
+	if a.Type == AgentActionWait {
+		return nil, fmt.Errorf("wait action is not executable")
+	}
+	positions, err := agentPositions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load positions: %w", err)
+	}
+	mark, _ := GetPriceCache().GetPrice(a.Symbol)
+	validateAgentAction(a, defaultSymbol, positions, mark)
+	if !a.Valid {
+		return nil, fmt.Errorf("validation failed: %s", a.Rejected)
+	}
+	pos := findAgentPosition(positions, a.Symbol)
+
+	switch a.Type {
+	case AgentActionOpen, AgentActionAdd:
+		side, posSide := futures.SideTypeBuy, futures.PositionSideTypeLong
+		if a.Side == "SHORT" {
+			side, posSide = futures.SideTypeSell, futures.PositionSideTypeShort
+		}
+		return PlaceOrderViaWs(ctx, PlaceOrderReq{
+			Source:        agentSource,
+			Symbol:        a.Symbol,
+			Side:          side,
+			OrderType:     futures.OrderTypeMarket,
+			PositionSide:  posSide,
+			QuoteQuantity: strconv.FormatFloat(a.AmountUSDT, 'f', 2, 64),
+			Leverage:      a.Leverage,
+		})
+	case AgentActionReduce:
+		return ReducePositionViaWs(ctx, ReducePositionReq{
+			Symbol:       a.Symbol,
+			PositionSide: futures.PositionSideType(pos.PositionSide),
+			Percent:      a.Percent,
+		})
+	case AgentActionClose:
+		return ClosePositionViaWs(ctx, ClosePositionReq{
+			Symbol:       a.Symbol,
+			PositionSide: futures.PositionSideType(pos.PositionSide),
+		})
+	case AgentActionSetSL, AgentActionSetTP:
+		if IsDryRun() {
+			return nil, fmt.Errorf("%s is not supported in dry-run mode", a.Type)
+		}
+		trigger, err := normalizePriceForSymbol(ctx, a.Symbol, a.Price)
+		if err != nil {
+			return nil, fmt.Errorf("normalize price: %w", err)
+		}
+		orderType := "STOP_MARKET"
+		if a.Type == AgentActionSetTP {
+			orderType = "TAKE_PROFIT_MARKET"
+		}
+		closeSide := "SELL"
+		if pos.Side == "SHORT" {
+			closeSide = "BUY"
+		}
+		return PlaceAlgoOrder(ctx, AlgoOrderParams{
+			Symbol:        a.Symbol,
+			Side:          closeSide,
+			OrderType:     orderType,
+			TriggerPrice:  trigger,
+			ClosePosition: true,
+			PositionSide:  pos.PositionSide,
+			WorkingType:   "MARK_PRICE",
+			PriceProtect:  true,
+		})
+	}
+	return nil, fmt.Errorf("unknown action type %q", a.Type)
+}
+
+// ========== HTTP Handlers ==========
+
+// HandleAgent POST /tool/agent
+// body: {"symbol":"BTCUSDT","question":"...","deep":false,"stream":true}，stream 也可用 ?stream=1
+// 流式模式事件：delta（模型增量输出）→ result（结构化结果）/ error
+func HandleAgent(c context.Context, ctx *app.RequestContext) {
+	var req AgentRequest
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if string(ctx.Query("stream")) == "1" {
+		req.Stream = true
+	}
+
+	if !req.Stream {
+		analysis, err := RunAgentAnalysis(c, req, nil)
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, utils.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, utils.H{"data": analysis})
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Response.Header.Set("Content-Type", "text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("Connection", "keep-alive")
+	ctx.Response.HijackWriter(resp.NewChunkedBodyWriter(&ctx.Response, ctx.GetWriter()))
+
+	send := func(event string, data interface{}) {
+		payload, _ := json.Marshal(data)
+		ctx.Write([]byte("event: " + event + "\ndata: " + string(payload) + "\n\n"))
+		_ = ctx.Flush()
+	}
+
+	analysis, err := RunAgentAnalysis(c, req, func(delta string) {
+		send("delta", utils.H{"text": delta})
+	})
+	if err != nil {
+		send("error", utils.H{"error": err.Error()})
+		return
+	}
+	send("result", analysis)
+}
+
+// HandleAgentExecute POST /tool/agent/execute
+// body: {"id":"agent_xxx","index":0}
+func HandleAgentExecute(c context.Context, ctx *app.RequestContext) {
+	var req struct {
+		ID    string `json:"id"`
+		Index int    `json:"index"`
+	}
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	action, result, err := ExecuteAgentAction(c, req.ID, req.Index)
+	if err != nil {
+		ctx.JSON(http.StatusConflict, utils.H{"error": err.Error(), "action": action})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": utils.H{"action": action, "result": result}})
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ========== 交易分析助手：上下文组装 ==========
+// 汇总持仓、支撑阻力、推荐信号、市场状态、资金费率、爆仓、新闻与风控状态
+// 各部分独立获取，失败记录到 errors，不影响其他部分
+
+// AgentPosition 持仓摘要
+type AgentPosition struct {
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`         // LONG / SHORT
+	PositionSide  string  `json:"positionSide"` // 交易所持仓方向 BOTH / LONG / SHORT
+	Qty           float64 `json:"qty"`
+	EntryPrice    float64 `json:"entryPrice"`
+	MarkPrice     float64 `json:"markPrice"`
+	UnrealizedPnl float64 `json:"unrealizedPnl"`
+	Leverage      int     `json:"leverage"`
+}
+
+// AgentSRSummary 支撑阻力摘要
+type AgentSRSummary struct {
+	CurrentPrice   float64   `json:"currentPrice"`
+	Supports       []float64 `json:"supports"`
+	Resistances    []float64 `json:"resistances"`
+	ClosestSupport float64   `json:"closestSupport,omitempty"`
+	ClosestResist  float64   `json:"closestResist,omitempty"`
+}
+
+// AgentRecommend 推荐信号摘要
+type AgentRecommend struct {
+	Symbol     string   `json:"symbol"`
+	Direction  string   `json:"direction"`
+	Confidence int      `json:"confidence"`
+	Entry      float64  `json:"entry"`
+	StopLoss   float64  `json:"stopLoss"`
+	TakeProfit float64  `json:"takeProfit"`
+	Reasons    []string `json:"reasons,omitempty"`
+}
+
+// AgentLiqSummary 爆仓摘要（Long = 多头被强平）
+type AgentLiqSummary struct {
+	H1Long     float64          `json:"h1Long"`
+	H1Short    float64          `json:"h1Short"`
+	H4Long     float64          `json:"h4Long"`
+	H4Short    float64          `json:"h4Short"`
+	TopSymbols []symbolNotional `json:"topSymbols,omitempty"`
+}
+
+// AgentContext 分析上下文
+type AgentContext struct {
+	Symbol           string                 `json:"symbol"`
+	Time             string                 `json:"time"`
+	DryRun           bool                   `json:"dryRun"`
+	MarkPrice        float64                `json:"markPrice,omitempty"`
+	Positions        []AgentPosition        `json:"positions"`
+	SR               *AgentSRSummary        `json:"sr,omitempty"`
+	Recommendations  []AgentRecommend       `json:"recommendations,omitempty"`
+	Regime           string                 `json:"regime,omitempty"`
+	RegimeConfidence float64                `json:"regimeConfidence,omitempty"`
+	RegimeIndicators *RegimeIndicators      `json:"regimeIndicators,omitempty"`
+	Funding          []FundingRateItem      `json:"funding,omitempty"`
+	Liquidations     *AgentLiqSummary       `json:"liquidations,omitempty"`
+	News             []NewsDigest           `json:"news,omitempty"`
+	Risk             map[string]interface{} `json:"risk,omitempty"`
+	KillSwitch       map[string]interface{} `json:"killSwitch,omitempty"`
+	VaR              *VarSnapshot           `json:"var,omitempty"`
+	Errors           []string               `json:"errors,omitempty"`
+}
+
+// BuildAgentContext 组装分析上下文
+func BuildAgentContext(ctx context.Context, symbol string) *AgentContext {
+	ac := &AgentContext{
+		Symbol: symbol,
+		Time:   time.Now().UTC().Format(time.RFC3339),
+		DryRun: IsDryRun(),
+	}
+	addErr := func(part string, err error) {
+		ac.Errors = append(ac.Errors, part+": "+err.Error())
+	}
+
+	if price, err := GetPriceCache().GetPrice(symbol); err == nil {
+		ac.MarkPrice = price
+	} else {
+		addErr("price", err)
+	}
+
+	positions, err := agentPositions(ctx)
+	if err != nil {
+		addErr("positions", err)
+	}
+	ac.Positions = positions
+
+	if sr, err := GetSRLevels(ctx, symbol); err == nil {
+		ac.SR = summarizeAgentSR(sr)
+	} else {
+		addErr("sr", err)
+	}
+
+	ac.Recommendations = agentRecommendations(symbol, 5)
+
+	regime, conf, ind := GetCurrentRegime()
+	ac.Regime = string(regime)
+	ac.RegimeConfidence = conf
+	ac.RegimeIndicators = ind
+
+	if rates, err := fetchAllFundingRates(ctx); err == nil {
+		ac.Funding = agentFunding(rates, symbol, 3)
+	} else {
+		addErr("funding", err)
+	}
+
+	if store := getLiqStatsStore(); store != nil {
+		ac.Liquidations = summarizeAgentLiq(store.snapshot(time.Now().UTC()))
+	}
+
+	ac.News = GetRecentNews(10)
+	ac.Risk = GetRiskStatus()
+	ac.KillSwitch = GetKillSwitchStatus()
+	ac.VaR = GetVarStatus()
+	return ac
+}
+
+// agentPositions 当前持仓（模拟模式读取纸面持仓）
+func agentPositions(ctx context.Context) ([]AgentPosition, error) {
+	var out []AgentPosition
+	if IsDryRun() {
+		for _, p := range GetPaperPositions() {
+			mark, _ := GetPriceCache().GetPrice(p.Symbol)
+			pnl := 0.0
+			if mark > 0 {
+				pnl = (mark - p.EntryPrice) * p.Quantity
+				if p.Side == "SHORT" {
+					pnl = -pnl
+				}
+			}
+			out = append(out, AgentPosition{
+				Symbol:        p.Symbol,
+				Side:          p.Side,
+				PositionSide:  p.Side,
+				Qty:           p.Quantity,
+				EntryPrice:    p.EntryPrice,
+				MarkPrice:     mark,
+				UnrealizedPnl: pnl,
+				Leverage:      p.Leverage,
+			})
+		}
+		return out, nil
+	}
+
+	positions, err := GetPositions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range positions {
+		amt := mustParseFloat(p.PositionAmt)
+		side := "LONG"
+		if p.PositionSide == "SHORT" || (p.PositionSide != "LONG" && amt < 0) {
+			side = "SHORT"
+		}
+		lev, _ := strconv.Atoi(p.Leverage)
+		out = append(out, AgentPosition{
+			Symbol:        p.Symbol,
+			Side:          side,
+			PositionSide:  p.PositionSide,
+			Qty:           math.Abs(amt),
+			EntryPrice:    mustParseFloat(p.EntryPrice),
+			MarkPrice:     mustParseFloat(p.MarkPrice),
+			UnrealizedPnl: mustParseFloat(p.UnRealizedProfit),
+			Leverage:      lev,
+		})
+	}
+	return out, nil
+}
+
+func summarizeAgentSR(sr *SRResponse) *AgentSRSummary {
+	if sr == nil {
+		return nil
+	}
+	s := &AgentSRSummary{CurrentPrice: sr.CurrentPrice}
+	for i, l := range sr.Supports {
+		if i >= 3 {
+			break
+		}
+		s.Supports = append(s.Supports, l.Price)
+	}
+	for i, l := range sr.Resistances {
+		if i >= 3 {
+			break
+		}
+		s.Resistances = append(s.Resistances, l.Price)
+	}
+	if sr.ClosestSupport != nil {
+		s.ClosestSupport = sr.ClosestSupport.Price
+	}
+	if sr.ClosestResist != nil {
+		s.ClosestResist = sr.ClosestResist.Price
+	}
+	return s
+}
+
+// agentRecommendations 缓存中的推荐信号：目标币种优先，其余按置信度取前 n 条
+func agentRecommendations(symbol string, n int) []AgentRecommend {
+	finalCache.RLock()
+	resp := finalCache.resp
+	finalCache.RUnlock()
+	if resp == nil {
+		return nil
+	}
+	items := append([]RecommendItem(nil), resp.Items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		if (items[i].Symbol == symbol) != (items[j].Symbol == symbol) {
+			return items[i].Symbol == symbol
+		}
+		return items[i].Confidence > items[j].Confidence
+	})
+	var out []AgentRecommend
+	for _, it := range items {
+		if len(out) >= n {
+			break
+		}
+		out = append(out, AgentRecommend{
+			Symbol:     it.Symbol,
+			Direction:  it.Direction,
+			Confidence: it.Confidence,
+			Entry:      it.Entry,
+			StopLoss:   it.StopLoss,
+			TakeProfit: it.TakeProfit,
+			Reasons:    it.Reasons,
+		})
+	}
+	return out
+}
+
+// agentFunding 目标币种费率 + 全市场绝对值最大的 n 个
+func agentFunding(rates []FundingRateItem, symbol string, n int) []FundingRateItem {
+	var out []FundingRateItem
+	others := make([]FundingRateItem, 0, len(rates))
+	for _, r := range rates {
+		if strings.EqualFold(r.Symbol, symbol) {
+			out = append(out, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return math.Abs(others[i].FundingRate) > math.Abs(others[j].FundingRate)
+	})
+	if len(others) > n {
+		others = others[:n]
+	}
+	return append(out, others...)
+}
+
+func summarizeAgentLiq(snap liquidationStatsPayload) *AgentLiqSummary {
+	s := &AgentLiqSummary{TopSymbols: snap.TopSymbols.H1}
+	if len(snap.Stats.H1) > 0 {
+		s.H1Long = snap.Stats.H1[0].SellNotional
+		s.H1Short = snap.Stats.H1[0].BuyNotional
+	}
+	if len(snap.Stats.H4) > 0 {
+		s.H4Long = snap.Stats.H4[0].SellNotional
+		s.H4Short = snap.Stats.H4[0].BuyNotional
+	}
+	return s
+}
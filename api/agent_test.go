@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAgentReply(t *testing.T) {
+	reply := "分析如下：\n```json\n{\"summary\":\"趋势向上\",\"actions\":[{\"type\":\"OPEN\",\"symbol\":\"ethusdt\",\"side\":\"long\",\"amountUSDT\":20,\"leverage\":3}]}\n```"
+	summary, actions, err := parseAgentReply(reply)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary != "趋势向上" || len(actions) != 1 || actions[0].AmountUSDT != 20 {
+		t.Fatalf("parsed summary=%q actions=%+v", summary, actions)
+	}
+	if _, _, err := parseAgentReply("no json here"); err == nil {
+		t.Fatalf("expected error for reply without json")
+	}
+}
+
+func TestValidateAgentAction(t *testing.T) {
+	positions := []AgentPosition{{Symbol: "BTCUSDT", Side: "LONG", PositionSide: "BOTH", Qty: 0.1, EntryPrice: 60000, MarkPrice: 62000}}
+
+	cases := []struct {
+		name  string
+		act   AgentAction
+		valid bool
+	}{
+		{"open", AgentAction{Type: "OPEN", Symbol: "ethusdt", Side: "short", AmountUSDT: 10}, true},
+		{"wait", AgentAction{Type: "wait"}, true},
+		{"add same side", AgentAction{Type: "add", Side: "LONG", AmountUSDT: 10, Leverage: 5}, true},
+		{"add without position", AgentAction{Type: "add", Symbol: "ETHUSDT", Side: "LONG", AmountUSDT: 10}, false},
+		{"open against position", AgentAction{Type: "open", Side: "SHORT", AmountUSDT: 10}, false},
+		{"leverage too high", AgentAction{Type: "open", Symbol: "ETHUSDT", Side: "LONG", AmountUSDT: 10, Leverage: 50}, false},
+		{"reduce", AgentAction{Type: "reduce", Percent: 50}, true},
+		{"reduce bad percent", AgentAction{Type: "reduce", Percent: 150}, false},
+		{"close no position", AgentAction{Type: "close", Symbol: "SOLUSDT"}, false},
+		{"set_sl below mark", AgentAction{Type: "set_sl", Price: 59000}, true},
+		{"set_sl above mark", AgentAction{Type: "set_sl", Price: 63000}, false},
+		{"set_tp above mark", AgentAction{Type: "set_tp", Price: 65000}, true},
+		{"unknown", AgentAction{Type: "hedge"}, false},
+	}
+	for _, c := range cases {
+		a := c.act
+		validateAgentAction(&a, "BTCUSDT", positions, 62000)
+		if a.Valid != c.valid {
+			t.Fatalf("%s: valid=%v want %v (rejected=%q)", c.name, a.Valid, c.valid, a.Rejected)
+		}
+	}
+
+	// 币种熔断后所有动作均被拒绝
+	ActivateKillSwitch(KSSymbol, "BTCUSDT", "test")
+	defer DeactivateKillSwitch(KSSymbol, "BTCUSDT")
+	a := AgentAction{Type: "close"}
+	validateAgentAction(&a, "BTCUSDT", positions, 62000)
+	if a.Valid || !strings.Contains(a.Rejected, "kill-switch") {
+		t.Fatalf("kill switch should reject action: %+v", a)
+	}
+}
+
+func TestLLMChatStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{`{\"summary\":`, `\"ok\",`, `\"actions\":[]}`} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", part)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var deltas []string
+	m := &LLMModel{BaseURL: srv.URL, Model: "stub"}
+	full, err := llmChatStream(context.Background(), m, []llmMessage{{Role: "user", Content: "hi"}}, 5*time.Second, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deltas) != 3 || full != `{"summary":"ok","actions":[]}` {
+		t.Fatalf("deltas=%v full=%q", deltas, full)
+	}
+	if summary, actions, err := parseAgentReply(full); err != nil || summary != "ok" || len(actions) != 0 {
+		t.Fatalf("streamed reply parse: %q %v %v", summary, actions, err)
+	}
+}
+
+func TestValidateAgentActionRiskLockHasNoSideEffects(t *testing.T) {
+	withRiskConfig(t, RiskConfig{Enabled: true})
+	risk.mu.Lock()
+	origLocked, origReason, origDay := risk.locked, risk.lockReason, risk.lastResetDay
+	risk.locked, risk.lockReason, risk.lastResetDay = true, "test", today()
+	risk.mu.Unlock()
+	defer func() {
+		risk.mu.Lock()
+		risk.locked, risk.lockReason, risk.lastResetDay = origLocked, origReason, origDay
+		risk.mu.Unlock()
+	}()
+	origOps := ops
+	ops = &opsMetricsState{}
+	defer func() { ops = origOps }()
+
+	a := AgentAction{Type: "open", Symbol: "ETHUSDT", Side: "LONG", AmountUSDT: 10}
+	validateAgentAction(&a, "BTCUSDT", nil, 0)
+	if a.Valid || !strings.Contains(a.Rejected, "风控锁定") {
+		t.Fatalf("risk lock should reject open: %+v", a)
+	}
+	if ops.riskTriggers != 0 {
+		t.Fatalf("validation recorded %d risk triggers", ops.riskTriggers)
+	}
+}
@@ -31,6 +31,18 @@ type AutoScaleConfig struct {
 	UpdateTPSL     bool    `json:"updateTPSL,omitempty"`     // 加仓后是否重新计算 TP/SL
 	StopLossAmount float64 `json:"stopLossAmount,omitempty"` // 止损金额(USDT)，updateTPSL=true 时使用
 	RiskReward     float64 `json:"riskReward,omitempty"`     // 盈亏比，updateTPSL=true 时使用
+
+	// 金字塔加仓（可选）：mode=sr 突破阻力/支撑位时加仓，mode=atr 价格每朝有利方向移动 atrMultiple×ATR 时加仓
+	// 默认 profit 即上面的浮盈触发；sr/atr 模式下止损由本地止盈止损引擎自动上移
+	Mode              string  `json:"mode,omitempty"`              // profit / sr / atr
+	ATRMultiple       float64 `json:"atrMultiple,omitempty"`       // atr 模式加仓间距（ATR 倍数），默认 1
+	ATRInterval       string  `json:"atrInterval,omitempty"`       // ATR K 线周期，默认 1h
+	ATRPeriod         int     `json:"atrPeriod,omitempty"`         // ATR 周期，默认 14
+	StopATR           float64 `json:"stopATR,omitempty"`           // 结构止损距离：加仓价（sr 模式为突破位）外 stopATR×ATR，默认 1
+	BreakoutBufferPct float64 `json:"breakoutBufferPct,omitempty"` // sr 模式突破确认缓冲（%），默认 0.1
+	SizeDecay         float64 `json:"sizeDecay,omitempty"`         // 加仓金额递减系数：第 n 次 = addQuantity × sizeDecay^(n-1)，默认 1（不递减）
+	RiskNeutral       bool    `json:"riskNeutral,omitempty"`       // 加仓后合并止损处的总亏损不得超过初始风险，超出时缩减加仓量或跳过
+	InitialStopPrice  float64 `json:"initialStopPrice,omitempty"`  // 初始止损价，为空时取该币种当前活跃的本地止损
 }
 
 // AutoScaleStatus 返回给用户的加仓任务状态（不含内部 channel）
@@ -41,6 +53,15 @@ type AutoScaleStatus struct {
 	Active     bool            `json:"active"`     // 是否正在运行
 	LastAlgoTP int64           `json:"lastAlgoTP"` // 最新止盈 AlgoID
 	LastAlgoSL int64           `json:"lastAlgoSL"` // 最新止损 AlgoID
+
+	Adds        []AutoScaleAdd `json:"adds"`                  // 每次加仓记录
+	InitialRisk float64        `json:"initialRisk,omitempty"` // 初始风险（USDT）：初始仓位在初始止损处的亏损
+	StopPrice   float64        `json:"stopPrice,omitempty"`   // 当前合并止损价
+	StopGroupID string         `json:"stopGroupId,omitempty"` // 本地止损条件分组
+	NextTrigger float64        `json:"nextTrigger,omitempty"` // sr/atr 模式下一次加仓触发价
+	CurrentRisk float64        `json:"currentRisk,omitempty"` // 当前仓位在止损处的亏损（USDT）
+	LastSkipped string         `json:"lastSkipped,omitempty"` // 最近一次因风险约束跳过加仓的原因
+	Blocked     string         `json:"blocked,omitempty"`     // 后续加仓被停止的原因（撤回加仓失败等）
 }
 
 // autoScaleState 运行中的加仓任务内部状态
@@ -52,6 +73,8 @@ type autoScaleState struct {
 	LastAlgoTP int64
 	LastAlgoSL int64
 	stopC      chan struct{}
+
+	pyramid pyramidState
 }
 
 var (
@@ -77,12 +100,12 @@ func StartAutoScale(config AutoScaleConfig) error {
 	if config.Leverage <= 0 {
 		return fmt.Errorf("leverage must be > 0")
 	}
-	if config.TriggerAmount <= 0 && config.TriggerPercent <= 0 {
-		return fmt.Errorf("triggerAmount or triggerPercent is required")
-	}
 	if config.TriggerAmount > 0 && config.TriggerPercent > 0 {
 		return fmt.Errorf("triggerAmount and triggerPercent cannot be set at the same time")
 	}
+	if err := normalizePyramidConfig(&config); err != nil {
+		return err
+	}
 	if config.UpdateTPSL {
 		if config.StopLossAmount <= 0 {
 			return fmt.Errorf("stopLossAmount is required when updateTPSL is true")
@@ -146,13 +169,22 @@ func GetAutoScaleStatus(symbol string) *AutoScaleStatus {
 		return nil
 	}
 
+	p := state.pyramid
 	return &AutoScaleStatus{
-		Config:     state.Config,
-		ScaleCount: state.ScaleCount,
-		TotalAdded: state.TotalAdded,
-		Active:     state.Active,
-		LastAlgoTP: state.LastAlgoTP,
-		LastAlgoSL: state.LastAlgoSL,
+		Config:      state.Config,
+		ScaleCount:  state.ScaleCount,
+		TotalAdded:  state.TotalAdded,
+		Active:      state.Active,
+		LastAlgoTP:  state.LastAlgoTP,
+		LastAlgoSL:  state.LastAlgoSL,
+		Adds:        append([]AutoScaleAdd{}, p.adds...),
+		InitialRisk: roundFloat(p.initialRisk, 4),
+		StopPrice:   p.stopPrice,
+		StopGroupID: p.stopGroupID,
+		NextTrigger: p.nextTrigger,
+		CurrentRisk: roundFloat(p.currentRisk, 4),
+		LastSkipped: p.lastSkipped,
+		Blocked:     p.blocked,
 	}
 }
 
@@ -199,12 +231,24 @@ func monitorAndScale(state *autoScaleState) {
 				return
 			}
 
+			// 接管止损的模式：首次确定初始止损与初始风险
+			if cfg.managesStop() {
+				if err := initPyramid(ctx, state, position); err != nil {
+					skipPyramidAdd(state, err.Error(), 0)
+					continue
+				}
+				refreshPyramidLevels(ctx, state)
+			}
+
 			// 获取浮盈
 			unrealizedProfit, _ := strconv.ParseFloat(position.UnRealizedProfit, 64)
 
 			// 判断是否触发加仓
 			shouldScale := false
-			if cfg.TriggerAmount > 0 {
+			trigger := cfg.Mode
+			if cfg.Mode == AutoScaleModeSR || cfg.Mode == AutoScaleModeATR {
+				shouldScale, trigger = checkPyramidTrigger(ctx, state, position)
+			} else if cfg.TriggerAmount > 0 {
 				// 金额模式：浮盈 >= triggerAmount × (已加仓次数+1)
 				threshold := cfg.TriggerAmount * float64(state.ScaleCount+1)
 				if unrealizedProfit >= threshold {
@@ -235,7 +279,7 @@ func monitorAndScale(state *autoScaleState) {
 			}
 
 			// 执行加仓
-			err = executeScaleIn(ctx, state)
+			err = executeScaleIn(ctx, state, position, trigger)
 			if err != nil {
 				log.Printf("[AutoScale] Error scaling in for %s: %v", cfg.Symbol, err)
 				continue
@@ -245,20 +289,36 @@ func monitorAndScale(state *autoScaleState) {
 }
 
 // executeScaleIn 执行一次加仓操作
-func executeScaleIn(ctx context.Context, state *autoScaleState) error {
+func executeScaleIn(ctx context.Context, state *autoScaleState, position *futures.PositionRisk, trigger string) error {
 	cfg := state.Config
+	margin := pyramidAddMargin(cfg, state.ScaleCount)
+
+	// 接管止损时先计算结构止损，风险中性下按初始风险缩减加仓量
+	var plan pyramidPlan
+	if cfg.managesStop() {
+		if blocked := state.pyramid.blocked; blocked != "" {
+			skipPyramidAdd(state, "scale-in blocked: "+blocked, 0)
+			return nil
+		}
+		plan = planPyramidAdd(state, position, margin)
+		if plan.skip != "" {
+			skipPyramidAdd(state, plan.skip, mustParseFloat(position.MarkPrice))
+			return nil
+		}
+		margin = plan.margin
+	}
 
-	log.Printf("[AutoScale] Executing scale-in #%d for %s: %s USDT",
-		state.ScaleCount+1, cfg.Symbol, cfg.AddQuantity)
+	log.Printf("[AutoScale] Executing scale-in #%d for %s: %.4f USDT (%s)",
+		state.ScaleCount+1, cfg.Symbol, margin, trigger)
 
 	// 构建加仓请求（不带止盈止损，TP/SL单独处理）
 	scaleReq := PlaceOrderReq{
-		Source:        "strategy_autoscale",
+		Source:        autoScaleStrategySource,
 		Symbol:        cfg.Symbol,
 		Side:          cfg.Side,
 		OrderType:     futures.OrderTypeMarket,
 		PositionSide:  cfg.PositionSide,
-		QuoteQuantity: cfg.AddQuantity,
+		QuoteQuantity: strconv.FormatFloat(margin, 'f', 4, 64),
 		Leverage:      cfg.Leverage,
 	}
 
@@ -269,16 +329,37 @@ func executeScaleIn(ctx context.Context, state *autoScaleState) error {
 
 	// 更新状态
 	autoScaleMu.Lock()
-	addQty, _ := strconv.ParseFloat(cfg.AddQuantity, 64)
 	state.ScaleCount++
-	state.TotalAdded += addQty
+	state.TotalAdded += margin
 	autoScaleMu.Unlock()
 
+	add := AutoScaleAdd{
+		Index:   state.ScaleCount,
+		Time:    time.Now().Format(time.RFC3339),
+		Trigger: trigger,
+		Margin:  roundFloat(margin, 4),
+		Price:   mustParseFloat(position.MarkPrice),
+		Capped:  plan.capped,
+	}
+	if result != nil && result.Order != nil {
+		add.OrderID = result.Order.OrderID
+		if avg := mustParseFloat(result.Order.AvgPrice); avg > 0 {
+			add.Price = avg
+		}
+		add.Quantity = mustParseFloat(result.Order.ExecutedQuantity)
+	}
+	if add.Quantity == 0 && add.Price > 0 {
+		add.Quantity = margin * float64(cfg.Leverage) / add.Price
+	}
+
 	log.Printf("[AutoScale] Scale-in #%d success for %s: orderId=%d, total scaled=%d/%d",
-		state.ScaleCount, cfg.Symbol, result.Order.OrderID, state.ScaleCount, cfg.MaxScaleCount)
+		state.ScaleCount, cfg.Symbol, add.OrderID, state.ScaleCount, cfg.MaxScaleCount)
 
-	// 如果需要更新止盈止损
-	if cfg.UpdateTPSL {
+	if cfg.managesStop() {
+		// 整体止损移动到计划止损并校验实际风险，失败或超出初始风险时撤回加仓
+		securePyramidAdd(ctx, state, plan, &add)
+	} else if cfg.UpdateTPSL {
+		// 如果需要更新止盈止损
 		err = updateTPSLAfterScale(ctx, state)
 		if err != nil {
 			log.Printf("[AutoScale] Warning: failed to update TP/SL after scale-in: %v", err)
@@ -286,6 +367,19 @@ func executeScaleIn(ctx context.Context, state *autoScaleState) error {
 		}
 	}
 
+	autoScaleMu.Lock()
+	p := &state.pyramid
+	p.adds = append(p.adds, add)
+	p.lastSkipped = ""
+	if cfg.Mode != AutoScaleModeProfit {
+		// 下一档从本次加仓价（sr 模式为已突破价位之后）重新计算
+		p.refPrice = add.Price
+		p.level = 0
+		p.nextTrigger = 0
+		p.refreshedAt = time.Time{}
+	}
+	autoScaleMu.Unlock()
+
 	return nil
 }
 
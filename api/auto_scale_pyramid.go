@@ -0,0 +1,546 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// ========== 金字塔加仓 ==========
+// sr：价格突破下一档阻力（空头为支撑）时加仓；atr：价格自上次加仓价朝有利方向移动 atrMultiple×ATR 时加仓
+// 加仓金额按 sizeDecay 递减；加仓后把整体止损移动到结构止损（只收紧不放松），
+// riskNeutral 时按 总亏损(止损处) ≤ 初始风险 反推加仓量上限
+
+const (
+	AutoScaleModeProfit = "profit"
+	AutoScaleModeSR     = "sr"
+	AutoScaleModeATR    = "atr"
+
+	autoScaleMinNotional    = 5.0 // 低于该名义价值（USDT）的加仓视为无效，跳过
+	pyramidRefreshInterval  = 5 * time.Minute
+	pyramidSkipLogInterval  = time.Minute
+	pyramidStopRetries      = 3
+	pyramidStopRetryDelay   = 2 * time.Second
+	pyramidRiskTolerance    = 1e-6 // 风险比较容差（USDT），避免浮点误差触发撤回
+	autoScaleStrategySource = "strategy_autoscale"
+)
+
+// AutoScaleAdd 单次加仓记录
+type AutoScaleAdd struct {
+	Index       int     `json:"index"` // 第几次加仓（从 1 开始）
+	Time        string  `json:"time"`
+	Trigger     string  `json:"trigger"` // 触发原因
+	OrderID     int64   `json:"orderId"`
+	Price       float64 `json:"price"`                // 加仓价（成交均价，缺失时为标记价）
+	Quantity    float64 `json:"quantity"`             // 加仓数量（币）
+	Margin      float64 `json:"margin"`               // 加仓保证金（USDT）
+	Capped      bool    `json:"capped"`               // 是否因风险约束缩减了加仓量
+	AvgEntry    float64 `json:"avgEntry"`             // 加仓后持仓均价
+	PositionQty float64 `json:"positionQty"`          // 加仓后持仓数量
+	StopPrice   float64 `json:"stopPrice"`            // 加仓后合并止损价
+	RiskAfter   float64 `json:"riskAfter"`            // 加仓后止损处的总亏损（USDT），负数表示已锁定利润
+	UnwoundQty  float64 `json:"unwoundQty,omitempty"` // 因止损未能移动或风险超出初始风险而撤回的数量（币）
+}
+
+// pyramidState 金字塔加仓运行状态（由监控协程写入，写入时持有 autoScaleMu）
+type pyramidState struct {
+	initialized bool
+	dir         float64 // 多 +1 / 空 -1
+	initialRisk float64
+	stopPrice   float64
+	stopGroupID string
+	currentRisk float64
+
+	refPrice    float64 // atr 模式起算价：初始均价或上次加仓价
+	level       float64 // sr 模式待突破价位
+	atr         float64
+	nextTrigger float64
+	refreshedAt time.Time
+
+	lastSkipped string
+	skipLogAt   time.Time
+	blocked     string // 非空时停止后续加仓（撤回失败等需人工处理的情况）
+	adds        []AutoScaleAdd
+}
+
+// 测试注入点
+var (
+	pyramidMoveStop   = movePyramidStop
+	pyramidUnwindAdd  = unwindPyramidAdd
+	pyramidRetryDelay = pyramidStopRetryDelay
+)
+
+// normalizePyramidConfig 校验加仓模式并补全默认值
+func normalizePyramidConfig(cfg *AutoScaleConfig) error {
+	cfg.Mode = strings.ToLower(strings.TrimSpace(cfg.Mode))
+	if cfg.Mode == "" {
+		cfg.Mode = AutoScaleModeProfit
+	}
+	switch cfg.Mode {
+	case AutoScaleModeProfit:
+		if cfg.TriggerAmount <= 0 && cfg.TriggerPercent <= 0 {
+			return fmt.Errorf("triggerAmount or triggerPercent is required")
+		}
+	case AutoScaleModeSR, AutoScaleModeATR:
+	default:
+		return fmt.Errorf("mode must be profit, sr or atr")
+	}
+
+	if cfg.SizeDecay == 0 {
+		cfg.SizeDecay = 1
+	}
+	if cfg.SizeDecay < 0 || cfg.SizeDecay > 1 {
+		return fmt.Errorf("sizeDecay must be in (0, 1]")
+	}
+	if cfg.ATRMultiple <= 0 {
+		cfg.ATRMultiple = 1
+	}
+	if cfg.ATRInterval == "" {
+		cfg.ATRInterval = "1h"
+	}
+	if cfg.ATRPeriod <= 0 {
+		cfg.ATRPeriod = 14
+	}
+	if cfg.StopATR <= 0 {
+		cfg.StopATR = 1
+	}
+	if cfg.BreakoutBufferPct <= 0 {
+		cfg.BreakoutBufferPct = 0.1
+	}
+	if cfg.InitialStopPrice < 0 {
+		return fmt.Errorf("initialStopPrice must be >= 0")
+	}
+	if cfg.managesStop() && cfg.UpdateTPSL {
+		return fmt.Errorf("updateTPSL cannot be combined with sr/atr mode or riskNeutral, the stop is managed by local TP/SL")
+	}
+	return nil
+}
+
+// managesStop 是否由加仓任务接管整体止损
+func (c AutoScaleConfig) managesStop() bool {
+	return c.Mode == AutoScaleModeSR || c.Mode == AutoScaleModeATR || c.RiskNeutral
+}
+
+// pyramidAddMargin 第 n 次（从 0 起）加仓的计划保证金
+func pyramidAddMargin(cfg AutoScaleConfig, n int) float64 {
+	base, _ := strconv.ParseFloat(cfg.AddQuantity, 64)
+	decay := cfg.SizeDecay
+	if decay <= 0 {
+		decay = 1
+	}
+	return base * math.Pow(decay, float64(n))
+}
+
+// positionRiskAt 持仓在止损处的亏损（USDT），负数表示止损已越过均价、锁定利润
+func positionRiskAt(dir, qty, entry, stop float64) float64 {
+	return qty * dir * (entry - stop)
+}
+
+// pyramidMaxAddQty 在止损 stop 下、总亏损不超过 budget 时以 price 最多可加的数量
+// 约束：Q·dir·(E−S) + q·dir·(P−S) ≤ budget
+func pyramidMaxAddQty(dir, qty, entry, price, stop, budget float64) float64 {
+	perUnit := dir * (price - stop)
+	if perUnit <= 0 {
+		return 0
+	}
+	q := (budget - positionRiskAt(dir, qty, entry, stop)) / perUnit
+	if q < 0 {
+		return 0
+	}
+	return q
+}
+
+// tighterStop 取对持仓更有利（更收紧）的止损；0 视为未设置
+func tighterStop(dir, a, b float64) float64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	if dir > 0 {
+		return math.Max(a, b)
+	}
+	return math.Min(a, b)
+}
+
+// nextSRLevel 多头取 ref 上方最近的阻力，空头取 ref 下方最近的支撑；没有时返回 0
+func nextSRLevel(resp *SRResponse, dir, ref float64) float64 {
+	if resp == nil {
+		return 0
+	}
+	levels := resp.Resistances
+	if dir < 0 {
+		levels = resp.Supports
+	}
+	best := 0.0
+	for _, l := range levels {
+		if l.Price <= 0 || dir*(l.Price-ref) <= 0 {
+			continue
+		}
+		if best == 0 || dir*(l.Price-best) < 0 {
+			best = l.Price
+		}
+	}
+	return best
+}
+
+// findAdoptableStop 查找该持仓方向上当前活跃的本地止损（取最收紧的一条）
+func findAdoptableStop(cfg AutoScaleConfig, dir float64) *LocalTPSLCondition {
+	closeSide := "SELL"
+	if dir < 0 {
+		closeSide = "BUY"
+	}
+	posSide := string(cfg.PositionSide)
+	if posSide == "" {
+		posSide = string(futures.PositionSideTypeBoth)
+	}
+	var best *LocalTPSLCondition
+	for _, c := range GetActiveTPSLConditions(cfg.Symbol) {
+		if c == nil || c.ConditionType != "STOP_LOSS" || c.Side != closeSide || c.PositionSide != posSide {
+			continue
+		}
+		if best == nil || tighterStop(dir, c.TriggerPrice, best.TriggerPrice) == c.TriggerPrice {
+			best = c
+		}
+	}
+	return best
+}
+
+// formatPositionQty 按交易对精度格式化持仓数量
+func formatPositionQty(ctx context.Context, symbol string, qty float64) (string, error) {
+	precision, stepSize, err := getSymbolPrecision(ctx, symbol)
+	if err != nil {
+		return "", fmt.Errorf("get symbol precision: %w", err)
+	}
+	return formatQuantity(roundToStepSize(qty, stepSize), precision), nil
+}
+
+// initPyramid 首次运行时确定初始止损与初始风险；未找到止损时返回错误，下一轮重试
+func initPyramid(ctx context.Context, state *autoScaleState, position *futures.PositionRisk) error {
+	if state.pyramid.initialized {
+		return nil
+	}
+	cfg := state.Config
+	dir := 1.0
+	if cfg.Side == futures.SideTypeSell {
+		dir = -1
+	}
+	qty := math.Abs(mustParseFloat(position.PositionAmt))
+	entry := mustParseFloat(position.EntryPrice)
+	mark := mustParseFloat(position.MarkPrice)
+
+	stop, groupID := cfg.InitialStopPrice, ""
+	if cond := findAdoptableStop(cfg, dir); cond != nil {
+		if stop <= 0 {
+			stop = cond.TriggerPrice
+		}
+		groupID = cond.GroupID
+	}
+	if stop <= 0 {
+		return fmt.Errorf("initialStopPrice is required when no active local stop loss exists")
+	}
+	if mark > 0 && dir*(mark-stop) <= 0 {
+		return fmt.Errorf("initial stop %.4f already crossed by mark price %.4f", stop, mark)
+	}
+
+	closeSide := "SELL"
+	if dir < 0 {
+		closeSide = "BUY"
+	}
+	qtyStr, err := formatPositionQty(ctx, cfg.Symbol, qty)
+	if err != nil {
+		return err
+	}
+	groupID, err = MoveLocalStopLoss(groupID, cfg.Symbol, closeSide, string(cfg.PositionSide), qtyStr, stop, entry, autoScaleStrategySource)
+	if err != nil {
+		return fmt.Errorf("register stop loss: %w", err)
+	}
+
+	autoScaleMu.Lock()
+	p := &state.pyramid
+	p.initialized = true
+	p.dir = dir
+	p.initialRisk = math.Max(0, positionRiskAt(dir, qty, entry, stop))
+	p.currentRisk = positionRiskAt(dir, qty, entry, stop)
+	p.stopPrice = stop
+	p.stopGroupID = groupID
+	p.refPrice = entry
+	autoScaleMu.Unlock()
+
+	log.Printf("[AutoScale] %s pyramid initialised: entry=%.4f qty=%.6f stop=%.4f initialRisk=%.4f USDT",
+		cfg.Symbol, entry, qty, stop, p.initialRisk)
+	return nil
+}
+
+// refreshPyramidLevels 定期刷新 ATR 与 sr 模式的待突破价位，并计算下一次加仓触发价
+func refreshPyramidLevels(ctx context.Context, state *autoScaleState) {
+	p := &state.pyramid
+	if !p.refreshedAt.IsZero() && time.Since(p.refreshedAt) < pyramidRefreshInterval {
+		return
+	}
+	cfg := state.Config
+
+	atr := p.atr
+	klines, err := Client.NewKlinesService().Symbol(cfg.Symbol).Interval(cfg.ATRInterval).Limit(cfg.ATRPeriod + 50).Do(ctx)
+	if err != nil {
+		log.Printf("[AutoScale] %s fetch ATR klines failed: %v", cfg.Symbol, err)
+	} else if v := calcATR(klines, cfg.ATRPeriod); v > 0 {
+		atr = v
+	}
+
+	level := p.level
+	if cfg.Mode == AutoScaleModeSR {
+		if resp, err := GetSRLevels(ctx, cfg.Symbol); err != nil {
+			log.Printf("[AutoScale] %s fetch SR levels failed: %v", cfg.Symbol, err)
+		} else {
+			level = nextSRLevel(resp, p.dir, p.refPrice)
+		}
+	}
+
+	next := 0.0
+	switch cfg.Mode {
+	case AutoScaleModeATR:
+		if atr > 0 {
+			next = p.refPrice + p.dir*cfg.ATRMultiple*atr
+		}
+	case AutoScaleModeSR:
+		if level > 0 {
+			next = level * (1 + p.dir*cfg.BreakoutBufferPct/100)
+		}
+	}
+
+	autoScaleMu.Lock()
+	p.atr = atr
+	p.level = level
+	p.nextTrigger = next
+	p.refreshedAt = time.Now()
+	autoScaleMu.Unlock()
+}
+
+// checkPyramidTrigger sr/atr 模式：标记价越过下一次加仓触发价
+func checkPyramidTrigger(ctx context.Context, state *autoScaleState, position *futures.PositionRisk) (bool, string) {
+	refreshPyramidLevels(ctx, state)
+	p := &state.pyramid
+	mark := mustParseFloat(position.MarkPrice)
+	if p.nextTrigger <= 0 || mark <= 0 || p.dir*(mark-p.nextTrigger) < 0 {
+		return false, ""
+	}
+	if state.Config.Mode == AutoScaleModeSR {
+		return true, fmt.Sprintf("sr breakout %.4f", p.level)
+	}
+	return true, fmt.Sprintf("atr %.2fx from %.4f", state.Config.ATRMultiple, p.refPrice)
+}
+
+// pyramidPlan 一次加仓的计划
+type pyramidPlan struct {
+	price  float64
+	qty    float64
+	margin float64
+	stop   float64
+	capped bool
+	skip   string
+}
+
+// planPyramidAdd 计算结构止损与（风险中性时）加仓量上限
+func planPyramidAdd(state *autoScaleState, position *futures.PositionRisk, margin float64) pyramidPlan {
+	cfg := state.Config
+	p := state.pyramid
+	price := mustParseFloat(position.MarkPrice)
+	qty := math.Abs(mustParseFloat(position.PositionAmt))
+	entry := mustParseFloat(position.EntryPrice)
+	if price <= 0 || qty <= 0 || cfg.Leverage <= 0 {
+		return pyramidPlan{skip: "invalid position data"}
+	}
+
+	// 结构止损：atr/profit 模式在加仓价外 stopATR×ATR，sr 模式在突破位外 stopATR×ATR
+	if p.atr <= 0 {
+		return pyramidPlan{skip: "ATR unavailable"}
+	}
+	anchor := price
+	if cfg.Mode == AutoScaleModeSR && p.level > 0 {
+		anchor = p.level
+	}
+	stop := tighterStop(p.dir, anchor-p.dir*cfg.StopATR*p.atr, p.stopPrice)
+	if p.dir*(price-stop) <= 0 {
+		return pyramidPlan{skip: fmt.Sprintf("stop %.4f is not behind price %.4f", stop, price)}
+	}
+
+	plan := pyramidPlan{price: price, stop: stop, qty: margin * float64(cfg.Leverage) / price}
+	if cfg.RiskNeutral {
+		if maxQty := pyramidMaxAddQty(p.dir, qty, entry, price, stop, p.initialRisk); maxQty < plan.qty {
+			plan.qty = maxQty
+			plan.capped = true
+		}
+	}
+	if plan.qty*price < autoScaleMinNotional {
+		return pyramidPlan{skip: fmt.Sprintf("risk budget exhausted at stop %.4f (max add %.6f)", stop, plan.qty)}
+	}
+	plan.margin = plan.qty * price / float64(cfg.Leverage)
+	return plan
+}
+
+// skipPyramidAdd 记录跳过原因；sr/atr 模式推进参考价，避免同一触发反复重试
+func skipPyramidAdd(state *autoScaleState, reason string, price float64) {
+	autoScaleMu.Lock()
+	p := &state.pyramid
+	changed := p.lastSkipped != reason
+	p.lastSkipped = reason
+	if state.Config.Mode != AutoScaleModeProfit && price > 0 {
+		p.refPrice = price
+		p.level = 0
+		p.nextTrigger = 0
+		p.refreshedAt = time.Time{}
+	}
+	logNow := changed || time.Since(p.skipLogAt) >= pyramidSkipLogInterval
+	if logNow {
+		p.skipLogAt = time.Now()
+	}
+	autoScaleMu.Unlock()
+	if logNow {
+		log.Printf("[AutoScale] %s skip scale-in: %s", state.Config.Symbol, reason)
+	}
+}
+
+// movePyramidStop 加仓成交后按最新持仓把整体止损移动到计划止损
+func movePyramidStop(ctx context.Context, state *autoScaleState, plan pyramidPlan, add *AutoScaleAdd) error {
+	cfg := state.Config
+	position, err := findPosition(ctx, cfg.Symbol, cfg.PositionSide)
+	if err != nil {
+		return fmt.Errorf("find position: %w", err)
+	}
+	qty := math.Abs(mustParseFloat(position.PositionAmt))
+	entry := mustParseFloat(position.EntryPrice)
+	if qty == 0 || entry == 0 {
+		return fmt.Errorf("invalid position data: entry=%.4f, amt=%.4f", entry, qty)
+	}
+	qtyStr, err := formatPositionQty(ctx, cfg.Symbol, qty)
+	if err != nil {
+		return err
+	}
+	closeSide := "SELL"
+	if state.pyramid.dir < 0 {
+		closeSide = "BUY"
+	}
+	groupID, err := MoveLocalStopLoss(state.pyramid.stopGroupID, cfg.Symbol, closeSide, string(cfg.PositionSide), qtyStr, plan.stop, entry, autoScaleStrategySource)
+	if err != nil {
+		return fmt.Errorf("move stop loss: %w", err)
+	}
+
+	risk := positionRiskAt(state.pyramid.dir, qty, entry, plan.stop)
+	autoScaleMu.Lock()
+	state.pyramid.stopPrice = plan.stop
+	state.pyramid.stopGroupID = groupID
+	state.pyramid.currentRisk = risk
+	autoScaleMu.Unlock()
+
+	add.AvgEntry = entry
+	add.PositionQty = qty
+	add.StopPrice = plan.stop
+	add.RiskAfter = roundFloat(risk, 4)
+	log.Printf("[AutoScale] %s stop moved to %.4f after scale-in: qty=%.6f avg=%.4f risk=%.4f/%.4f USDT",
+		cfg.Symbol, plan.stop, qty, entry, risk, state.pyramid.initialRisk)
+	return nil
+}
+
+// securePyramidAdd 加仓成交后移动整体止损并按实际成交校验风险：
+// 止损移动失败时重试，仍失败则撤回本次加仓；风险中性下滑点导致风险超出初始风险时撤回超出部分；
+// 撤回失败时停止后续加仓并告警
+func securePyramidAdd(ctx context.Context, state *autoScaleState, plan pyramidPlan, add *AutoScaleAdd) {
+	cfg := state.Config
+	var err error
+	for attempt := 0; attempt < pyramidStopRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(pyramidRetryDelay)
+		}
+		if err = pyramidMoveStop(ctx, state, plan, add); err == nil {
+			break
+		}
+		log.Printf("[AutoScale] %s move stop after scale-in failed (attempt %d/%d): %v",
+			cfg.Symbol, attempt+1, pyramidStopRetries, err)
+	}
+
+	stopMoved := err == nil
+	var unwindQty float64
+	var reason string
+	if !stopMoved {
+		unwindQty = add.Quantity
+		reason = fmt.Sprintf("stop move failed: %v", err)
+	} else if cfg.RiskNeutral {
+		unwindQty = pyramidExcessQty(state.pyramid.dir, add.RiskAfter, state.pyramid.initialRisk, add.AvgEntry, add.StopPrice, add.Quantity)
+		reason = fmt.Sprintf("risk %.4f exceeds initial risk %.4f USDT", add.RiskAfter, state.pyramid.initialRisk)
+	}
+	if unwindQty <= 0 {
+		return
+	}
+
+	log.Printf("[AutoScale] %s unwinding %.6f of scale-in #%d: %s", cfg.Symbol, unwindQty, add.Index, reason)
+	unwound, err := pyramidUnwindAdd(ctx, state, unwindQty, add.Quantity)
+	if err != nil {
+		blockPyramidAdds(state, fmt.Sprintf("%s; unwind %.6f failed: %v", reason, unwindQty, err))
+		return
+	}
+	add.UnwoundQty = unwound
+
+	// 止损已按加仓后数量移动：撤回后按剩余持仓重新设置止损数量与风险
+	if stopMoved {
+		if err := pyramidMoveStop(ctx, state, plan, add); err != nil {
+			blockPyramidAdds(state, fmt.Sprintf("%s; unwound %.6f but stop resize failed: %v", reason, unwound, err))
+			return
+		}
+	}
+	SendNotify(fmt.Sprintf("*金字塔加仓* %s 第%d次加仓已撤回 %.6f：%s", cfg.Symbol, add.Index, unwound, reason))
+}
+
+// pyramidExcessQty 加仓后止损处风险超出初始风险时需撤回的数量（不超过本次加仓量）
+// 减仓不改变均价，每撤回一单位减少 dir·(E−S) 的风险
+func pyramidExcessQty(dir, riskAfter, initialRisk, entry, stop, addQty float64) float64 {
+	excess := riskAfter - initialRisk
+	perUnit := dir * (entry - stop)
+	if excess <= pyramidRiskTolerance || perUnit <= 0 {
+		return 0
+	}
+	return math.Min(addQty, excess/perUnit)
+}
+
+// unwindPyramidAdd 市价减仓撤回加仓，数量按步长向上取整（不超过 maxQty），返回实际撤回数量
+func unwindPyramidAdd(ctx context.Context, state *autoScaleState, qty, maxQty float64) (float64, error) {
+	cfg := state.Config
+	precision, stepSize, err := getSymbolPrecision(ctx, cfg.Symbol)
+	if err != nil {
+		return 0, fmt.Errorf("get symbol precision: %w", err)
+	}
+	if stepSize > 0 {
+		qty = math.Min(math.Ceil(qty/stepSize-1e-9)*stepSize, roundToStepSize(maxQty, stepSize))
+	}
+	if qty <= 0 {
+		return 0, fmt.Errorf("unwind quantity below step size")
+	}
+	_, err = ReducePositionViaWs(ctx, ReducePositionReq{
+		Symbol:       cfg.Symbol,
+		PositionSide: cfg.PositionSide,
+		Quantity:     formatQuantity(qty, precision),
+	})
+	if err != nil {
+		SaveFailedOperation("REDUCE_POSITION", autoScaleStrategySource, cfg.Symbol, map[string]interface{}{
+			"quantity": qty, "reason": "pyramid_unwind",
+		}, 0, err)
+		return 0, err
+	}
+	return qty, nil
+}
+
+// blockPyramidAdds 停止后续加仓并告警，需人工确认仓位与止损后重启任务
+func blockPyramidAdds(state *autoScaleState, reason string) {
+	autoScaleMu.Lock()
+	state.pyramid.blocked = reason
+	autoScaleMu.Unlock()
+	log.Printf("[AutoScale] %s further scale-ins blocked: %s", state.Config.Symbol, reason)
+	SendNotify(fmt.Sprintf("*金字塔加仓* %s 已停止后续加仓，请检查仓位与止损：%s", state.Config.Symbol, reason))
+}
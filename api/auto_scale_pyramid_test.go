@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestPyramidMaxAddQtyKeepsRiskNeutral(t *testing.T) {
+	// 多头：1 BTC @100，初始止损 90 → 初始风险 10
+	budget := positionRiskAt(1, 1, 100, 90)
+	if budget != 10 {
+		t.Fatalf("initial risk=%v", budget)
+	}
+	// 价格到 120，止损上移到 110：原仓位止损处盈利 -10，新增每单位风险 10 → 最多加 2
+	q := pyramidMaxAddQty(1, 1, 100, 120, 110, budget)
+	if math.Abs(q-2) > 1e-9 {
+		t.Fatalf("max add=%v want 2", q)
+	}
+	avg := (100 + 2*120) / 3.0
+	if risk := positionRiskAt(1, 3, avg, 110); risk > budget+1e-9 {
+		t.Fatalf("risk after add %v exceeds budget %v", risk, budget)
+	}
+
+	// 空头对称：1 @100，止损 110；价格 80，止损 90
+	if q := pyramidMaxAddQty(-1, 1, 100, 80, 90, 10); math.Abs(q-2) > 1e-9 {
+		t.Fatalf("short max add=%v want 2", q)
+	}
+	// 止损没有上移时无法再加
+	if q := pyramidMaxAddQty(1, 1, 100, 120, 90, budget); q != 0 {
+		t.Fatalf("expected no room, got %v", q)
+	}
+	// 止损在价格之上：无效
+	if q := pyramidMaxAddQty(1, 1, 100, 120, 125, budget); q != 0 {
+		t.Fatalf("expected zero for stop beyond price, got %v", q)
+	}
+}
+
+func TestPyramidHelpers(t *testing.T) {
+	cfg := AutoScaleConfig{AddQuantity: "100", SizeDecay: 0.5}
+	if m := pyramidAddMargin(cfg, 2); m != 25 {
+		t.Fatalf("margin=%v want 25", m)
+	}
+	if s := tighterStop(1, 95, 90); s != 95 {
+		t.Fatalf("long tighter=%v", s)
+	}
+	if s := tighterStop(-1, 105, 110); s != 105 {
+		t.Fatalf("short tighter=%v", s)
+	}
+	if s := tighterStop(1, 0, 90); s != 90 {
+		t.Fatalf("unset stop=%v", s)
+	}
+
+	resp := &SRResponse{
+		Resistances: []SRLevel{{Price: 130}, {Price: 110}, {Price: 95}},
+		Supports:    []SRLevel{{Price: 80}, {Price: 90}},
+	}
+	if l := nextSRLevel(resp, 1, 100); l != 110 {
+		t.Fatalf("next resistance=%v", l)
+	}
+	if l := nextSRLevel(resp, -1, 100); l != 90 {
+		t.Fatalf("next support=%v", l)
+	}
+	if l := nextSRLevel(resp, 1, 140); l != 0 {
+		t.Fatalf("no level expected, got %v", l)
+	}
+}
+
+func TestNormalizePyramidConfig(t *testing.T) {
+	cfg := AutoScaleConfig{Mode: "ATR"}
+	if err := normalizePyramidConfig(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Mode != AutoScaleModeATR || cfg.SizeDecay != 1 || cfg.ATRPeriod != 14 || cfg.ATRInterval != "1h" || !cfg.managesStop() {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+
+	bad := []AutoScaleConfig{
+		{},                             // profit 模式缺少触发条件
+		{Mode: "grid"},                 // 未知模式
+		{Mode: "sr", SizeDecay: 2},     // 递减系数越界
+		{Mode: "sr", UpdateTPSL: true}, // 与算法单止盈止损冲突
+	}
+	for i := range bad {
+		if err := normalizePyramidConfig(&bad[i]); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestPlanPyramidAdd(t *testing.T) {
+	state := &autoScaleState{
+		Config: AutoScaleConfig{Mode: AutoScaleModeATR, Leverage: 10, StopATR: 1, RiskNeutral: true},
+		pyramid: pyramidState{
+			initialized: true, dir: 1, initialRisk: 10, stopPrice: 90, atr: 10,
+		},
+	}
+	pos := &futures.PositionRisk{PositionAmt: "1", EntryPrice: "100", MarkPrice: "120"}
+
+	// 计划保证金 30 → 名义 300 → 2.5 个，风险约束下最多 2 个，止损上移到 110
+	plan := planPyramidAdd(state, pos, 30)
+	if plan.skip != "" || plan.stop != 110 || !plan.capped || math.Abs(plan.qty-2) > 1e-9 || math.Abs(plan.margin-24) > 1e-9 {
+		t.Fatalf("plan=%+v", plan)
+	}
+
+	// 止损无法上移（ATR 很大）时预算用尽，跳过
+	state.pyramid.atr = 40
+	if plan := planPyramidAdd(state, pos, 30); plan.skip == "" {
+		t.Fatalf("expected skip, got %+v", plan)
+	}
+}
+
+func TestSecurePyramidAdd(t *testing.T) {
+	origMove, origUnwind, origDelay := pyramidMoveStop, pyramidUnwindAdd, pyramidRetryDelay
+	defer func() { pyramidMoveStop, pyramidUnwindAdd, pyramidRetryDelay = origMove, origUnwind, origDelay }()
+	pyramidRetryDelay = 0
+
+	newState := func() *autoScaleState {
+		return &autoScaleState{
+			Config:  AutoScaleConfig{Symbol: "BTCUSDT", Mode: AutoScaleModeATR, RiskNeutral: true},
+			pyramid: pyramidState{initialized: true, dir: 1, initialRisk: 10},
+		}
+	}
+	var unwound []float64
+	pyramidUnwindAdd = func(_ context.Context, _ *autoScaleState, qty, maxQty float64) (float64, error) {
+		unwound = append(unwound, qty)
+		return qty, nil
+	}
+
+	// 止损移动持续失败：重试后撤回整笔加仓
+	moves := 0
+	pyramidMoveStop = func(context.Context, *autoScaleState, pyramidPlan, *AutoScaleAdd) error {
+		moves++
+		return fmt.Errorf("tpsl unavailable")
+	}
+	state := newState()
+	add := AutoScaleAdd{Index: 1, Quantity: 2}
+	securePyramidAdd(context.Background(), state, pyramidPlan{stop: 110}, &add)
+	if moves != pyramidStopRetries || len(unwound) != 1 || unwound[0] != 2 || add.UnwoundQty != 2 || state.pyramid.blocked != "" {
+		t.Fatalf("moves=%d unwound=%v add=%+v blocked=%q", moves, unwound, add, state.pyramid.blocked)
+	}
+
+	// 滑点导致风险超出初始风险：只撤回超出部分，并按剩余持仓重设止损
+	unwound, moves = nil, 0
+	pyramidMoveStop = func(_ context.Context, _ *autoScaleState, plan pyramidPlan, add *AutoScaleAdd) error {
+		moves++
+		add.AvgEntry, add.StopPrice = 115, plan.stop
+		add.PositionQty = 3 - add.UnwoundQty
+		add.RiskAfter = positionRiskAt(1, add.PositionQty, add.AvgEntry, plan.stop)
+		return nil
+	}
+	state = newState()
+	add = AutoScaleAdd{Index: 2, Quantity: 2}
+	securePyramidAdd(context.Background(), state, pyramidPlan{stop: 110}, &add)
+	// 3 个 @115、止损 110 → 风险 15，超出 5，每单位 5 → 撤回 1
+	if moves != 2 || len(unwound) != 1 || math.Abs(unwound[0]-1) > 1e-9 || add.RiskAfter > 10+1e-9 {
+		t.Fatalf("moves=%d unwound=%v add=%+v", moves, unwound, add)
+	}
+
+	// 撤回失败：停止后续加仓
+	pyramidUnwindAdd = func(context.Context, *autoScaleState, float64, float64) (float64, error) {
+		return 0, fmt.Errorf("reduce rejected")
+	}
+	state = newState()
+	add = AutoScaleAdd{Index: 3, Quantity: 2}
+	securePyramidAdd(context.Background(), state, pyramidPlan{stop: 110}, &add)
+	if state.pyramid.blocked == "" || add.UnwoundQty != 0 {
+		t.Fatalf("expected adds blocked, add=%+v", add)
+	}
+
+	// 风险在预算内：不撤回
+	state = newState()
+	state.pyramid.initialRisk = 20
+	add = AutoScaleAdd{Index: 4, Quantity: 2}
+	securePyramidAdd(context.Background(), state, pyramidPlan{stop: 110}, &add)
+	if state.pyramid.blocked != "" || add.UnwoundQty != 0 {
+		t.Fatalf("unexpected unwind, add=%+v blocked=%q", add, state.pyramid.blocked)
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/adshao/go-binance/v2"
 	"github.com/adshao/go-binance/v2/futures"
 	ws "tools/websocket"
 )
 
 var Client *futures.Client
 
+// SpotClient 现货/杠杆 REST 客户端，用于资金费率对冲腿
+var SpotClient *binance.Client
+
 // WsOrderClient 全局 WebSocket 订单客户端，优先使用 WS 下单
 var WsOrderClient *ws.WsClient
 var wsClientMu sync.Mutex
@@ -25,9 +29,11 @@ func InitClient(cfgPath string) {
 
 	if Cfg.Testnet {
 		futures.UseTestnet = true
+		binance.UseTestnet = true
 	}
 
 	Client = futures.NewClient(Cfg.REST.APIKey, Cfg.REST.SecretKey)
+	SpotClient = binance.NewClient(Cfg.REST.APIKey, Cfg.REST.SecretKey)
 }
 
 // InitWsClient 初始化 WebSocket 订单客户端（Ed25519 签名）
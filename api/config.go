@@ -31,6 +31,7 @@ type LLMConfig struct {
 	Model       string  `json:"model"`
 	MaxTokens   int     `json:"max_tokens"`
 	Temperature float64 `json:"temperature"`
+	TimeoutSec  int     `json:"timeout_sec"` // 单次请求超时秒数，默认 8
 
 	// 多模型路由
 	RouterEnabled bool      `json:"router_enabled"` // 是否启用多模型路由
@@ -42,6 +43,9 @@ type LLMConfig struct {
 type NewsConfig struct {
 	RSSHubBaseURL    string   `json:"rsshubBaseUrl"`    // RSSHub 实例地址
 	TelegramChannels []string `json:"telegramChannels"` // Telegram 频道用户名或 t.me 链接
+
+	Sources            []NewsSourceSpec `json:"sources"`            // 自定义资讯源（rss / json / webhook）
+	DefaultIntervalSec int              `json:"defaultIntervalSec"` // 内置源轮询间隔秒数，默认 30
 }
 
 // RedisConfig Redis 缓存配置（可选）。
@@ -69,6 +73,7 @@ type Config struct {
 	Notify          NotifyConfig          `json:"notify"`
 	VolatilityGuard VolatilityGuardConfig `json:"volatilityGuard"`
 	VarRisk         VarRiskConfig         `json:"varRisk"`
+	PreTrade        PreTradeConfig        `json:"preTrade"`
 	Testnet         bool                  `json:"testnet"`
 	DryRun          bool                  `json:"dryRun"` // 模拟交易模式，不实际下单
 }
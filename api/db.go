@@ -78,6 +78,14 @@ func autoMigrateSchema() error {
 		&SlippageRecord{}, // 滑点记录（新增表，不影响已有数据）
 		&VarSnapshot{},
 		&StrategyAllocation{},
+		&GridFillRecord{},
+		&HyperLeaderSnapshot{},
+		&HyperLeaderWatch{},
+		&HyperFollowRecord{},
+		&StrategyLinkRuleRecord{},
+		&StrategyLinkAudit{},
+		&NewsSourceRecord{},
+		&NewsArchiveRecord{},
 	)
 }
 
@@ -155,6 +163,9 @@ func ensureDBIndexes() error {
 	if err := createIndexIfMissing(&RecommendSignalEvaluationRecord{}, "Final"); err != nil {
 		return err
 	}
+	if err := ensureNewsArchiveSearchIndex(); err != nil {
+		return err
+	}
 	return nil
 }
 
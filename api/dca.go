@@ -28,6 +28,38 @@ type DCAConfig struct {
 	// 止盈止损（可选）
 	StopLossAmount   float64 `json:"stopLossAmount,omitempty"`   // 总止损(USDT)
 	TakeProfitAmount float64 `json:"takeProfitAmount,omitempty"` // 总止盈(USDT)
+
+	// 安全单阶梯（SafetyOrders > 0 时启用，取代按间隔定投；totalOrders 自动为 1+safetyOrders）
+	SafetyOrders        int     `json:"safetyOrders,omitempty"`        // 安全单数量
+	SafetyOrderAmount   string  `json:"safetyOrderAmount,omitempty"`   // 首个安全单金额(USDT)，默认 amountPerOrder
+	PriceDeviation      float64 `json:"priceDeviation,omitempty"`      // 首个安全单相对首单价格的偏离(%)，默认 priceDropPercent 或 1
+	StepScale           float64 `json:"stepScale,omitempty"`           // 间距倍数：第 n 个安全单间距 = priceDeviation × stepScale^(n-1)
+	VolumeScale         float64 `json:"volumeScale,omitempty"`         // 金额倍数：第 n 个安全单金额 = safetyOrderAmount × volumeScale^(n-1)（>1 即马丁）
+	ATRSpacing          bool    `json:"atrSpacing,omitempty"`          // 用 ATR% × atrMultiplier 作为首个间距
+	ATRInterval         string  `json:"atrInterval,omitempty"`         // ATR K线周期，默认 1h
+	ATRPeriod           int     `json:"atrPeriod,omitempty"`           // ATR 周期，默认 14
+	ATRMultiplier       float64 `json:"atrMultiplier,omitempty"`       // 默认 1
+	TakeProfitPercent   float64 `json:"takeProfitPercent,omitempty"`   // 止盈：均价上方(做空为下方) X%，每次成交后按新均价重算
+	MaxDeviationPercent float64 `json:"maxDeviationPercent,omitempty"` // 最大偏离保护：超出首单价格该偏离的安全单不再挂出
+	RestartOnTakeProfit bool    `json:"restartOnTakeProfit,omitempty"` // 止盈后开始新一轮
+}
+
+// DCAFill 定投成交记录
+type DCAFill struct {
+	Index  int       `json:"index"` // 0 为首单，1..n 为安全单
+	Price  float64   `json:"price"`
+	Qty    float64   `json:"qty"`
+	Amount float64   `json:"amount"` // 保证金(USDT)
+	At     time.Time `json:"at"`
+}
+
+// DCALadderStep 安全单阶梯中的一级
+type DCALadderStep struct {
+	Index        int     `json:"index"`
+	DeviationPct float64 `json:"deviationPct"` // 相对首单价格的累计偏离
+	TriggerPrice float64 `json:"triggerPrice"`
+	Amount       float64 `json:"amount"`
+	Filled       bool    `json:"filled"`
 }
 
 // DCAStatus 定投状态
@@ -41,6 +73,13 @@ type DCAStatus struct {
 	LastOrderAt string    `json:"lastOrderAt"` // 上次下单时间
 	LastError   string    `json:"lastError"`   // 最近一次错误
 	FailCount   int       `json:"failCount"`   // 连续失败次数
+
+	// 安全单阶梯
+	Fills           []DCAFill       `json:"fills,omitempty"`
+	Ladder          []DCALadderStep `json:"ladder,omitempty"`
+	TakeProfitPrice float64         `json:"takeProfitPrice,omitempty"`
+	Cycle           int             `json:"cycle"`
+	RealizedPnl     float64         `json:"realizedPnl"`
 }
 
 type dcaState struct {
@@ -48,21 +87,59 @@ type dcaState struct {
 	Active      bool
 	OrderCount  int
 	TotalAmount float64
+	TotalQty    float64
 	AvgEntry    float64
 	LastOrderAt time.Time
 	LastPrice   float64 // 上次下单时的价格（用于 priceDropPercent）
 	LastError   string  // 最近一次错误
 	FailCount   int     // 连续失败次数
+
+	Fills       []DCAFill // 本轮成交记录（持久化，重启后从中断处继续）
+	BasePrice   float64   // 本轮首单成交价
+	StepPct     float64   // 本轮首个安全单间距(%)，ATR 模式在首单成交时确定
+	Cycle       int       // 已完成的止盈轮次
+	RealizedPnl float64   // 已止盈轮次的累计盈亏(估算)
 	stopC       chan struct{}
 }
 
+// dcaRuntime 定投运行时快照，随策略状态持久化
+type dcaRuntime struct {
+	Fills       []DCAFill `json:"fills"`
+	OrderCount  int       `json:"orderCount"`
+	TotalAmount float64   `json:"totalAmount"`
+	LastOrderAt time.Time `json:"lastOrderAt"`
+	LastPrice   float64   `json:"lastPrice"`
+	BasePrice   float64   `json:"basePrice"`
+	StepPct     float64   `json:"stepPct"`
+	Cycle       int       `json:"cycle"`
+	RealizedPnl float64   `json:"realizedPnl"`
+}
+
 var (
 	dcaTasks = make(map[string]*dcaState)
 	dcaMu    sync.Mutex
 )
 
-// StartDCA 启动定投策略
+// StartDCA 启动定投策略（全新一轮，清空旧的运行时状态）
 func StartDCA(config DCAConfig) error {
+	if err := startDCA(config, nil); err != nil {
+		return err
+	}
+	SaveStrategyRuntime("dca", config.Symbol, nil)
+	return nil
+}
+
+// RecoverDCA 重启恢复定投：沿用持久化的成交记录，从中断的阶梯位置继续
+func RecoverDCA(config DCAConfig) error {
+	var rt dcaRuntime
+	if !LoadStrategyRuntime("dca", config.Symbol, &rt) {
+		return startDCA(config, nil)
+	}
+	log.Printf("[DCA] Recovering %s: orders=%d, fills=%d, cycle=%d", config.Symbol, rt.OrderCount, len(rt.Fills), rt.Cycle)
+	return startDCA(config, &rt)
+}
+
+func startDCA(config DCAConfig, rt *dcaRuntime) error {
 	if config.Symbol == "" {
 		return fmt.Errorf("symbol is required")
 	}
@@ -72,6 +149,14 @@ func StartDCA(config DCAConfig) error {
 	if config.AmountPerOrder == "" {
 		return fmt.Errorf("amountPerOrder is required")
 	}
+	if config.SafetyOrders < 0 {
+		return fmt.Errorf("safetyOrders must be >= 0")
+	}
+	if config.SafetyOrders > 0 {
+		if err := normalizeDCALadderConfig(&config); err != nil {
+			return err
+		}
+	}
 	if config.TotalOrders <= 0 {
 		return fmt.Errorf("totalOrders must be > 0")
 	}
@@ -103,6 +188,18 @@ func StartDCA(config DCAConfig) error {
 		Active: true,
 		stopC:  make(chan struct{}),
 	}
+	if rt != nil {
+		state.Fills = rt.Fills
+		state.OrderCount = rt.OrderCount
+		state.TotalAmount = rt.TotalAmount
+		state.LastOrderAt = rt.LastOrderAt
+		state.LastPrice = rt.LastPrice
+		state.BasePrice = rt.BasePrice
+		state.StepPct = rt.StepPct
+		state.Cycle = rt.Cycle
+		state.RealizedPnl = rt.RealizedPnl
+		recalcDCAAverage(state)
+	}
 	dcaTasks[config.Symbol] = state
 
 	go dcaLoop(state)
@@ -164,7 +261,7 @@ func GetDCAStatus(symbol string) *DCAStatus {
 		lastOrderStr = state.LastOrderAt.Format("15:04:05")
 	}
 
-	return &DCAStatus{
+	status := &DCAStatus{
 		Config:      state.Config,
 		Active:      state.Active,
 		OrderCount:  state.OrderCount,
@@ -174,7 +271,18 @@ func GetDCAStatus(symbol string) *DCAStatus {
 		LastOrderAt: lastOrderStr,
 		LastError:   state.LastError,
 		FailCount:   state.FailCount,
+		Fills:       append([]DCAFill(nil), state.Fills...),
+		Cycle:       state.Cycle,
+		RealizedPnl: state.RealizedPnl,
 	}
+	if state.Config.SafetyOrders > 0 && state.BasePrice > 0 {
+		status.Ladder = dcaLadderPlan(state.Config, state.BasePrice, state.StepPct)
+		for i := range status.Ladder {
+			status.Ladder[i].Filled = dcaFilledIndex(state, status.Ladder[i].Index)
+		}
+		status.TakeProfitPrice = dcaTakeProfitPrice(state.Config, state.AvgEntry)
+	}
+	return status
 }
 
 // dcaLoop 定投主循环
@@ -189,10 +297,15 @@ func dcaLoop(state *dcaState) {
 		log.Printf("[DCA] Warning: set leverage failed: %v", err)
 	}
 
-	// 立即执行第一次（带重试）
-	if err := dcaExecuteWithRetry(ctx, state, 3); err != nil {
-		log.Printf("[DCA] First order failed after retries: %v", err)
-		// 不退出，继续等待下次 ticker
+	// 立即执行第一次（带重试）；恢复的任务已有成交则直接续跑
+	dcaMu.Lock()
+	resumed := state.OrderCount > 0
+	dcaMu.Unlock()
+	if !resumed {
+		if err := dcaExecuteWithRetry(ctx, state, 3, 0); err != nil {
+			log.Printf("[DCA] First order failed after retries: %v", err)
+			// 不退出，继续等待下次 ticker
+		}
 	}
 
 	ticker := time.NewTicker(time.Duration(cfg.IntervalSec) * time.Second)
@@ -204,6 +317,12 @@ func dcaLoop(state *dcaState) {
 			log.Printf("[DCA] Loop stopped for %s", cfg.Symbol)
 			return
 		case <-ticker.C:
+			if cfg.SafetyOrders > 0 {
+				if dcaLadderTick(ctx, state) {
+					return
+				}
+				continue
+			}
 			if state.OrderCount >= cfg.TotalOrders {
 				log.Printf("[DCA] All %d orders completed for %s", cfg.TotalOrders, cfg.Symbol)
 				dcaMu.Lock()
@@ -255,7 +374,7 @@ func dcaLoop(state *dcaState) {
 				log.Printf("[DCA] Price condition met for %s: current=%.4f, avgEntry=%.4f", cfg.Symbol, currentPrice, state.AvgEntry)
 			}
 
-			if err := dcaExecuteWithRetry(ctx, state, 2); err != nil {
+			if err := dcaExecuteWithRetry(ctx, state, 2, state.OrderCount); err != nil {
 				log.Printf("[DCA] Order failed after retries: %v", err)
 			}
 		}
@@ -263,7 +382,7 @@ func dcaLoop(state *dcaState) {
 }
 
 // dcaExecuteWithRetry 带重试的定投执行
-func dcaExecuteWithRetry(ctx context.Context, state *dcaState, maxRetries int) error {
+func dcaExecuteWithRetry(ctx context.Context, state *dcaState, maxRetries int, index int) error {
 	var lastErr error
 	for i := 0; i < maxRetries; i++ {
 		if i > 0 {
@@ -271,7 +390,7 @@ func dcaExecuteWithRetry(ctx context.Context, state *dcaState, maxRetries int) e
 			time.Sleep(time.Duration(i*2) * time.Second)
 		}
 
-		err := dcaExecute(ctx, state)
+		err := dcaExecute(ctx, state, index)
 		if err == nil {
 			// 成功，清除错误状态
 			dcaMu.Lock()
@@ -305,17 +424,15 @@ func dcaExecuteWithRetry(ctx context.Context, state *dcaState, maxRetries int) e
 	return lastErr
 }
 
-// dcaExecute 执行一次定投
-func dcaExecute(ctx context.Context, state *dcaState) error {
+// dcaExecute 执行一次定投，index 为本轮第几单（0 为首单，阶梯模式下 1..n 为安全单）
+func dcaExecute(ctx context.Context, state *dcaState, index int) error {
 	cfg := state.Config
 
-	// 风控检查
-	if err := CheckRisk(); err != nil {
-		return fmt.Errorf("risk blocked: %w", err)
-	}
+	amount := dcaOrderAmount(cfg, index)
+	amountStr := strconv.FormatFloat(amount, 'f', -1, 64)
 
 	log.Printf("[DCA] Executing order #%d for %s: side=%s, positionSide=%s, amount=%s USDT",
-		state.OrderCount+1, cfg.Symbol, cfg.Side, cfg.PositionSide, cfg.AmountPerOrder)
+		state.OrderCount+1, cfg.Symbol, cfg.Side, cfg.PositionSide, amountStr)
 
 	req := PlaceOrderReq{
 		Source:        "strategy_dca",
@@ -323,7 +440,7 @@ func dcaExecute(ctx context.Context, state *dcaState) error {
 		Side:          cfg.Side,
 		OrderType:     futures.OrderTypeMarket,
 		PositionSide:  cfg.PositionSide,
-		QuoteQuantity: cfg.AmountPerOrder,
+		QuoteQuantity: amountStr,
 		Leverage:      cfg.Leverage,
 	}
 
@@ -332,25 +449,45 @@ func dcaExecute(ctx context.Context, state *dcaState) error {
 		return fmt.Errorf("order failed: %w", err)
 	}
 
-	// 获取成交价
+	// 获取成交价与成交量
 	filledPrice, _ := strconv.ParseFloat(result.Order.AvgPrice, 64)
-	amtPerOrder, _ := strconv.ParseFloat(cfg.AmountPerOrder, 64)
+	if filledPrice <= 0 {
+		filledPrice, _ = GetPriceCache().GetPrice(cfg.Symbol)
+	}
+	filledQty, _ := strconv.ParseFloat(result.Order.ExecutedQuantity, 64)
+	if filledQty <= 0 {
+		filledQty, _ = strconv.ParseFloat(result.Order.OrigQuantity, 64)
+	}
+	if filledQty <= 0 && filledPrice > 0 {
+		filledQty = amount * float64(cfg.Leverage) / filledPrice
+	}
 
 	dcaMu.Lock()
 	state.OrderCount++
-	state.TotalAmount += amtPerOrder
+	state.TotalAmount += amount
 	state.LastOrderAt = time.Now()
 	state.LastPrice = filledPrice
-
-	if state.OrderCount == 1 {
-		state.AvgEntry = filledPrice
-	} else {
-		state.AvgEntry = (state.AvgEntry*float64(state.OrderCount-1) + filledPrice) / float64(state.OrderCount)
+	state.Fills = append(state.Fills, DCAFill{Index: index, Price: filledPrice, Qty: filledQty, Amount: amount, At: state.LastOrderAt})
+	if index == 0 {
+		state.BasePrice = filledPrice
 	}
+	recalcDCAAverage(state)
+	needStep := index == 0 && cfg.SafetyOrders > 0
+	rt := dcaRuntimeOf(state)
 	dcaMu.Unlock()
 
-	log.Printf("[DCA] Order #%d/%d for %s: price=%.4f, amount=%s USDT, avgEntry=%.4f",
-		state.OrderCount, cfg.TotalOrders, cfg.Symbol, filledPrice, cfg.AmountPerOrder, state.AvgEntry)
+	if needStep {
+		step := dcaResolveStep(ctx, cfg)
+		dcaMu.Lock()
+		state.StepPct = step
+		rt = dcaRuntimeOf(state)
+		dcaMu.Unlock()
+	}
+	SaveStrategyRuntime("dca", cfg.Symbol, rt)
+
+	log.Printf("[DCA] Order #%d/%d for %s: price=%.4f, amount=%s USDT, avgEntry=%.4f, tp=%.4f",
+		state.OrderCount, cfg.TotalOrders, cfg.Symbol, filledPrice, amountStr, state.AvgEntry,
+		dcaTakeProfitPrice(cfg, state.AvgEntry))
 
 	return nil
 }
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+const dcaLadderCheckSec = 5 // 阶梯模式默认检查间隔(秒)
+
+// normalizeDCALadderConfig 校验并补全安全单阶梯参数
+func normalizeDCALadderConfig(cfg *DCAConfig) error {
+	if cfg.SafetyOrderAmount == "" {
+		cfg.SafetyOrderAmount = cfg.AmountPerOrder
+	}
+	if v, err := strconv.ParseFloat(cfg.SafetyOrderAmount, 64); err != nil || v <= 0 {
+		return fmt.Errorf("invalid safetyOrderAmount: %s", cfg.SafetyOrderAmount)
+	}
+	if cfg.PriceDeviation <= 0 {
+		cfg.PriceDeviation = cfg.PriceDropPercent
+	}
+	if cfg.PriceDeviation <= 0 {
+		cfg.PriceDeviation = 1
+	}
+	if cfg.StepScale == 0 {
+		cfg.StepScale = 1
+	}
+	if cfg.VolumeScale == 0 {
+		cfg.VolumeScale = 1
+	}
+	if cfg.StepScale < 0 || cfg.VolumeScale < 0 {
+		return fmt.Errorf("stepScale and volumeScale must be > 0")
+	}
+	if cfg.ATRSpacing {
+		if cfg.ATRInterval == "" {
+			cfg.ATRInterval = "1h"
+		}
+		if cfg.ATRPeriod <= 0 {
+			cfg.ATRPeriod = 14
+		}
+		if cfg.ATRMultiplier <= 0 {
+			cfg.ATRMultiplier = 1
+		}
+	}
+	if cfg.TakeProfitPercent < 0 || cfg.MaxDeviationPercent < 0 {
+		return fmt.Errorf("takeProfitPercent and maxDeviationPercent must be >= 0")
+	}
+	cfg.TotalOrders = 1 + cfg.SafetyOrders
+	if cfg.IntervalSec <= 0 {
+		cfg.IntervalSec = dcaLadderCheckSec
+	}
+	return nil
+}
+
+// dcaOrderAmount 第 index 单的保证金：首单为 amountPerOrder，安全单按 volumeScale 逐级放大
+func dcaOrderAmount(cfg DCAConfig, index int) float64 {
+	base, _ := strconv.ParseFloat(cfg.AmountPerOrder, 64)
+	if index <= 0 || cfg.SafetyOrders <= 0 {
+		return base
+	}
+	so, _ := strconv.ParseFloat(cfg.SafetyOrderAmount, 64)
+	return roundFloat(so*math.Pow(cfg.VolumeScale, float64(index-1)), 4)
+}
+
+// dcaLadderPlan 根据首单价格和首个间距生成安全单阶梯；超出 maxDeviationPercent 的级别被截断
+func dcaLadderPlan(cfg DCAConfig, basePrice, stepPct float64) []DCALadderStep {
+	if basePrice <= 0 || cfg.SafetyOrders <= 0 {
+		return nil
+	}
+	if stepPct <= 0 {
+		stepPct = cfg.PriceDeviation
+	}
+	steps := make([]DCALadderStep, 0, cfg.SafetyOrders)
+	var deviation float64
+	for i := 1; i <= cfg.SafetyOrders; i++ {
+		deviation += stepPct * math.Pow(cfg.StepScale, float64(i-1))
+		if cfg.MaxDeviationPercent > 0 && deviation > cfg.MaxDeviationPercent+1e-9 {
+			break
+		}
+		var trigger float64
+		if cfg.Side == futures.SideTypeSell {
+			trigger = basePrice * (1 + deviation/100)
+		} else {
+			if deviation >= 100 {
+				break
+			}
+			trigger = basePrice * (1 - deviation/100)
+		}
+		steps = append(steps, DCALadderStep{
+			Index:        i,
+			DeviationPct: roundFloat(deviation, 4),
+			TriggerPrice: trigger,
+			Amount:       dcaOrderAmount(cfg, i),
+		})
+	}
+	return steps
+}
+
+// dcaTakeProfitPrice 按当前均价计算止盈价（未配置 takeProfitPercent 时为 0）
+func dcaTakeProfitPrice(cfg DCAConfig, avgEntry float64) float64 {
+	if cfg.TakeProfitPercent <= 0 || avgEntry <= 0 {
+		return 0
+	}
+	if cfg.Side == futures.SideTypeSell {
+		return avgEntry * (1 - cfg.TakeProfitPercent/100)
+	}
+	return avgEntry * (1 + cfg.TakeProfitPercent/100)
+}
+
+// recalcDCAAverage 按成交量加权重算均价（调用方持有 dcaMu）
+func recalcDCAAverage(state *dcaState) {
+	var qty, cost float64
+	for _, f := range state.Fills {
+		qty += f.Qty
+		cost += f.Qty * f.Price
+	}
+	state.TotalQty = qty
+	if qty > 0 {
+		state.AvgEntry = cost / qty
+	}
+}
+
+// dcaFilledIndex 本轮第 index 单是否已成交（调用方持有 dcaMu）
+func dcaFilledIndex(state *dcaState, index int) bool {
+	for _, f := range state.Fills {
+		if f.Index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// dcaRuntimeOf 生成运行时快照（调用方持有 dcaMu）
+func dcaRuntimeOf(state *dcaState) *dcaRuntime {
+	return &dcaRuntime{
+		Fills:       append([]DCAFill(nil), state.Fills...),
+		OrderCount:  state.OrderCount,
+		TotalAmount: state.TotalAmount,
+		LastOrderAt: state.LastOrderAt,
+		LastPrice:   state.LastPrice,
+		BasePrice:   state.BasePrice,
+		StepPct:     state.StepPct,
+		Cycle:       state.Cycle,
+		RealizedPnl: state.RealizedPnl,
+	}
+}
+
+// dcaResolveStep 确定本轮首个安全单间距：ATR 模式取 ATR% × 倍数，获取失败时回退到 priceDeviation
+func dcaResolveStep(ctx context.Context, cfg DCAConfig) float64 {
+	if !cfg.ATRSpacing {
+		return cfg.PriceDeviation
+	}
+	klines, err := Client.NewKlinesService().Symbol(cfg.Symbol).Interval(cfg.ATRInterval).Limit(cfg.ATRPeriod + 2).Do(ctx)
+	if err != nil {
+		log.Printf("[DCA] ATR klines failed for %s, fallback to %.2f%%: %v", cfg.Symbol, cfg.PriceDeviation, err)
+		return cfg.PriceDeviation
+	}
+	atrPct := calcATRPercent(klines, cfg.ATRPeriod)
+	if atrPct <= 0 {
+		return cfg.PriceDeviation
+	}
+	step := roundFloat(atrPct*cfg.ATRMultiplier, 4)
+	log.Printf("[DCA] %s ATR spacing: atr=%.3f%% × %.2f => step=%.3f%%", cfg.Symbol, atrPct, cfg.ATRMultiplier, step)
+	return step
+}
+
+// dcaLadderTick 阶梯模式的一次检查：补首单 → 止盈 → 触发下一个安全单。返回 true 表示策略结束
+func dcaLadderTick(ctx context.Context, state *dcaState) bool {
+	cfg := state.Config
+
+	// 金额止盈止损仍然有效
+	if dcaCheckTPSL(ctx, state) {
+		return true
+	}
+
+	dcaMu.Lock()
+	hasBase := dcaFilledIndex(state, 0)
+	dcaMu.Unlock()
+	if !hasBase {
+		if err := dcaExecuteWithRetry(ctx, state, 2, 0); err != nil {
+			log.Printf("[DCA] Base order failed for %s: %v", cfg.Symbol, err)
+		}
+		return false
+	}
+
+	price, err := GetPriceCache().GetPrice(cfg.Symbol)
+	if err != nil || price <= 0 {
+		return false
+	}
+
+	dcaMu.Lock()
+	avgEntry, totalQty := state.AvgEntry, state.TotalQty
+	plan := dcaLadderPlan(cfg, state.BasePrice, state.StepPct)
+	var next *DCALadderStep
+	for i := range plan {
+		if !dcaFilledIndex(state, plan[i].Index) {
+			next = &plan[i]
+			break
+		}
+	}
+	dcaMu.Unlock()
+
+	// 止盈：价格到达按最新均价重算的止盈价
+	if tp := dcaTakeProfitPrice(cfg, avgEntry); tp > 0 {
+		if (cfg.Side == futures.SideTypeSell && price <= tp) || (cfg.Side != futures.SideTypeSell && price >= tp) {
+			return dcaTakeProfit(ctx, state, price, avgEntry, totalQty)
+		}
+	}
+
+	if next == nil {
+		return false
+	}
+	if (cfg.Side == futures.SideTypeSell && price < next.TriggerPrice) || (cfg.Side != futures.SideTypeSell && price > next.TriggerPrice) {
+		return false
+	}
+	log.Printf("[DCA] %s safety order #%d triggered: price=%.4f, trigger=%.4f (-%.2f%%)",
+		cfg.Symbol, next.Index, price, next.TriggerPrice, next.DeviationPct)
+	if err := dcaExecuteWithRetry(ctx, state, 2, next.Index); err != nil {
+		log.Printf("[DCA] Safety order #%d failed for %s: %v", next.Index, cfg.Symbol, err)
+	}
+	return false
+}
+
+// dcaTakeProfit 平仓止盈；配置 restartOnTakeProfit 时清空本轮成交开始下一轮，否则停止策略
+func dcaTakeProfit(ctx context.Context, state *dcaState, price, avgEntry, qty float64) bool {
+	cfg := state.Config
+	if _, err := ClosePositionViaWs(ctx, ClosePositionReq{
+		Symbol:       cfg.Symbol,
+		PositionSide: cfg.PositionSide,
+	}); err != nil {
+		log.Printf("[DCA] Take profit close failed for %s: %v", cfg.Symbol, err)
+		dcaMu.Lock()
+		state.LastError = err.Error()
+		dcaMu.Unlock()
+		return false
+	}
+
+	pnl := (price - avgEntry) * qty
+	if cfg.Side == futures.SideTypeSell {
+		pnl = -pnl
+	}
+
+	dcaMu.Lock()
+	state.Cycle++
+	state.RealizedPnl += pnl
+	filled := len(state.Fills)
+	state.Fills = nil
+	state.OrderCount = 0
+	state.TotalAmount = 0
+	state.BasePrice = 0
+	state.StepPct = 0
+	state.LastPrice = 0
+	recalcDCAAverage(state)
+	state.AvgEntry = 0
+	cycle := state.Cycle
+	rt := dcaRuntimeOf(state)
+	if !cfg.RestartOnTakeProfit {
+		state.Active = false
+	}
+	dcaMu.Unlock()
+
+	log.Printf("[DCA] %s take profit cycle #%d: price=%.4f, avgEntry=%.4f, fills=%d, pnl≈%.2f",
+		cfg.Symbol, cycle, price, avgEntry, filled, pnl)
+	SendNotify(fmt.Sprintf("DCA %s 第%d轮止盈：均价 %.4f → %.4f，成交 %d 单，盈亏约 %.2f USDT",
+		cfg.Symbol, cycle, avgEntry, price, filled, pnl))
+
+	if cfg.RestartOnTakeProfit {
+		SaveStrategyRuntime("dca", cfg.Symbol, rt)
+		return false
+	}
+	SaveStrategyRuntime("dca", cfg.Symbol, nil)
+	MarkStrategyStopped("dca", cfg.Symbol)
+	select {
+	case <-state.stopC:
+	default:
+		close(state.stopC)
+	}
+	return true
+}
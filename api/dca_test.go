@@ -0,0 +1,67 @@
+package api
+
+import (
+	"math"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestDCALadderPlan(t *testing.T) {
+	cfg := DCAConfig{
+		Symbol:              "BTCUSDT",
+		Side:                futures.SideTypeBuy,
+		AmountPerOrder:      "10",
+		SafetyOrders:        5,
+		SafetyOrderAmount:   "20",
+		PriceDeviation:      1,
+		StepScale:           2,
+		VolumeScale:         1.5,
+		MaxDeviationPercent: 10,
+	}
+	if err := normalizeDCALadderConfig(&cfg); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if cfg.TotalOrders != 6 {
+		t.Fatalf("totalOrders = %d, want 6", cfg.TotalOrders)
+	}
+
+	plan := dcaLadderPlan(cfg, 100, 0)
+	// 累计偏离 1, 3, 7, 15(超出 10% 截断)
+	if len(plan) != 3 {
+		t.Fatalf("len(plan) = %d, want 3", len(plan))
+	}
+	wantDev := []float64{1, 3, 7}
+	wantAmt := []float64{20, 30, 45}
+	for i, s := range plan {
+		if math.Abs(s.DeviationPct-wantDev[i]) > 1e-9 || math.Abs(s.Amount-wantAmt[i]) > 1e-9 {
+			t.Fatalf("step %d = %+v, want dev %.0f amount %.0f", i, s, wantDev[i], wantAmt[i])
+		}
+		if math.Abs(s.TriggerPrice-100*(1-wantDev[i]/100)) > 1e-9 {
+			t.Fatalf("step %d trigger = %.4f", i, s.TriggerPrice)
+		}
+	}
+
+	cfg.Side = futures.SideTypeSell
+	if p := dcaLadderPlan(cfg, 100, 2); len(p) != 2 || math.Abs(p[1].TriggerPrice-106) > 1e-9 {
+		t.Fatalf("short plan = %+v", p)
+	}
+}
+
+func TestDCAAverageAndTakeProfit(t *testing.T) {
+	state := &dcaState{Fills: []DCAFill{
+		{Index: 0, Price: 100, Qty: 1},
+		{Index: 1, Price: 90, Qty: 3},
+	}}
+	recalcDCAAverage(state)
+	if math.Abs(state.AvgEntry-92.5) > 1e-9 || state.TotalQty != 4 {
+		t.Fatalf("avg = %.4f qty = %.2f", state.AvgEntry, state.TotalQty)
+	}
+	cfg := DCAConfig{Side: futures.SideTypeBuy, TakeProfitPercent: 2}
+	if tp := dcaTakeProfitPrice(cfg, state.AvgEntry); math.Abs(tp-94.35) > 1e-9 {
+		t.Fatalf("tp = %.4f, want 94.35", tp)
+	}
+	if !dcaFilledIndex(state, 1) || dcaFilledIndex(state, 2) {
+		t.Fatalf("filled index mismatch")
+	}
+}
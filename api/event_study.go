@@ -0,0 +1,711 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// ========== 事件研究 ==========
+// 取一组事件（新闻关键词命中、爆仓尖峰、资金费率极值、推荐信号），按 K 线计算事件后各窗口的远期收益，
+// 统计平均收益、胜率及其 95% 置信区间，用于检验触发条件是否有超额收益
+
+const (
+	EventTypeNews        = "news"
+	EventTypeLiquidation = "liquidation"
+	EventTypeFunding     = "funding"
+	EventTypeRecommend   = "recommend"
+
+	eventStudyDefaultMaxEvents = 200
+	eventStudyMaxEvents        = 1000
+	eventStudyMaxSymbols       = 50
+	eventStudyMaxBarsPerSymbol = 20000
+	eventStudyDefaultRangeDays = 30
+	eventStudyMaxRangeDays     = 180
+	eventStudyTimeout          = 90 * time.Second
+)
+
+var (
+	eventStudyDefaultHorizons = []string{"1h", "4h", "24h"}
+	eventStudyIntervals       = map[string]bool{"1m": true, "5m": true, "15m": true, "1h": true, "4h": true}
+)
+
+// EventStudyReq 事件研究请求
+type EventStudyReq struct {
+	Type     string   `json:"type"`     // news / liquidation / funding / recommend
+	From     string   `json:"from"`     // 毫秒时间戳或日期，默认 30 天前
+	To       string   `json:"to"`       // 默认当前时间
+	Horizons []string `json:"horizons"` // 远期窗口，如 15m/1h/4h/1d，默认 1h/4h/24h
+	Interval string   `json:"interval"` // 计算收益的 K 线周期，默认 5m；窗口须为其整数倍
+	Symbol   string   `json:"symbol"`   // 可选：只研究该币种
+
+	Keyword string `json:"keyword"` // news：关键词（必填）
+	Source  string `json:"source"`  // news / recommend：来源过滤
+
+	LiqThresholdUSD float64 `json:"liqThresholdUSD"` // liquidation：单币种 1h 爆仓额阈值，默认 5,000,000
+
+	FundingThresholdPct float64 `json:"fundingThresholdPct"` // funding：单期费率绝对值阈值（%），默认 0.05
+	FundingTopN         int     `json:"fundingTopN"`         // funding：未指定币种时取当前费率绝对值最大的 N 个，默认 10
+
+	Direction     string `json:"direction"`     // recommend：LONG / SHORT
+	MinConfidence int    `json:"minConfidence"` // recommend：最低置信度
+
+	MaxEvents int    `json:"maxEvents"` // 默认 200，最多 1000（保留最近的事件）
+	Format    string `json:"format"`    // json（默认）/ csv
+	Table     string `json:"table"`     // csv 导出内容：events（默认）/ stats
+}
+
+// EventStudyEvent 单个事件及其远期收益
+// Sign 为事件隐含方向：+1 看多、-1 看空、0 无方向（统计时按 +1 处理）
+type EventStudyEvent struct {
+	Type      string             `json:"type"`
+	Symbol    string             `json:"symbol"`
+	Time      int64              `json:"time"` // 事件时间（毫秒），收益从其后第一根 K 线开盘价起算
+	Sign      int                `json:"sign"`
+	Label     string             `json:"label"`
+	Value     float64            `json:"value"` // 事件强度：爆仓额 USD / 费率 % / 置信度
+	BasePrice float64            `json:"basePrice"`
+	Returns   map[string]float64 `json:"returns"` // 窗口 → 原始涨跌幅（%），数据不足的窗口缺失
+}
+
+// EventStudyHorizonStat 单窗口统计（收益已乘以事件方向）
+type EventStudyHorizonStat struct {
+	Horizon   string  `json:"horizon"`
+	N         int     `json:"n"`
+	MeanPct   float64 `json:"meanPct"`
+	MedianPct float64 `json:"medianPct"`
+	StdPct    float64 `json:"stdPct"`
+	CILowPct  float64 `json:"ciLowPct"` // 平均收益 95% 置信区间（t 分布）
+	CIHighPct float64 `json:"ciHighPct"`
+	TStat     float64 `json:"tStat"`
+	HitRate   float64 `json:"hitRate"` // 方向收益 > 0 的比例
+	HitCILow  float64 `json:"hitCiLow"`
+	HitCIHigh float64 `json:"hitCiHigh"` // 胜率 95% Wilson 区间
+}
+
+// EventStudyResult 事件研究结果
+type EventStudyResult struct {
+	Type      string                  `json:"type"`
+	From      string                  `json:"from"`
+	To        string                  `json:"to"`
+	Interval  string                  `json:"interval"`
+	Horizons  []string                `json:"horizons"`
+	Events    int                     `json:"events"`
+	Truncated bool                    `json:"truncated"` // 事件数超过 maxEvents，仅保留最近的部分
+	Stats     []EventStudyHorizonStat `json:"stats"`
+	Items     []EventStudyEvent       `json:"items"`
+	Warnings  []string                `json:"warnings,omitempty"`
+}
+
+type eventStudyHorizon struct {
+	name string
+	d    time.Duration
+}
+
+// parseEventHorizon 解析窗口：支持 Go duration（15m、4h）及天数（1d）
+func parseEventHorizon(raw string) (time.Duration, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if strings.HasSuffix(raw, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid horizon %q", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid horizon %q", raw)
+	}
+	return d, nil
+}
+
+// normalizeEventStudyReq 校验并补全默认值，返回解析后的时间范围、窗口与 K 线周期
+func normalizeEventStudyReq(req *EventStudyReq, now time.Time) (time.Time, time.Time, []eventStudyHorizon, time.Duration, error) {
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	switch req.Type {
+	case EventTypeNews:
+		if strings.TrimSpace(req.Keyword) == "" {
+			return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("keyword is required for news events")
+		}
+	case EventTypeLiquidation, EventTypeFunding, EventTypeRecommend:
+	default:
+		return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("type must be news/liquidation/funding/recommend")
+	}
+
+	from, err := parseNewsQueryTime(req.From)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("invalid from")
+	}
+	to, err := parseNewsQueryTime(req.To)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("invalid to")
+	}
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -eventStudyDefaultRangeDays)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("from must be earlier than to")
+	}
+	if to.Sub(from) > eventStudyMaxRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("range too large (max %d days)", eventStudyMaxRangeDays)
+	}
+
+	if req.Interval == "" {
+		req.Interval = "5m"
+	}
+	if !eventStudyIntervals[req.Interval] {
+		return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("interval must be one of 1m/5m/15m/1h/4h")
+	}
+	barDur := klineToCheckInterval(req.Interval)
+
+	if len(req.Horizons) == 0 {
+		req.Horizons = eventStudyDefaultHorizons
+	}
+	horizons := make([]eventStudyHorizon, 0, len(req.Horizons))
+	seen := make(map[time.Duration]bool)
+	for _, raw := range req.Horizons {
+		d, err := parseEventHorizon(raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, nil, 0, err
+		}
+		if d < barDur || d%barDur != 0 {
+			return time.Time{}, time.Time{}, nil, 0, fmt.Errorf("horizon %s must be a multiple of interval %s", raw, req.Interval)
+		}
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		horizons = append(horizons, eventStudyHorizon{name: strings.ToLower(strings.TrimSpace(raw)), d: d})
+	}
+	sort.Slice(horizons, func(i, j int) bool { return horizons[i].d < horizons[j].d })
+	req.Horizons = make([]string, 0, len(horizons))
+	for _, h := range horizons {
+		req.Horizons = append(req.Horizons, h.name)
+	}
+
+	if req.MaxEvents <= 0 {
+		req.MaxEvents = eventStudyDefaultMaxEvents
+	}
+	if req.MaxEvents > eventStudyMaxEvents {
+		req.MaxEvents = eventStudyMaxEvents
+	}
+	if req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol)); req.Symbol != "" && !strings.HasSuffix(req.Symbol, "USDT") {
+		req.Symbol += "USDT"
+	}
+	if req.LiqThresholdUSD <= 0 {
+		req.LiqThresholdUSD = 5_000_000
+	}
+	if req.FundingThresholdPct <= 0 {
+		req.FundingThresholdPct = 0.05
+	}
+	if req.FundingTopN <= 0 {
+		req.FundingTopN = 10
+	}
+	req.Direction = strings.ToUpper(strings.TrimSpace(req.Direction))
+	return from.UTC(), to.UTC(), horizons, barDur, nil
+}
+
+// ========== 事件来源 ==========
+
+// loadStudyEvents 按类型加载 [from, to) 内的事件，按时间升序
+func loadStudyEvents(ctx context.Context, req EventStudyReq, from, to time.Time) ([]EventStudyEvent, error) {
+	var (
+		events []EventStudyEvent
+		err    error
+	)
+	switch req.Type {
+	case EventTypeNews:
+		events, err = loadNewsStudyEvents(req, from, to)
+	case EventTypeLiquidation:
+		events = loadLiquidationStudyEvents(req, from, to)
+	case EventTypeFunding:
+		events, err = loadFundingStudyEvents(ctx, req, from, to)
+	case EventTypeRecommend:
+		events, err = loadRecommendStudyEvents(req, from, to)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	return events, err
+}
+
+// loadNewsStudyEvents 新闻关键词命中：优先查归档库，未启用数据库时退回内存快照
+func loadNewsStudyEvents(req EventStudyReq, from, to time.Time) ([]EventStudyEvent, error) {
+	var out []EventStudyEvent
+	if DB != nil {
+		for offset := 0; len(out) < eventStudyMaxEvents; offset += newsSearchMaxLimit {
+			res, err := SearchNews(NewsSearchQuery{
+				Q: req.Keyword, From: from, To: to, Source: req.Source, Symbol: req.Symbol,
+				Limit: newsSearchMaxLimit, Offset: offset,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range res.Items {
+				sym := r.RefSymbol
+				if req.Symbol != "" {
+					sym = req.Symbol
+				}
+				out = append(out, EventStudyEvent{Type: EventTypeNews, Symbol: sym, Time: r.PublishedAt.UnixMilli(), Label: trimRunes(r.Title, 120)})
+			}
+			if len(res.Items) < newsSearchMaxLimit {
+				break
+			}
+		}
+		return out, nil
+	}
+
+	keyword := strings.ToLower(strings.TrimSpace(req.Keyword))
+	clusters, _, _ := getNewsIndex()
+	for _, c := range clusters {
+		if c.FirstSeen < from.UnixMilli() || c.FirstSeen >= to.UnixMilli() {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(c.Title+" "+c.Summary), keyword) {
+			continue
+		}
+		if req.Source != "" && !containsString(c.Sources, req.Source) {
+			continue
+		}
+		sym := newsReactionMacroRef
+		if len(c.Symbols) > 0 {
+			sym = c.Symbols[0]
+		}
+		if req.Symbol != "" {
+			if len(c.Symbols) > 0 && !containsString(c.Symbols, req.Symbol) {
+				continue
+			}
+			sym = req.Symbol
+		}
+		out = append(out, EventStudyEvent{Type: EventTypeNews, Symbol: sym, Time: c.FirstSeen, Label: trimRunes(c.Title, 120)})
+	}
+	return out, nil
+}
+
+// loadLiquidationStudyEvents 单币种 1h 爆仓尖峰；事件时间取桶结束时刻，避免用到桶内未来信息
+// 数据来自内存统计（保留 liquidationRetentionDays 天）
+func loadLiquidationStudyEvents(req EventStudyReq, from, to time.Time) []EventStudyEvent {
+	hourMs := time.Hour.Milliseconds()
+	spikes := liquidationStore.symbolSpikes(req.Symbol, req.LiqThresholdUSD, from.UnixMilli()-hourMs, to.UnixMilli()-hourMs)
+	out := make([]EventStudyEvent, 0, len(spikes))
+	for _, s := range spikes {
+		out = append(out, EventStudyEvent{
+			Type:   EventTypeLiquidation,
+			Symbol: s.Symbol,
+			Time:   s.StartTime + hourMs,
+			Label:  fmt.Sprintf("1h 爆仓 %.1fM USD / %d 笔", s.Notional/1e6, s.Count),
+			Value:  roundFloat(s.Notional, 2),
+		})
+	}
+	return out
+}
+
+// loadFundingStudyEvents 资金费率极值：未指定币种时按当前费率绝对值取前 N 个币种，
+// 再在其历史结算记录中筛选超过阈值的期次；正费率预期回落记为看空，负费率记为看多
+func loadFundingStudyEvents(ctx context.Context, req EventStudyReq, from, to time.Time) ([]EventStudyEvent, error) {
+	if Client == nil {
+		return nil, fmt.Errorf("binance client not initialized")
+	}
+	symbols := []string{}
+	if req.Symbol != "" {
+		symbols = append(symbols, req.Symbol)
+	} else {
+		items, err := fetchAllFundingRates(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetch funding rates: %w", err)
+		}
+		usdt := make([]FundingRateItem, 0, len(items))
+		for _, it := range items {
+			if strings.HasSuffix(it.Symbol, "USDT") {
+				usdt = append(usdt, it)
+			}
+		}
+		sort.Slice(usdt, func(i, j int) bool { return math.Abs(usdt[i].FundingRate) > math.Abs(usdt[j].FundingRate) })
+		for i := 0; i < len(usdt) && i < req.FundingTopN; i++ {
+			symbols = append(symbols, usdt[i].Symbol)
+		}
+	}
+
+	th := req.FundingThresholdPct / 100
+	var out []EventStudyEvent
+	for _, sym := range symbols {
+		start := from.UnixMilli()
+		for start < to.UnixMilli() {
+			list, err := Client.NewFundingRateService().Symbol(sym).StartTime(start).EndTime(to.UnixMilli()).Limit(1000).Do(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("funding history %s: %w", sym, err)
+			}
+			for _, f := range list {
+				rate := mustParseFloat(f.FundingRate)
+				if math.Abs(rate) < th {
+					continue
+				}
+				sign := 1
+				if rate > 0 {
+					sign = -1
+				}
+				out = append(out, EventStudyEvent{
+					Type:   EventTypeFunding,
+					Symbol: sym,
+					Time:   f.FundingTime,
+					Sign:   sign,
+					Label:  fmt.Sprintf("费率 %.4f%%", rate*100),
+					Value:  roundFloat(rate*100, 6),
+				})
+			}
+			if len(list) < 1000 {
+				break
+			}
+			start = list[len(list)-1].FundingTime + 1
+		}
+	}
+	return out, nil
+}
+
+// loadRecommendStudyEvents 推荐信号：LONG 记为看多，SHORT 记为看空
+func loadRecommendStudyEvents(req EventStudyReq, from, to time.Time) ([]EventStudyEvent, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	tx := DB.Where("scanned_at >= ? AND scanned_at < ?", from, to)
+	if req.Symbol != "" {
+		tx = tx.Where("symbol = ?", req.Symbol)
+	}
+	if req.Source != "" {
+		tx = tx.Where("source = ?", req.Source)
+	}
+	if req.Direction != "" {
+		tx = tx.Where("direction = ?", req.Direction)
+	}
+	if req.MinConfidence > 0 {
+		tx = tx.Where("confidence >= ?", req.MinConfidence)
+	}
+	var rows []RecommendSignalRecord
+	if err := tx.Order("scanned_at DESC").Limit(eventStudyMaxEvents + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]EventStudyEvent, 0, len(rows))
+	for _, r := range rows {
+		sign := 1
+		if strings.EqualFold(r.Direction, "SHORT") {
+			sign = -1
+		}
+		out = append(out, EventStudyEvent{
+			Type:   EventTypeRecommend,
+			Symbol: r.Symbol,
+			Time:   r.ScannedAt.UnixMilli(),
+			Sign:   sign,
+			Label:  fmt.Sprintf("%s %s", r.Direction, r.Source),
+			Value:  float64(r.Confidence),
+		})
+	}
+	return out, nil
+}
+
+// ========== 远期收益 ==========
+
+// fetchKlinesRange 分批拉取 [start, end] 区间的 K 线（升序）
+func fetchKlinesRange(ctx context.Context, symbol, interval string, start, end int64) ([]*futures.Kline, error) {
+	const batch = 1500
+	var out []*futures.Kline
+	for start < end {
+		klines, err := Client.NewKlinesService().Symbol(symbol).Interval(interval).
+			StartTime(start).EndTime(end).Limit(batch).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(klines) == 0 {
+			break
+		}
+		out = append(out, klines...)
+		if len(klines) < batch {
+			break
+		}
+		start = klines[len(klines)-1].CloseTime + 1
+	}
+	return out, nil
+}
+
+// eventForwardReturns 以事件后第一根 K 线开盘价为基准，计算各窗口结束时的收盘涨跌幅（%）
+// 窗口内 K 线不完整（数据尚未产生或缺失）时该窗口不返回
+func eventForwardReturns(klines []*futures.Kline, ts int64, barDur time.Duration, horizons []eventStudyHorizon) (float64, map[string]float64) {
+	rets := make(map[string]float64)
+	idx := sort.Search(len(klines), func(i int) bool { return klines[i].OpenTime >= ts })
+	if idx >= len(klines) {
+		return 0, rets
+	}
+	entry := klines[idx].OpenTime
+	base := mustParseFloat(klines[idx].Open)
+	if base <= 0 || entry-ts >= barDur.Milliseconds() {
+		return 0, rets
+	}
+	for _, h := range horizons {
+		exitOpen := entry + h.d.Milliseconds() - barDur.Milliseconds()
+		j := sort.Search(len(klines), func(i int) bool { return klines[i].OpenTime >= exitOpen })
+		if j >= len(klines) || klines[j].OpenTime != exitOpen {
+			continue
+		}
+		if c := mustParseFloat(klines[j].Close); c > 0 {
+			rets[h.name] = roundFloat((c-base)/base*100, 4)
+		}
+	}
+	return base, rets
+}
+
+// fillEventReturns 按币种拉取 K 线并填充事件远期收益，返回告警信息
+func fillEventReturns(ctx context.Context, events []EventStudyEvent, interval string, barDur time.Duration, horizons []eventStudyHorizon) []string {
+	var warnings []string
+	if Client == nil {
+		return append(warnings, "binance client not initialized, returns not computed")
+	}
+	bySymbol := make(map[string][]int)
+	var order []string
+	for i, ev := range events {
+		if _, ok := bySymbol[ev.Symbol]; !ok {
+			order = append(order, ev.Symbol)
+		}
+		bySymbol[ev.Symbol] = append(bySymbol[ev.Symbol], i)
+	}
+	if len(order) > eventStudyMaxSymbols {
+		warnings = append(warnings, fmt.Sprintf("%d symbols, only the first %d are evaluated", len(order), eventStudyMaxSymbols))
+		order = order[:eventStudyMaxSymbols]
+	}
+
+	maxH := horizons[len(horizons)-1].d.Milliseconds()
+	now := time.Now().UnixMilli()
+	for _, sym := range order {
+		idxs := bySymbol[sym]
+		start := events[idxs[0]].Time - barDur.Milliseconds()
+		end := events[idxs[len(idxs)-1]].Time + maxH + barDur.Milliseconds()
+		if end > now {
+			end = now
+		}
+		if bars := (end - start) / barDur.Milliseconds(); bars > eventStudyMaxBarsPerSymbol {
+			warnings = append(warnings, fmt.Sprintf("%s: %d bars exceeds limit, use a larger interval", sym, bars))
+			continue
+		}
+		klines, err := fetchKlinesRange(ctx, sym, interval, start, end)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", sym, err))
+			continue
+		}
+		for _, i := range idxs {
+			events[i].BasePrice, events[i].Returns = eventForwardReturns(klines, events[i].Time, barDur, horizons)
+		}
+	}
+	return warnings
+}
+
+// ========== 统计 ==========
+
+// tCritical95 双侧 95% t 分布临界值（自由度 1~30），更大自由度取正态近似
+var tCritical95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+func tCritical(df int) float64 {
+	if df <= 0 {
+		return 0
+	}
+	if df <= len(tCritical95) {
+		return tCritical95[df-1]
+	}
+	return 1.96
+}
+
+// wilsonInterval 胜率的 95% Wilson 置信区间
+func wilsonInterval(hits, n int) (float64, float64) {
+	if n == 0 {
+		return 0, 0
+	}
+	const z = 1.96
+	p := float64(hits) / float64(n)
+	nf := float64(n)
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return math.Max(0, center-half), math.Min(1, center+half)
+}
+
+// eventHorizonStats 汇总单窗口的方向收益
+func eventHorizonStats(events []EventStudyEvent, horizon string) EventStudyHorizonStat {
+	st := EventStudyHorizonStat{Horizon: horizon}
+	var vals []float64
+	hits := 0
+	for _, ev := range events {
+		r, ok := ev.Returns[horizon]
+		if !ok {
+			continue
+		}
+		if ev.Sign < 0 {
+			r = -r
+		}
+		vals = append(vals, r)
+		if r > 0 {
+			hits++
+		}
+	}
+	st.N = len(vals)
+	if st.N == 0 {
+		return st
+	}
+
+	sum := 0.0
+	for _, v := range vals {
+		sum += v
+	}
+	mean := sum / float64(st.N)
+	sorted := append([]float64(nil), vals...)
+	sort.Float64s(sorted)
+	median := sorted[st.N/2]
+	if st.N%2 == 0 {
+		median = (sorted[st.N/2-1] + sorted[st.N/2]) / 2
+	}
+	st.MeanPct = roundFloat(mean, 4)
+	st.MedianPct = roundFloat(median, 4)
+	st.CILowPct, st.CIHighPct = st.MeanPct, st.MeanPct
+	if st.N > 1 {
+		ss := 0.0
+		for _, v := range vals {
+			ss += (v - mean) * (v - mean)
+		}
+		std := math.Sqrt(ss / float64(st.N-1))
+		se := std / math.Sqrt(float64(st.N))
+		half := tCritical(st.N-1) * se
+		st.StdPct = roundFloat(std, 4)
+		st.CILowPct = roundFloat(mean-half, 4)
+		st.CIHighPct = roundFloat(mean+half, 4)
+		if se > 0 {
+			st.TStat = roundFloat(mean/se, 3)
+		}
+	}
+	lo, hi := wilsonInterval(hits, st.N)
+	st.HitRate = roundFloat(float64(hits)/float64(st.N), 4)
+	st.HitCILow = roundFloat(lo, 4)
+	st.HitCIHigh = roundFloat(hi, 4)
+	return st
+}
+
+// RunEventStudy 执行事件研究
+func RunEventStudy(ctx context.Context, req EventStudyReq) (*EventStudyResult, error) {
+	from, to, horizons, barDur, err := normalizeEventStudyReq(&req, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	events, err := loadStudyEvents(ctx, req, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &EventStudyResult{
+		Type:     req.Type,
+		From:     from.Format(time.RFC3339),
+		To:       to.Format(time.RFC3339),
+		Interval: req.Interval,
+		Horizons: req.Horizons,
+		Items:    []EventStudyEvent{},
+		Stats:    []EventStudyHorizonStat{},
+	}
+	if len(events) > req.MaxEvents {
+		events = events[len(events)-req.MaxEvents:]
+		res.Truncated = true
+	}
+	if len(events) > 0 {
+		res.Warnings = fillEventReturns(ctx, events, req.Interval, barDur, horizons)
+		res.Items = events
+	}
+	res.Events = len(events)
+	for _, h := range horizons {
+		res.Stats = append(res.Stats, eventHorizonStats(events, h.name))
+	}
+	return res, nil
+}
+
+// ========== 导出 ==========
+
+// eventStudyCSV 导出事件明细（events）或窗口统计（stats）
+func eventStudyCSV(res *EventStudyResult, table string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	if table == "stats" {
+		w.Write([]string{"horizon", "n", "mean_pct", "median_pct", "std_pct", "ci_low_pct", "ci_high_pct", "t_stat", "hit_rate", "hit_ci_low", "hit_ci_high"})
+		for _, s := range res.Stats {
+			w.Write([]string{s.Horizon, strconv.Itoa(s.N), f(s.MeanPct), f(s.MedianPct), f(s.StdPct), f(s.CILowPct), f(s.CIHighPct), f(s.TStat), f(s.HitRate), f(s.HitCILow), f(s.HitCIHigh)})
+		}
+	} else {
+		header := []string{"type", "symbol", "time", "sign", "label", "value", "base_price"}
+		for _, h := range res.Horizons {
+			header = append(header, "ret_"+h)
+		}
+		w.Write(header)
+		for _, ev := range res.Items {
+			row := []string{ev.Type, ev.Symbol, time.UnixMilli(ev.Time).UTC().Format(time.RFC3339), strconv.Itoa(ev.Sign), ev.Label, f(ev.Value), f(ev.BasePrice)}
+			for _, h := range res.Horizons {
+				if r, ok := ev.Returns[h]; ok {
+					row = append(row, f(r))
+				} else {
+					row = append(row, "")
+				}
+			}
+			w.Write(row)
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// HandleEventStudy POST /tool/analytics/event-study
+// format=csv（请求体或查询参数）时返回 CSV 附件，table=stats 导出窗口统计，否则导出事件明细
+func HandleEventStudy(c context.Context, ctx *app.RequestContext) {
+	var req EventStudyReq
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if q := ctx.Query("format"); q != "" {
+		req.Format = q
+	}
+	if q := ctx.Query("table"); q != "" {
+		req.Table = q
+	}
+
+	runCtx, cancel := context.WithTimeout(c, eventStudyTimeout)
+	defer cancel()
+	res, err := RunEventStudy(runCtx, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	if strings.EqualFold(req.Format, "csv") {
+		table := strings.ToLower(req.Table)
+		if table != "stats" {
+			table = "events"
+		}
+		body, err := eventStudyCSV(res, table)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+		ctx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="event_study_%s_%s.csv"`, res.Type, table))
+		ctx.Data(http.StatusOK, "text/csv; charset=utf-8", body)
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": res})
+}
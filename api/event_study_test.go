@@ -0,0 +1,150 @@
+package api
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestEventForwardReturns(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	bar := 5 * time.Minute
+	var klines []*futures.Kline
+	for i := 0; i < 24; i++ {
+		open := 100 + float64(i)
+		klines = append(klines, &futures.Kline{
+			OpenTime:  start + int64(i)*bar.Milliseconds(),
+			CloseTime: start + int64(i+1)*bar.Milliseconds() - 1,
+			Open:      strconv.FormatFloat(open, 'f', 2, 64),
+			Close:     strconv.FormatFloat(open+1, 'f', 2, 64),
+		})
+	}
+	horizons := []eventStudyHorizon{{"15m", 15 * time.Minute}, {"1h", time.Hour}, {"4h", 4 * time.Hour}}
+
+	// 事件发生在第一根 K 线中途：从第二根开盘价 101 起算
+	base, rets := eventForwardReturns(klines, start+time.Minute.Milliseconds(), bar, horizons)
+	if base != 101 {
+		t.Fatalf("base=%v want 101", base)
+	}
+	// 15m 窗口为第 2~4 根，收盘价 104
+	if math.Abs(rets["15m"]-roundFloat(3.0/101*100, 4)) > 1e-9 {
+		t.Fatalf("15m=%v", rets["15m"])
+	}
+	// 1h 窗口需要第 13 根（下标 12），收盘 113
+	if math.Abs(rets["1h"]-roundFloat(12.0/101*100, 4)) > 1e-9 {
+		t.Fatalf("1h=%v", rets["1h"])
+	}
+	if _, ok := rets["4h"]; ok {
+		t.Fatalf("4h should be missing: %+v", rets)
+	}
+	if base, _ := eventForwardReturns(klines, start+time.Hour.Milliseconds()*3, bar, horizons); base != 0 {
+		t.Fatalf("event after last kline should have no base, got %v", base)
+	}
+}
+
+func TestEventHorizonStats(t *testing.T) {
+	events := []EventStudyEvent{
+		{Sign: 1, Returns: map[string]float64{"1h": 2}},
+		{Sign: -1, Returns: map[string]float64{"1h": -1}}, // 看空且下跌：方向收益 +1
+		{Sign: 0, Returns: map[string]float64{"1h": -3}},
+		{Sign: 1, Returns: map[string]float64{"1h": 4}},
+		{Sign: 1, Returns: map[string]float64{}},
+	}
+	st := eventHorizonStats(events, "1h")
+	if st.N != 4 || st.MeanPct != 1 || st.MedianPct != 1.5 || st.HitRate != 0.75 {
+		t.Fatalf("stats=%+v", st)
+	}
+	// 样本标准差 sqrt(26/3)，t(3)=3.182
+	half := 3.182 * math.Sqrt(26.0/3) / 2
+	if math.Abs(st.CILowPct-roundFloat(1-half, 4)) > 1e-9 || math.Abs(st.CIHighPct-roundFloat(1+half, 4)) > 1e-9 {
+		t.Fatalf("ci=[%v,%v]", st.CILowPct, st.CIHighPct)
+	}
+	if st.HitCILow <= 0 || st.HitCILow >= 0.75 || st.HitCIHigh <= 0.75 || st.HitCIHigh > 1 {
+		t.Fatalf("wilson=[%v,%v]", st.HitCILow, st.HitCIHigh)
+	}
+	if empty := eventHorizonStats(events, "4h"); empty.N != 0 || empty.MeanPct != 0 {
+		t.Fatalf("empty stats=%+v", empty)
+	}
+}
+
+func TestNormalizeEventStudyReq(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	req := EventStudyReq{Type: "Funding", Horizons: []string{"1d", "15m", "1h", "60m"}, Symbol: "eth"}
+	from, to, horizons, bar, err := normalizeEventStudyReq(&req, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !to.Equal(now) || !from.Equal(now.AddDate(0, 0, -eventStudyDefaultRangeDays)) || bar != 5*time.Minute {
+		t.Fatalf("from=%v to=%v bar=%v", from, to, bar)
+	}
+	if len(horizons) != 3 || strings.Join(req.Horizons, ",") != "15m,1h,1d" || req.Symbol != "ETHUSDT" {
+		t.Fatalf("horizons=%v symbol=%s", req.Horizons, req.Symbol)
+	}
+
+	bad := []EventStudyReq{
+		{Type: "news"},
+		{Type: "unknown"},
+		{Type: "funding", Interval: "1h", Horizons: []string{"30m"}},
+		{Type: "funding", Interval: "7m"},
+		{Type: "funding", From: "2026-10-02", To: "2026-10-01"},
+	}
+	for i := range bad {
+		if _, _, _, _, err := normalizeEventStudyReq(&bad[i], now); err == nil {
+			t.Fatalf("case %d: expected error for %+v", i, bad[i])
+		}
+	}
+}
+
+func TestLiquidationStudyEvents(t *testing.T) {
+	store := &liquidationStatsStore{
+		daily:     make(map[int64]*liquidationBucketStat),
+		h4:        make(map[int64]*liquidationBucketStat),
+		h1:        make(map[int64]*liquidationBucketStat),
+		symbolH1:  make(map[int64]*symbolBucket),
+		symbolH4:  make(map[int64]*symbolBucket),
+		symbolDay: make(map[int64]*symbolBucket),
+	}
+	now := time.Now().UTC()
+	hour := utcHourStart(now.Add(-3*time.Hour), 1)
+	store.addEvent(hour.UnixMilli()+1000, "BTCUSDT", "SELL", 6e6)
+	store.addEvent(hour.UnixMilli()+2000, "ETHUSDT", "SELL", 1e6)
+
+	orig := liquidationStore
+	liquidationStore = store
+	defer func() { liquidationStore = orig }()
+
+	events := loadLiquidationStudyEvents(EventStudyReq{LiqThresholdUSD: 5e6}, now.Add(-24*time.Hour), now)
+	if len(events) != 1 || events[0].Symbol != "BTCUSDT" || events[0].Time != hour.Add(time.Hour).UnixMilli() {
+		t.Fatalf("events=%+v", events)
+	}
+}
+
+func TestEventStudyCSV(t *testing.T) {
+	res := &EventStudyResult{
+		Horizons: []string{"1h", "4h"},
+		Items: []EventStudyEvent{{
+			Type: EventTypeRecommend, Symbol: "BTCUSDT", Time: 0, Sign: 1, Label: "LONG, scan", Value: 80,
+			BasePrice: 100, Returns: map[string]float64{"1h": 1.5},
+		}},
+		Stats: []EventStudyHorizonStat{{Horizon: "1h", N: 1, MeanPct: 1.5}},
+	}
+	body, err := eventStudyCSV(res, "events")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 || lines[0] != "type,symbol,time,sign,label,value,base_price,ret_1h,ret_4h" {
+		t.Fatalf("csv=%q", body)
+	}
+	if lines[1] != `recommend,BTCUSDT,1970-01-01T00:00:00Z,1,"LONG, scan",80,100,1.5,` {
+		t.Fatalf("row=%q", lines[1])
+	}
+	body, _ = eventStudyCSV(res, "stats")
+	if !strings.HasPrefix(string(body), "horizon,n,mean_pct") || !strings.Contains(string(body), "\n1h,1,1.5,") {
+		t.Fatalf("stats csv=%q", body)
+	}
+}
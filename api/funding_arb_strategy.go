@@ -23,6 +23,16 @@ type FundingArbConfig struct {
 	AmountPerOrder    string   `json:"amountPerOrder"`    // 默认 50
 	HighRateThreshold float64  `json:"highRateThreshold"` // 费率阈值，默认 0.05 (0.05%)
 	CooldownHours     int      `json:"cooldownHours"`     // 冷却小时，默认 8
+
+	// 对冲模式（期现套利）：永续腿 + 反向现货/杠杆腿，只赚费率不担价格风险
+	Hedged            bool    `json:"hedged"`
+	SpotMode          string  `json:"spotMode"`          // spot（仅正费率，买现货）/ margin（可借币做空现货），默认 spot
+	MinAnnualCarry    float64 `json:"minAnnualCarry"`    // 年化 carry 低于该值（%）视为失效，默认 5
+	ExitConfirmChecks int     `json:"exitConfirmChecks"` // 连续多少次低于阈值才平仓，默认 3
+	RebalanceDriftPct float64 `json:"rebalanceDriftPct"` // 两腿数量偏离超过该比例（%）时再平衡，默认 5
+	MaxBasisPct       float64 `json:"maxBasisPct"`       // 开仓时允许的最大基差（%），默认 0.5
+	MaxCarryPositions int     `json:"maxCarryPositions"` // 最多同时持有的对冲仓位，默认 3
+	BorrowAPR         float64 `json:"borrowAPR"`         // 杠杆借币年化成本（%），做空现货时从 carry 中扣除
 }
 
 // FundingArbStatus 策略状态
@@ -33,6 +43,9 @@ type FundingArbStatus struct {
 	ActiveTrades  map[string]fundingArbTrade `json:"activeTrades"` // symbol -> 持仓信息
 	TotalTrades   int                        `json:"totalTrades"`
 	LastError     string                     `json:"lastError"`
+
+	CarryPositions     []FundingCarryPosition `json:"carryPositions,omitempty"` // 对冲模式持仓
+	TotalFundingIncome float64                `json:"totalFundingIncome"`       // 对冲模式累计费率收入（含已平仓）
 }
 
 // fundingArbTrade 单个套利持仓记录
@@ -53,10 +66,13 @@ type fundingArbState struct {
 	cooldownMap  map[string]time.Time        // symbol -> 上次触发时间
 	totalTrades  int
 	lastError    string
+
+	carry        map[string]*FundingCarryPosition // symbol -> 对冲仓位
+	carryIncome  float64                          // 已平仓对冲仓位的累计费率收入
 }
 
 var (
-	fundingArb     = &fundingArbState{activeTrades: make(map[string]fundingArbTrade), cooldownMap: make(map[string]time.Time)}
+	fundingArb     = &fundingArbState{activeTrades: make(map[string]fundingArbTrade), cooldownMap: make(map[string]time.Time), carry: make(map[string]*FundingCarryPosition)}
 	fundingArbOnce sync.Mutex
 )
 
@@ -85,6 +101,11 @@ func StartFundingArb(cfg FundingArbConfig) error {
 	if cfg.CooldownHours <= 0 {
 		cfg.CooldownHours = 8
 	}
+	if cfg.Hedged {
+		if err := normalizeFundingCarryConfig(&cfg); err != nil {
+			return err
+		}
+	}
 
 	stopC := make(chan struct{})
 
@@ -94,6 +115,8 @@ func StartFundingArb(cfg FundingArbConfig) error {
 	fundingArb.stopC = stopC
 	fundingArb.activeTrades = make(map[string]fundingArbTrade)
 	fundingArb.cooldownMap = make(map[string]time.Time)
+	// 对冲仓位是真实持仓，停止策略不会平掉，重启时从运行时状态接管
+	fundingArb.carry, fundingArb.carryIncome = loadFundingCarryRuntime()
 	fundingArb.mu.Unlock()
 
 	go runFundingArbLoop(stopC, cfg)
@@ -138,6 +161,12 @@ func GetFundingArbStatus() *FundingArbStatus {
 		ActiveTrades: trades,
 		TotalTrades:  fundingArb.totalTrades,
 		LastError:    fundingArb.lastError,
+
+		CarryPositions:     fundingCarryList(fundingArb.carry),
+		TotalFundingIncome: fundingArb.carryIncome,
+	}
+	for _, p := range s.CarryPositions {
+		s.TotalFundingIncome += p.FundingIncome
 	}
 	if !fundingArb.lastCheckAt.IsZero() {
 		s.LastCheckAt = fundingArb.lastCheckAt.Format(time.RFC3339)
@@ -176,6 +205,10 @@ func checkFundingArb(cfg FundingArbConfig) {
 		log.Printf("[FundingArb] Fetch rates failed: %v", err)
 		return
 	}
+	if cfg.Hedged {
+		checkFundingCarry(ctx, cfg, items)
+		return
+	}
 
 	cooldownDur := time.Duration(cfg.CooldownHours) * time.Hour
 
@@ -0,0 +1,700 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// ========== 资金费率期现对冲（cash-and-carry） ==========
+// 正费率：做空永续 + 买入现货；负费率：做多永续 + 杠杆借币卖出现货
+// 价格风险由两腿对冲，收益来自资金费率；跟踪基差与实际费率收入，
+// 两腿数量偏离时再平衡，年化 carry 连续低于阈值时双腿平仓
+
+const fundingCarrySource = "funding_arb"
+
+// FundingCarryPosition 单个期现对冲仓位
+type FundingCarryPosition struct {
+	Symbol    string  `json:"symbol"`
+	PerpSide  string  `json:"perpSide"` // SHORT / LONG
+	PerpQty   float64 `json:"perpQty"`
+	PerpEntry float64 `json:"perpEntry"`
+	SpotSide  string  `json:"spotSide"` // BUY（持有现货）/ SELL（借币卖出）
+	SpotMode  string  `json:"spotMode"` // spot / margin
+	SpotQty   float64 `json:"spotQty"`
+	SpotEntry float64 `json:"spotEntry"`
+
+	EntryBasisPct  float64 `json:"entryBasisPct"`  // 开仓基差 (永续-现货)/现货 %
+	BasisPct       float64 `json:"basisPct"`       // 最新基差
+	FundingRate    float64 `json:"fundingRate"`    // 最新费率（原始小数）
+	AnnualCarryPct float64 `json:"annualCarryPct"` // 最新年化 carry（已扣借币成本）
+	FundingIncome  float64 `json:"fundingIncome"`  // 累计费率收入 USDT
+	FundingCount   int     `json:"fundingCount"`   // 已结算次数
+	LastIncomeAt   int64   `json:"lastIncomeAt"`   // 最后一条收入记录时间戳（毫秒）
+	NextFundingAt  int64   `json:"nextFundingAt"`  // 下次结算时间戳（毫秒）
+	LowCarryChecks int     `json:"lowCarryChecks"` // 连续低 carry 次数
+	Rebalances     int     `json:"rebalances"`
+	OpenedAt       string  `json:"openedAt"`
+	Closing        bool    `json:"closing"` // 平仓/回滚未完成，下一轮重试
+	CloseReason    string  `json:"closeReason,omitempty"`
+}
+
+// fundingCarryRuntime 对冲模式的持久化运行时状态
+type fundingCarryRuntime struct {
+	Positions []FundingCarryPosition `json:"positions"`
+	Income    float64                `json:"income"` // 已平仓累计费率收入
+}
+
+// spotSymbolInfo 现货交易对规则
+type spotSymbolInfo struct {
+	StepSize      float64
+	Precision     int
+	MinQty        float64
+	MarginAllowed bool
+}
+
+var (
+	spotInfoMu    sync.RWMutex
+	spotInfoCache map[string]spotSymbolInfo
+	spotInfoAt    time.Time
+)
+
+func normalizeFundingCarryConfig(cfg *FundingArbConfig) error {
+	cfg.SpotMode = strings.ToLower(cfg.SpotMode)
+	if cfg.SpotMode == "" {
+		cfg.SpotMode = "spot"
+	}
+	if cfg.SpotMode != "spot" && cfg.SpotMode != "margin" {
+		return fmt.Errorf("spotMode must be spot or margin")
+	}
+	if cfg.MinAnnualCarry <= 0 {
+		cfg.MinAnnualCarry = 5
+	}
+	if cfg.ExitConfirmChecks <= 0 {
+		cfg.ExitConfirmChecks = 3
+	}
+	if cfg.RebalanceDriftPct <= 0 {
+		cfg.RebalanceDriftPct = 5
+	}
+	if cfg.MaxBasisPct <= 0 {
+		cfg.MaxBasisPct = 0.5
+	}
+	if cfg.MaxCarryPositions <= 0 {
+		cfg.MaxCarryPositions = 3
+	}
+	if cfg.BorrowAPR < 0 {
+		return fmt.Errorf("borrowAPR must be >= 0")
+	}
+	return nil
+}
+
+// fundingCarryAPR 按持仓方向计算年化 carry（%）：做空永续收正费率，做多永续收负费率；
+// 做多永续时现货腿为借币卖出，需扣除借币成本
+func fundingCarryAPR(annualizedPct float64, perpSide string, borrowAPR float64) float64 {
+	if perpSide == "LONG" {
+		return -annualizedPct - borrowAPR
+	}
+	return annualizedPct
+}
+
+// fundingCarryBasisPct 基差百分比 (永续-现货)/现货
+func fundingCarryBasisPct(perpPrice, spotPrice float64) float64 {
+	if spotPrice <= 0 {
+		return 0
+	}
+	return (perpPrice - spotPrice) / spotPrice * 100
+}
+
+// fundingCarryDriftPct 两腿数量偏离百分比（相对较大一腿）
+func fundingCarryDriftPct(perpQty, spotQty float64) float64 {
+	base := math.Max(perpQty, spotQty)
+	if base <= 0 {
+		return 0
+	}
+	return math.Abs(perpQty-spotQty) / base * 100
+}
+
+// fundingCarryAccrual 按费率估算一次结算的收入（模拟盘用）
+func fundingCarryAccrual(perpSide string, qty, markPrice, rate float64) float64 {
+	income := qty * markPrice * rate
+	if perpSide == "LONG" {
+		return -income
+	}
+	return income
+}
+
+func fundingCarryList(m map[string]*FundingCarryPosition) []FundingCarryPosition {
+	list := make([]FundingCarryPosition, 0, len(m))
+	for _, p := range m {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+	return list
+}
+
+func loadFundingCarryRuntime() (map[string]*FundingCarryPosition, float64) {
+	positions := make(map[string]*FundingCarryPosition)
+	var rt fundingCarryRuntime
+	if !LoadStrategyRuntime("funding_arb", "*", &rt) {
+		return positions, 0
+	}
+	for i := range rt.Positions {
+		p := rt.Positions[i]
+		positions[p.Symbol] = &p
+	}
+	if len(positions) > 0 {
+		log.Printf("[FundingArb] Restored %d hedged carry positions", len(positions))
+	}
+	return positions, rt.Income
+}
+
+func saveFundingCarry() {
+	fundingArb.mu.RLock()
+	rt := fundingCarryRuntime{Positions: fundingCarryList(fundingArb.carry), Income: fundingArb.carryIncome}
+	fundingArb.mu.RUnlock()
+	if len(rt.Positions) == 0 && rt.Income == 0 {
+		SaveStrategyRuntime("funding_arb", "*", nil)
+		return
+	}
+	SaveStrategyRuntime("funding_arb", "*", rt)
+}
+
+func setFundingCarry(pos *FundingCarryPosition) {
+	fundingArb.mu.Lock()
+	if pos == nil {
+		fundingArb.mu.Unlock()
+		return
+	}
+	fundingArb.carry[pos.Symbol] = pos
+	fundingArb.mu.Unlock()
+}
+
+// checkFundingCarry 对冲模式主流程：先维护已有仓位，再寻找新机会
+func checkFundingCarry(ctx context.Context, cfg FundingArbConfig, items []FundingRateItem) {
+	rates := make(map[string]FundingRateItem, len(items))
+	for _, item := range items {
+		rates[item.Symbol] = item
+	}
+	spotPrices, err := fetchSpotPrices(ctx)
+	if err != nil {
+		fundingArb.mu.Lock()
+		fundingArb.lastError = err.Error()
+		fundingArb.mu.Unlock()
+		log.Printf("[FundingArb] Fetch spot prices failed: %v", err)
+		return
+	}
+
+	fundingArb.mu.RLock()
+	held := fundingCarryList(fundingArb.carry)
+	fundingArb.mu.RUnlock()
+
+	for i := range held {
+		pos := held[i]
+		item, ok := rates[pos.Symbol]
+		if !ok {
+			continue
+		}
+		fundingCarryManage(ctx, cfg, &pos, item, spotPrices[pos.Symbol])
+	}
+
+	fundingArb.mu.RLock()
+	open := len(fundingArb.carry)
+	cooldownMap := make(map[string]time.Time, len(fundingArb.cooldownMap))
+	for k, v := range fundingArb.cooldownMap {
+		cooldownMap[k] = v
+	}
+	heldSet := make(map[string]bool, len(fundingArb.carry))
+	for sym := range fundingArb.carry {
+		heldSet[sym] = true
+	}
+	fundingArb.mu.RUnlock()
+
+	watchSet := make(map[string]bool)
+	for _, s := range cfg.Symbols {
+		watchSet[s] = true
+	}
+	cooldownDur := time.Duration(cfg.CooldownHours) * time.Hour
+
+	// 按 carry 从高到低挑选
+	sort.Slice(items, func(i, j int) bool { return math.Abs(items[i].FundingRate) > math.Abs(items[j].FundingRate) })
+	for _, item := range items {
+		if open >= cfg.MaxCarryPositions {
+			break
+		}
+		if !strings.HasSuffix(item.Symbol, "USDT") || heldSet[item.Symbol] {
+			continue
+		}
+		if len(cfg.Symbols) > 0 && !watchSet[item.Symbol] {
+			continue
+		}
+		if t, ok := cooldownMap[item.Symbol]; ok && time.Since(t) < cooldownDur {
+			continue
+		}
+		if math.Abs(item.FundingRate) < cfg.HighRateThreshold/100 {
+			continue
+		}
+		perpSide := "SHORT"
+		if item.FundingRate < 0 {
+			// 负费率需要借币卖出现货
+			if cfg.SpotMode != "margin" {
+				continue
+			}
+			perpSide = "LONG"
+		}
+		apr := fundingCarryAPR(item.AnnualizedPct, perpSide, cfg.BorrowAPR)
+		if apr < cfg.MinAnnualCarry {
+			continue
+		}
+		if time.Until(time.UnixMilli(item.NextFundingTime)) < 30*time.Minute {
+			continue
+		}
+		spotPrice := spotPrices[item.Symbol]
+		if spotPrice <= 0 {
+			// 无同名现货（如 1000SHIBUSDT），无法对冲
+			continue
+		}
+		basis := fundingCarryBasisPct(item.MarkPrice, spotPrice)
+		if math.Abs(basis) > cfg.MaxBasisPct {
+			log.Printf("[FundingArb] %s basis %.3f%% exceeds %.3f%%, skip", item.Symbol, basis, cfg.MaxBasisPct)
+			continue
+		}
+		info, err := getSpotSymbolInfo(ctx, item.Symbol)
+		if err != nil {
+			continue
+		}
+		if cfg.SpotMode == "margin" && !info.MarginAllowed {
+			continue
+		}
+		if err := fundingCarryOpen(ctx, cfg, item, perpSide, spotPrice, info); err != nil {
+			log.Printf("[FundingArb] Hedged open %s failed: %v", item.Symbol, err)
+			fundingArb.mu.Lock()
+			fundingArb.lastError = err.Error()
+			fundingArb.cooldownMap[item.Symbol] = time.Now()
+			fundingArb.mu.Unlock()
+			continue
+		}
+		open++
+	}
+	saveFundingCarry()
+}
+
+// fundingCarryManage 维护已有仓位：重试未完成平仓、累计费率、更新基差/carry、再平衡、低 carry 退出
+func fundingCarryManage(ctx context.Context, cfg FundingArbConfig, pos *FundingCarryPosition, item FundingRateItem, spotPrice float64) {
+	if pos.Closing {
+		fundingCarryClose(ctx, cfg, pos, pos.CloseReason)
+		return
+	}
+
+	fundingCarryAccrue(ctx, pos, item)
+
+	if spotPrice > 0 {
+		pos.BasisPct = roundFloat(fundingCarryBasisPct(item.MarkPrice, spotPrice), 4)
+	}
+	pos.FundingRate = item.FundingRate
+	pos.NextFundingAt = item.NextFundingTime
+	pos.AnnualCarryPct = roundFloat(fundingCarryAPR(item.AnnualizedPct, pos.PerpSide, cfg.BorrowAPR), 2)
+
+	if pos.AnnualCarryPct < cfg.MinAnnualCarry {
+		pos.LowCarryChecks++
+	} else {
+		pos.LowCarryChecks = 0
+	}
+	if pos.LowCarryChecks >= cfg.ExitConfirmChecks {
+		fundingCarryClose(ctx, cfg, pos, fmt.Sprintf("年化 carry %.2f%% 低于 %.2f%%", pos.AnnualCarryPct, cfg.MinAnnualCarry))
+		return
+	}
+
+	if fundingCarryRebalance(ctx, cfg, pos, spotPrice) {
+		return
+	}
+	setFundingCarry(pos)
+}
+
+// fundingCarryAccrue 累计费率收入：实盘读取 FUNDING_FEE 收入流水，模拟盘按结算时点估算
+func fundingCarryAccrue(ctx context.Context, pos *FundingCarryPosition, item FundingRateItem) {
+	if IsDryRun() {
+		if pos.NextFundingAt > pos.LastIncomeAt && time.Now().UnixMilli() >= pos.NextFundingAt {
+			pos.FundingIncome += fundingCarryAccrual(pos.PerpSide, pos.PerpQty, item.MarkPrice, pos.FundingRate)
+			pos.FundingCount++
+			pos.LastIncomeAt = pos.NextFundingAt
+		}
+		return
+	}
+
+	incomes, err := Client.NewGetIncomeHistoryService().
+		Symbol(pos.Symbol).
+		IncomeType("FUNDING_FEE").
+		StartTime(pos.LastIncomeAt + 1).
+		Limit(100).
+		Do(ctx)
+	if err != nil {
+		log.Printf("[FundingArb] Income history %s failed: %v", pos.Symbol, err)
+		return
+	}
+	for _, in := range incomes {
+		if in.Time <= pos.LastIncomeAt {
+			continue
+		}
+		v, _ := strconv.ParseFloat(in.Income, 64)
+		pos.FundingIncome += v
+		pos.FundingCount++
+		pos.LastIncomeAt = in.Time
+	}
+}
+
+// fundingCarryRebalance 两腿数量偏离（强平/ADL/手续费扣币）超过阈值时调整现货腿对齐永续腿
+// 永续腿已消失时直接平掉现货腿，返回 true
+func fundingCarryRebalance(ctx context.Context, cfg FundingArbConfig, pos *FundingCarryPosition, spotPrice float64) bool {
+	if !IsDryRun() {
+		posSide := futures.PositionSideTypeShort
+		if pos.PerpSide == "LONG" {
+			posSide = futures.PositionSideTypeLong
+		}
+		perpQty := 0.0
+		if risk, err := findPosition(ctx, pos.Symbol, posSide); err == nil {
+			perpQty = math.Abs(mustParseFloat(risk.PositionAmt))
+		} else if !strings.Contains(err.Error(), "no open position") {
+			return false
+		}
+		pos.PerpQty = perpQty
+	}
+
+	if pos.PerpQty <= 0 {
+		// 永续腿已不存在（被强平或手动平仓），只剩现货腿
+		fundingCarryClose(ctx, cfg, pos, "永续腿已消失")
+		return true
+	}
+	if fundingCarryDriftPct(pos.PerpQty, pos.SpotQty) <= cfg.RebalanceDriftPct {
+		return false
+	}
+
+	info, err := getSpotSymbolInfo(ctx, pos.Symbol)
+	if err != nil {
+		return false
+	}
+	diff := pos.PerpQty - pos.SpotQty
+	// 现货多头：少了就买、多了就卖；现货空头反之
+	side := binance.SideTypeBuy
+	if (pos.SpotSide == "BUY") != (diff > 0) {
+		side = binance.SideTypeSell
+	}
+	qty := roundToStepSize(math.Abs(diff), info.StepSize)
+	if qty <= 0 || qty < info.MinQty {
+		return false
+	}
+	// 现货空头加仓时借币卖出，减仓时买回还币
+	repay := pos.SpotSide == "SELL" && side == binance.SideTypeBuy
+	filled, _, _, err := placeSpotLeg(ctx, pos.SpotMode, pos.Symbol, side, qty, info, spotPrice, repay)
+	if err != nil {
+		log.Printf("[FundingArb] Rebalance %s failed: %v", pos.Symbol, err)
+		return false
+	}
+	if diff > 0 {
+		pos.SpotQty += filled
+	} else {
+		pos.SpotQty -= filled
+	}
+	pos.Rebalances++
+	log.Printf("[FundingArb] Rebalanced %s spot %s %.6f, perp=%.6f spot=%.6f", pos.Symbol, side, filled, pos.PerpQty, pos.SpotQty)
+	return false
+}
+
+// fundingCarryOpen 先开永续腿，再按成交数量开现货腿；现货腿失败则回滚永续腿
+func fundingCarryOpen(ctx context.Context, cfg FundingArbConfig, item FundingRateItem, perpSide string, spotPrice float64, info spotSymbolInfo) error {
+	req := PlaceOrderReq{
+		Source:        fundingCarrySource,
+		Symbol:        item.Symbol,
+		Side:          futures.SideTypeSell,
+		OrderType:     futures.OrderTypeMarket,
+		PositionSide:  futures.PositionSideTypeShort,
+		QuoteQuantity: cfg.AmountPerOrder,
+		Leverage:      cfg.Leverage,
+	}
+	spotSide := binance.SideTypeBuy
+	if perpSide == "LONG" {
+		req.Side = futures.SideTypeBuy
+		req.PositionSide = futures.PositionSideTypeLong
+		spotSide = binance.SideTypeSell
+	}
+	res, err := PlaceOrderViaWs(ctx, req)
+	if err != nil {
+		return fmt.Errorf("perp leg: %w", err)
+	}
+	margin, _ := strconv.ParseFloat(cfg.AmountPerOrder, 64)
+	perpQty, perpPrice := pairsOrderFill(res.Order, item.MarkPrice, margin*float64(cfg.Leverage))
+	perpLeg := PairsLeg{Symbol: item.Symbol, Side: perpSide, Qty: perpQty, EntryPrice: perpPrice, OrderID: res.Order.OrderID}
+
+	pos := &FundingCarryPosition{
+		Symbol:        item.Symbol,
+		PerpSide:      perpSide,
+		PerpQty:       perpQty,
+		PerpEntry:     perpPrice,
+		SpotSide:      string(spotSide),
+		SpotMode:      cfg.SpotMode,
+		FundingRate:   item.FundingRate,
+		NextFundingAt: item.NextFundingTime,
+		LastIncomeAt:  time.Now().UnixMilli(),
+		OpenedAt:      time.Now().Format(time.RFC3339),
+	}
+
+	spotQty := roundToStepSize(perpQty, info.StepSize)
+	var spotErr error
+	if spotQty <= 0 || spotQty < info.MinQty {
+		spotErr = fmt.Errorf("spot qty %.8f below min %.8f", spotQty, info.MinQty)
+	} else {
+		var filled, price float64
+		filled, price, _, spotErr = placeSpotLeg(ctx, cfg.SpotMode, item.Symbol, spotSide, spotQty, info, spotPrice, false)
+		pos.SpotQty, pos.SpotEntry = filled, price
+	}
+	if spotErr != nil {
+		log.Printf("[FundingArb] %s spot leg failed (%v), unwinding perp", item.Symbol, spotErr)
+		if _, uerr := pairsCloseLeg(ctx, perpLeg); uerr != nil {
+			// 回滚失败：登记为待平仓，下一轮继续重试
+			pos.Closing = true
+			pos.CloseReason = "unwind"
+			setFundingCarry(pos)
+			saveFundingCarry()
+			SaveFailedOperation("funding_carry_unwind", fundingCarrySource, item.Symbol, perpLeg, perpLeg.OrderID, uerr)
+			SendNotify(fmt.Sprintf("*资金费率对冲* %s 现货腿失败且回滚永续腿失败，裸露敞口，持续重试中: %v", item.Symbol, uerr))
+			return fmt.Errorf("spot leg failed: %v; unwind perp failed: %v", spotErr, uerr)
+		}
+		return fmt.Errorf("spot leg failed, perp unwound: %w", spotErr)
+	}
+
+	pos.EntryBasisPct = roundFloat(fundingCarryBasisPct(perpPrice, pos.SpotEntry), 4)
+	pos.BasisPct = pos.EntryBasisPct
+	pos.AnnualCarryPct = roundFloat(fundingCarryAPR(item.AnnualizedPct, perpSide, cfg.BorrowAPR), 2)
+	setFundingCarry(pos)
+
+	fundingArb.mu.Lock()
+	fundingArb.cooldownMap[item.Symbol] = time.Now()
+	fundingArb.totalTrades++
+	fundingArb.lastError = ""
+	fundingArb.mu.Unlock()
+	saveFundingCarry()
+
+	log.Printf("[FundingArb] Hedged open %s perp %s %.6f@%.4f / spot %s %.6f@%.4f basis=%.3f%% carry=%.1f%%",
+		item.Symbol, perpSide, perpQty, perpPrice, spotSide, pos.SpotQty, pos.SpotEntry, pos.EntryBasisPct, pos.AnnualCarryPct)
+	SendNotify(fmt.Sprintf("*资金费率对冲开仓*\n交易对: %s\n永续: %s %.6f @ %.4f\n现货: %s %.6f @ %.4f (%s)\n基差: %.3f%%\n年化 carry: %.1f%%",
+		item.Symbol, perpSide, perpQty, perpPrice, spotSide, pos.SpotQty, pos.SpotEntry, cfg.SpotMode,
+		pos.EntryBasisPct, pos.AnnualCarryPct))
+	return nil
+}
+
+// fundingCarryClose 双腿平仓；任一腿失败则保留剩余部分并标记 closing，下一轮重试
+func fundingCarryClose(ctx context.Context, cfg FundingArbConfig, pos *FundingCarryPosition, reason string) {
+	var pnl float64
+	var failErr error
+
+	if pos.PerpQty > 0 {
+		leg := PairsLeg{Symbol: pos.Symbol, Side: pos.PerpSide, Qty: pos.PerpQty, EntryPrice: pos.PerpEntry}
+		price, err := pairsCloseLeg(ctx, leg)
+		if err != nil {
+			failErr = fmt.Errorf("perp close: %w", err)
+		} else {
+			pnl += pairsLegPnl(leg, price)
+			pos.PerpQty = 0
+		}
+	}
+
+	if pos.SpotQty > 0 {
+		info, err := getSpotSymbolInfo(ctx, pos.Symbol)
+		if err != nil {
+			failErr = fmt.Errorf("spot info: %w", err)
+		} else {
+			side := binance.SideTypeSell
+			if pos.SpotSide == "SELL" {
+				side = binance.SideTypeBuy
+			}
+			qty := roundToStepSize(pos.SpotQty, info.StepSize)
+			if qty < info.MinQty || qty <= 0 {
+				// 剩余尘埃无法下单，视为已平
+				pos.SpotQty = 0
+			} else {
+				filled, price, _, err := placeSpotLeg(ctx, pos.SpotMode, pos.Symbol, side, qty, info, 0, pos.SpotSide == "SELL")
+				if err != nil {
+					failErr = fmt.Errorf("spot close: %w", err)
+				} else {
+					spotLeg := PairsLeg{Symbol: pos.Symbol, Side: "LONG", Qty: filled, EntryPrice: pos.SpotEntry}
+					if pos.SpotSide == "SELL" {
+						spotLeg.Side = "SHORT"
+					}
+					pnl += pairsLegPnl(spotLeg, price)
+					pos.SpotQty = 0
+				}
+			}
+		}
+	}
+
+	if failErr != nil {
+		pos.Closing = true
+		pos.CloseReason = reason
+		setFundingCarry(pos)
+		saveFundingCarry()
+		log.Printf("[FundingArb] Close %s incomplete (%s): %v", pos.Symbol, reason, failErr)
+		SaveFailedOperation("funding_carry_close", fundingCarrySource, pos.Symbol, pos, 0, failErr)
+		SendNotify(fmt.Sprintf("*资金费率对冲* %s 平仓未完成，下一轮重试: %v", pos.Symbol, failErr))
+		return
+	}
+
+	fundingArb.mu.Lock()
+	delete(fundingArb.carry, pos.Symbol)
+	fundingArb.carryIncome += pos.FundingIncome
+	fundingArb.cooldownMap[pos.Symbol] = time.Now()
+	fundingArb.mu.Unlock()
+	saveFundingCarry()
+
+	total := pnl + pos.FundingIncome
+	log.Printf("[FundingArb] Hedged close %s (%s): legs pnl=%.4f funding=%.4f total=%.4f",
+		pos.Symbol, reason, pnl, pos.FundingIncome, total)
+	NotifyTradeClose(pos.Symbol, "CARRY", total, fmt.Sprintf("%s，费率收入 %.4f USDT", reason, pos.FundingIncome))
+}
+
+// marginSideEffect 杠杆下单副作用：卖出时借币（MARGIN_BUY），买回还币时 AUTO_REPAY，其余不借不还
+func marginSideEffect(side binance.SideType, repay bool) binance.SideEffectType {
+	switch {
+	case side == binance.SideTypeSell && !repay:
+		return binance.SideEffectTypeMarginBuy
+	case side == binance.SideTypeBuy && repay:
+		return binance.SideEffectTypeAutoRepay
+	}
+	return binance.SideEffectTypeNoSideEffect
+}
+
+// placeSpotLeg 现货/杠杆市价单，返回扣除币本位手续费后的净数量与成交均价
+// repay=true 表示买回借币卖出的现货并自动还款
+func placeSpotLeg(ctx context.Context, mode, symbol string, side binance.SideType, qty float64, info spotSymbolInfo, refPrice float64, repay bool) (filled, price float64, orderID int64, err error) {
+	if IsDryRun() {
+		if refPrice <= 0 {
+			prices, perr := fetchSpotPrices(ctx)
+			if perr != nil {
+				return 0, 0, 0, perr
+			}
+			refPrice = prices[symbol]
+		}
+		if refPrice <= 0 {
+			return 0, 0, 0, fmt.Errorf("no spot price for %s", symbol)
+		}
+		log.Printf("[DryRun] Spot %s %s %s qty=%.8f @ %.6f", mode, symbol, side, qty, refPrice)
+		return qty, refPrice, 0, nil
+	}
+	if SpotClient == nil {
+		return 0, 0, 0, fmt.Errorf("spot client not initialized")
+	}
+
+	qtyStr := formatQuantity(qty, info.Precision)
+	var resp *binance.CreateOrderResponse
+	if mode == "margin" {
+		resp, err = SpotClient.NewCreateMarginOrderService().
+			Symbol(symbol).Side(side).Type(binance.OrderTypeMarket).
+			Quantity(qtyStr).SideEffectType(marginSideEffect(side, repay)).
+			NewOrderRespType(binance.NewOrderRespTypeFULL).
+			Do(ctx)
+	} else {
+		resp, err = SpotClient.NewCreateOrderService().
+			Symbol(symbol).Side(side).Type(binance.OrderTypeMarket).
+			Quantity(qtyStr).
+			NewOrderRespType(binance.NewOrderRespTypeFULL).
+			Do(ctx)
+	}
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	filled = mustParseFloat(resp.ExecutedQuantity)
+	quote := mustParseFloat(resp.CummulativeQuoteQuantity)
+	if filled > 0 {
+		price = quote / filled
+	}
+	if side == binance.SideTypeBuy {
+		base := strings.TrimSuffix(symbol, "USDT")
+		for _, f := range resp.Fills {
+			if f.CommissionAsset == base {
+				filled -= mustParseFloat(f.Commission)
+			}
+		}
+	}
+	return filled, price, resp.OrderID, nil
+}
+
+// fetchSpotPrices 获取全部现货最新价
+func fetchSpotPrices(ctx context.Context) (map[string]float64, error) {
+	if SpotClient == nil {
+		return nil, fmt.Errorf("spot client not initialized")
+	}
+	list, err := SpotClient.NewListPricesService().Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("spot prices: %w", err)
+	}
+	prices := make(map[string]float64, len(list))
+	for _, p := range list {
+		prices[p.Symbol] = mustParseFloat(p.Price)
+	}
+	return prices, nil
+}
+
+// getSpotSymbolInfo 读取现货交易对规则（缓存 1 小时）
+func getSpotSymbolInfo(ctx context.Context, symbol string) (spotSymbolInfo, error) {
+	spotInfoMu.RLock()
+	if spotInfoCache != nil && time.Since(spotInfoAt) < time.Hour {
+		info, ok := spotInfoCache[symbol]
+		spotInfoMu.RUnlock()
+		if !ok {
+			return spotSymbolInfo{}, fmt.Errorf("spot symbol %s not tradable", symbol)
+		}
+		return info, nil
+	}
+	spotInfoMu.RUnlock()
+
+	if SpotClient == nil {
+		return spotSymbolInfo{}, fmt.Errorf("spot client not initialized")
+	}
+	res, err := SpotClient.NewExchangeInfoService().Do(ctx)
+	if err != nil {
+		return spotSymbolInfo{}, fmt.Errorf("spot exchange info: %w", err)
+	}
+	cache := make(map[string]spotSymbolInfo, len(res.Symbols))
+	for i := range res.Symbols {
+		s := &res.Symbols[i]
+		if s.Status != "TRADING" {
+			continue
+		}
+		info := spotSymbolInfo{MarginAllowed: s.IsMarginTradingAllowed}
+		if lot := s.LotSizeFilter(); lot != nil {
+			info.StepSize = mustParseFloat(lot.StepSize)
+			info.MinQty = mustParseFloat(lot.MinQuantity)
+			info.Precision = stepPrecision(lot.StepSize)
+		}
+		cache[s.Symbol] = info
+	}
+
+	spotInfoMu.Lock()
+	spotInfoCache = cache
+	spotInfoAt = time.Now()
+	spotInfoMu.Unlock()
+
+	info, ok := cache[symbol]
+	if !ok {
+		return spotSymbolInfo{}, fmt.Errorf("spot symbol %s not tradable", symbol)
+	}
+	return info, nil
+}
+
+// stepPrecision 由 stepSize 字符串（如 "0.00100000"）推出小数位数
+func stepPrecision(step string) int {
+	step = strings.TrimRight(step, "0")
+	idx := strings.IndexByte(step, '.')
+	if idx < 0 {
+		return 0
+	}
+	return len(step) - idx - 1
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"math"
+	"testing"
+
+	"github.com/adshao/go-binance/v2"
+)
+
+func TestFundingCarryAPR(t *testing.T) {
+	// 正费率做空永续：carry 即年化费率
+	if got := fundingCarryAPR(36.5, "SHORT", 10); got != 36.5 {
+		t.Fatalf("short carry = %v, want 36.5", got)
+	}
+	// 负费率做多永续：取反并扣除借币成本
+	if got := fundingCarryAPR(-36.5, "LONG", 10); got != 26.5 {
+		t.Fatalf("long carry = %v, want 26.5", got)
+	}
+	// 费率翻转后 carry 为负
+	if got := fundingCarryAPR(-5, "SHORT", 0); got >= 0 {
+		t.Fatalf("flipped carry = %v, want negative", got)
+	}
+}
+
+func TestFundingCarryBasisAndDrift(t *testing.T) {
+	if got := fundingCarryBasisPct(101, 100); math.Abs(got-1) > 1e-9 {
+		t.Fatalf("basis = %v, want 1", got)
+	}
+	if got := fundingCarryBasisPct(101, 0); got != 0 {
+		t.Fatalf("basis with zero spot = %v, want 0", got)
+	}
+	if got := fundingCarryDriftPct(1, 0.95); math.Abs(got-5) > 1e-9 {
+		t.Fatalf("drift = %v, want 5", got)
+	}
+	if got := fundingCarryDriftPct(0, 0); got != 0 {
+		t.Fatalf("drift of empty legs = %v, want 0", got)
+	}
+}
+
+func TestFundingCarryAccrual(t *testing.T) {
+	// 空永续收正费率
+	if got := fundingCarryAccrual("SHORT", 2, 100, 0.001); math.Abs(got-0.2) > 1e-9 {
+		t.Fatalf("short accrual = %v, want 0.2", got)
+	}
+	// 多永续收负费率
+	if got := fundingCarryAccrual("LONG", 2, 100, -0.001); math.Abs(got-0.2) > 1e-9 {
+		t.Fatalf("long accrual = %v, want 0.2", got)
+	}
+}
+
+func TestNormalizeFundingCarryConfig(t *testing.T) {
+	cfg := FundingArbConfig{Hedged: true, SpotMode: "MARGIN"}
+	if err := normalizeFundingCarryConfig(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SpotMode != "margin" || cfg.MinAnnualCarry != 5 || cfg.ExitConfirmChecks != 3 || cfg.MaxCarryPositions != 3 {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+	bad := FundingArbConfig{SpotMode: "futures"}
+	if err := normalizeFundingCarryConfig(&bad); err == nil {
+		t.Fatalf("expected error for invalid spotMode")
+	}
+	if got := stepPrecision("0.00100000"); got != 3 {
+		t.Fatalf("stepPrecision = %d, want 3", got)
+	}
+	if got := stepPrecision("1.00000000"); got != 0 {
+		t.Fatalf("stepPrecision = %d, want 0", got)
+	}
+}
+
+func TestMarginSideEffect(t *testing.T) {
+	cases := []struct {
+		side  binance.SideType
+		repay bool
+		want  binance.SideEffectType
+	}{
+		{binance.SideTypeSell, false, binance.SideEffectTypeMarginBuy}, // 现货空头开仓/加仓：借币卖出
+		{binance.SideTypeBuy, true, binance.SideEffectTypeAutoRepay},   // 现货空头减仓/平仓：买回还币
+		{binance.SideTypeBuy, false, binance.SideEffectTypeNoSideEffect},
+		{binance.SideTypeSell, true, binance.SideEffectTypeNoSideEffect},
+	}
+	for _, c := range cases {
+		if got := marginSideEffect(c.side, c.repay); got != c.want {
+			t.Fatalf("side=%s repay=%v: got %s want %s", c.side, c.repay, got, c.want)
+		}
+	}
+}
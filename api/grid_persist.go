@@ -0,0 +1,305 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// gridFillHistoryCap 内存中保留的最近成交条数
+const gridFillHistoryCap = 500
+
+// GridFillRecord 网格成交记录（每层成交历史，数据库永久保存）
+type GridFillRecord struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Symbol     string    `gorm:"type:varchar(20);index" json:"symbol"`
+	Generation int       `json:"generation"` // 网格代数（移动网格每平移一次 +1）
+	Level      int       `json:"level"`
+	LevelPrice float64   `gorm:"type:numeric(36,8)" json:"levelPrice"`
+	OrderID    int64     `gorm:"index" json:"orderId"`
+	Side       string    `gorm:"type:varchar(10)" json:"side"` // BUY / SELL
+	Closing    bool      `json:"closing"`                      // 是否为平仓腿
+	Price      float64   `gorm:"type:numeric(36,8)" json:"price"`
+	Quantity   float64   `gorm:"type:numeric(36,8)" json:"quantity"`
+	Fee        float64   `gorm:"type:numeric(36,8)" json:"fee"`
+	Profit     float64   `gorm:"type:numeric(36,8)" json:"profit"` // 平仓腿的本轮净利润
+	EntryLevel int       `json:"entryLevel"`
+	EntryPrice float64   `gorm:"type:numeric(36,8)" json:"entryPrice,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// gridRuntime 网格运行时快照（层级挂单/库存 + 累计统计），随策略状态持久化
+type gridRuntime struct {
+	Generation  int                  `json:"generation"`
+	Levels      []GridLevel          `json:"levels"`
+	FilledBuys  int                  `json:"filledBuys"`
+	FilledSells int                  `json:"filledSells"`
+	RoundTrips  int                  `json:"roundTrips"`
+	TotalFees   float64              `json:"totalFees"`
+	TotalProfit float64              `json:"totalProfit"`
+	Shifts      []GridShift          `json:"shifts,omitempty"`
+	Pending     []gridPendingCounter `json:"pending,omitempty"`
+	SavedAt     time.Time            `json:"savedAt"`
+}
+
+// RecoverGrid 重启恢复网格：优先沿用持久化的层级状态，与配置不匹配时按全新网格启动
+func RecoverGrid(config GridConfig) error {
+	var rt gridRuntime
+	if !LoadStrategyRuntime("grid", config.Symbol, &rt) {
+		log.Printf("[Grid] No runtime snapshot for %s, start fresh", config.Symbol)
+		return startGrid(config, nil)
+	}
+	if !gridRuntimeMatches(config, &rt) {
+		log.Printf("[Grid] Runtime snapshot for %s does not match config, start fresh", config.Symbol)
+		return StartGrid(config)
+	}
+	rt.Levels = gridSanitizeLevels(rt.Levels)
+	log.Printf("[Grid] Recovering %s from snapshot at %s: roundTrips=%d profit=%.4f",
+		config.Symbol, rt.SavedAt.Format(time.RFC3339), rt.RoundTrips, rt.TotalProfit)
+	return startGrid(config, &rt)
+}
+
+// gridRuntimeMatches 快照的层级须与配置算出的价格一致（层数相同、首尾价格相同）
+func gridRuntimeMatches(config GridConfig, rt *gridRuntime) bool {
+	if rt == nil || len(rt.Levels) == 0 {
+		return false
+	}
+	prices := calculateGridLevels(config.LowerPrice, config.UpperPrice, config.GridCount, config.Spacing)
+	if len(prices) != len(rt.Levels) {
+		return false
+	}
+	return gridPriceClose(prices[0], rt.Levels[0].Price) &&
+		gridPriceClose(prices[len(prices)-1], rt.Levels[len(rt.Levels)-1].Price)
+}
+
+// gridSanitizeLevels 修正快照中的层级序号，并去掉仅用于展示的成交历史
+func gridSanitizeLevels(levels []GridLevel) []GridLevel {
+	out := make([]GridLevel, len(levels))
+	for i, lv := range levels {
+		lv.Index = i
+		lv.History = nil
+		out[i] = lv
+	}
+	return out
+}
+
+// applyGridRuntime 把快照写回网格状态（需持有 gridMu）
+func applyGridRuntime(state *gridState, rt *gridRuntime) {
+	state.Levels = rt.Levels
+	state.Generation = rt.Generation
+	state.FilledBuys = rt.FilledBuys
+	state.FilledSells = rt.FilledSells
+	state.RoundTrips = rt.RoundTrips
+	state.TotalFees = rt.TotalFees
+	state.TotalProfit = rt.TotalProfit
+	state.Shifts = rt.Shifts
+	state.Pending = rt.Pending
+	state.Fills = loadGridFillRecords(state.Config.Symbol, gridFillHistoryCap)
+	state.recovered = true
+}
+
+// gridFlushRuntime 运行时状态有变更时写入快照
+func gridFlushRuntime(state *gridState) {
+	gridMu.Lock()
+	if !state.dirty {
+		gridMu.Unlock()
+		return
+	}
+	state.dirty = false
+	symbol := state.Config.Symbol
+	rt := gridRuntime{
+		Generation:  state.Generation,
+		Levels:      gridSanitizeLevels(state.Levels),
+		FilledBuys:  state.FilledBuys,
+		FilledSells: state.FilledSells,
+		RoundTrips:  state.RoundTrips,
+		TotalFees:   state.TotalFees,
+		TotalProfit: state.TotalProfit,
+		Shifts:      append([]GridShift(nil), state.Shifts...),
+		Pending:     append([]gridPendingCounter(nil), state.Pending...),
+		SavedAt:     time.Now(),
+	}
+	gridMu.Unlock()
+
+	SaveStrategyRuntime("grid", symbol, rt)
+}
+
+// recordGridFillLocked 记录一笔网格成交：写入内存环形缓冲并异步落库（需持有 gridMu）
+func recordGridFillLocked(state *gridState, rec GridFillRecord) {
+	rec.Symbol = state.Config.Symbol
+	rec.Generation = state.Generation
+	rec.CreatedAt = time.Now()
+	state.Fills = append(state.Fills, rec)
+	if len(state.Fills) > gridFillHistoryCap {
+		state.Fills = state.Fills[len(state.Fills)-gridFillHistoryCap:]
+	}
+	go saveGridFillRecord(rec)
+}
+
+func saveGridFillRecord(rec GridFillRecord) {
+	if DB == nil {
+		return
+	}
+	if err := DB.Create(&rec).Error; err != nil {
+		log.Printf("[Grid] Save fill record failed for %s: %v", rec.Symbol, err)
+	}
+}
+
+// loadGridFillRecords 读取最近的网格成交（按时间升序）
+func loadGridFillRecords(symbol string, limit int) []GridFillRecord {
+	if DB == nil {
+		return nil
+	}
+	var records []GridFillRecord
+	if err := DB.Where("symbol = ?", symbol).Order("created_at DESC").Limit(limit).Find(&records).Error; err != nil {
+		log.Printf("[Grid] Load fill records failed for %s: %v", symbol, err)
+		return nil
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records
+}
+
+// gridRebuildFromExchange 恢复后与交易所对账：认领落在网格价位上的未知挂单，
+// 补齐快照之后的成交，最后核对网格隐含库存与实际持仓
+func gridRebuildFromExchange(ctx context.Context, state *gridState) {
+	cfg := gridConfigOf(state)
+
+	openOrders, err := GetOrderListViaWs(ctx, cfg.Symbol)
+	if err != nil {
+		log.Printf("[Grid] Rebuild list open orders failed for %s: %v", cfg.Symbol, err)
+	} else {
+		gridMu.Lock()
+		adopted := adoptGridOpenOrdersLocked(state, openOrders)
+		// 重启前提交在途、交易所也没有对应挂单的层：释放 clientOrderId，交由对账重挂
+		for i := range state.Levels {
+			if lv := &state.Levels[i]; lv.OrderID == 0 && lv.ClientID != "" {
+				lv.ClientID = ""
+				state.dirty = true
+			}
+		}
+		if adopted > 0 {
+			state.dirty = true
+		}
+		gridMu.Unlock()
+		if adopted > 0 {
+			log.Printf("[Grid] %s adopted %d open orders from exchange", cfg.Symbol, adopted)
+		}
+	}
+
+	// 快照之后成交/撤销的挂单由对账统一处理
+	gridReconcile(ctx, state)
+
+	gridMu.Lock()
+	implied := gridImpliedInventoryLocked(state)
+	gridMu.Unlock()
+
+	var actual float64
+	pos, err := findPosition(ctx, cfg.Symbol, cfg.PositionSide)
+	if err != nil {
+		log.Printf("[Grid] Rebuild query position failed for %s: %v", cfg.Symbol, err)
+		return
+	}
+	if pos != nil {
+		actual, _ = strconv.ParseFloat(pos.PositionAmt, 64)
+	}
+	if math.Abs(implied-actual) > math.Max(math.Abs(implied), math.Abs(actual))*0.05+1e-12 {
+		msg := fmt.Sprintf("网格 %s 恢复后库存不一致：网格记录 %.8f，实际持仓 %.8f", cfg.Symbol, implied, actual)
+		log.Printf("[Grid] %s", msg)
+		SendNotify(msg)
+	}
+}
+
+// adoptGridOpenOrdersLocked 把价格落在网格层上、但快照中未记录的挂单认领到对应层（需持有 gridMu）
+func adoptGridOpenOrdersLocked(state *gridState, orders []*futures.Order) int {
+	known := make(map[int64]bool)
+	for _, lv := range state.Levels {
+		if lv.OrderID != 0 {
+			known[lv.OrderID] = true
+		}
+	}
+
+	adopted := 0
+	for _, o := range orders {
+		if o == nil || known[o.OrderID] {
+			continue
+		}
+		// 重启前已提交、订单号尚未回写的挂单按 clientOrderId 认领
+		if idx := gridFindLevelByClientIDLocked(state, o.ClientOrderID); idx >= 0 && state.Levels[idx].OrderID == 0 {
+			state.Levels[idx].OrderID = o.OrderID
+			known[o.OrderID] = true
+			adopted++
+			continue
+		}
+		price, _ := strconv.ParseFloat(o.Price, 64)
+		idx := gridMatchLevelLocked(state, price)
+		if idx < 0 || state.Levels[idx].OrderSide != "" {
+			continue
+		}
+		side := futures.SideType(o.Side)
+		closing := o.ReduceOnly ||
+			(state.Config.Mode == GridModeLong && side == futures.SideTypeSell) ||
+			(state.Config.Mode == GridModeShort && side == futures.SideTypeBuy)
+		origQty, _ := strconv.ParseFloat(o.OrigQuantity, 64)
+		execQty, _ := strconv.ParseFloat(o.ExecutedQuantity, 64)
+
+		lv := &state.Levels[idx]
+		lv.OrderID = o.OrderID
+		lv.OrderSide = string(side)
+		lv.OrderQty = origQty - execQty
+		lv.Closing = closing
+		if closing {
+			// 平仓单的开仓层为相邻层：卖单对应下一层买入，买单对应上一层卖出
+			entry := idx - 1
+			if side == futures.SideTypeBuy {
+				entry = idx + 1
+			}
+			if entry >= 0 && entry < len(state.Levels) {
+				lv.EntryLevel = entry
+				lv.EntryPrice = state.Levels[entry].Price
+				entryLv := &state.Levels[entry]
+				entryLv.Filled = true
+				entryLv.LotQty, entryLv.LotPrice = lv.OrderQty, entryLv.Price
+			}
+		}
+		known[o.OrderID] = true
+		adopted++
+	}
+	return adopted
+}
+
+// gridMatchLevelLocked 返回与价格匹配的网格层（相对误差 1e-4 以内），找不到返回 -1
+func gridMatchLevelLocked(state *gridState, price float64) int {
+	for i, lv := range state.Levels {
+		if gridPriceClose(lv.Price, price) {
+			return i
+		}
+	}
+	return -1
+}
+
+func gridPriceClose(a, b float64) bool {
+	if a <= 0 || b <= 0 {
+		return false
+	}
+	return math.Abs(a-b)/a <= 1e-4
+}
+
+// gridImpliedInventoryLocked 由网格库存推算持仓（多为正、空为负，需持有 gridMu）
+func gridImpliedInventoryLocked(state *gridState) float64 {
+	var qty float64
+	for _, lot := range gridInventoryLotsLocked(state) {
+		if lot.CounterSide == futures.SideTypeSell {
+			qty += lot.Qty
+		} else {
+			qty -= lot.Qty
+		}
+	}
+	return qty
+}
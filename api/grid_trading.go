@@ -6,12 +6,27 @@ import (
 	"log"
 	"math"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/adshao/go-binance/v2/futures"
 )
 
+// 网格模式
+const (
+	GridModeNeutral = "neutral" // 中性：下方挂买、上方挂卖
+	GridModeLong    = "long"    // 做多：只在下方挂买，卖单仅作为平仓对手单
+	GridModeShort   = "short"   // 做空：只在上方挂卖，买单仅作为平仓对手单
+)
+
+// 网格间距
+const (
+	GridSpacingArithmetic = "arithmetic" // 等差
+	GridSpacingGeometric  = "geometric"  // 等比（百分比）
+)
+
 // GridConfig 网格交易配置
 type GridConfig struct {
 	Symbol       string                   `json:"symbol"`
@@ -23,8 +38,18 @@ type GridConfig struct {
 	GridCount     int     `json:"gridCount"`     // 网格数量
 	AmountPerGrid string  `json:"amountPerGrid"` // 每格投入金额(USDT)
 
+	Mode    string `json:"mode,omitempty"`    // neutral / long / short，默认 long
+	Spacing string `json:"spacing,omitempty"` // arithmetic / geometric，默认 arithmetic
+
 	StopLossPrice   float64 `json:"stopLossPrice,omitempty"`   // 整体止损价，可选
 	TakeProfitPrice float64 `json:"takeProfitPrice,omitempty"` // 整体止盈价，可选
+
+	// 移动网格：价格突破区间后整体平移网格，而不是停在区间外闲置
+	Trailing       bool   `json:"trailing,omitempty"`
+	TrailInventory string `json:"trailInventory,omitempty"` // carry / realize，平移时持仓的处理方式，默认 carry
+	MaxShifts      int    `json:"maxShifts,omitempty"`      // 最大平移次数，0 表示不限
+	SRBounds       bool   `json:"srBounds,omitempty"`       // 平移时参考支撑/阻力位确定新区间
+	ShiftCount     int    `json:"shiftCount,omitempty"`     // 已平移次数（随配置持久化，恢复时沿用）
 }
 
 // GridStatus 网格交易状态
@@ -34,15 +59,42 @@ type GridStatus struct {
 	GridLevels   []GridLevel `json:"gridLevels"`
 	FilledBuys   int         `json:"filledBuys"`   // 已成交买单数
 	FilledSells  int         `json:"filledSells"`  // 已成交卖单数
-	TotalProfit  float64     `json:"totalProfit"`  // 网格总利润
+	RoundTrips   int         `json:"roundTrips"`   // 已完成的买卖轮次
+	OpenOrders   int         `json:"openOrders"`   // 当前挂单数
+	PendingLots  int         `json:"pendingLots"`  // 等待对手层空出的开仓库存笔数
+	TotalFees    float64     `json:"totalFees"`    // 累计手续费
+	TotalProfit  float64     `json:"totalProfit"`  // 网格总利润（已扣手续费）
 	CurrentPrice float64     `json:"currentPrice"` // 当前价格
+	Shifts       []GridShift `json:"shifts,omitempty"`
 }
 
 // GridLevel 单个网格层级
 type GridLevel struct {
-	Price  float64 `json:"price"`
-	HasBuy bool    `json:"hasBuy"` // 是否在此价位有挂单/已买入
-	Filled bool    `json:"filled"` // 该层是否已持有
+	Index int     `json:"index"`
+	Price float64 `json:"price"`
+
+	// 当前挂单（每层最多一笔）
+	OrderID   int64   `json:"orderId,omitempty"`
+	ClientID  string  `json:"clientId,omitempty"`  // 当前挂单的 clientOrderId，orderId 回写前用于匹配回报
+	OrderSide string  `json:"orderSide,omitempty"` // BUY / SELL，为空表示空闲
+	OrderQty  float64 `json:"orderQty,omitempty"`
+	OrderFee  float64 `json:"orderFee,omitempty"` // 当前挂单已产生的手续费（部分成交累加）
+	Closing   bool    `json:"closing,omitempty"`  // 当前挂单是否为平仓对手单
+
+	// 对手单对应的开仓信息
+	EntryPrice float64 `json:"entryPrice,omitempty"`
+	EntryLevel int     `json:"entryLevel,omitempty"`
+	EntryFee   float64 `json:"entryFee,omitempty"`
+
+	Filled     bool    `json:"filled"`             // 该层开仓已成交、等待对手单平仓
+	LotQty     float64 `json:"lotQty,omitempty"`   // 该层持有的库存数量（Filled 时有效）
+	LotPrice   float64 `json:"lotPrice,omitempty"` // 库存开仓均价
+	LotFee     float64 `json:"lotFee,omitempty"`   // 库存开仓手续费
+	Profit     float64 `json:"profit"`             // 该层累计已实现利润（扣手续费）
+	Fees       float64 `json:"fees"`               // 该层累计手续费
+	RoundTrips int     `json:"roundTrips"`         // 该层完成的买卖轮次
+
+	History []GridFillRecord `json:"history,omitempty"` // 该层成交历史（仅状态查询时填充）
 }
 
 type gridState struct {
@@ -51,23 +103,65 @@ type gridState struct {
 	Levels      []GridLevel
 	FilledBuys  int
 	FilledSells int
+	RoundTrips  int
+	TotalFees   float64
 	TotalProfit float64
+	Generation  int // 网格平移后递增，用于丢弃旧网格的在途挂单
+	Shifts      []GridShift
+	Fills       []GridFillRecord     // 最近成交（内存环形缓冲，恢复时从 DB 回填）
+	Pending     []gridPendingCounter // 对手层被占用/不存在、尚未挂出平仓单的开仓库存
+	outsideTick int                  // 价格连续位于区间外的 tick 数
+	dirty       bool                 // 运行时状态有变更，待持久化
+	recovered   bool                 // 由重启恢复而来，需要与交易所挂单/持仓对账
 	stopC       chan struct{}
 }
 
+// gridOrderIntent 待提交的网格挂单
+type gridOrderIntent struct {
+	Level   int
+	Side    futures.SideType
+	Price   float64
+	Qty     float64 // 0 表示按 AmountPerGrid 换算（开仓单）
+	Closing bool
+	Gen     int
+}
+
+// gridPendingCounter 待挂出的平仓对手单：开仓已成交，但目标层暂时被占用或越界
+type gridPendingCounter struct {
+	EntryLevel int     `json:"entryLevel"`
+	Side       string  `json:"side"` // 平仓方向：SELL 平多、BUY 平空
+	Qty        float64 `json:"qty"`
+	EntryPrice float64 `json:"entryPrice"`
+	EntryFee   float64 `json:"entryFee"`
+}
+
 var (
 	gridTasks = make(map[string]*gridState)
 	gridMu    sync.Mutex
+
+	// DryRun 下网格挂单的虚拟订单号（负数，避免与交易所订单号冲突）
+	gridPaperOrderSeq atomic.Int64
 )
 
-// StartGrid 启动网格交易
+// StartGrid 启动网格交易（全新网格，清空旧的运行时状态）
 func StartGrid(config GridConfig) error {
+	if err := startGrid(config, nil); err != nil {
+		return err
+	}
+	SaveStrategyRuntime("grid", config.Symbol, nil)
+	return nil
+}
+
+func startGrid(config GridConfig, rt *gridRuntime) error {
 	if config.Symbol == "" {
 		return fmt.Errorf("symbol is required")
 	}
 	if config.UpperPrice <= config.LowerPrice {
 		return fmt.Errorf("upperPrice must be greater than lowerPrice")
 	}
+	if config.LowerPrice <= 0 {
+		return fmt.Errorf("lowerPrice must be > 0")
+	}
 	if config.GridCount < 2 || config.GridCount > 100 {
 		return fmt.Errorf("gridCount must be between 2 and 100")
 	}
@@ -78,9 +172,49 @@ func StartGrid(config GridConfig) error {
 		return fmt.Errorf("leverage must be > 0")
 	}
 
-	// 双向持仓模式下默认用 LONG（网格做多为主）
+	config.Mode = strings.ToLower(strings.TrimSpace(config.Mode))
+	switch config.Mode {
+	case "":
+		config.Mode = GridModeLong
+	case GridModeNeutral, GridModeLong, GridModeShort:
+	default:
+		return fmt.Errorf("mode must be neutral, long or short")
+	}
+	config.Spacing = strings.ToLower(strings.TrimSpace(config.Spacing))
+	switch config.Spacing {
+	case "":
+		config.Spacing = GridSpacingArithmetic
+	case GridSpacingArithmetic, GridSpacingGeometric:
+	default:
+		return fmt.Errorf("spacing must be arithmetic or geometric")
+	}
+	if config.Trailing {
+		config.TrailInventory = strings.ToLower(strings.TrimSpace(config.TrailInventory))
+		switch config.TrailInventory {
+		case "":
+			config.TrailInventory = GridTrailCarry
+		case GridTrailCarry, GridTrailRealize:
+		default:
+			return fmt.Errorf("trailInventory must be carry or realize")
+		}
+		if config.MaxShifts < 0 {
+			return fmt.Errorf("maxShifts must be >= 0")
+		}
+	}
+
+	// 持仓方向默认：做多 LONG、做空 SHORT、中性用单向持仓 BOTH
 	if config.PositionSide == "" {
-		config.PositionSide = futures.PositionSideTypeLong
+		switch config.Mode {
+		case GridModeShort:
+			config.PositionSide = futures.PositionSideTypeShort
+		case GridModeNeutral:
+			config.PositionSide = futures.PositionSideTypeBoth
+		default:
+			config.PositionSide = futures.PositionSideTypeLong
+		}
+	}
+	if config.Mode == GridModeNeutral && config.PositionSide != futures.PositionSideTypeBoth {
+		return fmt.Errorf("neutral grid requires positionSide BOTH (one-way mode)")
 	}
 
 	gridMu.Lock()
@@ -91,12 +225,10 @@ func StartGrid(config GridConfig) error {
 	}
 
 	// 计算网格层级价格
-	levels := make([]GridLevel, config.GridCount)
-	step := (config.UpperPrice - config.LowerPrice) / float64(config.GridCount-1)
-	for i := 0; i < config.GridCount; i++ {
-		levels[i] = GridLevel{
-			Price: config.LowerPrice + step*float64(i),
-		}
+	prices := calculateGridLevels(config.LowerPrice, config.UpperPrice, config.GridCount, config.Spacing)
+	levels := make([]GridLevel, len(prices))
+	for i, p := range prices {
+		levels[i] = GridLevel{Index: i, Price: p}
 	}
 
 	state := &gridState{
@@ -105,32 +237,40 @@ func StartGrid(config GridConfig) error {
 		Levels: levels,
 		stopC:  make(chan struct{}),
 	}
+	if rt != nil {
+		applyGridRuntime(state, rt)
+	}
 	gridTasks[config.Symbol] = state
 
 	go gridMonitorLoop(state)
 
-	log.Printf("[Grid] Started for %s: range=[%.2f, %.2f], grids=%d, perGrid=%s USDT",
-		config.Symbol, config.LowerPrice, config.UpperPrice, config.GridCount, config.AmountPerGrid)
+	log.Printf("[Grid] Started for %s: mode=%s spacing=%s range=[%.4f, %.4f], grids=%d, perGrid=%s USDT",
+		config.Symbol, config.Mode, config.Spacing, config.LowerPrice, config.UpperPrice, config.GridCount, config.AmountPerGrid)
 
 	SaveStrategyState("grid", config.Symbol, config)
 	return nil
 }
 
-// StopGrid 停止网格交易
+// StopGrid 停止网格交易并撤销所有挂单
 func StopGrid(symbol string) error {
 	gridMu.Lock()
-	defer gridMu.Unlock()
-
 	state, ok := gridTasks[symbol]
 	if !ok || !state.Active {
+		gridMu.Unlock()
 		return fmt.Errorf("no active grid task for %s", symbol)
 	}
 
 	close(state.stopC)
 	state.Active = false
-	log.Printf("[Grid] Stopped for %s: buys=%d, sells=%d, profit=%.4f",
-		symbol, state.FilledBuys, state.FilledSells, state.TotalProfit)
+	orderIDs := gridCollectOrderIDsLocked(state)
+	log.Printf("[Grid] Stopped for %s: buys=%d, sells=%d, roundTrips=%d, profit=%.4f",
+		symbol, state.FilledBuys, state.FilledSells, state.RoundTrips, state.TotalProfit)
+	state.dirty = true
+	gridMu.Unlock()
+
+	gridFlushRuntime(state)
 
+	gridCancelOrders(context.Background(), symbol, orderIDs)
 	MarkStrategyStopped("grid", symbol)
 	return nil
 }
@@ -153,47 +293,163 @@ func GetGridStatus(symbol string) *GridStatus {
 		currentPrice = price
 	}
 
+	openOrders := 0
+	for _, lv := range state.Levels {
+		if lv.OrderID != 0 {
+			openOrders++
+		}
+	}
+
+	levels := append([]GridLevel(nil), state.Levels...)
+	for _, f := range state.Fills {
+		if f.Generation == state.Generation && f.Level >= 0 && f.Level < len(levels) {
+			levels[f.Level].History = append(levels[f.Level].History, f)
+		}
+	}
+
 	return &GridStatus{
 		Config:       state.Config,
 		Active:       state.Active,
-		GridLevels:   state.Levels,
+		GridLevels:   levels,
 		FilledBuys:   state.FilledBuys,
 		FilledSells:  state.FilledSells,
-		TotalProfit:  state.TotalProfit,
+		RoundTrips:   state.RoundTrips,
+		OpenOrders:   openOrders,
+		PendingLots:  len(state.Pending),
+		TotalFees:    roundFloat(state.TotalFees, 8),
+		TotalProfit:  roundFloat(state.TotalProfit, 8),
 		CurrentPrice: currentPrice,
+		Shifts:       append([]GridShift(nil), state.Shifts...),
 	}
 }
 
-// gridMonitorLoop 网格交易监控循环
+// gridMonitorLoop 网格交易监控循环：铺设初始挂单，之后负责止盈止损、对账和 DryRun 撮合
 func gridMonitorLoop(state *gridState) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
-	cfg := state.Config
+	cfg := gridConfigOf(state)
 	ctx := context.Background()
 
 	// 设置杠杆
-	if _, err := ChangeLeverage(ctx, cfg.Symbol, cfg.Leverage); err != nil {
-		log.Printf("[Grid] Warning: set leverage failed: %v", err)
+	if !IsDryRun() {
+		if _, err := ChangeLeverage(ctx, cfg.Symbol, cfg.Leverage); err != nil {
+			log.Printf("[Grid] Warning: set leverage failed: %v", err)
+		}
+	}
+
+	gridMu.Lock()
+	recovered := state.recovered
+	gridMu.Unlock()
+	if recovered && !IsDryRun() {
+		gridRebuildFromExchange(ctx, state)
 	}
+	gridPlaceInitialOrders(ctx, state)
 
 	log.Printf("[Grid] Monitor started for %s", cfg.Symbol)
 
+	tick := 0
 	for {
 		select {
 		case <-state.stopC:
 			log.Printf("[Grid] Monitor stopped for %s", cfg.Symbol)
 			return
 		case <-ticker.C:
-			gridTick(ctx, state)
+			gridFlushRuntime(state)
+			tick++
+			gridTick(ctx, state, tick%15 == 0)
 		}
 	}
 }
 
-// gridTick 每个 tick 检查价格并决定买卖
-func gridTick(ctx context.Context, state *gridState) {
+// gridPlaceInitialOrders 按当前价格铺设初始限价挂单
+func gridPlaceInitialOrders(ctx context.Context, state *gridState) {
+	cfg := gridConfigOf(state)
+
+	var currentPrice float64
+	for attempt := 0; attempt < 10; attempt++ {
+		p, err := GetPriceCache().GetPrice(cfg.Symbol)
+		if err == nil && p > 0 {
+			currentPrice = p
+			break
+		}
+		select {
+		case <-state.stopC:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+	if currentPrice <= 0 {
+		p, err := getCurrentPrice(ctx, cfg.Symbol, "")
+		if err != nil {
+			log.Printf("[Grid] Cannot get price for %s, initial orders not placed: %v", cfg.Symbol, err)
+			return
+		}
+		currentPrice = p
+	}
+
+	gridMu.Lock()
+	intents := planGridInitialOrdersLocked(state, currentPrice)
+	gridMu.Unlock()
+
+	for _, intent := range intents {
+		select {
+		case <-state.stopC:
+			return
+		default:
+		}
+		gridSubmitIntent(ctx, state, intent)
+	}
+	log.Printf("[Grid] Initial ladder for %s at %.4f: %d orders", cfg.Symbol, currentPrice, len(intents))
+}
+
+// planGridInitialOrdersLocked 计算初始挂单并占用对应层级（需持有 gridMu）
+// 中性网格在最接近现价的层级留空，作为第一笔对手单的位置
+func planGridInitialOrdersLocked(state *gridState, currentPrice float64) []gridOrderIntent {
 	cfg := state.Config
 
+	skip := -1
+	if cfg.Mode == GridModeNeutral {
+		best := math.MaxFloat64
+		for i, lv := range state.Levels {
+			if d := math.Abs(lv.Price - currentPrice); d < best {
+				best = d
+				skip = i
+			}
+		}
+	}
+
+	var intents []gridOrderIntent
+	for i := range state.Levels {
+		lv := &state.Levels[i]
+		if i == skip || lv.OrderSide != "" || lv.Filled {
+			continue
+		}
+		var side futures.SideType
+		switch {
+		case lv.Price < currentPrice && cfg.Mode != GridModeShort:
+			side = futures.SideTypeBuy
+		case lv.Price > currentPrice && cfg.Mode != GridModeLong:
+			side = futures.SideTypeSell
+		default:
+			continue
+		}
+		// 最外层没有相邻层可挂平仓对手单，不在该层开仓
+		if (side == futures.SideTypeBuy && i == len(state.Levels)-1) || (side == futures.SideTypeSell && i == 0) {
+			continue
+		}
+		lv.OrderSide = string(side)
+		lv.Closing = false
+		state.dirty = true
+		intents = append(intents, gridOrderIntent{Level: i, Side: side, Price: lv.Price, Gen: state.Generation})
+	}
+	return intents
+}
+
+// gridTick 每个 tick 检查止盈止损；DryRun 下按价格模拟撮合，实盘下定期对账
+func gridTick(ctx context.Context, state *gridState, reconcile bool) {
+	cfg := gridConfigOf(state)
+
 	// 获取当前价格
 	cache := GetPriceCache()
 	currentPrice, err := cache.GetPrice(cfg.Symbol)
@@ -201,142 +457,572 @@ func gridTick(ctx context.Context, state *gridState) {
 		return
 	}
 
-	// 止损/止盈检查
-	if cfg.StopLossPrice > 0 && currentPrice <= cfg.StopLossPrice {
+	// 止损/止盈检查（做空网格方向相反）
+	stopHit, takeHit := false, false
+	if cfg.Mode == GridModeShort {
+		stopHit = cfg.StopLossPrice > 0 && currentPrice >= cfg.StopLossPrice
+		takeHit = cfg.TakeProfitPrice > 0 && currentPrice <= cfg.TakeProfitPrice
+	} else {
+		stopHit = cfg.StopLossPrice > 0 && currentPrice <= cfg.StopLossPrice
+		takeHit = cfg.TakeProfitPrice > 0 && currentPrice >= cfg.TakeProfitPrice
+	}
+	if stopHit {
 		log.Printf("[Grid] Stop loss triggered for %s at %.4f", cfg.Symbol, currentPrice)
 		gridCloseAll(ctx, state)
 		return
 	}
-	if cfg.TakeProfitPrice > 0 && currentPrice >= cfg.TakeProfitPrice {
+	if takeHit {
 		log.Printf("[Grid] Take profit triggered for %s at %.4f", cfg.Symbol, currentPrice)
 		gridCloseAll(ctx, state)
 		return
 	}
 
-	// 找到当前价格所在的层级
-	for i := range state.Levels {
-		level := &state.Levels[i]
-
-		if level.Filled {
-			// 已持有：如果价格涨到上一格 → 卖出
-			if i < len(state.Levels)-1 {
-				nextPrice := state.Levels[i+1].Price
-				if currentPrice >= nextPrice {
-					// 卖出（平多 / 开空）
-					err := gridSellAtLevel(ctx, state, i)
-					if err != nil {
-						log.Printf("[Grid] Sell at level %d (%.2f) failed: %v", i, level.Price, err)
-					}
-				}
-			}
-		} else {
-			// 未持有：如果价格跌到该层 → 买入
-			if currentPrice <= level.Price && currentPrice >= cfg.LowerPrice {
-				// 不重复买：检查上方没有未持有层级先于当前层买入
-				err := gridBuyAtLevel(ctx, state, i)
-				if err != nil {
-					log.Printf("[Grid] Buy at level %d (%.2f) failed: %v", i, level.Price, err)
-				}
+	if cfg.Trailing && gridCheckTrailing(ctx, state, currentPrice) {
+		return
+	}
+
+	if IsDryRun() {
+		gridSimulateFills(ctx, state, currentPrice)
+		return
+	}
+	if reconcile {
+		gridReconcile(ctx, state)
+	}
+}
+
+// handleGridOrderUpdate 处理 User Data Stream 中属于网格挂单的订单更新
+func handleGridOrderUpdate(update futures.WsOrderTradeUpdate) {
+	symbol := strings.ToUpper(strings.TrimSpace(update.Symbol))
+	if symbol == "" || update.ID == 0 {
+		return
+	}
+
+	gridMu.Lock()
+	state, ok := gridTasks[symbol]
+	if !ok || !state.Active {
+		gridMu.Unlock()
+		return
+	}
+	intents := applyGridOrderUpdateLocked(state, update)
+	gridMu.Unlock()
+
+	for _, intent := range intents {
+		go gridSubmitIntent(context.Background(), state, intent)
+	}
+}
+
+// applyGridOrderUpdateLocked 按订单号（或尚未回写订单号时按 clientOrderId）定位网格层并应用订单回报（需持有 gridMu）
+func applyGridOrderUpdateLocked(state *gridState, update futures.WsOrderTradeUpdate) []gridOrderIntent {
+	idx := gridFindLevelByOrderLocked(state, update.ID)
+	if idx < 0 {
+		// 回报先于下单响应到达
+		idx = gridFindLevelByClientIDLocked(state, update.ClientOrderID)
+		if idx < 0 {
+			return nil
+		}
+		state.Levels[idx].OrderID = update.ID
+		state.dirty = true
+	}
+
+	lv := &state.Levels[idx]
+	if update.ExecutionType == futures.OrderExecutionTypeTrade && strings.EqualFold(update.CommissionAsset, "USDT") {
+		lv.OrderFee += parseNumeric(update.Commission)
+	}
+
+	price := parseNumeric(update.AveragePrice)
+	if price <= 0 {
+		price = parseNumeric(update.LastFilledPrice)
+	}
+	switch update.Status {
+	case futures.OrderStatusTypeFilled:
+		return applyGridFillLocked(state, idx, price, parseNumeric(update.AccumulatedFilledQty), lv.OrderFee)
+	case futures.OrderStatusTypeCanceled, futures.OrderStatusTypeExpired, futures.OrderStatusTypeRejected:
+		// 部分成交后被撤：已成交部分按成交处理（开仓形成库存并挂对手单，平仓结算已成交部分）
+		if filled := parseNumeric(update.AccumulatedFilledQty); filled > 0 {
+			log.Printf("[Grid] Order %d at level %d (%s) ended with %s after partial fill %.8f/%.8f",
+				update.ID, idx, state.Config.Symbol, update.Status, filled, lv.OrderQty)
+			if lv.Closing && lv.OrderQty > filled {
+				return applyGridPartialCloseLocked(state, idx, price, filled, lv.OrderFee)
 			}
+			return applyGridFillLocked(state, idx, price, filled, lv.OrderFee)
 		}
+		// 非网格主动撤单（例如手动撤单）：释放该层，待对账时补挂
+		log.Printf("[Grid] Order %d at level %d (%s) ended with %s, slot will be re-armed",
+			update.ID, idx, state.Config.Symbol, update.Status)
+		lv.OrderID = 0
+		lv.ClientID = ""
+		lv.OrderFee = 0
+		state.dirty = true
 	}
+	return nil
 }
 
-// gridBuyAtLevel 在指定层级买入
-func gridBuyAtLevel(ctx context.Context, state *gridState, levelIdx int) error {
+// applyGridFillLocked 记录某层挂单成交，更新逐层盈亏，并返回需要挂出的对手单（需持有 gridMu）
+// 规则：买单在第 i 层成交 → 第 i+1 层挂卖；卖单在第 i 层成交 → 第 i-1 层挂买。
+// 开仓单的对手单为平仓单，平仓单的对手单为新的开仓单（回到原开仓层）。
+// 对手层被占用时平仓单进入待挂队列，任何一层释放后优先补挂。
+func applyGridFillLocked(state *gridState, idx int, fillPrice, fillQty, fee float64) []gridOrderIntent {
+	if idx < 0 || idx >= len(state.Levels) {
+		return nil
+	}
+	lv := &state.Levels[idx]
+	side := futures.SideType(lv.OrderSide)
+	if fillPrice <= 0 {
+		fillPrice = lv.Price
+	}
+	if fillQty <= 0 {
+		fillQty = lv.OrderQty
+	}
+
+	closing := lv.Closing
+	entryPrice, entryLevel, entryFee := lv.EntryPrice, lv.EntryLevel, lv.EntryFee
+
+	orderID := lv.OrderID
+	lv.OrderID = 0
+	lv.ClientID = ""
+	lv.OrderSide = ""
+	lv.OrderQty = 0
+	lv.OrderFee = 0
+	lv.Closing = false
+	lv.EntryPrice = 0
+	lv.EntryLevel = 0
+	lv.EntryFee = 0
+	state.dirty = true
+
+	if side == futures.SideTypeBuy {
+		state.FilledBuys++
+	} else {
+		state.FilledSells++
+	}
+	lv.Fees += fee
+	state.TotalFees += fee
+
 	cfg := state.Config
-	level := &state.Levels[levelIdx]
+	if closing {
+		// 平仓腿：结算该轮利润，归属到开仓层
+		gross := (fillPrice - entryPrice) * fillQty
+		if side == futures.SideTypeBuy {
+			gross = (entryPrice - fillPrice) * fillQty
+		}
+		profit := gross - entryFee - fee
+		state.TotalProfit += profit
+		state.RoundTrips++
+		recordGridFillLocked(state, GridFillRecord{
+			Level: idx, LevelPrice: lv.Price, OrderID: orderID, Side: string(side), Closing: true,
+			Price: fillPrice, Quantity: fillQty, Fee: fee, Profit: profit, EntryLevel: entryLevel, EntryPrice: entryPrice,
+		})
+		if entryLevel >= 0 && entryLevel < len(state.Levels) {
+			origin := &state.Levels[entryLevel]
+			origin.Profit += profit
+			origin.RoundTrips++
+			origin.Filled = false
+			origin.LotQty, origin.LotPrice, origin.LotFee = 0, 0, 0
+		}
+		log.Printf("[Grid] %s %s closed at level %d (%.4f→%.4f) qty=%.8f profit=%.4f USDT",
+			cfg.Symbol, side, idx, entryPrice, fillPrice, fillQty, profit)
 
-	// 风控检查
-	if err := CheckRisk(); err != nil {
-		return err
+		// 本层已释放：先补挂待挂的平仓单，再回到开仓层重新挂开仓单
+		intents := gridDrainPendingLocked(state)
+		if entryLevel < 0 || entryLevel >= len(state.Levels) {
+			return intents
+		}
+		target := &state.Levels[entryLevel]
+		if target.OrderSide != "" {
+			log.Printf("[Grid] %s level %d already occupied, skip re-arm", cfg.Symbol, entryLevel)
+			return intents
+		}
+		reSide := futures.SideTypeBuy
+		if side == futures.SideTypeBuy {
+			reSide = futures.SideTypeSell
+		}
+		target.OrderSide = string(reSide)
+		return append(intents, gridOrderIntent{Level: entryLevel, Side: reSide, Price: target.Price, Gen: state.Generation})
 	}
 
-	positionSide := cfg.PositionSide
-	if positionSide == "" {
-		positionSide = futures.PositionSideTypeBoth
+	// 开仓腿：该层持有库存，在相邻层挂平仓对手单
+	lv.Filled = true
+	lv.LotQty, lv.LotPrice, lv.LotFee = fillQty, fillPrice, fee
+	recordGridFillLocked(state, GridFillRecord{
+		Level: idx, LevelPrice: lv.Price, OrderID: orderID, Side: string(side),
+		Price: fillPrice, Quantity: fillQty, Fee: fee, EntryLevel: idx,
+	})
+	counterSide := futures.SideTypeSell
+	if side == futures.SideTypeSell {
+		counterSide = futures.SideTypeBuy
 	}
+	log.Printf("[Grid] %s %s opened at level %d price=%.4f qty=%.8f", cfg.Symbol, side, idx, fillPrice, fillQty)
 
-	req := PlaceOrderReq{
-		Source:        "strategy_grid_buy",
-		Symbol:        cfg.Symbol,
-		Side:          futures.SideTypeBuy,
-		OrderType:     futures.OrderTypeMarket,
-		PositionSide:  positionSide,
-		QuoteQuantity: cfg.AmountPerGrid,
-		Leverage:      cfg.Leverage,
+	state.Pending = append(state.Pending, gridPendingCounter{
+		EntryLevel: idx, Side: string(counterSide), Qty: fillQty, EntryPrice: fillPrice, EntryFee: fee,
+	})
+	intents := gridDrainPendingLocked(state)
+	if len(state.Pending) > 0 && state.Pending[len(state.Pending)-1].EntryLevel == idx {
+		log.Printf("[Grid] %s counter for level %d not placeable yet, queued (%d pending)", cfg.Symbol, idx, len(state.Pending))
 	}
+	return intents
+}
 
-	result, err := PlaceOrderViaWs(ctx, req)
-	if err != nil {
-		return err
+// applyGridPartialCloseLocked 平仓单部分成交后被撤：结算已成交部分的利润（开仓手续费按比例分摊），
+// 剩余库存留在开仓层并重新进入待挂队列（需持有 gridMu）
+func applyGridPartialCloseLocked(state *gridState, idx int, fillPrice, fillQty, fee float64) []gridOrderIntent {
+	lv := &state.Levels[idx]
+	side := futures.SideType(lv.OrderSide)
+	if fillPrice <= 0 {
+		fillPrice = lv.Price
 	}
+	remaining := lv.OrderQty - fillQty
+	entryPrice, entryLevel := lv.EntryPrice, lv.EntryLevel
+	settledFee := lv.EntryFee * fillQty / lv.OrderQty
+	restFee := lv.EntryFee - settledFee
 
-	gridMu.Lock()
-	level.Filled = true
-	level.HasBuy = true
-	state.FilledBuys++
-	gridMu.Unlock()
+	orderID := lv.OrderID
+	lv.OrderID = 0
+	lv.ClientID = ""
+	lv.OrderSide = ""
+	lv.OrderQty = 0
+	lv.OrderFee = 0
+	lv.Closing = false
+	lv.EntryPrice = 0
+	lv.EntryLevel = 0
+	lv.EntryFee = 0
+	state.dirty = true
 
-	log.Printf("[Grid] BUY at level %d (%.2f): orderId=%d, %s USDT",
-		levelIdx, level.Price, result.Order.OrderID, cfg.AmountPerGrid)
+	if side == futures.SideTypeBuy {
+		state.FilledBuys++
+	} else {
+		state.FilledSells++
+	}
+	lv.Fees += fee
+	state.TotalFees += fee
 
-	return nil
+	gross := (fillPrice - entryPrice) * fillQty
+	if side == futures.SideTypeBuy {
+		gross = (entryPrice - fillPrice) * fillQty
+	}
+	profit := gross - settledFee - fee
+	state.TotalProfit += profit
+	recordGridFillLocked(state, GridFillRecord{
+		Level: idx, LevelPrice: lv.Price, OrderID: orderID, Side: string(side), Closing: true,
+		Price: fillPrice, Quantity: fillQty, Fee: fee, Profit: profit, EntryLevel: entryLevel, EntryPrice: entryPrice,
+	})
+	if entryLevel >= 0 && entryLevel < len(state.Levels) {
+		origin := &state.Levels[entryLevel]
+		origin.Profit += profit
+		origin.LotQty, origin.LotFee = remaining, restFee
+	}
+	log.Printf("[Grid] %s %s partially closed at level %d (%.4f→%.4f) qty=%.8f profit=%.4f USDT, %.8f requeued",
+		state.Config.Symbol, side, idx, entryPrice, fillPrice, fillQty, profit, remaining)
+
+	state.Pending = append(state.Pending, gridPendingCounter{
+		EntryLevel: entryLevel, Side: string(side), Qty: remaining, EntryPrice: entryPrice, EntryFee: restFee,
+	})
+	return gridDrainPendingLocked(state)
+}
+
+// gridDrainPendingLocked 为待挂队列中目标层已空闲的库存挂出平仓对手单（需持有 gridMu）
+// 目标层越界（最外层成交，例如恢复时认领的挂单）时保留在队列中，计入库存，由平移或停止时处理
+func gridDrainPendingLocked(state *gridState) []gridOrderIntent {
+	var intents []gridOrderIntent
+	kept := state.Pending[:0]
+	for _, p := range state.Pending {
+		next := p.EntryLevel + 1
+		if futures.SideType(p.Side) == futures.SideTypeBuy {
+			next = p.EntryLevel - 1
+		}
+		if next < 0 || next >= len(state.Levels) || state.Levels[next].OrderSide != "" {
+			kept = append(kept, p)
+			continue
+		}
+		target := &state.Levels[next]
+		target.OrderSide = p.Side
+		target.Closing = true
+		target.EntryPrice = p.EntryPrice
+		target.EntryLevel = p.EntryLevel
+		target.EntryFee = p.EntryFee
+		target.OrderQty = p.Qty
+		intents = append(intents, gridOrderIntent{
+			Level: next, Side: futures.SideType(p.Side), Price: target.Price, Qty: p.Qty, Closing: true, Gen: state.Generation,
+		})
+	}
+	if len(kept) != len(state.Pending) {
+		state.dirty = true
+	}
+	state.Pending = kept
+	return intents
 }
 
-// gridSellAtLevel 在指定层级卖出
-func gridSellAtLevel(ctx context.Context, state *gridState, levelIdx int) error {
+// gridSubmitIntent 提交网格挂单，成功后把订单号写回对应层级；失败则保留占位，由对账流程重试。
+// 下单前先在层上登记 clientOrderId，成交回报早于下单响应到达时按它匹配，不会丢失。
+func gridSubmitIntent(ctx context.Context, state *gridState, intent gridOrderIntent) {
+	gridMu.Lock()
 	cfg := state.Config
-	level := &state.Levels[levelIdx]
+	ok := state.Active && state.Generation == intent.Gen && intent.Level < len(state.Levels)
+	var clientID string
+	if ok {
+		lv := &state.Levels[intent.Level]
+		// 该层已有挂单或另一笔提交在途
+		ok = lv.OrderSide == string(intent.Side) && lv.OrderID == 0 && lv.ClientID == ""
+		if ok {
+			clientID = gridClientOrderID(intent.Gen, intent.Level)
+			lv.ClientID = clientID
+		}
+	}
+	gridMu.Unlock()
+	if !ok {
+		return
+	}
 
-	positionSide := cfg.PositionSide
-	if positionSide == "" {
-		positionSide = futures.PositionSideTypeBoth
+	orderID, qty, err := gridPlaceLimitOrder(ctx, cfg, intent, clientID)
+	if err != nil {
+		log.Printf("[Grid] Place %s at level %d (%.4f) for %s failed: %v",
+			intent.Side, intent.Level, intent.Price, cfg.Symbol, err)
+		gridMu.Lock()
+		if intent.Level < len(state.Levels) && state.Levels[intent.Level].ClientID == clientID {
+			state.Levels[intent.Level].ClientID = ""
+		}
+		gridMu.Unlock()
+		return
+	}
+
+	gridMu.Lock()
+	stale := !state.Active || state.Generation != intent.Gen || intent.Level >= len(state.Levels)
+	handled := false
+	if !stale {
+		lv := &state.Levels[intent.Level]
+		if lv.ClientID != clientID {
+			// 成交回报已按 clientOrderId 处理完该单
+			handled = true
+		} else {
+			if lv.OrderID == 0 {
+				lv.OrderID = orderID
+			}
+			lv.OrderQty = qty
+			state.dirty = true
+		}
 	}
+	gridMu.Unlock()
+
+	if stale {
+		// 网格已停止或已平移，撤掉刚挂出的单
+		gridCancelOrders(ctx, cfg.Symbol, []int64{orderID})
+		return
+	}
+	if handled {
+		log.Printf("[Grid] %s order %d at level %d already settled by user stream", cfg.Symbol, orderID, intent.Level)
+		return
+	}
+	log.Printf("[Grid] %s %s LIMIT at level %d price=%.4f qty=%.8f closing=%v orderId=%d",
+		cfg.Symbol, intent.Side, intent.Level, intent.Price, qty, intent.Closing, orderID)
+}
 
-	// 卖出（平仓）同样金额
+// gridPlaceLimitOrder 挂出一笔网格限价单，返回订单号和数量
+// 开仓单走 PlaceOrderViaWs（按 AmountPerGrid 换算数量）；平仓对手单按开仓成交数量直接挂单
+func gridPlaceLimitOrder(ctx context.Context, cfg GridConfig, intent gridOrderIntent, clientID string) (int64, float64, error) {
+	priceStr := strconv.FormatFloat(intent.Price, 'f', -1, 64)
 	req := PlaceOrderReq{
-		Source:        "strategy_grid_sell",
+		Source:        "strategy_grid",
+		ClientOrderID: clientID,
 		Symbol:        cfg.Symbol,
-		Side:          futures.SideTypeSell,
-		OrderType:     futures.OrderTypeMarket,
-		PositionSide:  positionSide,
+		Side:          intent.Side,
+		OrderType:     futures.OrderTypeLimit,
+		Price:         priceStr,
+		PositionSide:  cfg.PositionSide,
+		TimeInForce:   futures.TimeInForceTypeGTC,
 		QuoteQuantity: cfg.AmountPerGrid,
 		Leverage:      cfg.Leverage,
 	}
 
-	result, err := PlaceOrderViaWs(ctx, req)
+	if IsDryRun() {
+		qty := intent.Qty
+		if qty <= 0 {
+			amt, _ := strconv.ParseFloat(cfg.AmountPerGrid, 64)
+			qty = amt * float64(cfg.Leverage) / intent.Price
+		}
+		return -gridPaperOrderSeq.Add(1), qty, nil
+	}
+
+	if !intent.Closing {
+		result, err := PlaceOrderViaWs(ctx, req)
+		if err != nil {
+			return 0, 0, err
+		}
+		return result.Order.OrderID, parseNumeric(result.Order.OrigQuantity), nil
+	}
+
+	normalizedPrice, err := normalizePriceForSymbol(ctx, cfg.Symbol, intent.Price)
 	if err != nil {
-		return err
+		return 0, 0, fmt.Errorf("normalize price: %w", err)
+	}
+	req.Price = normalizedPrice
+	precision, stepSize, err := getSymbolPrecision(ctx, cfg.Symbol)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get symbol precision: %w", err)
 	}
+	qty := roundToStepSize(intent.Qty, stepSize)
+	if qty <= 0 {
+		return 0, 0, fmt.Errorf("counter quantity too small: %.10f", intent.Qty)
+	}
+	quantity := formatQuantity(qty, precision)
+	// 单向持仓模式下平仓腿使用 reduceOnly，双向持仓由 positionSide 区分
+	req.ReduceOnly = cfg.PositionSide == futures.PositionSideTypeBoth
 
-	// 计算单格利润
-	nextPrice := state.Levels[levelIdx+1].Price
-	gridStep := nextPrice - level.Price
-	amtPerGrid, _ := strconv.ParseFloat(cfg.AmountPerGrid, 64)
-	profitEstimate := amtPerGrid * float64(cfg.Leverage) * gridStep / level.Price
+	var order *futures.CreateOrderResponse
+	if wsClient := GetWsClient(); wsClient != nil {
+		order, err = wsPlaceOrder(wsClient, req, quantity)
+		if err != nil {
+			log.Printf("[Grid] Counter order via WebSocket failed: %v, falling back to REST API", err)
+			go ReconnectWsClient()
+			order = nil
+		}
+	}
+	if order == nil {
+		order, err = restPlaceOrder(ctx, req, quantity)
+		if err != nil {
+			SaveFailedOperation("PLACE_ORDER", req.Source, req.Symbol, req, 0, err)
+			return 0, 0, err
+		}
+	}
+	return order.OrderID, qty, nil
+}
+
+// gridSimulateFills DryRun 模式下按最新价撮合虚拟挂单
+func gridSimulateFills(ctx context.Context, state *gridState, currentPrice float64) {
+	type paperFill struct {
+		side futures.SideType
+		qty  float64
+	}
+	var fills []paperFill
+	var intents []gridOrderIntent
 
 	gridMu.Lock()
-	level.Filled = false
-	level.HasBuy = false
-	state.FilledSells++
-	state.TotalProfit += profitEstimate
+	if !state.Active {
+		gridMu.Unlock()
+		return
+	}
+	for i := range state.Levels {
+		lv := &state.Levels[i]
+		if lv.OrderID == 0 {
+			continue
+		}
+		side := futures.SideType(lv.OrderSide)
+		if (side == futures.SideTypeBuy && currentPrice > lv.Price) ||
+			(side == futures.SideTypeSell && currentPrice < lv.Price) {
+			continue
+		}
+		qty := lv.OrderQty
+		fills = append(fills, paperFill{side: side, qty: qty})
+		intents = append(intents, applyGridFillLocked(state, i, lv.Price, qty, calcFee(lv.Price, qty))...)
+	}
+	cfg := state.Config
 	gridMu.Unlock()
 
-	log.Printf("[Grid] SELL at level %d (%.2f→%.2f): orderId=%d, profit≈%.4f USDT",
-		levelIdx, level.Price, nextPrice, result.Order.OrderID, profitEstimate)
+	for _, f := range fills {
+		gridPaperFill(cfg, f.side, f.qty)
+	}
+	for _, intent := range intents {
+		gridSubmitIntent(ctx, state, intent)
+	}
+}
 
-	return nil
+// gridPaperFill 把网格成交同步到模拟账户：与现有持仓同向则加仓，反向则减仓
+func gridPaperFill(cfg GridConfig, side futures.SideType, qty float64) {
+	posSide := "LONG"
+	if side == futures.SideTypeSell {
+		posSide = "SHORT"
+	}
+	for _, pos := range GetPaperPositions() {
+		if pos.Symbol == cfg.Symbol && pos.Side != posSide {
+			if _, err := PaperReducePosition(cfg.Symbol, pos.Side, math.Min(qty, pos.Quantity), 0, "grid"); err != nil {
+				log.Printf("[Grid] Paper reduce failed: %v", err)
+			}
+			return
+		}
+	}
+	if _, err := PaperPlaceOrder(cfg.Symbol, posSide, qty, 0, cfg.Leverage, "grid"); err != nil {
+		log.Printf("[Grid] Paper order failed: %v", err)
+	}
 }
 
-// gridCloseAll 网格止损/止盈 → 平掉所有仓位并停止
+// gridReconcile 实盘对账：补挂失败/被撤的挂单，并处理 User Data Stream 断线期间漏掉的成交
+func gridReconcile(ctx context.Context, state *gridState) {
+	cfg := gridConfigOf(state)
+
+	openOrders, err := GetOrderListViaWs(ctx, cfg.Symbol)
+	if err != nil {
+		log.Printf("[Grid] Reconcile list open orders failed for %s: %v", cfg.Symbol, err)
+		return
+	}
+	openSet := make(map[int64]bool, len(openOrders))
+	for _, o := range openOrders {
+		openSet[o.OrderID] = true
+	}
+
+	var missing []int64
+	var retry []gridOrderIntent
+	gridMu.Lock()
+	if !state.Active {
+		gridMu.Unlock()
+		return
+	}
+	for i := range state.Levels {
+		lv := &state.Levels[i]
+		if lv.OrderSide == "" {
+			continue
+		}
+		if lv.OrderID == 0 {
+			if lv.ClientID != "" {
+				continue // 提交在途
+			}
+			retry = append(retry, gridOrderIntent{
+				Level: i, Side: futures.SideType(lv.OrderSide), Price: lv.Price, Qty: lv.OrderQty, Closing: lv.Closing,
+				Gen: state.Generation,
+			})
+			continue
+		}
+		if !openSet[lv.OrderID] {
+			missing = append(missing, lv.OrderID)
+		}
+	}
+	// 对手层已空闲的待挂库存补挂平仓单
+	retry = append(retry, gridDrainPendingLocked(state)...)
+	gridMu.Unlock()
+
+	for _, orderID := range missing {
+		order, err := QuerySingleOrderViaWs(ctx, cfg.Symbol, orderID)
+		if err != nil {
+			log.Printf("[Grid] Reconcile query order %d failed: %v", orderID, err)
+			continue
+		}
+		handleGridOrderUpdate(futures.WsOrderTradeUpdate{
+			Symbol:               cfg.Symbol,
+			ClientOrderID:        order.ClientOrderId,
+			ID:                   order.OrderId,
+			Side:                 futures.SideType(order.Side),
+			Status:               futures.OrderStatusType(order.Status),
+			AveragePrice:         order.AvgPrice,
+			AccumulatedFilledQty: order.ExecutedQty,
+		})
+	}
+	for _, intent := range retry {
+		gridSubmitIntent(ctx, state, intent)
+	}
+}
+
+// gridCloseAll 网格止损/止盈 → 撤销挂单、平掉所有仓位并停止
 func gridCloseAll(ctx context.Context, state *gridState) {
-	cfg := state.Config
+	cfg := gridConfigOf(state)
 	positionSide := cfg.PositionSide
 	if positionSide == "" {
 		positionSide = futures.PositionSideTypeBoth
 	}
 
+	gridMu.Lock()
+	state.Active = false
+	orderIDs := gridCollectOrderIDsLocked(state)
+	gridMu.Unlock()
+
+	gridCancelOrders(ctx, cfg.Symbol, orderIDs)
+
 	_, err := ClosePositionViaWs(ctx, ClosePositionReq{
 		Symbol:       cfg.Symbol,
 		PositionSide: positionSide,
@@ -345,24 +1031,90 @@ func gridCloseAll(ctx context.Context, state *gridState) {
 		log.Printf("[Grid] Close all position failed: %v", err)
 	}
 
-	gridMu.Lock()
-	state.Active = false
-	gridMu.Unlock()
-
 	// 关闭 channel
 	select {
 	case <-state.stopC:
 	default:
 		close(state.stopC)
 	}
+	MarkStrategyStopped("grid", cfg.Symbol)
+}
+
+// gridCollectOrderIDsLocked 收集并清空所有挂单（需持有 gridMu）
+func gridCollectOrderIDsLocked(state *gridState) []int64 {
+	var ids []int64
+	for i := range state.Levels {
+		lv := &state.Levels[i]
+		if lv.OrderID != 0 {
+			ids = append(ids, lv.OrderID)
+		}
+		lv.OrderID = 0
+		lv.ClientID = ""
+		lv.OrderSide = ""
+		lv.OrderFee = 0
+	}
+	return ids
+}
+
+// gridCancelOrders 撤销网格挂单（DryRun 虚拟单无需撤销）
+func gridCancelOrders(ctx context.Context, symbol string, orderIDs []int64) {
+	for _, id := range orderIDs {
+		if id <= 0 {
+			continue
+		}
+		if _, err := CancelOrderViaWs(ctx, symbol, id); err != nil {
+			log.Printf("[Grid] Cancel order %d for %s failed: %v", id, symbol, err)
+		}
+	}
+}
+
+// gridConfigOf 读取网格当前配置（移动网格会改写区间）
+func gridConfigOf(state *gridState) GridConfig {
+	gridMu.Lock()
+	defer gridMu.Unlock()
+	return state.Config
+}
+
+func gridFindLevelByOrderLocked(state *gridState, orderID int64) int {
+	for i := range state.Levels {
+		if state.Levels[i].OrderID == orderID {
+			return i
+		}
+	}
+	return -1
 }
 
-// calculateGridLevels 辅助：计算等差网格价格
-func calculateGridLevels(lower, upper float64, count int) []float64 {
+func gridFindLevelByClientIDLocked(state *gridState, clientID string) int {
+	if clientID == "" {
+		return -1
+	}
+	for i := range state.Levels {
+		if state.Levels[i].ClientID == clientID {
+			return i
+		}
+	}
+	return -1
+}
+
+// gridClientOrderID 生成网格挂单的 clientOrderId（交易所限制 36 字符）
+func gridClientOrderID(gen, level int) string {
+	return fmt.Sprintf("grid-%d-%d-%s", gen, level, strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+// calculateGridLevels 计算网格价格：等差按固定价差，等比按固定百分比
+func calculateGridLevels(lower, upper float64, count int, spacing string) []float64 {
 	levels := make([]float64, count)
+	if spacing == GridSpacingGeometric && lower > 0 {
+		ratio := math.Pow(upper/lower, 1/float64(count-1))
+		for i := 0; i < count; i++ {
+			levels[i] = lower * math.Pow(ratio, float64(i))
+		}
+		levels[count-1] = upper
+		return levels
+	}
 	step := (upper - lower) / float64(count-1)
 	for i := 0; i < count; i++ {
-		levels[i] = math.Round((lower+step*float64(i))*100) / 100
+		levels[i] = lower + step*float64(i)
 	}
 	return levels
 }
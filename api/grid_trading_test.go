@@ -0,0 +1,316 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/futures"
+	ws "tools/websocket"
+)
+
+func newTestGridState(mode string, prices []float64) *gridState {
+	levels := make([]GridLevel, len(prices))
+	for i, p := range prices {
+		levels[i] = GridLevel{Index: i, Price: p}
+	}
+	return &gridState{
+		Config: GridConfig{Symbol: "BTCUSDT", Mode: mode, AmountPerGrid: "10", Leverage: 1},
+		Active: true,
+		Levels: levels,
+	}
+}
+
+func TestCalculateGridLevels_Geometric(t *testing.T) {
+	levels := calculateGridLevels(100, 121, 3, GridSpacingGeometric)
+	want := []float64{100, 110, 121}
+	for i := range want {
+		if math.Abs(levels[i]-want[i]) > 1e-9 {
+			t.Fatalf("level %d: got=%.6f want=%.6f", i, levels[i], want[i])
+		}
+	}
+
+	arith := calculateGridLevels(100, 120, 5, GridSpacingArithmetic)
+	if math.Abs(arith[1]-105) > 1e-9 || math.Abs(arith[4]-120) > 1e-9 {
+		t.Fatalf("unexpected arithmetic levels: %v", arith)
+	}
+}
+
+func TestPlanGridInitialOrders_NeutralLeavesGap(t *testing.T) {
+	state := newTestGridState(GridModeNeutral, []float64{90, 95, 100, 105, 110})
+	intents := planGridInitialOrdersLocked(state, 101)
+
+	if len(intents) != 4 {
+		t.Fatalf("expected 4 initial orders, got %d", len(intents))
+	}
+	if state.Levels[2].OrderSide != "" {
+		t.Fatalf("expected level nearest to price to stay empty, got %s", state.Levels[2].OrderSide)
+	}
+	if state.Levels[1].OrderSide != string(futures.SideTypeBuy) || state.Levels[3].OrderSide != string(futures.SideTypeSell) {
+		t.Fatalf("unexpected ladder: %+v", state.Levels)
+	}
+
+	long := newTestGridState(GridModeLong, []float64{90, 95, 100, 105, 110})
+	if got := len(planGridInitialOrdersLocked(long, 101)); got != 3 {
+		t.Fatalf("long grid should only place buys below price, got %d orders", got)
+	}
+}
+
+func TestApplyGridFill_RoundTripProfit(t *testing.T) {
+	state := newTestGridState(GridModeLong, []float64{90, 100, 110})
+	state.Levels[1].OrderID = 1
+	state.Levels[1].OrderSide = string(futures.SideTypeBuy)
+
+	intents := applyGridFillLocked(state, 1, 100, 2, 0.1)
+	if len(intents) != 1 || intents[0].Level != 2 || intents[0].Side != futures.SideTypeSell || !intents[0].Closing {
+		t.Fatalf("unexpected counter after buy fill: %+v", intents)
+	}
+	if !state.Levels[1].Filled {
+		t.Fatal("expected level 1 to hold inventory")
+	}
+
+	state.Levels[2].OrderID = 2
+	intents = applyGridFillLocked(state, 2, 110, 2, 0.1)
+	if len(intents) != 1 || intents[0].Level != 1 || intents[0].Side != futures.SideTypeBuy || intents[0].Closing {
+		t.Fatalf("unexpected re-arm after sell fill: %+v", intents)
+	}
+
+	// (110-100)*2 - 0.1 - 0.1 = 19.8
+	if math.Abs(state.TotalProfit-19.8) > 1e-9 {
+		t.Fatalf("unexpected total profit: %.6f", state.TotalProfit)
+	}
+	if state.Levels[1].RoundTrips != 1 || math.Abs(state.Levels[1].Profit-19.8) > 1e-9 {
+		t.Fatalf("profit should be attributed to entry level: %+v", state.Levels[1])
+	}
+	if state.Levels[1].Filled {
+		t.Fatal("expected level 1 inventory released after round trip")
+	}
+}
+
+func TestApplyGridFill_OccupiedCounterQueued(t *testing.T) {
+	state := newTestGridState(GridModeNeutral, []float64{90, 100, 110})
+	state.Levels[1].OrderID = 1
+	state.Levels[1].OrderSide = string(futures.SideTypeBuy)
+	state.Levels[2].OrderID = 2
+	state.Levels[2].OrderSide = string(futures.SideTypeSell)
+
+	// 对手层挂着开空单：平仓单进入待挂队列，仍计入库存
+	if intents := applyGridFillLocked(state, 1, 100, 2, 0); len(intents) != 0 {
+		t.Fatalf("counter level occupied, got %+v", intents)
+	}
+	if len(state.Pending) != 1 || gridImpliedInventoryLocked(state) != 2 {
+		t.Fatalf("pending=%+v inventory=%.4f", state.Pending, gridImpliedInventoryLocked(state))
+	}
+
+	// 对手层的开空单成交后立即补挂平多单，空单的平仓单落在刚释放的第 1 层
+	intents := applyGridFillLocked(state, 2, 110, 1, 0)
+	if len(intents) != 2 || len(state.Pending) != 0 {
+		t.Fatalf("intents=%+v pending=%+v", intents, state.Pending)
+	}
+	if intents[0].Level != 2 || intents[0].Side != futures.SideTypeSell || intents[0].Qty != 2 {
+		t.Fatalf("queued long not closed first: %+v", intents[0])
+	}
+	if intents[1].Level != 1 || intents[1].Side != futures.SideTypeBuy || intents[1].Qty != 1 {
+		t.Fatalf("short counter: %+v", intents[1])
+	}
+}
+
+func TestPlanGridInitialOrders_SkipsEdgeOpenings(t *testing.T) {
+	state := newTestGridState(GridModeLong, []float64{90, 95, 100})
+	intents := planGridInitialOrdersLocked(state, 120)
+	if len(intents) != 2 || state.Levels[2].OrderSide != "" {
+		t.Fatalf("top level has no counter level, got %+v", intents)
+	}
+}
+
+func TestApplyGridOrderUpdate_MatchesClientID(t *testing.T) {
+	state := newTestGridState(GridModeLong, []float64{90, 100, 110})
+	state.Levels[1].OrderSide = string(futures.SideTypeBuy)
+	state.Levels[1].OrderQty = 2
+	state.Levels[1].ClientID = "grid-0-1-test"
+
+	// 成交回报早于下单响应：订单号尚未回写
+	intents := applyGridOrderUpdateLocked(state, futures.WsOrderTradeUpdate{
+		Symbol: "BTCUSDT", ClientOrderID: "grid-0-1-test", ID: 77,
+		Status: futures.OrderStatusTypeFilled, AveragePrice: "100", AccumulatedFilledQty: "2",
+	})
+	if !state.Levels[1].Filled || state.FilledBuys != 1 || state.Levels[1].ClientID != "" {
+		t.Fatalf("fill not applied: %+v", state.Levels[1])
+	}
+	if len(intents) != 1 || intents[0].Level != 2 || !intents[0].Closing || intents[0].Qty != 2 {
+		t.Fatalf("counter not armed: %+v", intents)
+	}
+	if got := applyGridOrderUpdateLocked(state, futures.WsOrderTradeUpdate{ClientOrderID: "other", ID: 78}); got != nil {
+		t.Fatalf("unknown order should be ignored: %+v", got)
+	}
+}
+
+func TestApplyGridOrderUpdate_PartialFillThenCancel(t *testing.T) {
+	state := newTestGridState(GridModeLong, []float64{90, 100, 110})
+	state.Levels[1].OrderSide = string(futures.SideTypeBuy)
+	state.Levels[1].OrderQty = 2
+	state.Levels[1].OrderID = 11
+
+	// 开仓买单成交 0.5 后被撤：形成 0.5 库存并在上一层挂平仓卖单
+	intents := applyGridOrderUpdateLocked(state, futures.WsOrderTradeUpdate{
+		Symbol: "BTCUSDT", ID: 11, Status: futures.OrderStatusTypeCanceled,
+		AveragePrice: "100", AccumulatedFilledQty: "0.5",
+	})
+	open := state.Levels[1]
+	if !open.Filled || open.LotQty != 0.5 || open.OrderSide != "" || state.FilledBuys != 1 {
+		t.Fatalf("partial open not recorded: %+v", open)
+	}
+	if len(intents) != 1 || intents[0].Level != 2 || !intents[0].Closing || intents[0].Qty != 0.5 {
+		t.Fatalf("counter not armed for partial qty: %+v", intents)
+	}
+
+	// 平仓卖单成交 0.2 后被撤：结算 0.2 的利润，剩余 0.3 重新挂出
+	state.Levels[2].OrderID = 12
+	intents = applyGridOrderUpdateLocked(state, futures.WsOrderTradeUpdate{
+		Symbol: "BTCUSDT", ID: 12, Status: futures.OrderStatusTypeExpired,
+		AveragePrice: "110", AccumulatedFilledQty: "0.2",
+	})
+	if math.Abs(state.TotalProfit-2) > 1e-9 || state.RoundTrips != 0 || state.FilledSells != 1 {
+		t.Fatalf("partial close not settled: profit=%v trips=%d sells=%d", state.TotalProfit, state.RoundTrips, state.FilledSells)
+	}
+	if !state.Levels[1].Filled || math.Abs(state.Levels[1].LotQty-0.3) > 1e-9 {
+		t.Fatalf("remaining lot lost: %+v", state.Levels[1])
+	}
+	if len(intents) != 1 || intents[0].Level != 2 || math.Abs(intents[0].Qty-0.3) > 1e-9 || len(state.Pending) != 0 {
+		t.Fatalf("remaining counter not re-armed: %+v pending=%+v", intents, state.Pending)
+	}
+}
+
+func TestPickGridSRBounds_SnapsWithinWidth(t *testing.T) {
+	sr := &SRResponse{
+		Supports:    []SRLevel{{Price: 80}, {Price: 93}, {Price: 99}},
+		Resistances: []SRLevel{{Price: 101}, {Price: 130}},
+	}
+	lower, upper, ok := pickGridSRBounds(100, 90, 110, sr)
+	if !ok {
+		t.Fatal("expected support snap")
+	}
+	// 99 离现价过近被忽略，80 超出宽度；阻力位均不在 [102.5, 110] 内
+	if lower != 93 || upper != 110 {
+		t.Fatalf("unexpected bounds: lower=%.2f upper=%.2f", lower, upper)
+	}
+}
+
+func TestGridAssignCarriedLots_LongInventoryAbovePrice(t *testing.T) {
+	state := newTestGridState(GridModeLong, []float64{100, 105, 110, 115})
+	lots := []gridInventoryLot{
+		{CounterSide: futures.SideTypeSell, Qty: 1, EntryPrice: 95},
+		{CounterSide: futures.SideTypeSell, Qty: 1, EntryPrice: 90},
+	}
+
+	intents, leftover := gridAssignCarriedLotsLocked(state, lots, 106)
+	if len(leftover) != 0 || len(intents) != 2 {
+		t.Fatalf("expected both lots carried, intents=%d leftover=%d", len(intents), len(leftover))
+	}
+	if intents[0].Level != 2 || state.Levels[2].EntryPrice != 90 {
+		t.Fatalf("cheapest lot should take the nearest level above price: %+v", state.Levels[2])
+	}
+	if intents[1].Level != 3 || !state.Levels[2].Filled || !state.Levels[1].Filled {
+		t.Fatalf("unexpected assignment: %+v", state.Levels)
+	}
+	if got := planGridInitialOrdersLocked(state, 106); len(got) != 1 || got[0].Level != 0 {
+		t.Fatalf("only the free level below price should get an opening buy, got %+v", got)
+	}
+}
+
+func TestGridInventoryLots_FromFilledLevels(t *testing.T) {
+	state := newTestGridState(GridModeLong, []float64{90, 100, 110, 120})
+	// 第 1 层库存挂着平仓单，第 2 层库存没有对手单（孤立库存），第 0 层只有成交记录
+	state.Levels[1].Filled = true
+	state.Levels[1].LotQty, state.Levels[1].LotPrice = 1, 100
+	state.Levels[2].OrderID, state.Levels[2].OrderSide, state.Levels[2].Closing = 5, "SELL", true
+	state.Levels[2].OrderQty, state.Levels[2].EntryLevel, state.Levels[2].EntryPrice = 1, 1, 100
+	state.Levels[2].Filled = true
+	state.Levels[2].LotQty, state.Levels[2].LotPrice = 2, 110
+	state.Levels[0].Filled = true
+	state.Fills = []GridFillRecord{{Level: 0, Side: "BUY", Price: 90, Quantity: 3}}
+
+	lots := gridInventoryLotsLocked(state)
+	if len(lots) != 3 || gridImpliedInventoryLocked(state) != 6 {
+		t.Fatalf("lots=%+v", lots)
+	}
+	byLevel := map[int]gridInventoryLot{}
+	for _, l := range lots {
+		byLevel[l.EntryLevel] = l
+	}
+	if byLevel[1].OrderID != 5 || byLevel[2].OrderID != 0 || byLevel[0].Qty != 3 || byLevel[0].EntryPrice != 90 {
+		t.Fatalf("lots=%+v", byLevel)
+	}
+}
+
+func TestGridSettleCancelledLots(t *testing.T) {
+	lots := []gridInventoryLot{
+		{CounterSide: futures.SideTypeSell, Qty: 2, EntryPrice: 100, EntryFee: 0.2, EntryLevel: 1, OrderID: 11}, // 撤单前成交一半
+		{CounterSide: futures.SideTypeSell, Qty: 1, EntryPrice: 95, EntryLevel: 0, OrderID: 12},                 // 撤单失败仍挂着
+		{CounterSide: futures.SideTypeSell, Qty: 1, EntryPrice: 105, EntryLevel: 2},                             // 孤立库存
+	}
+	openings := map[int64]futures.SideType{21: futures.SideTypeBuy}
+	results := map[int64]gridCancelResult{
+		11: {ExecutedQty: 1, AvgPrice: 110},
+		12: {Live: true},
+		21: {ExecutedQty: 0.5, AvgPrice: 90},
+	}
+	kept, settled := gridSettleCancelledLots(lots, openings, results)
+	if len(settled) != 1 || settled[0].Quantity != 1 || math.Abs(settled[0].Profit-(10-0.1-calcFee(110, 1))) > 1e-9 {
+		t.Fatalf("settled=%+v", settled)
+	}
+	if len(kept) != 3 || kept[0].Qty != 1 || math.Abs(kept[0].EntryFee-0.1) > 1e-12 || kept[1].EntryLevel != 2 {
+		t.Fatalf("kept=%+v", kept)
+	}
+	if kept[2].Qty != 0.5 || kept[2].CounterSide != futures.SideTypeSell || kept[2].EntryPrice != 90 {
+		t.Fatalf("opening fill not carried: %+v", kept[2])
+	}
+}
+
+func TestGridCancelAndVerify(t *testing.T) {
+	origCancel, origQuery := gridCancelOrder, gridQueryOrder
+	defer func() { gridCancelOrder, gridQueryOrder = origCancel, origQuery }()
+	gridCancelOrder = func(_ context.Context, _ string, id int64) (*futures.CancelOrderResponse, error) {
+		if id == 1 {
+			return &futures.CancelOrderResponse{Status: futures.OrderStatusTypeCanceled, ExecutedQuantity: "0"}, nil
+		}
+		return nil, fmt.Errorf("unknown order")
+	}
+	gridQueryOrder = func(_ context.Context, _ string, id int64) (*ws.OrderResult, error) {
+		return &ws.OrderResult{OrderId: id, Status: "FILLED", ExecutedQty: "2", AvgPrice: "101.5"}, nil
+	}
+
+	results := gridCancelAndVerify(context.Background(), "BTCUSDT", []int64{1, 2, -3})
+	if len(results) != 2 || results[1].ExecutedQty != 0 || results[2].ExecutedQty != 2 || results[2].AvgPrice != 101.5 || results[2].Live {
+		t.Fatalf("results=%+v", results)
+	}
+}
+
+func TestGridRuntimeMatchesAndAdopt(t *testing.T) {
+	cfg := GridConfig{LowerPrice: 90, UpperPrice: 110, GridCount: 5, Spacing: GridSpacingArithmetic, Mode: GridModeLong}
+	rt := &gridRuntime{Levels: []GridLevel{{Price: 90}, {Price: 95}, {Price: 100}, {Price: 105}, {Price: 110}}}
+	if !gridRuntimeMatches(cfg, rt) {
+		t.Fatal("expected snapshot to match config")
+	}
+	cfg.UpperPrice = 120
+	if gridRuntimeMatches(cfg, rt) {
+		t.Fatal("snapshot with different range should not match")
+	}
+
+	state := newTestGridState(GridModeLong, []float64{90, 95, 100, 105, 110})
+	orders := []*futures.Order{
+		{OrderID: 11, Price: "105.0000", Side: futures.SideTypeSell, OrigQuantity: "2", ExecutedQuantity: "0.5"},
+		{OrderID: 12, Price: "97", Side: futures.SideTypeBuy, OrigQuantity: "1"},
+	}
+	if n := adoptGridOpenOrdersLocked(state, orders); n != 1 {
+		t.Fatalf("expected 1 adopted order, got %d", n)
+	}
+	lv := state.Levels[3]
+	if lv.OrderID != 11 || !lv.Closing || lv.OrderQty != 1.5 || lv.EntryPrice != 100 || !state.Levels[2].Filled {
+		t.Fatalf("unexpected adopted level: %+v", lv)
+	}
+	if got := gridImpliedInventoryLocked(state); got != 1.5 {
+		t.Fatalf("unexpected implied inventory: %.4f", got)
+	}
+}
@@ -0,0 +1,518 @@
+package api
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// 移动网格平移时的库存处理方式
+const (
+	GridTrailCarry   = "carry"   // 保留持仓，在新网格里继续挂平仓对手单
+	GridTrailRealize = "realize" // 平移时直接了结持仓
+)
+
+// gridTrailConfirmTicks 价格需连续多少个 tick 位于区间外才触发平移，过滤插针
+const gridTrailConfirmTicks = 3
+
+// GridShift 网格平移记录
+type GridShift struct {
+	At           time.Time `json:"at"`
+	Price        float64   `json:"price"`
+	FromLower    float64   `json:"fromLower"`
+	FromUpper    float64   `json:"fromUpper"`
+	ToLower      float64   `json:"toLower"`
+	ToUpper      float64   `json:"toUpper"`
+	BoundsSource string    `json:"boundsSource"` // centered / sr
+	Inventory    string    `json:"inventory"`    // carry / realize
+	CarriedLots  int       `json:"carriedLots"`
+	RealizedQty  float64   `json:"realizedQty"`
+	RealizedPnl  float64   `json:"realizedPnl"`
+}
+
+// gridInventoryLot 一笔待平仓的网格库存（来自已成交的开仓层）
+type gridInventoryLot struct {
+	CounterSide futures.SideType // 平仓方向：SELL 平多、BUY 平空
+	Qty         float64
+	EntryPrice  float64
+	EntryFee    float64
+	EntryLevel  int
+	OrderID     int64 // 挂着的平仓单，0 表示没有
+}
+
+// gridCancelResult 平移撤单后核实的订单状态
+type gridCancelResult struct {
+	ExecutedQty float64
+	AvgPrice    float64
+	Live        bool // 撤单失败且订单仍在挂着
+}
+
+// 撤单/查单（测试注入点）
+var (
+	gridCancelOrder = CancelOrderViaWs
+	gridQueryOrder  = QuerySingleOrderViaWs
+)
+
+// gridCheckTrailing 价格持续突破区间时平移网格，返回 true 表示本 tick 已处理平移
+func gridCheckTrailing(ctx context.Context, state *gridState, price float64) bool {
+	gridMu.Lock()
+	cfg := state.Config
+	if price <= cfg.UpperPrice && price >= cfg.LowerPrice {
+		state.outsideTick = 0
+		gridMu.Unlock()
+		return false
+	}
+	state.outsideTick++
+	if state.outsideTick < gridTrailConfirmTicks {
+		gridMu.Unlock()
+		return false
+	}
+	if cfg.MaxShifts > 0 && cfg.ShiftCount >= cfg.MaxShifts {
+		if state.outsideTick == gridTrailConfirmTicks {
+			log.Printf("[Grid] %s reached max shifts (%d), ladder stays at [%.4f, %.4f]",
+				cfg.Symbol, cfg.MaxShifts, cfg.LowerPrice, cfg.UpperPrice)
+		}
+		gridMu.Unlock()
+		return false
+	}
+	state.outsideTick = 0
+	gridMu.Unlock()
+
+	gridShiftLadder(ctx, state, price)
+	return true
+}
+
+// gridShiftLadder 以当前价格为中心重建网格：撤旧单 → 处理库存 → 铺新挂单 → 持久化新区间
+func gridShiftLadder(ctx context.Context, state *gridState, price float64) {
+	gridMu.Lock()
+	if !state.Active {
+		gridMu.Unlock()
+		return
+	}
+	cfg := state.Config
+	lots := gridCollectInventoryLocked(state)
+	openings := make(map[int64]futures.SideType)
+	for _, lv := range state.Levels {
+		if lv.OrderID != 0 && !lv.Closing {
+			openings[lv.OrderID] = futures.SideType(lv.OrderSide)
+		}
+	}
+	orderIDs := gridCollectOrderIDsLocked(state)
+	state.Generation++
+	gridMu.Unlock()
+
+	// 撤单后逐笔核实：收集与撤单之间成交的部分不能既算成交又算库存
+	results := gridCancelAndVerify(ctx, cfg.Symbol, orderIDs)
+	lots, settled := gridSettleCancelledLots(lots, openings, results)
+
+	lower, upper, source := gridTrailBounds(ctx, cfg, price)
+	prices := calculateGridLevels(lower, upper, cfg.GridCount, cfg.Spacing)
+
+	gridMu.Lock()
+	if !state.Active {
+		gridMu.Unlock()
+		return
+	}
+	levels := make([]GridLevel, len(prices))
+	for i, p := range prices {
+		levels[i] = GridLevel{Index: i, Price: p}
+	}
+	state.Levels = levels
+	state.Config.LowerPrice = lower
+	state.Config.UpperPrice = upper
+	state.Config.ShiftCount++
+
+	var intents []gridOrderIntent
+	toRealize := lots
+	if cfg.TrailInventory != GridTrailRealize {
+		intents, toRealize = gridAssignCarriedLotsLocked(state, lots, price)
+	}
+	intents = append(intents, planGridInitialOrdersLocked(state, price)...)
+
+	shift := GridShift{
+		At:           time.Now(),
+		Price:        price,
+		FromLower:    cfg.LowerPrice,
+		FromUpper:    cfg.UpperPrice,
+		ToLower:      lower,
+		ToUpper:      upper,
+		BoundsSource: source,
+		Inventory:    cfg.TrailInventory,
+		CarriedLots:  len(lots) - len(toRealize),
+	}
+	var sellQty, buyQty float64
+	for _, lot := range toRealize {
+		if lot.CounterSide == futures.SideTypeSell {
+			sellQty += lot.Qty
+			shift.RealizedPnl += (price-lot.EntryPrice)*lot.Qty - lot.EntryFee
+		} else {
+			buyQty += lot.Qty
+			shift.RealizedPnl += (lot.EntryPrice-price)*lot.Qty - lot.EntryFee
+		}
+	}
+	shift.RealizedQty = sellQty + buyQty
+	for _, rec := range settled {
+		if rec.Side == string(futures.SideTypeBuy) {
+			state.FilledBuys++
+		} else {
+			state.FilledSells++
+		}
+		state.TotalProfit += rec.Profit
+		state.RoundTrips++
+		recordGridFillLocked(state, rec)
+	}
+	state.TotalProfit += shift.RealizedPnl
+	state.Shifts = append(state.Shifts, shift)
+	state.dirty = true
+	if len(state.Shifts) > 50 {
+		state.Shifts = state.Shifts[len(state.Shifts)-50:]
+	}
+	newCfg := state.Config
+	gridMu.Unlock()
+
+	// 同向库存相互抵消后按净额了结
+	net := sellQty - buyQty
+	if net > 0 {
+		gridRealizeInventory(ctx, cfg, futures.SideTypeSell, net)
+	} else if net < 0 {
+		gridRealizeInventory(ctx, cfg, futures.SideTypeBuy, -net)
+	}
+
+	log.Printf("[Grid] %s shifted #%d at %.4f: [%.4f, %.4f] → [%.4f, %.4f] (%s), carried=%d realized=%.8f pnl=%.4f",
+		cfg.Symbol, newCfg.ShiftCount, price, cfg.LowerPrice, cfg.UpperPrice, lower, upper, source,
+		shift.CarriedLots, shift.RealizedQty, shift.RealizedPnl)
+
+	// 新区间随配置持久化，重启恢复时直接沿用当前网格
+	SaveStrategyState("grid", newCfg.Symbol, newCfg)
+
+	for _, intent := range intents {
+		gridSubmitIntent(ctx, state, intent)
+	}
+}
+
+// gridCollectInventoryLocked 提取当前库存并清空待挂队列（需持有 gridMu）
+func gridCollectInventoryLocked(state *gridState) []gridInventoryLot {
+	lots := gridInventoryLotsLocked(state)
+	state.Pending = nil
+	return lots
+}
+
+// gridInventoryLotsLocked 以已成交的开仓层为准推算库存（需持有 gridMu）：
+// 数量/成本优先取层上记录的库存，其次取挂着的平仓单或待挂队列，最后回溯本代成交记录；
+// 没有挂平仓单的孤立库存同样计入。
+func gridInventoryLotsLocked(state *gridState) []gridInventoryLot {
+	counters := make(map[int]gridInventoryLot)
+	for _, lv := range state.Levels {
+		if lv.Closing && lv.OrderSide != "" && lv.OrderQty > 0 {
+			counters[lv.EntryLevel] = gridInventoryLot{
+				CounterSide: futures.SideType(lv.OrderSide), Qty: lv.OrderQty,
+				EntryPrice: lv.EntryPrice, EntryFee: lv.EntryFee, EntryLevel: lv.EntryLevel, OrderID: lv.OrderID,
+			}
+		}
+	}
+	for _, p := range state.Pending {
+		if _, ok := counters[p.EntryLevel]; !ok {
+			counters[p.EntryLevel] = gridInventoryLot{
+				CounterSide: futures.SideType(p.Side), Qty: p.Qty,
+				EntryPrice: p.EntryPrice, EntryFee: p.EntryFee, EntryLevel: p.EntryLevel,
+			}
+		}
+	}
+
+	var lots []gridInventoryLot
+	for i, lv := range state.Levels {
+		lot, hasCounter := counters[i]
+		delete(counters, i)
+		if !lv.Filled {
+			if hasCounter {
+				lots = append(lots, lot) // 开仓层越界或旧快照
+			}
+			continue
+		}
+		lot.EntryLevel = i
+		if lv.LotQty > 0 {
+			lot.Qty, lot.EntryPrice, lot.EntryFee = lv.LotQty, lv.LotPrice, lv.LotFee
+		}
+		if !hasCounter || lot.Qty <= 0 {
+			if rec, ok := gridLastOpenFillLocked(state, i); ok {
+				if lot.Qty <= 0 {
+					lot.Qty, lot.EntryPrice, lot.EntryFee = rec.Quantity, rec.Price, rec.Fee
+				}
+				lot.CounterSide = futures.SideTypeSell
+				if rec.Side == string(futures.SideTypeSell) {
+					lot.CounterSide = futures.SideTypeBuy
+				}
+			}
+		}
+		if lot.CounterSide == "" {
+			switch state.Config.Mode {
+			case GridModeLong:
+				lot.CounterSide = futures.SideTypeSell
+			case GridModeShort:
+				lot.CounterSide = futures.SideTypeBuy
+			}
+		}
+		if lot.Qty <= 0 || lot.CounterSide == "" {
+			log.Printf("[Grid] %s level %d marked filled but inventory unknown, skipped", state.Config.Symbol, i)
+			continue
+		}
+		lots = append(lots, lot)
+	}
+	for _, lot := range counters {
+		lots = append(lots, lot)
+	}
+	return lots
+}
+
+// gridLastOpenFillLocked 本代网格中该层最近一笔开仓成交（需持有 gridMu）
+func gridLastOpenFillLocked(state *gridState, level int) (GridFillRecord, bool) {
+	for i := len(state.Fills) - 1; i >= 0; i-- {
+		f := state.Fills[i]
+		if f.Generation == state.Generation && f.Level == level && !f.Closing {
+			return f, true
+		}
+	}
+	return GridFillRecord{}, false
+}
+
+// gridCancelAndVerify 撤销平移前的挂单，撤单未确认 CANCELED 时查询订单状态，返回各单已成交数量
+func gridCancelAndVerify(ctx context.Context, symbol string, orderIDs []int64) map[int64]gridCancelResult {
+	results := make(map[int64]gridCancelResult, len(orderIDs))
+	for _, id := range orderIDs {
+		if id <= 0 {
+			continue
+		}
+		resp, err := gridCancelOrder(ctx, symbol, id)
+		if err == nil && resp.Status == futures.OrderStatusTypeCanceled && parseNumeric(resp.ExecutedQuantity) == 0 {
+			results[id] = gridCancelResult{}
+			continue
+		}
+		// 撤单失败、已成交或部分成交：查询成交均价与数量
+		order, qerr := gridQueryOrder(ctx, symbol, id)
+		if qerr != nil {
+			// 状态未知：按未成交处理，由恢复对账核对持仓
+			log.Printf("[Grid] %s cancel order %d failed (%v) and query failed: %v", symbol, id, err, qerr)
+			continue
+		}
+		res := gridCancelResult{ExecutedQty: parseNumeric(order.ExecutedQty), AvgPrice: parseNumeric(order.AvgPrice)}
+		if res.AvgPrice <= 0 {
+			res.AvgPrice = parseNumeric(order.Price)
+		}
+		switch futures.OrderStatusType(order.Status) {
+		case futures.OrderStatusTypeNew, futures.OrderStatusTypePartiallyFilled:
+			res.Live = true
+			log.Printf("[Grid] %s order %d still %s after cancel: %v", symbol, id, order.Status, err)
+			SaveFailedOperation("CANCEL_ORDER", "strategy_grid", symbol, map[string]interface{}{
+				"orderId": id, "reason": "grid_shift",
+			}, id, err)
+		}
+		results[id] = res
+	}
+	return results
+}
+
+// gridSettleCancelledLots 按撤单核实结果调整库存：
+// 平仓单已成交部分按成交价结算为一轮利润、不再携带；仍在挂着的平仓单继续负责其库存，不携带也不了结；
+// 开仓单已成交部分作为新库存。
+func gridSettleCancelledLots(lots []gridInventoryLot, openings map[int64]futures.SideType, results map[int64]gridCancelResult) ([]gridInventoryLot, []GridFillRecord) {
+	var kept []gridInventoryLot
+	var settled []GridFillRecord
+	for _, lot := range lots {
+		res, ok := results[lot.OrderID]
+		if lot.OrderID == 0 || !ok {
+			kept = append(kept, lot)
+			continue
+		}
+		if res.Live {
+			continue
+		}
+		if res.ExecutedQty > 0 {
+			qty := math.Min(res.ExecutedQty, lot.Qty)
+			price := res.AvgPrice
+			entryFee := lot.EntryFee * qty / lot.Qty
+			gross := (price - lot.EntryPrice) * qty
+			if lot.CounterSide == futures.SideTypeBuy {
+				gross = (lot.EntryPrice - price) * qty
+			}
+			fee := calcFee(price, qty)
+			settled = append(settled, GridFillRecord{
+				Level: -1, OrderID: lot.OrderID, Side: string(lot.CounterSide), Closing: true,
+				Price: price, Quantity: qty, Fee: fee, Profit: gross - entryFee - fee,
+				EntryLevel: lot.EntryLevel, EntryPrice: lot.EntryPrice,
+			})
+			lot.Qty -= qty
+			lot.EntryFee -= entryFee
+		}
+		if lot.Qty > 1e-12 {
+			kept = append(kept, lot)
+		}
+	}
+	for id, side := range openings {
+		res := results[id]
+		if res.ExecutedQty <= 0 || res.Live {
+			continue
+		}
+		counter := futures.SideTypeSell
+		if side == futures.SideTypeSell {
+			counter = futures.SideTypeBuy
+		}
+		kept = append(kept, gridInventoryLot{
+			CounterSide: counter, Qty: res.ExecutedQty, EntryPrice: res.AvgPrice, EntryFee: calcFee(res.AvgPrice, res.ExecutedQty),
+		})
+	}
+	return kept, settled
+}
+
+// gridAssignCarriedLotsLocked 把库存挂到新网格最近的空闲层作为平仓对手单，放不下的返回给调用方了结（需持有 gridMu）
+// 多头库存挂在现价上方（成本低的离现价近），空头库存挂在现价下方
+func gridAssignCarriedLotsLocked(state *gridState, lots []gridInventoryLot, price float64) ([]gridOrderIntent, []gridInventoryLot) {
+	var sells, buys []gridInventoryLot
+	for _, lot := range lots {
+		if lot.CounterSide == futures.SideTypeSell {
+			sells = append(sells, lot)
+		} else {
+			buys = append(buys, lot)
+		}
+	}
+	sort.Slice(sells, func(i, j int) bool { return sells[i].EntryPrice < sells[j].EntryPrice })
+	sort.Slice(buys, func(i, j int) bool { return buys[i].EntryPrice > buys[j].EntryPrice })
+
+	var intents []gridOrderIntent
+	var leftover []gridInventoryLot
+
+	assign := func(lot gridInventoryLot, idx, entryIdx int) {
+		lv := &state.Levels[idx]
+		lv.OrderSide = string(lot.CounterSide)
+		lv.Closing = true
+		lv.OrderQty = lot.Qty
+		lv.EntryPrice = lot.EntryPrice
+		lv.EntryFee = lot.EntryFee
+		lv.EntryLevel = entryIdx
+		entry := &state.Levels[entryIdx]
+		entry.Filled = true
+		entry.LotQty, entry.LotPrice, entry.LotFee = lot.Qty, lot.EntryPrice, lot.EntryFee
+		intents = append(intents, gridOrderIntent{
+			Level: idx, Side: lot.CounterSide, Price: lv.Price, Qty: lot.Qty, Closing: true, Gen: state.Generation,
+		})
+	}
+
+	next := 0
+	for next < len(state.Levels) && state.Levels[next].Price <= price {
+		next++
+	}
+	for _, lot := range sells {
+		for next < len(state.Levels) && (next == 0 || state.Levels[next].OrderSide != "" || state.Levels[next-1].Filled) {
+			next++
+		}
+		if next >= len(state.Levels) {
+			leftover = append(leftover, lot)
+			continue
+		}
+		assign(lot, next, next-1)
+		next++
+	}
+
+	prev := len(state.Levels) - 1
+	for prev >= 0 && state.Levels[prev].Price >= price {
+		prev--
+	}
+	for _, lot := range buys {
+		for prev >= 0 && (prev == len(state.Levels)-1 || state.Levels[prev].OrderSide != "" || state.Levels[prev+1].Filled) {
+			prev--
+		}
+		if prev < 0 {
+			leftover = append(leftover, lot)
+			continue
+		}
+		assign(lot, prev, prev+1)
+		prev--
+	}
+	return intents, leftover
+}
+
+// gridTrailBounds 计算平移后的新区间：默认以现价为中心保持原区间宽度（等比网格保持倍数），
+// 开启 SRBounds 时把边界吸附到附近的支撑/阻力位
+func gridTrailBounds(ctx context.Context, cfg GridConfig, price float64) (float64, float64, string) {
+	var lower, upper float64
+	if cfg.Spacing == GridSpacingGeometric {
+		half := math.Sqrt(cfg.UpperPrice / cfg.LowerPrice)
+		lower, upper = price/half, price*half
+	} else {
+		width := cfg.UpperPrice - cfg.LowerPrice
+		lower, upper = price-width/2, price+width/2
+		if lower <= 0 {
+			lower = price / 2
+		}
+	}
+
+	if !cfg.SRBounds {
+		return lower, upper, "centered"
+	}
+	sr, err := GetSRLevels(ctx, cfg.Symbol)
+	if err != nil {
+		log.Printf("[Grid] %s SR levels unavailable, use centered bounds: %v", cfg.Symbol, err)
+		return lower, upper, "centered"
+	}
+	if l, u, ok := pickGridSRBounds(price, lower, upper, sr); ok {
+		return l, u, "sr"
+	}
+	return lower, upper, "centered"
+}
+
+// pickGridSRBounds 在 [现价-宽度, 现价-宽度/4] 内找最近支撑作为下界，在 [现价+宽度/4, 现价+宽度] 内找最近阻力作为上界，
+// 两侧独立吸附，任一侧吸附成功即返回 true
+func pickGridSRBounds(price, lower, upper float64, sr *SRResponse) (float64, float64, bool) {
+	if sr == nil {
+		return lower, upper, false
+	}
+	downWidth := price - lower
+	upWidth := upper - price
+	snapped := false
+
+	bestSupport := 0.0
+	for _, s := range sr.Supports {
+		if s.Price < price-downWidth/4 && s.Price >= price-downWidth && s.Price > bestSupport {
+			bestSupport = s.Price
+		}
+	}
+	if bestSupport > 0 {
+		lower = bestSupport
+		snapped = true
+	}
+
+	bestResist := math.MaxFloat64
+	for _, r := range sr.Resistances {
+		if r.Price > price+upWidth/4 && r.Price <= price+upWidth && r.Price < bestResist {
+			bestResist = r.Price
+		}
+	}
+	if bestResist < math.MaxFloat64 {
+		upper = bestResist
+		snapped = true
+	}
+	return lower, upper, snapped
+}
+
+// gridRealizeInventory 按市价了结平移时未能携带的库存
+func gridRealizeInventory(ctx context.Context, cfg GridConfig, side futures.SideType, qty float64) {
+	if IsDryRun() {
+		gridPaperFill(cfg, side, qty)
+		return
+	}
+	_, err := ReducePositionViaWs(ctx, ReducePositionReq{
+		Symbol:       cfg.Symbol,
+		PositionSide: cfg.PositionSide,
+		Quantity:     strconv.FormatFloat(qty, 'f', -1, 64),
+	})
+	if err != nil {
+		log.Printf("[Grid] %s realize inventory %s %.8f failed: %v", cfg.Symbol, side, qty, err)
+		SaveFailedOperation("REDUCE_POSITION", "strategy_grid", cfg.Symbol, map[string]interface{}{
+			"side": side, "quantity": qty, "reason": "grid_shift",
+		}, 0, err)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -32,12 +33,7 @@ func HandleGetPositions(c context.Context, ctx *app.RequestContext) {
 
 // HandlePlaceOrder POST /api/order
 func HandlePlaceOrder(c context.Context, ctx *app.RequestContext) {
-	// 风控检查
-	if err := CheckRisk(); err != nil {
-		ctx.JSON(http.StatusForbidden, utils.H{"error": err.Error()})
-		return
-	}
-
+	// 风控检查统一在 PlaceOrderViaWs 的下单前网关中执行
 	var req PlaceOrderReq
 	if err := ctx.BindAndValidate(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
@@ -48,6 +44,11 @@ func HandlePlaceOrder(c context.Context, ctx *app.RequestContext) {
 	}
 	resp, err := PlaceOrderViaWs(c, req)
 	if err != nil {
+		var rej *PreTradeRejection
+		if errors.As(err, &rej) {
+			ctx.JSON(http.StatusForbidden, utils.H{"error": err.Error(), "code": rej.Code})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
 		return
 	}
@@ -318,10 +319,20 @@ func HandleGetRiskStatus(c context.Context, ctx *app.RequestContext) {
 	ctx.JSON(http.StatusOK, utils.H{"data": GetRiskStatus()})
 }
 
-// HandleUnlockRisk POST /api/risk/unlock
+// HandleUnlockRisk POST /api/risk/unlock?level=strategy&name=strategy_scalp
+// 不带 level 时解锁全部（含分层预算）；币种节点 name 为 来源:币种，如 strategy_scalp:BTCUSDT
 func HandleUnlockRisk(c context.Context, ctx *app.RequestContext) {
-	UnlockRisk()
-	ctx.JSON(http.StatusOK, utils.H{"message": "risk control unlocked"})
+	level := ctx.Query("level")
+	if level == "" {
+		UnlockRisk()
+		ctx.JSON(http.StatusOK, utils.H{"message": "risk control unlocked"})
+		return
+	}
+	if !UnlockRiskBudget(level, ctx.Query("name")) {
+		ctx.JSON(http.StatusNotFound, utils.H{"error": "no locked risk budget for " + level + " " + ctx.Query("name")})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"message": "risk budget unlocked"})
 }
 
 // HandleGetPortfolioStatus GET /risk/portfolio
@@ -599,10 +610,23 @@ func HandleUpdateStrategyLinkRules(c context.Context, ctx *app.RequestContext) {
 		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
 		return
 	}
-	UpdateStrategyLinkRules(req.Rules)
+	if err := UpdateStrategyLinkRules(req.Rules); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
 	ctx.JSON(http.StatusOK, utils.H{"message": "rules updated", "ruleCount": len(req.Rules)})
 }
 
+// HandleGetStrategyLinkRules GET /api/link/rules
+func HandleGetStrategyLinkRules(c context.Context, ctx *app.RequestContext) {
+	rules, err := LoadStrategyLinkRules()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": rules})
+}
+
 // ========== 支撑/阻力位 ==========
 
 // HandleGetSRLevels GET /api/sr/levels?symbol=ETHUSDT
@@ -721,6 +745,18 @@ func HandleGetNewsPage(c context.Context, ctx *app.RequestContext) {
 	ctx.JSON(http.StatusOK, utils.H{"data": GetNewsPage(source, page, pageSize)})
 }
 
+// HandleGetSymbolNews GET /tool/news/symbol/:symbol?limit=50
+func HandleGetSymbolNews(c context.Context, ctx *app.RequestContext) {
+	_ = c
+	symbol := ctx.Param("symbol")
+	if symbol == "" {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "symbol is required"})
+		return
+	}
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	ctx.JSON(http.StatusOK, utils.H{"data": GetSymbolNews(symbol, limit)})
+}
+
 // ========== 爆仓级联策略 ==========
 
 // HandleStartLiqCascade POST /tool/liq-cascade/start
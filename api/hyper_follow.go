@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,22 +24,51 @@ const (
 type HyperFollowConfig struct {
 	Address       string `json:"address"`
 	Symbol        string `json:"symbol"`
-	QuoteQuantity string `json:"quoteQuantity"`
+	QuoteQuantity string `json:"quoteQuantity"` // fixed 模式下每笔保证金
 	Leverage      int    `json:"leverage"`
+
+	// 多币种映射：Hyperliquid coin → Binance 交易对，如 {"BTC":"BTCUSDT","kPEPE":"1000PEPEUSDT"}
+	// 为空时只跟随 Symbol 对应的币种
+	SymbolMap map[string]string `json:"symbolMap,omitempty"`
+
+	// 仓位计算：fixed（默认）/ ratio / proportional
+	SizingMode string  `json:"sizingMode,omitempty"`
+	Ratio      float64 `json:"ratio,omitempty"` // ratio：带单名义价值 × ratio；proportional：额外缩放系数（默认 1）
+
+	// 我方风险预算（对所有模式生效）
+	MaxOrderNotional float64 `json:"maxOrderNotional,omitempty"` // 单笔跟单名义价值上限（USDT）
+	MaxPositionPct   float64 `json:"maxPositionPct,omitempty"`   // 单币持仓名义价值占我方权益的上限（%）
+
+	// 带单者回撤熔断：权益自峰值回撤超过该比例后暂停跟随开仓
+	MaxLeaderDrawdownPct float64 `json:"maxLeaderDrawdownPct,omitempty"`
 }
 
 // HyperFollowStatus 服务端跟单状态
 type HyperFollowStatus struct {
-	Address       string `json:"address"`
-	Symbol        string `json:"symbol"`
-	QuoteQuantity string `json:"quoteQuantity"`
-	Leverage      int    `json:"leverage"`
-	Enabled       bool   `json:"enabled"`
-	Connected     bool   `json:"connected"`
-	ExecutedCount int64  `json:"executedCount"`
-	FailedCount   int64  `json:"failedCount"`
-	LastError     string `json:"lastError,omitempty"`
-	UpdatedAt     int64  `json:"updatedAt"`
+	Address       string            `json:"address"`
+	Symbol        string            `json:"symbol"`
+	SymbolMap     map[string]string `json:"symbolMap,omitempty"`
+	QuoteQuantity string            `json:"quoteQuantity"`
+	Leverage      int               `json:"leverage"`
+	SizingMode    string            `json:"sizingMode"`
+	Ratio         float64           `json:"ratio,omitempty"`
+	Enabled       bool              `json:"enabled"`
+	Connected     bool              `json:"connected"`
+	ExecutedCount int64             `json:"executedCount"`
+	FailedCount   int64             `json:"failedCount"`
+	SkippedCount  int64             `json:"skippedCount"`
+	LastError     string            `json:"lastError,omitempty"`
+	UpdatedAt     int64             `json:"updatedAt"`
+
+	LeaderEquity      float64 `json:"leaderEquity,omitempty"`
+	LeaderPeakEquity  float64 `json:"leaderPeakEquity,omitempty"`
+	LeaderDrawdownPct float64 `json:"leaderDrawdownPct"`
+	Cutoff            bool    `json:"cutoff"`
+	CutoffReason      string  `json:"cutoffReason,omitempty"`
+
+	// 最近跟单记录的延迟/滑点均值，完整报告见 /hyper/follow/fidelity
+	AvgLatencyMs   float64 `json:"avgLatencyMs"`
+	AvgSlippageBps float64 `json:"avgSlippageBps"`
 }
 
 type hyperFollowManager struct {
@@ -54,10 +84,23 @@ type hyperFollowTask struct {
 	lastError    string
 	executed     int64
 	failed       int64
+	skipped      int64
 	updatedAt    time.Time
 	stopC        chan struct{}
 	stopOnce     sync.Once
 	seenFillKeys map[string]int64
+
+	// 带单者权益与回撤熔断
+	leaderEq       float64
+	leaderEqAt     time.Time
+	leaderPeak     float64
+	leaderDrawdown float64
+	cutoff         bool
+	cutoffReason   string
+
+	// 跟单保真度记录（内存环形缓冲，DB 不可用时报告使用）
+	startedAt time.Time
+	records   []HyperFollowRecord
 }
 
 var hyperFollowMgr = &hyperFollowManager{
@@ -76,13 +119,46 @@ func normalizeHyperFollowConfig(cfg HyperFollowConfig) (HyperFollowConfig, error
 		cfg.Symbol = hyperFollowDefaultSymbol
 	}
 
+	if len(cfg.SymbolMap) > 0 {
+		mapping := make(map[string]string, len(cfg.SymbolMap))
+		for coin, symbol := range cfg.SymbolMap {
+			coin = strings.ToUpper(strings.TrimSpace(coin))
+			symbol = strings.ToUpper(strings.TrimSpace(symbol))
+			if coin == "" || symbol == "" {
+				return cfg, fmt.Errorf("symbolMap entries must have both coin and symbol")
+			}
+			mapping[coin] = symbol
+		}
+		cfg.SymbolMap = mapping
+	}
+
+	cfg.SizingMode = strings.ToLower(strings.TrimSpace(cfg.SizingMode))
+	switch cfg.SizingMode {
+	case "":
+		cfg.SizingMode = HyperSizingFixed
+	case HyperSizingFixed, HyperSizingRatio, HyperSizingProportional:
+	default:
+		return cfg, fmt.Errorf("sizingMode must be fixed, ratio or proportional")
+	}
+
 	cfg.QuoteQuantity = strings.TrimSpace(cfg.QuoteQuantity)
-	if cfg.QuoteQuantity == "" {
-		return cfg, fmt.Errorf("quoteQuantity is required")
+	if cfg.SizingMode == HyperSizingFixed {
+		if cfg.QuoteQuantity == "" {
+			return cfg, fmt.Errorf("quoteQuantity is required")
+		}
+		quoteQty, err := strconv.ParseFloat(cfg.QuoteQuantity, 64)
+		if err != nil || quoteQty <= 0 {
+			return cfg, fmt.Errorf("quoteQuantity must be > 0")
+		}
 	}
-	quoteQty, err := strconv.ParseFloat(cfg.QuoteQuantity, 64)
-	if err != nil || quoteQty <= 0 {
-		return cfg, fmt.Errorf("quoteQuantity must be > 0")
+	if cfg.SizingMode == HyperSizingRatio && cfg.Ratio <= 0 {
+		return cfg, fmt.Errorf("ratio must be > 0 for ratio sizing")
+	}
+	if cfg.Ratio < 0 || cfg.MaxOrderNotional < 0 || cfg.MaxPositionPct < 0 {
+		return cfg, fmt.Errorf("ratio, maxOrderNotional and maxPositionPct must be >= 0")
+	}
+	if cfg.MaxLeaderDrawdownPct < 0 || cfg.MaxLeaderDrawdownPct >= 100 {
+		return cfg, fmt.Errorf("maxLeaderDrawdownPct must be in [0, 100)")
 	}
 
 	if cfg.Leverage <= 0 {
@@ -173,6 +249,7 @@ func newHyperFollowTask(cfg HyperFollowConfig) *hyperFollowTask {
 	return &hyperFollowTask{
 		cfg:          cfg,
 		updatedAt:    now,
+		startedAt:    now,
 		stopC:        make(chan struct{}),
 		seenFillKeys: make(map[string]int64, 2048),
 	}
@@ -181,9 +258,15 @@ func newHyperFollowTask(cfg HyperFollowConfig) *hyperFollowTask {
 func (t *hyperFollowTask) updateConfig(cfg HyperFollowConfig) {
 	t.mu.Lock()
 	t.cfg = cfg
+	// 重新提交配置视为人工确认：解除熔断，回撤从当前权益重新计算
+	t.cutoff = false
+	t.cutoffReason = ""
+	t.leaderPeak = t.leaderEq
+	t.leaderDrawdown = 0
 	t.updatedAt = time.Now()
 	t.mu.Unlock()
-	log.Printf("[HyperFollow] Updated config for %s: symbol=%s qty=%s lev=%d", cfg.Address, cfg.Symbol, cfg.QuoteQuantity, cfg.Leverage)
+	log.Printf("[HyperFollow] Updated config for %s: symbol=%s mode=%s qty=%s ratio=%.4f lev=%d",
+		cfg.Address, cfg.Symbol, cfg.SizingMode, cfg.QuoteQuantity, cfg.Ratio, cfg.Leverage)
 }
 
 func (t *hyperFollowTask) stop() {
@@ -196,17 +279,38 @@ func (t *hyperFollowTask) snapshot() HyperFollowStatus {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
+	fidelity := buildHyperFollowFidelity(t.records)
+
+	var symbolMap map[string]string
+	if len(t.cfg.SymbolMap) > 0 {
+		symbolMap = make(map[string]string, len(t.cfg.SymbolMap))
+		for k, v := range t.cfg.SymbolMap {
+			symbolMap[k] = v
+		}
+	}
+
 	return HyperFollowStatus{
-		Address:       t.cfg.Address,
-		Symbol:        t.cfg.Symbol,
-		QuoteQuantity: t.cfg.QuoteQuantity,
-		Leverage:      t.cfg.Leverage,
-		Enabled:       true,
-		Connected:     t.connected,
-		ExecutedCount: t.executed,
-		FailedCount:   t.failed,
-		LastError:     t.lastError,
-		UpdatedAt:     t.updatedAt.UnixMilli(),
+		Address:           t.cfg.Address,
+		Symbol:            t.cfg.Symbol,
+		SymbolMap:         symbolMap,
+		QuoteQuantity:     t.cfg.QuoteQuantity,
+		Leverage:          t.cfg.Leverage,
+		SizingMode:        t.cfg.SizingMode,
+		Ratio:             t.cfg.Ratio,
+		Enabled:           true,
+		Connected:         t.connected,
+		ExecutedCount:     t.executed,
+		FailedCount:       t.failed,
+		SkippedCount:      t.skipped,
+		LastError:         t.lastError,
+		UpdatedAt:         t.updatedAt.UnixMilli(),
+		LeaderEquity:      t.leaderEq,
+		LeaderPeakEquity:  t.leaderPeak,
+		LeaderDrawdownPct: t.leaderDrawdown,
+		Cutoff:            t.cutoff,
+		CutoffReason:      t.cutoffReason,
+		AvgLatencyMs:      fidelity.Latency.AvgMs,
+		AvgSlippageBps:    fidelity.Slippage.AvgBps,
 	}
 }
 
@@ -245,11 +349,27 @@ func (t *hyperFollowTask) markFailed(err error) {
 	t.mu.Unlock()
 }
 
+func (t *hyperFollowTask) markSkipped(reason string) {
+	t.mu.Lock()
+	t.skipped++
+	t.lastError = reason
+	t.updatedAt = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *hyperFollowTask) isCutoff() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.cutoff
+}
+
 func (t *hyperFollowTask) run() {
 	cfg := t.snapshot()
 	log.Printf("[HyperFollow] Started for %s (symbol=%s)", cfg.Address, cfg.Symbol)
 	defer log.Printf("[HyperFollow] Stopped for %s", cfg.Address)
 
+	go t.equityLoop()
+
 	for {
 		select {
 		case <-t.stopC:
@@ -370,7 +490,8 @@ func (t *hyperFollowTask) handleUpstreamMessage(raw []byte) {
 
 func (t *hyperFollowTask) handleFills(fills []map[string]interface{}) {
 	for _, fill := range fills {
-		if !t.isTargetCoinFill(fill) {
+		symbol := t.resolveSymbol(fill)
+		if symbol == "" {
 			continue
 		}
 
@@ -383,23 +504,51 @@ func (t *hyperFollowTask) handleFills(fills []map[string]interface{}) {
 		action := fillAction(fill)
 		side := orderSideFromFill(fill)
 		positionSide := positionSideFromFill(fill, action, side)
+		rec := newHyperFollowRecord(t.getConfig().Address, symbol, fillKey, action, fill)
 
 		switch action {
 		case "open":
 			if side == "" {
 				continue
 			}
-			t.executeOpen(fill, side, positionSide)
+			t.executeOpen(fill, symbol, side, positionSide, math.Abs(parseAnyFloat(fill["sz"])), rec)
 		case "close":
-			t.executeClose(fill, positionSide)
+			t.executeClose(fill, symbol, positionSide, hyperReduceFraction(fill), rec)
+		case "flip":
+			t.executeFlip(fill, symbol, side, rec)
 		}
 	}
 }
 
-func (t *hyperFollowTask) executeOpen(fill map[string]interface{}, side, positionSide string) {
-	if err := CheckRisk(); err != nil {
-		t.markFailed(err)
-		SaveFailedOperation("HYPER_FOLLOW_OPEN", hyperFollowSource, t.getConfig().Symbol, fill, 0, err)
+// executeFlip 带单者一笔成交完成反手（Long > Short / Short > Long）：先全平旧方向，再按超出原持仓的部分开新方向
+func (t *hyperFollowTask) executeFlip(fill map[string]interface{}, symbol, side string, rec *HyperFollowRecord) {
+	if side == "" {
+		return
+	}
+	oldSide, newSide := "SHORT", "LONG"
+	if side == "SELL" {
+		oldSide, newSide = "LONG", "SHORT"
+	}
+	startSz := math.Abs(parseAnyFloat(fill["startPosition"]))
+	closeRec, openRec := *rec, *rec
+	closeRec.Action, closeRec.LeaderSize = "flip_close", startSz
+	openRec.Action, openRec.LeaderClosedPnl = "flip_open", 0
+	t.executeClose(fill, symbol, oldSide, 1, &closeRec)
+
+	openSz := math.Abs(parseAnyFloat(fill["sz"])) - startSz
+	if openSz <= 0 {
+		return
+	}
+	t.executeOpen(fill, symbol, side, newSide, openSz, &openRec)
+}
+
+func (t *hyperFollowTask) executeOpen(fill map[string]interface{}, symbol, side, positionSide string, leaderSz float64, rec *HyperFollowRecord) {
+	rec.Side, rec.PositionSide, rec.LeaderSize = side, positionSide, leaderSz
+	defer t.recordFollow(rec)
+
+	if t.isCutoff() {
+		t.markSkipped("cutoff: skip open " + symbol)
+		rec.Status, rec.Error = hyperFollowRecordSkipped, "cutoff"
 		return
 	}
 
@@ -407,12 +556,21 @@ func (t *hyperFollowTask) executeOpen(fill map[string]interface{}, side, positio
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
+	sizing, err := t.sizeOpen(ctx, cfg, symbol, positionSide, leaderSz*parseAnyFloat(fill["px"]))
+	if err != nil {
+		t.markSkipped(err.Error())
+		rec.Status, rec.Error = hyperFollowRecordSkipped, err.Error()
+		log.Printf("[HyperFollow] Skip open for %s %s: %v", cfg.Address, symbol, err)
+		return
+	}
+	quote := strconv.FormatFloat(sizing.Quote, 'f', 4, 64)
+
 	req := PlaceOrderReq{
 		Source:        hyperFollowSource,
-		Symbol:        cfg.Symbol,
+		Symbol:        symbol,
 		Side:          futures.SideType(side),
 		OrderType:     futures.OrderTypeMarket,
-		QuoteQuantity: cfg.QuoteQuantity,
+		QuoteQuantity: quote,
 		Leverage:      cfg.Leverage,
 		PositionSide:  futures.PositionSideType(positionSide),
 	}
@@ -420,6 +578,7 @@ func (t *hyperFollowTask) executeOpen(fill map[string]interface{}, side, positio
 	result, err := PlaceOrderViaWs(ctx, req)
 	if err != nil {
 		t.markFailed(err)
+		rec.Status, rec.Error = hyperFollowRecordFailed, err.Error()
 		log.Printf("[HyperFollow] Open failed for %s: %v", cfg.Address, err)
 		return
 	}
@@ -428,40 +587,91 @@ func (t *hyperFollowTask) executeOpen(fill map[string]interface{}, side, positio
 	orderID := int64(0)
 	if result != nil && result.Order != nil {
 		orderID = result.Order.OrderID
+		rec.fillFromOrder(result.Order)
 	}
-	log.Printf("[HyperFollow] Open executed for %s: %s %s %s (%sU %dx), orderId=%d",
+	log.Printf("[HyperFollow] Open executed for %s: %s %s %s (%sU %dx, mode=%s capped=%s), orderId=%d",
 		cfg.Address,
-		cfg.Symbol,
+		symbol,
 		positionSide,
 		side,
-		cfg.QuoteQuantity,
+		quote,
 		cfg.Leverage,
+		cfg.SizingMode,
+		sizing.Capped,
 		orderID,
 	)
 
 }
 
-func (t *hyperFollowTask) executeClose(fill map[string]interface{}, positionSide string) {
+// sizeOpen 收集权益/持仓后计算本次跟单保证金
+func (t *hyperFollowTask) sizeOpen(ctx context.Context, cfg HyperFollowConfig, symbol, positionSide string, leaderNotional float64) (hyperSizingResult, error) {
+	in := hyperSizingInput{LeaderNotional: leaderNotional}
+	if cfg.SizingMode == HyperSizingProportional {
+		equity, err := t.leaderEquity()
+		if err != nil {
+			return hyperSizingResult{}, fmt.Errorf("leader equity: %w", err)
+		}
+		in.LeaderEquity = equity
+	}
+	if cfg.SizingMode == HyperSizingProportional || cfg.MaxPositionPct > 0 {
+		equity, err := hyperFollowOurEquity(ctx)
+		if err != nil {
+			return hyperSizingResult{}, fmt.Errorf("own equity: %w", err)
+		}
+		in.OurEquity = equity
+	}
+	if cfg.MaxPositionPct > 0 {
+		in.OurExposure = hyperFollowExposure(ctx, symbol, futures.PositionSideType(positionSide))
+	}
+	return calcHyperFollowQuote(cfg, in)
+}
+
+// executeClose 按带单者的减仓比例同步减仓，fraction ≥ 0.999 时全平
+func (t *hyperFollowTask) executeClose(fill map[string]interface{}, symbol, positionSide string, fraction float64, rec *HyperFollowRecord) {
+	rec.PositionSide = positionSide
+	rec.Side = "SELL"
+	if positionSide == "SHORT" {
+		rec.Side = "BUY"
+	}
+	defer t.recordFollow(rec)
+
 	cfg := t.getConfig()
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	_, err := ClosePositionViaWs(ctx, ClosePositionReq{
-		Symbol:       cfg.Symbol,
-		PositionSide: futures.PositionSideType(positionSide),
-	})
+	// 平仓前记下我方开仓均价，用于计算本次平仓盈亏
+	entryPrice := hyperFollowEntryPrice(ctx, symbol, futures.PositionSideType(positionSide))
+
+	var order *futures.CreateOrderResponse
+	var err error
+	if fraction >= 0.999 {
+		order, err = ClosePositionViaWs(ctx, ClosePositionReq{
+			Symbol:       symbol,
+			PositionSide: futures.PositionSideType(positionSide),
+		})
+	} else {
+		order, err = ReducePositionViaWs(ctx, ReducePositionReq{
+			Symbol:       symbol,
+			PositionSide: futures.PositionSideType(positionSide),
+			Percent:      fraction * 100,
+		})
+	}
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "no open position") {
+			rec.Status, rec.Error = hyperFollowRecordSkipped, "no open position"
 			return
 		}
 		t.markFailed(err)
-		SaveFailedOperation("HYPER_FOLLOW_CLOSE", hyperFollowSource, cfg.Symbol, fill, 0, err)
+		rec.Status, rec.Error = hyperFollowRecordFailed, err.Error()
+		SaveFailedOperation("HYPER_FOLLOW_CLOSE", hyperFollowSource, symbol, fill, 0, err)
 		log.Printf("[HyperFollow] Close failed for %s: %v", cfg.Address, err)
 		return
 	}
 
 	t.markExecuted()
-	log.Printf("[HyperFollow] Close executed for %s: %s %s", cfg.Address, cfg.Symbol, positionSide)
+	rec.fillFromOrder(order)
+	rec.setFollowerPnl(entryPrice)
+	log.Printf("[HyperFollow] Close executed for %s: %s %s (%.1f%%)", cfg.Address, symbol, positionSide, fraction*100)
 
 }
 
@@ -509,21 +719,27 @@ func (t *hyperFollowTask) markFillSeen(fillKey string, ts int64) bool {
 	return false
 }
 
-// isTargetCoinFill 根据配置的 Symbol 动态匹配 fill 的 coin 字段
+// resolveSymbol 把 fill 的 coin 映射到 Binance 交易对，不跟随的币种返回空
+// 配置了 SymbolMap 时按 coin 精确匹配；否则沿用 Symbol 的前缀匹配，
 // 例如 Symbol="BTCUSDT" 匹配 coin 前缀 "BTC"
-// Symbol="ETHUSDT" 匹配 coin 前缀 "ETH"
-func (t *hyperFollowTask) isTargetCoinFill(fill map[string]interface{}) bool {
-	coin := strings.ToUpper(strings.TrimSpace(parseAnyString(fill["coin"])))
+func (t *hyperFollowTask) resolveSymbol(fill map[string]interface{}) string {
+	return resolveHyperFollowSymbol(t.getConfig(), parseAnyString(fill["coin"]))
+}
+
+func resolveHyperFollowSymbol(cfg HyperFollowConfig, coin string) string {
+	coin = strings.ToUpper(strings.TrimSpace(coin))
 	if coin == "" {
-		return false
+		return ""
+	}
+	if len(cfg.SymbolMap) > 0 {
+		return cfg.SymbolMap[coin]
 	}
-	cfg := t.getConfig()
 	// 从 Symbol 中提取基础币种（去掉 USDT/BUSD/USDC 等后缀）
 	target := extractBaseCoin(cfg.Symbol)
-	if target == "" {
-		return false
+	if target == "" || !strings.HasPrefix(coin, target) {
+		return ""
 	}
-	return strings.HasPrefix(coin, target)
+	return cfg.Symbol
 }
 
 // extractBaseCoin 从交易对符号中提取基础币种
@@ -543,6 +759,10 @@ func extractBaseCoin(symbol string) string {
 
 func fillAction(fill map[string]interface{}) string {
 	dir := strings.ToLower(parseAnyString(fill["dir"]))
+	// 反手：Long > Short / Short > Long
+	if strings.Contains(dir, ">") {
+		return "flip"
+	}
 	if strings.Contains(dir, "open") {
 		return "open"
 	}
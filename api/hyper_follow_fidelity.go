@@ -0,0 +1,417 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// 跟单记录状态
+const (
+	hyperFollowRecordExecuted = "executed"
+	hyperFollowRecordFailed   = "failed"
+	hyperFollowRecordSkipped  = "skipped"
+)
+
+const hyperFollowRecordCap = 1000
+
+// HyperFollowRecord 一笔带单成交与我方镜像订单的对照记录
+type HyperFollowRecord struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Address      string `gorm:"type:varchar(42);index" json:"address"`
+	Symbol       string `gorm:"type:varchar(20);index" json:"symbol"`
+	Coin         string `gorm:"type:varchar(20)" json:"coin"`
+	FillKey      string `gorm:"type:varchar(200);index" json:"fillKey"`
+	Action       string `gorm:"type:varchar(20)" json:"action"` // open / close / flip_close / flip_open
+	Side         string `gorm:"type:varchar(10)" json:"side"`   // 我方下单方向 BUY / SELL
+	PositionSide string `gorm:"type:varchar(10)" json:"positionSide"`
+	Status       string `gorm:"type:varchar(20);index" json:"status"` // executed / failed / skipped
+	Error        string `gorm:"type:text" json:"error,omitempty"`
+
+	// 带单者成交
+	LeaderTime      int64   `json:"leaderTime"` // 带单者成交时间（ms）
+	LeaderPrice     float64 `gorm:"type:numeric(36,8)" json:"leaderPrice"`
+	LeaderSize      float64 `gorm:"type:numeric(36,8)" json:"leaderSize"`
+	LeaderClosedPnl float64 `gorm:"type:numeric(36,8)" json:"leaderClosedPnl"`
+	LeaderReturnPct float64 `gorm:"type:numeric(36,8)" json:"leaderReturnPct"` // 平仓腿收益率（%）
+
+	// 我方镜像订单
+	ReceivedAt        int64   `json:"receivedAt"` // 收到推送时间（ms）
+	FilledAt          int64   `json:"filledAt,omitempty"`
+	OrderID           int64   `gorm:"index" json:"orderId,omitempty"`
+	OurPrice          float64 `gorm:"type:numeric(36,8)" json:"ourPrice"`
+	OurQty            float64 `gorm:"type:numeric(36,8)" json:"ourQty"`
+	OurEntryPrice     float64 `gorm:"type:numeric(36,8)" json:"ourEntryPrice,omitempty"`
+	OurPnl            float64 `gorm:"type:numeric(36,8)" json:"ourPnl"`
+	FollowerReturnPct float64 `gorm:"type:numeric(36,8)" json:"followerReturnPct"`
+
+	LatencyMs   int64   `json:"latencyMs"`   // 带单者成交 → 我方成交
+	SlippageBps float64 `json:"slippageBps"` // 相对带单者成交价的不利滑点（正为吃亏）
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// HyperFollowFidelityReport 跟单保真度报告
+type HyperFollowFidelityReport struct {
+	Address     string              `json:"address"`
+	Days        int                 `json:"days"`
+	From        time.Time           `json:"from"`
+	LeaderFills int                 `json:"leaderFills"` // 应跟随的带单成交数（含漏收）
+	Mirrored    int                 `json:"mirrored"`
+	Failed      int                 `json:"failed"`
+	Skipped     int                 `json:"skipped"`
+	Missed      int                 `json:"missed"` // 带单者有成交但我方完全没收到（断线等）
+	MissRate    float64             `json:"missRate"`
+	MissedCheck string              `json:"missedCheck,omitempty"`
+	Latency     HyperLatencyStats   `json:"latency"`
+	Slippage    HyperSlippageStats  `json:"slippage"`
+	Pnl         HyperTrackingStats  `json:"pnl"`
+	Recent      []HyperFollowRecord `json:"recent"`
+}
+
+// HyperLatencyStats 跟单延迟分布（ms）
+type HyperLatencyStats struct {
+	Samples int     `json:"samples"`
+	AvgMs   float64 `json:"avgMs"`
+	P50Ms   float64 `json:"p50Ms"`
+	P90Ms   float64 `json:"p90Ms"`
+	P99Ms   float64 `json:"p99Ms"`
+	MaxMs   float64 `json:"maxMs"`
+}
+
+// HyperSlippageStats 相对带单者的价格滑点
+type HyperSlippageStats struct {
+	Samples  int     `json:"samples"`
+	AvgBps   float64 `json:"avgBps"`
+	P50Bps   float64 `json:"p50Bps"`
+	P95Bps   float64 `json:"p95Bps"`
+	CostUSDT float64 `json:"costUSDT"` // 滑点成本合计
+}
+
+// HyperTrackingStats 平仓腿收益率跟踪误差
+type HyperTrackingStats struct {
+	Samples          int     `json:"samples"`
+	LeaderPnl        float64 `json:"leaderPnl"`
+	FollowerPnl      float64 `json:"followerPnl"`
+	MeanDiffPct      float64 `json:"meanDiffPct"`      // 我方收益率 - 带单者收益率 的均值
+	TrackingErrorPct float64 `json:"trackingErrorPct"` // 收益率差的标准差
+}
+
+func newHyperFollowRecord(address, symbol, fillKey, action string, fill map[string]interface{}) *HyperFollowRecord {
+	return &HyperFollowRecord{
+		Address:         address,
+		Symbol:          symbol,
+		Coin:            parseAnyString(fill["coin"]),
+		FillKey:         fillKey,
+		Action:          action,
+		LeaderTime:      parseAnyInt64(fill["time"]),
+		LeaderPrice:     parseAnyFloat(fill["px"]),
+		LeaderSize:      math.Abs(parseAnyFloat(fill["sz"])),
+		LeaderClosedPnl: parseAnyFloat(fill["closedPnl"]),
+		ReceivedAt:      time.Now().UnixMilli(),
+	}
+}
+
+// fillFromOrder 填入我方成交结果，并计算延迟与滑点
+func (r *HyperFollowRecord) fillFromOrder(order *futures.CreateOrderResponse) {
+	r.Status = hyperFollowRecordExecuted
+	r.FilledAt = time.Now().UnixMilli()
+	if r.LeaderTime > 0 {
+		r.LatencyMs = r.FilledAt - r.LeaderTime
+	}
+	if order == nil {
+		return
+	}
+	r.OrderID = order.OrderID
+	r.OurPrice = parseNumeric(order.AvgPrice)
+	if r.OurPrice == 0 {
+		r.OurPrice = parseNumeric(order.Price)
+	}
+	r.OurQty = parseNumeric(order.ExecutedQuantity)
+	if r.OurQty == 0 {
+		r.OurQty = parseNumeric(order.OrigQuantity)
+	}
+	if r.LeaderPrice > 0 && r.OurPrice > 0 {
+		diff := (r.OurPrice - r.LeaderPrice) / r.LeaderPrice * 10000
+		if r.Side == "SELL" {
+			diff = -diff
+		}
+		r.SlippageBps = roundFloat(diff, 4)
+	}
+}
+
+// setFollowerPnl 平仓腿：计算我方与带单者的本次收益率
+func (r *HyperFollowRecord) setFollowerPnl(entryPrice float64) {
+	if r.LeaderSize > 0 && r.LeaderPrice > 0 {
+		// 由平仓价与已实现盈亏倒推带单者开仓价
+		perUnit := r.LeaderClosedPnl / r.LeaderSize
+		leaderEntry := r.LeaderPrice - perUnit
+		if r.PositionSide == "SHORT" {
+			leaderEntry = r.LeaderPrice + perUnit
+		}
+		if leaderEntry > 0 {
+			r.LeaderReturnPct = roundFloat(r.LeaderClosedPnl/(r.LeaderSize*leaderEntry)*100, 6)
+		}
+	}
+	if entryPrice <= 0 || r.OurPrice <= 0 || r.OurQty <= 0 {
+		return
+	}
+	r.OurEntryPrice = entryPrice
+	pnl := (r.OurPrice - entryPrice) * r.OurQty
+	if r.PositionSide == "SHORT" {
+		pnl = -pnl
+	}
+	r.OurPnl = roundFloat(pnl, 8)
+	r.FollowerReturnPct = roundFloat(pnl/(r.OurQty*entryPrice)*100, 6)
+}
+
+// hyperFollowEntryPrice 我方当前持仓均价（无持仓返回 0）
+func hyperFollowEntryPrice(ctx context.Context, symbol string, positionSide futures.PositionSideType) float64 {
+	if IsDryRun() {
+		for _, p := range GetPaperPositions() {
+			if p.Symbol == symbol && (positionSide == futures.PositionSideTypeBoth || string(positionSide) == p.Side) {
+				return p.EntryPrice
+			}
+		}
+		return 0
+	}
+	pos, err := findPosition(ctx, symbol, positionSide)
+	if err != nil || pos == nil {
+		return 0
+	}
+	return parseNumeric(pos.EntryPrice)
+}
+
+// recordFollow 保存对照记录；成交的记录以带单者成交价为基准写入执行质量
+func (t *hyperFollowTask) recordFollow(rec *HyperFollowRecord) {
+	if rec == nil {
+		return
+	}
+	if rec.Status == "" {
+		rec.Status = hyperFollowRecordExecuted
+	}
+	t.mu.Lock()
+	t.records = append(t.records, *rec)
+	if len(t.records) > hyperFollowRecordCap {
+		t.records = t.records[len(t.records)-hyperFollowRecordCap:]
+	}
+	t.mu.Unlock()
+
+	saved := *rec
+	go func() {
+		if DB != nil {
+			if err := DB.Create(&saved).Error; err != nil {
+				log.Printf("[HyperFollow] Save follow record failed for %s: %v", saved.Address, err)
+			}
+		}
+		if saved.Status == hyperFollowRecordExecuted && saved.OrderID > 0 && !IsDryRun() {
+			RecordExecutionQuality(saved.Symbol, strconv.FormatInt(saved.OrderID, 10), saved.Side, hyperFollowSource,
+				saved.LeaderPrice, saved.OurPrice, saved.OurQty, saved.LatencyMs)
+		}
+	}()
+}
+
+// GetHyperFollowFidelity 生成跟单保真度报告
+func GetHyperFollowFidelity(address string, days int) (*HyperFollowFidelityReport, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if !reAddress.MatchString(address) {
+		return nil, fmt.Errorf("address is invalid")
+	}
+	if days <= 0 {
+		days = 7
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	hyperFollowMgr.mu.RLock()
+	task := hyperFollowMgr.tasks[address]
+	hyperFollowMgr.mu.RUnlock()
+
+	var records []HyperFollowRecord
+	if DB != nil {
+		if err := DB.Where("address = ? AND created_at >= ?", address, since).
+			Order("created_at ASC").Limit(5000).Find(&records).Error; err != nil {
+			return nil, err
+		}
+	} else if task != nil {
+		task.mu.RLock()
+		for _, r := range task.records {
+			if r.ReceivedAt >= since.UnixMilli() {
+				records = append(records, r)
+			}
+		}
+		task.mu.RUnlock()
+	}
+
+	report := buildHyperFollowFidelity(records)
+	report.Address = address
+	report.Days = days
+	report.From = since
+
+	// 漏收检测：对比带单者在跟单期间的全部成交
+	if task != nil {
+		missed, err := countHyperMissedFills(task, records, since)
+		if err != nil {
+			report.MissedCheck = err.Error()
+		} else {
+			report.Missed = missed
+			report.LeaderFills += missed
+		}
+	}
+	if report.LeaderFills > 0 {
+		report.MissRate = roundFloat(float64(report.Missed+report.Failed)/float64(report.LeaderFills), 4)
+	}
+	return report, nil
+}
+
+// buildHyperFollowFidelity 按对照记录统计延迟、滑点与收益跟踪误差
+func buildHyperFollowFidelity(records []HyperFollowRecord) *HyperFollowFidelityReport {
+	report := &HyperFollowFidelityReport{}
+	keys := make(map[string]bool)
+	var latencies, slippages, diffs []float64
+	for _, r := range records {
+		if r.FillKey == "" || !keys[r.FillKey] {
+			report.LeaderFills++
+			if r.FillKey != "" {
+				keys[r.FillKey] = true
+			}
+		}
+		switch r.Status {
+		case hyperFollowRecordFailed:
+			report.Failed++
+			continue
+		case hyperFollowRecordSkipped:
+			report.Skipped++
+			continue
+		}
+		report.Mirrored++
+		if r.LatencyMs > 0 {
+			latencies = append(latencies, float64(r.LatencyMs))
+		}
+		if r.LeaderPrice > 0 && r.OurPrice > 0 {
+			slippages = append(slippages, r.SlippageBps)
+			report.Slippage.CostUSDT += r.SlippageBps / 10000 * r.OurPrice * r.OurQty
+		}
+		if strings.HasSuffix(r.Action, "close") && r.OurEntryPrice > 0 {
+			report.Pnl.Samples++
+			report.Pnl.LeaderPnl += r.LeaderClosedPnl
+			report.Pnl.FollowerPnl += r.OurPnl
+			diffs = append(diffs, r.FollowerReturnPct-r.LeaderReturnPct)
+		}
+	}
+
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		report.Latency = HyperLatencyStats{
+			Samples: len(latencies),
+			AvgMs:   roundFloat(hyperMean(latencies), 2),
+			P50Ms:   roundFloat(percentile(latencies, 50), 2),
+			P90Ms:   roundFloat(percentile(latencies, 90), 2),
+			P99Ms:   roundFloat(percentile(latencies, 99), 2),
+			MaxMs:   latencies[len(latencies)-1],
+		}
+	}
+	if len(slippages) > 0 {
+		sort.Float64s(slippages)
+		report.Slippage.Samples = len(slippages)
+		report.Slippage.AvgBps = roundFloat(hyperMean(slippages), 4)
+		report.Slippage.P50Bps = roundFloat(percentile(slippages, 50), 4)
+		report.Slippage.P95Bps = roundFloat(percentile(slippages, 95), 4)
+	}
+	report.Slippage.CostUSDT = roundFloat(report.Slippage.CostUSDT, 4)
+	if len(diffs) > 0 {
+		mean := hyperMean(diffs)
+		var variance float64
+		for _, d := range diffs {
+			variance += (d - mean) * (d - mean)
+		}
+		if len(diffs) > 1 {
+			variance /= float64(len(diffs) - 1)
+		}
+		report.Pnl.MeanDiffPct = roundFloat(mean, 6)
+		report.Pnl.TrackingErrorPct = roundFloat(math.Sqrt(variance), 6)
+	}
+	report.Pnl.LeaderPnl = roundFloat(report.Pnl.LeaderPnl, 4)
+	report.Pnl.FollowerPnl = roundFloat(report.Pnl.FollowerPnl, 4)
+
+	start := len(records) - 20
+	if start < 0 {
+		start = 0
+	}
+	report.Recent = append([]HyperFollowRecord(nil), records[start:]...)
+	return report
+}
+
+// countHyperMissedFills 拉取带单者成交，统计跟单期间应跟随却没有任何记录的成交
+func countHyperMissedFills(task *hyperFollowTask, records []HyperFollowRecord, since time.Time) (int, error) {
+	task.mu.RLock()
+	cfg := task.cfg
+	startedAt := task.startedAt
+	task.mu.RUnlock()
+	if startedAt.After(since) {
+		since = startedAt
+	}
+
+	raw, err := fetchHyperInfo(map[string]any{
+		"type":            "userFillsByTime",
+		"user":            cfg.Address,
+		"startTime":       since.UnixMilli(),
+		"aggregateByTime": true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("fetch leader fills: %w", err)
+	}
+
+	seen := make(map[string]bool, len(records))
+	for _, r := range records {
+		seen[r.FillKey] = true
+	}
+	// 最近 10 秒的成交可能仍在处理中，不计入
+	cutoff := time.Now().Add(-10 * time.Second).UnixMilli()
+	missed := 0
+	for _, f := range toHyperFillList(raw) {
+		if parseAnyInt64(f["time"]) > cutoff || fillAction(f) == "" {
+			continue
+		}
+		if resolveHyperFollowSymbol(cfg, parseAnyString(f["coin"])) == "" {
+			continue
+		}
+		if !seen[makeHyperFillKey(f)] {
+			missed++
+		}
+	}
+	return missed, nil
+}
+
+func hyperMean(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / float64(len(vals))
+}
+
+// HandleGetHyperFollowFidelity GET /tool/hyper/follow/fidelity?address=0x...&days=7
+func HandleGetHyperFollowFidelity(c context.Context, ctx *app.RequestContext) {
+	address := string(ctx.Query("address"))
+	days, _ := strconv.Atoi(strings.TrimSpace(string(ctx.Query("days"))))
+
+	report, err := GetHyperFollowFidelity(address, days)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": report})
+}
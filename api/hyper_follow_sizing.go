@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// 跟单仓位计算模式
+const (
+	HyperSizingFixed        = "fixed"        // 每笔固定保证金（quoteQuantity）
+	HyperSizingRatio        = "ratio"        // 按带单者成交名义价值的固定比例
+	HyperSizingProportional = "proportional" // 按我方/带单者账户权益之比等比缩放
+)
+
+const (
+	hyperLeaderEquityTTL     = 60 * time.Second
+	hyperLeaderEquityRefresh = 60 * time.Second
+	hyperFollowMinQuote      = 0.01 // 低于该保证金（USDT）视为无需跟单
+)
+
+// hyperSizingInput 计算跟单保证金所需的输入
+type hyperSizingInput struct {
+	LeaderNotional float64 // 带单者本次开仓名义价值（sz × px）
+	LeaderEquity   float64 // 带单者账户权益
+	OurEquity      float64 // 我方账户权益
+	OurExposure    float64 // 我方该交易对当前持仓名义价值
+}
+
+// hyperSizingResult 跟单仓位计算结果
+type hyperSizingResult struct {
+	Quote    float64 // 保证金（USDT），即 PlaceOrderReq.QuoteQuantity
+	Notional float64 // 名义价值 = Quote × Leverage
+	Capped   string  // 触发的风险上限：maxOrderNotional / maxPositionPct
+}
+
+// calcHyperFollowQuote 按配置的模式计算跟单保证金，再用我方风险预算（单笔名义上限、单币持仓占权益比例）封顶
+func calcHyperFollowQuote(cfg HyperFollowConfig, in hyperSizingInput) (hyperSizingResult, error) {
+	var res hyperSizingResult
+	lev := float64(cfg.Leverage)
+	if lev <= 0 {
+		return res, fmt.Errorf("leverage must be > 0")
+	}
+
+	var notional float64
+	switch cfg.SizingMode {
+	case HyperSizingRatio:
+		if in.LeaderNotional <= 0 {
+			return res, fmt.Errorf("leader fill notional unavailable")
+		}
+		notional = in.LeaderNotional * cfg.Ratio
+	case HyperSizingProportional:
+		if in.LeaderNotional <= 0 {
+			return res, fmt.Errorf("leader fill notional unavailable")
+		}
+		if in.LeaderEquity <= 0 || in.OurEquity <= 0 {
+			return res, fmt.Errorf("equity unavailable (leader=%.2f, ours=%.2f)", in.LeaderEquity, in.OurEquity)
+		}
+		scale := cfg.Ratio
+		if scale <= 0 {
+			scale = 1
+		}
+		notional = in.LeaderNotional * in.OurEquity / in.LeaderEquity * scale
+	default:
+		quote, _ := strconv.ParseFloat(cfg.QuoteQuantity, 64)
+		notional = quote * lev
+	}
+
+	if cfg.MaxOrderNotional > 0 && notional > cfg.MaxOrderNotional {
+		notional = cfg.MaxOrderNotional
+		res.Capped = "maxOrderNotional"
+	}
+	if cfg.MaxPositionPct > 0 {
+		if in.OurEquity <= 0 {
+			return res, fmt.Errorf("own equity unavailable for position cap")
+		}
+		room := in.OurEquity*cfg.MaxPositionPct/100 - in.OurExposure
+		if room <= 0 {
+			return res, fmt.Errorf("position cap reached: exposure %.2f >= %.2f%% of equity %.2f",
+				in.OurExposure, cfg.MaxPositionPct, in.OurEquity)
+		}
+		if notional > room {
+			notional = room
+			res.Capped = "maxPositionPct"
+		}
+	}
+
+	res.Notional = notional
+	res.Quote = notional / lev
+	if res.Quote < hyperFollowMinQuote {
+		return res, fmt.Errorf("copy size too small: %.4f USDT margin", res.Quote)
+	}
+	return res, nil
+}
+
+// hyperReduceFraction 带单者本次减仓占其原持仓的比例（startPosition 缺失时视为全平）
+func hyperReduceFraction(fill map[string]interface{}) float64 {
+	start := math.Abs(parseAnyFloat(fill["startPosition"]))
+	sz := math.Abs(parseAnyFloat(fill["sz"]))
+	if start <= 0 || sz <= 0 {
+		return 1
+	}
+	return math.Min(sz/start, 1)
+}
+
+// fetchHyperLeaderEquity 查询 Hyperliquid 账户权益（clearinghouseState.marginSummary.accountValue）
+func fetchHyperLeaderEquity(address string) (float64, error) {
+	data, err := fetchHyperInfo(map[string]any{
+		"type": "clearinghouseState",
+		"user": address,
+	})
+	if err != nil {
+		return 0, err
+	}
+	root, ok := data.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("unexpected clearinghouseState payload")
+	}
+	summary, _ := root["marginSummary"].(map[string]interface{})
+	equity := parseAnyFloat(summary["accountValue"])
+	if equity <= 0 {
+		return 0, fmt.Errorf("leader equity unavailable")
+	}
+	return equity, nil
+}
+
+// hyperFollowOurEquity 我方账户权益：模拟盘取模拟余额，实盘取 USDT 余额 + 全仓未实现盈亏
+func hyperFollowOurEquity(ctx context.Context) (float64, error) {
+	if IsDryRun() {
+		return GetPaperBalance(), nil
+	}
+	bal, err := GetBalance(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return parseNumeric(bal["balance"]) + parseNumeric(bal["crossUnPnl"]), nil
+}
+
+// hyperFollowExposure 我方该交易对指定方向的持仓名义价值
+func hyperFollowExposure(ctx context.Context, symbol string, positionSide futures.PositionSideType) float64 {
+	if IsDryRun() {
+		var total float64
+		for _, p := range GetPaperPositions() {
+			if p.Symbol == symbol && (positionSide == "" || positionSide == futures.PositionSideTypeBoth || string(positionSide) == p.Side) {
+				total += p.Quantity * p.EntryPrice
+			}
+		}
+		return total
+	}
+	pos, err := findPosition(ctx, symbol, positionSide)
+	if err != nil || pos == nil {
+		return 0
+	}
+	return math.Abs(parseNumeric(pos.Notional))
+}
+
+// leaderEquity 返回带单者权益（带缓存），必要时同步刷新
+func (t *hyperFollowTask) leaderEquity() (float64, error) {
+	t.mu.RLock()
+	equity, at := t.leaderEq, t.leaderEqAt
+	addr := t.cfg.Address
+	t.mu.RUnlock()
+	if equity > 0 && time.Since(at) < hyperLeaderEquityTTL {
+		return equity, nil
+	}
+	equity, err := fetchHyperLeaderEquity(addr)
+	if err != nil {
+		return 0, err
+	}
+	t.observeLeaderEquity(equity)
+	return equity, nil
+}
+
+// observeLeaderEquity 记录带单者权益并检查回撤熔断：回撤超过阈值后暂停开仓，仅继续跟随减仓/平仓
+func (t *hyperFollowTask) observeLeaderEquity(equity float64) {
+	t.mu.Lock()
+	t.leaderEq = equity
+	t.leaderEqAt = time.Now()
+	if equity > t.leaderPeak {
+		t.leaderPeak = equity
+	}
+	if t.leaderPeak > 0 {
+		t.leaderDrawdown = (t.leaderPeak - equity) / t.leaderPeak * 100
+	}
+	limit := t.cfg.MaxLeaderDrawdownPct
+	tripped := limit > 0 && !t.cutoff && t.leaderDrawdown >= limit
+	if tripped {
+		t.cutoff = true
+		t.cutoffReason = fmt.Sprintf("leader drawdown %.2f%% >= %.2f%%", t.leaderDrawdown, limit)
+		t.updatedAt = time.Now()
+	}
+	addr, reason := t.cfg.Address, t.cutoffReason
+	t.mu.Unlock()
+
+	if tripped {
+		log.Printf("[HyperFollow] Cutoff for %s: %s, stop opening new copies", addr, reason)
+		SendNotify(fmt.Sprintf("跟单熔断 %s：%s，暂停跟随开仓", shortHyperAddress(addr), reason))
+	}
+}
+
+// equityLoop 定时刷新带单者权益，用于回撤熔断和等比跟单
+func (t *hyperFollowTask) equityLoop() {
+	ticker := time.NewTicker(hyperLeaderEquityRefresh)
+	defer ticker.Stop()
+	for {
+		cfg := t.getConfig()
+		if cfg.MaxLeaderDrawdownPct > 0 || cfg.SizingMode == HyperSizingProportional {
+			if equity, err := fetchHyperLeaderEquity(cfg.Address); err != nil {
+				log.Printf("[HyperFollow] Refresh leader equity failed for %s: %v", cfg.Address, err)
+			} else {
+				t.observeLeaderEquity(equity)
+			}
+		}
+		select {
+		case <-t.stopC:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func shortHyperAddress(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if len(addr) <= 10 {
+		return addr
+	}
+	return addr[:6] + "..." + addr[len(addr)-4:]
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestCalcHyperFollowQuote_Modes(t *testing.T) {
+	fixed := HyperFollowConfig{SizingMode: HyperSizingFixed, QuoteQuantity: "5", Leverage: 10}
+	res, err := calcHyperFollowQuote(fixed, hyperSizingInput{LeaderNotional: 100000})
+	if err != nil || res.Quote != 5 || res.Notional != 50 {
+		t.Fatalf("fixed sizing should ignore leader size: %+v err=%v", res, err)
+	}
+
+	ratio := HyperFollowConfig{SizingMode: HyperSizingRatio, Ratio: 0.01, Leverage: 5}
+	res, err = calcHyperFollowQuote(ratio, hyperSizingInput{LeaderNotional: 20000})
+	if err != nil || math.Abs(res.Notional-200) > 1e-9 || math.Abs(res.Quote-40) > 1e-9 {
+		t.Fatalf("unexpected ratio sizing: %+v err=%v", res, err)
+	}
+
+	// 我方权益 1000，带单者 100000 → 缩放 1%
+	prop := HyperFollowConfig{SizingMode: HyperSizingProportional, Leverage: 10}
+	res, err = calcHyperFollowQuote(prop, hyperSizingInput{LeaderNotional: 50000, LeaderEquity: 100000, OurEquity: 1000})
+	if err != nil || math.Abs(res.Notional-500) > 1e-9 {
+		t.Fatalf("unexpected proportional sizing: %+v err=%v", res, err)
+	}
+}
+
+func TestCalcHyperFollowQuote_RiskCaps(t *testing.T) {
+	cfg := HyperFollowConfig{SizingMode: HyperSizingRatio, Ratio: 1, Leverage: 10, MaxOrderNotional: 300, MaxPositionPct: 50}
+	res, err := calcHyperFollowQuote(cfg, hyperSizingInput{LeaderNotional: 1000, OurEquity: 1000, OurExposure: 300})
+	if err != nil || res.Capped != "maxPositionPct" || math.Abs(res.Notional-200) > 1e-9 {
+		t.Fatalf("expected position cap to bind: %+v err=%v", res, err)
+	}
+
+	if _, err := calcHyperFollowQuote(cfg, hyperSizingInput{LeaderNotional: 1000, OurEquity: 1000, OurExposure: 500}); err == nil {
+		t.Fatal("expected error when position cap is exhausted")
+	}
+}
+
+func TestHyperFollowFillParsing(t *testing.T) {
+	fill := map[string]interface{}{"sz": "0.5", "startPosition": "2.0", "dir": "Close Long"}
+	if got := hyperReduceFraction(fill); math.Abs(got-0.25) > 1e-9 {
+		t.Fatalf("unexpected reduce fraction: %.4f", got)
+	}
+	if fillAction(map[string]interface{}{"dir": "Long > Short"}) != "flip" {
+		t.Fatal("expected flip action")
+	}
+
+	mapped := HyperFollowConfig{Symbol: "BTCUSDT", SymbolMap: map[string]string{"KPEPE": "1000PEPEUSDT"}}
+	if got := resolveHyperFollowSymbol(mapped, "kPEPE"); got != "1000PEPEUSDT" {
+		t.Fatalf("unexpected mapped symbol: %s", got)
+	}
+	if got := resolveHyperFollowSymbol(mapped, "BTC"); got != "" {
+		t.Fatalf("symbolMap should restrict followed coins, got %s", got)
+	}
+	if got := resolveHyperFollowSymbol(HyperFollowConfig{Symbol: "ETHUSDT"}, "ETH"); got != "ETHUSDT" {
+		t.Fatalf("unexpected default symbol: %s", got)
+	}
+}
+
+func TestComputeHyperLeaderStats_RoundTrips(t *testing.T) {
+	fills := []map[string]interface{}{
+		{"coin": "BTC", "time": float64(1000), "px": "100", "sz": "1", "side": "B", "startPosition": "0", "closedPnl": "0", "fee": "0.1"},
+		{"coin": "BTC", "time": float64(61000), "px": "110", "sz": "1", "side": "A", "startPosition": "1", "closedPnl": "10", "fee": "0.1"},
+		{"coin": "ETH", "time": float64(121000), "px": "50", "sz": "2", "side": "A", "startPosition": "0", "closedPnl": "0", "fee": "0"},
+		{"coin": "ETH", "time": float64(241000), "px": "55", "sz": "2", "side": "B", "startPosition": "-2", "closedPnl": "-10", "fee": "0"},
+	}
+	state := map[string]interface{}{"marginSummary": map[string]interface{}{"accountValue": "1000"}}
+	s := computeHyperLeaderStats("0xabc", fills, state, time.UnixMilli(0), time.UnixMilli(300000), 0)
+
+	if s.RoundTrips != 2 || s.Wins != 1 || s.WinRate != 0.5 {
+		t.Fatalf("unexpected round trips: trips=%d wins=%d rate=%.2f", s.RoundTrips, s.Wins, s.WinRate)
+	}
+	// 持仓时间 1m 和 2m
+	if s.AvgHoldMinutes != 1.5 {
+		t.Fatalf("unexpected avg hold: %.2f", s.AvgHoldMinutes)
+	}
+	if math.Abs(s.NetPnl+0.2) > 1e-9 || s.MaxDrawdown <= 0 {
+		t.Fatalf("unexpected pnl/drawdown: net=%.4f dd=%.4f", s.NetPnl, s.MaxDrawdown)
+	}
+	if s.MaxLeverage <= 0 {
+		t.Fatal("expected leverage usage from fills")
+	}
+}
+
+func TestDetectHyperLeaderDegradation(t *testing.T) {
+	s := &HyperLeaderStats{WinRate: 0.6, AvgLeverage: 3, MaxDrawdownPct: 8,
+		Recent: &HyperLeaderStats{Days: 7, RoundTrips: 10, WinRate: 0.3, AvgLeverage: 8, MaxDrawdownPct: 12}}
+	if alerts := detectHyperLeaderDegradation(s); len(alerts) != 3 {
+		t.Fatalf("expected win rate, leverage and drawdown alerts, got %v", alerts)
+	}
+}
+
+func TestHyperFollowRecord_SlippageAndTracking(t *testing.T) {
+	open := &HyperFollowRecord{FillKey: "a", Action: "open", Side: "BUY", LeaderTime: time.Now().UnixMilli() - 500, LeaderPrice: 100}
+	open.fillFromOrder(&futures.CreateOrderResponse{OrderID: 1, AvgPrice: "100.1", ExecutedQuantity: "1"})
+	if math.Abs(open.SlippageBps-10) > 1e-6 || open.LatencyMs < 500 {
+		t.Fatalf("unexpected open record: %+v", open)
+	}
+
+	// 带单者 100 开多、110 平，收益 10%；我方 100.1 开、109 平
+	closeRec := &HyperFollowRecord{FillKey: "b", Action: "close", Side: "SELL", PositionSide: "LONG",
+		LeaderPrice: 110, LeaderSize: 1, LeaderClosedPnl: 10}
+	closeRec.fillFromOrder(&futures.CreateOrderResponse{OrderID: 2, AvgPrice: "109", ExecutedQuantity: "1"})
+	closeRec.setFollowerPnl(100.1)
+	if math.Abs(closeRec.LeaderReturnPct-10) > 1e-6 || math.Abs(closeRec.OurPnl-8.9) > 1e-9 {
+		t.Fatalf("unexpected close record: %+v", closeRec)
+	}
+
+	skipped := HyperFollowRecord{FillKey: "c", Action: "open", Status: hyperFollowRecordSkipped}
+	report := buildHyperFollowFidelity([]HyperFollowRecord{*open, *closeRec, skipped})
+	if report.LeaderFills != 3 || report.Mirrored != 2 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	if report.Pnl.Samples != 1 || report.Slippage.Samples != 2 {
+		t.Fatalf("unexpected samples: pnl=%d slippage=%d", report.Pnl.Samples, report.Slippage.Samples)
+	}
+}
+
+func TestFetchHyperFillsSince_Paginates(t *testing.T) {
+	orig := hyperLeaderInfo
+	defer func() { hyperLeaderInfo = orig }()
+
+	// 2500 笔成交：第一页满 2000 条，第二页从最后一笔之后继续
+	var starts []int64
+	hyperLeaderInfo = func(body map[string]any) (any, error) {
+		start := body["startTime"].(int64)
+		starts = append(starts, start)
+		var page []interface{}
+		for ts := start; ts < 2500 && len(page) < hyperFillsPageLimit; ts++ {
+			page = append(page, map[string]interface{}{"time": float64(ts)})
+		}
+		return page, nil
+	}
+	fills, err := fetchHyperFillsSince("0xabc", time.UnixMilli(0), time.UnixMilli(10000))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fills) != 2500 || len(starts) != 2 || starts[1] != 2000 {
+		t.Fatalf("fills=%d starts=%v", len(fills), starts)
+	}
+}
+
+func TestComputeHyperLeaderStats_StartEquityFromHistory(t *testing.T) {
+	raw := []interface{}{
+		[]interface{}{"week", map[string]interface{}{"accountValueHistory": []interface{}{
+			[]interface{}{float64(5000), "1000"}, []interface{}{float64(9000), "6000"},
+		}}},
+		[]interface{}{"allTime", map[string]interface{}{"accountValueHistory": []interface{}{
+			[]interface{}{float64(1000), "800"}, []interface{}{float64(5000), "1000"},
+		}}},
+	}
+	history := parseHyperAccountValueHistory(raw)
+	if len(history) != 3 || hyperEquityAt(history, time.UnixMilli(6000)) != 1000 || hyperEquityAt(history, time.UnixMilli(0)) != 800 {
+		t.Fatalf("history=%+v", history)
+	}
+
+	// 窗口内入金 5000：当前权益 6000，按历史取起点 1000，收益率 = 100/1000
+	fills := []map[string]interface{}{
+		{"coin": "BTC", "time": float64(6000), "px": "100", "sz": "1", "side": "B", "startPosition": "0", "closedPnl": "0", "fee": "0"},
+		{"coin": "BTC", "time": float64(7000), "px": "200", "sz": "1", "side": "A", "startPosition": "1", "closedPnl": "100", "fee": "0"},
+	}
+	state := map[string]interface{}{"marginSummary": map[string]interface{}{"accountValue": "6000"}}
+	s := computeHyperLeaderStats("0xabc", fills, state, time.UnixMilli(5000), time.UnixMilli(10000), hyperEquityAt(history, time.UnixMilli(5000)))
+	if s.ReturnPct != 10 {
+		t.Fatalf("returnPct=%v want 10", s.ReturnPct)
+	}
+}
@@ -0,0 +1,741 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+const (
+	hyperLeaderDefaultDays   = 30
+	hyperLeaderRecentDays    = 7
+	hyperLeaderCacheTTL      = 5 * time.Minute
+	hyperLeaderRefreshEvery  = 30 * time.Minute
+	hyperLeaderAlertCooldown = 6 * time.Hour
+	hyperLeaderCurvePoints   = 200
+	hyperFillsPageLimit      = 2000 // userFillsByTime 单次最多返回的成交数
+	hyperFillsMaxPages       = 50
+)
+
+// hyperLeaderInfo Hyperliquid info 接口（测试注入点）
+var hyperLeaderInfo = fetchHyperInfo
+
+// HyperLeaderStats Hyperliquid 地址画像
+type HyperLeaderStats struct {
+	Address string    `json:"address"`
+	Days    int       `json:"days"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+
+	Fills          int     `json:"fills"`
+	Coins          int     `json:"coins"`
+	RoundTrips     int     `json:"roundTrips"` // 完整开平仓轮次（空仓 → 持仓 → 空仓/反手）
+	Wins           int     `json:"wins"`
+	WinRate        float64 `json:"winRate"` // 0-1
+	GrossPnl       float64 `json:"grossPnl"`
+	Fees           float64 `json:"fees"`
+	NetPnl         float64 `json:"netPnl"`
+	ReturnPct      float64 `json:"returnPct"`
+	ProfitFactor   float64 `json:"profitFactor"`
+	AvgHoldMinutes float64 `json:"avgHoldMinutes"`
+
+	AvgLeverage     float64 `json:"avgLeverage"`     // 成交时刻的有效杠杆（总持仓名义 / 估算权益）均值
+	MaxLeverage     float64 `json:"maxLeverage"`     // 有效杠杆峰值
+	CurrentLeverage float64 `json:"currentLeverage"` // 当前总持仓名义 / 账户权益
+
+	MaxDrawdown    float64 `json:"maxDrawdown"`    // 已实现权益曲线最大回撤（USDC）
+	MaxDrawdownPct float64 `json:"maxDrawdownPct"` // 相对峰值权益的回撤（%）
+
+	AccountValue float64               `json:"accountValue"`
+	Positions    []HyperLeaderPosition `json:"positions"`
+	PnlCurve     []HyperPnlPoint       `json:"pnlCurve,omitempty"`
+
+	Recent *HyperLeaderStats `json:"recent,omitempty"` // 最近 7 天同口径统计，用于识别行为变化
+	Score  float64           `json:"score"`
+
+	ComputedAt time.Time `json:"computedAt"`
+}
+
+// HyperLeaderPosition 带单者当前持仓
+type HyperLeaderPosition struct {
+	Coin          string  `json:"coin"`
+	Size          float64 `json:"size"` // 正为多、负为空
+	EntryPrice    float64 `json:"entryPrice"`
+	PositionValue float64 `json:"positionValue"`
+	UnrealizedPnl float64 `json:"unrealizedPnl"`
+	Leverage      float64 `json:"leverage"`
+}
+
+// HyperPnlPoint 累计已实现盈亏曲线点
+type HyperPnlPoint struct {
+	Time   int64   `json:"time"`
+	CumPnl float64 `json:"cumPnl"`
+}
+
+// HyperLeaderSnapshot 地址画像快照（数据库永久保存，用于观察行为变化）
+type HyperLeaderSnapshot struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Address        string    `gorm:"type:varchar(42);index" json:"address"`
+	Days           int       `json:"days"`
+	RoundTrips     int       `json:"roundTrips"`
+	WinRate        float64   `gorm:"type:numeric(36,8)" json:"winRate"`
+	NetPnl         float64   `gorm:"type:numeric(36,8)" json:"netPnl"`
+	ReturnPct      float64   `gorm:"type:numeric(36,8)" json:"returnPct"`
+	MaxDrawdownPct float64   `gorm:"type:numeric(36,8)" json:"maxDrawdownPct"`
+	AvgHoldMinutes float64   `gorm:"type:numeric(36,8)" json:"avgHoldMinutes"`
+	AvgLeverage    float64   `gorm:"type:numeric(36,8)" json:"avgLeverage"`
+	MaxLeverage    float64   `gorm:"type:numeric(36,8)" json:"maxLeverage"`
+	AccountValue   float64   `gorm:"type:numeric(36,8)" json:"accountValue"`
+	Score          float64   `gorm:"type:numeric(36,8)" json:"score"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// HyperLeaderWatch 候选带单地址观察列表
+type HyperLeaderWatch struct {
+	Address   string    `gorm:"type:varchar(42);primaryKey" json:"address"`
+	Label     string    `gorm:"type:varchar(64)" json:"label,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// HyperLeaderRank 观察列表排名
+type HyperLeaderRank struct {
+	Address        string    `json:"address"`
+	Label          string    `json:"label,omitempty"`
+	Following      bool      `json:"following"`
+	Score          float64   `json:"score"`
+	WinRate        float64   `json:"winRate"`
+	NetPnl         float64   `json:"netPnl"`
+	ReturnPct      float64   `json:"returnPct"`
+	MaxDrawdownPct float64   `json:"maxDrawdownPct"`
+	AvgLeverage    float64   `json:"avgLeverage"`
+	AvgHoldMinutes float64   `json:"avgHoldMinutes"`
+	Degraded       bool      `json:"degraded"`
+	Alerts         []string  `json:"alerts,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type hyperLeaderCacheEntry struct {
+	stats *HyperLeaderStats
+	at    time.Time
+}
+
+var (
+	hyperLeaderMu        sync.Mutex
+	hyperLeaderCache     = make(map[string]hyperLeaderCacheEntry) // key: address|days
+	hyperWatchlist       = make(map[string]HyperLeaderWatch)
+	hyperWatchRanks      []HyperLeaderRank
+	hyperLeaderAlertedAt = make(map[string]time.Time)
+	hyperWatcherOnce     sync.Once
+)
+
+// GetHyperLeaderStats 拉取地址成交历史与持仓并计算画像（5 分钟缓存）
+func GetHyperLeaderStats(address string, days int) (*HyperLeaderStats, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if !reAddress.MatchString(address) {
+		return nil, fmt.Errorf("address is invalid")
+	}
+	if days <= 0 {
+		days = hyperLeaderDefaultDays
+	}
+	if days > 180 {
+		days = 180
+	}
+
+	key := address + "|" + strconv.Itoa(days)
+	hyperLeaderMu.Lock()
+	if entry, ok := hyperLeaderCache[key]; ok && time.Since(entry.at) < hyperLeaderCacheTTL {
+		hyperLeaderMu.Unlock()
+		return entry.stats, nil
+	}
+	hyperLeaderMu.Unlock()
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	fills, err := fetchHyperFillsSince(address, since, now)
+	if err != nil {
+		return nil, fmt.Errorf("fetch fills: %w", err)
+	}
+	rawState, err := hyperLeaderInfo(map[string]any{
+		"type": "clearinghouseState",
+		"user": address,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch clearinghouse state: %w", err)
+	}
+
+	state, _ := rawState.(map[string]interface{})
+	// 起点权益取账户权益历史，出入金不影响收益率与回撤；历史不可用时退回按当前权益倒推
+	var history []HyperPnlPoint
+	if rawPortfolio, err := hyperLeaderInfo(map[string]any{"type": "portfolio", "user": address}); err != nil {
+		log.Printf("[HyperLeader] fetch portfolio for %s failed, start equity backed out from current value: %v", shortHyperAddress(address), err)
+	} else {
+		history = parseHyperAccountValueHistory(rawPortfolio)
+	}
+	stats := computeHyperLeaderStats(address, fills, state, since, now, hyperEquityAt(history, since))
+	stats.Days = days
+
+	recentSince := now.AddDate(0, 0, -hyperLeaderRecentDays)
+	if days > hyperLeaderRecentDays {
+		var recentFills []map[string]interface{}
+		for _, f := range fills {
+			if parseAnyInt64(f["time"]) >= recentSince.UnixMilli() {
+				recentFills = append(recentFills, f)
+			}
+		}
+		recent := computeHyperLeaderStats(address, recentFills, state, recentSince, now, hyperEquityAt(history, recentSince))
+		recent.Days = hyperLeaderRecentDays
+		recent.PnlCurve = nil
+		recent.Positions = nil
+		stats.Recent = recent
+	}
+
+	hyperLeaderMu.Lock()
+	hyperLeaderCache[key] = hyperLeaderCacheEntry{stats: stats, at: now}
+	hyperLeaderMu.Unlock()
+
+	go saveHyperLeaderSnapshot(stats)
+	return stats, nil
+}
+
+// fetchHyperFillsSince 分页拉取 [since, until] 内的成交：每页最多 2000 条，
+// 满页时把 startTime 移到本页最后一笔之后继续，直到返回不满一页
+func fetchHyperFillsSince(address string, since, until time.Time) ([]map[string]interface{}, error) {
+	var fills []map[string]interface{}
+	start, end := since.UnixMilli(), until.UnixMilli()
+	for page := 0; page < hyperFillsMaxPages; page++ {
+		raw, err := hyperLeaderInfo(map[string]any{
+			"type":            "userFillsByTime",
+			"user":            address,
+			"startTime":       start,
+			"endTime":         end,
+			"aggregateByTime": true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		batch := toHyperFillList(raw)
+		fills = append(fills, batch...)
+		if len(batch) < hyperFillsPageLimit {
+			return fills, nil
+		}
+		last := start
+		for _, f := range batch {
+			if ts := parseAnyInt64(f["time"]); ts > last {
+				last = ts
+			}
+		}
+		if last >= end {
+			return fills, nil
+		}
+		start = last + 1
+	}
+	log.Printf("[HyperLeader] %s fills truncated after %d pages", shortHyperAddress(address), hyperFillsMaxPages)
+	return fills, nil
+}
+
+// parseHyperAccountValueHistory 合并 portfolio 各周期的 accountValueHistory，按时间升序
+// 返回格式：[["day", {"accountValueHistory": [[ts, "value"], ...], ...}], ...]
+func parseHyperAccountValueHistory(raw any) []HyperPnlPoint {
+	periods, _ := raw.([]interface{})
+	seen := make(map[int64]bool)
+	var points []HyperPnlPoint
+	for _, p := range periods {
+		pair, _ := p.([]interface{})
+		if len(pair) != 2 {
+			continue
+		}
+		data, _ := pair[1].(map[string]interface{})
+		history, _ := data["accountValueHistory"].([]interface{})
+		for _, h := range history {
+			point, _ := h.([]interface{})
+			if len(point) != 2 {
+				continue
+			}
+			ts := parseAnyInt64(point[0])
+			if ts <= 0 || seen[ts] {
+				continue
+			}
+			seen[ts] = true
+			points = append(points, HyperPnlPoint{Time: ts, CumPnl: parseAnyFloat(point[1])})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Time < points[j].Time })
+	return points
+}
+
+// hyperEquityAt 取 at 时刻（或之前最近）的账户权益；账户晚于 at 创建时取最早一点，无数据返回 0
+func hyperEquityAt(history []HyperPnlPoint, at time.Time) float64 {
+	if len(history) == 0 {
+		return 0
+	}
+	ts := at.UnixMilli()
+	i := sort.Search(len(history), func(i int) bool { return history[i].Time > ts })
+	if i == 0 {
+		return history[0].CumPnl
+	}
+	return history[i-1].CumPnl
+}
+
+func toHyperFillList(raw any) []map[string]interface{} {
+	list, _ := raw.([]interface{})
+	fills := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			fills = append(fills, m)
+		}
+	}
+	return fills
+}
+
+// computeHyperLeaderStats 由成交记录与账户状态计算画像
+// 轮次按单币种仓位从 0 开到回 0（或反手）划分；权益曲线起点为窗口开始时的账户权益 startEquity，
+// 未知（≤0）时以当前权益倒推：起点 = 当前权益 - 窗口内净已实现盈亏（窗口内出入金会造成偏差）
+func computeHyperLeaderStats(address string, fills []map[string]interface{}, state map[string]interface{}, since, now time.Time, startEquity float64) *HyperLeaderStats {
+	stats := &HyperLeaderStats{
+		Address:    address,
+		From:       since,
+		To:         now,
+		Fills:      len(fills),
+		ComputedAt: now,
+	}
+
+	// 当前持仓与杠杆
+	var totalNtl float64
+	if state != nil {
+		summary, _ := state["marginSummary"].(map[string]interface{})
+		stats.AccountValue = parseAnyFloat(summary["accountValue"])
+		assets, _ := state["assetPositions"].([]interface{})
+		for _, a := range assets {
+			am, _ := a.(map[string]interface{})
+			pm, _ := am["position"].(map[string]interface{})
+			if pm == nil {
+				continue
+			}
+			lev, _ := pm["leverage"].(map[string]interface{})
+			pos := HyperLeaderPosition{
+				Coin:          parseAnyString(pm["coin"]),
+				Size:          parseAnyFloat(pm["szi"]),
+				EntryPrice:    parseAnyFloat(pm["entryPx"]),
+				PositionValue: parseAnyFloat(pm["positionValue"]),
+				UnrealizedPnl: parseAnyFloat(pm["unrealizedPnl"]),
+				Leverage:      parseAnyFloat(lev["value"]),
+			}
+			if pos.Size == 0 {
+				continue
+			}
+			totalNtl += math.Abs(pos.PositionValue)
+			stats.Positions = append(stats.Positions, pos)
+		}
+		if stats.AccountValue > 0 {
+			stats.CurrentLeverage = roundFloat(totalNtl/stats.AccountValue, 4)
+		}
+	}
+
+	sorted := append([]map[string]interface{}(nil), fills...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return parseAnyInt64(sorted[i]["time"]) < parseAnyInt64(sorted[j]["time"])
+	})
+
+	var netTotal float64
+	for _, f := range sorted {
+		netTotal += parseAnyFloat(f["closedPnl"]) - parseAnyFloat(f["fee"])
+	}
+	if startEquity <= 0 {
+		startEquity = stats.AccountValue - netTotal
+	}
+
+	type coinState struct {
+		pos       float64
+		openAt    int64
+		knownOpen bool
+		tripPnl   float64
+		notional  float64
+	}
+	coins := make(map[string]*coinState)
+	var cum, peak, grossWin, grossLoss, holdSum, levSum float64
+	var holdCount, levCount int
+	curve := make([]HyperPnlPoint, 0, len(sorted))
+	peak = startEquity
+
+	closeTrip := func(cs *coinState, ts int64) {
+		stats.RoundTrips++
+		if cs.tripPnl > 0 {
+			stats.Wins++
+			grossWin += cs.tripPnl
+		} else {
+			grossLoss += -cs.tripPnl
+		}
+		if cs.knownOpen && ts > cs.openAt {
+			holdSum += float64(ts-cs.openAt) / 60000
+			holdCount++
+		}
+		cs.tripPnl = 0
+	}
+
+	for _, f := range sorted {
+		coin := parseAnyString(f["coin"])
+		ts := parseAnyInt64(f["time"])
+		px := parseAnyFloat(f["px"])
+		sz := math.Abs(parseAnyFloat(f["sz"]))
+		if orderSideFromFill(f) == "SELL" {
+			sz = -sz
+		}
+		closed := parseAnyFloat(f["closedPnl"])
+		fee := parseAnyFloat(f["fee"])
+
+		cs, ok := coins[coin]
+		if !ok {
+			start := parseAnyFloat(f["startPosition"])
+			cs = &coinState{pos: start, openAt: ts}
+			coins[coin] = cs
+		}
+		before := cs.pos
+		after := before + sz
+		if math.Abs(after) < 1e-12 {
+			after = 0
+		}
+
+		stats.GrossPnl += closed
+		stats.Fees += fee
+		cs.tripPnl += closed - fee
+
+		switch {
+		case before == 0 && after != 0:
+			cs.openAt, cs.knownOpen = ts, true
+		case before != 0 && after == 0:
+			closeTrip(cs, ts)
+		case before != 0 && (before > 0) != (after > 0):
+			closeTrip(cs, ts)
+			cs.openAt, cs.knownOpen = ts, true
+		}
+		cs.pos = after
+		cs.notional = math.Abs(after) * px
+
+		cum += closed - fee
+		curve = append(curve, HyperPnlPoint{Time: ts, CumPnl: roundFloat(cum, 4)})
+
+		equity := startEquity + cum
+		if equity > peak {
+			peak = equity
+		}
+		if dd := peak - equity; dd > stats.MaxDrawdown {
+			stats.MaxDrawdown = dd
+			if peak > 0 {
+				stats.MaxDrawdownPct = dd / peak * 100
+			}
+		}
+		if equity > 0 {
+			var gross float64
+			for _, c := range coins {
+				gross += c.notional
+			}
+			lev := gross / equity
+			levSum += lev
+			levCount++
+			if lev > stats.MaxLeverage {
+				stats.MaxLeverage = lev
+			}
+		}
+	}
+
+	stats.Coins = len(coins)
+	stats.NetPnl = roundFloat(stats.GrossPnl-stats.Fees, 4)
+	stats.GrossPnl = roundFloat(stats.GrossPnl, 4)
+	stats.Fees = roundFloat(stats.Fees, 4)
+	if stats.RoundTrips > 0 {
+		stats.WinRate = roundFloat(float64(stats.Wins)/float64(stats.RoundTrips), 4)
+	}
+	if grossLoss > 0 {
+		stats.ProfitFactor = roundFloat(grossWin/grossLoss, 4)
+	} else if grossWin > 0 {
+		stats.ProfitFactor = 999
+	}
+	if holdCount > 0 {
+		stats.AvgHoldMinutes = roundFloat(holdSum/float64(holdCount), 2)
+	}
+	if levCount > 0 {
+		stats.AvgLeverage = roundFloat(levSum/float64(levCount), 4)
+	}
+	stats.MaxLeverage = roundFloat(stats.MaxLeverage, 4)
+	stats.MaxDrawdown = roundFloat(stats.MaxDrawdown, 4)
+	stats.MaxDrawdownPct = roundFloat(stats.MaxDrawdownPct, 4)
+	if startEquity > 0 {
+		stats.ReturnPct = roundFloat(netTotal/startEquity*100, 4)
+	}
+	stats.PnlCurve = downsampleHyperCurve(curve, hyperLeaderCurvePoints)
+	stats.Score = scoreHyperLeader(stats)
+	return stats
+}
+
+// scoreHyperLeader 综合评分（0-100）：胜率 35 + 收益回撤比 40 + 杠杆克制 15 + 样本量 10
+func scoreHyperLeader(s *HyperLeaderStats) float64 {
+	if s.RoundTrips == 0 {
+		return 0
+	}
+	calmar := s.ReturnPct / math.Max(s.MaxDrawdownPct, 1)
+	levScore := 1 - clamp((s.AvgLeverage-3)/17, 0, 1) // 3x 以内满分，20x 以上 0 分
+	sample := clamp(float64(s.RoundTrips)/30, 0, 1)
+	score := s.WinRate*35 + clamp(calmar, 0, 3)/3*40 + levScore*15 + sample*10
+	return roundFloat(score, 2)
+}
+
+// detectHyperLeaderDegradation 对比最近 7 天与整个窗口，识别带单者行为恶化
+func detectHyperLeaderDegradation(s *HyperLeaderStats) []string {
+	if s == nil || s.Recent == nil {
+		return nil
+	}
+	r := s.Recent
+	var alerts []string
+	if r.RoundTrips >= 5 && s.WinRate-r.WinRate >= 0.15 {
+		alerts = append(alerts, fmt.Sprintf("胜率下降 %.0f%% → %.0f%%", s.WinRate*100, r.WinRate*100))
+	}
+	if s.AvgLeverage > 0 && r.AvgLeverage >= s.AvgLeverage*2 && r.AvgLeverage >= 3 {
+		alerts = append(alerts, fmt.Sprintf("杠杆抬升 %.1fx → %.1fx", s.AvgLeverage, r.AvgLeverage))
+	}
+	if r.MaxDrawdownPct >= 10 && r.MaxDrawdownPct >= s.MaxDrawdownPct*0.8 {
+		alerts = append(alerts, fmt.Sprintf("近 %d 天回撤 %.1f%%", r.Days, r.MaxDrawdownPct))
+	}
+	if s.AvgHoldMinutes > 0 && r.RoundTrips >= 5 && r.AvgHoldMinutes > 0 && r.AvgHoldMinutes < s.AvgHoldMinutes/3 {
+		alerts = append(alerts, fmt.Sprintf("持仓时间缩短 %.0fm → %.0fm", s.AvgHoldMinutes, r.AvgHoldMinutes))
+	}
+	return alerts
+}
+
+func downsampleHyperCurve(curve []HyperPnlPoint, maxPoints int) []HyperPnlPoint {
+	if len(curve) <= maxPoints || maxPoints < 2 {
+		return curve
+	}
+	out := make([]HyperPnlPoint, 0, maxPoints)
+	step := float64(len(curve)-1) / float64(maxPoints-1)
+	for i := 0; i < maxPoints; i++ {
+		out = append(out, curve[int(math.Round(float64(i)*step))])
+	}
+	return out
+}
+
+func saveHyperLeaderSnapshot(s *HyperLeaderStats) {
+	if DB == nil || s == nil {
+		return
+	}
+	snap := &HyperLeaderSnapshot{
+		Address:        s.Address,
+		Days:           s.Days,
+		RoundTrips:     s.RoundTrips,
+		WinRate:        s.WinRate,
+		NetPnl:         s.NetPnl,
+		ReturnPct:      s.ReturnPct,
+		MaxDrawdownPct: s.MaxDrawdownPct,
+		AvgHoldMinutes: s.AvgHoldMinutes,
+		AvgLeverage:    s.AvgLeverage,
+		MaxLeverage:    s.MaxLeverage,
+		AccountValue:   s.AccountValue,
+		Score:          s.Score,
+	}
+	if err := DB.Create(snap).Error; err != nil {
+		log.Printf("[HyperLeader] Save snapshot failed for %s: %v", s.Address, err)
+	}
+}
+
+// ========== 观察列表 ==========
+
+// StartHyperLeaderWatcher 加载观察列表并定时刷新排名（跟单中的地址自动纳入）
+func StartHyperLeaderWatcher() {
+	hyperWatcherOnce.Do(func() {
+		if DB != nil {
+			var rows []HyperLeaderWatch
+			if err := DB.Find(&rows).Error; err != nil {
+				log.Printf("[HyperLeader] Load watchlist failed: %v", err)
+			}
+			hyperLeaderMu.Lock()
+			for _, w := range rows {
+				hyperWatchlist[w.Address] = w
+			}
+			hyperLeaderMu.Unlock()
+		}
+		go func() {
+			ticker := time.NewTicker(hyperLeaderRefreshEvery)
+			defer ticker.Stop()
+			for range ticker.C {
+				RefreshHyperWatchlist()
+			}
+		}()
+	})
+}
+
+// AddHyperWatch 加入观察列表
+func AddHyperWatch(address, label string) error {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if !reAddress.MatchString(address) {
+		return fmt.Errorf("address is invalid")
+	}
+	w := HyperLeaderWatch{Address: address, Label: strings.TrimSpace(label), CreatedAt: time.Now()}
+	if DB != nil {
+		if err := DB.Save(&w).Error; err != nil {
+			return err
+		}
+	}
+	hyperLeaderMu.Lock()
+	hyperWatchlist[address] = w
+	hyperLeaderMu.Unlock()
+	return nil
+}
+
+// RemoveHyperWatch 移出观察列表
+func RemoveHyperWatch(address string) error {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if DB != nil {
+		if err := DB.Delete(&HyperLeaderWatch{}, "address = ?", address).Error; err != nil {
+			return err
+		}
+	}
+	hyperLeaderMu.Lock()
+	delete(hyperWatchlist, address)
+	filtered := hyperWatchRanks[:0]
+	for _, r := range hyperWatchRanks {
+		if r.Address != address {
+			filtered = append(filtered, r)
+		}
+	}
+	hyperWatchRanks = filtered
+	hyperLeaderMu.Unlock()
+	return nil
+}
+
+// RefreshHyperWatchlist 重新计算观察列表及跟单地址的画像并排名，跟单地址行为恶化时告警
+func RefreshHyperWatchlist() []HyperLeaderRank {
+	following := make(map[string]bool)
+	hyperFollowMgr.mu.RLock()
+	for addr := range hyperFollowMgr.tasks {
+		following[addr] = true
+	}
+	hyperFollowMgr.mu.RUnlock()
+
+	hyperLeaderMu.Lock()
+	targets := make(map[string]string, len(hyperWatchlist)+len(following))
+	for addr, w := range hyperWatchlist {
+		targets[addr] = w.Label
+	}
+	hyperLeaderMu.Unlock()
+	for addr := range following {
+		if _, ok := targets[addr]; !ok {
+			targets[addr] = ""
+		}
+	}
+
+	ranks := make([]HyperLeaderRank, 0, len(targets))
+	for addr, label := range targets {
+		rank := HyperLeaderRank{Address: addr, Label: label, Following: following[addr], UpdatedAt: time.Now()}
+		stats, err := GetHyperLeaderStats(addr, hyperLeaderDefaultDays)
+		if err != nil {
+			rank.Error = err.Error()
+			ranks = append(ranks, rank)
+			continue
+		}
+		rank.Score = stats.Score
+		rank.WinRate = stats.WinRate
+		rank.NetPnl = stats.NetPnl
+		rank.ReturnPct = stats.ReturnPct
+		rank.MaxDrawdownPct = stats.MaxDrawdownPct
+		rank.AvgLeverage = stats.AvgLeverage
+		rank.AvgHoldMinutes = stats.AvgHoldMinutes
+		rank.Alerts = detectHyperLeaderDegradation(stats)
+		rank.Degraded = len(rank.Alerts) > 0
+		if rank.Degraded && rank.Following {
+			alertHyperLeaderDegraded(addr, rank.Alerts)
+		}
+		ranks = append(ranks, rank)
+	}
+
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i].Score > ranks[j].Score })
+
+	hyperLeaderMu.Lock()
+	hyperWatchRanks = ranks
+	hyperLeaderMu.Unlock()
+	return ranks
+}
+
+func alertHyperLeaderDegraded(address string, alerts []string) {
+	hyperLeaderMu.Lock()
+	last := hyperLeaderAlertedAt[address]
+	if time.Since(last) < hyperLeaderAlertCooldown {
+		hyperLeaderMu.Unlock()
+		return
+	}
+	hyperLeaderAlertedAt[address] = time.Now()
+	hyperLeaderMu.Unlock()
+
+	msg := fmt.Sprintf("跟单地址 %s 行为恶化：%s", shortHyperAddress(address), strings.Join(alerts, "；"))
+	log.Printf("[HyperLeader] %s", msg)
+	SendNotify(msg)
+}
+
+// GetHyperWatchRanks 返回最近一次排名结果
+func GetHyperWatchRanks() []HyperLeaderRank {
+	hyperLeaderMu.Lock()
+	defer hyperLeaderMu.Unlock()
+	return append([]HyperLeaderRank(nil), hyperWatchRanks...)
+}
+
+// ========== HTTP Handler ==========
+
+// HandleGetHyperLeaderStats GET /tool/hyper/leaders/:address/stats?days=30
+func HandleGetHyperLeaderStats(c context.Context, ctx *app.RequestContext) {
+	address := ctx.Param("address")
+	days, _ := strconv.Atoi(strings.TrimSpace(string(ctx.Query("days"))))
+
+	stats, err := GetHyperLeaderStats(address, days)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": stats})
+}
+
+// HandleGetHyperWatchlist GET /tool/hyper/watchlist?refresh=1
+func HandleGetHyperWatchlist(c context.Context, ctx *app.RequestContext) {
+	var ranks []HyperLeaderRank
+	if string(ctx.Query("refresh")) == "1" {
+		ranks = RefreshHyperWatchlist()
+	} else {
+		ranks = GetHyperWatchRanks()
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": ranks})
+}
+
+// HandleAddHyperWatch POST /tool/hyper/watchlist
+func HandleAddHyperWatch(c context.Context, ctx *app.RequestContext) {
+	var req struct {
+		Address string `json:"address"`
+		Label   string `json:"label"`
+	}
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if err := AddHyperWatch(req.Address, req.Label); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	go RefreshHyperWatchlist()
+	ctx.JSON(http.StatusOK, utils.H{"message": "added"})
+}
+
+// HandleRemoveHyperWatch DELETE /tool/hyper/watchlist?address=0x...
+func HandleRemoveHyperWatch(c context.Context, ctx *app.RequestContext) {
+	if err := RemoveHyperWatch(string(ctx.Query("address"))); err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"message": "removed"})
+}
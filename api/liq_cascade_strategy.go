@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,29 +13,104 @@ import (
 
 // ========== 爆仓级联交易策略 ==========
 // 当检测到大规模同方向爆仓时，反向开仓做均值回归
+// 数据源为实时 forceOrder 事件（见 liq_cascade_window.go），阈值按 OI / 24h 成交额归一化；
+// 触发后等待强平衰竭 + 盘口确认再入场，按 ATR 设置止盈止损
+
+const (
+	liqCascadeCheckInterval = 5 * time.Second
+	liqCascadeBaseRefresh   = 10 * time.Minute
+)
 
 // LiqCascadeConfig 爆仓级联策略配置
 type LiqCascadeConfig struct {
-	Enabled        bool    `json:"enabled"`
-	Symbol         string  `json:"symbol"`         // 默认 BTCUSDT
-	Leverage       int     `json:"leverage"`        // 默认 5
-	AmountPerOrder string  `json:"amountPerOrder"`  // 默认 10
-	ThresholdUSDT  float64 `json:"thresholdUSDT"`   // 爆仓金额阈值，默认 5000000 (500万U)
-	WindowMin      int     `json:"windowMin"`       // 时间窗口分钟，默认 5
-	CooldownMin    int     `json:"cooldownMin"`     // 冷却期分钟，默认 30
+	Enabled          bool               `json:"enabled"`
+	Symbol           string             `json:"symbol"`           // 兼容单币种配置，默认 BTCUSDT（亦作为持久化 key）
+	Symbols          []string           `json:"symbols"`          // 监控币种列表，为空时使用 symbol
+	Leverage         int                `json:"leverage"`         // 默认 5
+	AmountPerOrder   string             `json:"amountPerOrder"`   // 默认 10
+	ThresholdUSDT    float64            `json:"thresholdUSDT"`    // 绝对阈值：normalizeBy=none 或 OI/成交额不可用时使用，默认 5000000
+	NormalizeBy      string             `json:"normalizeBy"`      // oi / volume / none，默认 oi
+	ThresholdPct     float64            `json:"thresholdPct"`     // 窗口强平额占 OI（或 24h 成交额）的百分比，默认 oi=0.1 volume=0.05
+	MinNotionalUSDT  float64            `json:"minNotionalUSDT"`  // 归一化阈值下限，默认 200000
+	SymbolThresholds map[string]float64 `json:"symbolThresholds"` // 按币种的绝对阈值（USDT），优先级最高
+	WindowMin        int                `json:"windowMin"`        // 滑动窗口分钟，默认 5
+	CooldownMin      int                `json:"cooldownMin"`      // 单币种冷却期分钟，默认 30
+	ExhaustSec       int                `json:"exhaustSec"`       // 衰竭判定切片秒数，默认 30
+	ExhaustRatio     float64            `json:"exhaustRatio"`     // 最近切片强平额 <= 峰值切片 × ratio 视为衰竭，默认 0.3
+	MinImbalance     float64            `json:"minImbalance"`     // 盘口确认：做多要求 imbalance >= 该值，做空要求 <= -该值，默认 0
+	ArmTimeoutMin    int                `json:"armTimeoutMin"`    // 触发后等待衰竭确认的最长分钟，默认同 windowMin
+	ATRInterval      string             `json:"atrInterval"`      // ATR K线周期，默认 5m
+	ATRPeriod        int                `json:"atrPeriod"`        // 默认 14
+	SLATRMult        float64            `json:"slAtrMult"`        // 止损 ATR 倍数，默认 1.5
+	TPATRMult        float64            `json:"tpAtrMult"`        // 止盈 ATR 倍数，默认 2.5
+	MaxHoldMin       int                `json:"maxHoldMin"`       // 最长持仓分钟，超时平仓，默认 240
+}
+
+// LiqCascadePosition 策略持仓
+type LiqCascadePosition struct {
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"` // LONG / SHORT
+	Qty        float64   `json:"qty"`
+	EntryPrice float64   `json:"entryPrice"`
+	StopLoss   float64   `json:"stopLoss"`
+	TakeProfit float64   `json:"takeProfit"`
+	ATR        float64   `json:"atr"`
+	Reason     string    `json:"reason"`
+	OpenedAt   time.Time `json:"openedAt"`
+}
+
+// LiqCascadeSymbolStatus 单币种窗口状态
+type LiqCascadeSymbolStatus struct {
+	Symbol        string              `json:"symbol"`
+	LongLiq       float64             `json:"longLiq"`   // 窗口内多头被强平金额
+	ShortLiq      float64             `json:"shortLiq"`  // 窗口内空头被强平金额
+	Base          float64             `json:"base"`      // 归一化基数（OI 或 24h 成交额，USDT）
+	Threshold     float64             `json:"threshold"` // 当前生效阈值
+	Armed         string              `json:"armed"`     // 等待确认的方向 LONG / SHORT
+	ArmedAt       string              `json:"armedAt,omitempty"`
+	RecentLiq     float64             `json:"recentLiq"` // 最近切片强平额（衰竭判定）
+	PeakLiq       float64             `json:"peakLiq"`   // 窗口内峰值切片强平额
+	LastTriggerAt string              `json:"lastTriggerAt,omitempty"`
+	Position      *LiqCascadePosition `json:"position,omitempty"`
 }
 
 // LiqCascadeStatus 策略状态
 type LiqCascadeStatus struct {
-	Config        LiqCascadeConfig `json:"config"`
-	Active        bool             `json:"active"`
-	LastCheckAt   string           `json:"lastCheckAt"`
-	LastSignal    string           `json:"lastSignal"`    // LONG / SHORT / NONE
-	LastTriggerAt string           `json:"lastTriggerAt"`
-	BuyNotional   float64          `json:"buyNotional"`   // 最近窗口内多头爆仓金额
-	SellNotional  float64          `json:"sellNotional"`  // 最近窗口内空头爆仓金额
-	TotalTrades   int              `json:"totalTrades"`
-	LastError     string           `json:"lastError"`
+	Config        LiqCascadeConfig         `json:"config"`
+	Active        bool                     `json:"active"`
+	LastCheckAt   string                   `json:"lastCheckAt"`
+	LastSignal    string                   `json:"lastSignal"` // LONG / SHORT / NONE
+	LastTriggerAt string                   `json:"lastTriggerAt"`
+	BuyNotional   float64                  `json:"buyNotional"`  // 窗口内 BUY 方向强平金额（空头被强平）合计
+	SellNotional  float64                  `json:"sellNotional"` // 窗口内 SELL 方向强平金额（多头被强平）合计
+	Symbols       []LiqCascadeSymbolStatus `json:"symbols"`
+	TotalTrades   int                      `json:"totalTrades"`
+	TotalPnl      float64                  `json:"totalPnl"`
+	LastError     string                   `json:"lastError"`
+}
+
+// liqCascadeSymbol 单币种运行时状态
+type liqCascadeSymbol struct {
+	window        liqWindow
+	base          float64
+	baseAt        time.Time
+	threshold     float64
+	longLiq       float64
+	shortLiq      float64
+	armed         string
+	armedAt       time.Time
+	recent        float64
+	peak          float64
+	lastTriggerAt time.Time
+	position      *LiqCascadePosition
+}
+
+// liqCascadeRuntime 持久化的运行时状态（持仓、冷却、累计统计）
+type liqCascadeRuntime struct {
+	Positions   map[string]*LiqCascadePosition `json:"positions"`
+	LastTrigger map[string]time.Time           `json:"lastTrigger"`
+	TotalTrades int                            `json:"totalTrades"`
+	TotalPnl    float64                        `json:"totalPnl"`
 }
 
 type liqCascadeState struct {
@@ -42,12 +118,14 @@ type liqCascadeState struct {
 	config        LiqCascadeConfig
 	active        bool
 	stopC         chan struct{}
+	symbols       map[string]*liqCascadeSymbol
 	lastCheckAt   time.Time
 	lastSignal    string
 	lastTriggerAt time.Time
 	buyNotional   float64
 	sellNotional  float64
 	totalTrades   int
+	totalPnl      float64
 	lastError     string
 }
 
@@ -56,22 +134,36 @@ var (
 	liqCascadeOnce sync.Mutex
 )
 
-// StartLiqCascade 启动爆仓级联策略
-func StartLiqCascade(cfg LiqCascadeConfig) error {
-	liqCascadeOnce.Lock()
-	defer liqCascadeOnce.Unlock()
-
-	liqCascade.mu.Lock()
-	if liqCascade.active {
-		liqCascade.mu.Unlock()
-		return fmt.Errorf("liq cascade strategy already running")
+// normalizeLiqCascadeConfig 参数默认值与校验
+func normalizeLiqCascadeConfig(cfg *LiqCascadeConfig) error {
+	cfg.Symbol = strings.ToUpper(strings.TrimSpace(cfg.Symbol))
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, s := range cfg.Symbols {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s != "" && !seen[s] {
+			seen[s] = true
+			symbols = append(symbols, s)
+		}
 	}
-	liqCascade.mu.Unlock()
-
-	// 参数默认值
+	if len(symbols) == 0 {
+		if cfg.Symbol == "" {
+			cfg.Symbol = "BTCUSDT"
+		}
+		symbols = []string{cfg.Symbol}
+	}
+	cfg.Symbols = symbols
 	if cfg.Symbol == "" {
-		cfg.Symbol = "BTCUSDT"
+		cfg.Symbol = symbols[0]
 	}
+	if len(cfg.SymbolThresholds) > 0 {
+		th := make(map[string]float64, len(cfg.SymbolThresholds))
+		for k, v := range cfg.SymbolThresholds {
+			th[strings.ToUpper(strings.TrimSpace(k))] = v
+		}
+		cfg.SymbolThresholds = th
+	}
+
 	if cfg.Leverage <= 0 {
 		cfg.Leverage = 5
 	}
@@ -81,12 +173,92 @@ func StartLiqCascade(cfg LiqCascadeConfig) error {
 	if cfg.ThresholdUSDT <= 0 {
 		cfg.ThresholdUSDT = 5_000_000
 	}
+	cfg.NormalizeBy = strings.ToLower(strings.TrimSpace(cfg.NormalizeBy))
+	switch cfg.NormalizeBy {
+	case "":
+		cfg.NormalizeBy = "oi"
+	case "oi", "volume", "none":
+	default:
+		return fmt.Errorf("invalid normalizeBy %q, expected oi / volume / none", cfg.NormalizeBy)
+	}
+	if cfg.ThresholdPct <= 0 {
+		if cfg.NormalizeBy == "volume" {
+			cfg.ThresholdPct = 0.05
+		} else {
+			cfg.ThresholdPct = 0.1
+		}
+	}
+	if cfg.MinNotionalUSDT <= 0 {
+		cfg.MinNotionalUSDT = 200_000
+	}
 	if cfg.WindowMin <= 0 {
 		cfg.WindowMin = 5
 	}
 	if cfg.CooldownMin <= 0 {
 		cfg.CooldownMin = 30
 	}
+	if cfg.ExhaustSec <= 0 {
+		cfg.ExhaustSec = 30
+	}
+	if cfg.ExhaustSec*2 > cfg.WindowMin*60 {
+		return fmt.Errorf("exhaustSec (%d) must be at most half of windowMin", cfg.ExhaustSec)
+	}
+	if cfg.ExhaustRatio <= 0 || cfg.ExhaustRatio >= 1 {
+		cfg.ExhaustRatio = 0.3
+	}
+	if cfg.MinImbalance < -1 || cfg.MinImbalance > 1 {
+		return fmt.Errorf("minImbalance must be within [-1, 1]")
+	}
+	if cfg.ArmTimeoutMin <= 0 {
+		cfg.ArmTimeoutMin = cfg.WindowMin
+	}
+	if cfg.ATRInterval == "" {
+		cfg.ATRInterval = "5m"
+	}
+	if cfg.ATRPeriod <= 0 {
+		cfg.ATRPeriod = 14
+	}
+	if cfg.SLATRMult <= 0 {
+		cfg.SLATRMult = 1.5
+	}
+	if cfg.TPATRMult <= 0 {
+		cfg.TPATRMult = 2.5
+	}
+	if cfg.MaxHoldMin <= 0 {
+		cfg.MaxHoldMin = 240
+	}
+	return nil
+}
+
+// StartLiqCascade 启动爆仓级联策略
+func StartLiqCascade(cfg LiqCascadeConfig) error {
+	liqCascadeOnce.Lock()
+	defer liqCascadeOnce.Unlock()
+
+	liqCascade.mu.Lock()
+	if liqCascade.active {
+		liqCascade.mu.Unlock()
+		return fmt.Errorf("liq cascade strategy already running")
+	}
+	liqCascade.mu.Unlock()
+
+	if err := normalizeLiqCascadeConfig(&cfg); err != nil {
+		return err
+	}
+
+	// 恢复持仓与冷却状态
+	var rt liqCascadeRuntime
+	LoadStrategyRuntime("liq_cascade", cfg.Symbol, &rt)
+
+	symbols := make(map[string]*liqCascadeSymbol, len(cfg.Symbols))
+	for _, s := range cfg.Symbols {
+		st := &liqCascadeSymbol{}
+		if p := rt.Positions[s]; p != nil && p.Qty > 0 {
+			st.position = p
+		}
+		st.lastTriggerAt = rt.LastTrigger[s]
+		symbols[s] = st
+	}
 
 	stopC := make(chan struct{})
 
@@ -94,18 +266,24 @@ func StartLiqCascade(cfg LiqCascadeConfig) error {
 	liqCascade.config = cfg
 	liqCascade.active = true
 	liqCascade.stopC = stopC
+	liqCascade.symbols = symbols
 	liqCascade.lastSignal = "NONE"
+	liqCascade.totalTrades = rt.TotalTrades
+	liqCascade.totalPnl = rt.TotalPnl
+	liqCascade.lastError = ""
 	liqCascade.mu.Unlock()
 
+	// 事件来自后台强平采集，确保其已运行
+	StartLiquidationCollectorBackground()
 	go runLiqCascadeLoop(stopC, cfg)
 
 	SaveStrategyState("liq_cascade", cfg.Symbol, cfg)
-	log.Printf("[LiqCascade] Started symbol=%s threshold=%.0f windowMin=%d",
-		cfg.Symbol, cfg.ThresholdUSDT, cfg.WindowMin)
+	log.Printf("[LiqCascade] Started symbols=%v normalizeBy=%s thresholdPct=%.3f windowMin=%d",
+		cfg.Symbols, cfg.NormalizeBy, cfg.ThresholdPct, cfg.WindowMin)
 	return nil
 }
 
-// StopLiqCascade 停止爆仓级联策略
+// StopLiqCascade 停止爆仓级联策略（已有持仓保留，由交易所止盈止损单管理）
 func StopLiqCascade() error {
 	liqCascade.mu.Lock()
 	defer liqCascade.mu.Unlock()
@@ -134,6 +312,7 @@ func GetLiqCascadeStatus() *LiqCascadeStatus {
 		BuyNotional:  liqCascade.buyNotional,
 		SellNotional: liqCascade.sellNotional,
 		TotalTrades:  liqCascade.totalTrades,
+		TotalPnl:     roundFloat(liqCascade.totalPnl, 4),
 		LastError:    liqCascade.lastError,
 	}
 	if !liqCascade.lastCheckAt.IsZero() {
@@ -142,11 +321,38 @@ func GetLiqCascadeStatus() *LiqCascadeStatus {
 	if !liqCascade.lastTriggerAt.IsZero() {
 		s.LastTriggerAt = liqCascade.lastTriggerAt.Format(time.RFC3339)
 	}
+	for _, sym := range liqCascade.config.Symbols {
+		st := liqCascade.symbols[sym]
+		if st == nil {
+			continue
+		}
+		ss := LiqCascadeSymbolStatus{
+			Symbol:    sym,
+			LongLiq:   st.longLiq,
+			ShortLiq:  st.shortLiq,
+			Base:      st.base,
+			Threshold: st.threshold,
+			Armed:     st.armed,
+			RecentLiq: st.recent,
+			PeakLiq:   st.peak,
+		}
+		if !st.armedAt.IsZero() && st.armed != "" {
+			ss.ArmedAt = st.armedAt.Format(time.RFC3339)
+		}
+		if !st.lastTriggerAt.IsZero() {
+			ss.LastTriggerAt = st.lastTriggerAt.Format(time.RFC3339)
+		}
+		if st.position != nil {
+			p := *st.position
+			ss.Position = &p
+		}
+		s.Symbols = append(s.Symbols, ss)
+	}
 	return s
 }
 
 func runLiqCascadeLoop(stopC chan struct{}, cfg LiqCascadeConfig) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(liqCascadeCheckInterval)
 	defer ticker.Stop()
 
 	for {
@@ -160,124 +366,354 @@ func runLiqCascadeLoop(stopC chan struct{}, cfg LiqCascadeConfig) {
 }
 
 func checkLiqCascade(cfg LiqCascadeConfig) {
-	if DB == nil {
-		return
-	}
-
+	now := time.Now()
 	liqCascade.mu.Lock()
-	liqCascade.lastCheckAt = time.Now()
+	liqCascade.lastCheckAt = now
 	liqCascade.mu.Unlock()
 
-	// 计算时间窗口
-	windowStart := time.Now().Add(-time.Duration(cfg.WindowMin) * time.Minute)
-	windowStartMs := windowStart.UnixMilli()
-
-	// 查询 DB 最近 WindowMin 分钟内的爆仓统计
-	// 使用 1h 粒度数据（最细粒度），按 start_time >= windowStartMs 过滤
-	var records []LiquidationStatRecord
-	if err := DB.
-		Where("bucket_interval = ? AND start_time >= ?", liquidationIntervalH1, windowStartMs).
-		Order("start_time DESC").
-		Find(&records).Error; err != nil {
-		liqCascade.mu.Lock()
-		liqCascade.lastError = err.Error()
-		liqCascade.mu.Unlock()
-		log.Printf("[LiqCascade] DB query failed: %v", err)
-		return
-	}
-
-	// 汇总买方（多头）和卖方（空头）爆仓金额
-	// BuyNotional = 多头爆仓（买入平仓），SellNotional = 空头爆仓（卖出平仓）
 	var totalBuy, totalSell float64
-	for _, r := range records {
-		totalBuy += r.BuyNotional
-		totalSell += r.SellNotional
+	for _, sym := range cfg.Symbols {
+		refreshLiqCascadeBase(cfg, sym, now)
+		longLiq, shortLiq := checkLiqCascadeSymbol(cfg, sym, now)
+		totalSell += longLiq
+		totalBuy += shortLiq
 	}
 
 	liqCascade.mu.Lock()
 	liqCascade.buyNotional = totalBuy
 	liqCascade.sellNotional = totalSell
-	lastTrigger := liqCascade.lastTriggerAt
 	liqCascade.mu.Unlock()
+}
 
-	// 冷却检查
-	cooldownDur := time.Duration(cfg.CooldownMin) * time.Minute
-	if time.Since(lastTrigger) < cooldownDur {
+// refreshLiqCascadeBase 定期刷新归一化基数（OI 名义价值或 24h 成交额）
+func refreshLiqCascadeBase(cfg LiqCascadeConfig, symbol string, now time.Time) {
+	if cfg.NormalizeBy == "none" || cfg.SymbolThresholds[symbol] > 0 {
+		return
+	}
+	liqCascade.mu.RLock()
+	st := liqCascade.symbols[symbol]
+	due := st != nil && now.Sub(st.baseAt) >= liqCascadeBaseRefresh
+	liqCascade.mu.RUnlock()
+	if !due {
 		return
 	}
 
-	// 判断信号
-	// 多头大规模爆仓 → 反向做多（均值回归，超卖反弹）
-	// 空头大规模爆仓 → 反向做空（均值回归，超买回调）
-	var signal string
-	var side futures.SideType
-	var positionSide futures.PositionSideType
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	base, err := fetchLiqCascadeBase(ctx, cfg.NormalizeBy, symbol)
+
+	liqCascade.mu.Lock()
+	st.baseAt = now // 失败也推迟下一次刷新，沿用旧基数
+	if err == nil && base > 0 {
+		st.base = base
+	}
+	liqCascade.mu.Unlock()
+	if err != nil {
+		log.Printf("[LiqCascade] Refresh %s base (%s) failed: %v", symbol, cfg.NormalizeBy, err)
+	}
+}
+
+// fetchLiqCascadeBase 获取归一化基数（USDT）
+func fetchLiqCascadeBase(ctx context.Context, normalizeBy, symbol string) (float64, error) {
+	switch normalizeBy {
+	case "oi":
+		oi, err := Client.NewGetOpenInterestService().Symbol(symbol).Do(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("open interest: %w", err)
+		}
+		price, err := GetPriceCache().GetPrice(symbol)
+		if err != nil {
+			return 0, fmt.Errorf("price: %w", err)
+		}
+		return mustParseFloat(oi.OpenInterest) * price, nil
+	case "volume":
+		stats, err := Client.NewListPriceChangeStatsService().Symbol(symbol).Do(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("24h ticker: %w", err)
+		}
+		if len(stats) == 0 {
+			return 0, fmt.Errorf("24h ticker: empty response")
+		}
+		return mustParseFloat(stats[0].QuoteVolume), nil
+	}
+	return 0, nil
+}
+
+// checkLiqCascadeSymbol 单币种：更新窗口统计 → 管理持仓 / 触发待确认 / 衰竭确认入场
+// 返回窗口内多头、空头被强平金额
+func checkLiqCascadeSymbol(cfg LiqCascadeConfig, symbol string, now time.Time) (float64, float64) {
+	nowMs := now.UnixMilli()
+	windowMs := int64(cfg.WindowMin) * 60_000
+	bucketMs := int64(cfg.ExhaustSec) * 1000
 
-	if totalBuy > cfg.ThresholdUSDT {
-		// 多头大量爆仓 → 做多反弹
-		signal = "LONG"
-		side = futures.SideTypeBuy
-		positionSide = futures.PositionSideTypeLong
-	} else if totalSell > cfg.ThresholdUSDT {
-		// 空头大量爆仓 → 做空反弹
-		signal = "SHORT"
-		side = futures.SideTypeSell
-		positionSide = futures.PositionSideTypeShort
+	liqCascade.mu.Lock()
+	st := liqCascade.symbols[symbol]
+	if st == nil {
+		liqCascade.mu.Unlock()
+		return 0, 0
+	}
+	st.window.prune(nowMs - windowMs)
+	st.longLiq, st.shortLiq = st.window.sums(nowMs-windowMs, nowMs)
+	st.threshold = liqCascadeThreshold(cfg, symbol, st.base)
+	longLiq, shortLiq, threshold := st.longLiq, st.shortLiq, st.threshold
+	pos := st.position
+	armed, armedAt, lastTrigger := st.armed, st.armedAt, st.lastTriggerAt
+	var exhausted bool
+	if armed != "" {
+		exhausted, st.recent, st.peak = st.window.exhausted(armed == "LONG", nowMs, windowMs, bucketMs, cfg.ExhaustRatio)
+	} else {
+		st.recent, st.peak = 0, 0
 	}
+	recent, peak := st.recent, st.peak
+	liqCascade.mu.Unlock()
 
-	if signal == "" {
-		return
+	if pos != nil {
+		manageLiqCascadePosition(cfg, pos, now)
+		return longLiq, shortLiq
 	}
 
-	log.Printf("[LiqCascade] Signal=%s buyLiq=%.0f sellLiq=%.0f threshold=%.0f",
-		signal, totalBuy, totalSell, cfg.ThresholdUSDT)
+	// 尚未触发：窗口强平额超过阈值则进入待确认状态
+	if armed == "" {
+		if now.Sub(lastTrigger) < time.Duration(cfg.CooldownMin)*time.Minute {
+			return longLiq, shortLiq
+		}
+		signal := liqCascadeSignal(longLiq, shortLiq, threshold)
+		if signal == "" {
+			return longLiq, shortLiq
+		}
+		liqCascade.mu.Lock()
+		st.armed = signal
+		st.armedAt = now
+		liqCascade.lastSignal = signal
+		liqCascade.mu.Unlock()
+		log.Printf("[LiqCascade] %s armed %s longLiq=%.0f shortLiq=%.0f threshold=%.0f, waiting for exhaustion",
+			symbol, signal, longLiq, shortLiq, threshold)
+		return longLiq, shortLiq
+	}
+
+	if now.Sub(armedAt) > time.Duration(cfg.ArmTimeoutMin)*time.Minute {
+		liqCascade.mu.Lock()
+		st.armed = ""
+		liqCascade.mu.Unlock()
+		log.Printf("[LiqCascade] %s %s signal expired without exhaustion", symbol, armed)
+		return longLiq, shortLiq
+	}
+	if !exhausted {
+		return longLiq, shortLiq
+	}
+
+	// 盘口确认：做多要求买盘已恢复，做空要求卖盘已恢复
+	flow, err := AnalyzeOrderFlow(symbol, 100)
+	if err != nil {
+		log.Printf("[LiqCascade] %s order flow failed: %v", symbol, err)
+		return longLiq, shortLiq
+	}
+	if (armed == "LONG" && flow.Imbalance < cfg.MinImbalance) || (armed == "SHORT" && flow.Imbalance > -cfg.MinImbalance) {
+		return longLiq, shortLiq
+	}
 
 	liqCascade.mu.Lock()
-	liqCascade.lastSignal = signal
-	liqCascade.lastTriggerAt = time.Now()
+	st.armed = ""
+	st.lastTriggerAt = now
+	liqCascade.lastTriggerAt = now
 	liqCascade.mu.Unlock()
 
+	reason := fmt.Sprintf("多头强平 %.0f / 空头强平 %.0f (阈值 %.0f)，最近 %ds %.0f / 峰值 %.0f，盘口失衡 %.3f",
+		longLiq, shortLiq, threshold, cfg.ExhaustSec, recent, peak, flow.Imbalance)
+	openLiqCascade(cfg, symbol, armed, reason)
+	saveLiqCascadeRuntime()
+	return longLiq, shortLiq
+}
+
+// openLiqCascade 市价开仓，按 ATR 挂止损并以 tp/sl 倍数比作为盈亏比挂止盈
+func openLiqCascade(cfg LiqCascadeConfig, symbol, signal, reason string) {
+	setErr := func(err error) {
+		liqCascade.mu.Lock()
+		liqCascade.lastError = err.Error()
+		liqCascade.mu.Unlock()
+	}
+
 	if err := CheckRisk(); err != nil {
 		log.Printf("[LiqCascade] Risk check failed: %v", err)
+		setErr(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	klines, err := Client.NewKlinesService().Symbol(symbol).Interval(cfg.ATRInterval).Limit(cfg.ATRPeriod + 2).Do(ctx)
+	if err != nil {
+		log.Printf("[LiqCascade] %s fetch klines failed: %v", symbol, err)
+		setErr(err)
+		return
+	}
+	atr := calcATR(klines, cfg.ATRPeriod)
+	price, err := GetPriceCache().GetPrice(symbol)
+	if err != nil && len(klines) > 0 {
+		price, err = mustParseFloat(klines[len(klines)-1].Close), nil
+	}
+	if err != nil || price <= 0 || atr <= 0 {
+		err = fmt.Errorf("%s invalid price %.6f or ATR %.6f", symbol, price, atr)
+		log.Printf("[LiqCascade] %v", err)
+		setErr(err)
+		return
+	}
+
+	stopLoss, takeProfit := liqCascadeExitLevels(signal, price, atr, cfg.SLATRMult, cfg.TPATRMult)
+	slStr, err := normalizePriceForSymbol(ctx, symbol, stopLoss)
+	if err != nil {
+		log.Printf("[LiqCascade] %s normalize stop loss failed: %v", symbol, err)
+		setErr(err)
 		return
 	}
 
+	side, posSide := futures.SideTypeBuy, futures.PositionSideTypeLong
+	if signal == "SHORT" {
+		side, posSide = futures.SideTypeSell, futures.PositionSideTypeShort
+	}
 	req := PlaceOrderReq{
 		Source:        "liq_cascade",
-		Symbol:        cfg.Symbol,
+		Symbol:        symbol,
 		Side:          side,
 		OrderType:     futures.OrderTypeMarket,
-		PositionSide:  positionSide,
+		PositionSide:  posSide,
 		QuoteQuantity: cfg.AmountPerOrder,
 		Leverage:      cfg.Leverage,
+		StopLossPrice: slStr,
+		RiskReward:    cfg.TPATRMult / cfg.SLATRMult,
 	}
 
-	resp, err := PlaceOrderViaWs(context.Background(), req)
+	resp, err := PlaceOrderViaWs(ctx, req)
 	if err != nil {
 		log.Printf("[LiqCascade] Place order failed: %v", err)
-		SendNotify(fmt.Sprintf("*爆仓级联* 下单失败 %s %s: %v", cfg.Symbol, signal, err))
-		liqCascade.mu.Lock()
-		liqCascade.lastError = err.Error()
-		liqCascade.mu.Unlock()
+		SendNotify(fmt.Sprintf("*爆仓级联* 下单失败 %s %s: %v", symbol, signal, err))
+		setErr(err)
 		return
 	}
 
+	var order *futures.CreateOrderResponse
+	if resp != nil {
+		order = resp.Order
+	}
+	qty, entry := pairsOrderFill(order, price, mustParseFloat(cfg.AmountPerOrder)*float64(cfg.Leverage))
+	pos := &LiqCascadePosition{
+		Symbol:     symbol,
+		Side:       signal,
+		Qty:        qty,
+		EntryPrice: entry,
+		StopLoss:   stopLoss,
+		TakeProfit: takeProfit,
+		ATR:        atr,
+		Reason:     reason,
+		OpenedAt:   time.Now(),
+	}
+
 	liqCascade.mu.Lock()
+	if st := liqCascade.symbols[symbol]; st != nil {
+		st.position = pos
+	}
 	liqCascade.totalTrades++
 	liqCascade.lastError = ""
 	liqCascade.mu.Unlock()
 
 	orderID := int64(0)
-	if resp != nil && resp.Order != nil {
-		orderID = resp.Order.OrderID
+	if order != nil {
+		orderID = order.OrderID
 	}
-	log.Printf("[LiqCascade] Order placed symbol=%s signal=%s orderID=%d", cfg.Symbol, signal, orderID)
+	log.Printf("[LiqCascade] Order placed symbol=%s signal=%s orderID=%d entry=%.6f sl=%.6f tp=%.6f atr=%.6f",
+		symbol, signal, orderID, entry, stopLoss, takeProfit, atr)
 
 	direction := "多头"
 	if signal == "SHORT" {
 		direction = "空头"
 	}
-	msg := fmt.Sprintf("*爆仓级联开仓* %s\n方向: %s\n多头爆仓: %.0f U\n空头爆仓: %.0f U\n金额: %s USDT x %dx",
-		cfg.Symbol, direction, totalBuy, totalSell, cfg.AmountPerOrder, cfg.Leverage)
+	msg := fmt.Sprintf("*爆仓级联开仓* %s\n方向: %s\n%s\n入场: %.6f 止损: %.6f 止盈: %.6f\n金额: %s USDT x %dx",
+		symbol, direction, reason, entry, stopLoss, takeProfit, cfg.AmountPerOrder, cfg.Leverage)
 	SendNotify(msg)
 }
+
+// manageLiqCascadePosition 本地跟踪止盈止损与持仓超时
+// 止盈止损已挂到交易所，触价时先确认交易所是否已平仓，避免重复平仓
+func manageLiqCascadePosition(cfg LiqCascadeConfig, pos *LiqCascadePosition, now time.Time) {
+	price, err := GetPriceCache().GetPrice(pos.Symbol)
+	if err != nil || price <= 0 {
+		return
+	}
+
+	var reason string
+	level := price
+	switch {
+	case pos.Side == "LONG" && price <= pos.StopLoss, pos.Side == "SHORT" && price >= pos.StopLoss:
+		reason, level = "ATR止损", pos.StopLoss
+	case pos.Side == "LONG" && price >= pos.TakeProfit, pos.Side == "SHORT" && price <= pos.TakeProfit:
+		reason, level = "ATR止盈", pos.TakeProfit
+	case now.Sub(pos.OpenedAt) >= time.Duration(cfg.MaxHoldMin)*time.Minute:
+		reason = "持仓超时"
+	}
+	if reason == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	leg := PairsLeg{Symbol: pos.Symbol, Side: pos.Side, Qty: pos.Qty, EntryPrice: pos.EntryPrice}
+	exitPrice := price
+	closedByExchange := false
+	if level != price && !IsDryRun() {
+		posSide := futures.PositionSideTypeLong
+		if pos.Side == "SHORT" {
+			posSide = futures.PositionSideTypeShort
+		}
+		if _, err := findPosition(ctx, pos.Symbol, posSide); err != nil && strings.Contains(err.Error(), "no open position") {
+			closedByExchange = true
+			exitPrice = level
+		}
+	}
+	if !closedByExchange {
+		exitPrice, err = pairsCloseLeg(ctx, leg)
+		if err != nil {
+			log.Printf("[LiqCascade] %s close %s failed: %v", pos.Symbol, pos.Side, err)
+			liqCascade.mu.Lock()
+			liqCascade.lastError = err.Error()
+			liqCascade.mu.Unlock()
+			return
+		}
+	}
+
+	pnl := pairsLegPnl(leg, exitPrice)
+	liqCascade.mu.Lock()
+	if st := liqCascade.symbols[pos.Symbol]; st != nil && st.position == pos {
+		st.position = nil
+	}
+	liqCascade.totalPnl += pnl
+	liqCascade.mu.Unlock()
+	saveLiqCascadeRuntime()
+
+	log.Printf("[LiqCascade] Closed %s %s exit=%.6f pnl=%.4f reason=%s", pos.Symbol, pos.Side, exitPrice, pnl, reason)
+	NotifyTradeClose(pos.Symbol, pos.Side, pnl, "爆仓级联 "+reason)
+}
+
+// saveLiqCascadeRuntime 持久化持仓与冷却状态
+func saveLiqCascadeRuntime() {
+	liqCascade.mu.RLock()
+	key := liqCascade.config.Symbol
+	rt := liqCascadeRuntime{
+		Positions:   make(map[string]*LiqCascadePosition),
+		LastTrigger: make(map[string]time.Time),
+		TotalTrades: liqCascade.totalTrades,
+		TotalPnl:    liqCascade.totalPnl,
+	}
+	for sym, st := range liqCascade.symbols {
+		if st.position != nil {
+			p := *st.position
+			rt.Positions[sym] = &p
+		}
+		if !st.lastTriggerAt.IsZero() {
+			rt.LastTrigger[sym] = st.lastTriggerAt
+		}
+	}
+	liqCascade.mu.RUnlock()
+	SaveStrategyRuntime("liq_cascade", key, rt)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLiqWindow_SlidingSums(t *testing.T) {
+	var w liqWindow
+	w.add(liqEvent{ts: 1000, longLiq: true, notional: 100})
+	w.add(liqEvent{ts: 3000, longLiq: false, notional: 50})
+	w.add(liqEvent{ts: 2000, longLiq: true, notional: 200}) // 乱序到达
+	if w.events[1].ts != 2000 {
+		t.Fatalf("out-of-order event not sorted: %+v", w.events)
+	}
+
+	longLiq, shortLiq := w.sums(0, 3000)
+	if longLiq != 300 || shortLiq != 50 {
+		t.Fatalf("sums = %v/%v, want 300/50", longLiq, shortLiq)
+	}
+	// 窗口右移后最早的事件滑出
+	longLiq, _ = w.sums(1000, 4000)
+	if longLiq != 200 {
+		t.Fatalf("sliding sum = %v, want 200", longLiq)
+	}
+
+	w.prune(2500)
+	if len(w.events) != 1 || w.events[0].ts != 3000 {
+		t.Fatalf("prune left %+v", w.events)
+	}
+}
+
+func TestLiqWindow_Exhausted(t *testing.T) {
+	var w liqWindow
+	// 60s 窗口、10s 切片：峰值在 30s 前，最近 10s 明显衰减
+	w.add(liqEvent{ts: 31_000, longLiq: true, notional: 1000})
+	w.add(liqEvent{ts: 35_000, longLiq: true, notional: 500})
+	w.add(liqEvent{ts: 58_000, longLiq: true, notional: 100})
+	w.add(liqEvent{ts: 59_000, longLiq: false, notional: 5000}) // 另一方向不计入
+
+	ok, recent, peak := w.exhausted(true, 60_000, 60_000, 10_000, 0.3)
+	if !ok || recent != 100 || peak != 1500 {
+		t.Fatalf("exhausted=%v recent=%v peak=%v, want true/100/1500", ok, recent, peak)
+	}
+
+	// 最近切片仍是峰值 → 未衰竭
+	w.add(liqEvent{ts: 59_500, longLiq: true, notional: 3000})
+	if ok, _, _ := w.exhausted(true, 60_000, 60_000, 10_000, 0.3); ok {
+		t.Fatalf("cascade still accelerating should not be exhausted")
+	}
+}
+
+func TestLiqCascadeThreshold(t *testing.T) {
+	cfg := LiqCascadeConfig{Symbols: []string{"btcusdt"}, SymbolThresholds: map[string]float64{"ethusdt": 3e6}}
+	if err := normalizeLiqCascadeConfig(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Symbol != "BTCUSDT" || cfg.NormalizeBy != "oi" || cfg.ThresholdPct != 0.1 {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+
+	// OI 80 亿 × 0.1% = 800 万
+	if got := liqCascadeThreshold(cfg, "BTCUSDT", 8e9); math.Abs(got-8e6) > 1e-6 {
+		t.Fatalf("oi threshold = %v, want 8e6", got)
+	}
+	// 小币归一化结果低于下限时取下限
+	if got := liqCascadeThreshold(cfg, "BTCUSDT", 1e7); got != cfg.MinNotionalUSDT {
+		t.Fatalf("floor threshold = %v, want %v", got, cfg.MinNotionalUSDT)
+	}
+	// 基数不可用时回退绝对阈值
+	if got := liqCascadeThreshold(cfg, "BTCUSDT", 0); got != cfg.ThresholdUSDT {
+		t.Fatalf("fallback threshold = %v, want %v", got, cfg.ThresholdUSDT)
+	}
+	// 币种绝对阈值优先
+	if got := liqCascadeThreshold(cfg, "ETHUSDT", 8e9); got != 3e6 {
+		t.Fatalf("symbol threshold = %v, want 3e6", got)
+	}
+
+	bad := LiqCascadeConfig{NormalizeBy: "depth"}
+	if err := normalizeLiqCascadeConfig(&bad); err == nil {
+		t.Fatalf("expected error for invalid normalizeBy")
+	}
+}
+
+func TestLiqCascadeSignalAndExits(t *testing.T) {
+	if got := liqCascadeSignal(6e6, 1e6, 5e6); got != "LONG" {
+		t.Fatalf("long liquidation cascade signal = %q, want LONG", got)
+	}
+	if got := liqCascadeSignal(6e6, 7e6, 5e6); got != "SHORT" {
+		t.Fatalf("larger short liquidation signal = %q, want SHORT", got)
+	}
+	if got := liqCascadeSignal(1e6, 1e6, 5e6); got != "" {
+		t.Fatalf("below threshold signal = %q, want none", got)
+	}
+
+	sl, tp := liqCascadeExitLevels("LONG", 100, 2, 1.5, 2.5)
+	if sl != 97 || tp != 105 {
+		t.Fatalf("long exits = %v/%v, want 97/105", sl, tp)
+	}
+	sl, tp = liqCascadeExitLevels("SHORT", 100, 2, 1.5, 2.5)
+	if sl != 103 || tp != 95 {
+		t.Fatalf("short exits = %v/%v, want 103/95", sl, tp)
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"strings"
+)
+
+// ========== 爆仓级联：实时强平事件滑动窗口 ==========
+// 直接消费 handleLiquidationMessage 解析出的 forceOrder 事件，按币种维护真正的滑动窗口
+// forceOrder 方向：SELL = 多头被强平（价格下砸），BUY = 空头被强平（价格上冲）
+
+// liqCascadeMaxEvents 单币种窗口内最多保留的事件数，防止极端行情内存膨胀
+const liqCascadeMaxEvents = 20000
+
+// liqEvent 单笔强平事件
+type liqEvent struct {
+	ts       int64 // 毫秒
+	longLiq  bool  // true = 多头被强平（forceOrder SELL）
+	notional float64
+}
+
+// liqWindow 单币种强平事件滑动窗口（按时间升序）
+type liqWindow struct {
+	events []liqEvent
+}
+
+// add 追加事件；乱序到达的事件插入到正确位置
+func (w *liqWindow) add(e liqEvent) {
+	n := len(w.events)
+	if n == 0 || w.events[n-1].ts <= e.ts {
+		w.events = append(w.events, e)
+	} else {
+		i := n
+		for i > 0 && w.events[i-1].ts > e.ts {
+			i--
+		}
+		w.events = append(w.events, liqEvent{})
+		copy(w.events[i+1:], w.events[i:])
+		w.events[i] = e
+	}
+	if len(w.events) > liqCascadeMaxEvents {
+		w.events = append([]liqEvent(nil), w.events[len(w.events)-liqCascadeMaxEvents:]...)
+	}
+}
+
+// prune 丢弃 cutoff 之前的事件
+func (w *liqWindow) prune(cutoff int64) {
+	i := 0
+	for i < len(w.events) && w.events[i].ts < cutoff {
+		i++
+	}
+	if i > 0 {
+		w.events = append(w.events[:0], w.events[i:]...)
+	}
+}
+
+// sums 统计 (since, until] 内多头/空头被强平金额
+func (w *liqWindow) sums(since, until int64) (longLiq, shortLiq float64) {
+	for _, e := range w.events {
+		if e.ts <= since || e.ts > until {
+			continue
+		}
+		if e.longLiq {
+			longLiq += e.notional
+		} else {
+			shortLiq += e.notional
+		}
+	}
+	return longLiq, shortLiq
+}
+
+// exhausted 判断某一方向的强平是否衰竭：
+// 将 (now-window, now] 按 bucketMs 切片，最近一片的强平金额 <= 峰值片 × ratio 视为衰竭
+// 返回是否衰竭、最近一片金额与峰值
+func (w *liqWindow) exhausted(longSide bool, now, windowMs, bucketMs int64, ratio float64) (bool, float64, float64) {
+	if bucketMs <= 0 || windowMs < bucketMs {
+		return false, 0, 0
+	}
+	nb := int(windowMs / bucketMs)
+	buckets := make([]float64, nb)
+	for _, e := range w.events {
+		if e.longLiq != longSide || e.ts > now {
+			continue
+		}
+		idx := int((now - e.ts) / bucketMs)
+		if idx < nb {
+			buckets[idx] += e.notional
+		}
+	}
+	var peak float64
+	for _, v := range buckets {
+		if v > peak {
+			peak = v
+		}
+	}
+	recent := buckets[0]
+	return peak > 0 && recent <= peak*ratio, recent, peak
+}
+
+// liqCascadeThreshold 计算币种的触发阈值（USDT）
+// 优先级：symbolThresholds 绝对值 > 按 OI / 成交额归一化 > thresholdUSDT 兜底
+func liqCascadeThreshold(cfg LiqCascadeConfig, symbol string, base float64) float64 {
+	if v := cfg.SymbolThresholds[symbol]; v > 0 {
+		return v
+	}
+	if cfg.NormalizeBy == "none" || base <= 0 || cfg.ThresholdPct <= 0 {
+		return cfg.ThresholdUSDT
+	}
+	th := base * cfg.ThresholdPct / 100
+	if th < cfg.MinNotionalUSDT {
+		th = cfg.MinNotionalUSDT
+	}
+	return th
+}
+
+// liqCascadeSignal 窗口强平金额超过阈值时给出反向均值回归方向
+// 多头被大量强平 → 做多反弹；空头被大量强平 → 做空回落；两者都超过取更大的一方
+func liqCascadeSignal(longLiq, shortLiq, threshold float64) string {
+	if threshold <= 0 {
+		return ""
+	}
+	longHit := longLiq >= threshold
+	shortHit := shortLiq >= threshold
+	switch {
+	case longHit && (!shortHit || longLiq >= shortLiq):
+		return "LONG"
+	case shortHit:
+		return "SHORT"
+	}
+	return ""
+}
+
+// liqCascadeExitLevels 按 ATR 计算止损/止盈价
+func liqCascadeExitLevels(signal string, entry, atr, slMult, tpMult float64) (stopLoss, takeProfit float64) {
+	if signal == "LONG" {
+		return entry - atr*slMult, entry + atr*tpMult
+	}
+	return entry + atr*slMult, entry - atr*tpMult
+}
+
+// onLiqCascadeEvent 由 handleLiquidationMessage 调用，策略未运行或币种不在监控列表时直接返回
+func onLiqCascadeEvent(ts int64, symbol, side string, notional float64) {
+	if notional <= 0 {
+		return
+	}
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	liqCascade.mu.Lock()
+	defer liqCascade.mu.Unlock()
+	if !liqCascade.active {
+		return
+	}
+	st, ok := liqCascade.symbols[symbol]
+	if !ok {
+		return
+	}
+	st.window.add(liqEvent{
+		ts:       ts,
+		longLiq:  strings.EqualFold(strings.TrimSpace(side), "SELL"),
+		notional: notional,
+	})
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ========== OpenAI 兼容大模型客户端 ==========
+// 调用 {base_url}/chat/completions；支持多模型路由（fast / deep）与 SSE 流式输出
+
+const llmDefaultTimeout = 8 * time.Second
+
+var llmHTTP = &http.Client{}
+
+// llmMessage 对话消息
+type llmMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// resolveLLMModel 解析模型配置：tier=fast/deep
+// 启用路由时使用 fast_model / deep_model，未填写的字段继承顶层配置；未启用路由时仅有 fast 档（顶层配置）
+func resolveLLMModel(tier string) *LLMModel {
+	cfg := Cfg.LLM
+	m := LLMModel{
+		Provider:    cfg.Provider,
+		APIKey:      cfg.APIKey,
+		BaseURL:     cfg.BaseURL,
+		Model:       cfg.Model,
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+	}
+	var routed *LLMModel
+	if cfg.RouterEnabled {
+		if tier == "deep" {
+			routed = cfg.DeepModel
+		} else {
+			routed = cfg.FastModel
+		}
+	}
+	if tier == "deep" && routed == nil {
+		return nil
+	}
+	if routed != nil {
+		if routed.Provider != "" {
+			m.Provider = routed.Provider
+		}
+		if routed.APIKey != "" {
+			m.APIKey = routed.APIKey
+		}
+		if routed.BaseURL != "" {
+			m.BaseURL = routed.BaseURL
+		}
+		if routed.Model != "" {
+			m.Model = routed.Model
+		}
+		if routed.MaxTokens > 0 {
+			m.MaxTokens = routed.MaxTokens
+		}
+		if routed.Temperature > 0 {
+			m.Temperature = routed.Temperature
+		}
+	}
+	if m.BaseURL == "" {
+		switch strings.ToLower(m.Provider) {
+		case "openai":
+			m.BaseURL = "https://api.openai.com/v1"
+		case "deepseek":
+			m.BaseURL = "https://api.deepseek.com"
+		}
+	}
+	if m.BaseURL == "" || m.Model == "" {
+		return nil
+	}
+	return &m
+}
+
+// llmTimeout 单次请求超时（llm.timeout_sec，默认 8 秒）
+func llmTimeout() time.Duration {
+	if Cfg.LLM.TimeoutSec > 0 {
+		return time.Duration(Cfg.LLM.TimeoutSec) * time.Second
+	}
+	return llmDefaultTimeout
+}
+
+func newLLMRequest(ctx context.Context, m *LLMModel, messages []llmMessage, stream bool) (*http.Request, error) {
+	body := map[string]interface{}{
+		"model":       m.Model,
+		"messages":    messages,
+		"temperature": m.Temperature,
+		"stream":      stream,
+	}
+	if m.MaxTokens > 0 {
+		body["max_tokens"] = m.MaxTokens
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimRight(m.BaseURL, "/")
+	if !strings.HasSuffix(endpoint, "/chat/completions") {
+		endpoint += "/chat/completions"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if m.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.APIKey)
+	}
+	return req, nil
+}
+
+// decodeLLMCompletion 解析非流式响应，返回首条回复内容
+func decodeLLMCompletion(raw []byte) (string, error) {
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", fmt.Errorf("decode llm response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("llm response has no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
+
+// llmChat 非流式调用，返回首条回复内容
+func llmChat(ctx context.Context, m *LLMModel, messages []llmMessage, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := newLLMRequest(ctx, m, messages, false)
+	if err != nil {
+		return "", err
+	}
+	resp, err := llmHTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llm status %d: %s", resp.StatusCode, trimRunes(string(raw), 200))
+	}
+	return decodeLLMCompletion(raw)
+}
+
+// llmChatStream 流式调用，每个增量回调 onDelta，返回完整回复
+// 服务端不支持流式而直接返回 JSON 时整体回调一次
+func llmChatStream(ctx context.Context, m *LLMModel, messages []llmMessage, timeout time.Duration, onDelta func(string)) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := newLLMRequest(ctx, m, messages, true)
+	if err != nil {
+		return "", err
+	}
+	resp, err := llmHTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("llm status %d: %s", resp.StatusCode, trimRunes(string(raw), 200))
+	}
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return "", err
+		}
+		content, err := decodeLLMCompletion(raw)
+		if err == nil && onDelta != nil {
+			onDelta(content)
+		}
+		return content, err
+	}
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		if delta == "" {
+			continue
+		}
+		full.WriteString(delta)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return full.String(), fmt.Errorf("read llm stream: %w", err)
+	}
+	return full.String(), nil
+}
+
+// extractJSONObject 截取回复中首个 { 到最后一个 } 之间的内容（兼容 ```json 代码块）
+func extractJSONObject(content string) (string, bool) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return "", false
+	}
+	return content[start : end+1], true
+}
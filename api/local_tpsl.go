@@ -1099,6 +1099,79 @@ func RegisterTrailingStop(
 	return groupID, nil
 }
 
+// MoveLocalStopLoss 移动止损：groupID 下仍活跃的 STOP_LOSS 原地更新触发价、数量与均价，
+// 找不到时新建独立分组的 STOP_LOSS；返回止损所在的 groupID
+// side/positionSide 遵循平仓约定（平多 SELL，平空 BUY）
+func MoveLocalStopLoss(groupID, symbol, side, positionSide, quantity string, triggerPrice, entryPrice float64, source string) (string, error) {
+	if tpslMonitor == nil {
+		return "", fmt.Errorf("local TPSL monitor not started")
+	}
+	if triggerPrice <= 0 || quantity == "" {
+		return "", fmt.Errorf("triggerPrice and quantity are required")
+	}
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	var target *LocalTPSLCondition
+	if groupID != "" {
+		tpslMonitor.mu.Lock()
+		for _, c := range tpslMonitor.conditions[symbol] {
+			if c.GroupID == groupID && c.ConditionType == "STOP_LOSS" && c.Status == "ACTIVE" {
+				c.TriggerPrice = triggerPrice
+				c.Quantity = quantity
+				c.EntryPrice = entryPrice
+				target = c
+				break
+			}
+		}
+		tpslMonitor.mu.Unlock()
+	}
+
+	if target != nil {
+		if DB != nil {
+			DB.Model(&LocalTPSLCondition{}).Where("id = ?", target.ID).Updates(map[string]interface{}{
+				"trigger_price": triggerPrice,
+				"quantity":      quantity,
+				"entry_price":   entryPrice,
+				"updated_at":    time.Now(),
+			})
+		}
+		upsertActiveTPSLToRedis(target)
+		log.Printf("[LocalTPSL] Moved stop loss %d for %s: trigger=%.4f qty=%s", target.ID, symbol, triggerPrice, quantity)
+		return groupID, nil
+	}
+
+	if positionSide == "" {
+		positionSide = string(futures.PositionSideTypeBoth)
+	}
+	if source == "" {
+		source = "manual"
+	}
+	cond := &LocalTPSLCondition{
+		GroupID:       uuid.New().String(),
+		Symbol:        symbol,
+		ConditionType: "STOP_LOSS",
+		Side:          side,
+		PositionSide:  positionSide,
+		TriggerPrice:  triggerPrice,
+		Quantity:      quantity,
+		EntryPrice:    entryPrice,
+		LevelIndex:    -1,
+		TotalLevels:   1,
+		Status:        "ACTIVE",
+		Source:        source,
+	}
+	if DB != nil {
+		if err := DB.Create(cond).Error; err != nil {
+			return "", fmt.Errorf("save stop loss: %w", err)
+		}
+	}
+	tpslMonitor.addToMemory(cond)
+	_ = GetPriceCache().Subscribe(symbol)
+
+	log.Printf("[LocalTPSL] Registered stop loss for %s: trigger=%.4f qty=%s groupID=%s", symbol, triggerPrice, quantity, cond.GroupID)
+	return cond.GroupID, nil
+}
+
 // --- HTTP Handlers ---
 
 // HandleGetTPSLList GET /tool/tpsl/list?symbol=BTCUSDT
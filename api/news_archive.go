@@ -0,0 +1,511 @@
+package api
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"gorm.io/gorm/clause"
+)
+
+// ========== 新闻归档与价格反应 ==========
+// 每条抓取/推送的新闻写入 Postgres（来源、发布时间、币种标注），支持全文检索；
+// 后台根据 1m K 线计算发布后 5m / 1h / 4h 的价格变动，用于衡量各来源对行情的影响
+
+const (
+	newsArchiveQueueSize   = 64
+	newsArchiveSeenMax     = 50000
+	newsReactionInterval   = time.Minute
+	newsReactionBatch      = 100
+	newsReactionMacroRef   = "BTCUSDT" // 未标注币种的宏观新闻以 BTC 衡量反应
+	newsReactionMaxAge     = 7 * 24 * time.Hour
+	newsSearchDefaultLimit = 50
+	newsSearchMaxLimit     = 500
+)
+
+// newsReactionHorizons 价格反应观察窗口
+var newsReactionHorizons = []struct {
+	name   string
+	d      time.Duration
+	column string // 对应的 evaluatedX 列
+}{
+	{"5m", 5 * time.Minute, "evaluated5_m"},
+	{"1h", time.Hour, "evaluated1_h"},
+	{"4h", 4 * time.Hour, "evaluated4_h"},
+}
+
+// NewsArchiveRecord 新闻归档
+type NewsArchiveRecord struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UID         string    `gorm:"type:varchar(40);uniqueIndex" json:"uid"` // sha1(来源 key + 链接/ID/标题)
+	Source      string    `gorm:"type:varchar(64);index" json:"source"`    // 资讯源 key
+	SourceName  string    `gorm:"type:varchar(100)" json:"sourceName"`
+	ExternalID  string    `gorm:"type:varchar(255)" json:"externalId"`
+	Title       string    `gorm:"type:text" json:"title"`
+	Summary     string    `gorm:"type:text" json:"summary"`
+	Link        string    `gorm:"type:text" json:"link"`
+	TitleHash   string    `gorm:"type:varchar(16);index" json:"titleHash"` // 标题 simhash，跨来源近似重复分析
+	Symbols     string    `gorm:"type:varchar(200);index" json:"symbols"`  // 标注的交易对，格式 ,BTCUSDT,ETHUSDT,
+	PublishedAt time.Time `gorm:"index" json:"publishedAt"`                // 发布时间（缺失时为首次抓取时间）
+	FirstSeenAt time.Time `json:"firstSeenAt"`
+
+	RefSymbol   string  `gorm:"type:varchar(20)" json:"refSymbol"` // 计算价格反应的交易对
+	BasePrice   float64 `gorm:"type:numeric(36,8)" json:"basePrice"`
+	Ret5M       float64 `gorm:"type:numeric(10,4)" json:"ret5m"` // 发布后涨跌幅（%）
+	Ret1H       float64 `gorm:"type:numeric(10,4)" json:"ret1h"`
+	Ret4H       float64 `gorm:"type:numeric(10,4)" json:"ret4h"`
+	Evaluated5M bool    `gorm:"default:false" json:"evaluated5m"`
+	Evaluated1H bool    `gorm:"default:false" json:"evaluated1h"`
+	Evaluated4H bool    `gorm:"default:false" json:"evaluated4h"`
+	Final       bool    `gorm:"default:false;index" json:"final"` // 反应计算完成（或无法计算）
+	ReactionErr string  `gorm:"type:varchar(200)" json:"reactionErr,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (NewsArchiveRecord) TableName() string { return "news_archive" }
+
+// SymbolList 标注的交易对列表
+func (r NewsArchiveRecord) SymbolList() []string {
+	var out []string
+	for _, s := range strings.Split(r.Symbols, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+var (
+	newsArchiveStarted atomic.Bool
+	newsArchiveQueue   = make(chan map[string][]newsItem, newsArchiveQueueSize)
+	newsArchiveOnce    sync.Once
+
+	// newsArchiveSeen 已写入的 UID，避免每轮抓取重复写库
+	newsArchiveSeen = struct {
+		mu   sync.Mutex
+		uids map[string]struct{}
+	}{uids: make(map[string]struct{})}
+)
+
+// StartNewsArchive 启动新闻归档写入与价格反应计算（需要 DB）
+func StartNewsArchive() {
+	if DB == nil {
+		return
+	}
+	newsArchiveOnce.Do(func() {
+		newsArchiveStarted.Store(true)
+		go runNewsArchiveWriter()
+		go runNewsReactionLoop()
+		log.Printf("[NewsArchive] Started")
+	})
+}
+
+// archiveNews 异步归档本轮抓取/推送的新闻；队列满时丢弃（下轮抓取仍会带上这些条目）
+func archiveNews(latest map[string][]newsItem) {
+	if !newsArchiveStarted.Load() || len(latest) == 0 {
+		return
+	}
+	select {
+	case newsArchiveQueue <- latest:
+	default:
+		log.Printf("[NewsArchive] queue full, skip %d sources", len(latest))
+	}
+}
+
+func runNewsArchiveWriter() {
+	for batch := range newsArchiveQueue {
+		if err := saveNewsArchive(batch, time.Now()); err != nil {
+			log.Printf("[NewsArchive] save failed: %v", err)
+		}
+	}
+}
+
+func newsArchiveUID(sourceKey string, item newsItem) string {
+	sum := sha1.Sum([]byte(sourceKey + "|" + chooseValue(item.Link, item.ID, item.Title)))
+	return hex.EncodeToString(sum[:])
+}
+
+// buildNewsArchiveRecord 新闻转归档记录；未来的发布时间按首次抓取时间处理
+func buildNewsArchiveRecord(sourceKey string, item newsItem, now time.Time) NewsArchiveRecord {
+	published := now
+	if ts := parseNewsTime(item.PubDate); ts > 0 && ts <= now.Add(5*time.Minute).UnixMilli() {
+		published = time.UnixMilli(ts)
+	}
+	symbols := tagNewsSymbols(item.Title + " " + item.Summary)
+	ref := newsReactionMacroRef
+	if len(symbols) > 0 {
+		ref = symbols[0]
+	}
+	rec := NewsArchiveRecord{
+		UID:         newsArchiveUID(sourceKey, item),
+		Source:      sourceKey,
+		SourceName:  trimRunes(item.Source, 100),
+		ExternalID:  trimRunes(item.ID, 255),
+		Title:       strings.TrimSpace(item.Title),
+		Summary:     trimRunes(item.Summary, 2000),
+		Link:        strings.TrimSpace(item.Link),
+		TitleHash:   fmt.Sprintf("%016x", newsSimhash(item.Title)),
+		PublishedAt: published.UTC(),
+		FirstSeenAt: now.UTC(),
+		RefSymbol:   ref,
+	}
+	if len(symbols) > 0 {
+		rec.Symbols = "," + strings.Join(symbols, ",") + ","
+	}
+	return rec
+}
+
+// saveNewsArchive 写入未归档过的新闻（UID 冲突忽略）
+func saveNewsArchive(batch map[string][]newsItem, now time.Time) error {
+	if DB == nil {
+		return nil
+	}
+	var rows []NewsArchiveRecord
+	newsArchiveSeen.mu.Lock()
+	for sourceKey, list := range batch {
+		for _, item := range list {
+			if strings.TrimSpace(item.Title) == "" {
+				continue
+			}
+			uid := newsArchiveUID(sourceKey, item)
+			if _, ok := newsArchiveSeen.uids[uid]; ok {
+				continue
+			}
+			rows = append(rows, buildNewsArchiveRecord(sourceKey, item, now))
+		}
+	}
+	newsArchiveSeen.mu.Unlock()
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uid"}},
+		DoNothing: true,
+	}).CreateInBatches(&rows, 200).Error; err != nil {
+		return err
+	}
+
+	newsArchiveSeen.mu.Lock()
+	if len(newsArchiveSeen.uids)+len(rows) > newsArchiveSeenMax {
+		newsArchiveSeen.uids = make(map[string]struct{})
+	}
+	for _, r := range rows {
+		newsArchiveSeen.uids[r.UID] = struct{}{}
+	}
+	newsArchiveSeen.mu.Unlock()
+	return nil
+}
+
+// ========== 价格反应 ==========
+
+func runNewsReactionLoop() {
+	time.Sleep(30 * time.Second)
+	evaluateNewsReactions()
+
+	ticker := time.NewTicker(newsReactionInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		evaluateNewsReactions()
+	}
+}
+
+// newsReaction 由发布时刻起的 1m K 线计算基准价与各窗口涨跌幅（%）
+// 基准价为发布所在分钟 K 线的开盘价，窗口价格为 发布分钟+窗口 前一根 K 线的收盘价；K 线不足的窗口不返回
+func newsReaction(klines []*futures.Kline, publishedAt time.Time) (base float64, rets map[string]float64) {
+	rets = make(map[string]float64)
+	start := publishedAt.Truncate(time.Minute).UnixMilli()
+	closes := make(map[int64]float64, len(klines))
+	for _, k := range klines {
+		if k.OpenTime == start {
+			base = mustParseFloat(k.Open)
+		}
+		closes[k.OpenTime] = mustParseFloat(k.Close)
+	}
+	if base <= 0 {
+		return 0, rets
+	}
+	for _, h := range newsReactionHorizons {
+		if c, ok := closes[start+h.d.Milliseconds()-time.Minute.Milliseconds()]; ok && c > 0 {
+			rets[h.name] = roundFloat((c-base)/base*100, 4)
+		}
+	}
+	return base, rets
+}
+
+// evaluateNewsReactions 为到期窗口尚未计算的归档新闻补算价格反应
+func evaluateNewsReactions() {
+	if DB == nil || Client == nil {
+		return
+	}
+	now := time.Now()
+	dueSQL, dueArgs := newsReactionDueClause(now)
+	var rows []NewsArchiveRecord
+	// 只取至少有一个窗口已到期的行、从最早的开始：新发布的新闻不会占满批次饿死早已到期的 1h/4h 窗口
+	if err := DB.Where("final = ? AND published_at >= ?", false, now.Add(-newsReactionMaxAge)).
+		Where(dueSQL, dueArgs...).
+		Order("published_at ASC").
+		Limit(newsReactionBatch).
+		Find(&rows).Error; err != nil {
+		log.Printf("[NewsArchive] load pending reactions failed: %v", err)
+		return
+	}
+
+	for _, r := range rows {
+		updates := map[string]any{}
+		elapsed := now.Sub(r.PublishedAt)
+		due := (elapsed >= 5*time.Minute && !r.Evaluated5M) ||
+			(elapsed >= time.Hour && !r.Evaluated1H) ||
+			(elapsed >= 4*time.Hour && !r.Evaluated4H)
+		if !due {
+			continue
+		}
+
+		start := r.PublishedAt.Truncate(time.Minute)
+		end := start.Add(newsReactionHorizons[len(newsReactionHorizons)-1].d)
+		if end.After(now) {
+			end = now
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		klines, err := Client.NewKlinesService().Symbol(r.RefSymbol).Interval("1m").
+			StartTime(start.UnixMilli()).EndTime(end.UnixMilli()).Limit(250).Do(ctx)
+		cancel()
+		if err != nil {
+			// 交易对不存在等不可恢复错误：超过最长窗口后放弃
+			if elapsed > 2*newsReactionHorizons[len(newsReactionHorizons)-1].d {
+				updates["final"] = true
+				updates["reaction_err"] = trimRunes(err.Error(), 200)
+				DB.Model(&NewsArchiveRecord{}).Where("id = ?", r.ID).Updates(updates)
+			}
+			continue
+		}
+
+		base, rets := newsReaction(klines, r.PublishedAt)
+		if base <= 0 {
+			if elapsed > 2*newsReactionHorizons[len(newsReactionHorizons)-1].d {
+				updates["final"] = true
+				updates["reaction_err"] = "no kline at publish time"
+				DB.Model(&NewsArchiveRecord{}).Where("id = ?", r.ID).Updates(updates)
+			}
+			continue
+		}
+		updates["base_price"] = base
+		if v, ok := rets["5m"]; ok && !r.Evaluated5M {
+			updates["ret5_m"] = v
+			updates["evaluated5_m"] = true
+		}
+		if v, ok := rets["1h"]; ok && !r.Evaluated1H {
+			updates["ret1_h"] = v
+			updates["evaluated1_h"] = true
+		}
+		if v, ok := rets["4h"]; ok && !r.Evaluated4H {
+			updates["ret4_h"] = v
+			updates["evaluated4_h"] = true
+			updates["final"] = true
+		}
+		if err := DB.Model(&NewsArchiveRecord{}).Where("id = ?", r.ID).Updates(updates).Error; err != nil {
+			log.Printf("[NewsArchive] update reaction failed id=%d err=%v", r.ID, err)
+		}
+	}
+}
+
+// newsReactionDueClause 任一窗口到期且未计算：((evaluated5_m = false AND published_at <= now-5m) OR ...)
+func newsReactionDueClause(now time.Time) (string, []any) {
+	parts := make([]string, 0, len(newsReactionHorizons))
+	args := make([]any, 0, 2*len(newsReactionHorizons))
+	for _, h := range newsReactionHorizons {
+		parts = append(parts, "("+h.column+" = ? AND published_at <= ?)")
+		args = append(args, false, now.Add(-h.d))
+	}
+	return "(" + strings.Join(parts, " OR ") + ")", args
+}
+
+// ========== 检索 ==========
+
+// NewsSearchQuery 检索条件
+type NewsSearchQuery struct {
+	Q      string
+	From   time.Time
+	To     time.Time
+	Source string
+	Symbol string
+	Limit  int
+	Offset int
+}
+
+// NewsSearchResult 检索结果
+type NewsSearchResult struct {
+	Total int64               `json:"total"`
+	Items []NewsArchiveRecord `json:"items"`
+}
+
+// newsSearchLikePattern 转义 LIKE 通配符
+func newsSearchLikePattern(q string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(q) + "%"
+}
+
+// SearchNews 全文检索归档新闻：英文等按词匹配（to_tsvector），中文等无分词文本按子串匹配
+func SearchNews(q NewsSearchQuery) (NewsSearchResult, error) {
+	res := NewsSearchResult{Items: []NewsArchiveRecord{}}
+	if DB == nil {
+		return res, fmt.Errorf("database not initialized")
+	}
+	if q.Limit <= 0 {
+		q.Limit = newsSearchDefaultLimit
+	}
+	if q.Limit > newsSearchMaxLimit {
+		q.Limit = newsSearchMaxLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	tx := DB.Model(&NewsArchiveRecord{})
+	if text := strings.TrimSpace(q.Q); text != "" {
+		like := newsSearchLikePattern(text)
+		tx = tx.Where(
+			"to_tsvector('simple', coalesce(title,'') || ' ' || coalesce(summary,'')) @@ plainto_tsquery('simple', ?) OR title ILIKE ? OR summary ILIKE ?",
+			text, like, like,
+		)
+	}
+	if !q.From.IsZero() {
+		tx = tx.Where("published_at >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		tx = tx.Where("published_at < ?", q.To)
+	}
+	if q.Source != "" {
+		tx = tx.Where("source = ?", q.Source)
+	}
+	if sym := strings.ToUpper(strings.TrimSpace(q.Symbol)); sym != "" {
+		if !strings.HasSuffix(sym, "USDT") {
+			sym += "USDT"
+		}
+		tx = tx.Where("symbols LIKE ?", "%,"+sym+",%")
+	}
+
+	if err := tx.Count(&res.Total).Error; err != nil {
+		return res, err
+	}
+	err := tx.Order("published_at DESC").Limit(q.Limit).Offset(q.Offset).Find(&res.Items).Error
+	return res, err
+}
+
+// ensureNewsArchiveSearchIndex 全文检索 GIN 索引
+func ensureNewsArchiveSearchIndex() error {
+	return DB.Exec(`CREATE INDEX IF NOT EXISTS idx_news_archive_fts ON news_archive USING GIN (to_tsvector('simple', coalesce(title,'') || ' ' || coalesce(summary,'')))`).Error
+}
+
+// NewsSourceImpact 来源影响力：发布后价格绝对变动的均值
+type NewsSourceImpact struct {
+	Source      string  `json:"source"`
+	Count       int64   `json:"count"`
+	Evaluated   int64   `json:"evaluated"` // 已完成 1h 反应计算的条数
+	AvgAbsRet5M float64 `json:"avgAbsRet5m"`
+	AvgAbsRet1H float64 `json:"avgAbsRet1h"`
+	AvgAbsRet4H float64 `json:"avgAbsRet4h"`
+}
+
+// GetNewsSourceImpact 按来源汇总价格反应，按 1h 平均绝对变动降序
+func GetNewsSourceImpact(from, to time.Time) ([]NewsSourceImpact, error) {
+	out := []NewsSourceImpact{}
+	if DB == nil {
+		return out, fmt.Errorf("database not initialized")
+	}
+	err := DB.Model(&NewsArchiveRecord{}).
+		Select(`source,
+			COUNT(*) AS count,
+			COUNT(*) FILTER (WHERE evaluated1_h) AS evaluated,
+			COALESCE(AVG(ABS(ret5_m)) FILTER (WHERE evaluated5_m), 0) AS avg_abs_ret5_m,
+			COALESCE(AVG(ABS(ret1_h)) FILTER (WHERE evaluated1_h), 0) AS avg_abs_ret1_h,
+			COALESCE(AVG(ABS(ret4_h)) FILTER (WHERE evaluated4_h), 0) AS avg_abs_ret4_h`).
+		Where("published_at >= ? AND published_at < ?", from, to).
+		Group("source").
+		Order("avg_abs_ret1_h DESC").
+		Scan(&out).Error
+	return out, err
+}
+
+// parseNewsQueryTime 解析查询时间：毫秒时间戳或 parseFlexibleTime 支持的格式
+func parseNewsQueryTime(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return parseFlexibleTime(raw)
+}
+
+// HandleSearchNews GET /tool/news/search?q=&from=&to=&source=&symbol=&limit=50&offset=0
+func HandleSearchNews(c context.Context, ctx *app.RequestContext) {
+	_ = c
+	from, err := parseNewsQueryTime(ctx.Query("from"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "invalid from"})
+		return
+	}
+	to, err := parseNewsQueryTime(ctx.Query("to"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "invalid to"})
+		return
+	}
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	res, err := SearchNews(NewsSearchQuery{
+		Q:      ctx.Query("q"),
+		From:   from,
+		To:     to,
+		Source: strings.TrimSpace(ctx.Query("source")),
+		Symbol: ctx.Query("symbol"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": res})
+}
+
+// HandleNewsSourceImpact GET /tool/news/impact?from=&to=（默认最近 30 天）
+func HandleNewsSourceImpact(c context.Context, ctx *app.RequestContext) {
+	_ = c
+	from, err := parseNewsQueryTime(ctx.Query("from"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "invalid from"})
+		return
+	}
+	to, err := parseNewsQueryTime(ctx.Query("to"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "invalid to"})
+		return
+	}
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+	data, err := GetNewsSourceImpact(from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": data})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestNewsReaction(t *testing.T) {
+	pub := time.Date(2026, 10, 1, 12, 0, 30, 0, time.UTC)
+	start := pub.Truncate(time.Minute)
+	var klines []*futures.Kline
+	for i := 0; i < 60; i++ {
+		open := 100 + float64(i)
+		klines = append(klines, &futures.Kline{
+			OpenTime: start.Add(time.Duration(i) * time.Minute).UnixMilli(),
+			Open:     strconv.FormatFloat(open, 'f', 2, 64),
+			Close:    strconv.FormatFloat(open+1, 'f', 2, 64),
+		})
+	}
+
+	base, rets := newsReaction(klines, pub)
+	if base != 100 {
+		t.Fatalf("base=%v want 100", base)
+	}
+	// 5m 窗口取第 5 根（下标 4）收盘价 105
+	if rets["5m"] != 5 {
+		t.Fatalf("ret5m=%v want 5", rets["5m"])
+	}
+	// 1h 窗口取第 60 根收盘价 160
+	if rets["1h"] != 60 {
+		t.Fatalf("ret1h=%v want 60", rets["1h"])
+	}
+	if _, ok := rets["4h"]; ok {
+		t.Fatalf("4h should be missing without enough klines: %+v", rets)
+	}
+
+	if base, _ := newsReaction(klines[1:], pub); base != 0 {
+		t.Fatalf("missing publish kline should give zero base, got %v", base)
+	}
+}
+
+func TestNewsReactionDueClause(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	sql, args := newsReactionDueClause(now)
+	want := "((evaluated5_m = ? AND published_at <= ?) OR (evaluated1_h = ? AND published_at <= ?) OR (evaluated4_h = ? AND published_at <= ?))"
+	if sql != want || len(args) != 6 {
+		t.Fatalf("sql=%s args=%v", sql, args)
+	}
+	if args[0] != false || args[5] != now.Add(-4*time.Hour) {
+		t.Fatalf("args=%v", args)
+	}
+}
+
+func TestBuildNewsArchiveRecord(t *testing.T) {
+	withNewsSymbolUniverse(t, []string{"BTCUSDT", "ETHUSDT"})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	item := newsItem{ID: "1", Title: "$ETH ETF inflows hit record", Link: "https://x/1", PubDate: "2026-10-01T11:30:00Z", Source: "Feed"}
+	rec := buildNewsArchiveRecord("feed", item, now)
+	if rec.Symbols != ",ETHUSDT," || rec.RefSymbol != "ETHUSDT" {
+		t.Fatalf("symbols=%q ref=%q", rec.Symbols, rec.RefSymbol)
+	}
+	if !rec.PublishedAt.Equal(now.Add(-30 * time.Minute)) {
+		t.Fatalf("publishedAt=%v", rec.PublishedAt)
+	}
+	if rec.UID != newsArchiveUID("feed", item) || rec.UID == newsArchiveUID("other", item) {
+		t.Fatalf("uid should be stable per source")
+	}
+
+	// 未来时间与无币种：按首次抓取时间、BTC 衡量
+	future := newsItem{Title: "Fed holds rates", PubDate: "2026-10-02T00:00:00Z"}
+	rec = buildNewsArchiveRecord("feed", future, now)
+	if !rec.PublishedAt.Equal(now) || rec.RefSymbol != newsReactionMacroRef || rec.Symbols != "" {
+		t.Fatalf("future record: %+v", rec)
+	}
+}
+
+func TestParseNewsQueryTime(t *testing.T) {
+	if ts, err := parseNewsQueryTime("1759320000000"); err != nil || ts.UnixMilli() != 1759320000000 {
+		t.Fatalf("ms: %v %v", ts, err)
+	}
+	if ts, err := parseNewsQueryTime("2026-10-01"); err != nil || ts.Day() != 1 {
+		t.Fatalf("date: %v %v", ts, err)
+	}
+	if ts, err := parseNewsQueryTime(""); err != nil || !ts.IsZero() {
+		t.Fatalf("empty: %v %v", ts, err)
+	}
+	if _, err := parseNewsQueryTime("yesterday"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if got := newsSearchLikePattern("50%_off"); got != `%50\%\_off%` {
+		t.Fatalf("like pattern=%q", got)
+	}
+}
@@ -0,0 +1,464 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ========== 新闻去重聚类与币种标注 ==========
+// 多个来源（RSSHub、Telegram 频道等）转载同一条新闻时，按标题/正文 simhash 聚成一簇；
+// 根据交易所 USDT 永续合约列表把新闻中的币种映射为交易对，并按币种建立新闻时间线
+
+const (
+	newsShingleSize        = 3   // 字符 shingle 长度
+	newsSimhashThreshold   = 6   // 海明距离不超过该值视为近似重复
+	newsSummaryShingleRune = 300 // 参与正文指纹的摘要最大字符数
+	newsUniverseTTL        = time.Hour
+	newsUniverseRetry      = time.Minute
+	newsSymbolTimelineMax  = 200
+	newsIndexMaxItems      = 3000 // 时间线只索引最近的条目，避免历史快照过大时聚类开销过高
+)
+
+// newsTickerStopwords 与常见英文缩写/单词重名的代码，仅在 $ 标签或别名中出现时才识别
+var newsTickerStopwords = map[string]bool{
+	"ETF": true, "SEC": true, "CEO": true, "USD": true, "THE": true, "AND": true,
+	"FOR": true, "NEW": true, "ALL": true, "TOP": true, "BIG": true, "NOW": true,
+	"ONE": true, "CAT": true, "DOG": true, "GAS": true, "WIN": true, "API": true,
+	"FED": true, "CPI": true, "GDP": true, "USA": true, "NFT": true, "AI": true,
+}
+
+// newsFingerprint 新闻指纹：标题与正文（标题+摘要）各一个 simhash
+type newsFingerprint struct {
+	title   uint64
+	body    uint64
+	hasBody bool
+}
+
+// newsShingles 归一化文本（小写、去标点空白）后切分字符 shingle
+func newsShingles(text string) []string {
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	if len(runes) == 0 {
+		return nil
+	}
+	if len(runes) <= newsShingleSize {
+		return []string{string(runes)}
+	}
+	out := make([]string, 0, len(runes)-newsShingleSize+1)
+	for i := 0; i+newsShingleSize <= len(runes); i++ {
+		out = append(out, string(runes[i:i+newsShingleSize]))
+	}
+	return out
+}
+
+// newsSimhash 64 位 simhash；文本为空时返回 0
+func newsSimhash(text string) uint64 {
+	shingles := newsShingles(text)
+	if len(shingles) == 0 {
+		return 0
+	}
+	var weights [64]int
+	for _, s := range shingles {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(s))
+		v := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if v&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var out uint64
+	for i, w := range weights {
+		if w > 0 {
+			out |= 1 << uint(i)
+		}
+	}
+	return out
+}
+
+func newsHamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func fingerprintNews(item newsItem) newsFingerprint {
+	fp := newsFingerprint{title: newsSimhash(item.Title)}
+	if summary := strings.TrimSpace(item.Summary); summary != "" {
+		fp.body = newsSimhash(item.Title + " " + trimRunes(summary, newsSummaryShingleRune))
+		fp.hasBody = true
+	}
+	return fp
+}
+
+// nearDuplicate 标题相近，或双方都有摘要且正文相近
+func (fp newsFingerprint) nearDuplicate(other newsFingerprint) bool {
+	if fp.title != 0 && other.title != 0 && newsHamming(fp.title, other.title) <= newsSimhashThreshold {
+		return true
+	}
+	return fp.hasBody && other.hasBody && newsHamming(fp.body, other.body) <= newsSimhashThreshold
+}
+
+// ========== 币种实体识别 ==========
+
+// newsSymbolUniverseState 交易所 USDT 永续合约：基础币种 → 交易对
+type newsSymbolUniverseState struct {
+	mu       sync.RWMutex
+	bases    map[string]string
+	loadedAt time.Time
+}
+
+var newsUniverse = &newsSymbolUniverseState{}
+
+// setNewsSymbolUniverse 替换币种表（symbols 为交易对列表）
+func setNewsSymbolUniverse(symbols []string) {
+	bases := make(map[string]string, len(symbols))
+	for _, sym := range symbols {
+		sym = strings.ToUpper(strings.TrimSpace(sym))
+		base := strings.TrimSuffix(sym, "USDT")
+		if base == "" || base == sym {
+			continue
+		}
+		bases[base] = sym
+		// 1000PEPEUSDT 等合约同时登记去掉倍数前缀的代码
+		for _, prefix := range []string{"1000000", "1000", "1M"} {
+			if trimmed := strings.TrimPrefix(base, prefix); trimmed != base && trimmed != "" {
+				if _, ok := bases[trimmed]; !ok {
+					bases[trimmed] = sym
+				}
+			}
+		}
+	}
+	newsUniverse.mu.Lock()
+	newsUniverse.bases = bases
+	newsUniverse.loadedAt = time.Now()
+	newsUniverse.mu.Unlock()
+}
+
+// newsSymbolBases 返回币种表，过期时从交易所信息刷新；拉取失败沿用旧表，一分钟后重试
+func newsSymbolBases() map[string]string {
+	newsUniverse.mu.RLock()
+	bases, loadedAt := newsUniverse.bases, newsUniverse.loadedAt
+	newsUniverse.mu.RUnlock()
+
+	ttl := newsUniverseTTL
+	if len(bases) == 0 {
+		ttl = newsUniverseRetry
+	}
+	if time.Since(loadedAt) < ttl || Client == nil {
+		return bases
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	info, err := getExchangeInfoCached(ctx)
+	if err != nil {
+		newsUniverse.mu.Lock()
+		newsUniverse.loadedAt = time.Now().Add(newsUniverseRetry - ttl)
+		newsUniverse.mu.Unlock()
+		return bases
+	}
+	var symbols []string
+	for _, s := range info.Symbols {
+		if s.QuoteAsset == "USDT" && s.ContractType == "PERPETUAL" && s.Status == "TRADING" {
+			symbols = append(symbols, s.Symbol)
+		}
+	}
+	setNewsSymbolUniverse(symbols)
+
+	newsUniverse.mu.RLock()
+	defer newsUniverse.mu.RUnlock()
+	return newsUniverse.bases
+}
+
+// tagNewsSymbols 识别文本中的币种并映射为交易所交易对
+// 候选来自 $ 标签、币种别名、大写代码；币种表可用时只保留交易所存在的交易对
+func tagNewsSymbols(text string) []string {
+	bases := newsSymbolBases()
+	var out []string
+	add := func(code string, known bool) {
+		code = strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(code, "$")))
+		if code == "" {
+			return
+		}
+		if len(bases) == 0 {
+			if known {
+				out = appendNewsSymbol(out, code)
+			}
+			return
+		}
+		if sym, ok := bases[code]; ok {
+			out = appendNewsSymbol(out, sym)
+		}
+	}
+
+	for _, m := range newsCashtagRe.FindAllStringSubmatch(text, -1) {
+		add(m[1], true)
+	}
+	lower := strings.ToLower(text)
+	for name, code := range newsSymbolAliases {
+		if strings.Contains(lower, name) {
+			add(code, true)
+		}
+	}
+	for _, w := range newsTickerRe.FindAllString(text, -1) {
+		if newsKnownTickers[w] {
+			add(w, true)
+		} else if len(w) >= 3 && !newsTickerStopwords[w] {
+			add(w, false)
+		}
+	}
+	return out
+}
+
+// validNewsSymbol 交易对是否存在于币种表（币种表不可用时不做过滤）
+func validNewsSymbol(symbol string) bool {
+	bases := newsSymbolBases()
+	if len(bases) == 0 {
+		return true
+	}
+	base := strings.TrimSuffix(strings.ToUpper(symbol), "USDT")
+	return bases[base] == strings.ToUpper(symbol)
+}
+
+// ========== 聚类 ==========
+
+// NewsClusterItem 簇内的单条来源新闻
+type NewsClusterItem struct {
+	Source  string `json:"source"`
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Link    string `json:"link"`
+	PubTime int64  `json:"pubTime"`
+}
+
+// NewsCluster 近似重复新闻簇，代表条目为最早发布的一条
+type NewsCluster struct {
+	ID        string            `json:"id"` // 代表条目标题 simhash
+	Title     string            `json:"title"`
+	Summary   string            `json:"summary"`
+	Link      string            `json:"link"`
+	FirstSeen int64             `json:"firstSeen"` // 最早发布时间（毫秒）
+	LastSeen  int64             `json:"lastSeen"`
+	Sources   []string          `json:"sources"`
+	Symbols   []string          `json:"symbols"`
+	Items     []NewsClusterItem `json:"items"`
+
+	rep newsItem
+	fp  newsFingerprint
+}
+
+// clusterNews 按来源快照聚类；发布时间缺失时使用 fallbackTs
+// 返回按最早发布时间倒序的簇列表
+func clusterNews(data map[string][]newsItem, fallbackTs int64) []*NewsCluster {
+	type entry struct {
+		source string
+		item   newsItem
+		ts     int64
+	}
+	var entries []entry
+	for source, list := range data {
+		for _, item := range list {
+			if strings.TrimSpace(item.Title) == "" {
+				continue
+			}
+			ts := parseNewsTime(item.PubDate)
+			if ts <= 0 {
+				ts = fallbackTs
+			}
+			entries = append(entries, entry{source: source, item: item, ts: ts})
+		}
+	}
+	// 先发布的作为代表；同一时间按来源排序保证结果稳定
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].ts != entries[j].ts {
+			return entries[i].ts < entries[j].ts
+		}
+		return entries[i].source < entries[j].source
+	})
+
+	var clusters []*NewsCluster
+	byLink := make(map[string]*NewsCluster)
+	for _, e := range entries {
+		fp := fingerprintNews(e.item)
+		var target *NewsCluster
+		if e.item.Link != "" {
+			target = byLink[e.item.Link]
+		}
+		if target == nil {
+			for _, c := range clusters {
+				if c.fp.nearDuplicate(fp) {
+					target = c
+					break
+				}
+			}
+		}
+		if target == nil {
+			target = &NewsCluster{
+				ID:        fmt.Sprintf("%016x", fp.title),
+				Title:     strings.TrimSpace(e.item.Title),
+				Summary:   trimRunes(strings.TrimSpace(e.item.Summary), 200),
+				Link:      e.item.Link,
+				FirstSeen: e.ts,
+				rep:       e.item,
+				fp:        fp,
+			}
+			clusters = append(clusters, target)
+		}
+		if e.item.Link != "" {
+			byLink[e.item.Link] = target
+		}
+
+		target.Items = append(target.Items, NewsClusterItem{
+			Source:  e.source,
+			ID:      e.item.ID,
+			Title:   e.item.Title,
+			Link:    e.item.Link,
+			PubTime: e.ts,
+		})
+		if e.ts > target.LastSeen {
+			target.LastSeen = e.ts
+		}
+		if !containsString(target.Sources, e.source) {
+			target.Sources = append(target.Sources, e.source)
+		}
+		for _, sym := range tagNewsSymbols(e.item.Title + " " + e.item.Summary) {
+			if !containsString(target.Symbols, sym) {
+				target.Symbols = append(target.Symbols, sym)
+			}
+		}
+		if target.Summary == "" && strings.TrimSpace(e.item.Summary) != "" {
+			target.Summary = trimRunes(strings.TrimSpace(e.item.Summary), 200)
+		}
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].FirstSeen > clusters[j].FirstSeen
+	})
+	return clusters
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ========== 币种时间线 ==========
+
+// newsIndexState 基于资讯快照构建的聚类与币种索引，快照更新后惰性重建
+type newsIndexState struct {
+	mu        sync.Mutex
+	updatedAt int64
+	builtAt   time.Time
+	clusters  []*NewsCluster
+	bySymbol  map[string][]*NewsCluster
+}
+
+var newsIndex = &newsIndexState{}
+
+// SymbolNewsTimeline 单币种新闻时间线
+type SymbolNewsTimeline struct {
+	Symbol    string         `json:"symbol"`
+	Total     int            `json:"total"`
+	Items     []*NewsCluster `json:"items"`
+	UpdatedAt int64          `json:"updatedAt"`
+}
+
+// getNewsIndex 快照未变化且币种表未过期时复用已有索引
+func getNewsIndex() ([]*NewsCluster, map[string][]*NewsCluster, int64) {
+	ensureNewsSnapshotLoaded()
+	data, updatedAt := cloneNewsSnapshot()
+
+	newsIndex.mu.Lock()
+	defer newsIndex.mu.Unlock()
+	if newsIndex.bySymbol != nil && newsIndex.updatedAt == updatedAt && time.Since(newsIndex.builtAt) < newsUniverseTTL {
+		return newsIndex.clusters, newsIndex.bySymbol, updatedAt
+	}
+
+	clusters := clusterNews(recentNewsItems(data, updatedAt, newsIndexMaxItems), updatedAt)
+	bySymbol := make(map[string][]*NewsCluster)
+	for _, c := range clusters {
+		for _, sym := range c.Symbols {
+			if len(bySymbol[sym]) < newsSymbolTimelineMax {
+				bySymbol[sym] = append(bySymbol[sym], c)
+			}
+		}
+	}
+	newsIndex.clusters = clusters
+	newsIndex.bySymbol = bySymbol
+	newsIndex.updatedAt = updatedAt
+	newsIndex.builtAt = time.Now()
+	return clusters, bySymbol, updatedAt
+}
+
+// recentNewsItems 各来源合计只保留发布时间最新的 max 条
+func recentNewsItems(data map[string][]newsItem, fallbackTs int64, max int) map[string][]newsItem {
+	type ref struct {
+		source string
+		idx    int
+		ts     int64
+	}
+	var refs []ref
+	for source, list := range data {
+		for i, item := range list {
+			ts := parseNewsTime(item.PubDate)
+			if ts <= 0 {
+				ts = fallbackTs
+			}
+			refs = append(refs, ref{source: source, idx: i, ts: ts})
+		}
+	}
+	if len(refs) <= max {
+		return data
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ts > refs[j].ts })
+	out := make(map[string][]newsItem, len(data))
+	for _, r := range refs[:max] {
+		out[r.source] = append(out[r.source], data[r.source][r.idx])
+	}
+	return out
+}
+
+// GetSymbolNews 返回指定币种的新闻时间线（按发布时间倒序）
+func GetSymbolNews(symbol string, limit int) SymbolNewsTimeline {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if symbol != "" && !strings.HasSuffix(symbol, "USDT") {
+		symbol += "USDT"
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > newsSymbolTimelineMax {
+		limit = newsSymbolTimelineMax
+	}
+
+	_, bySymbol, updatedAt := getNewsIndex()
+	list := bySymbol[symbol]
+	out := SymbolNewsTimeline{
+		Symbol:    symbol,
+		Total:     len(list),
+		Items:     []*NewsCluster{},
+		UpdatedAt: updatedAt,
+	}
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	out.Items = append(out.Items, list...)
+	return out
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func withNewsSymbolUniverse(t *testing.T, symbols []string) {
+	t.Helper()
+	newsUniverse.mu.RLock()
+	oldBases, oldLoaded := newsUniverse.bases, newsUniverse.loadedAt
+	newsUniverse.mu.RUnlock()
+	setNewsSymbolUniverse(symbols)
+	t.Cleanup(func() {
+		newsUniverse.mu.Lock()
+		newsUniverse.bases, newsUniverse.loadedAt = oldBases, oldLoaded
+		newsUniverse.mu.Unlock()
+	})
+}
+
+func TestNewsSimhashNearDuplicate(t *testing.T) {
+	a := fingerprintNews(newsItem{Title: "SEC approves spot Solana ETF, trading to begin next week"})
+	b := fingerprintNews(newsItem{Title: "SEC Approves Spot Solana ETF; Trading To Begin Next Week!"})
+	c := fingerprintNews(newsItem{Title: "美国SEC批准现货Solana ETF，下周开始交易"})
+	d := fingerprintNews(newsItem{Title: "美国 SEC 批准现货 Solana ETF 下周开始交易"})
+	e := fingerprintNews(newsItem{Title: "Binance lists new perpetual contracts for WIF and BONK"})
+	if !a.nearDuplicate(b) {
+		t.Fatalf("case/punctuation variants should be near duplicates, hamming=%d", newsHamming(a.title, b.title))
+	}
+	if !c.nearDuplicate(d) {
+		t.Fatalf("chinese whitespace variants should be near duplicates, hamming=%d", newsHamming(c.title, d.title))
+	}
+	if a.nearDuplicate(e) {
+		t.Fatalf("unrelated headlines should not cluster, hamming=%d", newsHamming(a.title, e.title))
+	}
+}
+
+func TestClusterNewsAndTagging(t *testing.T) {
+	withNewsSymbolUniverse(t, []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "1000PEPEUSDT", "ENAUSDT"})
+
+	now := time.Now().UTC()
+	ts := func(minAgo int) string { return now.Add(-time.Duration(minAgo) * time.Minute).Format(time.RFC3339) }
+	data := map[string][]newsItem{
+		"rsshub": {
+			{ID: "r1", Title: "SEC approves spot Solana ETF, trading to begin next week", PubDate: ts(10), Link: "https://a/1"},
+			{ID: "r2", Title: "Whales accumulate PEPE and ENA as memecoins rally", PubDate: ts(5), Link: "https://a/2"},
+		},
+		"telegram": {
+			{ID: "t1", Title: "SEC Approves Spot Solana ETF; Trading To Begin Next Week!", PubDate: ts(8)},
+			{ID: "t2", Title: "THE market waits for CPI data", PubDate: ts(3)},
+		},
+	}
+	clusters := clusterNews(data, now.UnixMilli())
+	if len(clusters) != 3 {
+		t.Fatalf("clusters = %d, want 3", len(clusters))
+	}
+	if clusters[0].Title != "THE market waits for CPI data" || len(clusters[0].Symbols) != 0 {
+		t.Fatalf("newest cluster should be untagged macro news: %+v", clusters[0])
+	}
+	if got := clusters[1].Symbols; len(got) != 2 || got[0] != "1000PEPEUSDT" || got[1] != "ENAUSDT" {
+		t.Fatalf("symbols = %v, want [1000PEPEUSDT ENAUSDT]", got)
+	}
+	sol := clusters[2]
+	if len(sol.Items) != 2 || len(sol.Sources) != 2 || sol.rep.ID != "r1" {
+		t.Fatalf("solana cluster = %+v", sol)
+	}
+	if len(sol.Symbols) != 1 || sol.Symbols[0] != "SOLUSDT" {
+		t.Fatalf("solana symbols = %v", sol.Symbols)
+	}
+
+	// 分类器给出的币种需存在于交易所
+	tags := newsClusterTags(sol, NewsClassification{Symbols: []string{"ETHUSDT", "FAKEUSDT"}})
+	if len(tags) != 2 || tags[1] != "ETHUSDT" {
+		t.Fatalf("tags = %v, want [SOLUSDT ETHUSDT]", tags)
+	}
+
+	triggered := map[string]time.Time{"t1": now}
+	if !newsClusterTriggered(sol, triggered) || newsClusterTriggered(clusters[1], triggered) {
+		t.Fatalf("triggered check should match reposts in the same cluster")
+	}
+
+	cfg := NewsSentimentConfig{Symbols: []string{"BTCUSDT"}, TaggedOnly: true, FollowAffected: true}
+	if got := newsTradeSymbols(cfg, nil); len(got) != 0 {
+		t.Fatalf("taggedOnly should skip untagged news, got %v", got)
+	}
+	if got := newsTradeSymbols(cfg, sol.Symbols); len(got) != 1 || got[0] != "SOLUSDT" {
+		t.Fatalf("taggedOnly should trade the tagged symbol, got %v", got)
+	}
+}
+
+func TestGetSymbolNews(t *testing.T) {
+	withNewsSymbolUniverse(t, []string{"BTCUSDT", "ETHUSDT"})
+
+	old, oldAt := cloneNewsSnapshot()
+	t.Cleanup(func() { storeNewsSnapshot(old, oldAt) })
+
+	now := time.Now().UTC()
+	storeNewsSnapshot(map[string][]newsItem{
+		"a": {
+			{ID: "1", Title: "Bitcoin breaks $100k", PubDate: now.Add(-time.Hour).Format(time.RFC3339)},
+			{ID: "2", Title: "ETH staking inflows hit record", PubDate: now.Add(-30 * time.Minute).Format(time.RFC3339)},
+		},
+		"b": {
+			{ID: "3", Title: "$BTC ETF sees record inflows", PubDate: now.Format(time.RFC3339)},
+		},
+	}, now.UnixMilli())
+
+	tl := GetSymbolNews("btc", 10)
+	if tl.Symbol != "BTCUSDT" || tl.Total != 2 || tl.Items[0].ID == tl.Items[1].ID {
+		t.Fatalf("timeline = %+v", tl)
+	}
+	if tl.Items[0].Title != "$BTC ETF sees record inflows" {
+		t.Fatalf("timeline should be newest first: %q", tl.Items[0].Title)
+	}
+	if got := GetSymbolNews("SOLUSDT", 10); got.Total != 0 || got.Items == nil {
+		t.Fatalf("unknown symbol timeline = %+v", got)
+	}
+}
+
+func TestRecentNewsItems(t *testing.T) {
+	now := time.Now().UTC()
+	data := map[string][]newsItem{
+		"a": {{ID: "a1", PubDate: now.Format(time.RFC3339)}, {ID: "a2", PubDate: now.Add(-2 * time.Hour).Format(time.RFC3339)}},
+		"b": {{ID: "b1", PubDate: now.Add(-time.Hour).Format(time.RFC3339)}},
+	}
+	got := recentNewsItems(data, now.UnixMilli(), 2)
+	if len(got["a"]) != 1 || got["a"][0].ID != "a1" || len(got["b"]) != 1 {
+		t.Fatalf("recent items = %+v", got)
+	}
+	if got := recentNewsItems(data, now.UnixMilli(), 10); len(got["a"]) != 2 {
+		t.Fatalf("small snapshot should be kept as is: %+v", got)
+	}
+}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ========== 大模型新闻分类 ==========
+// 通过 OpenAI 兼容的 /chat/completions 接口将新闻分类为方向、受影响币种、影响强度与置信度
+// 快速模型负责全部新闻，启用路由且快速模型判定为高影响时交给深度模型复核
+// 模型未配置、超时或失败时回退到关键词分类；结果按标题缓存
+
+const (
+	newsClassCacheTTL    = 6 * time.Hour
+	newsClassCacheMax    = 5000
+	newsLLMFailBackoff   = 60 * time.Second
+	newsLLMDeepMagnitude = 0.6 // 快速模型判定影响强度 >= 该值时交给深度模型复核
+	newsMaxSymbols       = 5
+)
+
+// NewsClassification 新闻结构化分类结果
+type NewsClassification struct {
+	Direction  string   `json:"direction"`  // BULLISH / BEARISH / NEUTRAL
+	Symbols    []string `json:"symbols"`    // 直接受影响的交易对（如 BTCUSDT），宏观新闻为空
+	Magnitude  float64  `json:"magnitude"`  // 预期价格影响强度 0~1
+	Confidence float64  `json:"confidence"` // 置信度 0~1
+	Reason     string   `json:"reason,omitempty"`
+	Source     string   `json:"source"` // llm / keyword
+	Model      string   `json:"model,omitempty"`
+}
+
+const newsLLMSystemPrompt = `你是加密货币新闻交易分析师。阅读一条新闻，判断其对加密货币价格的短期影响，只输出一个 JSON 对象，不要输出其他内容：
+{"direction":"BULLISH|BEARISH|NEUTRAL","symbols":["BTC"],"magnitude":0.0,"confidence":0.0,"reason":"一句话理由"}
+- symbols：直接受影响的币种代码（不带 USDT 后缀），宏观或全市场新闻留空数组
+- magnitude：预期价格影响强度，0 表示无影响，1 表示极端影响
+- confidence：你对方向判断的把握，0~1`
+
+type newsClassCacheEntry struct {
+	result NewsClassification
+	at     time.Time
+}
+
+// newsClassifier 新闻分类器（缓存 + 失败退避）
+type newsClassifier struct {
+	mu        sync.Mutex
+	cache     map[string]newsClassCacheEntry
+	failUntil time.Time
+}
+
+func newNewsClassifier() *newsClassifier {
+	return &newsClassifier{
+		cache: make(map[string]newsClassCacheEntry),
+	}
+}
+
+var newsLLM = newNewsClassifier()
+
+// ClassifyNews 分类单条新闻，优先使用大模型，不可用时回退关键词
+func ClassifyNews(ctx context.Context, item newsItem) NewsClassification {
+	return newsLLM.classify(ctx, item)
+}
+
+func newsClassKey(item newsItem) string {
+	sum := sha1.Sum([]byte(strings.ToLower(strings.TrimSpace(item.Title))))
+	return hex.EncodeToString(sum[:])
+}
+
+func (nc *newsClassifier) classify(ctx context.Context, item newsItem) NewsClassification {
+	key := newsClassKey(item)
+	now := time.Now()
+
+	nc.mu.Lock()
+	if e, ok := nc.cache[key]; ok && now.Sub(e.at) < newsClassCacheTTL {
+		nc.mu.Unlock()
+		return e.result
+	}
+	backoff := now.Before(nc.failUntil)
+	nc.mu.Unlock()
+
+	fast := resolveLLMModel("fast")
+	if fast == nil || backoff {
+		// 关键词结果不缓存，模型恢复后重新分类
+		return keywordClassifyNews(item)
+	}
+
+	res, err := nc.callModel(ctx, fast, item)
+	if err != nil {
+		log.Printf("[NewsLLM] Classify %q via %s failed, fallback to keywords: %v", trimRunes(item.Title, 60), fast.Model, err)
+		nc.mu.Lock()
+		nc.failUntil = time.Now().Add(newsLLMFailBackoff)
+		nc.mu.Unlock()
+		return keywordClassifyNews(item)
+	}
+
+	if res.Direction != "NEUTRAL" && res.Magnitude >= newsLLMDeepMagnitude {
+		if deep := resolveLLMModel("deep"); deep != nil {
+			if deepRes, err := nc.callModel(ctx, deep, item); err == nil {
+				res = deepRes
+			} else {
+				log.Printf("[NewsLLM] Deep review via %s failed, keep fast result: %v", deep.Model, err)
+			}
+		}
+	}
+
+	nc.mu.Lock()
+	if len(nc.cache) >= newsClassCacheMax {
+		for k, e := range nc.cache {
+			if now.Sub(e.at) >= newsClassCacheTTL || len(nc.cache) >= newsClassCacheMax {
+				delete(nc.cache, k)
+			}
+		}
+	}
+	nc.cache[key] = newsClassCacheEntry{result: res, at: now}
+	nc.mu.Unlock()
+	return res
+}
+
+// callModel 调用模型并解析分类结果
+func (nc *newsClassifier) callModel(ctx context.Context, m *LLMModel, item newsItem) (NewsClassification, error) {
+	user := "标题: " + strings.TrimSpace(item.Title)
+	if s := strings.TrimSpace(item.Summary); s != "" {
+		user += "\n摘要: " + trimRunes(s, 800)
+	}
+	if item.Source != "" {
+		user += "\n来源: " + item.Source
+	}
+
+	content, err := llmChat(ctx, m, []llmMessage{
+		{Role: "system", Content: newsLLMSystemPrompt},
+		{Role: "user", Content: user},
+	}, llmTimeout())
+	if err != nil {
+		return NewsClassification{}, err
+	}
+	res, err := parseNewsClassification(content)
+	if err != nil {
+		return NewsClassification{}, err
+	}
+	res.Source = "llm"
+	res.Model = m.Model
+	return res, nil
+}
+
+// parseNewsClassification 从模型回复中提取 JSON 并规范化
+func parseNewsClassification(content string) (NewsClassification, error) {
+	obj, ok := extractJSONObject(content)
+	if !ok {
+		return NewsClassification{}, fmt.Errorf("no json object in llm reply: %s", trimRunes(content, 100))
+	}
+	var raw struct {
+		Direction  string   `json:"direction"`
+		Symbols    []string `json:"symbols"`
+		Magnitude  float64  `json:"magnitude"`
+		Confidence float64  `json:"confidence"`
+		Reason     string   `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(obj), &raw); err != nil {
+		return NewsClassification{}, fmt.Errorf("decode classification: %w", err)
+	}
+	res := NewsClassification{
+		Direction:  normalizeNewsDirection(raw.Direction),
+		Magnitude:  clampUnit(raw.Magnitude),
+		Confidence: clampUnit(raw.Confidence),
+		Reason:     strings.TrimSpace(raw.Reason),
+	}
+	for _, s := range raw.Symbols {
+		res.Symbols = appendNewsSymbol(res.Symbols, s)
+	}
+	return res, nil
+}
+
+func normalizeNewsDirection(d string) string {
+	switch strings.ToUpper(strings.TrimSpace(d)) {
+	case "BULLISH", "BUY", "LONG", "POSITIVE":
+		return "BULLISH"
+	case "BEARISH", "SELL", "SHORT", "NEGATIVE":
+		return "BEARISH"
+	}
+	return "NEUTRAL"
+}
+
+// clampUnit 规范到 [0,1]，兼容模型按百分比输出
+func clampUnit(v float64) float64 {
+	if v > 1 && v <= 100 {
+		v /= 100
+	}
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// appendNewsSymbol 币种代码转为 USDT 交易对并去重
+func appendNewsSymbol(list []string, s string) []string {
+	s = strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "$")))
+	if s == "" || len(list) >= newsMaxSymbols {
+		return list
+	}
+	if !strings.HasSuffix(s, "USDT") {
+		s += "USDT"
+	}
+	for _, x := range list {
+		if x == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// ========== 关键词回退 ==========
+
+var (
+	newsCashtagRe = regexp.MustCompile(`\$([A-Za-z]{2,10})\b`)
+	newsTickerRe  = regexp.MustCompile(`\b[A-Z]{2,6}\b`)
+
+	// newsSymbolAliases 常见币种名称 → 代码
+	newsSymbolAliases = map[string]string{
+		"bitcoin": "BTC", "比特币": "BTC", "ethereum": "ETH", "以太坊": "ETH",
+		"solana": "SOL", "ripple": "XRP", "dogecoin": "DOGE", "狗狗币": "DOGE",
+		"binance coin": "BNB", "cardano": "ADA", "chainlink": "LINK", "avalanche": "AVAX",
+	}
+	// newsKnownTickers 允许从正文大写词中识别的代码，避免 ETF/SEC 等缩写误判
+	newsKnownTickers = map[string]bool{
+		"BTC": true, "ETH": true, "SOL": true, "XRP": true, "DOGE": true, "BNB": true,
+		"ADA": true, "LINK": true, "AVAX": true, "TON": true, "TRX": true, "DOT": true,
+		"LTC": true, "SUI": true, "ARB": true, "OP": true, "PEPE": true, "WIF": true,
+	}
+)
+
+// newsKeywordScores 统计利多/利空关键词命中数
+func newsKeywordScores(text string) (bullish, bearish int) {
+	text = strings.ToLower(text)
+	for _, kw := range bullishKeywords {
+		if strings.Contains(text, strings.ToLower(kw)) {
+			bullish++
+		}
+	}
+	for _, kw := range bearishKeywords {
+		if strings.Contains(text, strings.ToLower(kw)) {
+			bearish++
+		}
+	}
+	return bullish, bearish
+}
+
+// extractNewsSymbols 从文本中识别币种：$代码、常见名称、已知代码
+func extractNewsSymbols(text string) []string {
+	var out []string
+	for _, m := range newsCashtagRe.FindAllStringSubmatch(text, -1) {
+		out = appendNewsSymbol(out, m[1])
+	}
+	lower := strings.ToLower(text)
+	for name, code := range newsSymbolAliases {
+		if strings.Contains(lower, name) {
+			out = appendNewsSymbol(out, code)
+		}
+	}
+	for _, w := range newsTickerRe.FindAllString(text, -1) {
+		if newsKnownTickers[w] {
+			out = appendNewsSymbol(out, w)
+		}
+	}
+	return out
+}
+
+// keywordClassifyNews 关键词分类：命中差值越大，强度与置信度越高
+func keywordClassifyNews(item newsItem) NewsClassification {
+	text := item.Title + " " + item.Summary
+	bull, bear := newsKeywordScores(text)
+	res := NewsClassification{
+		Direction: "NEUTRAL",
+		Symbols:   extractNewsSymbols(text),
+		Source:    "keyword",
+	}
+	diff := bull - bear
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff == 0 {
+		return res
+	}
+	if bull > bear {
+		res.Direction = "BULLISH"
+	} else {
+		res.Direction = "BEARISH"
+	}
+	res.Magnitude = math.Min(1, 0.3*float64(diff))
+	res.Confidence = math.Min(0.9, 0.5+0.1*float64(diff))
+	res.Reason = fmt.Sprintf("关键词命中 利多%d/利空%d", bull, bear)
+	return res
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newLLMStub 返回固定回复的 OpenAI 兼容桩服务
+func newLLMStub(t *testing.T, reply string, status int, delay time.Duration, hits *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if status != http.StatusOK {
+			http.Error(w, "upstream error", status)
+			return
+		}
+		resp := map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func withLLMConfig(t *testing.T, cfg LLMConfig) {
+	t.Helper()
+	old := Cfg.LLM
+	Cfg.LLM = cfg
+	t.Cleanup(func() { Cfg.LLM = old })
+}
+
+func TestClassifyNews_LLMAndCache(t *testing.T) {
+	var hits int32
+	reply := "```json\n{\"direction\":\"bullish\",\"symbols\":[\"$sol\",\"ETHUSDT\"],\"magnitude\":40,\"confidence\":0.8,\"reason\":\"ETF\"}\n```"
+	srv := newLLMStub(t, reply, http.StatusOK, 0, &hits)
+	withLLMConfig(t, LLMConfig{APIKey: "test-key", BaseURL: srv.URL + "/v1", Model: "stub-fast"})
+
+	nc := newNewsClassifier()
+	item := newsItem{ID: "1", Title: "SEC approves Solana ETF"}
+	res := nc.classify(context.Background(), item)
+	if res.Source != "llm" || res.Model != "stub-fast" || res.Direction != "BULLISH" {
+		t.Fatalf("unexpected classification: %+v", res)
+	}
+	if len(res.Symbols) != 2 || res.Symbols[0] != "SOLUSDT" || res.Symbols[1] != "ETHUSDT" {
+		t.Fatalf("symbols = %v, want [SOLUSDT ETHUSDT]", res.Symbols)
+	}
+	if res.Magnitude != 0.4 || res.Confidence != 0.8 {
+		t.Fatalf("magnitude/confidence = %v/%v, want 0.4/0.8", res.Magnitude, res.Confidence)
+	}
+
+	nc.classify(context.Background(), item)
+	if hits != 1 {
+		t.Fatalf("second classification should hit cache, server hits = %d", hits)
+	}
+}
+
+func TestClassifyNews_DeepRouting(t *testing.T) {
+	var fastHits, deepHits int32
+	fast := newLLMStub(t, `{"direction":"BEARISH","symbols":["BTC"],"magnitude":0.9,"confidence":0.6}`, http.StatusOK, 0, &fastHits)
+	deep := newLLMStub(t, `{"direction":"BEARISH","symbols":["BTC"],"magnitude":0.7,"confidence":0.95}`, http.StatusOK, 0, &deepHits)
+	withLLMConfig(t, LLMConfig{
+		APIKey:        "test-key",
+		RouterEnabled: true,
+		FastModel:     &LLMModel{BaseURL: fast.URL + "/v1", Model: "stub-fast"},
+		DeepModel:     &LLMModel{BaseURL: deep.URL + "/v1", Model: "stub-deep"},
+	})
+
+	res := newNewsClassifier().classify(context.Background(), newsItem{Title: "Major exchange hacked"})
+	if fastHits != 1 || deepHits != 1 {
+		t.Fatalf("hits fast=%d deep=%d, want 1/1", fastHits, deepHits)
+	}
+	if res.Model != "stub-deep" || res.Confidence != 0.95 {
+		t.Fatalf("high-magnitude result should be reviewed by deep model: %+v", res)
+	}
+}
+
+func TestClassifyNews_TimeoutFallback(t *testing.T) {
+	var hits int32
+	srv := newLLMStub(t, `{"direction":"NEUTRAL"}`, http.StatusOK, 1500*time.Millisecond, &hits)
+	withLLMConfig(t, LLMConfig{APIKey: "test-key", BaseURL: srv.URL + "/v1", Model: "stub-fast", TimeoutSec: 1})
+
+	nc := newNewsClassifier()
+	item := newsItem{Title: "Exchange hack drains $ETH hot wallet"}
+	res := nc.classify(context.Background(), item)
+	if res.Source != "keyword" || res.Direction != "BEARISH" {
+		t.Fatalf("timeout should fall back to keywords: %+v", res)
+	}
+	if len(res.Symbols) != 1 || res.Symbols[0] != "ETHUSDT" {
+		t.Fatalf("keyword symbols = %v, want [ETHUSDT]", res.Symbols)
+	}
+
+	// 失败退避期内不再请求模型
+	nc.classify(context.Background(), newsItem{Title: "another headline"})
+	if hits != 1 {
+		t.Fatalf("model should be skipped during backoff, hits = %d", hits)
+	}
+}
+
+func TestKeywordClassifyNewsAndStrategyGate(t *testing.T) {
+	withLLMConfig(t, LLMConfig{})
+	res := ClassifyNews(context.Background(), newsItem{Title: "Bitcoin ETF approved"})
+	if res.Source != "keyword" || res.Direction != "BULLISH" || len(res.Symbols) != 1 || res.Symbols[0] != "BTCUSDT" {
+		t.Fatalf("keyword classification = %+v", res)
+	}
+
+	cfg := NewsSentimentConfig{Symbols: []string{"BTCUSDT"}, MinConfidence: 0.5, MinMagnitude: 0.3}
+	if got := newsSignalFromClass(cfg, res); got != "BULLISH" {
+		t.Fatalf("signal = %q, want BULLISH", got)
+	}
+	weak := NewsClassification{Direction: "BEARISH", Magnitude: 0.1, Confidence: 0.9}
+	if got := newsSignalFromClass(cfg, weak); got != "" {
+		t.Fatalf("weak news should not trigger, got %q", got)
+	}
+
+	if got := newsTradeSymbols(cfg, nil); len(got) != 1 || got[0] != "BTCUSDT" {
+		t.Fatalf("macro news symbols = %v", got)
+	}
+	other := []string{"SOLUSDT"}
+	if got := newsTradeSymbols(cfg, other); len(got) != 0 {
+		t.Fatalf("unrelated news should not trade configured symbols, got %v", got)
+	}
+	cfg.FollowAffected = true
+	if got := newsTradeSymbols(cfg, other); len(got) != 1 || got[0] != "SOLUSDT" {
+		t.Fatalf("followAffected symbols = %v", got)
+	}
+}
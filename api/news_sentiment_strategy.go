@@ -12,7 +12,8 @@ import (
 )
 
 // ========== 新闻情绪事件驱动策略 ==========
-// 监控新闻关键词，识别利多/利空信号并可选自动开仓
+// 监控新闻，多源转载的近似重复新闻聚为一簇只处理一次；
+// 经大模型（不可用时回退关键词）分类为方向/受影响币种/强度/置信度，按新闻标注的币种可选自动开仓
 
 // NewsSentimentConfig 新闻情绪策略配置
 type NewsSentimentConfig struct {
@@ -22,6 +23,11 @@ type NewsSentimentConfig struct {
 	Leverage       int      `json:"leverage"`       // 杠杆
 	CooldownMin    int      `json:"cooldownMin"`    // 冷却分钟，默认 30
 	AutoTrade      bool     `json:"autoTrade"`      // true=自动下单，false=仅通知
+	MinConfidence  float64  `json:"minConfidence"`  // 最低置信度 0~1，默认 0.5
+	MinMagnitude   float64  `json:"minMagnitude"`   // 最低影响强度 0~1，默认 0.3
+	MaxNewsAgeMin  int      `json:"maxNewsAgeMin"`  // 只处理该分钟数内发布的新闻，默认 60
+	FollowAffected bool     `json:"followAffected"` // 新闻涉及的币种不在 symbols 中时也交易这些币种
+	TaggedOnly     bool     `json:"taggedOnly"`     // 只交易新闻标注的币种，未标注币种的宏观新闻不交易
 }
 
 // NewsSentimentStatus 策略状态
@@ -32,6 +38,9 @@ type NewsSentimentStatus struct {
 	LastSignal    string              `json:"lastSignal"`    // BULLISH / BEARISH / NONE
 	LastNewsID    string              `json:"lastNewsId"`
 	LastNewsTitle string              `json:"lastNewsTitle"`
+	LastClass     *NewsClassification `json:"lastClassification,omitempty"`
+	LastSources   []string            `json:"lastSources,omitempty"` // 触发新闻簇的转载来源
+	LastSymbols   []string            `json:"lastSymbols,omitempty"` // 触发新闻标注的币种
 	TotalSignals  int                 `json:"totalSignals"`
 	TotalTrades   int                 `json:"totalTrades"`
 	LastError     string              `json:"lastError"`
@@ -58,6 +67,9 @@ type newsSentimentState struct {
 	lastSignal    string
 	lastNewsID    string
 	lastNewsTitle string
+	lastClass     *NewsClassification
+	lastSources   []string
+	lastSymbols   []string
 	totalSignals  int
 	totalTrades   int
 	lastError     string
@@ -98,6 +110,15 @@ func StartNewsSentiment(cfg NewsSentimentConfig) error {
 	if cfg.AmountPerOrder == "" {
 		cfg.AmountPerOrder = "10"
 	}
+	if cfg.MinConfidence <= 0 {
+		cfg.MinConfidence = 0.5
+	}
+	if cfg.MinMagnitude <= 0 {
+		cfg.MinMagnitude = 0.3
+	}
+	if cfg.MaxNewsAgeMin <= 0 {
+		cfg.MaxNewsAgeMin = 60
+	}
 
 	stopC := make(chan struct{})
 
@@ -145,6 +166,9 @@ func GetNewsSentimentStatus() *NewsSentimentStatus {
 		LastSignal:    newsSentiment.lastSignal,
 		LastNewsID:    newsSentiment.lastNewsID,
 		LastNewsTitle: newsSentiment.lastNewsTitle,
+		LastClass:     newsSentiment.lastClass,
+		LastSources:   newsSentiment.lastSources,
+		LastSymbols:   newsSentiment.lastSymbols,
 		TotalSignals:  newsSentiment.totalSignals,
 		TotalTrades:   newsSentiment.totalTrades,
 		LastError:     newsSentiment.lastError,
@@ -177,22 +201,16 @@ func checkNewsSentiment(cfg NewsSentimentConfig) {
 	newsSentiment.lastCheckAt = time.Now()
 	newsSentiment.mu.Unlock()
 
-	// 拉取最新新闻
-	data, _, err := fetchNewsSnapshot()
-	if err != nil {
+	// 读取后台抓取循环维护的资讯快照（各源按自身间隔轮询），使用聚类去重后的新闻簇
+	nHub.ensureRunning()
+	clusters, _, _ := getNewsIndex()
+	if len(clusters) == 0 {
 		newsSentiment.mu.Lock()
-		newsSentiment.lastError = err.Error()
+		newsSentiment.lastError = "news snapshot is empty"
 		newsSentiment.mu.Unlock()
-		log.Printf("[NewsSentiment] Fetch news failed: %v", err)
 		return
 	}
 
-	// 合并所有来源新闻
-	var allNews []newsItem
-	for _, items := range data {
-		allNews = append(allNews, items...)
-	}
-
 	cooldownDur := time.Duration(cfg.CooldownMin) * time.Minute
 
 	newsSentiment.mu.Lock()
@@ -214,34 +232,54 @@ func checkNewsSentiment(cfg NewsSentimentConfig) {
 	triggered := newsSentiment.triggeredIDs
 	newsSentiment.mu.Unlock()
 
-	for _, item := range allNews {
-		// 跳过已触发的新闻
-		if _, ok := triggered[item.ID]; ok {
+	ageCutoff := time.Now().Add(-time.Duration(cfg.MaxNewsAgeMin) * time.Minute).UnixMilli()
+	for _, cluster := range clusters {
+		// 簇内任一条已触发过则整簇跳过（其他来源的转载）
+		if newsClusterTriggered(cluster, triggered) {
+			continue
+		}
+		// 首发时间过旧的新闻不再处理
+		if cluster.FirstSeen > 0 && cluster.FirstSeen < ageCutoff {
 			continue
 		}
 
-		signal := detectNewsSentiment(item)
+		item := cluster.rep
+		class := ClassifyNews(context.Background(), item)
+		signal := newsSignalFromClass(cfg, class)
 		if signal == "" {
 			continue
 		}
+		tags := newsClusterTags(cluster, class)
+		symbols := newsTradeSymbols(cfg, tags)
+		if len(symbols) == 0 {
+			continue
+		}
 
 		// 记录触发
+		now := time.Now()
 		newsSentiment.mu.Lock()
-		newsSentiment.triggeredIDs[item.ID] = time.Now()
-		newsSentiment.lastTriggerAt = time.Now()
+		for _, it := range cluster.Items {
+			newsSentiment.triggeredIDs[chooseValue(it.ID, it.Link, it.Title)] = now
+		}
+		newsSentiment.lastTriggerAt = now
 		newsSentiment.lastSignal = signal
 		newsSentiment.lastNewsID = item.ID
 		newsSentiment.lastNewsTitle = item.Title
+		newsSentiment.lastClass = &class
+		newsSentiment.lastSources = cluster.Sources
+		newsSentiment.lastSymbols = tags
 		newsSentiment.totalSignals++
 		newsSentiment.mu.Unlock()
 
-		log.Printf("[NewsSentiment] Signal=%s news=%q", signal, item.Title)
+		log.Printf("[NewsSentiment] Signal=%s via=%s mag=%.2f conf=%.2f tags=%v symbols=%v sources=%v news=%q",
+			signal, class.Source, class.Magnitude, class.Confidence, tags, symbols, cluster.Sources, item.Title)
 
 		if cfg.AutoTrade {
-			executeNewsSentimentTrade(cfg, signal, item)
+			executeNewsSentimentTrade(cfg, signal, symbols, item, class)
 		} else {
-			msg := fmt.Sprintf("*新闻情绪信号* %s\n方向: %s\n标题: %s\n来源: %s",
-				signal, signalToDirection(signal), item.Title, item.Source)
+			msg := fmt.Sprintf("*新闻情绪信号* %s\n方向: %s\n币种: %s\n强度: %.2f 置信度: %.2f (%s)\n标题: %s\n来源: %s",
+				signal, signalToDirection(signal), strings.Join(symbols, ","), class.Magnitude, class.Confidence,
+				class.Source, item.Title, strings.Join(cluster.Sources, ","))
 			SendNotify(msg)
 		}
 
@@ -254,31 +292,61 @@ func checkNewsSentiment(cfg NewsSentimentConfig) {
 	newsSentiment.mu.Unlock()
 }
 
-// detectNewsSentiment 检测新闻情绪，返回 "BULLISH"/"BEARISH"/""
-func detectNewsSentiment(item newsItem) string {
-	text := strings.ToLower(item.Title + " " + item.Summary)
-
-	bullishScore := 0
-	bearishScore := 0
+// newsSignalFromClass 分类结果达到置信度与强度门槛时返回 "BULLISH"/"BEARISH"
+func newsSignalFromClass(cfg NewsSentimentConfig, class NewsClassification) string {
+	if class.Direction != "BULLISH" && class.Direction != "BEARISH" {
+		return ""
+	}
+	if class.Confidence < cfg.MinConfidence || class.Magnitude < cfg.MinMagnitude {
+		return ""
+	}
+	return class.Direction
+}
 
-	for _, kw := range bullishKeywords {
-		if strings.Contains(text, strings.ToLower(kw)) {
-			bullishScore++
+// newsClusterTriggered 簇内是否有已触发过的新闻
+func newsClusterTriggered(cluster *NewsCluster, triggered map[string]time.Time) bool {
+	for _, it := range cluster.Items {
+		if _, ok := triggered[chooseValue(it.ID, it.Link, it.Title)]; ok {
+			return true
 		}
 	}
-	for _, kw := range bearishKeywords {
-		if strings.Contains(text, strings.ToLower(kw)) {
-			bearishScore++
+	return false
+}
+
+// newsClusterTags 新闻标注的交易对：实体识别结果，加上分类器给出且交易所存在的币种
+func newsClusterTags(cluster *NewsCluster, class NewsClassification) []string {
+	tags := append([]string(nil), cluster.Symbols...)
+	for _, s := range class.Symbols {
+		if !containsString(tags, s) && validNewsSymbol(s) {
+			tags = append(tags, s)
 		}
 	}
+	return tags
+}
 
-	if bullishScore > bearishScore && bullishScore > 0 {
-		return "BULLISH"
+// newsTradeSymbols 根据新闻标注的币种确定交易币种：
+// 宏观新闻（未标注币种）交易 symbols，taggedOnly 时不交易；
+// 标注了币种时只交易标注的币种：取与 symbols 的交集，followAffected 时交集为空则交易标注的币种
+func newsTradeSymbols(cfg NewsSentimentConfig, tags []string) []string {
+	if len(tags) == 0 {
+		if cfg.TaggedOnly {
+			return nil
+		}
+		return cfg.Symbols
+	}
+	var out []string
+	for _, s := range tags {
+		for _, c := range cfg.Symbols {
+			if strings.EqualFold(s, c) {
+				out = append(out, c)
+				break
+			}
+		}
 	}
-	if bearishScore > bullishScore && bearishScore > 0 {
-		return "BEARISH"
+	if len(out) == 0 && cfg.FollowAffected {
+		out = append(out, tags...)
 	}
-	return ""
+	return out
 }
 
 func signalToDirection(signal string) string {
@@ -288,7 +356,7 @@ func signalToDirection(signal string) string {
 	return "空头 (做空)"
 }
 
-func executeNewsSentimentTrade(cfg NewsSentimentConfig, signal string, item newsItem) {
+func executeNewsSentimentTrade(cfg NewsSentimentConfig, signal string, symbols []string, item newsItem, class NewsClassification) {
 	if err := CheckRisk(); err != nil {
 		log.Printf("[NewsSentiment] Risk check failed: %v", err)
 		return
@@ -301,7 +369,7 @@ func executeNewsSentimentTrade(cfg NewsSentimentConfig, signal string, item news
 		positionSide = futures.PositionSideTypeShort
 	}
 
-	for _, symbol := range cfg.Symbols {
+	for _, symbol := range symbols {
 		req := PlaceOrderReq{
 			Source:        "news_sentiment",
 			Symbol:        symbol,
@@ -329,8 +397,9 @@ func executeNewsSentimentTrade(cfg NewsSentimentConfig, signal string, item news
 		}
 		log.Printf("[NewsSentiment] Order placed symbol=%s signal=%s orderID=%d", symbol, signal, orderID)
 
-		msg := fmt.Sprintf("*新闻情绪自动开仓* %s\n信号: %s\n交易对: %s\n金额: %s USDT x %dx\n新闻: %s",
-			signal, signalToDirection(signal), symbol, cfg.AmountPerOrder, cfg.Leverage, item.Title)
+		msg := fmt.Sprintf("*新闻情绪自动开仓* %s\n信号: %s\n交易对: %s\n金额: %s USDT x %dx\n强度: %.2f 置信度: %.2f (%s)\n新闻: %s",
+			signal, signalToDirection(signal), symbol, cfg.AmountPerOrder, cfg.Leverage,
+			class.Magnitude, class.Confidence, class.Source, item.Title)
 		SendNotify(msg)
 	}
 }
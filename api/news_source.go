@@ -0,0 +1,601 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// ========== 可插拔资讯源 ==========
+// 资讯源统一实现 NewsSource：rss（RSS 2.0 / RSS 1.0 / Atom）、json（通用 JSON 接口，字段按路径配置）、
+// webhook（外部推送到 POST /tool/news/ingest）。每个源独立轮询间隔，失败后指数退避；
+// 运行时可新增/修改/删除，变更写入 DB，重启后恢复
+
+const (
+	newsSourceTypeRSS     = "rss"
+	newsSourceTypeJSON    = "json"
+	newsSourceTypeWebhook = "webhook"
+
+	newsSourceDefaultInterval = 30 * time.Second
+	newsSourceMinInterval     = 5 * time.Second
+	newsSourceMaxBackoff      = 30 * time.Minute
+	newsIngestMaxItems        = 200
+
+	newsSourceOriginConfig  = "config"
+	newsSourceOriginRuntime = "runtime"
+)
+
+var reNewsSourceKey = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+// NewsSourceSpec 资讯源配置
+type NewsSourceSpec struct {
+	Key         string            `json:"key"`
+	Name        string            `json:"name"`
+	Type        string            `json:"type"` // rss / json / webhook，默认 rss
+	URL         string            `json:"url,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	IntervalSec int               `json:"intervalSec,omitempty"` // 轮询间隔秒数，默认 30
+	Disabled    bool              `json:"disabled,omitempty"`
+
+	// json 类型字段路径（点分隔，数组用数字下标，如 data.list / attributes.title）；字段路径为空时尝试常见字段名
+	ItemsPath   string `json:"itemsPath,omitempty"`
+	IDPath      string `json:"idPath,omitempty"`
+	TitlePath   string `json:"titlePath,omitempty"`
+	SummaryPath string `json:"summaryPath,omitempty"`
+	LinkPath    string `json:"linkPath,omitempty"`
+	TimePath    string `json:"timePath,omitempty"`
+}
+
+func (s NewsSourceSpec) interval() time.Duration {
+	return time.Duration(s.IntervalSec) * time.Second
+}
+
+// NewsSource 资讯源连接器
+type NewsSource interface {
+	Spec() NewsSourceSpec
+	// Fetch 拉取一次最新条目；webhook 源只接受推送，不支持拉取
+	Fetch(ctx context.Context) ([]newsItem, error)
+}
+
+type rssNewsSource struct{ spec NewsSourceSpec }
+
+func (s *rssNewsSource) Spec() NewsSourceSpec { return s.spec }
+
+func (s *rssNewsSource) Fetch(ctx context.Context) ([]newsItem, error) {
+	body, err := fetchNewsBody(ctx, s.spec, "application/rss+xml, application/atom+xml, application/xml, text/xml, */*")
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseFeedXML(body, s.spec.Name)
+	if err == nil && len(items) > 0 {
+		return items, nil
+	}
+	// 不规范的 XML（未转义字符、非 UTF-8 编码等）回退正则解析
+	if fallback := parseRSSContent(string(body), s.spec.Name); len(fallback) > 0 {
+		return fallback, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("not rss/atom payload")
+}
+
+type jsonNewsSource struct{ spec NewsSourceSpec }
+
+func (s *jsonNewsSource) Spec() NewsSourceSpec { return s.spec }
+
+func (s *jsonNewsSource) Fetch(ctx context.Context) ([]newsItem, error) {
+	body, err := fetchNewsBody(ctx, s.spec, "application/json, */*")
+	if err != nil {
+		return nil, err
+	}
+	return parseNewsJSON(body, s.spec)
+}
+
+type webhookNewsSource struct{ spec NewsSourceSpec }
+
+func (s *webhookNewsSource) Spec() NewsSourceSpec { return s.spec }
+
+func (s *webhookNewsSource) Fetch(context.Context) ([]newsItem, error) {
+	return nil, fmt.Errorf("webhook source %s does not support polling", s.spec.Key)
+}
+
+// newsHTTPStatusError 资讯源返回非 2xx
+type newsHTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *newsHTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.StatusCode)
+}
+
+func fetchNewsBody(ctx context.Context, spec NewsSourceSpec, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// 兜底默认 Header，防止被目标站 403
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; NewsBot/1.0)")
+	req.Header.Set("Accept", accept)
+	// 源自定义 Header 覆盖默认值
+	for k, v := range spec.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := newsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &newsHTTPStatusError{StatusCode: resp.StatusCode}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, proxyHTTPResponseMaxSize))
+}
+
+// normalizeNewsSourceSpec 校验并补全默认值
+func normalizeNewsSourceSpec(spec NewsSourceSpec) (NewsSourceSpec, error) {
+	spec.Key = strings.TrimSpace(spec.Key)
+	if !reNewsSourceKey.MatchString(spec.Key) {
+		return spec, fmt.Errorf("invalid source key %q", spec.Key)
+	}
+	spec.Name = strings.TrimSpace(spec.Name)
+	if spec.Name == "" {
+		spec.Name = spec.Key
+	}
+	spec.Type = strings.ToLower(strings.TrimSpace(spec.Type))
+	if spec.Type == "" || spec.Type == "atom" {
+		spec.Type = newsSourceTypeRSS
+	}
+	switch spec.Type {
+	case newsSourceTypeRSS, newsSourceTypeJSON:
+		u, err := url.Parse(strings.TrimSpace(spec.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return spec, fmt.Errorf("source %s: url must be http(s)", spec.Key)
+		}
+		spec.URL = u.String()
+	case newsSourceTypeWebhook:
+		spec.URL = ""
+	default:
+		return spec, fmt.Errorf("source %s: unsupported type %q", spec.Key, spec.Type)
+	}
+	if spec.IntervalSec <= 0 {
+		spec.IntervalSec = int(newsSourceDefaultInterval / time.Second)
+	}
+	if spec.interval() < newsSourceMinInterval {
+		spec.IntervalSec = int(newsSourceMinInterval / time.Second)
+	}
+	return spec, nil
+}
+
+// buildNewsSource 根据配置创建连接器
+func buildNewsSource(spec NewsSourceSpec) (NewsSource, error) {
+	spec, err := normalizeNewsSourceSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch spec.Type {
+	case newsSourceTypeJSON:
+		return &jsonNewsSource{spec: spec}, nil
+	case newsSourceTypeWebhook:
+		return &webhookNewsSource{spec: spec}, nil
+	default:
+		return &rssNewsSource{spec: spec}, nil
+	}
+}
+
+// ========== 注册表与调度 ==========
+
+type newsSourceEntry struct {
+	source        NewsSource
+	origin        string // config / runtime
+	nextFetchAt   time.Time
+	failures      int
+	lastError     string
+	lastFetchAt   time.Time
+	lastSuccessAt time.Time
+	lastCount     int
+}
+
+// NewsSourceView 资讯源配置与调度状态
+type NewsSourceView struct {
+	NewsSourceSpec
+	Origin        string `json:"origin"`
+	Failures      int    `json:"failures"`
+	LastError     string `json:"lastError,omitempty"`
+	NextFetchAt   int64  `json:"nextFetchAt,omitempty"`
+	LastFetchAt   int64  `json:"lastFetchAt,omitempty"`
+	LastSuccessAt int64  `json:"lastSuccessAt,omitempty"`
+	LastCount     int    `json:"lastCount"`
+}
+
+var newsSourceRegistry = struct {
+	mu          sync.RWMutex
+	entries     map[string]*newsSourceEntry
+	configSpecs map[string]NewsSourceSpec // 配置文件中的源，运行时覆盖被删除后恢复
+}{
+	entries:     make(map[string]*newsSourceEntry),
+	configSpecs: make(map[string]NewsSourceSpec),
+}
+
+// newsMergeMu 串行化快照的读-合并-写（轮询抓取与 webhook 推送）
+var newsMergeMu sync.Mutex
+
+// setConfigNewsSources 用配置文件中的源替换注册表，保留运行时添加/覆盖的源及未变化源的调度状态
+func setConfigNewsSources(specs []NewsSourceSpec) {
+	newsSourceRegistry.mu.Lock()
+	defer newsSourceRegistry.mu.Unlock()
+
+	configSpecs := make(map[string]NewsSourceSpec, len(specs))
+	entries := make(map[string]*newsSourceEntry, len(specs))
+	for _, spec := range specs {
+		src, err := buildNewsSource(spec)
+		if err != nil {
+			log.Printf("[WsNews] skip invalid news source: %v", err)
+			continue
+		}
+		spec = src.Spec()
+		configSpecs[spec.Key] = spec
+		entry := &newsSourceEntry{source: src, origin: newsSourceOriginConfig}
+		if old, ok := newsSourceRegistry.entries[spec.Key]; ok && old.origin == newsSourceOriginConfig {
+			entry.nextFetchAt, entry.failures, entry.lastError = old.nextFetchAt, old.failures, old.lastError
+			entry.lastFetchAt, entry.lastSuccessAt, entry.lastCount = old.lastFetchAt, old.lastSuccessAt, old.lastCount
+		}
+		entries[spec.Key] = entry
+	}
+	for key, old := range newsSourceRegistry.entries {
+		if old.origin == newsSourceOriginRuntime {
+			entries[key] = old
+		}
+	}
+	newsSourceRegistry.entries = entries
+	newsSourceRegistry.configSpecs = configSpecs
+}
+
+// newsSourceSnapshot 当前已注册的连接器（按 key 排序）
+func newsSourceSnapshot() []NewsSource {
+	newsSourceRegistry.mu.RLock()
+	defer newsSourceRegistry.mu.RUnlock()
+	out := make([]NewsSource, 0, len(newsSourceRegistry.entries))
+	for _, e := range newsSourceRegistry.entries {
+		out = append(out, e.source)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Spec().Key < out[j].Spec().Key })
+	return out
+}
+
+// dueNewsSources 到达轮询时间（且不在退避期）的可拉取源
+func dueNewsSources(now time.Time) []NewsSource {
+	newsSourceRegistry.mu.RLock()
+	defer newsSourceRegistry.mu.RUnlock()
+	var out []NewsSource
+	for _, e := range newsSourceRegistry.entries {
+		spec := e.source.Spec()
+		if spec.Disabled || spec.Type == newsSourceTypeWebhook || now.Before(e.nextFetchAt) {
+			continue
+		}
+		out = append(out, e.source)
+	}
+	return out
+}
+
+// newsSourceBackoff 连续失败 n 次后的等待时间：间隔 × 2^n，最长 30 分钟
+func newsSourceBackoff(interval time.Duration, failures int) time.Duration {
+	d := interval
+	for i := 0; i < failures && d < newsSourceMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > newsSourceMaxBackoff {
+		d = newsSourceMaxBackoff
+	}
+	return d
+}
+
+// recordNewsSourceResult 记录一次拉取/推送结果并安排下次轮询
+func recordNewsSourceResult(key string, count int, err error, now time.Time) {
+	newsSourceRegistry.mu.Lock()
+	defer newsSourceRegistry.mu.Unlock()
+	e, ok := newsSourceRegistry.entries[key]
+	if !ok {
+		return
+	}
+	interval := e.source.Spec().interval()
+	e.lastFetchAt = now
+	if err != nil {
+		e.failures++
+		e.lastError = err.Error()
+		e.nextFetchAt = now.Add(newsSourceBackoff(interval, e.failures))
+		return
+	}
+	e.failures = 0
+	e.lastError = ""
+	e.lastSuccessAt = now
+	e.lastCount = count
+	e.nextFetchAt = now.Add(interval)
+}
+
+func newsSourceView(e *newsSourceEntry) NewsSourceView {
+	v := NewsSourceView{
+		NewsSourceSpec: e.source.Spec(),
+		Origin:         e.origin,
+		Failures:       e.failures,
+		LastError:      e.lastError,
+		LastCount:      e.lastCount,
+	}
+	if !e.nextFetchAt.IsZero() {
+		v.NextFetchAt = e.nextFetchAt.UnixMilli()
+	}
+	if !e.lastFetchAt.IsZero() {
+		v.LastFetchAt = e.lastFetchAt.UnixMilli()
+	}
+	if !e.lastSuccessAt.IsZero() {
+		v.LastSuccessAt = e.lastSuccessAt.UnixMilli()
+	}
+	return v
+}
+
+// ListNewsSources 返回所有资讯源的配置与调度状态
+func ListNewsSources() []NewsSourceView {
+	newsSourceRegistry.mu.RLock()
+	defer newsSourceRegistry.mu.RUnlock()
+	out := make([]NewsSourceView, 0, len(newsSourceRegistry.entries))
+	for _, e := range newsSourceRegistry.entries {
+		out = append(out, newsSourceView(e))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func getNewsSourceView(key string) (NewsSourceView, bool) {
+	newsSourceRegistry.mu.RLock()
+	defer newsSourceRegistry.mu.RUnlock()
+	e, ok := newsSourceRegistry.entries[key]
+	if !ok {
+		return NewsSourceView{}, false
+	}
+	return newsSourceView(e), true
+}
+
+// ========== 运行时配置 ==========
+
+// NewsSourceRecord 运行时添加/覆盖的资讯源
+type NewsSourceRecord struct {
+	Key       string    `gorm:"column:source_key;type:varchar(64);primaryKey" json:"key"`
+	SpecJSON  string    `gorm:"type:text" json:"specJson"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (NewsSourceRecord) TableName() string { return "news_sources" }
+
+// UpsertNewsSource 新增或修改资讯源（可覆盖配置文件中的同名源），立即生效
+func UpsertNewsSource(spec NewsSourceSpec) (NewsSourceView, error) {
+	src, err := buildNewsSource(spec)
+	if err != nil {
+		return NewsSourceView{}, err
+	}
+	spec = src.Spec()
+	if DB != nil {
+		raw, err := json.Marshal(spec)
+		if err != nil {
+			return NewsSourceView{}, err
+		}
+		if err := DB.Save(&NewsSourceRecord{Key: spec.Key, SpecJSON: string(raw)}).Error; err != nil {
+			return NewsSourceView{}, err
+		}
+	}
+
+	newsSourceRegistry.mu.Lock()
+	entry := &newsSourceEntry{source: src, origin: newsSourceOriginRuntime}
+	newsSourceRegistry.entries[spec.Key] = entry
+	view := newsSourceView(entry)
+	newsSourceRegistry.mu.Unlock()
+
+	log.Printf("[WsNews] news source upserted: key=%s type=%s interval=%ds disabled=%v", spec.Key, spec.Type, spec.IntervalSec, spec.Disabled)
+	nHub.triggerRefresh()
+	return view, nil
+}
+
+// RemoveNewsSource 删除运行时添加的资讯源；覆盖了配置文件源的则恢复配置文件中的设置
+func RemoveNewsSource(key string) error {
+	key = strings.TrimSpace(key)
+	newsSourceRegistry.mu.RLock()
+	e, ok := newsSourceRegistry.entries[key]
+	configSpec, inConfig := newsSourceRegistry.configSpecs[key]
+	newsSourceRegistry.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("news source %s not found", key)
+	}
+	if e.origin != newsSourceOriginRuntime {
+		return fmt.Errorf("news source %s comes from config file, disable it instead", key)
+	}
+	if DB != nil {
+		if err := DB.Delete(&NewsSourceRecord{}, "source_key = ?", key).Error; err != nil {
+			return err
+		}
+	}
+
+	newsSourceRegistry.mu.Lock()
+	defer newsSourceRegistry.mu.Unlock()
+	if inConfig {
+		src, _ := buildNewsSource(configSpec)
+		newsSourceRegistry.entries[key] = &newsSourceEntry{source: src, origin: newsSourceOriginConfig}
+	} else {
+		delete(newsSourceRegistry.entries, key)
+	}
+	log.Printf("[WsNews] news source removed: key=%s", key)
+	return nil
+}
+
+// loadRuntimeNewsSources 从 DB 恢复运行时添加/覆盖的资讯源
+func loadRuntimeNewsSources() {
+	if DB == nil {
+		return
+	}
+	var rows []NewsSourceRecord
+	if err := DB.Find(&rows).Error; err != nil {
+		log.Printf("[WsNews] Load runtime news sources failed: %v", err)
+		return
+	}
+	newsSourceRegistry.mu.Lock()
+	defer newsSourceRegistry.mu.Unlock()
+	for _, row := range rows {
+		var spec NewsSourceSpec
+		if err := json.Unmarshal([]byte(row.SpecJSON), &spec); err != nil {
+			log.Printf("[WsNews] skip runtime news source %s: %v", row.Key, err)
+			continue
+		}
+		src, err := buildNewsSource(spec)
+		if err != nil {
+			log.Printf("[WsNews] skip runtime news source %s: %v", row.Key, err)
+			continue
+		}
+		newsSourceRegistry.entries[src.Spec().Key] = &newsSourceEntry{source: src, origin: newsSourceOriginRuntime}
+	}
+	if len(rows) > 0 {
+		log.Printf("[WsNews] restored %d runtime news sources", len(rows))
+	}
+}
+
+// ========== Webhook 推送 ==========
+
+// NewsIngestItem 推送的单条资讯
+type NewsIngestItem struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Summary   string `json:"summary"`
+	Link      string `json:"link"`
+	PubDate   string `json:"pubDate"`
+	Timestamp int64  `json:"timestamp"` // 发布时间戳（秒或毫秒），pubDate 为空时使用
+}
+
+// NewsIngestRequest POST /tool/news/ingest 请求体
+type NewsIngestRequest struct {
+	Source string           `json:"source"` // webhook 源 key
+	Items  []NewsIngestItem `json:"items"`
+}
+
+// IngestNews 接收 webhook 推送并合并到资讯快照，返回接收条数
+func IngestNews(req NewsIngestRequest) (int, error) {
+	view, ok := getNewsSourceView(strings.TrimSpace(req.Source))
+	if !ok {
+		return 0, fmt.Errorf("news source %s not found", req.Source)
+	}
+	if view.Type != newsSourceTypeWebhook {
+		return 0, fmt.Errorf("news source %s is not a webhook source", view.Key)
+	}
+	if view.Disabled {
+		return 0, fmt.Errorf("news source %s is disabled", view.Key)
+	}
+	if len(req.Items) == 0 {
+		return 0, fmt.Errorf("items is empty")
+	}
+	if len(req.Items) > newsIngestMaxItems {
+		return 0, fmt.Errorf("too many items: %d > %d", len(req.Items), newsIngestMaxItems)
+	}
+
+	now := time.Now()
+	items := make([]newsItem, 0, len(req.Items))
+	for _, in := range req.Items {
+		title := cleanXMLText(in.Title)
+		if title == "" {
+			continue
+		}
+		pubDate := strings.TrimSpace(in.PubDate)
+		if pubDate == "" && in.Timestamp > 0 {
+			pubDate = normalizeNewsTimestamp(fmt.Sprintf("%d", in.Timestamp))
+		}
+		if pubDate == "" {
+			pubDate = now.UTC().Format(time.RFC3339)
+		}
+		link := strings.TrimSpace(in.Link)
+		items = append(items, newsItem{
+			ID:      chooseValue(in.ID, link, title),
+			Title:   title,
+			Summary: cleanXMLText(in.Summary),
+			Link:    link,
+			PubDate: pubDate,
+			Source:  view.Name,
+		})
+	}
+	if len(items) == 0 {
+		return 0, fmt.Errorf("no item has a title")
+	}
+
+	nHub.applyAndBroadcast(map[string][]newsItem{view.Key: normalizeNewsList(items)}, nil, nil)
+	recordNewsSourceResult(view.Key, len(items), nil, now)
+	return len(items), nil
+}
+
+// ========== HTTP ==========
+
+// HandleListNewsSources GET /tool/news/sources
+func HandleListNewsSources(c context.Context, ctx *app.RequestContext) {
+	_ = c
+	ctx.JSON(http.StatusOK, utils.H{"data": ListNewsSources()})
+}
+
+// HandleUpsertNewsSource POST /tool/news/sources
+func HandleUpsertNewsSource(c context.Context, ctx *app.RequestContext) {
+	_ = c
+	var spec NewsSourceSpec
+	if err := ctx.BindAndValidate(&spec); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	view, err := UpsertNewsSource(spec)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": view})
+}
+
+// HandleRemoveNewsSource DELETE /tool/news/sources?key=xxx
+func HandleRemoveNewsSource(c context.Context, ctx *app.RequestContext) {
+	_ = c
+	if err := RemoveNewsSource(ctx.Query("key")); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"message": "removed"})
+}
+
+// HandleNewsIngest POST /tool/news/ingest
+func HandleNewsIngest(c context.Context, ctx *app.RequestContext) {
+	_ = c
+	var req NewsIngestRequest
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	n, err := IngestNews(req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": utils.H{"source": req.Source, "accepted": n}})
+}
+
+// newsHTTPStatusCode 从拉取错误中取 HTTP 状态码
+func newsHTTPStatusCode(err error) int {
+	var statusErr *newsHTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ========== 资讯解析：RSS / Atom XML 与通用 JSON ==========
+
+type xmlFeedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type xmlFeedItem struct {
+	Title       string        `xml:"title"`
+	Links       []xmlFeedLink `xml:"link"`
+	GUID        string        `xml:"guid"`
+	ID          string        `xml:"id"`
+	Description string        `xml:"description"`
+	Encoded     string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Summary     string        `xml:"summary"`
+	Content     string        `xml:"content"`
+	PubDate     string        `xml:"pubDate"`
+	DCDate      string        `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Published   string        `xml:"published"`
+	Updated     string        `xml:"updated"`
+	Creator     string        `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Author      struct {
+		Name string `xml:"name"`
+		Text string `xml:",chardata"`
+	} `xml:"author"`
+	Source string `xml:"source"`
+}
+
+// xmlFeed 兼容 RSS 2.0（channel/item）、RSS 1.0 RDF（根节点下 item）与 Atom（entry）
+type xmlFeed struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []xmlFeedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []xmlFeedItem `xml:"item"`
+	Entries []xmlFeedItem `xml:"entry"`
+}
+
+// link RSS 取 <link> 文本，Atom 取 rel=alternate（或无 rel）的 href
+func (it xmlFeedItem) link() string {
+	var fallback string
+	for _, l := range it.Links {
+		if text := strings.TrimSpace(l.Text); text != "" {
+			return text
+		}
+		if l.Href == "" {
+			continue
+		}
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+		if fallback == "" {
+			fallback = strings.TrimSpace(l.Href)
+		}
+	}
+	return fallback
+}
+
+// parseFeedXML 使用 XML 解码器解析 RSS / Atom；非 UTF-8 编码或格式错误时返回 error
+func parseFeedXML(body []byte, defaultSource string) ([]newsItem, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "", "utf-8", "utf8", "us-ascii":
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported charset %s", charset)
+	}
+
+	var feed xmlFeed
+	if err := dec.Decode(&feed); err != nil {
+		return nil, fmt.Errorf("decode feed xml: %w", err)
+	}
+
+	raw := feed.Channel.Items
+	raw = append(raw, feed.Items...)
+	raw = append(raw, feed.Entries...)
+	items := make([]newsItem, 0, len(raw))
+	for idx, it := range raw {
+		link := cleanXMLText(it.link())
+		guid := cleanXMLText(chooseValue(it.GUID, it.ID))
+		items = append(items, newsItem{
+			ID:      chooseValue(guid, link, strconv.Itoa(idx)),
+			Title:   cleanXMLText(it.Title),
+			Summary: cleanXMLText(chooseValue(it.Description, it.Summary, it.Encoded, it.Content)),
+			Link:    chooseValue(link, guid),
+			PubDate: chooseValue(it.PubDate, it.DCDate, it.Published, it.Updated),
+			Source:  chooseValue(cleanXMLText(it.Source), it.Creator, it.Author.Name, cleanXMLText(it.Author.Text), defaultSource),
+		})
+	}
+	return items, nil
+}
+
+// ========== JSON 接口 ==========
+
+// 未配置字段路径时依次尝试的常见字段名
+var (
+	newsJSONIDKeys      = []string{"id", "guid", "uuid"}
+	newsJSONTitleKeys   = []string{"title", "headline", "name"}
+	newsJSONSummaryKeys = []string{"summary", "description", "content", "body", "text"}
+	newsJSONLinkKeys    = []string{"url", "link", "href"}
+	newsJSONTimeKeys    = []string{"publishedAt", "published_at", "pubDate", "time", "timestamp", "created_at", "createdAt", "date"}
+)
+
+// jsonPathLookup 按点分隔路径取值，数组节点用数字下标
+func jsonPathLookup(v interface{}, path string) (interface{}, bool) {
+	path = strings.TrimSpace(path)
+	if path == "" || path == "." {
+		return v, true
+	}
+	cur := v
+	for _, seg := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			next, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			cur = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// jsonFieldString 取字段字符串值：配置了路径只按路径取，否则依次尝试候选字段名
+func jsonFieldString(item interface{}, path string, candidates []string) string {
+	paths := candidates
+	if path != "" {
+		paths = []string{path}
+	}
+	for _, p := range paths {
+		v, ok := jsonPathLookup(item, p)
+		if !ok || v == nil {
+			continue
+		}
+		switch x := v.(type) {
+		case string:
+			if strings.TrimSpace(x) != "" {
+				return x
+			}
+		case json.Number:
+			return x.String()
+		case bool:
+			return strconv.FormatBool(x)
+		}
+	}
+	return ""
+}
+
+// normalizeNewsTimestamp 数字时间戳（秒或毫秒）转为 RFC3339，其他格式原样返回
+func normalizeNewsTimestamp(raw string) string {
+	raw = strings.TrimSpace(raw)
+	n, err := strconv.ParseFloat(raw, 64)
+	if err != nil || n <= 0 {
+		return raw
+	}
+	ms := int64(n)
+	if n < 1e12 {
+		ms = int64(n * 1000)
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
+
+// parseNewsJSON 按配置的 itemsPath 与字段路径解析 JSON 接口
+func parseNewsJSON(body []byte, spec NewsSourceSpec) ([]newsItem, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var root interface{}
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	node, ok := jsonPathLookup(root, spec.ItemsPath)
+	if !ok {
+		return nil, fmt.Errorf("itemsPath %q not found", spec.ItemsPath)
+	}
+	list, ok := node.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("itemsPath %q is not an array", spec.ItemsPath)
+	}
+
+	items := make([]newsItem, 0, len(list))
+	for idx, raw := range list {
+		title := cleanXMLText(jsonFieldString(raw, spec.TitlePath, newsJSONTitleKeys))
+		if title == "" {
+			continue
+		}
+		link := strings.TrimSpace(jsonFieldString(raw, spec.LinkPath, newsJSONLinkKeys))
+		items = append(items, newsItem{
+			ID:      chooseValue(jsonFieldString(raw, spec.IDPath, newsJSONIDKeys), link, strconv.Itoa(idx)),
+			Title:   title,
+			Summary: cleanXMLText(jsonFieldString(raw, spec.SummaryPath, newsJSONSummaryKeys)),
+			Link:    link,
+			PubDate: normalizeNewsTimestamp(jsonFieldString(raw, spec.TimePath, newsJSONTimeKeys)),
+			Source:  spec.Name,
+		})
+	}
+	return items, nil
+}
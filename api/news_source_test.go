@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withNewsSourceRegistry 测试期间使用独立的资讯源注册表与快照
+func withNewsSourceRegistry(t *testing.T) {
+	t.Helper()
+	newsSourceRegistry.mu.Lock()
+	oldEntries, oldConfig := newsSourceRegistry.entries, newsSourceRegistry.configSpecs
+	newsSourceRegistry.entries = make(map[string]*newsSourceEntry)
+	newsSourceRegistry.configSpecs = make(map[string]NewsSourceSpec)
+	newsSourceRegistry.mu.Unlock()
+	oldSnap, oldAt := cloneNewsSnapshot()
+	t.Cleanup(func() {
+		newsSourceRegistry.mu.Lock()
+		newsSourceRegistry.entries, newsSourceRegistry.configSpecs = oldEntries, oldConfig
+		newsSourceRegistry.mu.Unlock()
+		storeNewsSnapshot(oldSnap, oldAt)
+	})
+}
+
+func TestParseFeedXML(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel><title>Feed</title><atom:link href="https://feed/self" rel="self"/>
+<item><title><![CDATA[BTC &amp; ETH rally]]></title><link>https://a/1</link><guid>g1</guid>
+<content:encoded><![CDATA[<p>Prices <b>up</b></p>]]></content:encoded><pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate></item>
+</channel></rss>`
+	items, err := parseFeedXML([]byte(rss), "Feed")
+	if err != nil || len(items) != 1 {
+		t.Fatalf("rss items=%v err=%v", items, err)
+	}
+	it := items[0]
+	if it.ID != "g1" || it.Title != "BTC & ETH rally" || it.Summary != "Prices up" || it.Link != "https://a/1" || parseNewsTime(it.PubDate) <= 0 {
+		t.Fatalf("rss item = %+v", it)
+	}
+
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom"><entry><id>urn:1</id><title type="html">SOL upgrade</title>
+<link rel="replies" href="https://b/1#c"/><link rel="alternate" href="https://b/1"/>
+<updated>2024-05-01T08:00:00Z</updated><summary>Mainnet</summary><author><name>Bob</name></author></entry></feed>`
+	items, err = parseFeedXML([]byte(atom), "Atom")
+	if err != nil || len(items) != 1 {
+		t.Fatalf("atom items=%v err=%v", items, err)
+	}
+	if it := items[0]; it.Link != "https://b/1" || it.ID != "urn:1" || it.Source != "Bob" || it.Summary != "Mainnet" {
+		t.Fatalf("atom item = %+v", it)
+	}
+
+	rdf := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel><title>R</title></channel><item><title>RDF news</title><link>https://c/1</link><dc:date>2024-05-01T08:00:00+08:00</dc:date></item></rdf:RDF>`
+	items, err = parseFeedXML([]byte(rdf), "RDF")
+	if err != nil || len(items) != 1 || items[0].PubDate != "2024-05-01T08:00:00+08:00" {
+		t.Fatalf("rdf items=%+v err=%v", items, err)
+	}
+}
+
+func TestParseNewsJSON(t *testing.T) {
+	body := `{"data":{"list":[
+		{"id":7,"attributes":{"headline":"ETF inflows","url":"https://d/7"},"ts":1714550400},
+		{"id":8,"attributes":{"headline":""}},
+		{"id":9,"attributes":{"headline":"Second","url":"https://d/9"},"ts":"2024-05-01T09:00:00Z"}
+	]}}`
+	spec := NewsSourceSpec{Name: "API", ItemsPath: "data.list", TitlePath: "attributes.headline", LinkPath: "attributes.url", TimePath: "ts"}
+	items, err := parseNewsJSON([]byte(body), spec)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("items=%+v err=%v", items, err)
+	}
+	if items[0].ID != "7" || items[0].Link != "https://d/7" || items[0].PubDate != "2024-05-01T08:00:00Z" || items[0].Source != "API" {
+		t.Fatalf("item = %+v", items[0])
+	}
+	if _, err := parseNewsJSON([]byte(body), NewsSourceSpec{ItemsPath: "data.missing"}); err == nil {
+		t.Fatalf("missing itemsPath should fail")
+	}
+}
+
+func TestNewsSourceSchedulingAndBackoff(t *testing.T) {
+	withNewsSourceRegistry(t)
+
+	var failing bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss":
+			if failing {
+				http.Error(w, "down", http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`<rss><channel><item><title>hello</title><link>https://x/1</link></item></channel></rss>`))
+		case "/json":
+			w.Write([]byte(`[{"title":"json news","link":"https://x/2"}]`))
+		}
+	}))
+	defer srv.Close()
+
+	setConfigNewsSources([]NewsSourceSpec{
+		{Key: "rss_a", URL: srv.URL + "/rss", IntervalSec: 60},
+		{Key: "json_b", Type: "json", URL: srv.URL + "/json", IntervalSec: 120},
+		{Key: "hook", Type: "webhook"},
+		{Key: "bad key!", URL: srv.URL},
+	})
+	if got := len(ListNewsSources()); got != 3 {
+		t.Fatalf("registered sources = %d, want 3", got)
+	}
+
+	data, failures, err := fetchNewsSnapshot()
+	if err != nil || len(failures) != 0 || len(data["rss_a"]) != 1 || len(data["json_b"]) != 1 {
+		t.Fatalf("first fetch data=%v failures=%v err=%v", data, failures, err)
+	}
+	// 未到下次轮询时间
+	if data, _, _ := fetchNewsSnapshot(); len(data) != 0 {
+		t.Fatalf("sources should not be due yet: %v", data)
+	}
+
+	failing = true
+	now := time.Now()
+	recordNewsSourceResult("rss_a", 0, context.DeadlineExceeded, now)
+	recordNewsSourceResult("rss_a", 0, context.DeadlineExceeded, now)
+	view, _ := getNewsSourceView("rss_a")
+	if view.Failures != 2 || view.NextFetchAt != now.Add(4*time.Minute).UnixMilli() {
+		t.Fatalf("backoff view = %+v", view)
+	}
+	if d := newsSourceBackoff(time.Minute, 10); d != newsSourceMaxBackoff {
+		t.Fatalf("backoff should be capped, got %v", d)
+	}
+
+	// 健康检测失败延长退避，恢复后清零
+	checkNewsSourceHealthOnce()
+	report := GetNewsSourceHealthReport()
+	if report.TotalSourceCount != 3 || report.ReachableSourceCount != 1 {
+		t.Fatalf("health report = %+v", report)
+	}
+	for _, item := range report.Sources {
+		if item.Key == "rss_a" && (item.StatusCode != http.StatusBadGateway || item.Failures != 3) {
+			t.Fatalf("rss_a health = %+v", item)
+		}
+	}
+	failing = false
+	checkNewsSourceHealthOnce()
+	if view, _ := getNewsSourceView("rss_a"); view.Failures != 0 || view.LastCount != 1 {
+		t.Fatalf("recovered view = %+v", view)
+	}
+}
+
+func TestNewsSourceRuntimeConfigAndIngest(t *testing.T) {
+	withNewsSourceRegistry(t)
+	setConfigNewsSources([]NewsSourceSpec{{Key: "cfg", URL: "https://example.com/rss"}})
+
+	if _, err := UpsertNewsSource(NewsSourceSpec{Key: "cfg", URL: "https://example.com/rss2", IntervalSec: 1}); err != nil {
+		t.Fatalf("override config source: %v", err)
+	}
+	view, _ := getNewsSourceView("cfg")
+	if view.Origin != newsSourceOriginRuntime || view.IntervalSec != 5 || view.URL != "https://example.com/rss2" {
+		t.Fatalf("override view = %+v", view)
+	}
+	if err := RemoveNewsSource("cfg"); err != nil {
+		t.Fatalf("remove override: %v", err)
+	}
+	if view, _ := getNewsSourceView("cfg"); view.Origin != newsSourceOriginConfig || view.URL != "https://example.com/rss" {
+		t.Fatalf("config spec should be restored: %+v", view)
+	}
+	if err := RemoveNewsSource("cfg"); err == nil {
+		t.Fatalf("config source should not be removable")
+	}
+
+	if _, err := IngestNews(NewsIngestRequest{Source: "cfg", Items: []NewsIngestItem{{Title: "x"}}}); err == nil {
+		t.Fatalf("ingest into rss source should fail")
+	}
+	if _, err := UpsertNewsSource(NewsSourceSpec{Key: "tv", Name: "TradingView", Type: "webhook"}); err != nil {
+		t.Fatalf("add webhook: %v", err)
+	}
+	n, err := IngestNews(NewsIngestRequest{Source: "tv", Items: []NewsIngestItem{
+		{ID: "1", Title: "<b>BTC</b> breakout", Timestamp: 1714550400000},
+		{Title: "  "},
+	}})
+	if err != nil || n != 1 {
+		t.Fatalf("ingest n=%d err=%v", n, err)
+	}
+	snap, _ := cloneNewsSnapshot()
+	got := snap["tv"]
+	if len(got) != 1 || got[0].Title != "BTC breakout" || got[0].Source != "TradingView" || !strings.HasPrefix(got[0].PubDate, "2024-05-01T08:00:00") {
+		t.Fatalf("snapshot tv = %+v", got)
+	}
+	if view, _ := getNewsSourceView("tv"); view.LastSuccessAt == 0 || view.LastCount != 1 {
+		t.Fatalf("webhook view = %+v", view)
+	}
+}
@@ -38,6 +38,8 @@ type PlaceOrderReq struct {
 	PositionSide futures.PositionSideType `json:"positionSide,omitempty"` // BOTH / LONG / SHORT
 	TimeInForce  futures.TimeInForceType  `json:"timeInForce,omitempty"`  // GTC / IOC / FOK
 	ReduceOnly   bool                     `json:"reduceOnly,omitempty"`
+	// 自定义客户端订单号（策略用来在拿到 orderId 之前识别回报），为空由交易所生成
+	ClientOrderID string `json:"newClientOrderId,omitempty"`
 
 	// 必填字段：用 USDT 金额下单
 	QuoteQuantity string `json:"quoteQuantity"` // USDT 保证金金额，必填，如 "5" 表示 5 USDT
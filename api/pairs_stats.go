@@ -0,0 +1,258 @@
+package api
+
+import (
+	"math"
+)
+
+// ========== 配对交易统计工具 ==========
+// OLS 回归、ADF 单位根检验（Engle-Granger 两步法）、滚动对冲比例与价差 z-score
+
+// Engle-Granger 协整检验临界值（MacKinnon 2010，两变量、含常数项，大样本近似）
+const (
+	egCritical1  = -3.90
+	egCritical5  = -3.34
+	egCritical10 = -3.04
+)
+
+// CointegrationResult Engle-Granger 协整检验结果
+type CointegrationResult struct {
+	Alpha        float64 `json:"alpha"`        // 截距：ln(A) = alpha + beta × ln(B) + e
+	Beta         float64 `json:"beta"`         // 对冲比例（对数价格）
+	ADFStat      float64 `json:"adfStat"`      // 残差 ADF t 统计量，越负越平稳
+	Significance string  `json:"significance"` // 1% / 5% / 10% / none
+	Cointegrated bool    `json:"cointegrated"` // 是否在设定显著性水平下协整
+	HalfLife     float64 `json:"halfLife"`     // 价差均值回复半衰期（K 线根数），不回复时为 0
+	SpreadStd    float64 `json:"spreadStd"`    // 残差标准差
+	Samples      int     `json:"samples"`
+}
+
+// olsFit 普通最小二乘，X 每行一个样本；返回系数与标准误
+func olsFit(X [][]float64, y []float64) (coef, se []float64, ok bool) {
+	n := len(y)
+	if n == 0 || len(X) != n {
+		return nil, nil, false
+	}
+	k := len(X[0])
+	if n <= k {
+		return nil, nil, false
+	}
+
+	// 正规方程 X'X b = X'y
+	xtx := make([][]float64, k)
+	xty := make([]float64, k)
+	for i := range xtx {
+		xtx[i] = make([]float64, k)
+	}
+	for r := 0; r < n; r++ {
+		for i := 0; i < k; i++ {
+			xty[i] += X[r][i] * y[r]
+			for j := 0; j < k; j++ {
+				xtx[i][j] += X[r][i] * X[r][j]
+			}
+		}
+	}
+	inv, ok := invertMatrix(xtx)
+	if !ok {
+		return nil, nil, false
+	}
+	coef = make([]float64, k)
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			coef[i] += inv[i][j] * xty[j]
+		}
+	}
+
+	var rss float64
+	for r := 0; r < n; r++ {
+		var fit float64
+		for i := 0; i < k; i++ {
+			fit += X[r][i] * coef[i]
+		}
+		d := y[r] - fit
+		rss += d * d
+	}
+	s2 := rss / float64(n-k)
+	se = make([]float64, k)
+	for i := 0; i < k; i++ {
+		se[i] = math.Sqrt(math.Max(s2*inv[i][i], 0))
+	}
+	return coef, se, true
+}
+
+// invertMatrix Gauss-Jordan 求逆（小矩阵）
+func invertMatrix(m [][]float64) ([][]float64, bool) {
+	k := len(m)
+	a := make([][]float64, k)
+	for i := range m {
+		a[i] = make([]float64, 2*k)
+		copy(a[i], m[i])
+		a[i][k+i] = 1
+	}
+	for col := 0; col < k; col++ {
+		pivot := col
+		for r := col + 1; r < k; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		p := a[col][col]
+		for j := range a[col] {
+			a[col][j] /= p
+		}
+		for r := 0; r < k; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for j := range a[r] {
+				a[r][j] -= f * a[col][j]
+			}
+		}
+	}
+	inv := make([][]float64, k)
+	for i := range a {
+		inv[i] = a[i][k:]
+	}
+	return inv, true
+}
+
+// linearFit y = alpha + beta × x
+func linearFit(x, y []float64) (alpha, beta float64, ok bool) {
+	n := len(x)
+	if n < 3 || len(y) != n {
+		return 0, 0, false
+	}
+	var mx, my float64
+	for i := 0; i < n; i++ {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(n)
+	my /= float64(n)
+	var sxy, sxx float64
+	for i := 0; i < n; i++ {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+	}
+	if sxx == 0 {
+		return 0, 0, false
+	}
+	beta = sxy / sxx
+	return my - beta*mx, beta, true
+}
+
+// adfTest 对序列做 ADF 检验（无常数项，适用于 OLS 残差）：Δe_t = γ e_{t-1} + Σ φ_i Δe_{t-i} + ε
+// 返回 γ 的 t 统计量与 γ
+func adfTest(e []float64, lags int) (tStat, gamma float64, ok bool) {
+	if lags < 0 {
+		lags = 0
+	}
+	n := len(e)
+	if n < lags+10 {
+		return 0, 0, false
+	}
+	diff := make([]float64, n)
+	for t := 1; t < n; t++ {
+		diff[t] = e[t] - e[t-1]
+	}
+	var X [][]float64
+	var y []float64
+	for t := lags + 1; t < n; t++ {
+		row := make([]float64, 0, 1+lags)
+		row = append(row, e[t-1])
+		for i := 1; i <= lags; i++ {
+			row = append(row, diff[t-i])
+		}
+		X = append(X, row)
+		y = append(y, diff[t])
+	}
+	coef, se, ok := olsFit(X, y)
+	if !ok || se[0] == 0 {
+		return 0, 0, false
+	}
+	return coef[0] / se[0], coef[0], true
+}
+
+// engleGranger 两步法协整检验：先回归 ln(A) 对 ln(B)，再对残差做 ADF
+// level 为显著性水平：0.01 / 0.05 / 0.10，默认 0.05
+func engleGranger(logA, logB []float64, level float64) (*CointegrationResult, bool) {
+	alpha, beta, ok := linearFit(logB, logA)
+	if !ok {
+		return nil, false
+	}
+	resid := make([]float64, len(logA))
+	for i := range logA {
+		resid[i] = logA[i] - alpha - beta*logB[i]
+	}
+	tStat, gamma, ok := adfTest(resid, 1)
+	if !ok {
+		return nil, false
+	}
+
+	_, spreadStd := meanStd(resid)
+	res := &CointegrationResult{
+		Alpha:        alpha,
+		Beta:         beta,
+		ADFStat:      tStat,
+		Significance: "none",
+		SpreadStd:    spreadStd,
+		Samples:      len(resid),
+	}
+	switch {
+	case tStat < egCritical1:
+		res.Significance = "1%"
+	case tStat < egCritical5:
+		res.Significance = "5%"
+	case tStat < egCritical10:
+		res.Significance = "10%"
+	}
+	crit := egCritical5
+	if level > 0 && level <= 0.01 {
+		crit = egCritical1
+	} else if level >= 0.10 {
+		crit = egCritical10
+	}
+	res.Cointegrated = tStat < crit
+	if gamma < 0 {
+		res.HalfLife = -math.Ln2 / math.Log(1+gamma)
+	}
+	return res, true
+}
+
+// pairsSnapshot 某一时刻的滚动价差状态
+type pairsSnapshot struct {
+	Alpha  float64
+	Beta   float64
+	Spread float64
+	Z      float64
+}
+
+// rollingPairsZ 用截至 end（含）的 lookback 个对数价格做回归，返回最新价差 z-score
+func rollingPairsZ(logA, logB []float64, end, lookback int) (pairsSnapshot, bool) {
+	var snap pairsSnapshot
+	start := end - lookback + 1
+	if start < 0 || end >= len(logA) || end >= len(logB) {
+		return snap, false
+	}
+	wa, wb := logA[start:end+1], logB[start:end+1]
+	alpha, beta, ok := linearFit(wb, wa)
+	if !ok {
+		return snap, false
+	}
+	spreads := make([]float64, len(wa))
+	for i := range wa {
+		spreads[i] = wa[i] - alpha - beta*wb[i]
+	}
+	mean, sd := meanStd(spreads)
+	if sd == 0 {
+		return snap, false
+	}
+	snap.Alpha, snap.Beta = alpha, beta
+	snap.Spread = spreads[len(spreads)-1]
+	snap.Z = (snap.Spread - mean) / sd
+	return snap, true
+}
@@ -0,0 +1,1123 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// ========== 配对交易 / 统计套利 ==========
+// 选对：收益率相关性预筛 + Engle-Granger 协整检验（对数价格）
+// 交易：滚动窗口回归得到对冲比例与价差 z-score，|z| 超过入场阈值时开等金额多空两腿，
+// z 回归到出场阈值平仓；价差继续发散超过止损阈值或持仓超时强制平仓。
+// 两腿作为一个整体执行：第二腿失败立即回滚第一腿，平仓失败的腿在下一轮继续重试。
+
+const (
+	pairsCloseRetries = 3
+	pairsSource       = "strategy_pairs"
+)
+
+// PairsConfig 配对交易配置
+type PairsConfig struct {
+	SymbolA  string `json:"symbolA"`  // 被解释腿：ln(A) = alpha + beta × ln(B)
+	SymbolB  string `json:"symbolB"`  // 解释腿
+	Interval string `json:"interval"` // K 线周期，默认 1h
+	Lookback int    `json:"lookback"` // 滚动回归窗口（K 线数），默认 200
+
+	EntryZ      float64 `json:"entryZ"`      // 入场阈值，默认 2
+	ExitZ       float64 `json:"exitZ"`       // 出场阈值（回归到 ±exitZ 内），默认 0.5
+	StopZ       float64 `json:"stopZ"`       // 发散止损阈值，默认 4
+	MaxHoldBars int     `json:"maxHoldBars"` // 最长持仓 K 线数，默认 lookback/2
+
+	NotionalPerLeg float64 `json:"notionalPerLeg"` // 每腿名义价值(USDT)，两腿相等 → 美元中性
+	Leverage       int     `json:"leverage"`
+
+	RequireCointegration bool    `json:"requireCointegration,omitempty"` // 开仓前要求窗口内协整
+	CointLevel           float64 `json:"cointLevel,omitempty"`           // 显著性水平 0.01/0.05/0.10，默认 0.05
+}
+
+// PairsLeg 一条腿
+type PairsLeg struct {
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"` // LONG / SHORT
+	Qty        float64 `json:"qty"`  // 0 表示已平
+	EntryPrice float64 `json:"entryPrice"`
+	OrderID    int64   `json:"orderId"`
+}
+
+// PairsTrade 一次完整的配对交易
+type PairsTrade struct {
+	Direction  string  `json:"direction"` // LONG_SPREAD（多A空B） / SHORT_SPREAD（空A多B）
+	OpenTime   string  `json:"openTime"`
+	CloseTime  string  `json:"closeTime"`
+	EntryZ     float64 `json:"entryZ"`
+	ExitZ      float64 `json:"exitZ"`
+	HedgeRatio float64 `json:"hedgeRatio"`
+	Bars       int     `json:"bars"`
+	PnL        float64 `json:"pnl"`
+	Reason     string  `json:"reason"` // revert / stop / time
+}
+
+// PairsStatus 策略状态
+type PairsStatus struct {
+	Config        PairsConfig          `json:"config"`
+	Key           string               `json:"key"`
+	Active        bool                 `json:"active"`
+	Position      string               `json:"position"` // FLAT / LONG_SPREAD / SHORT_SPREAD
+	Legs          []PairsLeg           `json:"legs,omitempty"`
+	OpenedAt      string               `json:"openedAt,omitempty"`
+	BarsHeld      int                  `json:"barsHeld"`
+	Z             float64              `json:"z"`
+	HedgeRatio    float64              `json:"hedgeRatio"`
+	Cointegration *CointegrationResult `json:"cointegration,omitempty"`
+	Closing       bool                 `json:"closing"` // 有腿平仓失败，正在重试
+	LegFailures   int                  `json:"legFailures"`
+	Trades        []PairsTrade         `json:"trades"`
+	TotalPnl      float64              `json:"totalPnl"`
+	LastError     string               `json:"lastError"`
+	LastCheckAt   string               `json:"lastCheckAt"`
+}
+
+type pairsState struct {
+	Config PairsConfig
+	Key    string
+	Active bool
+
+	Position int // +1 多价差 / -1 空价差 / 0 空仓
+	Legs     []PairsLeg
+	OpenedAt time.Time
+	EntryZ   float64
+	EntryB   float64 // 入场时的对冲比例
+	Closing  bool
+	armed    bool // 止损后需 |z| 回到入场阈值内才允许再次开仓
+
+	Z           float64
+	HedgeRatio  float64
+	Coint       *CointegrationResult
+	LegFailures int
+	Trades      []PairsTrade
+	TotalPnl    float64
+	closePnl    float64 // 本次平仓已结算腿的盈亏
+	closeReason string  // 平仓未完成时记录原因，重试成功后写入交易记录
+	LastError   string
+	LastCheckAt time.Time
+
+	stopC chan struct{}
+}
+
+// pairsRuntime 持仓快照，重启后继续管理未平的两腿
+type pairsRuntime struct {
+	Position    int          `json:"position"`
+	Legs        []PairsLeg   `json:"legs"`
+	OpenedAt    time.Time    `json:"openedAt"`
+	EntryZ      float64      `json:"entryZ"`
+	EntryB      float64      `json:"entryB"`
+	Closing     bool         `json:"closing"`
+	ClosePnl    float64      `json:"closePnl"`
+	CloseReason string       `json:"closeReason,omitempty"`
+	Trades      []PairsTrade `json:"trades"`
+	TotalPnl    float64      `json:"totalPnl"`
+	LegFailures int          `json:"legFailures"`
+}
+
+var (
+	pairsTasks = make(map[string]*pairsState)
+	pairsMu    sync.Mutex
+)
+
+// pairsKey 策略标识，去掉 USDT 后缀以适配持久化字段长度，如 BTC-ETH
+func pairsKey(a, b string) string {
+	return strings.TrimSuffix(a, "USDT") + "-" + strings.TrimSuffix(b, "USDT")
+}
+
+func normalizePairsConfig(cfg *PairsConfig) error {
+	cfg.SymbolA = strings.ToUpper(strings.TrimSpace(cfg.SymbolA))
+	cfg.SymbolB = strings.ToUpper(strings.TrimSpace(cfg.SymbolB))
+	if cfg.SymbolA == "" || cfg.SymbolB == "" || cfg.SymbolA == cfg.SymbolB {
+		return fmt.Errorf("symbolA and symbolB are required and must differ")
+	}
+	if cfg.Interval == "" {
+		cfg.Interval = "1h"
+	}
+	if cfg.Lookback <= 0 {
+		cfg.Lookback = 200
+	}
+	if cfg.Lookback < 30 {
+		return fmt.Errorf("lookback must be >= 30")
+	}
+	if cfg.EntryZ <= 0 {
+		cfg.EntryZ = 2
+	}
+	if cfg.ExitZ <= 0 {
+		cfg.ExitZ = 0.5
+	}
+	if cfg.StopZ <= 0 {
+		cfg.StopZ = 4
+	}
+	if cfg.ExitZ >= cfg.EntryZ || cfg.StopZ <= cfg.EntryZ {
+		return fmt.Errorf("thresholds must satisfy exitZ < entryZ < stopZ")
+	}
+	if cfg.MaxHoldBars <= 0 {
+		cfg.MaxHoldBars = cfg.Lookback / 2
+	}
+	if cfg.CointLevel <= 0 {
+		cfg.CointLevel = 0.05
+	}
+	return nil
+}
+
+// StartPairs 启动配对交易（全新开始，清空旧的持仓快照）
+func StartPairs(cfg PairsConfig) error {
+	if err := startPairs(cfg, nil); err != nil {
+		return err
+	}
+	SaveStrategyRuntime("pairs", pairsKey(strings.ToUpper(cfg.SymbolA), strings.ToUpper(cfg.SymbolB)), nil)
+	return nil
+}
+
+// RecoverPairs 重启恢复：沿用持久化的两腿持仓
+func RecoverPairs(cfg PairsConfig) error {
+	var rt pairsRuntime
+	if !LoadStrategyRuntime("pairs", pairsKey(cfg.SymbolA, cfg.SymbolB), &rt) {
+		return startPairs(cfg, nil)
+	}
+	log.Printf("[Pairs] Recovering %s: position=%d, legs=%d, closing=%v", pairsKey(cfg.SymbolA, cfg.SymbolB), rt.Position, len(rt.Legs), rt.Closing)
+	return startPairs(cfg, &rt)
+}
+
+func startPairs(cfg PairsConfig, rt *pairsRuntime) error {
+	if err := normalizePairsConfig(&cfg); err != nil {
+		return err
+	}
+	if cfg.NotionalPerLeg <= 0 {
+		return fmt.Errorf("notionalPerLeg must be > 0")
+	}
+	if cfg.Leverage <= 0 {
+		return fmt.Errorf("leverage must be > 0")
+	}
+	key := pairsKey(cfg.SymbolA, cfg.SymbolB)
+
+	pairsMu.Lock()
+	defer pairsMu.Unlock()
+	if existing, ok := pairsTasks[key]; ok && existing.Active {
+		return fmt.Errorf("pairs strategy already running for %s", key)
+	}
+
+	state := &pairsState{
+		Config: cfg,
+		Key:    key,
+		Active: true,
+		armed:  true,
+		stopC:  make(chan struct{}),
+	}
+	if rt != nil {
+		state.Position = rt.Position
+		state.Legs = rt.Legs
+		state.OpenedAt = rt.OpenedAt
+		state.EntryZ = rt.EntryZ
+		state.EntryB = rt.EntryB
+		state.Closing = rt.Closing
+		state.closePnl = rt.ClosePnl
+		state.closeReason = rt.CloseReason
+		state.Trades = rt.Trades
+		state.TotalPnl = rt.TotalPnl
+		state.LegFailures = rt.LegFailures
+	}
+	pairsTasks[key] = state
+
+	go pairsLoop(state)
+
+	log.Printf("[Pairs] Started %s: interval=%s, lookback=%d, z(entry=%.2f exit=%.2f stop=%.2f), maxHold=%d bars, notional=%.2f/leg, lev=%dx",
+		key, cfg.Interval, cfg.Lookback, cfg.EntryZ, cfg.ExitZ, cfg.StopZ, cfg.MaxHoldBars, cfg.NotionalPerLeg, cfg.Leverage)
+
+	SaveStrategyState("pairs", key, cfg)
+	return nil
+}
+
+// StopPairs 停止配对交易（不主动平仓）
+func StopPairs(key string) error {
+	pairsMu.Lock()
+	defer pairsMu.Unlock()
+
+	state, ok := pairsTasks[key]
+	if !ok || !state.Active {
+		return fmt.Errorf("no active pairs strategy for %s", key)
+	}
+	close(state.stopC)
+	state.Active = false
+	log.Printf("[Pairs] Stopped %s: trades=%d, PnL=%.4f, position=%d", key, len(state.Trades), state.TotalPnl, state.Position)
+	MarkStrategyStopped("pairs", key)
+	return nil
+}
+
+// GetPairsStatus 获取策略状态
+func GetPairsStatus(key string) *PairsStatus {
+	pairsMu.Lock()
+	defer pairsMu.Unlock()
+
+	state, ok := pairsTasks[key]
+	if !ok {
+		return nil
+	}
+	status := &PairsStatus{
+		Config:        state.Config,
+		Key:           state.Key,
+		Active:        state.Active,
+		Position:      pairsDirectionName(state.Position),
+		Legs:          append([]PairsLeg(nil), state.Legs...),
+		Z:             roundFloat(state.Z, 4),
+		HedgeRatio:    roundFloat(state.HedgeRatio, 4),
+		Cointegration: state.Coint,
+		Closing:       state.Closing,
+		LegFailures:   state.LegFailures,
+		Trades:        append([]PairsTrade(nil), state.Trades...),
+		TotalPnl:      roundFloat(state.TotalPnl, 4),
+		LastError:     state.LastError,
+	}
+	if !state.OpenedAt.IsZero() {
+		status.OpenedAt = state.OpenedAt.Format("2006-01-02 15:04:05")
+		status.BarsHeld = pairsBarsHeld(state.Config, state.OpenedAt, time.Now())
+	}
+	if !state.LastCheckAt.IsZero() {
+		status.LastCheckAt = state.LastCheckAt.Format("15:04:05")
+	}
+	return status
+}
+
+func pairsDirectionName(pos int) string {
+	switch pos {
+	case 1:
+		return "LONG_SPREAD"
+	case -1:
+		return "SHORT_SPREAD"
+	}
+	return "FLAT"
+}
+
+func pairsBarsHeld(cfg PairsConfig, openedAt, now time.Time) int {
+	return int(now.Sub(openedAt) / klineToCheckInterval(cfg.Interval))
+}
+
+// pairsDecide 根据当前 z-score 给出动作（实盘与回测共用）
+// pos: +1 多价差 / -1 空价差 / 0 空仓；armed: 空仓时是否允许开仓
+// 返回动作 open_long / open_short / close 与平仓原因 revert / stop / time
+func pairsDecide(cfg PairsConfig, pos int, z float64, barsHeld int, armed bool) (action, reason string) {
+	switch pos {
+	case 0:
+		if !armed || math.Abs(z) >= cfg.StopZ {
+			return "", ""
+		}
+		if z <= -cfg.EntryZ {
+			return "open_long", ""
+		}
+		if z >= cfg.EntryZ {
+			return "open_short", ""
+		}
+	case 1:
+		if z <= -cfg.StopZ {
+			return "close", "stop"
+		}
+		if z >= -cfg.ExitZ {
+			return "close", "revert"
+		}
+	case -1:
+		if z >= cfg.StopZ {
+			return "close", "stop"
+		}
+		if z <= cfg.ExitZ {
+			return "close", "revert"
+		}
+	}
+	if pos != 0 && cfg.MaxHoldBars > 0 && barsHeld >= cfg.MaxHoldBars {
+		return "close", "time"
+	}
+	return "", ""
+}
+
+// ========== 实盘循环 ==========
+
+func pairsLoop(state *pairsState) {
+	cfg := state.Config
+	ctx := context.Background()
+
+	for _, sym := range []string{cfg.SymbolA, cfg.SymbolB} {
+		if _, err := ChangeLeverage(ctx, sym, cfg.Leverage); err != nil {
+			log.Printf("[Pairs] Warning: set leverage failed for %s: %v", sym, err)
+		}
+	}
+
+	// 比 K 线周期更频繁地检查，以便及时止损；z-score 使用含当前未收盘 K 线的最新价
+	every := klineToCheckInterval(cfg.Interval) / 4
+	if every < time.Minute {
+		every = time.Minute
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	pairsCheck(ctx, state)
+	for {
+		select {
+		case <-state.stopC:
+			log.Printf("[Pairs] Loop stopped for %s", state.Key)
+			return
+		case <-ticker.C:
+			pairsCheck(ctx, state)
+		}
+	}
+}
+
+func pairsCheck(ctx context.Context, state *pairsState) {
+	cfg := state.Config
+
+	pairsMu.Lock()
+	state.LastCheckAt = time.Now()
+	closing := state.Closing
+	pairsMu.Unlock()
+
+	// 上次平仓有腿失败：先把剩余腿平掉
+	if closing {
+		pairsCloseLegs(ctx, state, "retry", 0)
+		return
+	}
+
+	limit := cfg.Lookback + 1
+	if cfg.RequireCointegration && limit < 2*cfg.Lookback {
+		limit = 2 * cfg.Lookback
+	}
+	_, closesA, closesB, err := fetchPairsCloses(ctx, cfg.SymbolA, cfg.SymbolB, cfg.Interval, limit)
+	if err != nil {
+		pairsSetError(state, fmt.Sprintf("fetch klines: %v", err))
+		return
+	}
+	if len(closesA) < cfg.Lookback {
+		pairsSetError(state, fmt.Sprintf("K线不足: %d < %d", len(closesA), cfg.Lookback))
+		return
+	}
+	logA, logB := pairsLogPrices(closesA), pairsLogPrices(closesB)
+	snap, ok := rollingPairsZ(logA, logB, len(logA)-1, cfg.Lookback)
+	if !ok {
+		pairsSetError(state, "rolling regression failed")
+		return
+	}
+	var coint *CointegrationResult
+	if cfg.RequireCointegration {
+		coint, _ = engleGranger(logA, logB, cfg.CointLevel)
+	}
+
+	pairsMu.Lock()
+	state.Z = snap.Z
+	state.HedgeRatio = snap.Beta
+	state.Coint = coint
+	state.LastError = ""
+	if math.Abs(snap.Z) < cfg.EntryZ {
+		state.armed = true
+	}
+	pos, armed, openedAt := state.Position, state.armed, state.OpenedAt
+	pairsMu.Unlock()
+
+	bars := 0
+	if pos != 0 {
+		bars = pairsBarsHeld(cfg, openedAt, time.Now())
+	}
+	action, reason := pairsDecide(cfg, pos, snap.Z, bars, armed)
+	switch action {
+	case "open_long", "open_short":
+		if cfg.RequireCointegration && (coint == nil || !coint.Cointegrated) {
+			log.Printf("[Pairs] %s z=%.2f but not cointegrated (adf=%.2f), skip entry", state.Key, snap.Z, pairsADF(coint))
+			return
+		}
+		dir := 1
+		if action == "open_short" {
+			dir = -1
+		}
+		if err := pairsOpen(ctx, state, dir, snap, closesA[len(closesA)-1], closesB[len(closesB)-1]); err != nil {
+			pairsSetError(state, err.Error())
+		}
+	case "close":
+		pairsCloseLegs(ctx, state, reason, snap.Z)
+	}
+}
+
+func pairsADF(c *CointegrationResult) float64 {
+	if c == nil {
+		return 0
+	}
+	return c.ADFStat
+}
+
+func pairsSetError(state *pairsState, msg string) {
+	pairsMu.Lock()
+	state.LastError = msg
+	pairsMu.Unlock()
+	log.Printf("[Pairs] %s: %s", state.Key, msg)
+}
+
+// pairsOpen 两腿作为一个整体开仓：第二腿失败则回滚第一腿
+func pairsOpen(ctx context.Context, state *pairsState, dir int, snap pairsSnapshot, priceA, priceB float64) error {
+	cfg := state.Config
+	margin := strconv.FormatFloat(cfg.NotionalPerLeg/float64(cfg.Leverage), 'f', 4, 64)
+	sideA, sideB := "LONG", "SHORT"
+	if dir < 0 {
+		sideA, sideB = "SHORT", "LONG"
+	}
+
+	legA, err := pairsPlaceLeg(ctx, cfg, cfg.SymbolA, sideA, margin, priceA)
+	if err != nil {
+		return fmt.Errorf("leg %s %s failed: %w", sideA, cfg.SymbolA, err)
+	}
+	legB, err := pairsPlaceLeg(ctx, cfg, cfg.SymbolB, sideB, margin, priceB)
+	if err != nil {
+		log.Printf("[Pairs] %s leg B failed (%v), unwinding leg A", state.Key, err)
+		pairsMu.Lock()
+		state.LegFailures++
+		pairsMu.Unlock()
+		if _, uerr := pairsCloseLeg(ctx, legA); uerr != nil {
+			// 回滚失败：把 A 腿登记为待平仓，下一轮继续重试
+			pairsMu.Lock()
+			state.Position = dir
+			state.Legs = []PairsLeg{legA}
+			state.OpenedAt = time.Now()
+			state.Closing = true
+			state.closeReason = "unwind"
+			rt := pairsRuntimeOf(state)
+			pairsMu.Unlock()
+			SaveStrategyRuntime("pairs", state.Key, rt)
+			SaveFailedOperation("pairs_unwind", pairsSource, legA.Symbol, legA, legA.OrderID, uerr)
+			SendNotify(fmt.Sprintf("配对 %s 第二腿失败且回滚 %s 失败，裸露单腿敞口，持续重试中: %v", state.Key, legA.Symbol, uerr))
+			return fmt.Errorf("leg B failed: %v; unwind leg A failed: %v", err, uerr)
+		}
+		return fmt.Errorf("leg %s %s failed, leg A unwound: %w", sideB, cfg.SymbolB, err)
+	}
+
+	pairsMu.Lock()
+	state.Position = dir
+	state.Legs = []PairsLeg{legA, legB}
+	state.OpenedAt = time.Now()
+	state.EntryZ = snap.Z
+	state.EntryB = snap.Beta
+	state.closePnl = 0
+	state.closeReason = ""
+	rt := pairsRuntimeOf(state)
+	pairsMu.Unlock()
+	SaveStrategyRuntime("pairs", state.Key, rt)
+
+	log.Printf("[Pairs] Opened %s %s: z=%.2f beta=%.3f, %s %s qty=%.6f@%.4f / %s %s qty=%.6f@%.4f",
+		state.Key, pairsDirectionName(dir), snap.Z, snap.Beta,
+		legA.Side, legA.Symbol, legA.Qty, legA.EntryPrice, legB.Side, legB.Symbol, legB.Qty, legB.EntryPrice)
+	NotifyTradeOpen(state.Key, pairsDirectionName(dir), strconv.FormatFloat(cfg.NotionalPerLeg*2, 'f', 2, 64), cfg.Leverage,
+		fmt.Sprintf("配对 z=%.2f", snap.Z))
+	return nil
+}
+
+func pairsPlaceLeg(ctx context.Context, cfg PairsConfig, symbol, side, margin string, refPrice float64) (PairsLeg, error) {
+	req := PlaceOrderReq{
+		Source:        pairsSource,
+		Symbol:        symbol,
+		Side:          futures.SideTypeBuy,
+		OrderType:     futures.OrderTypeMarket,
+		PositionSide:  futures.PositionSideTypeLong,
+		QuoteQuantity: margin,
+		Leverage:      cfg.Leverage,
+	}
+	if side == "SHORT" {
+		req.Side = futures.SideTypeSell
+		req.PositionSide = futures.PositionSideTypeShort
+	}
+	res, err := PlaceOrderViaWs(ctx, req)
+	if err != nil {
+		return PairsLeg{}, err
+	}
+	qty, price := pairsOrderFill(res.Order, refPrice, cfg.NotionalPerLeg)
+	return PairsLeg{Symbol: symbol, Side: side, Qty: qty, EntryPrice: price, OrderID: res.Order.OrderID}, nil
+}
+
+// pairsOrderFill 从订单回报取成交量与成交价，缺失时按参考价估算
+func pairsOrderFill(order *futures.CreateOrderResponse, refPrice, notional float64) (qty, price float64) {
+	if order != nil {
+		price, _ = strconv.ParseFloat(order.AvgPrice, 64)
+		qty, _ = strconv.ParseFloat(order.ExecutedQuantity, 64)
+		if qty <= 0 {
+			qty, _ = strconv.ParseFloat(order.OrigQuantity, 64)
+		}
+	}
+	if price <= 0 {
+		price = refPrice
+	}
+	if qty <= 0 && price > 0 && notional > 0 {
+		qty = notional / price
+	}
+	return qty, price
+}
+
+// pairsCloseLeg 按本腿数量减仓，返回成交价
+func pairsCloseLeg(ctx context.Context, leg PairsLeg) (float64, error) {
+	posSide := futures.PositionSideTypeLong
+	if leg.Side == "SHORT" {
+		posSide = futures.PositionSideTypeShort
+	}
+	var lastErr error
+	for i := 0; i < pairsCloseRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		resp, err := ReducePositionViaWs(ctx, ReducePositionReq{
+			Symbol:       leg.Symbol,
+			PositionSide: posSide,
+			Quantity:     strconv.FormatFloat(leg.Qty, 'f', -1, 64),
+		})
+		if err == nil {
+			price, _ := strconv.ParseFloat(resp.AvgPrice, 64)
+			if price <= 0 {
+				price, _ = GetPriceCache().GetPrice(leg.Symbol)
+			}
+			return price, nil
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+func pairsLegPnl(leg PairsLeg, exitPrice float64) float64 {
+	if exitPrice <= 0 {
+		return 0
+	}
+	if leg.Side == "SHORT" {
+		return (leg.EntryPrice - exitPrice) * leg.Qty
+	}
+	return (exitPrice - leg.EntryPrice) * leg.Qty
+}
+
+// pairsCloseLegs 平掉所有未平的腿；部分失败时保留剩余腿并标记 closing，下一轮重试
+func pairsCloseLegs(ctx context.Context, state *pairsState, reason string, z float64) {
+	pairsMu.Lock()
+	legs := append([]PairsLeg(nil), state.Legs...)
+	pairsMu.Unlock()
+
+	var remaining []PairsLeg
+	var pnl float64
+	var failErr error
+	for _, leg := range legs {
+		if leg.Qty <= 0 {
+			continue
+		}
+		exit, err := pairsCloseLeg(ctx, leg)
+		if err != nil {
+			failErr = err
+			remaining = append(remaining, leg)
+			continue
+		}
+		pnl += pairsLegPnl(leg, exit)
+	}
+
+	pairsMu.Lock()
+	state.closePnl += pnl
+	if len(remaining) > 0 {
+		wasClosing := state.Closing
+		state.Closing = true
+		if state.closeReason == "" {
+			state.closeReason = reason
+		}
+		state.Legs = remaining
+		state.LegFailures++
+		state.LastError = fmt.Sprintf("close leg failed: %v", failErr)
+		rt := pairsRuntimeOf(state)
+		pairsMu.Unlock()
+		SaveStrategyRuntime("pairs", state.Key, rt)
+		if !wasClosing {
+			SaveFailedOperation("pairs_close", pairsSource, remaining[0].Symbol, remaining, remaining[0].OrderID, failErr)
+			SendNotify(fmt.Sprintf("配对 %s 平仓时 %s 腿失败，单腿敞口待平，持续重试: %v", state.Key, remaining[0].Symbol, failErr))
+		}
+		return
+	}
+
+	if state.closeReason != "" {
+		reason = state.closeReason
+	}
+	trade := PairsTrade{
+		Direction:  pairsDirectionName(state.Position),
+		OpenTime:   state.OpenedAt.Format("2006-01-02 15:04:05"),
+		CloseTime:  time.Now().Format("2006-01-02 15:04:05"),
+		EntryZ:     roundFloat(state.EntryZ, 4),
+		ExitZ:      roundFloat(z, 4),
+		HedgeRatio: roundFloat(state.EntryB, 4),
+		Bars:       pairsBarsHeld(state.Config, state.OpenedAt, time.Now()),
+		PnL:        roundFloat(state.closePnl, 4),
+		Reason:     reason,
+	}
+	state.Trades = append(state.Trades, trade)
+	if len(state.Trades) > 200 {
+		state.Trades = state.Trades[len(state.Trades)-200:]
+	}
+	state.TotalPnl += state.closePnl
+	state.Position = 0
+	state.Legs = nil
+	state.OpenedAt = time.Time{}
+	state.Closing = false
+	state.closePnl = 0
+	state.closeReason = ""
+	if reason == "stop" {
+		state.armed = false
+	}
+	rt := pairsRuntimeOf(state)
+	pairsMu.Unlock()
+	SaveStrategyRuntime("pairs", state.Key, rt)
+
+	log.Printf("[Pairs] Closed %s %s: reason=%s, z=%.2f, bars=%d, pnl=%.4f", state.Key, trade.Direction, reason, z, trade.Bars, trade.PnL)
+	NotifyTradeClose(state.Key, trade.Direction, trade.PnL, "配对平仓: "+reason)
+}
+
+// pairsRuntimeOf 生成持仓快照（调用方持有 pairsMu）
+func pairsRuntimeOf(state *pairsState) *pairsRuntime {
+	return &pairsRuntime{
+		Position:    state.Position,
+		Legs:        append([]PairsLeg(nil), state.Legs...),
+		OpenedAt:    state.OpenedAt,
+		EntryZ:      state.EntryZ,
+		EntryB:      state.EntryB,
+		Closing:     state.Closing,
+		ClosePnl:    state.closePnl,
+		CloseReason: state.closeReason,
+		Trades:      append([]PairsTrade(nil), state.Trades...),
+		TotalPnl:    state.TotalPnl,
+		LegFailures: state.LegFailures,
+	}
+}
+
+// ========== 数据 ==========
+
+// fetchPairsCloses 拉取两个币种最近 limit 根 K 线并按开盘时间对齐
+func fetchPairsCloses(ctx context.Context, symbolA, symbolB, interval string, limit int) ([]int64, []float64, []float64, error) {
+	ka, err := fetchIntervalKlines(ctx, symbolA, interval, limit)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", symbolA, err)
+	}
+	kb, err := fetchIntervalKlines(ctx, symbolB, interval, limit)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", symbolB, err)
+	}
+	times, a, b := alignPairsKlines(ka, kb)
+	return times, a, b, nil
+}
+
+// fetchIntervalKlines 向前分页拉取最近 total 根 K 线（单次最多 1500）
+func fetchIntervalKlines(ctx context.Context, symbol, interval string, total int) ([]*futures.Kline, error) {
+	const batch = 1500
+	var out []*futures.Kline
+	var endTime int64
+	for len(out) < total {
+		n := total - len(out)
+		if n > batch {
+			n = batch
+		}
+		svc := Client.NewKlinesService().Symbol(symbol).Interval(interval).Limit(n)
+		if endTime > 0 {
+			svc = svc.EndTime(endTime)
+		}
+		klines, err := svc.Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(klines) == 0 {
+			break
+		}
+		out = append(klines, out...)
+		endTime = klines[0].OpenTime - 1
+		if len(klines) < n {
+			break
+		}
+	}
+	return out, nil
+}
+
+func alignPairsKlines(ka, kb []*futures.Kline) ([]int64, []float64, []float64) {
+	closeB := make(map[int64]float64, len(kb))
+	for _, k := range kb {
+		if c, _ := strconv.ParseFloat(k.Close, 64); c > 0 {
+			closeB[k.OpenTime] = c
+		}
+	}
+	var times []int64
+	var a, b []float64
+	for _, k := range ka {
+		ca, _ := strconv.ParseFloat(k.Close, 64)
+		cb, ok := closeB[k.OpenTime]
+		if !ok || ca <= 0 {
+			continue
+		}
+		times = append(times, k.OpenTime)
+		a = append(a, ca)
+		b = append(b, cb)
+	}
+	return times, a, b
+}
+
+func pairsLogPrices(closes []float64) []float64 {
+	out := make([]float64, len(closes))
+	for i, c := range closes {
+		out[i] = math.Log(c)
+	}
+	return out
+}
+
+// ========== 选对 ==========
+
+// PairCandidate 候选配对
+type PairCandidate struct {
+	SymbolA     string  `json:"symbolA"`
+	SymbolB     string  `json:"symbolB"`
+	Correlation float64 `json:"correlation"` // 收益率 Pearson 相关系数
+	CointegrationResult
+	CurrentZ float64 `json:"currentZ"`
+}
+
+// ScanCointegratedPairs 对一组币种两两做相关性预筛和 Engle-Granger 检验，按 ADF 统计量升序返回
+func ScanCointegratedPairs(ctx context.Context, symbols []string, interval string, limit int, minCorr, level float64) ([]PairCandidate, error) {
+	if len(symbols) < 2 {
+		return nil, fmt.Errorf("at least 2 symbols required")
+	}
+	if interval == "" {
+		interval = "1h"
+	}
+	if limit <= 0 {
+		limit = 500
+	}
+	type series struct {
+		times  []int64
+		closes map[int64]float64
+	}
+	data := make(map[string]series, len(symbols))
+	for _, sym := range symbols {
+		klines, err := fetchIntervalKlines(ctx, sym, interval, limit)
+		if err != nil {
+			return nil, fmt.Errorf("fetch klines for %s: %w", sym, err)
+		}
+		s := series{closes: make(map[int64]float64, len(klines))}
+		for _, k := range klines {
+			if c, _ := strconv.ParseFloat(k.Close, 64); c > 0 {
+				s.times = append(s.times, k.OpenTime)
+				s.closes[k.OpenTime] = c
+			}
+		}
+		data[sym] = s
+	}
+
+	var out []PairCandidate
+	for i := 0; i < len(symbols); i++ {
+		for j := i + 1; j < len(symbols); j++ {
+			sa, sb := data[symbols[i]], data[symbols[j]]
+			var a, b []float64
+			for _, t := range sa.times {
+				if cb, ok := sb.closes[t]; ok {
+					a = append(a, sa.closes[t])
+					b = append(b, cb)
+				}
+			}
+			if len(a) < 50 {
+				continue
+			}
+			corr := pearson(pairsReturns(a), pairsReturns(b))
+			if corr < minCorr {
+				continue
+			}
+			logA, logB := pairsLogPrices(a), pairsLogPrices(b)
+			res, ok := engleGranger(logA, logB, level)
+			if !ok {
+				continue
+			}
+			cand := PairCandidate{
+				SymbolA:             symbols[i],
+				SymbolB:             symbols[j],
+				Correlation:         roundFloat(corr, 4),
+				CointegrationResult: *res,
+			}
+			if snap, ok := rollingPairsZ(logA, logB, len(logA)-1, len(logA)); ok {
+				cand.CurrentZ = roundFloat(snap.Z, 4)
+			}
+			cand.ADFStat = roundFloat(cand.ADFStat, 4)
+			cand.Beta = roundFloat(cand.Beta, 4)
+			cand.HalfLife = roundFloat(cand.HalfLife, 2)
+			out = append(out, cand)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ADFStat < out[j].ADFStat })
+	return out, nil
+}
+
+func pairsReturns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return nil
+	}
+	rets := make([]float64, len(closes)-1)
+	for i := 1; i < len(closes); i++ {
+		rets[i-1] = (closes[i] - closes[i-1]) / closes[i-1]
+	}
+	return rets
+}
+
+// ========== 回测 ==========
+
+// PairsBacktestConfig 配对回测参数
+type PairsBacktestConfig struct {
+	PairsConfig
+	Bars    int     `json:"bars"`    // 回测 K 线数量，默认 2000
+	FeeRate float64 `json:"feeRate"` // 单边手续费率，默认 0.0004
+}
+
+// PairsBacktestResult 配对回测结果
+type PairsBacktestResult struct {
+	SymbolA       string               `json:"symbolA"`
+	SymbolB       string               `json:"symbolB"`
+	Interval      string               `json:"interval"`
+	Bars          int                  `json:"bars"`
+	Period        string               `json:"period"`
+	Cointegration *CointegrationResult `json:"cointegration,omitempty"` // 全样本协整检验
+	TotalTrades   int                  `json:"totalTrades"`
+	WinRate       float64              `json:"winRate"`
+	TotalPnL      float64              `json:"totalPnl"`
+	TotalFees     float64              `json:"totalFees"`
+	MaxDrawdown   float64              `json:"maxDrawdown"`
+	ExitReasons   map[string]int       `json:"exitReasons"`
+	Trades        []PairsTrade         `json:"trades"`
+}
+
+// RunPairsBacktest 拉取历史 K 线回放配对策略
+func RunPairsBacktest(ctx context.Context, cfg PairsBacktestConfig) (*PairsBacktestResult, error) {
+	if err := normalizePairsConfig(&cfg.PairsConfig); err != nil {
+		return nil, err
+	}
+	if cfg.Bars <= 0 {
+		cfg.Bars = 2000
+	}
+	if cfg.Bars > 10000 {
+		cfg.Bars = 10000
+	}
+	times, a, b, err := fetchPairsCloses(ctx, cfg.SymbolA, cfg.SymbolB, cfg.Interval, cfg.Bars)
+	if err != nil {
+		return nil, err
+	}
+	if len(a) <= cfg.Lookback+1 {
+		return nil, fmt.Errorf("insufficient aligned klines: %d", len(a))
+	}
+	return simulatePairs(cfg, times, a, b), nil
+}
+
+// simulatePairs 逐根 K 线回放：收盘价成交，两腿等名义价值，每腿开平各收一次手续费
+func simulatePairs(cfg PairsBacktestConfig, times []int64, closesA, closesB []float64) *PairsBacktestResult {
+	if cfg.FeeRate <= 0 {
+		cfg.FeeRate = 0.0004
+	}
+	if cfg.NotionalPerLeg <= 0 {
+		cfg.NotionalPerLeg = 100
+	}
+	logA, logB := pairsLogPrices(closesA), pairsLogPrices(closesB)
+	res := &PairsBacktestResult{
+		SymbolA:     cfg.SymbolA,
+		SymbolB:     cfg.SymbolB,
+		Interval:    cfg.Interval,
+		Bars:        len(closesA),
+		ExitReasons: make(map[string]int),
+	}
+	if len(times) > 0 {
+		res.Period = time.UnixMilli(times[0]).Format("2006-01-02") + " ~ " + time.UnixMilli(times[len(times)-1]).Format("2006-01-02")
+	}
+	res.Cointegration, _ = engleGranger(logA, logB, cfg.CointLevel)
+
+	var (
+		pos               int
+		armed             = true
+		openIdx           int
+		qtyA, qtyB        float64
+		entryA, entryB    float64
+		entryZ, entryBeta float64
+		equity, peak      float64
+		wins              int
+	)
+	fmtTime := func(i int) string {
+		if i < len(times) {
+			return time.UnixMilli(times[i]).Format("2006-01-02 15:04")
+		}
+		return ""
+	}
+
+	for t := cfg.Lookback - 1; t < len(closesA); t++ {
+		snap, ok := rollingPairsZ(logA, logB, t, cfg.Lookback)
+		if !ok {
+			continue
+		}
+		if math.Abs(snap.Z) < cfg.EntryZ {
+			armed = true
+		}
+		action, reason := pairsDecide(cfg.PairsConfig, pos, snap.Z, t-openIdx, armed)
+		if action == "" && pos != 0 && t == len(closesA)-1 {
+			action, reason = "close", "end"
+		}
+		switch action {
+		case "open_long", "open_short":
+			if cfg.RequireCointegration {
+				start := t - 2*cfg.Lookback + 1
+				if start < 0 {
+					start = 0
+				}
+				if c, ok := engleGranger(logA[start:t+1], logB[start:t+1], cfg.CointLevel); !ok || !c.Cointegrated {
+					continue
+				}
+			}
+			pos = 1
+			if action == "open_short" {
+				pos = -1
+			}
+			openIdx = t
+			entryA, entryB = closesA[t], closesB[t]
+			qtyA, qtyB = cfg.NotionalPerLeg/entryA, cfg.NotionalPerLeg/entryB
+			entryZ, entryBeta = snap.Z, snap.Beta
+		case "close":
+			exitA, exitB := closesA[t], closesB[t]
+			gross := float64(pos) * ((exitA-entryA)*qtyA - (exitB-entryB)*qtyB)
+			fees := cfg.FeeRate * (qtyA*(entryA+exitA) + qtyB*(entryB+exitB))
+			pnl := gross - fees
+			res.TotalFees += fees
+			res.Trades = append(res.Trades, PairsTrade{
+				Direction:  pairsDirectionName(pos),
+				OpenTime:   fmtTime(openIdx),
+				CloseTime:  fmtTime(t),
+				EntryZ:     roundFloat(entryZ, 4),
+				ExitZ:      roundFloat(snap.Z, 4),
+				HedgeRatio: roundFloat(entryBeta, 4),
+				Bars:       t - openIdx,
+				PnL:        roundFloat(pnl, 4),
+				Reason:     reason,
+			})
+			res.ExitReasons[reason]++
+			if pnl > 0 {
+				wins++
+			}
+			equity += pnl
+			if equity > peak {
+				peak = equity
+			}
+			if dd := peak - equity; dd > res.MaxDrawdown {
+				res.MaxDrawdown = dd
+			}
+			if reason == "stop" {
+				armed = false
+			}
+			pos = 0
+		}
+	}
+
+	res.TotalTrades = len(res.Trades)
+	if res.TotalTrades > 0 {
+		res.WinRate = roundFloat(float64(wins)/float64(res.TotalTrades), 4)
+	}
+	res.TotalPnL = roundFloat(equity, 4)
+	res.TotalFees = roundFloat(res.TotalFees, 4)
+	res.MaxDrawdown = roundFloat(res.MaxDrawdown, 4)
+	return res
+}
+
+// ========== Handlers ==========
+
+// HandleStartPairs POST /tool/pairs/start
+func HandleStartPairs(c context.Context, ctx *app.RequestContext) {
+	var cfg PairsConfig
+	if err := ctx.BindAndValidate(&cfg); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if err := StartPairs(cfg); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	key := pairsKey(strings.ToUpper(cfg.SymbolA), strings.ToUpper(cfg.SymbolB))
+	ctx.JSON(http.StatusOK, utils.H{"message": "pairs strategy started", "key": key})
+}
+
+// HandleStopPairs POST /tool/pairs/stop
+func HandleStopPairs(c context.Context, ctx *app.RequestContext) {
+	var req struct {
+		Key string `json:"key"` // 如 BTC-ETH
+	}
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if err := StopPairs(req.Key); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"message": "pairs strategy stopped", "key": req.Key})
+}
+
+// HandlePairsStatus GET /tool/pairs/status?key=BTC-ETH
+func HandlePairsStatus(c context.Context, ctx *app.RequestContext) {
+	key := ctx.Query("key")
+	if key == "" {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "key is required"})
+		return
+	}
+	status := GetPairsStatus(key)
+	if status == nil {
+		ctx.JSON(http.StatusOK, utils.H{"data": nil, "message": "no pairs strategy for " + key})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": status})
+}
+
+// HandleScanPairs GET /tool/pairs/scan?symbols=BTCUSDT,ETHUSDT,SOLUSDT&interval=1h&limit=500&minCorr=0.7&level=0.05
+func HandleScanPairs(c context.Context, ctx *app.RequestContext) {
+	raw := ctx.Query("symbols")
+	if raw == "" {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "symbols is required"})
+		return
+	}
+	var symbols []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) > 20 {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "at most 20 symbols"})
+		return
+	}
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	minCorr, err := strconv.ParseFloat(ctx.DefaultQuery("minCorr", "0.5"), 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "invalid minCorr"})
+		return
+	}
+	level, _ := strconv.ParseFloat(ctx.DefaultQuery("level", "0.05"), 64)
+
+	result, err := ScanCointegratedPairs(c, symbols, ctx.Query("interval"), limit, minCorr, level)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": result})
+}
+
+// HandlePairsBacktest POST /tool/pairs/backtest
+func HandlePairsBacktest(c context.Context, ctx *app.RequestContext) {
+	var cfg PairsBacktestConfig
+	if err := ctx.BindJSON(&cfg); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	result, err := RunPairsBacktest(c, cfg)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": result})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// synthPair 生成一对协整价格：ln(A) = 0.5 + 1.2 × ln(B) + OU 噪声
+func synthPair(n int, seed int64) (a, b []float64) {
+	rng := rand.New(rand.NewSource(seed))
+	lnB, e := math.Log(100), 0.0
+	for i := 0; i < n; i++ {
+		lnB += rng.NormFloat64() * 0.01
+		e = 0.8*e + rng.NormFloat64()*0.01
+		b = append(b, math.Exp(lnB))
+		a = append(a, math.Exp(0.5+1.2*lnB+e))
+	}
+	return a, b
+}
+
+func TestEngleGranger(t *testing.T) {
+	a, b := synthPair(600, 1)
+	res, ok := engleGranger(pairsLogPrices(a), pairsLogPrices(b), 0.05)
+	if !ok || !res.Cointegrated {
+		t.Fatalf("synthetic pair should be cointegrated: %+v", res)
+	}
+	if math.Abs(res.Beta-1.2) > 0.1 || res.HalfLife <= 0 {
+		t.Fatalf("beta=%.3f halfLife=%.2f", res.Beta, res.HalfLife)
+	}
+
+	// 两条独立随机游走不应协整
+	rng := rand.New(rand.NewSource(7))
+	x, y := make([]float64, 600), make([]float64, 600)
+	for i := 1; i < 600; i++ {
+		x[i] = x[i-1] + rng.NormFloat64()*0.01
+		y[i] = y[i-1] + rng.NormFloat64()*0.01
+	}
+	if res, ok := engleGranger(x, y, 0.05); ok && res.Cointegrated {
+		t.Fatalf("independent random walks flagged cointegrated: adf=%.2f", res.ADFStat)
+	}
+}
+
+func TestPairsDecide(t *testing.T) {
+	cfg := PairsConfig{SymbolA: "AUSDT", SymbolB: "BUSDT"}
+	if err := normalizePairsConfig(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		pos    int
+		z      float64
+		bars   int
+		armed  bool
+		action string
+		reason string
+	}{
+		{0, -2.5, 0, true, "open_long", ""},
+		{0, 2.5, 0, true, "open_short", ""},
+		{0, 2.5, 0, false, "", ""},
+		{0, 4.5, 0, true, "", ""},
+		{1, -0.3, 3, true, "close", "revert"},
+		{1, -4.2, 3, true, "close", "stop"},
+		{-1, 1.5, cfg.MaxHoldBars, true, "close", "time"},
+		{-1, 1.5, 1, true, "", ""},
+	}
+	for _, c := range cases {
+		action, reason := pairsDecide(cfg, c.pos, c.z, c.bars, c.armed)
+		if action != c.action || reason != c.reason {
+			t.Fatalf("pos=%d z=%.1f: got %s/%s want %s/%s", c.pos, c.z, action, reason, c.action, c.reason)
+		}
+	}
+}
+
+func TestSimulatePairs(t *testing.T) {
+	a, b := synthPair(1500, 3)
+	times := make([]int64, len(a))
+	for i := range times {
+		times[i] = int64(i) * 3600_000
+	}
+	cfg := PairsBacktestConfig{PairsConfig: PairsConfig{SymbolA: "AUSDT", SymbolB: "BUSDT", Lookback: 100, NotionalPerLeg: 100}}
+	if err := normalizePairsConfig(&cfg.PairsConfig); err != nil {
+		t.Fatal(err)
+	}
+	res := simulatePairs(cfg, times, a, b)
+	if res.TotalTrades == 0 {
+		t.Fatalf("expected trades on a mean-reverting spread")
+	}
+	if res.ExitReasons["revert"] == 0 || res.TotalPnL <= 0 {
+		t.Fatalf("mean-reverting spread should be profitable: %+v", res.ExitReasons)
+	}
+	var sum float64
+	for _, tr := range res.Trades {
+		sum += tr.PnL
+	}
+	if math.Abs(sum-res.TotalPnL) > 0.01 {
+		t.Fatalf("trade pnl sum %.4f != total %.4f", sum, res.TotalPnL)
+	}
+}
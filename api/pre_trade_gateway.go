@@ -0,0 +1,323 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// PreTradeConfig 下单前风控网关配置
+type PreTradeConfig struct {
+	Disabled         bool    `json:"disabled"`         // 关闭网关（仅用于排障）
+	MaxLeverage      int     `json:"maxLeverage"`      // 最大杠杆，0=不限
+	FatFingerBandPct float64 `json:"fatFingerBandPct"` // 限价/触发价偏离标记价格的上限(%)，0=默认10，负数关闭
+	// 默认只检查会立即成交的价格（IOC/FOK 限价、穿价限价、立即触发的止损价），
+	// 开启后挂单（GTC 限价、未穿价止损）也按双向价格带检查；网格等宽区间挂单不宜开启
+	BandRestingOrders bool `json:"bandRestingOrders"`
+}
+
+// 拒单原因代码
+const (
+	PreTradeKillSwitch    = "KILL_SWITCH"
+	PreTradeDailyLoss     = "DAILY_LOSS_LOCK"
+	PreTradeDrawdown      = "DRAWDOWN_LOCK"
+	PreTradeRiskBudget    = "RISK_BUDGET"
+	PreTradeMaxLeverage   = "MAX_LEVERAGE"
+	PreTradeFatFinger     = "FAT_FINGER"
+	PreTradePortfolio     = "PORTFOLIO_LIMIT"
+	PreTradeVarBudget     = "VAR_BUDGET"
+	PreTradeMarginalVar   = "MARGINAL_VAR"
+	PreTradeAllocationCap = "ALLOCATION_CAP"
+)
+
+const defaultFatFingerBandPct = 10.0
+
+// PreTradeRejection 网关拒单，携带结构化原因代码
+type PreTradeRejection struct {
+	Code    string `json:"code"`
+	Source  string `json:"source"`
+	Symbol  string `json:"symbol"`
+	Message string `json:"message"`
+}
+
+func (r *PreTradeRejection) Error() string {
+	return fmt.Sprintf("pre-trade rejected [%s]: %s", r.Code, r.Message)
+}
+
+// preTradeOrder 网关视角的订单（已解析数值）
+type preTradeOrder struct {
+	Source       string
+	Symbol       string
+	Side         string
+	PositionSide string
+	OrderType    string
+	TimeInForce  string
+	IsLong       bool
+	Margin       float64
+	Notional     float64
+	Leverage     int
+	Price        float64
+	StopPrice    float64
+}
+
+type preTradeRule struct {
+	code  string
+	check func(o *preTradeOrder, cfg PreTradeConfig) error
+}
+
+// preTradeRules 按顺序执行，命中第一条即拒单
+var preTradeRules = []preTradeRule{
+	{PreTradeKillSwitch, func(o *preTradeOrder, _ PreTradeConfig) error { return CheckKillSwitch(o.Source, o.Symbol) }},
+	{PreTradeDailyLoss, func(*preTradeOrder, PreTradeConfig) error { return checkDailyLossLock() }},
+	{PreTradeDrawdown, func(*preTradeOrder, PreTradeConfig) error { return checkDrawdownLocks() }},
+	{PreTradeRiskBudget, func(o *preTradeOrder, _ PreTradeConfig) error {
+		return CheckRiskBudget(o.Source, o.Symbol, o.Margin)
+	}},
+	{PreTradeMaxLeverage, checkPreTradeLeverage},
+	{PreTradeFatFinger, checkPreTradeFatFinger},
+	{PreTradePortfolio, func(o *preTradeOrder, _ PreTradeConfig) error {
+		return CheckPortfolioRisk(o.Symbol, o.Notional, o.IsLong)
+	}},
+	{PreTradeVarBudget, func(*preTradeOrder, PreTradeConfig) error { return checkPreTradeVarBudget() }},
+	{PreTradeMarginalVar, func(o *preTradeOrder, _ PreTradeConfig) error { return checkPreTradeMarginalVar(o) }},
+	{PreTradeAllocationCap, func(o *preTradeOrder, _ PreTradeConfig) error { return checkPreTradeAllocation(o) }},
+}
+
+var (
+	preTradeMu  sync.RWMutex
+	preTradeCfg PreTradeConfig
+
+	// 以下为测试注入点
+	preTradeMarkPrice     = cachedMarkPrice
+	preTradeOpenMarginFor = openMarginBySource
+)
+
+// InitPreTradeGateway 初始化下单前风控网关
+func InitPreTradeGateway(cfg PreTradeConfig) {
+	preTradeMu.Lock()
+	preTradeCfg = cfg
+	preTradeMu.Unlock()
+	log.Printf("[PreTrade] Initialized: disabled=%v, maxLeverage=%d, fatFingerBand=%.2f%%, bandResting=%v",
+		cfg.Disabled, cfg.MaxLeverage, cfg.FatFingerBandPct, cfg.BandRestingOrders)
+}
+
+// CheckPreTrade 统一下单前风控：Kill-Switch → 日内亏损 → 回撤 → 分层预算 → 杠杆 → 价格带 → 组合名义 → VaR 预算 → 边际 VaR → 策略资金上限。
+// 减仓/平仓单不受限制，避免风控锁定时无法降低风险。返回 *PreTradeRejection。
+func CheckPreTrade(req PlaceOrderReq) error {
+	preTradeMu.RLock()
+	cfg := preTradeCfg
+	preTradeMu.RUnlock()
+
+	if cfg.Disabled || isReducingOrder(req) {
+		return nil
+	}
+	o := buildPreTradeOrder(req)
+	for _, rule := range preTradeRules {
+		if err := rule.check(o, cfg); err != nil {
+			return &PreTradeRejection{Code: rule.code, Source: o.Source, Symbol: o.Symbol, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// isReducingOrder 只减仓或对冲模式下的反向平仓单
+func isReducingOrder(req PlaceOrderReq) bool {
+	if req.ReduceOnly {
+		return true
+	}
+	switch req.PositionSide {
+	case futures.PositionSideTypeLong:
+		return req.Side == futures.SideTypeSell
+	case futures.PositionSideTypeShort:
+		return req.Side == futures.SideTypeBuy
+	}
+	return false
+}
+
+func buildPreTradeOrder(req PlaceOrderReq) *preTradeOrder {
+	margin, _ := strconv.ParseFloat(req.QuoteQuantity, 64)
+	price, _ := strconv.ParseFloat(req.Price, 64)
+	stopPrice, _ := strconv.ParseFloat(req.StopPrice, 64)
+	isLong := req.Side == futures.SideTypeBuy
+	if req.PositionSide == futures.PositionSideTypeLong {
+		isLong = true
+	} else if req.PositionSide == futures.PositionSideTypeShort {
+		isLong = false
+	}
+	return &preTradeOrder{
+		Source:       req.Source,
+		Symbol:       strings.ToUpper(req.Symbol),
+		Side:         string(req.Side),
+		PositionSide: string(req.PositionSide),
+		OrderType:    string(req.OrderType),
+		TimeInForce:  string(req.TimeInForce),
+		IsLong:       isLong,
+		Margin:       margin,
+		Notional:     margin * float64(req.Leverage),
+		Leverage:     req.Leverage,
+		Price:        price,
+		StopPrice:    stopPrice,
+	}
+}
+
+func checkPreTradeLeverage(o *preTradeOrder, cfg PreTradeConfig) error {
+	if cfg.MaxLeverage > 0 && o.Leverage > cfg.MaxLeverage {
+		return fmt.Errorf("杠杆 %dx 超过上限 %dx", o.Leverage, cfg.MaxLeverage)
+	}
+	return nil
+}
+
+// checkPreTradeFatFinger 限价/触发价偏离标记价格过大视为误操作；拿不到标记价格时放行。
+// 远离市价挂着的限价单不会成交，默认不受价格带限制，只拦截会按偏离价格立即成交的订单。
+func checkPreTradeFatFinger(o *preTradeOrder, cfg PreTradeConfig) error {
+	band := cfg.FatFingerBandPct
+	if band < 0 || (o.Price <= 0 && o.StopPrice <= 0) {
+		return nil
+	}
+	if band == 0 {
+		band = defaultFatFingerBandPct
+	}
+	mark := preTradeMarkPrice(o.Symbol)
+	if mark <= 0 {
+		return nil
+	}
+	buy := o.Side == string(futures.SideTypeBuy)
+	immediate := o.TimeInForce == string(futures.TimeInForceTypeIOC) || o.TimeInForce == string(futures.TimeInForceTypeFOK)
+	for _, p := range []struct {
+		name  string
+		value float64
+		// 价格是否处在会立即成交/触发的一侧：买单限价高于市价、卖单限价低于市价；
+		// 止损买单触发价低于市价、止损卖单触发价高于市价
+		through bool
+	}{
+		{"price", o.Price, (buy && o.Price > mark) || (!buy && o.Price < mark)},
+		{"stopPrice", o.StopPrice, (buy && o.StopPrice < mark) || (!buy && o.StopPrice > mark)},
+	} {
+		if p.value <= 0 || !(p.through || immediate || cfg.BandRestingOrders) {
+			continue
+		}
+		dev := math.Abs(p.value-mark) / mark * 100
+		if dev > band {
+			return fmt.Errorf("%s %.8g 偏离标记价格 %.8g 达 %.2f%%，超过价格带 %.2f%%", p.name, p.value, mark, dev, band)
+		}
+	}
+	return nil
+}
+
+// checkPreTradeVarBudget 最新 VaR 快照已超预算且处置为 lock 时拒绝新开仓
+func checkPreTradeVarBudget() error {
+	varMu.RLock()
+	enabled, action := varCfg.Enabled, varCfg.BreachAction
+	varMu.RUnlock()
+	if !enabled || action != "lock" {
+		return nil
+	}
+	snap := GetVarStatus()
+	if snap.Breached {
+		return fmt.Errorf("VaR95 %.2f 已用预算 %.1f%%", snap.TotalVar95, snap.RiskBudgetUsedPct)
+	}
+	return nil
+}
+
+// checkPreTradeMarginalVar 新单带来的 VaR 增量超过剩余预算时拒单。
+// 基于缓存的持仓/协方差快照做参数法增量计算，不在下单路径上拉取账户与 K 线；
+// 快照不可用且毫秒级超时内无法重建时放行，由 VaR 快照规则兜底。
+func checkPreTradeMarginalVar(o *preTradeOrder) error {
+	varMu.RLock()
+	enabled, budget := varCfg.Enabled, varCfg.RiskBudgetUSDT
+	varMu.RUnlock()
+	if !enabled || budget <= 0 || o.Notional <= 0 {
+		return nil
+	}
+	delta := o.Notional
+	if !o.IsLong {
+		delta = -delta
+	}
+	marginal, before, err := preTradeMarginalVar(o.Symbol, delta, budget)
+	if err != nil {
+		log.Printf("[PreTrade] marginal VaR skipped for %s: %v", o.Symbol, err)
+		return nil
+	}
+	if marginal > 0 && marginal > budget-before {
+		return fmt.Errorf("边际 VaR %.2f 超过剩余预算 %.2f（当前 VaR %.2f / 预算 %.2f）",
+			marginal, budget-before, before, budget)
+	}
+	return nil
+}
+
+// checkPreTradeAllocation 策略来源的订单不得超过分配器给该策略的资金
+func checkPreTradeAllocation(o *preTradeOrder) error {
+	allowed, ok := strategyAllocationCap(o.Source)
+	if !ok {
+		return nil
+	}
+	used := preTradeOpenMarginFor(o.Source)
+	if used+o.Margin > allowed {
+		return fmt.Errorf("策略 %s 已用保证金 %.2f + 新单 %.2f > 分配额 %.2f", o.Source, used, o.Margin, allowed)
+	}
+	return nil
+}
+
+// strategyAllocationCap 分配器运行时返回 strategy_xxx 对应的分配资金
+func strategyAllocationCap(source string) (float64, bool) {
+	strategyType, ok := strings.CutPrefix(source, "strategy_")
+	if !ok {
+		return 0, false
+	}
+	allocMu.RLock()
+	active := allocActive
+	allocMu.RUnlock()
+	if !active {
+		return 0, false
+	}
+	for _, a := range GetAllocationStatus() {
+		if a.StrategyType == strategyType {
+			return a.AllocatedUSDT, true
+		}
+	}
+	return 0, false
+}
+
+// openMarginBySource 统计某来源未平仓交易记录占用的保证金
+func openMarginBySource(source string) float64 {
+	if DB == nil {
+		return 0
+	}
+	var total float64
+	DB.Model(&TradeRecord{}).
+		Where("source = ? AND status = ?", source, "OPEN").
+		Select("COALESCE(SUM(quote_quantity), 0)").
+		Scan(&total)
+	return total
+}
+
+// cachedMarkPrice 只读价格缓存，不触发订阅等待，避免阻塞下单路径
+func cachedMarkPrice(symbol string) float64 {
+	pc := GetPriceCache()
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if data, ok := pc.prices[symbol]; ok && time.Since(data.LastUpdate) < 30*time.Second {
+		return data.MarkPrice
+	}
+	return 0
+}
+
+// rejectPreTrade 记录拒单并持久化到失败操作表
+func rejectPreTrade(req PlaceOrderReq, rej *PreTradeRejection) {
+	trigger := "pre_trade_" + strings.ToLower(rej.Code)
+	if rej.Code == PreTradeKillSwitch {
+		trigger = "kill_switch"
+	}
+	RecordRiskTrigger(trigger)
+	SaveFailedOperation("PRE_TRADE_REJECT", req.Source, req.Symbol, map[string]interface{}{
+		"order":     req,
+		"rejection": rej,
+	}, 0, rej)
+	log.Printf("[PreTrade] %s %s rejected: %s", req.Source, req.Symbol, rej.Error())
+}
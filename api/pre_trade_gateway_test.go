@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func withPreTradeConfig(t *testing.T, cfg PreTradeConfig) {
+	t.Helper()
+	preTradeMu.Lock()
+	orig := preTradeCfg
+	preTradeCfg = cfg
+	preTradeMu.Unlock()
+	t.Cleanup(func() {
+		preTradeMu.Lock()
+		preTradeCfg = orig
+		preTradeMu.Unlock()
+	})
+}
+
+func rejectionCode(err error) string {
+	var rej *PreTradeRejection
+	if errors.As(err, &rej) {
+		return rej.Code
+	}
+	return ""
+}
+
+func TestCheckPreTradeLeverageAndFatFinger(t *testing.T) {
+	withPreTradeConfig(t, PreTradeConfig{MaxLeverage: 20, FatFingerBandPct: 5})
+	origMark := preTradeMarkPrice
+	preTradeMarkPrice = func(string) float64 { return 100 }
+	defer func() { preTradeMarkPrice = origMark }()
+
+	base := PlaceOrderReq{Source: "manual", Symbol: "btcusdt", Side: futures.SideTypeBuy, QuoteQuantity: "10", Leverage: 10}
+	if err := CheckPreTrade(base); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+
+	req := base
+	req.Leverage = 50
+	if code := rejectionCode(CheckPreTrade(req)); code != PreTradeMaxLeverage {
+		t.Fatalf("code=%q want %s", code, PreTradeMaxLeverage)
+	}
+
+	req = base
+	req.Price = "104"
+	if err := CheckPreTrade(req); err != nil {
+		t.Fatalf("price inside band rejected: %v", err)
+	}
+	req.StopPrice = "80"
+	if code := rejectionCode(CheckPreTrade(req)); code != PreTradeFatFinger {
+		t.Fatalf("code=%q want %s", code, PreTradeFatFinger)
+	}
+
+	// 平仓单不受网关限制
+	req.PositionSide = futures.PositionSideTypeShort
+	if err := CheckPreTrade(req); err != nil {
+		t.Fatalf("reducing order rejected: %v", err)
+	}
+}
+
+func TestCheckPreTradeFatFingerRestingOrders(t *testing.T) {
+	withPreTradeConfig(t, PreTradeConfig{FatFingerBandPct: 5})
+	origMark := preTradeMarkPrice
+	preTradeMarkPrice = func(string) float64 { return 100 }
+	defer func() { preTradeMarkPrice = origMark }()
+
+	// 网格外层挂单远离市价但不会立即成交：默认放行
+	resting := PlaceOrderReq{Source: "strategy_grid", Symbol: "BTCUSDT", Side: futures.SideTypeBuy,
+		OrderType: futures.OrderTypeLimit, TimeInForce: futures.TimeInForceTypeGTC, Price: "80", QuoteQuantity: "10", Leverage: 5}
+	if err := CheckPreTrade(resting); err != nil {
+		t.Fatalf("resting limit rejected: %v", err)
+	}
+	sell := resting
+	sell.Side, sell.Price = futures.SideTypeSell, "125"
+	if err := CheckPreTrade(sell); err != nil {
+		t.Fatalf("resting sell rejected: %v", err)
+	}
+
+	// 穿价限价单、IOC 按偏离价格成交：拦截
+	through := resting
+	through.Price = "120"
+	if code := rejectionCode(CheckPreTrade(through)); code != PreTradeFatFinger {
+		t.Fatalf("through-market limit: code=%q", code)
+	}
+	ioc := resting
+	ioc.TimeInForce = futures.TimeInForceTypeIOC
+	if code := rejectionCode(CheckPreTrade(ioc)); code != PreTradeFatFinger {
+		t.Fatalf("ioc: code=%q", code)
+	}
+
+	withPreTradeConfig(t, PreTradeConfig{FatFingerBandPct: 5, BandRestingOrders: true})
+	if code := rejectionCode(CheckPreTrade(resting)); code != PreTradeFatFinger {
+		t.Fatalf("opt-in resting band: code=%q", code)
+	}
+}
+
+func TestCheckPreTradeKillSwitchUsesSource(t *testing.T) {
+	withPreTradeConfig(t, PreTradeConfig{FatFingerBandPct: -1})
+	ks.mu.Lock()
+	origStrategies := ks.strategies
+	ks.strategies = map[string]bool{"strategy_grid": true}
+	ks.mu.Unlock()
+	defer func() {
+		ks.mu.Lock()
+		ks.strategies = origStrategies
+		ks.mu.Unlock()
+	}()
+
+	req := PlaceOrderReq{Source: "strategy_grid", Symbol: "ETHUSDT", Side: futures.SideTypeSell, QuoteQuantity: "5", Leverage: 5}
+	if code := rejectionCode(CheckPreTrade(req)); code != PreTradeKillSwitch {
+		t.Fatalf("code=%q want %s", code, PreTradeKillSwitch)
+	}
+	req.Source = "manual"
+	if err := CheckPreTrade(req); err != nil {
+		t.Fatalf("other source rejected: %v", err)
+	}
+}
+
+func TestCheckPreTradeAllocationCap(t *testing.T) {
+	withPreTradeConfig(t, PreTradeConfig{FatFingerBandPct: -1})
+	allocMu.Lock()
+	origActive, origLatest := allocActive, allocLatest
+	allocActive = true
+	allocLatest = []StrategyAllocation{{StrategyType: "dca", AllocatedUSDT: 50}}
+	allocMu.Unlock()
+	origUsed := preTradeOpenMarginFor
+	preTradeOpenMarginFor = func(string) float64 { return 45 }
+	defer func() {
+		allocMu.Lock()
+		allocActive, allocLatest = origActive, origLatest
+		allocMu.Unlock()
+		preTradeOpenMarginFor = origUsed
+	}()
+
+	req := PlaceOrderReq{Source: "strategy_dca", Symbol: "SOLUSDT", Side: futures.SideTypeBuy, QuoteQuantity: "10", Leverage: 3}
+	if code := rejectionCode(CheckPreTrade(req)); code != PreTradeAllocationCap {
+		t.Fatalf("code=%q want %s", code, PreTradeAllocationCap)
+	}
+	req.QuoteQuantity = "5"
+	if err := CheckPreTrade(req); err != nil {
+		t.Fatalf("order within cap rejected: %v", err)
+	}
+	// 未分配的策略不受限
+	req.Source, req.QuoteQuantity = "strategy_scalp", "100"
+	if err := CheckPreTrade(req); err != nil {
+		t.Fatalf("unallocated strategy rejected: %v", err)
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 风险预算层级
+const (
+	RiskLevelAccount  = "account"
+	RiskLevelStrategy = "strategy"
+	RiskLevelSymbol   = "symbol"
+)
+
+// RiskBudgetLimits 单个层级的风险预算，所有字段 0=不限
+type RiskBudgetLimits struct {
+	DailyLossLimit       float64 `json:"dailyLossLimit"`       // 日亏损上限(USDT)
+	WeeklyLossLimit      float64 `json:"weeklyLossLimit"`      // 周亏损上限(USDT)
+	MaxConsecutiveLosses int     `json:"maxConsecutiveLosses"` // 最大连续亏损次数
+	MaxOpenRisk          float64 `json:"maxOpenRisk"`          // 未平仓保证金上限(USDT)
+	CooldownMin          int     `json:"cooldownMin"`          // 连亏锁定的冷却分钟，0=锁到次日
+}
+
+// RiskBudgetConfig 分层风险预算：账户 → 策略(来源) → 币种，币种节点挂在所属策略下（来源:币种）
+type RiskBudgetConfig struct {
+	Account    RiskBudgetLimits            `json:"account"`
+	Strategy   RiskBudgetLimits            `json:"strategy"`   // 各来源默认预算
+	Symbol     RiskBudgetLimits            `json:"symbol"`     // 各币种默认预算
+	Strategies map[string]RiskBudgetLimits `json:"strategies"` // 按来源覆盖，如 strategy_scalp
+	Symbols    map[string]RiskBudgetLimits `json:"symbols"`    // 按币种覆盖，如 BTCUSDT；也可写 strategy_scalp:BTCUSDT 只覆盖该策略
+}
+
+func (c RiskBudgetConfig) limitsFor(level, name string) RiskBudgetLimits {
+	switch level {
+	case RiskLevelStrategy:
+		if l, ok := c.Strategies[name]; ok {
+			return l
+		}
+		return c.Strategy
+	case RiskLevelSymbol:
+		if l, ok := c.Symbols[name]; ok {
+			return l
+		}
+		if _, symbol := splitRiskBudgetSymbol(name); symbol != name {
+			if l, ok := c.Symbols[symbol]; ok {
+				return l
+			}
+		}
+		return c.Symbol
+	}
+	return c.Account
+}
+
+// riskBudgetBucket 单个层级节点的运行时状态
+type riskBudgetBucket struct {
+	level, name       string
+	day, week         string
+	dailyPnl          float64
+	dailyLoss         float64 // 当日亏损（正数）
+	weeklyPnl         float64
+	weeklyLoss        float64 // 本周亏损（正数）
+	consecutiveLosses int
+	locked            bool
+	lockReason        string
+	lockedAt          time.Time
+	lockedUntil       time.Time
+}
+
+// RiskBudgetStatus 风险预算节点状态
+type RiskBudgetStatus struct {
+	Level             string           `json:"level"`
+	Name              string           `json:"name"`
+	Strategy          string           `json:"strategy,omitempty"` // 币种节点所属策略
+	Symbol            string           `json:"symbol,omitempty"`   // 币种节点的交易对
+	Limits            RiskBudgetLimits `json:"limits"`
+	DailyPnl          float64          `json:"dailyPnl"`
+	DailyLoss         float64          `json:"dailyLoss"`
+	WeeklyPnl         float64          `json:"weeklyPnl"`
+	WeeklyLoss        float64          `json:"weeklyLoss"`
+	ConsecutiveLosses int              `json:"consecutiveLosses"`
+	OpenRisk          float64          `json:"openRisk"`
+	Locked            bool             `json:"locked"`
+	LockReason        string           `json:"lockReason,omitempty"`
+	LockedAt          *time.Time       `json:"lockedAt,omitempty"`
+	LockedUntil       *time.Time       `json:"lockedUntil,omitempty"`
+}
+
+// riskBudgetOpenMargin 统计层级节点未平仓保证金（测试注入点）
+var riskBudgetOpenMargin = openMarginByLevel
+
+func riskBudgetKey(level, name string) string {
+	return level + ":" + name
+}
+
+// riskBudgetSymbolName 币种节点名：有来源时为 来源:币种，同一币种在各策略下独立计算
+func riskBudgetSymbolName(source, symbol string) string {
+	if source == "" {
+		return symbol
+	}
+	return source + ":" + symbol
+}
+
+// splitRiskBudgetSymbol 拆分币种节点名为来源与币种
+func splitRiskBudgetSymbol(name string) (source, symbol string) {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// normalizeRiskBudgetName 规范化节点名：来源小写，币种大写
+func normalizeRiskBudgetName(level, name string) string {
+	name = strings.TrimSpace(name)
+	if level != RiskLevelSymbol {
+		return strings.ToLower(name)
+	}
+	source, symbol := splitRiskBudgetSymbol(name)
+	return riskBudgetSymbolName(strings.ToLower(source), strings.ToUpper(symbol))
+}
+
+// riskBudgetNodes 一笔交易归属的层级节点：账户 → 策略 → 策略下的币种
+func riskBudgetNodes(source, symbol string) [][2]string {
+	nodes := [][2]string{{RiskLevelAccount, ""}}
+	if source = strings.ToLower(strings.TrimSpace(source)); source != "" {
+		nodes = append(nodes, [2]string{RiskLevelStrategy, source})
+	}
+	if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+		nodes = append(nodes, [2]string{RiskLevelSymbol, riskBudgetSymbolName(source, symbol)})
+	}
+	return nodes
+}
+
+// getRiskBudgetBucketLocked 获取或创建节点；调用方需持有 risk.mu 锁。
+func getRiskBudgetBucketLocked(level, name string) *riskBudgetBucket {
+	if risk.budgets == nil {
+		risk.budgets = make(map[string]*riskBudgetBucket)
+	}
+	key := riskBudgetKey(level, name)
+	b, ok := risk.budgets[key]
+	if !ok {
+		b = &riskBudgetBucket{level: level, name: name}
+		risk.budgets[key] = b
+	}
+	return b
+}
+
+// roll 跨日/跨周重置统计，并释放到期的锁
+func (b *riskBudgetBucket) roll(now time.Time) {
+	if day := tradingDay(now); day != b.day {
+		b.day = day
+		b.dailyPnl = 0
+		b.dailyLoss = 0
+	}
+	if week := tradingDay(tradingWeekStart(now)); week != b.week {
+		b.week = week
+		b.weeklyPnl = 0
+		b.weeklyLoss = 0
+	}
+	if b.locked && !b.lockedUntil.IsZero() && !now.Before(b.lockedUntil) {
+		b.unlock()
+	}
+}
+
+func (b *riskBudgetBucket) unlock() {
+	b.locked = false
+	b.lockReason = ""
+	b.lockedAt = time.Time{}
+	b.lockedUntil = time.Time{}
+}
+
+func (b *riskBudgetBucket) lock(reason string, at, until time.Time) {
+	if b.locked {
+		if until.After(b.lockedUntil) {
+			b.lockedUntil = until
+		}
+		return
+	}
+	b.locked = true
+	b.lockReason = reason
+	b.lockedAt = at
+	b.lockedUntil = until
+	label := b.level
+	if b.name != "" {
+		label += " " + b.name
+	}
+	log.Printf("[Risk] Budget LOCKED %s: %s (until %s)", label, reason, until.Format(time.RFC3339))
+	SendNotify(fmt.Sprintf("*风险预算锁定*\n层级: %s\n%s\n解锁时间: %s", label, reason, until.Format("01-02 15:04")))
+}
+
+// apply 记录一笔已实现盈亏并检查预算
+func (b *riskBudgetBucket) apply(limits RiskBudgetLimits, pnl float64, at time.Time) {
+	b.roll(at)
+	b.dailyPnl += pnl
+	b.weeklyPnl += pnl
+	if pnl >= 0 {
+		b.consecutiveLosses = 0
+		return
+	}
+	b.dailyLoss += -pnl
+	b.weeklyLoss += -pnl
+	b.consecutiveLosses++
+
+	nextDay := tradingDayStart(at).AddDate(0, 0, 1)
+	if limits.WeeklyLossLimit > 0 && b.weeklyLoss >= limits.WeeklyLossLimit {
+		b.lock(fmt.Sprintf("本周亏损 %.2f USDT 已达限额 %.2f USDT", b.weeklyLoss, limits.WeeklyLossLimit),
+			at, tradingWeekStart(at).AddDate(0, 0, 7))
+	}
+	if limits.DailyLossLimit > 0 && b.dailyLoss >= limits.DailyLossLimit {
+		b.lock(fmt.Sprintf("今日亏损 %.2f USDT 已达限额 %.2f USDT", b.dailyLoss, limits.DailyLossLimit), at, nextDay)
+	}
+	if limits.MaxConsecutiveLosses > 0 && b.consecutiveLosses >= limits.MaxConsecutiveLosses {
+		until := nextDay
+		if limits.CooldownMin > 0 {
+			until = at.Add(time.Duration(limits.CooldownMin) * time.Minute)
+		}
+		b.lock(fmt.Sprintf("连续亏损 %d 次 (限额 %d 次)", b.consecutiveLosses, limits.MaxConsecutiveLosses), at, until)
+		// 冷却结束后重新计数
+		b.consecutiveLosses = 0
+	}
+}
+
+// recordRiskBudgetPnlLocked 将已实现盈亏计入账户/策略/币种三级预算；调用方需持有 risk.mu 锁。
+func recordRiskBudgetPnlLocked(source, symbol string, pnl float64, at time.Time) {
+	for _, n := range riskBudgetNodes(source, symbol) {
+		limits := risk.config.Budgets.limitsFor(n[0], n[1])
+		getRiskBudgetBucketLocked(n[0], n[1]).apply(limits, pnl, at)
+	}
+}
+
+// CheckRiskBudget 下单前检查各层级预算锁与未平仓保证金上限
+func CheckRiskBudget(source, symbol string, margin float64) error {
+	type openCheck struct {
+		level, name string
+		limit       float64
+	}
+	var checks []openCheck
+
+	risk.mu.Lock()
+	if !risk.config.Enabled {
+		risk.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	for _, n := range riskBudgetNodes(source, symbol) {
+		if b, ok := risk.budgets[riskBudgetKey(n[0], n[1])]; ok {
+			b.roll(now)
+			if b.locked {
+				risk.mu.Unlock()
+				return fmt.Errorf("风险预算锁定 [%s %s]: %s，至 %s", n[0], n[1], b.lockReason, b.lockedUntil.Format("01-02 15:04"))
+			}
+		}
+		if limit := risk.config.Budgets.limitsFor(n[0], n[1]).MaxOpenRisk; limit > 0 {
+			checks = append(checks, openCheck{n[0], n[1], limit})
+		}
+	}
+	risk.mu.Unlock()
+
+	for _, c := range checks {
+		used := riskBudgetOpenMargin(c.level, c.name)
+		if used+margin > c.limit {
+			return fmt.Errorf("风险预算 [%s %s]: 未平仓保证金 %.2f + 新单 %.2f > 上限 %.2f", c.level, c.name, used, margin, c.limit)
+		}
+	}
+	return nil
+}
+
+// openMarginByLevel 统计层级节点未平仓交易记录占用的保证金
+func openMarginByLevel(level, name string) float64 {
+	if DB == nil {
+		return 0
+	}
+	q := DB.Model(&TradeRecord{}).Where("status = ?", "OPEN")
+	switch level {
+	case RiskLevelStrategy:
+		q = q.Where("LOWER(source) = ?", name)
+	case RiskLevelSymbol:
+		source, symbol := splitRiskBudgetSymbol(name)
+		q = q.Where("symbol = ?", symbol)
+		if source != "" {
+			q = q.Where("LOWER(source) = ?", source)
+		}
+	}
+	var total float64
+	q.Select("COALESCE(SUM(quote_quantity), 0)").Scan(&total)
+	return total
+}
+
+// UnlockRiskBudget 手动解锁指定层级节点
+func UnlockRiskBudget(level, name string) bool {
+	risk.mu.Lock()
+	defer risk.mu.Unlock()
+	name = normalizeRiskBudgetName(level, name)
+	b, ok := risk.budgets[riskBudgetKey(level, name)]
+	if !ok || !b.locked {
+		return false
+	}
+	b.unlock()
+	log.Printf("[Risk] Budget %s %s manually unlocked", level, name)
+	return true
+}
+
+// riskBudgetStatusLocked 各层级状态快照；调用方需持有 risk.mu 锁。
+func riskBudgetStatusLocked(now time.Time) []RiskBudgetStatus {
+	getRiskBudgetBucketLocked(RiskLevelAccount, "")
+	for name := range risk.config.Budgets.Strategies {
+		getRiskBudgetBucketLocked(RiskLevelStrategy, strings.ToLower(name))
+	}
+	for name := range risk.config.Budgets.Symbols {
+		// 只按币种配置的覆盖在交易发生后才会出现在各策略下
+		if source, _ := splitRiskBudgetSymbol(name); source != "" {
+			getRiskBudgetBucketLocked(RiskLevelSymbol, normalizeRiskBudgetName(RiskLevelSymbol, name))
+		}
+	}
+
+	out := make([]RiskBudgetStatus, 0, len(risk.budgets))
+	for _, b := range risk.budgets {
+		b.roll(now)
+		st := RiskBudgetStatus{
+			Level:             b.level,
+			Name:              b.name,
+			Limits:            risk.config.Budgets.limitsFor(b.level, b.name),
+			DailyPnl:          roundFloat(b.dailyPnl, 4),
+			DailyLoss:         roundFloat(b.dailyLoss, 4),
+			WeeklyPnl:         roundFloat(b.weeklyPnl, 4),
+			WeeklyLoss:        roundFloat(b.weeklyLoss, 4),
+			ConsecutiveLosses: b.consecutiveLosses,
+			Locked:            b.locked,
+			LockReason:        b.lockReason,
+		}
+		if b.level == RiskLevelSymbol {
+			st.Strategy, st.Symbol = splitRiskBudgetSymbol(b.name)
+		}
+		if b.locked {
+			at, until := b.lockedAt, b.lockedUntil
+			st.LockedAt, st.LockedUntil = &at, &until
+		}
+		out = append(out, st)
+	}
+	levelOrder := map[string]int{RiskLevelAccount: 0, RiskLevelStrategy: 1, RiskLevelSymbol: 2}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Level != out[j].Level {
+			return levelOrder[out[i].Level] < levelOrder[out[j].Level]
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// groupRiskBudgetStatus 填充未平仓保证金并按层级分组
+func groupRiskBudgetStatus(items []RiskBudgetStatus) map[string]interface{} {
+	grouped := map[string]interface{}{}
+	strategies := make([]RiskBudgetStatus, 0)
+	symbols := make([]RiskBudgetStatus, 0)
+	for _, st := range items {
+		st.OpenRisk = roundFloat(riskBudgetOpenMargin(st.Level, st.Name), 4)
+		switch st.Level {
+		case RiskLevelAccount:
+			grouped["account"] = st
+		case RiskLevelStrategy:
+			strategies = append(strategies, st)
+		case RiskLevelSymbol:
+			symbols = append(symbols, st)
+		}
+	}
+	grouped["strategies"] = strategies
+	grouped["symbols"] = symbols
+	return grouped
+}
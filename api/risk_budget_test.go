@@ -0,0 +1,157 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func withRiskConfig(t *testing.T, cfg RiskConfig) {
+	t.Helper()
+	risk.mu.Lock()
+	origCfg, origBudgets := risk.config, risk.budgets
+	risk.config = cfg
+	risk.budgets = make(map[string]*riskBudgetBucket)
+	risk.mu.Unlock()
+	t.Cleanup(func() {
+		risk.mu.Lock()
+		risk.config, risk.budgets = origCfg, origBudgets
+		risk.mu.Unlock()
+	})
+}
+
+func TestRiskBudgetStrategyLockIsIndependent(t *testing.T) {
+	withRiskConfig(t, RiskConfig{Enabled: true, Budgets: RiskBudgetConfig{
+		Strategy: RiskBudgetLimits{DailyLossLimit: 10},
+	}})
+	risk.mu.Lock()
+	recordRiskBudgetPnlLocked("strategy_scalp", "BTCUSDT", -6, time.Now())
+	recordRiskBudgetPnlLocked("strategy_scalp", "ETHUSDT", -5, time.Now())
+	risk.mu.Unlock()
+
+	if err := CheckRiskBudget("strategy_scalp", "SOLUSDT", 1); err == nil || !strings.Contains(err.Error(), "strategy_scalp") {
+		t.Fatalf("expected scalp lock, got %v", err)
+	}
+	if err := CheckRiskBudget("strategy_grid", "BTCUSDT", 1); err != nil {
+		t.Fatalf("grid should not be locked: %v", err)
+	}
+	if !UnlockRiskBudget(RiskLevelStrategy, "STRATEGY_SCALP") {
+		t.Fatal("expected manual unlock")
+	}
+	if err := CheckRiskBudget("strategy_scalp", "SOLUSDT", 1); err != nil {
+		t.Fatalf("still locked after unlock: %v", err)
+	}
+}
+
+func TestRiskBudgetSymbolNestedUnderStrategy(t *testing.T) {
+	withRiskConfig(t, RiskConfig{Enabled: true, Budgets: RiskBudgetConfig{
+		Symbols: map[string]RiskBudgetLimits{
+			"BTCUSDT":               {DailyLossLimit: 10},
+			"strategy_grid:ETHUSDT": {DailyLossLimit: 100},
+		},
+	}})
+	if l := risk.config.Budgets.limitsFor(RiskLevelSymbol, "strategy_grid:BTCUSDT"); l.DailyLossLimit != 10 {
+		t.Fatalf("symbol override not inherited: %+v", l)
+	}
+	if l := risk.config.Budgets.limitsFor(RiskLevelSymbol, "strategy_grid:ETHUSDT"); l.DailyLossLimit != 100 {
+		t.Fatalf("strategy symbol override ignored: %+v", l)
+	}
+
+	risk.mu.Lock()
+	recordRiskBudgetPnlLocked("strategy_scalp", "btcusdt", -12, time.Now())
+	risk.mu.Unlock()
+
+	// scalp 在 BTC 上的亏损只锁 scalp 的 BTC 节点
+	if err := CheckRiskBudget("strategy_scalp", "BTCUSDT", 1); err == nil || !strings.Contains(err.Error(), "strategy_scalp:BTCUSDT") {
+		t.Fatalf("expected scalp BTC lock, got %v", err)
+	}
+	if err := CheckRiskBudget("strategy_grid", "BTCUSDT", 1); err != nil {
+		t.Fatalf("grid BTC should not be locked: %v", err)
+	}
+	if err := CheckRiskBudget("strategy_scalp", "ETHUSDT", 1); err != nil {
+		t.Fatalf("scalp ETH should not be locked: %v", err)
+	}
+	if !UnlockRiskBudget(RiskLevelSymbol, "STRATEGY_SCALP:btcusdt") {
+		t.Fatal("expected manual unlock")
+	}
+	if err := CheckRiskBudget("strategy_scalp", "BTCUSDT", 1); err != nil {
+		t.Fatalf("still locked after unlock: %v", err)
+	}
+}
+
+func TestRiskBudgetConsecutiveLossCooldown(t *testing.T) {
+	limits := RiskBudgetLimits{MaxConsecutiveLosses: 3, CooldownMin: 30}
+	b := &riskBudgetBucket{level: RiskLevelSymbol, name: "BTCUSDT"}
+	at := time.Date(2026, 10, 14, 10, 0, 0, 0, time.Local)
+
+	b.apply(limits, -1, at)
+	b.apply(limits, -1, at)
+	b.apply(limits, 2, at) // 盈利打断连亏
+	b.apply(limits, -1, at)
+	b.apply(limits, -1, at)
+	if b.locked {
+		t.Fatal("locked too early")
+	}
+	b.apply(limits, -1, at)
+	if !b.locked || !b.lockedUntil.Equal(at.Add(30*time.Minute)) {
+		t.Fatalf("locked=%v until=%v", b.locked, b.lockedUntil)
+	}
+	b.roll(at.Add(29 * time.Minute))
+	if !b.locked {
+		t.Fatal("released before cooldown ended")
+	}
+	b.roll(at.Add(30 * time.Minute))
+	if b.locked || b.consecutiveLosses != 0 {
+		t.Fatalf("locked=%v consecutive=%d after cooldown", b.locked, b.consecutiveLosses)
+	}
+}
+
+func TestRiskBudgetWeeklyLimitAndRoll(t *testing.T) {
+	limits := RiskBudgetLimits{WeeklyLossLimit: 20}
+	b := &riskBudgetBucket{level: RiskLevelAccount}
+	mon := time.Date(2026, 10, 12, 9, 0, 0, 0, time.Local) // 周一
+	b.apply(limits, -12, mon)
+	b.apply(limits, -9, mon.AddDate(0, 0, 2))
+	if !b.locked || b.dailyLoss != 9 || b.weeklyLoss != 21 {
+		t.Fatalf("locked=%v daily=%v weekly=%v", b.locked, b.dailyLoss, b.weeklyLoss)
+	}
+	if want := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local); !b.lockedUntil.Equal(want) {
+		t.Fatalf("until=%v want %v", b.lockedUntil, want)
+	}
+	b.roll(time.Date(2026, 10, 19, 0, 1, 0, 0, time.Local))
+	if b.locked || b.weeklyLoss != 0 || b.dailyLoss != 0 {
+		t.Fatalf("not reset on new week: %+v", b)
+	}
+}
+
+func TestCheckRiskBudgetOpenRisk(t *testing.T) {
+	withRiskConfig(t, RiskConfig{Enabled: true, Budgets: RiskBudgetConfig{
+		Symbols: map[string]RiskBudgetLimits{"BTCUSDT": {MaxOpenRisk: 100}},
+	}})
+	orig := riskBudgetOpenMargin
+	riskBudgetOpenMargin = func(level, name string) float64 {
+		if level == RiskLevelSymbol && name == "manual:BTCUSDT" {
+			return 90
+		}
+		return 0
+	}
+	defer func() { riskBudgetOpenMargin = orig }()
+
+	if err := CheckRiskBudget("manual", "btcusdt", 20); err == nil {
+		t.Fatal("expected open risk rejection")
+	}
+	if err := CheckRiskBudget("manual", "btcusdt", 10); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+
+	// 币种节点在该策略有交易后出现在状态中
+	risk.mu.Lock()
+	recordRiskBudgetPnlLocked("manual", "BTCUSDT", 1, time.Now())
+	risk.mu.Unlock()
+	status := GetRiskStatus()["budgets"].(map[string]interface{})
+	symbols := status["symbols"].([]RiskBudgetStatus)
+	if len(symbols) != 1 || symbols[0].Name != "manual:BTCUSDT" || symbols[0].Strategy != "manual" ||
+		symbols[0].Symbol != "BTCUSDT" || symbols[0].OpenRisk != 90 {
+		t.Fatalf("symbols=%+v", symbols)
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,6 +13,19 @@ type RiskConfig struct {
 	DailyMaxLosses     int     `json:"dailyMaxLosses"`     // 每日最大亏损次数，0=不限制
 	MaxDailyLossAmount float64 `json:"maxDailyLossAmount"` // 每日最大亏损金额(USDT)，0=不限制
 	Enabled            bool    `json:"enabled"`            // 是否启用风控
+
+	// 交易日边界：默认本地时区 00:00，可设为 "UTC" + "00:00" 与币安资金费率周期对齐
+	ResetTimezone string `json:"resetTimezone"` // 时区，如 "UTC"、"Asia/Shanghai"
+	ResetTime     string `json:"resetTime"`     // 切换时间 "HH:MM"
+
+	Rolling7dDrawdownLimit  float64 `json:"rolling7dDrawdownLimit"`  // 近 7 天已实现盈亏回撤上限(USDT)，0=不限制
+	Rolling30dDrawdownLimit float64 `json:"rolling30dDrawdownLimit"` // 近 30 天已实现盈亏回撤上限(USDT)，0=不限制
+	MaxEquityDrawdownPct    float64 `json:"maxEquityDrawdownPct"`    // 钱包余额峰谷回撤上限(%)，0=不限制
+
+	LockAction  string            `json:"lockAction"`  // 锁定动作：warn / block / reduce_only / flatten，默认 block
+	LockActions map[string]string `json:"lockActions"` // 按锁类型覆盖：daily / rolling7d / rolling30d / equity
+
+	Budgets RiskBudgetConfig `json:"budgets"` // 账户/策略/币种分层风险预算
 }
 
 // riskState 风控运行时状态
@@ -24,28 +38,52 @@ type riskState struct {
 	locked          bool      // 是否已锁定下单
 	lockReason      string    // 锁定原因
 	lockedAt        time.Time // 锁定时间
-	lastResetDay    string    // 上次重置的日期 "2006-01-02"
+	lastResetDay    string    // 上次重置的交易日 "2006-01-02"
+	dailyWarned     bool      // 当日限额已按 warn 动作告警
+
+	budgets map[string]*riskBudgetBucket // 分层预算节点，key=level:name
+
+	pnlHistory      []riskPnlPoint       // 近 30 天已实现盈亏流水
+	drawdownResetAt time.Time            // 手动解锁后滚动回撤从此刻重新计算
+	equity          float64              // 最新钱包余额
+	equityPeak      float64              // 钱包余额峰值
+	drawdownLocks   map[string]*riskLock // 生效中的回撤锁
 }
 
 var risk = &riskState{}
 
 // InitRiskControl 初始化风控模块
 func InitRiskControl(config RiskConfig) {
+	if err := setRiskCalendar(config.ResetTimezone, config.ResetTime); err != nil {
+		log.Printf("[Risk] %v, fallback to local midnight", err)
+		_ = setRiskCalendar("", "")
+	}
+
 	risk.mu.Lock()
 	defer risk.mu.Unlock()
 	risk.config = config
+	risk.config.Budgets.Strategies = normalizeBudgetKeys(config.Budgets.Strategies, strings.ToLower)
+	risk.config.Budgets.Symbols = normalizeBudgetKeys(config.Budgets.Symbols, strings.ToUpper)
+	risk.budgets = make(map[string]*riskBudgetBucket)
+	risk.pnlHistory = nil
+	risk.drawdownLocks = make(map[string]*riskLock)
+	risk.equity, risk.equityPeak = 0, 0
 	risk.lastResetDay = today()
 	risk.dailyPnl = 0
 	risk.dailyLosses = 0
 	risk.dailyLossAmount = 0
 	risk.locked = false
 	risk.lockReason = ""
+	risk.dailyWarned = false
 
 	if config.Enabled {
-		log.Printf("[Risk] Enabled: max loss count=%d per day, max daily loss amount=%.2f USDT",
-			config.DailyMaxLosses, config.MaxDailyLossAmount)
+		log.Printf("[Risk] Enabled: max loss count=%d per day, max daily loss amount=%.2f USDT, trading day starts %s %s, action=%s",
+			config.DailyMaxLosses, config.MaxDailyLossAmount, config.ResetTime, config.ResetTimezone, normalizeRiskAction(config.LockAction))
 		// 从数据库恢复当天已有的盈亏
 		go recoverDailyPnl()
+		if config.MaxEquityDrawdownPct > 0 {
+			go seedRiskEquity()
+		}
 	} else {
 		log.Println("[Risk] Disabled")
 	}
@@ -59,6 +97,15 @@ func CheckRisk() error {
 		return err
 	}
 
+	if err := checkDailyLossLock(); err != nil {
+		RecordRiskTrigger("daily_loss")
+		return err
+	}
+	return checkDrawdownLocks()
+}
+
+// checkDailyLossLock 日内亏损锁定检查（不含 Kill-Switch，不计指标）
+func checkDailyLossLock() error {
 	risk.mu.Lock()
 	defer risk.mu.Unlock()
 
@@ -69,15 +116,14 @@ func CheckRisk() error {
 	resetRiskForNewDayLocked()
 
 	if risk.locked {
-		RecordRiskTrigger("daily_loss")
-		return fmt.Errorf("风控锁定: %s，禁止下单至明日", risk.lockReason)
+		return fmt.Errorf("风控锁定: %s，禁止下单至下一交易日", risk.lockReason)
 	}
 
 	return nil
 }
 
-// AddDailyPnl 累加当日盈亏，检查是否触发锁定
-func AddDailyPnl(pnl float64) {
+// AddDailyPnl 累加当日盈亏，检查是否触发锁定；source/symbol 用于分层风险预算归属
+func AddDailyPnl(pnl float64, source, symbol string) {
 	risk.mu.Lock()
 	defer risk.mu.Unlock()
 
@@ -90,6 +136,12 @@ func AddDailyPnl(pnl float64) {
 	}
 
 	risk.dailyPnl += pnl
+	if pnl != 0 {
+		now := time.Now()
+		recordRiskBudgetPnlLocked(source, symbol, pnl, now)
+		appendRiskPnlLocked(pnl, now)
+		evaluateDrawdownLocksLocked(now)
+	}
 
 	// 亏损次数 + 亏损金额计数
 	if pnl < 0 {
@@ -103,37 +155,37 @@ func AddDailyPnl(pnl float64) {
 
 	// 检查锁定条件: 亏损次数
 	if risk.config.DailyMaxLosses > 0 && risk.dailyLosses >= risk.config.DailyMaxLosses {
-		if !risk.locked {
-			risk.locked = true
-			risk.lockedAt = time.Now()
-			risk.lockReason = fmt.Sprintf("今日已亏损 %d 次 (限额 %d 次)", risk.dailyLosses, risk.config.DailyMaxLosses)
-			log.Printf("[Risk] LOCKED! %s", risk.lockReason)
-			NotifyRiskLocked(risk.lockReason)
-		}
+		lockDailyLocked(fmt.Sprintf("今日已亏损 %d 次 (限额 %d 次)", risk.dailyLosses, risk.config.DailyMaxLosses))
 	}
 
 	// 检查锁定条件: 亏损金额
 	if risk.config.MaxDailyLossAmount > 0 && risk.dailyLossAmount >= risk.config.MaxDailyLossAmount {
-		if !risk.locked {
-			risk.locked = true
-			risk.lockedAt = time.Now()
-			risk.lockReason = fmt.Sprintf("今日累计亏损 %.2f USDT 已达限额 %.2f USDT", risk.dailyLossAmount, risk.config.MaxDailyLossAmount)
-			log.Printf("[Risk] LOCKED! %s", risk.lockReason)
-			NotifyRiskLocked(risk.lockReason)
-		}
+		lockDailyLocked(fmt.Sprintf("今日累计亏损 %.2f USDT 已达限额 %.2f USDT", risk.dailyLossAmount, risk.config.MaxDailyLossAmount))
 	}
 }
 
-// GetRiskStatus 获取当前风控状态
+// lockDailyLocked 触发日内锁定；warn 动作每个交易日只告警一次。调用方需持有 risk.mu 锁。
+func lockDailyLocked(reason string) {
+	if risk.locked || risk.dailyWarned {
+		return
+	}
+	if !triggerRiskLockLocked(RiskLockDaily, reason) {
+		risk.dailyWarned = true
+		return
+	}
+	risk.locked = true
+	risk.lockedAt = time.Now()
+	risk.lockReason = reason
+	log.Printf("[Risk] LOCKED! %s", risk.lockReason)
+}
+
+// GetRiskStatus 获取当前风控状态（含分层风险预算）
 func GetRiskStatus() map[string]interface{} {
 	risk.mu.Lock()
-	defer risk.mu.Unlock()
-
 	if risk.config.Enabled {
 		resetRiskForNewDayLocked()
 	}
-
-	return map[string]interface{}{
+	status := map[string]interface{}{
 		"enabled":            risk.config.Enabled,
 		"dailyMaxLosses":     risk.config.DailyMaxLosses,
 		"maxDailyLossAmount": risk.config.MaxDailyLossAmount,
@@ -143,28 +195,47 @@ func GetRiskStatus() map[string]interface{} {
 		"locked":             risk.locked,
 		"lockReason":         risk.lockReason,
 		"lockedAt":           risk.lockedAt,
+		"tradingDay":         risk.lastResetDay,
+		"lockAction":         riskLockActionLocked(RiskLockDaily),
+		"drawdown":           drawdownStatusLocked(time.Now()),
 	}
+	budgets := riskBudgetStatusLocked(time.Now())
+	risk.mu.Unlock()
+
+	// 未平仓保证金需查库，放在锁外
+	status["budgets"] = groupRiskBudgetStatus(budgets)
+	return status
 }
 
-// UnlockRisk 手动解锁风控（紧急情况）
+// UnlockRisk 手动解锁风控（紧急情况），同时解除所有分层预算锁
 func UnlockRisk() {
 	risk.mu.Lock()
 	defer risk.mu.Unlock()
 	risk.locked = false
 	risk.lockReason = ""
 	risk.lockedAt = time.Time{}
+	for _, b := range risk.budgets {
+		b.unlock()
+	}
+	// 回撤基线重置，避免立即再次触发
+	risk.drawdownLocks = make(map[string]*riskLock)
+	risk.drawdownResetAt = time.Now()
+	risk.equityPeak = risk.equity
 	log.Println("[Risk] Manually unlocked")
 }
 
-// recoverDailyPnl 从数据库恢复当天的已实现盈亏和亏损次数
+// recoverDailyPnl 从数据库恢复当天的已实现盈亏和亏损次数，并用近 30 天平仓记录重建分层预算与滚动回撤
 func recoverDailyPnl() {
 	if DB == nil {
 		return
 	}
 
-	todayStart := time.Now().Truncate(24 * time.Hour)
+	now := time.Now()
+	todayStart := tradingDayStart(now)
+	weekStart := tradingWeekStart(now)
 	var records []TradeRecord
-	err := DB.Where("status = ? AND updated_at >= ?", "CLOSED", todayStart).Find(&records).Error
+	err := DB.Where("status = ? AND updated_at >= ?", "CLOSED", now.Add(-riskPnlHistoryWindow)).
+		Order("updated_at ASC").Find(&records).Error
 	if err != nil {
 		log.Printf("[Risk] Failed to recover daily PnL: %v", err)
 		return
@@ -173,32 +244,56 @@ func recoverDailyPnl() {
 	var totalPnl float64
 	var lossCount int
 	var lossAmount float64
+	var todayCount int
+	risk.mu.Lock()
 	for _, r := range records {
 		pnl := r.RealizedPnl
+		if pnl != 0 {
+			appendRiskPnlLocked(pnl, r.UpdatedAt)
+			if !r.UpdatedAt.Before(weekStart) {
+				recordRiskBudgetPnlLocked(r.Source, r.Symbol, pnl, r.UpdatedAt)
+			}
+		}
+		if r.UpdatedAt.Before(todayStart) {
+			continue
+		}
+		todayCount++
 		totalPnl += pnl
 		if pnl < 0 {
 			lossCount++
 			lossAmount += -pnl
 		}
 	}
-
-	risk.mu.Lock()
 	risk.dailyPnl = totalPnl
 	risk.dailyLosses = lossCount
 	risk.dailyLossAmount = lossAmount
+	evaluateDrawdownLocksLocked(now)
 	risk.mu.Unlock()
 
-	log.Printf("[Risk] Recovered: PnL=%.2f USDT, losses=%d, lossAmount=%.2f USDT (%d closed trades today)",
-		totalPnl, lossCount, lossAmount, len(records))
+	log.Printf("[Risk] Recovered: PnL=%.2f USDT, losses=%d, lossAmount=%.2f USDT (%d closed trades today, %d in 30 days)",
+		totalPnl, lossCount, lossAmount, todayCount, len(records))
 
 	// 触发锁定检查
 	if lossCount > 0 || totalPnl < 0 {
-		AddDailyPnl(0)
+		AddDailyPnl(0, "", "")
+	}
+}
+
+// normalizeBudgetKeys 统一预算覆盖表的 key 大小写
+func normalizeBudgetKeys(m map[string]RiskBudgetLimits, norm func(string) string) map[string]RiskBudgetLimits {
+	if len(m) == 0 {
+		return m
+	}
+	out := make(map[string]RiskBudgetLimits, len(m))
+	for k, v := range m {
+		out[norm(strings.TrimSpace(k))] = v
 	}
+	return out
 }
 
+// today 当前交易日（按配置的时区与切换时间）
 func today() string {
-	return time.Now().Format("2006-01-02")
+	return tradingDay(time.Now())
 }
 
 // resetRiskForNewDayLocked 跨日时重置日内统计；调用方需持有 risk.mu 锁。
@@ -212,6 +307,7 @@ func resetRiskForNewDayLocked() bool {
 	risk.locked = false
 	risk.lockReason = ""
 	risk.lockedAt = time.Time{}
+	risk.dailyWarned = false
 	risk.lastResetDay = today()
 	return true
 }
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// 风控锁定后的处置动作
+const (
+	RiskActionWarn       = "warn"        // 仅告警
+	RiskActionBlock      = "block"       // 禁止新开仓
+	RiskActionReduceOnly = "reduce_only" // 禁止新开仓并撤销未成交的开仓挂单
+	RiskActionFlatten    = "flatten"     // 撤单并市价平掉全部持仓
+)
+
+// 风控锁类型（用于 lockActions 覆盖）
+const (
+	RiskLockDaily      = "daily"
+	RiskLockRolling7d  = "rolling7d"
+	RiskLockRolling30d = "rolling30d"
+	RiskLockEquity     = "equity"
+)
+
+// riskPnlPoint 已实现盈亏流水（滚动回撤用）
+type riskPnlPoint struct {
+	At  time.Time
+	Pnl float64
+}
+
+// riskLock 回撤类锁（条件恢复后自动解除）
+type riskLock struct {
+	Kind   string    `json:"kind"`
+	Action string    `json:"action"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+const riskPnlHistoryWindow = 30 * 24 * time.Hour
+
+var (
+	riskCalMu     sync.RWMutex
+	riskCalLoc    = time.Local
+	riskCalOffset time.Duration // 交易日切换时间相对 00:00 的偏移
+
+	// riskLockActionRunner 执行撤单/平仓动作（测试注入点）
+	riskLockActionRunner = applyRiskLockAction
+)
+
+// setRiskCalendar 设置交易日时区与切换时间，如 ("UTC", "00:00")
+func setRiskCalendar(tz, resetTime string) error {
+	loc := time.Local
+	if tz = strings.TrimSpace(tz); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("invalid resetTimezone %q: %w", tz, err)
+		}
+		loc = l
+	}
+	var offset time.Duration
+	if resetTime = strings.TrimSpace(resetTime); resetTime != "" {
+		t, err := time.Parse("15:04", resetTime)
+		if err != nil {
+			return fmt.Errorf("invalid resetTime %q, want HH:MM", resetTime)
+		}
+		offset = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	riskCalMu.Lock()
+	riskCalLoc, riskCalOffset = loc, offset
+	riskCalMu.Unlock()
+	return nil
+}
+
+func riskCalendar() (*time.Location, time.Duration) {
+	riskCalMu.RLock()
+	defer riskCalMu.RUnlock()
+	return riskCalLoc, riskCalOffset
+}
+
+// tradingDayStart t 所在交易日的起始时刻
+func tradingDayStart(t time.Time) time.Time {
+	loc, offset := riskCalendar()
+	y, m, d := t.In(loc).Add(-offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc).Add(offset)
+}
+
+// tradingWeekStart t 所在交易周（周一起）的起始时刻
+func tradingWeekStart(t time.Time) time.Time {
+	loc, offset := riskCalendar()
+	start := tradingDayStart(t)
+	weekday := start.In(loc).Add(-offset).Weekday()
+	return start.AddDate(0, 0, -((int(weekday) + 6) % 7))
+}
+
+// tradingDay t 所在交易日 "2006-01-02"
+func tradingDay(t time.Time) string {
+	loc, offset := riskCalendar()
+	return t.In(loc).Add(-offset).Format("2006-01-02")
+}
+
+// rollingDrawdown 窗口内已实现盈亏曲线从峰值到当前的回撤（窗口起点视为 0）
+func rollingDrawdown(points []riskPnlPoint, since time.Time) float64 {
+	var cum, peak float64
+	for _, p := range points {
+		if p.At.Before(since) {
+			continue
+		}
+		cum += p.Pnl
+		if cum > peak {
+			peak = cum
+		}
+	}
+	return peak - cum
+}
+
+// normalizeRiskAction 未知动作按 block 处理
+func normalizeRiskAction(action string) string {
+	switch a := strings.ToLower(strings.TrimSpace(action)); a {
+	case RiskActionWarn, RiskActionReduceOnly, RiskActionFlatten:
+		return a
+	}
+	return RiskActionBlock
+}
+
+// riskLockActionLocked 某类锁的处置动作；调用方需持有 risk.mu 锁。
+func riskLockActionLocked(kind string) string {
+	if a, ok := risk.config.LockActions[kind]; ok {
+		return normalizeRiskAction(a)
+	}
+	return normalizeRiskAction(risk.config.LockAction)
+}
+
+// triggerRiskLockLocked 触发风控锁：通知并执行处置动作，返回是否阻止新开仓；调用方需持有 risk.mu 锁。
+func triggerRiskLockLocked(kind, reason string) bool {
+	action := riskLockActionLocked(kind)
+	log.Printf("[Risk] %s triggered (action=%s): %s", kind, action, reason)
+	if action == RiskActionWarn {
+		SendNotify(fmt.Sprintf("*风控预警*\n类型: %s\n%s", kind, reason))
+		return false
+	}
+	if kind == RiskLockDaily {
+		NotifyRiskLocked(reason)
+	} else {
+		SendNotify(fmt.Sprintf("*风控锁定*\n类型: %s\n%s\n处置: %s", kind, reason, action))
+	}
+	if action == RiskActionReduceOnly || action == RiskActionFlatten {
+		go riskLockActionRunner(action, reason)
+	}
+	return true
+}
+
+// appendRiskPnlLocked 记录盈亏流水并裁剪到 30 天；调用方需持有 risk.mu 锁。
+func appendRiskPnlLocked(pnl float64, at time.Time) {
+	risk.pnlHistory = append(risk.pnlHistory, riskPnlPoint{At: at, Pnl: pnl})
+	cutoff := time.Now().Add(-riskPnlHistoryWindow)
+	drop := 0
+	for drop < len(risk.pnlHistory) && risk.pnlHistory[drop].At.Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		risk.pnlHistory = append([]riskPnlPoint(nil), risk.pnlHistory[drop:]...)
+	}
+}
+
+// drawdownMetricsLocked 当前各回撤指标；调用方需持有 risk.mu 锁。
+func drawdownMetricsLocked(now time.Time) (dd7, dd30, equityPct float64) {
+	since := func(window time.Duration) time.Time {
+		s := now.Add(-window)
+		if risk.drawdownResetAt.After(s) {
+			s = risk.drawdownResetAt
+		}
+		return s
+	}
+	dd7 = rollingDrawdown(risk.pnlHistory, since(7*24*time.Hour))
+	dd30 = rollingDrawdown(risk.pnlHistory, since(30*24*time.Hour))
+	if risk.equityPeak > 0 && risk.equity > 0 {
+		equityPct = (risk.equityPeak - risk.equity) / risk.equityPeak * 100
+	}
+	return
+}
+
+// evaluateDrawdownLocksLocked 评估滚动/权益回撤：新触发的锁执行处置，条件恢复的锁自动解除；调用方需持有 risk.mu 锁。
+func evaluateDrawdownLocksLocked(now time.Time) {
+	if !risk.config.Enabled {
+		return
+	}
+	if risk.drawdownLocks == nil {
+		risk.drawdownLocks = make(map[string]*riskLock)
+	}
+	dd7, dd30, equityPct := drawdownMetricsLocked(now)
+	checks := []struct {
+		kind     string
+		breached bool
+		reason   string
+	}{
+		{RiskLockRolling7d, risk.config.Rolling7dDrawdownLimit > 0 && dd7 >= risk.config.Rolling7dDrawdownLimit,
+			fmt.Sprintf("近 7 天回撤 %.2f USDT 已达限额 %.2f USDT", dd7, risk.config.Rolling7dDrawdownLimit)},
+		{RiskLockRolling30d, risk.config.Rolling30dDrawdownLimit > 0 && dd30 >= risk.config.Rolling30dDrawdownLimit,
+			fmt.Sprintf("近 30 天回撤 %.2f USDT 已达限额 %.2f USDT", dd30, risk.config.Rolling30dDrawdownLimit)},
+		{RiskLockEquity, risk.config.MaxEquityDrawdownPct > 0 && equityPct >= risk.config.MaxEquityDrawdownPct,
+			fmt.Sprintf("权益回撤 %.2f%% (峰值 %.2f → %.2f) 已达限额 %.2f%%",
+				equityPct, risk.equityPeak, risk.equity, risk.config.MaxEquityDrawdownPct)},
+	}
+	for _, c := range checks {
+		_, active := risk.drawdownLocks[c.kind]
+		switch {
+		case c.breached && !active:
+			RecordRiskTrigger("drawdown")
+			triggerRiskLockLocked(c.kind, c.reason)
+			risk.drawdownLocks[c.kind] = &riskLock{Kind: c.kind, Action: riskLockActionLocked(c.kind), Reason: c.reason, At: now}
+		case !c.breached && active:
+			delete(risk.drawdownLocks, c.kind)
+			log.Printf("[Risk] %s drawdown recovered, lock released", c.kind)
+		}
+	}
+}
+
+// checkDrawdownLocks 回撤锁检查（warn 动作不拦截）
+func checkDrawdownLocks() error {
+	risk.mu.Lock()
+	defer risk.mu.Unlock()
+	evaluateDrawdownLocksLocked(time.Now())
+	for _, kind := range []string{RiskLockEquity, RiskLockRolling30d, RiskLockRolling7d} {
+		if l, ok := risk.drawdownLocks[kind]; ok && l.Action != RiskActionWarn {
+			return fmt.Errorf("回撤风控锁定 [%s]: %s", kind, l.Reason)
+		}
+	}
+	return nil
+}
+
+// riskCapitalFlowReasons 出入金与划转类账户更新原因：余额变动不是交易盈亏，不计入权益回撤
+var riskCapitalFlowReasons = map[futures.UserDataEventReasonType]bool{
+	futures.UserDataEventReasonTypeDeposit:        true,
+	futures.UserDataEventReasonTypeWithdraw:       true,
+	futures.UserDataEventReasonTypeWithdrawReject: true,
+	futures.UserDataEventReasonTypeAdminDeposit:   true,
+	futures.UserDataEventReasonTypeAdminWithdraw:  true,
+	futures.UserDataEventReasonTypeMarginTransfer: true,
+	futures.UserDataEventReasonTypeAssetTransfer:  true,
+}
+
+// UpdateRiskEquity 更新钱包余额并维护峰值
+func UpdateRiskEquity(balance float64) {
+	if balance <= 0 {
+		return
+	}
+	risk.mu.Lock()
+	defer risk.mu.Unlock()
+	risk.equity = balance
+	if balance > risk.equityPeak {
+		risk.equityPeak = balance
+	}
+	evaluateDrawdownLocksLocked(time.Now())
+}
+
+// UpdateRiskEquityForReason 按账户更新原因同步钱包余额：出入金与划转时峰值随之平移 change（bc 字段，
+// 缺失时取与上次余额之差），提现/转出不会被当作回撤，入金也不会抬高峰值后放大之后的回撤
+func UpdateRiskEquityForReason(reason futures.UserDataEventReasonType, balance, change float64) {
+	if !riskCapitalFlowReasons[reason] {
+		UpdateRiskEquity(balance)
+		return
+	}
+	if balance < 0 {
+		return
+	}
+	risk.mu.Lock()
+	defer risk.mu.Unlock()
+	if change == 0 && risk.equity > 0 {
+		change = balance - risk.equity
+	}
+	if risk.equityPeak > 0 {
+		peak := math.Max(risk.equityPeak+change, balance)
+		log.Printf("[Risk] %s %.2f USDT: equity peak adjusted %.2f → %.2f", reason, change, risk.equityPeak, peak)
+		risk.equityPeak = peak
+	} else {
+		risk.equityPeak = balance
+	}
+	risk.equity = balance
+	evaluateDrawdownLocksLocked(time.Now())
+}
+
+// seedRiskEquity 启动时从账户余额初始化权益峰值
+func seedRiskEquity() {
+	if Client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	bal, err := GetBalance(ctx)
+	if err != nil {
+		log.Printf("[Risk] Failed to seed equity: %v", err)
+		return
+	}
+	wallet, _ := strconv.ParseFloat(bal["balance"], 64)
+	UpdateRiskEquity(wallet)
+}
+
+// drawdownStatusLocked 回撤类状态；调用方需持有 risk.mu 锁。
+func drawdownStatusLocked(now time.Time) map[string]interface{} {
+	evaluateDrawdownLocksLocked(now)
+	dd7, dd30, equityPct := drawdownMetricsLocked(now)
+	locks := make([]riskLock, 0, len(risk.drawdownLocks))
+	for _, l := range risk.drawdownLocks {
+		locks = append(locks, *l)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Kind < locks[j].Kind })
+	return map[string]interface{}{
+		"rolling7dDrawdown":       roundFloat(dd7, 4),
+		"rolling7dDrawdownLimit":  risk.config.Rolling7dDrawdownLimit,
+		"rolling30dDrawdown":      roundFloat(dd30, 4),
+		"rolling30dDrawdownLimit": risk.config.Rolling30dDrawdownLimit,
+		"equity":                  risk.equity,
+		"equityPeak":              risk.equityPeak,
+		"equityDrawdownPct":       roundFloat(equityPct, 4),
+		"maxEquityDrawdownPct":    risk.config.MaxEquityDrawdownPct,
+		"locks":                   locks,
+	}
+}
+
+// applyRiskLockAction 执行 reduce_only / flatten 处置：撤销开仓挂单，flatten 时再平掉全部持仓
+func applyRiskLockAction(action, reason string) {
+	if Client == nil || IsDryRun() {
+		log.Printf("[Risk] %s skipped: no live client (%s)", action, reason)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	orders, err := GetOrderListViaWs(ctx, "")
+	if err != nil {
+		log.Printf("[Risk] %s: list open orders failed: %v", action, err)
+	}
+	for _, o := range riskOrdersToCancel(orders) {
+		if _, err := CancelOrderViaWs(ctx, o.Symbol, o.OrderID); err != nil {
+			SaveFailedOperation("RISK_CANCEL_ORDER", "risk_control", o.Symbol, map[string]interface{}{
+				"action": action, "reason": reason, "orderId": o.OrderID,
+			}, o.OrderID, err)
+		}
+	}
+	if action != RiskActionFlatten {
+		return
+	}
+
+	positions, err := GetPositionsViaWs(ctx)
+	if err != nil {
+		log.Printf("[Risk] flatten: get positions failed: %v", err)
+		return
+	}
+	for _, p := range positions {
+		if amt, _ := strconv.ParseFloat(p.PositionAmt, 64); amt == 0 {
+			continue
+		}
+		req := ClosePositionReq{Symbol: p.Symbol, PositionSide: futures.PositionSideType(p.PositionSide)}
+		if _, err := ClosePositionViaWs(ctx, req); err != nil {
+			SaveFailedOperation("RISK_FLATTEN", "risk_control", p.Symbol, req, 0, err)
+			continue
+		}
+		log.Printf("[Risk] flatten: closed %s %s (%s)", p.Symbol, p.PositionSide, reason)
+	}
+}
+
+// riskOrdersToCancel 风控锁定时需撤销的开仓挂单：只减仓、全平以及对冲模式下的反向平仓单（SELL/LONG、BUY/SHORT）保留
+func riskOrdersToCancel(orders []*futures.Order) []*futures.Order {
+	var out []*futures.Order
+	for _, o := range orders {
+		if o == nil || o.ClosePosition || isReducingOrder(PlaceOrderReq{
+			Side: o.Side, PositionSide: o.PositionSide, ReduceOnly: o.ReduceOnly,
+		}) {
+			continue
+		}
+		out = append(out, o)
+	}
+	return out
+}
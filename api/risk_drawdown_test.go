@@ -0,0 +1,183 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestTradingDayBoundary(t *testing.T) {
+	if err := setRiskCalendar("Asia/Shanghai", "08:00"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer setRiskCalendar("", "")
+
+	// 北京时间 08:00 = UTC 00:00
+	before := time.Date(2026, 10, 14, 23, 59, 0, 0, time.UTC) // 周三
+	after := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	if tradingDay(before) != "2026-10-14" || tradingDay(after) != "2026-10-15" {
+		t.Fatalf("day before=%s after=%s", tradingDay(before), tradingDay(after))
+	}
+	if start := tradingDayStart(before); !start.Equal(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("day start=%v", start)
+	}
+	if week := tradingWeekStart(after); !week.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("week start=%v", week)
+	}
+
+	for _, bad := range [][2]string{{"Mars/Olympus", ""}, {"UTC", "25:00"}} {
+		if err := setRiskCalendar(bad[0], bad[1]); err == nil {
+			t.Fatalf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestRollingDrawdown(t *testing.T) {
+	base := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	points := []riskPnlPoint{
+		{base, -50},                      // 窗口外
+		{base.Add(24 * time.Hour), 30},   // 峰值 30
+		{base.Add(48 * time.Hour), -50},  // -20
+		{base.Add(72 * time.Hour), 10},   // -10
+		{base.Add(96 * time.Hour), -5},   // -15
+		{base.Add(120 * time.Hour), 100}, // 85，新峰值
+		{base.Add(144 * time.Hour), -40}, // 45
+	}
+	if dd := rollingDrawdown(points[:5], base.Add(time.Hour)); dd != 45 {
+		t.Fatalf("dd=%v want 45", dd)
+	}
+	if dd := rollingDrawdown(points, base.Add(time.Hour)); dd != 40 {
+		t.Fatalf("dd=%v want 40", dd)
+	}
+	// 窗口从亏损开始：起点 0 视为峰值
+	if dd := rollingDrawdown(points[:1], base); dd != 50 {
+		t.Fatalf("dd=%v want 50", dd)
+	}
+}
+
+func TestRiskLockActions(t *testing.T) {
+	withRiskConfig(t, RiskConfig{
+		Enabled:              true,
+		MaxDailyLossAmount:   10,
+		MaxEquityDrawdownPct: 20,
+		LockAction:           "reduce_only",
+		LockActions:          map[string]string{RiskLockDaily: "warn"},
+	})
+	risk.mu.Lock()
+	origHistory, origLocks := risk.pnlHistory, risk.drawdownLocks
+	origEquity, origPeak := risk.equity, risk.equityPeak
+	risk.lastResetDay, risk.locked, risk.dailyWarned = today(), false, false
+	risk.dailyLossAmount, risk.dailyLosses, risk.dailyPnl = 0, 0, 0
+	risk.pnlHistory, risk.drawdownLocks = nil, nil
+	risk.equity, risk.equityPeak = 0, 0
+	risk.mu.Unlock()
+	ran := make(chan string, 4)
+	origRunner := riskLockActionRunner
+	riskLockActionRunner = func(action, reason string) { ran <- action }
+	defer func() {
+		riskLockActionRunner = origRunner
+		risk.mu.Lock()
+		risk.pnlHistory, risk.drawdownLocks = origHistory, origLocks
+		risk.equity, risk.equityPeak = origEquity, origPeak
+		risk.locked, risk.dailyWarned = false, false
+		risk.dailyLossAmount, risk.dailyLosses, risk.dailyPnl = 0, 0, 0
+		risk.mu.Unlock()
+	}()
+
+	// 日内限额设为 warn：只告警不锁定
+	AddDailyPnl(-12, "manual", "BTCUSDT")
+	if err := CheckRisk(); err != nil {
+		t.Fatalf("warn action should not block: %v", err)
+	}
+
+	// 权益从 1000 跌到 750：触发 reduce_only
+	UpdateRiskEquity(1000)
+	UpdateRiskEquity(750)
+	err := CheckRisk()
+	if err == nil || !strings.Contains(err.Error(), RiskLockEquity) {
+		t.Fatalf("expected equity lock, got %v", err)
+	}
+	select {
+	case action := <-ran:
+		if action != RiskActionReduceOnly {
+			t.Fatalf("action=%s", action)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lock action not executed")
+	}
+
+	// 手动解锁后以当前余额为新峰值
+	UnlockRisk()
+	if err := CheckRisk(); err != nil {
+		t.Fatalf("still locked after unlock: %v", err)
+	}
+	status := GetRiskStatus()["drawdown"].(map[string]interface{})
+	if status["equityPeak"] != 750.0 || status["equityDrawdownPct"] != 0.0 {
+		t.Fatalf("status=%+v", status)
+	}
+}
+
+func TestUpdateRiskEquityForReasonCapitalFlows(t *testing.T) {
+	withRiskConfig(t, RiskConfig{Enabled: true, MaxEquityDrawdownPct: 20, LockAction: "warn"})
+	risk.mu.Lock()
+	origEquity, origPeak, origLocks := risk.equity, risk.equityPeak, risk.drawdownLocks
+	risk.equity, risk.equityPeak, risk.drawdownLocks = 0, 0, nil
+	risk.mu.Unlock()
+	defer func() {
+		risk.mu.Lock()
+		risk.equity, risk.equityPeak, risk.drawdownLocks = origEquity, origPeak, origLocks
+		risk.mu.Unlock()
+	}()
+	drawdown := func() (float64, float64) {
+		risk.mu.Lock()
+		defer risk.mu.Unlock()
+		_, _, pct := drawdownMetricsLocked(time.Now())
+		return risk.equityPeak, pct
+	}
+
+	UpdateRiskEquityForReason(futures.UserDataEventReasonTypeOrder, 1000, 0)
+	UpdateRiskEquityForReason(futures.UserDataEventReasonTypeOrder, 900, 0)
+
+	// 提现 500：峰值同步下移，回撤仍为交易造成的 100/500
+	UpdateRiskEquityForReason(futures.UserDataEventReasonTypeWithdraw, 400, -500)
+	if peak, pct := drawdown(); peak != 500 || pct != 20 {
+		t.Fatalf("after withdraw peak=%v pct=%v", peak, pct)
+	}
+
+	// 划转缺少 bc 字段时按余额差平移
+	UpdateRiskEquityForReason(futures.UserDataEventReasonTypeAssetTransfer, 600, 0)
+	if peak, pct := drawdown(); peak != 700 || pct > 14.29 || pct < 14.28 {
+		t.Fatalf("after transfer peak=%v pct=%v", peak, pct)
+	}
+
+	// 入金使余额超过平移后的峰值：峰值取余额
+	UpdateRiskEquityForReason(futures.UserDataEventReasonTypeDeposit, 2000, 1400)
+	if peak, pct := drawdown(); peak != 2100 || pct <= 0 {
+		t.Fatalf("after deposit peak=%v pct=%v", peak, pct)
+	}
+	UpdateRiskEquityForReason(futures.UserDataEventReasonTypeDeposit, 3000, 1000)
+	if peak, _ := drawdown(); peak != 3100 {
+		t.Fatalf("deposit peak=%v", peak)
+	}
+}
+
+func TestRiskOrdersToCancelKeepsExits(t *testing.T) {
+	orders := []*futures.Order{
+		{OrderID: 1, Side: futures.SideTypeBuy, PositionSide: futures.PositionSideTypeLong},   // 开多
+		{OrderID: 2, Side: futures.SideTypeSell, PositionSide: futures.PositionSideTypeLong},  // 平多止盈/网格对手单
+		{OrderID: 3, Side: futures.SideTypeBuy, PositionSide: futures.PositionSideTypeShort},  // 平空
+		{OrderID: 4, Side: futures.SideTypeSell, PositionSide: futures.PositionSideTypeShort}, // 开空
+		{OrderID: 5, Side: futures.SideTypeSell, PositionSide: futures.PositionSideTypeBoth, ReduceOnly: true},
+		{OrderID: 6, Side: futures.SideTypeBuy, PositionSide: futures.PositionSideTypeBoth, ClosePosition: true},
+		{OrderID: 7, Side: futures.SideTypeBuy, PositionSide: futures.PositionSideTypeBoth},
+	}
+	var ids []int64
+	for _, o := range riskOrdersToCancel(orders) {
+		ids = append(ids, o.OrderID)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 4 || ids[2] != 7 {
+		t.Fatalf("cancel ids=%v, want [1 4 7]", ids)
+	}
+}
@@ -0,0 +1,529 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// whatIfMaintMarginRate 估算强平距离使用的维持保证金率（主流币最低档约 0.4%~0.5%）
+const whatIfMaintMarginRate = 0.005
+
+// WhatIfOrder 假设订单
+type WhatIfOrder struct {
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`                   // BUY / SELL
+	PositionSide  string  `json:"positionSide,omitempty"` // BOTH / LONG / SHORT
+	QuoteQuantity float64 `json:"quoteQuantity"`          // 保证金(USDT)
+	Leverage      int     `json:"leverage"`
+}
+
+// WhatIfReq 假设下单模拟请求
+type WhatIfReq struct {
+	Orders      []WhatIfOrder `json:"orders"`
+	Model       string        `json:"model,omitempty"`       // 默认 varRisk.model，未配置时 historical
+	Confidence  float64       `json:"confidence,omitempty"`  // 默认 0.95
+	Simulations int           `json:"simulations,omitempty"` // 蒙特卡洛路径数
+}
+
+// WhatIfPosition 模拟后的单个持仓
+type WhatIfPosition struct {
+	Symbol                 string  `json:"symbol"`
+	PositionSide           string  `json:"positionSide"`
+	Notional               float64 `json:"notional"` // 带方向，空头为负
+	Margin                 float64 `json:"margin"`
+	Leverage               float64 `json:"leverage"`
+	LiquidationDistancePct float64 `json:"liquidationDistancePct"`
+	LiquidationEstimated   bool    `json:"liquidationEstimated"` // true=按杠杆估算，false=交易所强平价
+	ComponentVar           float64 `json:"componentVar"`
+	Changed                bool    `json:"changed"`
+}
+
+// WhatIfPortfolio 组合层面的风险指标
+type WhatIfPortfolio struct {
+	Var                   float64          `json:"var"`
+	CVar                  float64          `json:"cvar"`
+	GrossNotional         float64          `json:"grossNotional"`
+	NetNotional           float64          `json:"netNotional"`
+	MarginUsed            float64          `json:"marginUsed"`
+	MarginUsagePct        float64          `json:"marginUsagePct"`
+	AccountLiqDistancePct float64          `json:"accountLiqDistancePct"` // 全部持仓同时不利变动多少会亏光权益
+	MinLiqDistancePct     float64          `json:"minLiqDistancePct"`
+	AvgCorrelation        float64          `json:"avgCorrelation"`       // 按敞口加权的带方向平均相关系数，越高越集中
+	DiversificationRatio  float64          `json:"diversificationRatio"` // 单独 VaR 之和 / 组合 VaR
+	TopComponentPct       float64          `json:"topComponentPct"`      // 最大成分 VaR 占比
+	Positions             []WhatIfPosition `json:"positions"`
+}
+
+// WhatIfResult 假设下单前后的对比
+type WhatIfResult struct {
+	Model           string          `json:"model"`
+	Confidence      float64         `json:"confidence"`
+	Equity          float64         `json:"equity"`
+	Before          WhatIfPortfolio `json:"before"`
+	After           WhatIfPortfolio `json:"after"`
+	MarginalVar     float64         `json:"marginalVar"` // 下单带来的 VaR 增量
+	VarBudget       float64         `json:"varBudget"`
+	RemainingBudget float64         `json:"remainingBudget"`
+	ExceedsBudget   bool            `json:"exceedsBudget"`
+}
+
+// whatIfBookLoader 加载当前持仓与账户权益（测试注入点）
+var whatIfBookLoader = loadWhatIfBook
+
+// whatIfLeg 模拟中的持仓腿
+type whatIfLeg struct {
+	symbol, positionSide string
+	before, after        float64 // 带方向名义价值
+	beforeMargin, margin float64
+	leverage             float64
+	liqDistance          float64 // 交易所强平价给出的距离(%)，0=未知
+	changed              bool
+}
+
+func loadWhatIfBook(ctx context.Context) ([]*futures.PositionRisk, float64, error) {
+	if Client == nil {
+		return nil, 0, fmt.Errorf("binance client not initialized")
+	}
+	positions, err := GetPositions(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	bal, err := GetBalance(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	wallet, _ := strconv.ParseFloat(bal["balance"], 64)
+	unPnl, _ := strconv.ParseFloat(bal["crossUnPnl"], 64)
+	return positions, wallet + unPnl, nil
+}
+
+// buildWhatIfLegs 当前持仓叠加假设订单
+func buildWhatIfLegs(positions []*futures.PositionRisk, orders []WhatIfOrder) ([]*whatIfLeg, error) {
+	var legs []*whatIfLeg
+	index := map[string]*whatIfLeg{}
+	leg := func(symbol, positionSide string) *whatIfLeg {
+		key := symbol + "|" + positionSide
+		if l, ok := index[key]; ok {
+			return l
+		}
+		l := &whatIfLeg{symbol: symbol, positionSide: positionSide}
+		index[key] = l
+		legs = append(legs, l)
+		return l
+	}
+
+	for _, p := range positions {
+		amt, _ := strconv.ParseFloat(p.PositionAmt, 64)
+		if amt == 0 {
+			continue
+		}
+		mark, _ := strconv.ParseFloat(p.MarkPrice, 64)
+		liq, _ := strconv.ParseFloat(p.LiquidationPrice, 64)
+		lev, _ := strconv.ParseFloat(p.Leverage, 64)
+		side := strings.ToUpper(p.PositionSide)
+		if side == "" {
+			side = string(futures.PositionSideTypeBoth)
+		}
+		l := leg(strings.ToUpper(p.Symbol), side)
+		l.before += amt * mark
+		if lev > 0 {
+			l.leverage = lev
+			l.beforeMargin += math.Abs(amt*mark) / lev
+		}
+		if liq > 0 && mark > 0 {
+			l.liqDistance = math.Abs(mark-liq) / mark * 100
+		}
+	}
+	for _, l := range legs {
+		l.after, l.margin = l.before, l.beforeMargin
+	}
+
+	for _, o := range orders {
+		symbol := strings.ToUpper(strings.TrimSpace(o.Symbol))
+		side := strings.ToUpper(o.Side)
+		posSide := strings.ToUpper(o.PositionSide)
+		if posSide == "" {
+			posSide = string(futures.PositionSideTypeBoth)
+		}
+		if symbol == "" || (side != "BUY" && side != "SELL") || o.QuoteQuantity <= 0 || o.Leverage <= 0 {
+			return nil, fmt.Errorf("invalid order %+v", o)
+		}
+		if posSide != "BOTH" && posSide != "LONG" && posSide != "SHORT" {
+			return nil, fmt.Errorf("invalid positionSide %q", o.PositionSide)
+		}
+		delta := o.QuoteQuantity * float64(o.Leverage)
+		if side == "SELL" {
+			delta = -delta
+		}
+		l := leg(symbol, posSide)
+		l.changed = true
+		prev := l.after
+		next := prev + delta
+		// 对冲模式下平仓不会反向开仓
+		if (posSide == "LONG" && next < 0) || (posSide == "SHORT" && next > 0) {
+			next = 0
+		}
+		switch {
+		case prev == 0 || math.Signbit(prev) != math.Signbit(next):
+			// 新开仓或单向模式反手：超出原仓位的部分按本单杠杆占用保证金
+			l.margin = math.Abs(next) / float64(o.Leverage)
+			l.leverage = float64(o.Leverage)
+		case math.Abs(next) > math.Abs(prev):
+			l.margin += o.QuoteQuantity
+			l.leverage = float64(o.Leverage)
+		default:
+			l.margin *= math.Abs(next) / math.Abs(prev)
+		}
+		l.after = next
+	}
+	return legs, nil
+}
+
+// whatIfPortfolio 按一组敞口计算组合指标；est 为空时不含 VaR
+func whatIfPortfolio(legs []*whatIfLeg, after bool, equity float64, est *varEstimate, corr [][]float64, symIdx []int) WhatIfPortfolio {
+	var pf WhatIfPortfolio
+	pf.MinLiqDistancePct = -1
+	exposures := make([]float64, len(legs))
+	for j, l := range legs {
+		notional, margin := l.before, l.beforeMargin
+		if after {
+			notional, margin = l.after, l.margin
+		}
+		exposures[j] = notional
+		if notional == 0 {
+			continue
+		}
+		pos := WhatIfPosition{
+			Symbol:       l.symbol,
+			PositionSide: l.positionSide,
+			Notional:     roundFloat(notional, 2),
+			Margin:       roundFloat(margin, 2),
+			Leverage:     l.leverage,
+			Changed:      after && l.changed,
+		}
+		if l.liqDistance > 0 && !pos.Changed {
+			pos.LiquidationDistancePct = roundFloat(l.liqDistance, 2)
+		} else if margin > 0 {
+			pos.LiquidationDistancePct = roundFloat(math.Max(margin/math.Abs(notional)-whatIfMaintMarginRate, 0)*100, 2)
+			pos.LiquidationEstimated = true
+		}
+		if est != nil {
+			pos.ComponentVar = roundFloat(est.Components[j], 2)
+		}
+		if pf.MinLiqDistancePct < 0 || pos.LiquidationDistancePct < pf.MinLiqDistancePct {
+			pf.MinLiqDistancePct = pos.LiquidationDistancePct
+		}
+		pf.GrossNotional += math.Abs(notional)
+		pf.NetNotional += notional
+		pf.MarginUsed += margin
+		pf.Positions = append(pf.Positions, pos)
+	}
+	if pf.MinLiqDistancePct < 0 {
+		pf.MinLiqDistancePct = 0
+	}
+	if equity > 0 {
+		pf.MarginUsagePct = roundFloat(pf.MarginUsed/equity*100, 2)
+		if pf.GrossNotional > 0 {
+			pf.AccountLiqDistancePct = roundFloat(equity/pf.GrossNotional*100, 2)
+		}
+	}
+	pf.GrossNotional = roundFloat(pf.GrossNotional, 2)
+	pf.NetNotional = roundFloat(pf.NetNotional, 2)
+	pf.MarginUsed = roundFloat(pf.MarginUsed, 2)
+
+	if corr != nil {
+		var num, den float64
+		for a := range exposures {
+			for b := range exposures {
+				if a == b {
+					continue
+				}
+				num += exposures[a] * exposures[b] * corr[symIdx[a]][symIdx[b]]
+				den += math.Abs(exposures[a] * exposures[b])
+			}
+		}
+		if den > 0 {
+			pf.AvgCorrelation = roundFloat(num/den, 4)
+		}
+	}
+	if est != nil {
+		pf.Var = roundFloat(est.Var, 2)
+		pf.CVar = roundFloat(est.CVar, 2)
+		var standalone float64
+		for j, c := range est.Components {
+			standalone += est.Standalone[j]
+			if est.Var > 0 {
+				pf.TopComponentPct = math.Max(pf.TopComponentPct, roundFloat(c/est.Var*100, 2))
+			}
+		}
+		if est.Var > 0 {
+			pf.DiversificationRatio = roundFloat(standalone/est.Var, 4)
+		}
+	}
+	return pf
+}
+
+// SimulateWhatIf 模拟假设订单成交后的组合 VaR/CVaR、名义价值、保证金占用、强平距离与相关性集中度
+func SimulateWhatIf(ctx context.Context, req WhatIfReq) (*WhatIfResult, error) {
+	if len(req.Orders) == 0 {
+		return nil, fmt.Errorf("orders is required")
+	}
+	vreq := VarModelReq{Model: req.Model, Confidence: req.Confidence, Simulations: req.Simulations}
+	params, err := normalizeVarModelReq(&vreq)
+	if err != nil {
+		return nil, err
+	}
+	positions, equity, err := whatIfBookLoader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	legs, err := buildWhatIfLegs(positions, req.Orders)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []string
+	col := map[string]int{}
+	symIdx := make([]int, len(legs))
+	before := make([]float64, len(legs))
+	after := make([]float64, len(legs))
+	for j, l := range legs {
+		if _, ok := col[l.symbol]; !ok {
+			col[l.symbol] = len(symbols)
+			symbols = append(symbols, l.symbol)
+		}
+		symIdx[j] = col[l.symbol]
+		before[j], after[j] = l.before, l.after
+	}
+	// 前后使用同一份收益矩阵与随机种子，保证增量可比
+	returns, err := loadVarReturns(ctx, symbols, vreq.Interval, vreq.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	estBefore, err := estimateVar(vreq.Model, returns, symIdx, before, params)
+	if err != nil {
+		return nil, err
+	}
+	estAfter, err := estimateVar(vreq.Model, returns, symIdx, after, params)
+	if err != nil {
+		return nil, err
+	}
+	corr, _, _ := ledoitWolfCorrelation(returns)
+
+	varMu.RLock()
+	budget := varCfg.RiskBudgetUSDT
+	varMu.RUnlock()
+
+	res := &WhatIfResult{
+		Model:       vreq.Model,
+		Confidence:  vreq.Confidence,
+		Equity:      roundFloat(equity, 2),
+		Before:      whatIfPortfolio(legs, false, equity, estBefore, corr, symIdx),
+		After:       whatIfPortfolio(legs, true, equity, estAfter, corr, symIdx),
+		MarginalVar: roundFloat(estAfter.Var-estBefore.Var, 2),
+		VarBudget:   budget,
+	}
+	if budget > 0 {
+		res.RemainingBudget = roundFloat(budget-estBefore.Var, 2)
+		res.ExceedsBudget = res.MarginalVar > 0 && res.MarginalVar > res.RemainingBudget
+	}
+	sort.SliceStable(res.After.Positions, func(i, j int) bool {
+		return math.Abs(res.After.Positions[i].ComponentVar) > math.Abs(res.After.Positions[j].ComponentVar)
+	})
+	return res, nil
+}
+
+// HandleRiskWhatIf POST /tool/risk/whatif
+func HandleRiskWhatIf(c context.Context, ctx *app.RequestContext) {
+	var req WhatIfReq
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	res, err := SimulateWhatIf(c, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": res})
+}
+
+// ========== 网关热路径：边际 VaR ==========
+
+const (
+	preTradeVarTTL      = 15 * time.Second       // 快照过期后后台刷新
+	preTradeVarMaxStale = 5 * time.Minute        // 超过后在下单路径上同步重建
+	preTradeVarTimeout  = 800 * time.Millisecond // 同步重建超时
+)
+
+// preTradeVarBook 网关用的组合风险快照：按币种聚合的净敞口与收缩协方差
+type preTradeVarBook struct {
+	at       time.Time
+	col      map[string]int
+	symbols  []string
+	cov      [][]float64
+	exposure []float64 // 各币种净名义价值
+	variance float64   // exposureᵀ·cov·exposure
+	z        float64
+	baseVar  float64 // 配置模型下的组合 VaR，之后放行的新单按参数法增量累加
+}
+
+var (
+	preTradeVarMu      sync.Mutex
+	preTradeVarCache   *preTradeVarBook
+	preTradeVarLoading bool
+
+	// preTradeVarBookBuilder 构建快照（测试注入点）
+	preTradeVarBookBuilder = buildPreTradeVarBook
+)
+
+// buildPreTradeVarBook 拉取持仓与收益历史，构建网关快照；extra 为需要额外覆盖的币种
+func buildPreTradeVarBook(ctx context.Context, extra []string) (*preTradeVarBook, error) {
+	vreq := VarModelReq{Simulations: varBacktestMaxSims}
+	params, err := normalizeVarModelReq(&vreq)
+	if err != nil {
+		return nil, err
+	}
+	positions, _, err := whatIfBookLoader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	legs, err := buildWhatIfLegs(positions, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &preTradeVarBook{at: time.Now(), col: map[string]int{}, z: normQuantile(vreq.Confidence)}
+	addSymbol := func(sym string) int {
+		if i, ok := b.col[sym]; ok {
+			return i
+		}
+		b.col[sym] = len(b.symbols)
+		b.symbols = append(b.symbols, sym)
+		return b.col[sym]
+	}
+	symIdx := make([]int, len(legs))
+	w := make([]float64, len(legs))
+	for j, l := range legs {
+		symIdx[j] = addSymbol(l.symbol)
+		w[j] = l.before
+	}
+	for _, sym := range extra {
+		addSymbol(sym)
+	}
+	if len(b.symbols) == 0 {
+		return b, nil
+	}
+
+	returns, err := loadVarReturns(ctx, b.symbols, vreq.Interval, vreq.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	corr, vols, _ := ledoitWolfCorrelation(returns)
+	b.cov = make([][]float64, len(b.symbols))
+	for i := range b.cov {
+		b.cov[i] = make([]float64, len(b.symbols))
+		for j := range b.cov[i] {
+			b.cov[i][j] = corr[i][j] * vols[i] * vols[j]
+		}
+	}
+	b.exposure = make([]float64, len(b.symbols))
+	for j, l := range legs {
+		b.exposure[symIdx[j]] += l.before
+	}
+	for i := range b.exposure {
+		for j := range b.exposure {
+			b.variance += b.exposure[i] * b.cov[i][j] * b.exposure[j]
+		}
+	}
+	if len(legs) > 0 {
+		est, err := estimateVar(vreq.Model, returns, symIdx, w, params)
+		if err != nil {
+			return nil, err
+		}
+		b.baseVar = est.Var
+	}
+	return b, nil
+}
+
+// marginal 参数法边际 VaR：z·(√(σ² + 2Δ(Σe)ₛ + Δ²Σₛₛ) − √σ²)，返回增量与新方差
+func (b *preTradeVarBook) marginal(col int, delta float64) (float64, float64) {
+	var sigmaE float64
+	for j, e := range b.exposure {
+		sigmaE += b.cov[col][j] * e
+	}
+	after := math.Max(b.variance+2*delta*sigmaE+delta*delta*b.cov[col][col], 0)
+	return b.z * (math.Sqrt(after) - math.Sqrt(math.Max(b.variance, 0))), after
+}
+
+// preTradeMarginalVar 返回新单的边际 VaR 与当前组合 VaR；未超预算时把新单计入快照，
+// 避免网格等批量挂单在两次刷新之间逐笔放行、合计突破预算
+func preTradeMarginalVar(symbol string, delta, budget float64) (float64, float64, error) {
+	preTradeVarMu.Lock()
+	book := preTradeVarCache
+	if book != nil && time.Since(book.at) > preTradeVarTTL && !preTradeVarLoading {
+		preTradeVarLoading = true
+		go refreshPreTradeVarBook(book.symbols)
+	}
+	preTradeVarMu.Unlock()
+
+	var col int
+	ok := book != nil && time.Since(book.at) <= preTradeVarMaxStale
+	if ok {
+		col, ok = book.col[symbol]
+	}
+	if !ok {
+		var extra []string
+		if book != nil {
+			extra = book.symbols
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), preTradeVarTimeout)
+		fresh, err := preTradeVarBookBuilder(ctx, append(append([]string(nil), extra...), symbol))
+		cancel()
+		if err != nil {
+			return 0, 0, err
+		}
+		preTradeVarMu.Lock()
+		preTradeVarCache = fresh
+		preTradeVarMu.Unlock()
+		book, col = fresh, fresh.col[symbol]
+	}
+
+	preTradeVarMu.Lock()
+	defer preTradeVarMu.Unlock()
+	marginal, after := book.marginal(col, delta)
+	before := book.baseVar
+	if marginal <= 0 || marginal <= budget-before {
+		book.exposure[col] += delta
+		book.variance = after
+		book.baseVar = math.Max(before+marginal, 0)
+	}
+	return marginal, before, nil
+}
+
+// refreshPreTradeVarBook 后台刷新网关快照，失败时保留旧快照
+func refreshPreTradeVarBook(symbols []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	fresh, err := preTradeVarBookBuilder(ctx, symbols)
+	preTradeVarMu.Lock()
+	defer preTradeVarMu.Unlock()
+	preTradeVarLoading = false
+	if err != nil {
+		log.Printf("[PreTrade] refresh VaR snapshot failed: %v", err)
+		return
+	}
+	preTradeVarCache = fresh
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// withWhatIfMarket 注入持仓、权益与合成行情（BTC/ETH 正相关）
+func withWhatIfMarket(t *testing.T, positions []*futures.PositionRisk, equity, budget float64) {
+	t.Helper()
+	returns := syntheticReturns(300, 21)
+	closes := map[string]map[int64]float64{"BTCUSDT": {}, "ETHUSDT": {}}
+	px := [2]float64{50000, 3000}
+	for i, r := range returns {
+		ts := int64(i) * 3600_000
+		closes["BTCUSDT"][ts], closes["ETHUSDT"][ts] = px[0], px[1]
+		px[0] *= math.Exp(r[0])
+		px[1] *= math.Exp(r[1])
+	}
+
+	origBook, origCloses := whatIfBookLoader, varCloseLoader
+	whatIfBookLoader = func(context.Context) ([]*futures.PositionRisk, float64, error) {
+		return positions, equity, nil
+	}
+	varCloseLoader = func(_ context.Context, symbol, _ string, _ int) (map[int64]float64, error) {
+		c, ok := closes[symbol]
+		if !ok {
+			return nil, fmt.Errorf("no klines for %s", symbol)
+		}
+		return c, nil
+	}
+	varMu.Lock()
+	origCfg := varCfg
+	varCfg = VarRiskConfig{Enabled: true, RiskBudgetUSDT: budget}
+	varMu.Unlock()
+	resetPreTradeVarCache()
+	t.Cleanup(func() {
+		whatIfBookLoader, varCloseLoader = origBook, origCloses
+		varMu.Lock()
+		varCfg = origCfg
+		varMu.Unlock()
+		resetPreTradeVarCache()
+	})
+}
+
+func TestBuildWhatIfLegs(t *testing.T) {
+	positions := []*futures.PositionRisk{
+		{Symbol: "BTCUSDT", PositionSide: "BOTH", PositionAmt: "0.1", MarkPrice: "50000", LiquidationPrice: "45000", Leverage: "10"},
+		{Symbol: "ETHUSDT", PositionSide: "LONG", PositionAmt: "1", MarkPrice: "3000", Leverage: "5"},
+	}
+	legs, err := buildWhatIfLegs(positions, []WhatIfOrder{
+		{Symbol: "btcusdt", Side: "SELL", QuoteQuantity: 300, Leverage: 10},                       // 单向模式减仓 5000 → 2000
+		{Symbol: "ETHUSDT", Side: "SELL", PositionSide: "LONG", QuoteQuantity: 1000, Leverage: 5}, // 对冲模式平多不反向
+		{Symbol: "SOLUSDT", Side: "BUY", QuoteQuantity: 100, Leverage: 5},                         // 新开仓
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(legs) != 3 {
+		t.Fatalf("legs=%d", len(legs))
+	}
+	btc, eth, sol := legs[0], legs[1], legs[2]
+	if btc.before != 5000 || btc.after != 2000 || math.Abs(btc.margin-200) > 1e-9 || btc.liqDistance != 10 {
+		t.Fatalf("btc=%+v", *btc)
+	}
+	if eth.before != 3000 || eth.after != 0 || eth.margin != 0 {
+		t.Fatalf("eth=%+v", *eth)
+	}
+	if sol.before != 0 || sol.after != 500 || sol.margin != 100 || !sol.changed {
+		t.Fatalf("sol=%+v", *sol)
+	}
+
+	if _, err := buildWhatIfLegs(nil, []WhatIfOrder{{Symbol: "BTCUSDT", Side: "HOLD", QuoteQuantity: 1, Leverage: 1}}); err == nil {
+		t.Fatal("expected invalid side error")
+	}
+}
+
+func TestSimulateWhatIf(t *testing.T) {
+	positions := []*futures.PositionRisk{
+		{Symbol: "BTCUSDT", PositionSide: "BOTH", PositionAmt: "0.1", MarkPrice: "50000", LiquidationPrice: "45000", Leverage: "10"},
+	}
+	withWhatIfMarket(t, positions, 2000, 1e6)
+
+	// 同向加 ETH 多单：VaR 上升，集中度为正相关
+	res, err := SimulateWhatIf(context.Background(), WhatIfReq{
+		Orders: []WhatIfOrder{{Symbol: "ETHUSDT", Side: "BUY", QuoteQuantity: 300, Leverage: 10}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Model != VarModelHistorical || res.Before.Var <= 0 || res.MarginalVar <= 0 || res.ExceedsBudget {
+		t.Fatalf("res=%+v", res)
+	}
+	after := res.After
+	if after.GrossNotional != 8000 || after.MarginUsed != 800 || after.MarginUsagePct != 40 || after.AccountLiqDistancePct != 25 {
+		t.Fatalf("after=%+v", after)
+	}
+	if after.AvgCorrelation <= 0 || after.DiversificationRatio < 1 || len(after.Positions) != 2 {
+		t.Fatalf("concentration=%+v", after)
+	}
+	if res.Before.Positions[0].LiquidationDistancePct != 10 || res.Before.Positions[0].LiquidationEstimated {
+		t.Fatalf("before liq=%+v", res.Before.Positions[0])
+	}
+
+	// 反向对冲：VaR 下降，不可能超预算
+	hedge, err := SimulateWhatIf(context.Background(), WhatIfReq{
+		Orders: []WhatIfOrder{{Symbol: "ETHUSDT", Side: "SELL", QuoteQuantity: 300, Leverage: 10}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hedge.MarginalVar >= 0 || hedge.After.AvgCorrelation >= 0 {
+		t.Fatalf("hedge=%+v", hedge)
+	}
+
+	// 预算仅略高于当前 VaR：增量超出剩余预算
+	varMu.Lock()
+	varCfg.RiskBudgetUSDT = res.Before.Var + res.MarginalVar/2
+	varMu.Unlock()
+	res, err = SimulateWhatIf(context.Background(), WhatIfReq{
+		Orders: []WhatIfOrder{{Symbol: "ETHUSDT", Side: "BUY", QuoteQuantity: 300, Leverage: 10}},
+	})
+	if err != nil || !res.ExceedsBudget || res.RemainingBudget <= 0 {
+		t.Fatalf("expected budget breach: res=%+v err=%v", res, err)
+	}
+}
+
+func resetPreTradeVarCache() {
+	preTradeVarMu.Lock()
+	preTradeVarCache = nil
+	preTradeVarMu.Unlock()
+}
+
+func TestCheckPreTradeMarginalVar(t *testing.T) {
+	withPreTradeConfig(t, PreTradeConfig{FatFingerBandPct: -1})
+	positions := []*futures.PositionRisk{
+		{Symbol: "BTCUSDT", PositionSide: "BOTH", PositionAmt: "0.1", MarkPrice: "50000", Leverage: "10"},
+	}
+	withWhatIfMarket(t, positions, 2000, 1e6)
+
+	book, err := buildPreTradeVarBook(context.Background(), []string{"ETHUSDT"})
+	if err != nil {
+		t.Fatalf("build book: %v", err)
+	}
+	marginal, _ := book.marginal(book.col["ETHUSDT"], 1000)
+	if book.baseVar <= 0 || marginal <= 0 {
+		t.Fatalf("baseVar=%v marginal=%v", book.baseVar, marginal)
+	}
+
+	builds := 0
+	origBuilder := preTradeVarBookBuilder
+	preTradeVarBookBuilder = func(ctx context.Context, extra []string) (*preTradeVarBook, error) {
+		builds++
+		return buildPreTradeVarBook(ctx, extra)
+	}
+	defer func() { preTradeVarBookBuilder = origBuilder }()
+
+	// 预算只够半笔增量：开多 ETH 拒单，对冲方向的空单放行
+	varMu.Lock()
+	varCfg.RiskBudgetUSDT = book.baseVar + marginal/2
+	varMu.Unlock()
+	req := PlaceOrderReq{Source: "manual", Symbol: "ethusdt", Side: futures.SideTypeBuy, QuoteQuantity: "100", Leverage: 10}
+	if code := rejectionCode(CheckPreTrade(req)); code != PreTradeMarginalVar {
+		t.Fatalf("code=%q want %s", code, PreTradeMarginalVar)
+	}
+	hedge := req
+	hedge.Side = futures.SideTypeSell
+	if err := CheckPreTrade(hedge); err != nil {
+		t.Fatalf("hedge rejected: %v", err)
+	}
+	if builds != 1 {
+		t.Fatalf("snapshot should be reused, builds=%d", builds)
+	}
+
+	// 放行的单计入快照：连续小单累计到预算后被拦截
+	resetPreTradeVarCache()
+	varMu.Lock()
+	varCfg.RiskBudgetUSDT = book.baseVar + marginal*2.5
+	varMu.Unlock()
+	accepted := 0
+	for i := 0; i < 10 && CheckPreTrade(req) == nil; i++ {
+		accepted++
+	}
+	if accepted < 2 || accepted >= 10 {
+		t.Fatalf("accepted=%d, budget should cap consecutive orders", accepted)
+	}
+
+	// 快照不可用时放行
+	resetPreTradeVarCache()
+	preTradeVarBookBuilder = func(context.Context, []string) (*preTradeVarBook, error) {
+		return nil, fmt.Errorf("klines unavailable")
+	}
+	if err := CheckPreTrade(req); err != nil {
+		t.Fatalf("should fail open: %v", err)
+	}
+}
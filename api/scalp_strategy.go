@@ -84,6 +84,7 @@ type ScalpStatus struct {
 
 	LastError   string `json:"lastError"`
 	LastCheckAt string `json:"lastCheckAt"`
+	Basket      string `json:"basket,omitempty"` // 所属篮子
 }
 
 type scalpState struct {
@@ -123,6 +124,8 @@ type scalpState struct {
 	LastCheckAt time.Time
 	LastTradeAt time.Time // 冷却计时
 
+	Basket string // 所属篮子名称，空表示独立运行
+
 	stopC chan struct{}
 }
 
@@ -136,6 +139,42 @@ func StartScalp(config ScalpConfig) error {
 	if config.Symbol == "" {
 		return fmt.Errorf("symbol is required")
 	}
+	if err := normalizeScalpConfig(&config); err != nil {
+		return err
+	}
+
+	scalpMu.Lock()
+	defer scalpMu.Unlock()
+
+	if existing, ok := scalpTasks[config.Symbol]; ok && existing.Active {
+		return fmt.Errorf("scalp already running for %s", config.Symbol)
+	}
+
+	state := newScalpState(config)
+	scalpTasks[config.Symbol] = state
+
+	go scalpLoop(state)
+
+	log.Printf("[Scalp] Started for %s: EMA(%d/%d/%d), RSI(%d), amount=%s, lev=%dx",
+		config.Symbol, config.EMAFast, config.EMASlow, config.EMATrend,
+		config.RSIPeriod, config.AmountPerOrder, config.Leverage)
+
+	SaveStrategyState("scalp", config.Symbol, config)
+	return nil
+}
+
+func newScalpState(config ScalpConfig) *scalpState {
+	return &scalpState{
+		Config:    config,
+		Active:    true,
+		Direction: "FLAT",
+		DailyDate: time.Now().Format("2006-01-02"),
+		stopC:     make(chan struct{}),
+	}
+}
+
+// normalizeScalpConfig 校验参数并填充默认值（不含 symbol）
+func normalizeScalpConfig(config *ScalpConfig) error {
 	if config.Leverage <= 0 {
 		return fmt.Errorf("leverage must be > 0")
 	}
@@ -180,30 +219,6 @@ func StartScalp(config ScalpConfig) error {
 	if config.ATRMultiplier <= 0 {
 		config.ATRMultiplier = 1.5
 	}
-
-	scalpMu.Lock()
-	defer scalpMu.Unlock()
-
-	if existing, ok := scalpTasks[config.Symbol]; ok && existing.Active {
-		return fmt.Errorf("scalp already running for %s", config.Symbol)
-	}
-
-	state := &scalpState{
-		Config:    config,
-		Active:    true,
-		Direction: "FLAT",
-		DailyDate: time.Now().Format("2006-01-02"),
-		stopC:     make(chan struct{}),
-	}
-	scalpTasks[config.Symbol] = state
-
-	go scalpLoop(state)
-
-	log.Printf("[Scalp] Started for %s: EMA(%d/%d/%d), RSI(%d), amount=%s, lev=%dx",
-		config.Symbol, config.EMAFast, config.EMASlow, config.EMATrend,
-		config.RSIPeriod, config.AmountPerOrder, config.Leverage)
-
-	SaveStrategyState("scalp", config.Symbol, config)
 	return nil
 }
 
@@ -274,6 +289,7 @@ func GetScalpStatus(symbol string) *ScalpStatus {
 		LossCount:   state.LossCount,
 		LastError:   state.LastError,
 		LastCheckAt: lastCheck,
+		Basket:      state.Basket,
 	}
 }
 
@@ -630,6 +646,17 @@ func scalpOpenPosition(ctx context.Context, state *scalpState, signal string, re
 	currentPrice := state.CurrentPrice
 	scalpMu.Unlock()
 
+	// 篮子模式：并发持仓上限 / 相关性去重 / 共享日亏损预算
+	if err := basketAllowOpen(state.Basket, cfg.Symbol, direction); err != nil {
+		scalpMu.Lock()
+		state.LastError = err.Error()
+		scalpMu.Unlock()
+		log.Printf("[Scalp] %s open %s blocked by basket %s: %v", cfg.Symbol, direction, state.Basket, err)
+		return
+	}
+	// 成交后 Direction 已写入，失败时归还名额
+	defer basketReleaseOpen(state.Basket, cfg.Symbol)
+
 	// ATR 动态仓位计算
 	amount := cfg.AmountPerOrder
 	if cfg.ATRSizing && atr > 0 && currentPrice > 0 {
@@ -791,6 +818,7 @@ func scalpClosePosition(ctx context.Context, state *scalpState, direction string
 		state.LossCount++
 	}
 	scalpMu.Unlock()
+	basketRecordPnl(state.Basket, cfg.Symbol, pnl)
 
 	log.Printf("[Scalp] Closed %s for %s: PnL=%.4f, dailyPnl=%.4f, totalPnl=%.4f, reason=%s",
 		direction, cfg.Symbol, pnl, state.DailyPnl, state.TotalPnl, tpslReason)
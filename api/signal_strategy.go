@@ -62,6 +62,8 @@ type SignalStatus struct {
 	TotalPnl    float64      `json:"totalPnl"`    // 总盈亏
 	LastError   string       `json:"lastError"`
 	LastCheckAt string       `json:"lastCheckAt"`
+	Direction   string       `json:"direction,omitempty"` // 当前持仓方向 LONG / SHORT
+	Basket      string       `json:"basket,omitempty"`    // 所属篮子
 }
 
 type signalState struct {
@@ -78,6 +80,8 @@ type signalState struct {
 	TotalPnl    float64
 	LastError   string
 	LastCheckAt time.Time
+	Direction   string // 最近一次开仓方向，持仓清空后重置
+	Basket      string // 所属篮子名称，空表示独立运行
 	stopC       chan struct{}
 }
 
@@ -91,14 +95,49 @@ func StartSignalStrategy(config SignalConfig) error {
 	if config.Symbol == "" {
 		return fmt.Errorf("symbol is required")
 	}
+	normalizeSignalConfig(&config)
+	if err := validateSignalConfig(config); err != nil {
+		return err
+	}
+
+	signalMu.Lock()
+	defer signalMu.Unlock()
+
+	if existing, ok := signalTasks[config.Symbol]; ok && existing.Active {
+		return fmt.Errorf("signal strategy already running for %s, stop it first", config.Symbol)
+	}
+
+	state := &signalState{
+		Config: config,
+		Active: true,
+		stopC:  make(chan struct{}),
+	}
+	signalTasks[config.Symbol] = state
+
+	go signalLoop(state)
+
+	log.Printf("[Signal] Started for %s: interval=%s, RSI(%d) ob=%.0f/os=%.0f, vol(%d) multi=%.1f",
+		config.Symbol, config.Interval, config.RSIPeriod,
+		config.RSIOverbought, config.RSIOversold,
+		config.VolumePeriod, config.VolumeMulti)
+
+	SaveStrategyState("signal", config.Symbol, config)
+	return nil
+}
+
+// validateSignalConfig 校验必填参数（不含 symbol）
+func validateSignalConfig(config SignalConfig) error {
 	if config.Leverage <= 0 {
 		return fmt.Errorf("leverage must be > 0")
 	}
 	if config.AmountPerOrder == "" {
 		return fmt.Errorf("amountPerOrder is required")
 	}
+	return nil
+}
 
-	// 默认值
+// normalizeSignalConfig 填充默认值
+func normalizeSignalConfig(config *SignalConfig) {
 	if config.Interval == "" {
 		config.Interval = "15m"
 	}
@@ -120,30 +159,6 @@ func StartSignalStrategy(config SignalConfig) error {
 	if config.MaxPositions <= 0 {
 		config.MaxPositions = 1
 	}
-
-	signalMu.Lock()
-	defer signalMu.Unlock()
-
-	if existing, ok := signalTasks[config.Symbol]; ok && existing.Active {
-		return fmt.Errorf("signal strategy already running for %s, stop it first", config.Symbol)
-	}
-
-	state := &signalState{
-		Config: config,
-		Active: true,
-		stopC:  make(chan struct{}),
-	}
-	signalTasks[config.Symbol] = state
-
-	go signalLoop(state)
-
-	log.Printf("[Signal] Started for %s: interval=%s, RSI(%d) ob=%.0f/os=%.0f, vol(%d) multi=%.1f",
-		config.Symbol, config.Interval, config.RSIPeriod,
-		config.RSIOverbought, config.RSIOversold,
-		config.VolumePeriod, config.VolumeMulti)
-
-	SaveStrategyState("signal", config.Symbol, config)
-	return nil
 }
 
 // StopSignalStrategy 停止策略
@@ -198,6 +213,8 @@ func GetSignalStatus(symbol string) *SignalStatus {
 		TotalPnl:    math.Round(state.TotalPnl*10000) / 10000,
 		LastError:   state.LastError,
 		LastCheckAt: lastCheck,
+		Direction:   state.Direction,
+		Basket:      state.Basket,
 	}
 }
 
@@ -367,6 +384,17 @@ func signalOpenPosition(ctx context.Context, state *signalState, signal string)
 		posSide = futures.PositionSideTypeShort
 	}
 
+	// 篮子模式：并发持仓上限 / 相关性去重 / 共享日亏损预算
+	if err := basketAllowOpen(state.Basket, cfg.Symbol, string(posSide)); err != nil {
+		signalMu.Lock()
+		state.LastError = err.Error()
+		signalMu.Unlock()
+		log.Printf("[Signal] %s open %s blocked by basket %s: %v", cfg.Symbol, posSide, state.Basket, err)
+		return
+	}
+	// 成交后 OpenTrades 已写入，失败时归还名额
+	defer basketReleaseOpen(state.Basket, cfg.Symbol)
+
 	log.Printf("[Signal] Opening %s position for %s: amount=%s USDT, leverage=%dx",
 		signal, cfg.Symbol, cfg.AmountPerOrder, cfg.Leverage)
 
@@ -401,6 +429,7 @@ func signalOpenPosition(ctx context.Context, state *signalState, signal string)
 	signalMu.Lock()
 	state.OpenTrades++
 	state.TotalTrades++
+	state.Direction = string(posSide)
 	state.LastError = ""
 	signalMu.Unlock()
 
@@ -465,11 +494,13 @@ func signalCheckExit(ctx context.Context, state *signalState, currentRSI float64
 
 		signalMu.Lock()
 		state.OpenTrades--
-		if state.OpenTrades < 0 {
+		if state.OpenTrades <= 0 {
 			state.OpenTrades = 0
+			state.Direction = ""
 		}
 		state.TotalPnl += pnl
 		signalMu.Unlock()
+		basketRecordPnl(state.Basket, cfg.Symbol, pnl)
 
 		log.Printf("[Signal] Closed %s for %s: PnL=%.4f, totalPnl=%.4f",
 			posSide, cfg.Symbol, pnl, state.TotalPnl)
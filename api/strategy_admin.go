@@ -79,10 +79,16 @@ func stopStrategyByType(strategyType, symbol string) error {
 		return StopSignalStrategy(symbol)
 	case "doji":
 		return StopDojiStrategy(symbol)
+	case "basket":
+		return StopBasket(symbol)
+	case "pairs":
+		return StopPairs(symbol)
 	case "dca":
 		return StopDCA(symbol)
 	case "autoscale":
 		return StopAutoScale(symbol)
+	case "strategy_link":
+		return StopStrategyLink()
 	default:
 		return fmt.Errorf("unsupported strategy type: %s", strategyType)
 	}
@@ -0,0 +1,728 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// ========== 多币种篮子模式 ==========
+// 一份 scalp / signal 配置应用到一组币种（手动列表或按 24h 成交额取前 N），
+// 由一个 goroutine 轮询全部成员，开仓前统一经过篮子风控：
+// 并发持仓上限、相关性去重（高相关同向仓位只留一个）、共享日亏损预算。
+
+const (
+	basketStrategyScalp  = "scalp"
+	basketStrategySignal = "signal"
+
+	basketMaxSymbols      = 30
+	basketCorrRefresh     = time.Hour
+	basketDefaultCorrThld = 0.8
+)
+
+// BasketConfig 篮子配置
+type BasketConfig struct {
+	Name     string `json:"name"`     // 篮子名称，默认 <strategy>-basket
+	Strategy string `json:"strategy"` // scalp / signal
+
+	// 币种来源：symbols 优先；否则按 24h 成交额取前 topN 个 USDT 永续
+	Symbols        []string `json:"symbols,omitempty"`
+	TopN           int      `json:"topN,omitempty"`
+	MinQuoteVolume float64  `json:"minQuoteVolume,omitempty"` // topN 模式下最小 24h 成交额(USDT)
+	Exclude        []string `json:"exclude,omitempty"`
+
+	// 篮子风控
+	MaxConcurrent        int     `json:"maxConcurrent,omitempty"`        // 最大同时持仓币种数，默认 3
+	CorrelationThreshold float64 `json:"correlationThreshold,omitempty"` // 相关系数阈值，默认 0.8；>=1 关闭去重
+	CorrelationInterval  string  `json:"correlationInterval,omitempty"`  // 相关性 K 线周期，默认 1h
+	CorrelationLimit     int     `json:"correlationLimit,omitempty"`     // 相关性 K 线数量，默认 100
+	MaxDailyLoss         float64 `json:"maxDailyLoss,omitempty"`         // 全篮子共享日亏损预算(USDT)，0 不限
+
+	// 策略模板（symbol 字段忽略）
+	Scalp  *ScalpConfig  `json:"scalp,omitempty"`
+	Signal *SignalConfig `json:"signal,omitempty"`
+}
+
+// BasketPosition 篮子内的持仓
+type BasketPosition struct {
+	Symbol    string `json:"symbol"`
+	Direction string `json:"direction"`
+}
+
+// BasketStatus 篮子状态
+type BasketStatus struct {
+	Config        BasketConfig       `json:"config"`
+	Active        bool               `json:"active"`
+	Symbols       []string           `json:"symbols"`
+	Positions     []BasketPosition   `json:"positions"`
+	DailyPnl      float64            `json:"dailyPnl"`
+	DailyDate     string             `json:"dailyDate"`
+	BudgetLeft    float64            `json:"budgetLeft,omitempty"` // 剩余日亏损预算
+	SymbolPnl     map[string]float64 `json:"symbolPnl"`            // 今日各币种已实现盈亏
+	Blocked       map[string]int     `json:"blocked"`              // 各原因被拦截的开仓次数
+	CorrelationAt string             `json:"correlationAt,omitempty"`
+	LastBlock     string             `json:"lastBlock,omitempty"`
+	LastCheckAt   string             `json:"lastCheckAt,omitempty"`
+}
+
+type basketState struct {
+	Config  BasketConfig
+	Active  bool
+	Symbols []string
+
+	DailyPnl  float64
+	DailyDate string
+	SymbolPnl map[string]float64
+	Blocked   map[string]int
+	LastBlock string
+
+	corr        map[string]float64 // "A|B" -> 相关系数
+	corrAt      time.Time
+	LastCheckAt time.Time
+
+	pending map[string]string // 已放行、尚未成交或失败的开仓 symbol -> 方向，占用并发名额
+
+	stopC chan struct{}
+}
+
+// basketRuntime 篮子运行时快照：共享日盈亏随重启恢复，避免重启清零日亏损预算
+type basketRuntime struct {
+	DailyDate string             `json:"dailyDate"`
+	DailyPnl  float64            `json:"dailyPnl"`
+	SymbolPnl map[string]float64 `json:"symbolPnl,omitempty"`
+	SavedAt   time.Time          `json:"savedAt"`
+}
+
+var (
+	basketTasks = make(map[string]*basketState)
+	basketMu    sync.Mutex
+)
+
+// StartBasket 启动篮子
+func StartBasket(config BasketConfig) error {
+	return startBasket(config, nil)
+}
+
+// RecoverBasket 重启恢复：当日的共享日盈亏从运行时快照恢复
+func RecoverBasket(config BasketConfig) error {
+	var rt basketRuntime
+	if !LoadStrategyRuntime("basket", config.Name, &rt) || rt.DailyDate != tradingDay(time.Now()) {
+		return startBasket(config, nil)
+	}
+	log.Printf("[Basket] Recovering %s daily pnl %.4f from snapshot at %s",
+		config.Name, rt.DailyPnl, rt.SavedAt.Format(time.RFC3339))
+	return startBasket(config, &rt)
+}
+
+func startBasket(config BasketConfig, rt *basketRuntime) error {
+	config.Strategy = strings.ToLower(strings.TrimSpace(config.Strategy))
+	switch config.Strategy {
+	case basketStrategyScalp:
+		if config.Scalp == nil {
+			return fmt.Errorf("scalp config is required")
+		}
+		if err := normalizeScalpConfig(config.Scalp); err != nil {
+			return err
+		}
+	case basketStrategySignal:
+		if config.Signal == nil {
+			return fmt.Errorf("signal config is required")
+		}
+		normalizeSignalConfig(config.Signal)
+		if err := validateSignalConfig(*config.Signal); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("strategy must be scalp or signal")
+	}
+	if config.Name == "" {
+		config.Name = config.Strategy + "-basket"
+	}
+	if len(config.Name) > 20 {
+		return fmt.Errorf("name too long (max 20 chars)")
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 3
+	}
+	if config.CorrelationThreshold <= 0 {
+		config.CorrelationThreshold = basketDefaultCorrThld
+	}
+	if config.CorrelationInterval == "" {
+		config.CorrelationInterval = "1h"
+	}
+	if config.CorrelationLimit <= 0 {
+		config.CorrelationLimit = 100
+	}
+	if len(config.Symbols) == 0 && config.TopN <= 0 {
+		return fmt.Errorf("symbols or topN is required")
+	}
+
+	symbols, err := resolveBasketSymbols(context.Background(), config)
+	if err != nil {
+		return err
+	}
+	if len(symbols) == 0 {
+		return fmt.Errorf("no symbols resolved for basket")
+	}
+
+	basketMu.Lock()
+	if existing, ok := basketTasks[config.Name]; ok && existing.Active {
+		basketMu.Unlock()
+		return fmt.Errorf("basket %s already running", config.Name)
+	}
+	state := &basketState{
+		Config:    config,
+		Active:    true,
+		Symbols:   symbols,
+		DailyDate: tradingDay(time.Now()),
+		SymbolPnl: make(map[string]float64),
+		Blocked:   make(map[string]int),
+		pending:   make(map[string]string),
+		stopC:     make(chan struct{}),
+	}
+	if rt != nil {
+		state.DailyPnl = rt.DailyPnl
+		for sym, pnl := range rt.SymbolPnl {
+			state.SymbolPnl[sym] = pnl
+		}
+	}
+	basketTasks[config.Name] = state
+	basketMu.Unlock()
+
+	if err := registerBasketMembers(state); err != nil {
+		basketMu.Lock()
+		delete(basketTasks, config.Name)
+		basketMu.Unlock()
+		return err
+	}
+
+	go basketLoop(state)
+
+	log.Printf("[Basket] Started %s: strategy=%s, symbols=%v, maxConcurrent=%d, corr>=%.2f, dailyBudget=%.2f",
+		config.Name, config.Strategy, symbols, config.MaxConcurrent, config.CorrelationThreshold, config.MaxDailyLoss)
+
+	SaveStrategyState("basket", config.Name, config)
+	return nil
+}
+
+// registerBasketMembers 为每个币种创建策略状态并登记到对应策略的任务表（状态接口可直接查询）
+func registerBasketMembers(state *basketState) error {
+	cfg := state.Config
+	switch cfg.Strategy {
+	case basketStrategyScalp:
+		scalpMu.Lock()
+		defer scalpMu.Unlock()
+		for _, sym := range state.Symbols {
+			if existing, ok := scalpTasks[sym]; ok && existing.Active {
+				return fmt.Errorf("scalp already running for %s", sym)
+			}
+		}
+		for _, sym := range state.Symbols {
+			mc := *cfg.Scalp
+			mc.Symbol = sym
+			member := newScalpState(mc)
+			member.Basket = cfg.Name
+			scalpTasks[sym] = member
+		}
+	case basketStrategySignal:
+		signalMu.Lock()
+		defer signalMu.Unlock()
+		for _, sym := range state.Symbols {
+			if existing, ok := signalTasks[sym]; ok && existing.Active {
+				return fmt.Errorf("signal strategy already running for %s", sym)
+			}
+		}
+		for _, sym := range state.Symbols {
+			mc := *cfg.Signal
+			mc.Symbol = sym
+			signalTasks[sym] = &signalState{
+				Config: mc,
+				Active: true,
+				Basket: cfg.Name,
+				stopC:  make(chan struct{}),
+			}
+		}
+	}
+	return nil
+}
+
+// StopBasket 停止篮子（不主动平仓，已有仓位交由本地止盈止损处理）
+func StopBasket(name string) error {
+	basketMu.Lock()
+	state, ok := basketTasks[name]
+	if !ok || !state.Active {
+		basketMu.Unlock()
+		return fmt.Errorf("no active basket %s", name)
+	}
+	close(state.stopC)
+	state.Active = false
+	symbols := state.Symbols
+	strategy := state.Config.Strategy
+	basketMu.Unlock()
+
+	switch strategy {
+	case basketStrategyScalp:
+		scalpMu.Lock()
+		for _, sym := range symbols {
+			if m, ok := scalpTasks[sym]; ok && m.Basket == name && m.Active {
+				m.Active = false
+				close(m.stopC)
+			}
+		}
+		scalpMu.Unlock()
+	case basketStrategySignal:
+		signalMu.Lock()
+		for _, sym := range symbols {
+			if m, ok := signalTasks[sym]; ok && m.Basket == name && m.Active {
+				m.Active = false
+				close(m.stopC)
+			}
+		}
+		signalMu.Unlock()
+	}
+
+	log.Printf("[Basket] Stopped %s", name)
+	MarkStrategyStopped("basket", name)
+	return nil
+}
+
+// GetBasketStatus 获取篮子状态
+func GetBasketStatus(name string) *BasketStatus {
+	basketMu.Lock()
+	state, ok := basketTasks[name]
+	if !ok {
+		basketMu.Unlock()
+		return nil
+	}
+	status := &BasketStatus{
+		Config:    state.Config,
+		Active:    state.Active,
+		Symbols:   append([]string(nil), state.Symbols...),
+		DailyPnl:  math.Round(state.DailyPnl*10000) / 10000,
+		DailyDate: state.DailyDate,
+		SymbolPnl: make(map[string]float64, len(state.SymbolPnl)),
+		Blocked:   make(map[string]int, len(state.Blocked)),
+		LastBlock: state.LastBlock,
+	}
+	for k, v := range state.SymbolPnl {
+		status.SymbolPnl[k] = math.Round(v*10000) / 10000
+	}
+	for k, v := range state.Blocked {
+		status.Blocked[k] = v
+	}
+	if state.Config.MaxDailyLoss > 0 {
+		status.BudgetLeft = math.Round((state.Config.MaxDailyLoss+math.Min(state.DailyPnl, 0))*10000) / 10000
+	}
+	if !state.corrAt.IsZero() {
+		status.CorrelationAt = state.corrAt.Format("15:04:05")
+	}
+	if !state.LastCheckAt.IsZero() {
+		status.LastCheckAt = state.LastCheckAt.Format("15:04:05")
+	}
+	strategy, symbols := state.Config.Strategy, state.Symbols
+	basketMu.Unlock()
+
+	for sym, dir := range basketOpenPositions(strategy, name, symbols) {
+		status.Positions = append(status.Positions, BasketPosition{Symbol: sym, Direction: dir})
+	}
+	sort.Slice(status.Positions, func(i, j int) bool { return status.Positions[i].Symbol < status.Positions[j].Symbol })
+	return status
+}
+
+// ========== 篮子主循环 ==========
+
+func basketLoop(state *basketState) {
+	cfg := state.Config
+	ctx := context.Background()
+
+	var leverage int
+	interval := time.Minute
+	if cfg.Strategy == basketStrategyScalp {
+		leverage = cfg.Scalp.Leverage
+	} else {
+		leverage = cfg.Signal.Leverage
+		interval = klineToCheckInterval(cfg.Signal.Interval)
+	}
+	for _, sym := range state.Symbols {
+		if _, err := ChangeLeverage(ctx, sym, leverage); err != nil {
+			log.Printf("[Basket] Warning: set leverage failed for %s: %v", sym, err)
+		}
+		if cfg.Strategy == basketStrategyScalp {
+			_ = GetPriceCache().Subscribe(sym)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	basketTick(ctx, state)
+	for {
+		select {
+		case <-state.stopC:
+			log.Printf("[Basket] Loop stopped for %s", cfg.Name)
+			return
+		case <-ticker.C:
+			basketTick(ctx, state)
+		}
+	}
+}
+
+// basketRollDayLocked 按风控交易日（可配置时区与切换时间）重置共享日盈亏；调用方需持有 basketMu
+func basketRollDayLocked(state *basketState, now time.Time) {
+	if day := tradingDay(now); day != state.DailyDate {
+		state.DailyDate = day
+		state.DailyPnl = 0
+		state.SymbolPnl = make(map[string]float64)
+		state.Blocked = make(map[string]int)
+	}
+}
+
+// basketTick 日切重置、刷新相关性，然后依次驱动每个成员做一次检查
+func basketTick(ctx context.Context, state *basketState) {
+	cfg := state.Config
+
+	basketMu.Lock()
+	state.LastCheckAt = time.Now()
+	basketRollDayLocked(state, state.LastCheckAt)
+	needCorr := cfg.CorrelationThreshold < 1 && len(state.Symbols) >= 2 && time.Since(state.corrAt) > basketCorrRefresh
+	symbols := append([]string(nil), state.Symbols...)
+	basketMu.Unlock()
+
+	if needCorr {
+		refreshBasketCorrelation(state, symbols)
+	}
+
+	for _, sym := range symbols {
+		select {
+		case <-state.stopC:
+			return
+		default:
+		}
+		switch cfg.Strategy {
+		case basketStrategyScalp:
+			scalpMu.Lock()
+			member, ok := scalpTasks[sym]
+			active := ok && member.Active && member.Basket == cfg.Name
+			scalpMu.Unlock()
+			if active {
+				scalpCheck(ctx, member)
+			}
+		case basketStrategySignal:
+			signalMu.Lock()
+			member, ok := signalTasks[sym]
+			active := ok && member.Active && member.Basket == cfg.Name
+			signalMu.Unlock()
+			if active {
+				signalCheck(ctx, member)
+			}
+		}
+	}
+}
+
+func refreshBasketCorrelation(state *basketState, symbols []string) {
+	cfg := state.Config
+	res, err := CalcCorrelation(symbols, cfg.CorrelationInterval, cfg.CorrelationLimit)
+	if err != nil {
+		log.Printf("[Basket] %s correlation refresh failed: %v", cfg.Name, err)
+		return
+	}
+	corr := make(map[string]float64, len(symbols)*len(symbols))
+	for i := range res.Symbols {
+		for j := range res.Symbols {
+			if i != j {
+				corr[basketPairKey(res.Symbols[i], res.Symbols[j])] = res.Matrix[i][j]
+			}
+		}
+	}
+	basketMu.Lock()
+	state.corr = corr
+	state.corrAt = time.Now()
+	basketMu.Unlock()
+}
+
+// ========== 篮子风控 ==========
+
+// basketAllowOpen 成员开仓前的篮子级检查；非篮子成员（name 为空）直接放行。
+// 检查与占位在 basketMu 内完成：放行后该币种计入待成交名额，调用方在成交或失败后须调用 basketReleaseOpen
+func basketAllowOpen(name, symbol, direction string) error {
+	if name == "" {
+		return nil
+	}
+	basketMu.Lock()
+	defer basketMu.Unlock()
+	state, ok := basketTasks[name]
+	if !ok || !state.Active {
+		return nil
+	}
+	basketRollDayLocked(state, time.Now())
+	cfg := state.Config
+	dailyPnl := state.DailyPnl
+
+	// 持有 basketMu 时读取成员持仓（锁顺序 basketMu → scalpMu/signalMu），叠加已放行未成交的开仓
+	open := basketOpenPositions(cfg.Strategy, name, state.Symbols)
+	for sym, dir := range state.pending {
+		open[sym] = dir
+	}
+	delete(open, symbol)
+
+	var reason, detail string
+	switch {
+	case cfg.MaxDailyLoss > 0 && dailyPnl <= -cfg.MaxDailyLoss:
+		reason = "daily_loss"
+		detail = fmt.Sprintf("篮子日亏损 %.2f 达到共享预算 %.2f", dailyPnl, cfg.MaxDailyLoss)
+	case len(open) >= cfg.MaxConcurrent:
+		reason = "max_concurrent"
+		detail = fmt.Sprintf("篮子持仓 %d 已达上限 %d", len(open), cfg.MaxConcurrent)
+	default:
+		if peer, r := basketCorrelatedPeer(state.corr, symbol, direction, open, cfg.CorrelationThreshold); peer != "" {
+			reason = "correlation"
+			detail = fmt.Sprintf("与 %s 持仓(%s)相关系数 %.2f，视为重复敞口", peer, open[peer], r)
+		}
+	}
+	if reason == "" {
+		if state.pending == nil {
+			state.pending = make(map[string]string)
+		}
+		state.pending[symbol] = direction
+		return nil
+	}
+
+	state.Blocked[reason]++
+	state.LastBlock = fmt.Sprintf("%s %s %s: %s", time.Now().Format("15:04:05"), symbol, direction, detail)
+	return fmt.Errorf("basket %s: %s", name, detail)
+}
+
+// basketReleaseOpen 释放 basketAllowOpen 占用的名额；成交后持仓已由成员状态体现，失败时名额归还
+func basketReleaseOpen(name, symbol string) {
+	if name == "" {
+		return
+	}
+	basketMu.Lock()
+	if state, ok := basketTasks[name]; ok {
+		delete(state.pending, symbol)
+	}
+	basketMu.Unlock()
+}
+
+// basketRecordPnl 成员平仓后计入篮子共享日盈亏
+func basketRecordPnl(name, symbol string, pnl float64) {
+	if name == "" {
+		return
+	}
+	basketMu.Lock()
+	state, ok := basketTasks[name]
+	if !ok {
+		basketMu.Unlock()
+		return
+	}
+	basketRollDayLocked(state, time.Now())
+	state.DailyPnl += pnl
+	state.SymbolPnl[symbol] += pnl
+	if limit := state.Config.MaxDailyLoss; limit > 0 && state.DailyPnl <= -limit && state.DailyPnl-pnl > -limit {
+		log.Printf("[Basket] %s daily loss budget exhausted: %.2f <= -%.2f", name, state.DailyPnl, limit)
+		go SendNotify(fmt.Sprintf("篮子 %s 日亏损 %.2f 用尽共享预算 %.2f，暂停开新仓（次日恢复）", name, state.DailyPnl, limit))
+	}
+	rt := basketRuntime{
+		DailyDate: state.DailyDate,
+		DailyPnl:  state.DailyPnl,
+		SymbolPnl: make(map[string]float64, len(state.SymbolPnl)),
+		SavedAt:   time.Now(),
+	}
+	for sym, v := range state.SymbolPnl {
+		rt.SymbolPnl[sym] = v
+	}
+	basketMu.Unlock()
+
+	SaveStrategyRuntime("basket", name, rt)
+}
+
+// basketOpenPositions 篮子成员当前持仓方向 symbol -> LONG/SHORT
+func basketOpenPositions(strategy, name string, symbols []string) map[string]string {
+	open := make(map[string]string)
+	switch strategy {
+	case basketStrategyScalp:
+		scalpMu.Lock()
+		for _, sym := range symbols {
+			if m, ok := scalpTasks[sym]; ok && m.Basket == name && m.Direction != "" && m.Direction != "FLAT" {
+				open[sym] = m.Direction
+			}
+		}
+		scalpMu.Unlock()
+	case basketStrategySignal:
+		signalMu.Lock()
+		for _, sym := range symbols {
+			if m, ok := signalTasks[sym]; ok && m.Basket == name && m.OpenTrades > 0 && m.Direction != "" {
+				open[sym] = m.Direction
+			}
+		}
+		signalMu.Unlock()
+	}
+	return open
+}
+
+// basketCorrelatedPeer 查找与拟开仓构成重复敞口的持仓：正相关且同向，或负相关且反向
+func basketCorrelatedPeer(corr map[string]float64, symbol, direction string, open map[string]string, threshold float64) (string, float64) {
+	if threshold >= 1 || len(corr) == 0 {
+		return "", 0
+	}
+	peers := make([]string, 0, len(open))
+	for sym := range open {
+		peers = append(peers, sym)
+	}
+	sort.Strings(peers)
+	for _, peer := range peers {
+		r, ok := corr[basketPairKey(symbol, peer)]
+		if !ok {
+			continue
+		}
+		sameDir := open[peer] == direction
+		if (r >= threshold && sameDir) || (r <= -threshold && !sameDir) {
+			return peer, r
+		}
+	}
+	return "", 0
+}
+
+func basketPairKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "|" + b
+}
+
+// ========== 币种解析 ==========
+
+// resolveBasketSymbols 手动列表直接使用；否则从交易所信息筛选 USDT 永续，按 24h 成交额取前 N
+func resolveBasketSymbols(ctx context.Context, cfg BasketConfig) ([]string, error) {
+	exclude := make(map[string]bool, len(cfg.Exclude))
+	for _, s := range cfg.Exclude {
+		exclude[strings.ToUpper(strings.TrimSpace(s))] = true
+	}
+
+	if len(cfg.Symbols) > 0 {
+		seen := make(map[string]bool)
+		var out []string
+		for _, s := range cfg.Symbols {
+			s = strings.ToUpper(strings.TrimSpace(s))
+			if s == "" || seen[s] || exclude[s] {
+				continue
+			}
+			seen[s] = true
+			out = append(out, s)
+		}
+		if len(out) > basketMaxSymbols {
+			return nil, fmt.Errorf("too many symbols: %d > %d", len(out), basketMaxSymbols)
+		}
+		return out, nil
+	}
+
+	info, err := getExchangeInfoCached(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("exchange info: %w", err)
+	}
+	tradable := make(map[string]bool)
+	for _, s := range info.Symbols {
+		if s.Status == "TRADING" && s.ContractType == futures.ContractTypePerpetual && s.QuoteAsset == "USDT" {
+			tradable[s.Symbol] = true
+		}
+	}
+	stats, err := Client.NewListPriceChangeStatsService().Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("24h tickers: %w", err)
+	}
+	topN := cfg.TopN
+	if topN > basketMaxSymbols {
+		topN = basketMaxSymbols
+	}
+	return basketTopByVolume(stats, tradable, exclude, cfg.MinQuoteVolume, topN), nil
+}
+
+// basketTopByVolume 按 24h 成交额降序取前 n 个
+func basketTopByVolume(stats []*futures.PriceChangeStats, tradable, exclude map[string]bool, minQuoteVolume float64, n int) []string {
+	type item struct {
+		symbol string
+		volume float64
+	}
+	items := make([]item, 0, len(stats))
+	for _, st := range stats {
+		if st == nil || !tradable[st.Symbol] || exclude[st.Symbol] {
+			continue
+		}
+		vol, _ := strconv.ParseFloat(st.QuoteVolume, 64)
+		if vol <= 0 || vol < minQuoteVolume {
+			continue
+		}
+		items = append(items, item{st.Symbol, vol})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].volume > items[j].volume })
+	if n > len(items) {
+		n = len(items)
+	}
+	out := make([]string, 0, n)
+	for _, it := range items[:n] {
+		out = append(out, it.symbol)
+	}
+	return out
+}
+
+// ========== Handlers ==========
+
+// HandleStartBasket POST /tool/basket/start
+func HandleStartBasket(c context.Context, ctx *app.RequestContext) {
+	var config BasketConfig
+	if err := ctx.BindAndValidate(&config); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if err := StartBasket(config); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	name := config.Name
+	if name == "" {
+		name = strings.ToLower(strings.TrimSpace(config.Strategy)) + "-basket"
+	}
+	ctx.JSON(http.StatusOK, utils.H{"message": "basket started", "data": GetBasketStatus(name)})
+}
+
+// HandleStopBasket POST /tool/basket/stop
+func HandleStopBasket(c context.Context, ctx *app.RequestContext) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if err := StopBasket(req.Name); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"message": "basket stopped", "name": req.Name})
+}
+
+// HandleBasketStatus GET /tool/basket/status?name=scalp-basket
+func HandleBasketStatus(c context.Context, ctx *app.RequestContext) {
+	name := ctx.Query("name")
+	if name == "" {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "name is required"})
+		return
+	}
+	status := GetBasketStatus(name)
+	if status == nil {
+		ctx.JSON(http.StatusOK, utils.H{"data": nil, "message": "no basket " + name})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": status})
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestBasketCorrelatedPeer(t *testing.T) {
+	corr := map[string]float64{
+		basketPairKey("BTCUSDT", "ETHUSDT"): 0.9,
+		basketPairKey("BTCUSDT", "XRPUSDT"): -0.85,
+		basketPairKey("ETHUSDT", "XRPUSDT"): 0.3,
+	}
+	open := map[string]string{"BTCUSDT": "LONG"}
+
+	if peer, _ := basketCorrelatedPeer(corr, "ETHUSDT", "LONG", open, 0.8); peer != "BTCUSDT" {
+		t.Fatalf("same-direction correlated long should be rejected, got %q", peer)
+	}
+	if peer, _ := basketCorrelatedPeer(corr, "ETHUSDT", "SHORT", open, 0.8); peer != "" {
+		t.Fatalf("opposite direction on positive correlation is a hedge, got %q", peer)
+	}
+	if peer, _ := basketCorrelatedPeer(corr, "XRPUSDT", "SHORT", open, 0.8); peer != "BTCUSDT" {
+		t.Fatalf("short on negatively correlated symbol duplicates the long, got %q", peer)
+	}
+	if peer, _ := basketCorrelatedPeer(corr, "ETHUSDT", "LONG", open, 1); peer != "" {
+		t.Fatalf("threshold >= 1 disables de-dup, got %q", peer)
+	}
+}
+
+func TestBasketTopByVolume(t *testing.T) {
+	stats := []*futures.PriceChangeStats{
+		{Symbol: "BTCUSDT", QuoteVolume: "900"},
+		{Symbol: "ETHUSDT", QuoteVolume: "500"},
+		{Symbol: "SOLUSDT", QuoteVolume: "700"},
+		{Symbol: "BTCUSD_PERP", QuoteVolume: "9999"},
+		{Symbol: "DOGEUSDT", QuoteVolume: "50"},
+	}
+	tradable := map[string]bool{"BTCUSDT": true, "ETHUSDT": true, "SOLUSDT": true, "DOGEUSDT": true}
+	got := basketTopByVolume(stats, tradable, map[string]bool{"SOLUSDT": true}, 100, 5)
+	if strings.Join(got, ",") != "BTCUSDT,ETHUSDT" {
+		t.Fatalf("top symbols = %v", got)
+	}
+}
+
+func TestBasketAllowOpen(t *testing.T) {
+	const name = "test-basket"
+	symbols := []string{"AAAUSDT", "BBBUSDT", "CCCUSDT"}
+	basketMu.Lock()
+	basketTasks[name] = &basketState{
+		Config:    BasketConfig{Name: name, Strategy: basketStrategyScalp, MaxConcurrent: 1, CorrelationThreshold: 0.8, MaxDailyLoss: 10},
+		Active:    true,
+		Symbols:   symbols,
+		Blocked:   make(map[string]int),
+		SymbolPnl: make(map[string]float64),
+	}
+	basketMu.Unlock()
+	scalpMu.Lock()
+	for _, sym := range symbols {
+		m := newScalpState(ScalpConfig{Symbol: sym})
+		m.Basket = name
+		scalpTasks[sym] = m
+	}
+	scalpTasks["AAAUSDT"].Direction = "LONG"
+	scalpMu.Unlock()
+	defer func() {
+		basketMu.Lock()
+		delete(basketTasks, name)
+		basketMu.Unlock()
+		scalpMu.Lock()
+		for _, sym := range symbols {
+			delete(scalpTasks, sym)
+		}
+		scalpMu.Unlock()
+	}()
+
+	if err := basketAllowOpen(name, "BBBUSDT", "SHORT"); err == nil {
+		t.Fatalf("max concurrent should block second position")
+	}
+	if err := basketAllowOpen(name, "AAAUSDT", "SHORT"); err != nil {
+		t.Fatalf("reversing own position should pass: %v", err)
+	}
+
+	basketRecordPnl(name, "AAAUSDT", -12)
+	if err := basketAllowOpen(name, "AAAUSDT", "SHORT"); err == nil || !strings.Contains(err.Error(), "预算") {
+		t.Fatalf("daily loss budget should block, got %v", err)
+	}
+	if s := GetBasketStatus(name); s.Blocked["max_concurrent"] != 1 || s.Blocked["daily_loss"] != 1 || len(s.Positions) != 1 {
+		t.Fatalf("status = %+v", s)
+	}
+}
+
+func TestBasketAllowOpenReservesPendingSlot(t *testing.T) {
+	const name = "test-pending"
+	symbols := []string{"DDDUSDT", "EEEUSDT", "FFFUSDT"}
+	basketMu.Lock()
+	basketTasks[name] = &basketState{
+		Config:  BasketConfig{Name: name, Strategy: basketStrategyScalp, MaxConcurrent: 1, CorrelationThreshold: 0.8},
+		Active:  true,
+		Symbols: symbols,
+		Blocked: make(map[string]int),
+		corr:    map[string]float64{basketPairKey("DDDUSDT", "FFFUSDT"): 0.95},
+	}
+	basketMu.Unlock()
+	scalpMu.Lock()
+	for _, sym := range symbols {
+		m := newScalpState(ScalpConfig{Symbol: sym})
+		m.Basket = name
+		scalpTasks[sym] = m
+	}
+	scalpMu.Unlock()
+	defer func() {
+		basketMu.Lock()
+		delete(basketTasks, name)
+		basketMu.Unlock()
+		scalpMu.Lock()
+		for _, sym := range symbols {
+			delete(scalpTasks, sym)
+		}
+		scalpMu.Unlock()
+	}()
+
+	// 第一单放行后尚未成交：名额已被占用
+	if err := basketAllowOpen(name, "DDDUSDT", "LONG"); err != nil {
+		t.Fatalf("first open should pass: %v", err)
+	}
+	if err := basketAllowOpen(name, "EEEUSDT", "LONG"); err == nil {
+		t.Fatal("pending open should count toward max concurrent")
+	}
+
+	// 下单失败释放名额；相关性检查同样计入待成交的开仓
+	basketReleaseOpen(name, "DDDUSDT")
+	basketMu.Lock()
+	basketTasks[name].Config.MaxConcurrent = 3
+	basketMu.Unlock()
+	if err := basketAllowOpen(name, "DDDUSDT", "LONG"); err != nil {
+		t.Fatalf("released slot should be reusable: %v", err)
+	}
+	if err := basketAllowOpen(name, "FFFUSDT", "LONG"); err == nil || !strings.Contains(err.Error(), "DDDUSDT") {
+		t.Fatalf("correlated pending open should block, got %v", err)
+	}
+	if s := GetBasketStatus(name); s.Blocked["max_concurrent"] != 1 || s.Blocked["correlation"] != 1 {
+		t.Fatalf("status = %+v", s)
+	}
+}
+
+func TestBasketRollDayUsesTradingDay(t *testing.T) {
+	if err := setRiskCalendar("Asia/Shanghai", "08:00"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer setRiskCalendar("", "")
+
+	// 北京时间 08:00 切日：UTC 23:59 仍属 10-14，UTC 00:00 起为 10-15
+	state := &basketState{DailyDate: "2026-10-14", DailyPnl: -8, SymbolPnl: map[string]float64{"BTCUSDT": -8}}
+	basketRollDayLocked(state, time.Date(2026, 10, 14, 23, 59, 0, 0, time.UTC))
+	if state.DailyPnl != -8 {
+		t.Fatalf("rolled before trading day boundary: %+v", state)
+	}
+	basketRollDayLocked(state, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC))
+	if state.DailyDate != "2026-10-15" || state.DailyPnl != 0 || len(state.SymbolPnl) != 0 {
+		t.Fatalf("not rolled at trading day boundary: %+v", state)
+	}
+}
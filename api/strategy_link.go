@@ -2,21 +2,23 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/adshao/go-binance/v2/futures"
+	"gorm.io/gorm"
 )
 
 // ========== 多策略联动 ==========
 // 当一个策略产生信号时，自动触发另一个策略的动作
-// 支持的触发源：RSI信号、爆仓突增、资金费率异常
-// 支持的动作：启动网格、平仓/减仓、降杠杆、开仓
+// 触发条件为条件树（and/or/not/n_of_m 组合多个触发源），见 strategy_link_condition.go
+// 支持的动作：启动网格、平仓/减仓、降杠杆、开仓、通知、停止策略，可按顺序执行多个
+// 规则持久化到 strategy_link_rules 表，重启后随策略恢复
 
 // LinkTriggerType 触发源类型
 type LinkTriggerType string
@@ -26,7 +28,17 @@ const (
 	TriggerRSISell     LinkTriggerType = "rsi_sell"     // RSI 超买信号
 	TriggerLiqSpike    LinkTriggerType = "liq_spike"    // 爆仓突增
 	TriggerFundingHigh LinkTriggerType = "funding_high" // 资金费率超高
-	TriggerNewsKeyword LinkTriggerType = "news_keyword" // 新闻关键词命中
+	TriggerNewsKeyword LinkTriggerType = "news_keyword" // 新闻关键词 / 分类命中（params: keywords, direction, minConfidence, minMagnitude）
+
+	TriggerPriceCross   LinkTriggerType = "price_cross"         // 价格穿越 level（params: level, direction=above/below）
+	TriggerSRTouch      LinkTriggerType = "sr_touch"            // 触及最近支撑/阻力（params: side=support/resistance, tolerancePct）
+	TriggerRegimeChange LinkTriggerType = "regime_change"       // 市场状态切换（params: from, to）
+	TriggerVarBreach    LinkTriggerType = "var_breach"          // VaR 超预算（params: usedPct）
+	TriggerOrderFlow    LinkTriggerType = "orderflow_imbalance" // 盘口买卖不平衡（params: threshold, side=buy/sell）
+	TriggerWhaleWall    LinkTriggerType = "whale_wall"          // 大额挂单墙（params: minNotional, side=bid/ask, maxDistancePct）
+	TriggerOIChange     LinkTriggerType = "oi_change"           // 持仓量变化（params: period, lookback, changePct）
+	TriggerPnlThreshold LinkTriggerType = "pnl_threshold"       // 盈亏阈值（params: scope=daily/position, above, below）
+	TriggerStrategyFill LinkTriggerType = "strategy_fill"       // 其他策略成交（params: source, side）
 )
 
 // LinkActionType 动作类型
@@ -38,6 +50,8 @@ const (
 	ActionReducePosition LinkActionType = "reduce_position" // 减仓
 	ActionReduceLeverage LinkActionType = "reduce_leverage" // 降杠杆
 	ActionPlaceOrder     LinkActionType = "place_order"     // 开仓
+	ActionNotify         LinkActionType = "notify"          // 发送通知
+	ActionStopStrategy   LinkActionType = "stop_strategy"   // 停止策略（params: strategyType）
 )
 
 // StrategyLinkRule 联动规则
@@ -46,19 +60,35 @@ type StrategyLinkRule struct {
 	Name    string `json:"name"`
 	Enabled bool   `json:"enabled"`
 
-	// 触发条件
-	Trigger       LinkTriggerType `json:"trigger"`
+	// 触发条件（单触发源写法，兼容旧规则；设置了 Condition 时忽略）
+	Trigger       LinkTriggerType `json:"trigger,omitempty"`
 	TriggerSymbol string          `json:"triggerSymbol,omitempty"` // 触发源币种（RSI 用）
 
-	// 动作
-	Action       LinkActionType `json:"action"`
+	// 动作（单动作写法，兼容旧规则；设置了 Actions 时忽略）
+	Action       LinkActionType `json:"action,omitempty"`
 	ActionSymbol string         `json:"actionSymbol,omitempty"` // 动作目标币种
 
 	// 动作参数（根据 action 不同使用不同字段）
 	ActionParams map[string]string `json:"actionParams,omitempty"`
 
+	// 条件树与顺序动作
+	Condition *LinkCondition `json:"condition,omitempty"`
+	Actions   []LinkAction   `json:"actions,omitempty"`
+
 	// 冷却期（秒），同一规则不重复触发
 	CooldownSec int `json:"cooldownSec,omitempty"`
+
+	DryRun   bool `json:"dryRun,omitempty"`   // 演练：命中时只记录，不执行动作
+	AuditAll bool `json:"auditAll,omitempty"` // 未命中的求值也写入审计表（默认只记录命中）
+}
+
+// LinkAction 规则命中后按顺序执行的单个动作
+type LinkAction struct {
+	Action          LinkActionType    `json:"action"`
+	Symbol          string            `json:"symbol,omitempty"` // 为空时使用触发源币种
+	Params          map[string]string `json:"params,omitempty"`
+	DelaySec        int               `json:"delaySec,omitempty"`        // 执行前等待秒数（最多 60）
+	ContinueOnError bool              `json:"continueOnError,omitempty"` // 失败后是否继续后续动作
 }
 
 // StrategyLinkStatus 联动状态
@@ -75,6 +105,8 @@ type LinkCheckLog struct {
 	RuleName  string `json:"ruleName"`
 	Triggered bool   `json:"triggered"`
 	Detail    string `json:"detail"`
+	Outcome   string `json:"outcome,omitempty"` // fired / failed / dry_run
+	DryRun    bool   `json:"dryRun,omitempty"`
 }
 
 // strategyLinker 全局联动管理器
@@ -85,14 +117,28 @@ type strategyLinker struct {
 	cancel    context.CancelFunc
 	checkLogs []LinkCheckLog
 	lastFired map[string]time.Time // ruleID → 上次触发时间
+
+	edges   map[string]linkEdgeState // 条件节点路径 → 边沿触发状态
+	srCache map[string]linkSRCache   // symbol → 支撑阻力缓存
+	fills   []LinkFillEvent          // 最近成交（strategy_fill 触发源）
 }
 
 var linker = &strategyLinker{
 	lastFired: make(map[string]time.Time),
+	edges:     make(map[string]linkEdgeState),
+	srCache:   make(map[string]linkSRCache),
 }
 
 // StartStrategyLink 启动策略联动
 func StartStrategyLink(rules []StrategyLinkRule) error {
+	if err := normalizeLinkRules(rules); err != nil {
+		return err
+	}
+	if err := saveStrategyLinkRules(rules); err != nil {
+		return fmt.Errorf("persist rules: %w", err)
+	}
+	recentLogs := loadRecentLinkCheckLogs(50)
+
 	linker.mu.Lock()
 	defer linker.mu.Unlock()
 
@@ -102,27 +148,83 @@ func StartStrategyLink(rules []StrategyLinkRule) error {
 		}
 	}
 
-	for i := range rules {
-		if rules[i].ID == "" {
-			rules[i].ID = fmt.Sprintf("rule_%d", i+1)
-		}
-		if rules[i].CooldownSec <= 0 {
-			rules[i].CooldownSec = 300 // 默认 5 分钟冷却
-		}
-	}
-
 	ctx, cancel := context.WithCancel(context.Background())
 	linker.rules = rules
 	linker.active = true
 	linker.cancel = cancel
-	linker.checkLogs = nil
+	linker.checkLogs = recentLogs
+	linker.edges = make(map[string]linkEdgeState)
 
 	go strategyLinkLoop(ctx)
 
+	SaveStrategyState("strategy_link", "*", map[string]int{"ruleCount": len(rules)})
 	log.Printf("[StrategyLink] Started with %d rules", len(rules))
 	return nil
 }
 
+// RecoverStrategyLink 重启后从 DB 读取规则恢复联动
+func RecoverStrategyLink() error {
+	rules, err := LoadStrategyLinkRules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("no persisted strategy link rules")
+	}
+	return StartStrategyLink(rules)
+}
+
+// normalizeLinkRules 补默认值，把旧的单触发/单动作写法转成条件树与动作列表，并校验
+func normalizeLinkRules(rules []StrategyLinkRule) error {
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		r := &rules[i]
+		if r.ID == "" {
+			r.ID = fmt.Sprintf("rule_%d", i+1)
+		}
+		if len(r.ID) > 40 {
+			return fmt.Errorf("rule id %q too long (max 40)", r.ID)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("duplicate rule id %q", r.ID)
+		}
+		seen[r.ID] = true
+		if r.CooldownSec <= 0 {
+			r.CooldownSec = 300 // 默认 5 分钟冷却
+		}
+		if r.Condition == nil && r.Trigger != "" {
+			r.Condition = &LinkCondition{Trigger: r.Trigger, Symbol: r.TriggerSymbol, Params: r.ActionParams}
+		}
+		if len(r.Actions) == 0 && r.Action != "" {
+			r.Actions = []LinkAction{{Action: r.Action, Symbol: r.ActionSymbol, Params: r.ActionParams}}
+		}
+		if r.Condition == nil {
+			return fmt.Errorf("rule %s: condition or trigger is required", r.ID)
+		}
+		if err := validateLinkCondition(r.Condition, 0); err != nil {
+			return fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		if len(r.Actions) == 0 {
+			return fmt.Errorf("rule %s: at least one action is required", r.ID)
+		}
+		for _, a := range r.Actions {
+			if !validLinkAction(a.Action) {
+				return fmt.Errorf("rule %s: unknown action %q", r.ID, a.Action)
+			}
+		}
+	}
+	return nil
+}
+
+func validLinkAction(a LinkActionType) bool {
+	switch a {
+	case ActionStartGrid, ActionClosePosition, ActionReducePosition, ActionReduceLeverage,
+		ActionPlaceOrder, ActionNotify, ActionStopStrategy:
+		return true
+	}
+	return false
+}
+
 // StopStrategyLink 停止策略联动
 func StopStrategyLink() error {
 	linker.mu.Lock()
@@ -135,6 +237,7 @@ func StopStrategyLink() error {
 		linker.cancel()
 	}
 	linker.active = false
+	MarkStrategyStopped("strategy_link", "*")
 	log.Println("[StrategyLink] Stopped")
 	return nil
 }
@@ -151,10 +254,17 @@ func GetStrategyLinkStatus() *StrategyLinkStatus {
 }
 
 // UpdateStrategyLinkRules 更新规则（不中断监控循环）
-func UpdateStrategyLinkRules(rules []StrategyLinkRule) {
+func UpdateStrategyLinkRules(rules []StrategyLinkRule) error {
+	if err := normalizeLinkRules(rules); err != nil {
+		return err
+	}
+	if err := saveStrategyLinkRules(rules); err != nil {
+		return fmt.Errorf("persist rules: %w", err)
+	}
 	linker.mu.Lock()
 	defer linker.mu.Unlock()
 	linker.rules = rules
+	return nil
 }
 
 // strategyLinkLoop 联动主循环
@@ -179,8 +289,9 @@ func checkAllRules(ctx context.Context) {
 	copy(rules, linker.rules)
 	linker.mu.RUnlock()
 
+	cache := newLinkEvalCache()
 	for _, rule := range rules {
-		if !rule.Enabled {
+		if !rule.Enabled || rule.Condition == nil {
 			continue
 		}
 
@@ -192,57 +303,104 @@ func checkAllRules(ctx context.Context) {
 			continue
 		}
 
-		triggered, detail := checkTrigger(ctx, rule)
-		if triggered {
-			logEntry := LinkCheckLog{
-				Time:      time.Now().Format("15:04:05"),
-				RuleID:    rule.ID,
-				RuleName:  rule.Name,
-				Triggered: true,
-				Detail:    detail,
+		ev := evalLinkCondition(ctx, cache, rule.ID, rule.Condition)
+		if !ev.Matched {
+			if rule.AuditAll {
+				recordLinkAudit(rule, linkOutcomeNoMatch, ev.Detail, "")
 			}
+			continue
+		}
 
-			err := executeAction(ctx, rule)
-			if err != nil {
-				logEntry.Detail += fmt.Sprintf(" → 执行失败: %v", err)
-				log.Printf("[StrategyLink] Rule %s triggered but action failed: %v", rule.Name, err)
-			} else {
-				logEntry.Detail += " → 执行成功"
-				log.Printf("[StrategyLink] Rule %s triggered and executed: %s", rule.Name, detail)
-			}
+		detail := ev.Detail
+		logEntry := LinkCheckLog{
+			Time:      time.Now().Format("15:04:05"),
+			RuleID:    rule.ID,
+			RuleName:  rule.Name,
+			Triggered: true,
+			Detail:    detail,
+			DryRun:    rule.DryRun,
+		}
 
-			linker.mu.Lock()
-			linker.lastFired[rule.ID] = time.Now()
-			linker.checkLogs = append(linker.checkLogs, logEntry)
-			if len(linker.checkLogs) > 50 {
-				linker.checkLogs = linker.checkLogs[len(linker.checkLogs)-50:]
-			}
-			linker.mu.Unlock()
+		if rule.DryRun {
+			// 演练模式：只记录本应执行的动作，不下单
+			logEntry.Outcome = linkOutcomeDryRun
+			logEntry.Detail += " → [演练] 将执行 " + describeLinkActions(rule.Actions)
+			log.Printf("[StrategyLink] Rule %s would fire (dry-run): %s", rule.Name, detail)
+			recordLinkAudit(rule, linkOutcomeDryRun, detail, describeLinkActions(rule.Actions))
+		} else if err := executeActions(ctx, rule.Actions, ev); err != nil {
+			logEntry.Outcome = linkOutcomeFailed
+			logEntry.Detail += fmt.Sprintf(" → 执行失败: %v", err)
+			log.Printf("[StrategyLink] Rule %s triggered but action failed: %v", rule.Name, err)
+			recordLinkAudit(rule, linkOutcomeFailed, detail, err.Error())
+		} else {
+			logEntry.Outcome = linkOutcomeFired
+			logEntry.Detail += " → 执行成功"
+			log.Printf("[StrategyLink] Rule %s triggered and executed: %s", rule.Name, detail)
+			recordLinkAudit(rule, linkOutcomeFired, detail, describeLinkActions(rule.Actions))
 		}
+
+		linker.mu.Lock()
+		linker.lastFired[rule.ID] = time.Now()
+		appendLinkCheckLogLocked(logEntry)
+		linker.mu.Unlock()
+	}
+}
+
+func appendLinkCheckLogLocked(entry LinkCheckLog) {
+	linker.checkLogs = append(linker.checkLogs, entry)
+	if len(linker.checkLogs) > 50 {
+		linker.checkLogs = linker.checkLogs[len(linker.checkLogs)-50:]
 	}
 }
 
-// checkTrigger 检查触发条件是否满足
-func checkTrigger(ctx context.Context, rule StrategyLinkRule) (bool, string) {
-	switch rule.Trigger {
+// checkLeafTrigger 检查单个触发源
+func checkLeafTrigger(ctx context.Context, cache *linkEvalCache, path string, leaf *LinkCondition) linkEval {
+	var ok bool
+	var detail string
+	symbol := leaf.Symbol
+	switch leaf.Trigger {
 	case TriggerRSIBuy:
-		return checkRSITrigger(rule, true)
+		ok, detail = checkRSITrigger(leaf, true)
 	case TriggerRSISell:
-		return checkRSITrigger(rule, false)
+		ok, detail = checkRSITrigger(leaf, false)
 	case TriggerLiqSpike:
-		return checkLiqSpikeTrigger(rule)
+		ok, detail = checkLiqSpikeTrigger(leaf)
 	case TriggerFundingHigh:
-		return checkFundingTrigger(rule)
+		ok, detail = checkFundingTrigger(leaf)
 	case TriggerNewsKeyword:
-		return checkNewsKeywordTrigger(rule)
+		ok, detail, symbol = checkNewsKeywordTrigger(ctx, leaf)
+	case TriggerPriceCross:
+		ok, detail = checkPriceCrossTrigger(path, leaf)
+	case TriggerSRTouch:
+		ok, detail = checkSRTouchTrigger(ctx, leaf)
+	case TriggerRegimeChange:
+		ok, detail = checkRegimeChangeTrigger(path, leaf)
+	case TriggerVarBreach:
+		ok, detail = checkVarBreachTrigger(leaf)
+	case TriggerOrderFlow:
+		ok, detail = checkOrderFlowTrigger(cache, leaf)
+	case TriggerWhaleWall:
+		ok, detail = checkWhaleWallTrigger(cache, leaf)
+	case TriggerOIChange:
+		ok, detail = checkOIChangeTrigger(ctx, leaf)
+	case TriggerPnlThreshold:
+		ok, detail = checkPnlThresholdTrigger(ctx, leaf)
+	case TriggerStrategyFill:
+		ok, detail, symbol = checkStrategyFillTrigger(path, leaf)
 	default:
-		return false, "unknown trigger type"
+		return linkEval{Detail: "unknown trigger type"}
+	}
+	ev := linkEval{Matched: ok, Detail: detail}
+	if ok {
+		ev.Symbol = symbol
+		ev.Side = linkSideHint(leaf)
 	}
+	return ev
 }
 
 // checkRSITrigger 检查 RSI 信号
-func checkRSITrigger(rule StrategyLinkRule, isBuy bool) (bool, string) {
-	sym := rule.TriggerSymbol
+func checkRSITrigger(leaf *LinkCondition, isBuy bool) (bool, string) {
+	sym := leaf.Symbol
 	if sym == "" {
 		return false, ""
 	}
@@ -262,7 +420,7 @@ func checkRSITrigger(rule StrategyLinkRule, isBuy bool) (bool, string) {
 }
 
 // checkLiqSpikeTrigger 检查爆仓突增
-func checkLiqSpikeTrigger(rule StrategyLinkRule) (bool, string) {
+func checkLiqSpikeTrigger(leaf *LinkCondition) (bool, string) {
 	store := getLiqStatsStore()
 	if store == nil {
 		return false, ""
@@ -270,7 +428,7 @@ func checkLiqSpikeTrigger(rule StrategyLinkRule) (bool, string) {
 
 	snap := store.snapshot(time.Now().UTC())
 
-	thresholdStr := rule.ActionParams["liqThresholdM"]
+	thresholdStr := leaf.Params["liqThresholdM"]
 	threshold := 50.0 // 默认 50M USD
 	if thresholdStr != "" {
 		if v, err := strconv.ParseFloat(thresholdStr, 64); err == nil {
@@ -292,13 +450,13 @@ func checkLiqSpikeTrigger(rule StrategyLinkRule) (bool, string) {
 }
 
 // checkFundingTrigger 检查资金费率异常
-func checkFundingTrigger(rule StrategyLinkRule) (bool, string) {
+func checkFundingTrigger(leaf *LinkCondition) (bool, string) {
 	snap := GetFundingStatus()
 	if snap == nil || !snap.Active {
 		return false, ""
 	}
 
-	sym := rule.TriggerSymbol
+	sym := leaf.Symbol
 	if sym == "" {
 		// 检查任意超阈值的
 		if len(snap.AlertSymbols) > 0 {
@@ -317,40 +475,81 @@ func checkFundingTrigger(rule StrategyLinkRule) (bool, string) {
 	return false, ""
 }
 
+// executeActions 按顺序执行动作；默认遇错即停，ContinueOnError 的动作失败后继续
+func executeActions(ctx context.Context, actions []LinkAction, ev linkEval) error {
+	var errs []string
+	for i, act := range actions {
+		if act.DelaySec > 0 {
+			delay := act.DelaySec
+			if delay > 60 {
+				delay = 60
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(delay) * time.Second):
+			}
+		}
+		if err := executeAction(ctx, act, ev); err != nil {
+			errs = append(errs, fmt.Sprintf("#%d %s: %v", i+1, act.Action, err))
+			if !act.ContinueOnError {
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // executeAction 执行联动动作
-func executeAction(ctx context.Context, rule StrategyLinkRule) error {
-	switch rule.Action {
+func executeAction(ctx context.Context, act LinkAction, ev linkEval) error {
+	switch act.Action {
 	case ActionStartGrid:
-		return executeStartGrid(ctx, rule)
+		return executeStartGrid(ctx, act, ev)
 	case ActionClosePosition:
-		return executeClosePosition(ctx, rule)
+		return executeClosePosition(ctx, act)
 	case ActionReducePosition:
-		return executeReducePosition(ctx, rule)
+		return executeReducePosition(ctx, act)
 	case ActionReduceLeverage:
-		return executeReduceLeverage(ctx, rule)
+		return executeReduceLeverage(ctx, act)
 	case ActionPlaceOrder:
-		return executePlaceOrder(ctx, rule)
+		return executePlaceOrder(ctx, act, ev)
+	case ActionNotify:
+		msg := act.Params["message"]
+		if msg == "" {
+			msg = "联动规则触发"
+		}
+		SendNotify(fmt.Sprintf("*策略联动* %s\n%s", msg, ev.Detail))
+		return nil
+	case ActionStopStrategy:
+		sym := act.Symbol
+		if sym == "" {
+			sym = ev.Symbol
+		}
+		return stopStrategyByType(act.Params["strategyType"], sym)
 	default:
-		return fmt.Errorf("unknown action: %s", rule.Action)
+		return fmt.Errorf("unknown action: %s", act.Action)
 	}
 }
 
-func executeStartGrid(ctx context.Context, rule StrategyLinkRule) error {
-	sym := rule.ActionSymbol
+func executeStartGrid(ctx context.Context, act LinkAction, ev linkEval) error {
+	sym := act.Symbol
 	if sym == "" {
-		sym = rule.TriggerSymbol
+		sym = ev.Symbol
 	}
-	// 从 actionParams 构建 GridConfig
+	// 从 params 构建 GridConfig
 	gridCount := 5
-	if v, ok := rule.ActionParams["gridCount"]; ok {
+	if v, ok := act.Params["gridCount"]; ok {
 		if n, err := strconv.Atoi(v); err == nil {
 			gridCount = n
 		}
 	}
-	upperStr := rule.ActionParams["upperPrice"]
-	lowerStr := rule.ActionParams["lowerPrice"]
-	amountStr := rule.ActionParams["amountPerGrid"]
-	leverageStr := rule.ActionParams["leverage"]
+	upperStr := act.Params["upperPrice"]
+	lowerStr := act.Params["lowerPrice"]
+	amountStr := act.Params["amountPerGrid"]
+	leverageStr := act.Params["leverage"]
 
 	upper, _ := strconv.ParseFloat(upperStr, 64)
 	lower, _ := strconv.ParseFloat(lowerStr, 64)
@@ -382,22 +581,22 @@ func executeStartGrid(ctx context.Context, rule StrategyLinkRule) error {
 	return StartGrid(config)
 }
 
-func executeClosePosition(ctx context.Context, rule StrategyLinkRule) error {
-	sym := rule.ActionSymbol
+func executeClosePosition(ctx context.Context, act LinkAction) error {
+	sym := act.Symbol
 	if sym == "" {
-		return fmt.Errorf("actionSymbol is required for close_position")
+		return fmt.Errorf("symbol is required for close_position")
 	}
 	_, err := ClosePositionViaWs(ctx, ClosePositionReq{Symbol: sym})
 	return err
 }
 
-func executeReducePosition(ctx context.Context, rule StrategyLinkRule) error {
-	sym := rule.ActionSymbol
+func executeReducePosition(ctx context.Context, act LinkAction) error {
+	sym := act.Symbol
 	if sym == "" {
-		return fmt.Errorf("actionSymbol is required for reduce_position")
+		return fmt.Errorf("symbol is required for reduce_position")
 	}
 	pct := 50.0
-	if v, ok := rule.ActionParams["reducePercent"]; ok {
+	if v, ok := act.Params["reducePercent"]; ok {
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
 			pct = f
 		}
@@ -409,13 +608,13 @@ func executeReducePosition(ctx context.Context, rule StrategyLinkRule) error {
 	return err
 }
 
-func executeReduceLeverage(ctx context.Context, rule StrategyLinkRule) error {
-	sym := rule.ActionSymbol
+func executeReduceLeverage(ctx context.Context, act LinkAction) error {
+	sym := act.Symbol
 	if sym == "" {
-		return fmt.Errorf("actionSymbol is required for reduce_leverage")
+		return fmt.Errorf("symbol is required for reduce_leverage")
 	}
 	targetLev := 3
-	if v, ok := rule.ActionParams["targetLeverage"]; ok {
+	if v, ok := act.Params["targetLeverage"]; ok {
 		if n, err := strconv.Atoi(v); err == nil {
 			targetLev = n
 		}
@@ -424,30 +623,30 @@ func executeReduceLeverage(ctx context.Context, rule StrategyLinkRule) error {
 	return err
 }
 
-func executePlaceOrder(ctx context.Context, rule StrategyLinkRule) error {
-	sym := rule.ActionSymbol
+func executePlaceOrder(ctx context.Context, act LinkAction, ev linkEval) error {
+	sym := act.Symbol
+	if sym == "" {
+		sym = ev.Symbol
+	}
 	if sym == "" {
-		sym = rule.TriggerSymbol
+		return fmt.Errorf("symbol is required for place_order")
 	}
 
-	side := rule.ActionParams["side"]
+	side := act.Params["side"]
 	if side == "" {
-		// RSI buy → BUY, RSI sell → SELL
-		if rule.Trigger == TriggerRSIBuy {
-			side = "BUY"
-		} else if rule.Trigger == TriggerRSISell {
-			side = "SELL"
-		} else {
-			return fmt.Errorf("side is required for place_order")
-		}
+		// 由触发源推断方向（RSI buy → BUY, RSI sell → SELL 等）
+		side = ev.Side
+	}
+	if side == "" {
+		return fmt.Errorf("side is required for place_order")
 	}
 
-	amount := rule.ActionParams["amountPerOrder"]
+	amount := act.Params["amountPerOrder"]
 	if amount == "" {
 		amount = "5"
 	}
 	leverage := 10
-	if v, ok := rule.ActionParams["leverage"]; ok {
+	if v, ok := act.Params["leverage"]; ok {
 		if n, err := strconv.Atoi(v); err == nil {
 			leverage = n
 		}
@@ -472,20 +671,21 @@ func executePlaceOrder(ctx context.Context, rule StrategyLinkRule) error {
 	return err
 }
 
-// checkNewsKeywordTrigger 检查新闻关键词命中
-func checkNewsKeywordTrigger(rule StrategyLinkRule) (bool, string) {
-	kwStr := rule.ActionParams["keywords"]
-	if kwStr == "" {
-		return false, ""
+// checkNewsKeywordTrigger 检查新闻关键词 / 分类命中
+// params: keywords（逗号分隔，可选）、direction=bullish/bearish、minConfidence、minMagnitude；
+// leaf.Symbol 非空时要求新闻涉及该币种，否则以新闻涉及的第一个币种作为动作缺省币种
+func checkNewsKeywordTrigger(ctx context.Context, leaf *LinkCondition) (bool, string, string) {
+	keywords := splitAndTrim(leaf.Params["keywords"], ",")
+	direction := normalizeNewsDirection(leaf.Params["direction"])
+	if leaf.Params["direction"] == "" {
+		direction = ""
 	}
-	keywords := make([]string, 0)
-	for _, kw := range splitAndTrim(kwStr, ",") {
-		if kw != "" {
-			keywords = append(keywords, kw)
-		}
-	}
-	if len(keywords) == 0 {
-		return false, ""
+	minConf := linkParamFloat(leaf.Params, "minConfidence", 0)
+	minMag := linkParamFloat(leaf.Params, "minMagnitude", 0)
+	// 只配置关键词时保持原有纯关键词匹配，配置了方向/门槛/币种时才调用分类
+	needClass := direction != "" || minConf > 0 || minMag > 0 || leaf.Symbol != ""
+	if len(keywords) == 0 && !needClass {
+		return false, "", ""
 	}
 
 	items := GetRecentNews(30)
@@ -494,14 +694,53 @@ func checkNewsKeywordTrigger(rule StrategyLinkRule) (bool, string) {
 		if item.Timestamp.IsZero() || item.Timestamp.Before(cutoff) {
 			continue
 		}
-		title := strings.ToLower(item.Title + " " + item.Summary)
-		for _, kw := range keywords {
-			if strings.Contains(title, strings.ToLower(kw)) {
-				return true, fmt.Sprintf("新闻关键词命中: %s → %s", kw, item.Title)
+		hit := ""
+		if len(keywords) > 0 {
+			text := strings.ToLower(item.Title + " " + item.Summary)
+			for _, kw := range keywords {
+				if strings.Contains(text, strings.ToLower(kw)) {
+					hit = kw
+					break
+				}
+			}
+			if hit == "" {
+				continue
+			}
+		}
+		if !needClass {
+			return true, fmt.Sprintf("新闻关键词命中: %s → %s", hit, item.Title), leaf.Symbol
+		}
+
+		class := ClassifyNews(ctx, newsItem{Title: item.Title, Summary: item.Summary, Source: item.Source})
+		if direction != "" && class.Direction != direction {
+			continue
+		}
+		if class.Confidence < minConf || class.Magnitude < minMag {
+			continue
+		}
+		symbol := leaf.Symbol
+		if symbol != "" {
+			found := false
+			for _, s := range class.Symbols {
+				if strings.EqualFold(s, symbol) {
+					found = true
+					break
+				}
 			}
+			if !found {
+				continue
+			}
+		} else if len(class.Symbols) > 0 {
+			symbol = class.Symbols[0]
+		}
+		detail := fmt.Sprintf("新闻分类命中: %s 强度%.2f 置信度%.2f (%s) → %s",
+			class.Direction, class.Magnitude, class.Confidence, class.Source, item.Title)
+		if hit != "" {
+			detail = "关键词 " + hit + "，" + detail
 		}
+		return true, detail, symbol
 	}
-	return false, ""
+	return false, "", ""
 }
 
 func splitAndTrim(s, sep string) []string {
@@ -536,5 +775,66 @@ func SetGlobalStatsStore(store *liquidationStatsStore) {
 	statsStoreMu.Unlock()
 }
 
-// 为了让 executeAction 能正确编译，确保需要的类型被引用
-var _ = math.Abs
+// ========== 规则持久化 ==========
+
+// StrategyLinkRuleRecord 联动规则（数据库模型），整条规则以 JSON 保存
+type StrategyLinkRuleRecord struct {
+	RuleID    string    `gorm:"type:varchar(40);primaryKey" json:"ruleId"`
+	Name      string    `gorm:"type:varchar(100)" json:"name"`
+	Enabled   bool      `json:"enabled"`
+	Position  int       `json:"position"` // 规则顺序
+	RuleJSON  string    `gorm:"type:text" json:"ruleJson"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (StrategyLinkRuleRecord) TableName() string { return "strategy_link_rules" }
+
+// saveStrategyLinkRules 用新规则集整体替换 DB 中的规则
+func saveStrategyLinkRules(rules []StrategyLinkRule) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		ids := make([]string, 0, len(rules))
+		for i, r := range rules {
+			raw, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			rec := StrategyLinkRuleRecord{RuleID: r.ID, Name: r.Name, Enabled: r.Enabled, Position: i, RuleJSON: string(raw)}
+			if err := tx.Save(&rec).Error; err != nil {
+				return err
+			}
+			ids = append(ids, r.ID)
+		}
+		q := tx.Model(&StrategyLinkRuleRecord{})
+		if len(ids) > 0 {
+			q = q.Where("rule_id NOT IN ?", ids)
+		} else {
+			q = q.Where("1 = 1")
+		}
+		return q.Delete(&StrategyLinkRuleRecord{}).Error
+	})
+}
+
+// LoadStrategyLinkRules 从 DB 读取已保存的联动规则
+func LoadStrategyLinkRules() ([]StrategyLinkRule, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var records []StrategyLinkRuleRecord
+	if err := DB.Order("position ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	rules := make([]StrategyLinkRule, 0, len(records))
+	for _, rec := range records {
+		var r StrategyLinkRule
+		if err := json.Unmarshal([]byte(rec.RuleJSON), &r); err != nil {
+			log.Printf("[StrategyLink] Skip corrupt rule %s: %v", rec.RuleID, err)
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// ========== 联动审计与演练 ==========
+// 每次命中（执行/失败/演练）写入 strategy_link_audits；规则开启 auditAll 时未命中也记录
+// 启动时从审计表回填最近的 checkLogs，重启后仍可看到触发历史
+
+const (
+	linkOutcomeFired   = "fired"
+	linkOutcomeFailed  = "failed"
+	linkOutcomeDryRun  = "dry_run"
+	linkOutcomeNoMatch = "no_match"
+)
+
+// StrategyLinkAudit 联动规则求值/触发审计记录
+type StrategyLinkAudit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	RuleID    string    `gorm:"type:varchar(40);index" json:"ruleId"`
+	RuleName  string    `gorm:"type:varchar(100)" json:"ruleName"`
+	Outcome   string    `gorm:"type:varchar(20);index" json:"outcome"` // fired / failed / dry_run / no_match
+	Detail    string    `gorm:"type:text" json:"detail"`
+	Result    string    `gorm:"type:text" json:"result,omitempty"` // 执行的动作或错误信息
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+func (StrategyLinkAudit) TableName() string { return "strategy_link_audits" }
+
+// recordLinkAudit 异步写入审计记录
+func recordLinkAudit(rule StrategyLinkRule, outcome, detail, result string) {
+	if DB == nil {
+		return
+	}
+	rec := &StrategyLinkAudit{
+		RuleID:   rule.ID,
+		RuleName: rule.Name,
+		Outcome:  outcome,
+		Detail:   detail,
+		Result:   result,
+	}
+	go func() {
+		if err := DB.Create(rec).Error; err != nil {
+			log.Printf("[StrategyLink] Save audit for %s failed: %v", rec.RuleID, err)
+		}
+	}()
+}
+
+// GetLinkAudits 查询审计记录
+func GetLinkAudits(ruleID, outcome string, limit int) ([]StrategyLinkAudit, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	var records []StrategyLinkAudit
+	q := DB.Order("created_at DESC")
+	if ruleID != "" {
+		q = q.Where("rule_id = ?", ruleID)
+	}
+	if outcome != "" {
+		q = q.Where("outcome = ?", outcome)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Find(&records).Error
+	return records, err
+}
+
+// loadRecentLinkCheckLogs 从审计表回填内存中的最近触发日志（按时间正序）
+func loadRecentLinkCheckLogs(limit int) []LinkCheckLog {
+	records, err := GetLinkAudits("", "", limit*2)
+	if err != nil {
+		log.Printf("[StrategyLink] Load audit history failed: %v", err)
+		return nil
+	}
+	var logs []LinkCheckLog
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.Outcome == linkOutcomeNoMatch {
+			continue
+		}
+		logs = append(logs, LinkCheckLog{
+			Time:      r.CreatedAt.Format("15:04:05"),
+			RuleID:    r.RuleID,
+			RuleName:  r.RuleName,
+			Triggered: true,
+			Detail:    r.Detail,
+			Outcome:   r.Outcome,
+			DryRun:    r.Outcome == linkOutcomeDryRun,
+		})
+	}
+	if len(logs) > limit {
+		logs = logs[len(logs)-limit:]
+	}
+	return logs
+}
+
+// describeLinkActions 动作列表的简短描述
+func describeLinkActions(actions []LinkAction) string {
+	parts := make([]string, 0, len(actions))
+	for _, a := range actions {
+		p := string(a.Action)
+		if a.Symbol != "" {
+			p += "(" + a.Symbol + ")"
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, " → ")
+}
+
+// LinkEvaluation 单条规则的一次性演练结果
+type LinkEvaluation struct {
+	RuleID  string `json:"ruleId"`
+	Name    string `json:"name"`
+	Matched bool   `json:"matched"`
+	Detail  string `json:"detail"`
+	Symbol  string `json:"symbol,omitempty"` // 动作缺省币种
+	Side    string `json:"side,omitempty"`   // 推断的开仓方向
+	Actions string `json:"actions"`          // 将执行的动作
+}
+
+// EvaluateLinkRules 对规则做一次性求值，不执行动作、不影响运行中规则的冷却与边沿状态
+// 价格穿越、状态切换等边沿触发源首次求值只建立基准，结果恒为未命中
+func EvaluateLinkRules(ctx context.Context, rules []StrategyLinkRule) ([]LinkEvaluation, error) {
+	if err := normalizeLinkRules(rules); err != nil {
+		return nil, err
+	}
+	cache := newLinkEvalCache()
+	out := make([]LinkEvaluation, 0, len(rules))
+	for _, rule := range rules {
+		path := fmt.Sprintf("eval_%d/%s", time.Now().UnixNano(), rule.ID)
+		ev := evalLinkCondition(ctx, cache, path, rule.Condition)
+		clearLinkEdges(path)
+		out = append(out, LinkEvaluation{
+			RuleID:  rule.ID,
+			Name:    rule.Name,
+			Matched: ev.Matched,
+			Detail:  ev.Detail,
+			Symbol:  ev.Symbol,
+			Side:    ev.Side,
+			Actions: describeLinkActions(rule.Actions),
+		})
+		recordLinkAudit(rule, linkOutcomeDryRun, fmt.Sprintf("[evaluate] matched=%v %s", ev.Matched, ev.Detail), describeLinkActions(rule.Actions))
+	}
+	return out, nil
+}
+
+// clearLinkEdges 清理演练产生的边沿状态
+func clearLinkEdges(prefix string) {
+	linker.mu.Lock()
+	for k := range linker.edges {
+		if strings.HasPrefix(k, prefix) {
+			delete(linker.edges, k)
+		}
+	}
+	linker.mu.Unlock()
+}
+
+// HandleGetLinkAudit GET /tool/link/audit?ruleId=rule_1&outcome=fired&limit=100
+func HandleGetLinkAudit(c context.Context, ctx *app.RequestContext) {
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	records, err := GetLinkAudits(ctx.Query("ruleId"), ctx.Query("outcome"), limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": records})
+}
+
+// HandleEvaluateLinkRules POST /tool/link/evaluate
+// 传入 rules 则演练这些规则，否则演练已保存的规则
+func HandleEvaluateLinkRules(c context.Context, ctx *app.RequestContext) {
+	var req struct {
+		Rules []StrategyLinkRule `json:"rules"`
+	}
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	rules := req.Rules
+	if len(rules) == 0 {
+		saved, err := LoadStrategyLinkRules()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+		rules = saved
+	}
+	if len(rules) == 0 {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": "no rules to evaluate"})
+		return
+	}
+	result, err := EvaluateLinkRules(c, rules)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": result})
+}
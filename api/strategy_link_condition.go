@@ -0,0 +1,578 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// ========== 联动条件树 ==========
+// 组合节点：and / or / not / n_of_m；叶子节点为单个触发源
+// 每轮所有叶子都会求值（不短路），保证价格穿越、状态切换等边沿触发源的状态连续
+
+const linkConditionMaxDepth = 6
+
+// LinkCondition 条件树节点；Op 为空时为叶子
+type LinkCondition struct {
+	Op       string            `json:"op,omitempty"` // and / or / not / n_of_m
+	N        int               `json:"n,omitempty"`  // n_of_m：至少满足的子条件数
+	Children []LinkCondition   `json:"children,omitempty"`
+	Trigger  LinkTriggerType   `json:"trigger,omitempty"`
+	Symbol   string            `json:"symbol,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// LinkFillEvent 策略成交事件（供 strategy_fill 触发源使用）
+type LinkFillEvent struct {
+	Time         time.Time `json:"time"`
+	Source       string    `json:"source"`
+	Symbol       string    `json:"symbol"`
+	Side         string    `json:"side"`
+	PositionSide string    `json:"positionSide"`
+	Price        float64   `json:"price"`
+	Quantity     float64   `json:"quantity"`
+}
+
+// linkEval 条件求值结果；Symbol/Side 为命中叶子给出的动作提示
+type linkEval struct {
+	Matched bool
+	Detail  string
+	Symbol  string
+	Side    string
+}
+
+// linkEdgeState 边沿触发源的上一次观测值
+type linkEdgeState struct {
+	Price  float64
+	Regime string
+	SeenAt time.Time
+}
+
+type linkSRCache struct {
+	resp *SRResponse
+	at   time.Time
+}
+
+// linkEvalCache 单轮检查内共享的行情数据，避免多条规则重复请求盘口
+type linkEvalCache struct {
+	orderFlow map[string]*OrderFlowSnapshot
+}
+
+func newLinkEvalCache() *linkEvalCache {
+	return &linkEvalCache{orderFlow: make(map[string]*OrderFlowSnapshot)}
+}
+
+func (c *linkEvalCache) getOrderFlow(symbol string) (*OrderFlowSnapshot, error) {
+	if snap, ok := c.orderFlow[symbol]; ok {
+		return snap, nil
+	}
+	snap, err := AnalyzeOrderFlow(symbol, 100)
+	if err != nil {
+		return nil, err
+	}
+	c.orderFlow[symbol] = snap
+	return snap, nil
+}
+
+// validateLinkCondition 校验条件树结构
+func validateLinkCondition(c *LinkCondition, depth int) error {
+	if depth > linkConditionMaxDepth {
+		return fmt.Errorf("condition tree too deep (max %d)", linkConditionMaxDepth)
+	}
+	switch strings.ToLower(c.Op) {
+	case "":
+		if !validLinkTrigger(c.Trigger) {
+			return fmt.Errorf("unknown trigger %q", c.Trigger)
+		}
+		return nil
+	case "and", "or":
+		if len(c.Children) == 0 {
+			return fmt.Errorf("%s requires children", c.Op)
+		}
+	case "not":
+		if len(c.Children) != 1 {
+			return fmt.Errorf("not requires exactly one child")
+		}
+	case "n_of_m":
+		if c.N < 1 || c.N > len(c.Children) {
+			return fmt.Errorf("n_of_m requires 1 <= n <= %d", len(c.Children))
+		}
+	default:
+		return fmt.Errorf("unknown op %q", c.Op)
+	}
+	for i := range c.Children {
+		if err := validateLinkCondition(&c.Children[i], depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validLinkTrigger(t LinkTriggerType) bool {
+	switch t {
+	case TriggerRSIBuy, TriggerRSISell, TriggerLiqSpike, TriggerFundingHigh, TriggerNewsKeyword,
+		TriggerPriceCross, TriggerSRTouch, TriggerRegimeChange, TriggerVarBreach, TriggerOrderFlow,
+		TriggerWhaleWall, TriggerOIChange, TriggerPnlThreshold, TriggerStrategyFill:
+		return true
+	}
+	return false
+}
+
+// evalLinkCondition 递归求值条件树；path 用于定位边沿状态
+func evalLinkCondition(ctx context.Context, cache *linkEvalCache, path string, c *LinkCondition) linkEval {
+	return evalLinkTree(path, c, func(leafPath string, leaf *LinkCondition) linkEval {
+		return checkLeafTrigger(ctx, cache, leafPath, leaf)
+	})
+}
+
+// evalLinkTree 组合节点求值，叶子交给 leafFn（实时检查或历史回放）
+func evalLinkTree(path string, c *LinkCondition, leafFn func(path string, leaf *LinkCondition) linkEval) linkEval {
+	op := strings.ToLower(c.Op)
+	if op == "" {
+		return leafFn(path+"/"+string(c.Trigger), c)
+	}
+
+	results := make([]linkEval, len(c.Children))
+	hits := 0
+	for i := range c.Children {
+		results[i] = evalLinkTree(fmt.Sprintf("%s/%d", path, i), &c.Children[i], leafFn)
+		if results[i].Matched {
+			hits++
+		}
+	}
+
+	var matched bool
+	switch op {
+	case "and":
+		matched = hits == len(results)
+	case "or":
+		matched = hits > 0
+	case "not":
+		return linkEval{Matched: !results[0].Matched, Detail: "NOT(" + results[0].Detail + ")"}
+	case "n_of_m":
+		matched = hits >= c.N
+	}
+	if !matched {
+		return linkEval{}
+	}
+	return mergeLinkEvals(op, c.N, len(results), results)
+}
+
+// mergeLinkEvals 汇总命中子条件的描述与动作提示（取第一个给出提示的子条件）
+func mergeLinkEvals(op string, n, m int, results []linkEval) linkEval {
+	var out linkEval
+	out.Matched = true
+	var parts []string
+	for _, r := range results {
+		if !r.Matched {
+			continue
+		}
+		if r.Detail != "" {
+			parts = append(parts, r.Detail)
+		}
+		if out.Symbol == "" {
+			out.Symbol = r.Symbol
+		}
+		if out.Side == "" {
+			out.Side = r.Side
+		}
+	}
+	label := strings.ToUpper(op)
+	if op == "n_of_m" {
+		label = fmt.Sprintf("%d-of-%d", n, m)
+	}
+	out.Detail = label + "[" + strings.Join(parts, "; ") + "]"
+	return out
+}
+
+// linkSideHint 由叶子推断开仓方向
+func linkSideHint(leaf *LinkCondition) string {
+	switch leaf.Trigger {
+	case TriggerRSIBuy:
+		return "BUY"
+	case TriggerRSISell:
+		return "SELL"
+	case TriggerPriceCross:
+		if strings.EqualFold(leaf.Params["direction"], "below") {
+			return "SELL"
+		}
+		return "BUY"
+	case TriggerSRTouch:
+		if strings.EqualFold(leaf.Params["side"], "resistance") {
+			return "SELL"
+		}
+		if strings.EqualFold(leaf.Params["side"], "support") {
+			return "BUY"
+		}
+	case TriggerNewsKeyword:
+		switch normalizeNewsDirection(leaf.Params["direction"]) {
+		case "BULLISH":
+			return "BUY"
+		case "BEARISH":
+			return "SELL"
+		}
+	case TriggerOrderFlow:
+		if strings.EqualFold(leaf.Params["side"], "sell") {
+			return "SELL"
+		}
+		if strings.EqualFold(leaf.Params["side"], "buy") {
+			return "BUY"
+		}
+	}
+	return ""
+}
+
+func linkParamFloat(params map[string]string, key string, def float64) float64 {
+	if v, ok := params[key]; ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+func linkEdge(path string) (linkEdgeState, bool) {
+	linker.mu.RLock()
+	defer linker.mu.RUnlock()
+	st, ok := linker.edges[path]
+	return st, ok
+}
+
+func setLinkEdge(path string, st linkEdgeState) {
+	linker.mu.Lock()
+	linker.edges[path] = st
+	linker.mu.Unlock()
+}
+
+// checkPriceCrossTrigger 价格从 level 一侧穿越到另一侧时触发一次
+func checkPriceCrossTrigger(path string, leaf *LinkCondition) (bool, string) {
+	level := linkParamFloat(leaf.Params, "level", 0)
+	if leaf.Symbol == "" || level <= 0 {
+		return false, ""
+	}
+	price, err := GetPriceCache().GetPrice(leaf.Symbol)
+	if err != nil || price <= 0 {
+		return false, ""
+	}
+	prev, ok := linkEdge(path)
+	setLinkEdge(path, linkEdgeState{Price: price, SeenAt: time.Now()})
+	if !ok || prev.Price <= 0 {
+		return false, ""
+	}
+	below := strings.EqualFold(leaf.Params["direction"], "below")
+	if !below && prev.Price < level && price >= level {
+		return true, fmt.Sprintf("%s 上穿 %.6g (现价 %.6g)", leaf.Symbol, level, price)
+	}
+	if below && prev.Price > level && price <= level {
+		return true, fmt.Sprintf("%s 下穿 %.6g (现价 %.6g)", leaf.Symbol, level, price)
+	}
+	return false, ""
+}
+
+// checkSRTouchTrigger 现价进入最近支撑/阻力区间（含容差）时触发；支撑阻力结果缓存 5 分钟
+func checkSRTouchTrigger(ctx context.Context, leaf *LinkCondition) (bool, string) {
+	if leaf.Symbol == "" {
+		return false, ""
+	}
+	linker.mu.RLock()
+	cached, ok := linker.srCache[leaf.Symbol]
+	linker.mu.RUnlock()
+	if !ok || time.Since(cached.at) > 5*time.Minute {
+		resp, err := GetSRLevels(ctx, leaf.Symbol)
+		if err != nil {
+			return false, ""
+		}
+		cached = linkSRCache{resp: resp, at: time.Now()}
+		linker.mu.Lock()
+		linker.srCache[leaf.Symbol] = cached
+		linker.mu.Unlock()
+	}
+	price, err := GetPriceCache().GetPrice(leaf.Symbol)
+	if err != nil || price <= 0 {
+		price = cached.resp.CurrentPrice
+	}
+	tol := linkParamFloat(leaf.Params, "tolerancePct", 0.2) / 100
+	side := strings.ToLower(leaf.Params["side"])
+
+	touch := func(l *SRLevel) bool {
+		if l == nil {
+			return false
+		}
+		lo, hi := l.ZoneLow, l.ZoneHigh
+		if lo <= 0 || hi <= 0 {
+			lo, hi = l.Price, l.Price
+		}
+		return price >= lo*(1-tol) && price <= hi*(1+tol)
+	}
+	if side != "resistance" && touch(cached.resp.ClosestSupport) {
+		s := cached.resp.ClosestSupport
+		return true, fmt.Sprintf("%s 触及支撑 %.6g (强度 %d)", leaf.Symbol, s.Price, s.Strength)
+	}
+	if side != "support" && touch(cached.resp.ClosestResist) {
+		r := cached.resp.ClosestResist
+		return true, fmt.Sprintf("%s 触及阻力 %.6g (强度 %d)", leaf.Symbol, r.Price, r.Strength)
+	}
+	return false, ""
+}
+
+// checkRegimeChangeTrigger 市场状态发生切换时触发一次，可限定 from/to
+func checkRegimeChangeTrigger(path string, leaf *LinkCondition) (bool, string) {
+	cur, conf, _ := GetCurrentRegime()
+	if cur == "" {
+		return false, ""
+	}
+	prev, ok := linkEdge(path)
+	setLinkEdge(path, linkEdgeState{Regime: string(cur), SeenAt: time.Now()})
+	if !ok || prev.Regime == "" || prev.Regime == string(cur) {
+		return false, ""
+	}
+	if from := leaf.Params["from"]; from != "" && !strings.EqualFold(from, prev.Regime) {
+		return false, ""
+	}
+	if to := leaf.Params["to"]; to != "" && !strings.EqualFold(to, string(cur)) {
+		return false, ""
+	}
+	return true, fmt.Sprintf("市场状态 %s → %s (置信度 %.2f)", prev.Regime, cur, conf)
+}
+
+// checkVarBreachTrigger VaR 超预算，或风险预算占用达到 usedPct
+func checkVarBreachTrigger(leaf *LinkCondition) (bool, string) {
+	snap := GetVarStatus()
+	if snap == nil {
+		return false, ""
+	}
+	used := linkParamFloat(leaf.Params, "usedPct", 0)
+	if snap.Breached || (used > 0 && snap.RiskBudgetUsedPct >= used) {
+		return true, fmt.Sprintf("VaR95 %.2f USDT，预算占用 %.1f%%", snap.TotalVar95, snap.RiskBudgetUsedPct)
+	}
+	return false, ""
+}
+
+// checkOrderFlowTrigger 盘口不平衡度超过阈值
+func checkOrderFlowTrigger(cache *linkEvalCache, leaf *LinkCondition) (bool, string) {
+	if leaf.Symbol == "" {
+		return false, ""
+	}
+	snap, err := cache.getOrderFlow(leaf.Symbol)
+	if err != nil {
+		return false, ""
+	}
+	th := linkParamFloat(leaf.Params, "threshold", imbalanceBuyHeavy)
+	side := strings.ToLower(leaf.Params["side"])
+	if side != "sell" && snap.Imbalance >= th {
+		return true, fmt.Sprintf("%s 买盘不平衡 %.2f", leaf.Symbol, snap.Imbalance)
+	}
+	if side != "buy" && snap.Imbalance <= -th {
+		return true, fmt.Sprintf("%s 卖盘不平衡 %.2f", leaf.Symbol, snap.Imbalance)
+	}
+	return false, ""
+}
+
+// checkWhaleWallTrigger 现价附近出现大额挂单墙
+func checkWhaleWallTrigger(cache *linkEvalCache, leaf *LinkCondition) (bool, string) {
+	if leaf.Symbol == "" {
+		return false, ""
+	}
+	snap, err := cache.getOrderFlow(leaf.Symbol)
+	if err != nil {
+		return false, ""
+	}
+	minNotional := linkParamFloat(leaf.Params, "minNotional", largeWallNotionalThreshold*4)
+	maxDist := linkParamFloat(leaf.Params, "maxDistancePct", 1) / 100
+	price, err := GetPriceCache().GetPrice(leaf.Symbol)
+	if err != nil || price <= 0 {
+		return false, ""
+	}
+	side := strings.ToLower(leaf.Params["side"])
+	find := func(walls []PriceLevel) *PriceLevel {
+		for i := range walls {
+			w := &walls[i]
+			if w.Notional >= minNotional && math.Abs(w.Price-price)/price <= maxDist {
+				return w
+			}
+		}
+		return nil
+	}
+	if side != "ask" {
+		if w := find(snap.LargeBidWalls); w != nil {
+			return true, fmt.Sprintf("%s 买墙 %.6g × %.0f USDT", leaf.Symbol, w.Price, w.Notional)
+		}
+	}
+	if side != "bid" {
+		if w := find(snap.LargeAskWalls); w != nil {
+			return true, fmt.Sprintf("%s 卖墙 %.6g × %.0f USDT", leaf.Symbol, w.Price, w.Notional)
+		}
+	}
+	return false, ""
+}
+
+// checkOIChangeTrigger 持仓量在 lookback 个周期内变化超过 changePct（正数看增加，负数看减少）
+func checkOIChangeTrigger(ctx context.Context, leaf *LinkCondition) (bool, string) {
+	if leaf.Symbol == "" {
+		return false, ""
+	}
+	period := leaf.Params["period"]
+	if period == "" {
+		period = "5m"
+	}
+	lookback := int(linkParamFloat(leaf.Params, "lookback", 12))
+	if lookback < 1 {
+		lookback = 1
+	}
+	threshold := linkParamFloat(leaf.Params, "changePct", 5)
+	if threshold == 0 {
+		return false, ""
+	}
+	stats, err := Client.NewOpenInterestStatisticsService().Symbol(leaf.Symbol).Period(period).Limit(lookback + 1).Do(ctx)
+	if err != nil || len(stats) < 2 {
+		return false, ""
+	}
+	first := mustParseFloat(stats[0].SumOpenInterestValue)
+	last := mustParseFloat(stats[len(stats)-1].SumOpenInterestValue)
+	if first <= 0 {
+		return false, ""
+	}
+	change := (last - first) / first * 100
+	if (threshold > 0 && change >= threshold) || (threshold < 0 && change <= threshold) {
+		return true, fmt.Sprintf("%s 持仓量 %d×%s 变化 %+.2f%%", leaf.Symbol, lookback, period, change)
+	}
+	return false, ""
+}
+
+// checkPnlThresholdTrigger 当日已实现盈亏或指定币种浮动盈亏越过 above/below
+func checkPnlThresholdTrigger(ctx context.Context, leaf *LinkCondition) (bool, string) {
+	var pnl float64
+	var label string
+	if strings.EqualFold(leaf.Params["scope"], "position") {
+		if leaf.Symbol == "" {
+			return false, ""
+		}
+		v, ok := linkPositionPnl(ctx, leaf.Symbol)
+		if !ok {
+			return false, ""
+		}
+		pnl, label = v, leaf.Symbol+" 浮动盈亏"
+	} else {
+		v, _ := GetRiskStatus()["dailyPnl"].(float64)
+		pnl, label = v, "当日已实现盈亏"
+	}
+	if s, ok := leaf.Params["above"]; ok {
+		if th, err := strconv.ParseFloat(s, 64); err == nil && pnl >= th {
+			return true, fmt.Sprintf("%s %.2f ≥ %.2f", label, pnl, th)
+		}
+	}
+	if s, ok := leaf.Params["below"]; ok {
+		if th, err := strconv.ParseFloat(s, 64); err == nil && pnl <= th {
+			return true, fmt.Sprintf("%s %.2f ≤ %.2f", label, pnl, th)
+		}
+	}
+	return false, ""
+}
+
+// linkPositionPnl 币种浮动盈亏；模拟盘按缓存价计算
+func linkPositionPnl(ctx context.Context, symbol string) (float64, bool) {
+	if IsDryRun() {
+		price, err := GetPriceCache().GetPrice(symbol)
+		if err != nil {
+			return 0, false
+		}
+		var pnl float64
+		found := false
+		for _, p := range GetPaperPositions() {
+			if p.Symbol != symbol {
+				continue
+			}
+			found = true
+			if p.Side == "SHORT" {
+				pnl += (p.EntryPrice - price) * p.Quantity
+			} else {
+				pnl += (price - p.EntryPrice) * p.Quantity
+			}
+		}
+		return pnl, found
+	}
+	pos, err := findPosition(ctx, symbol, "")
+	if err != nil {
+		return 0, false
+	}
+	return mustParseFloat(pos.UnRealizedProfit), true
+}
+
+// checkStrategyFillTrigger 指定来源策略有新成交时触发；忽略联动自身的下单
+// 返回的 symbol 为成交币种，供动作缺省使用
+func checkStrategyFillTrigger(path string, leaf *LinkCondition) (bool, string, string) {
+	prev, ok := linkEdge(path)
+	now := time.Now()
+	if !ok {
+		// 首次求值只记录基准时间，不回放历史成交
+		setLinkEdge(path, linkEdgeState{SeenAt: now})
+		return false, "", ""
+	}
+	source := strings.ToLower(leaf.Params["source"])
+	side := strings.ToUpper(leaf.Params["side"])
+
+	linker.mu.RLock()
+	var hit *LinkFillEvent
+	for i := len(linker.fills) - 1; i >= 0; i-- {
+		f := linker.fills[i]
+		if !f.Time.After(prev.SeenAt) {
+			break
+		}
+		if f.Source == "strategy_link" {
+			continue
+		}
+		if source != "" && !strings.HasPrefix(strings.ToLower(f.Source), source) {
+			continue
+		}
+		if leaf.Symbol != "" && f.Symbol != leaf.Symbol {
+			continue
+		}
+		if side != "" && f.Side != side {
+			continue
+		}
+		hit = &f
+		break
+	}
+	linker.mu.RUnlock()
+
+	setLinkEdge(path, linkEdgeState{SeenAt: now})
+	if hit == nil {
+		return false, "", ""
+	}
+	return true, fmt.Sprintf("%s 成交 %s %s %.6g@%.6g", hit.Source, hit.Symbol, hit.Side, hit.Quantity, hit.Price), hit.Symbol
+}
+
+// recordLinkFill 记录下单成交，供 strategy_fill 触发源消费（保留最近 200 条）
+func recordLinkFill(req PlaceOrderReq, order *futures.CreateOrderResponse) {
+	if order == nil {
+		return
+	}
+	price := mustParseFloat(order.AvgPrice)
+	if price <= 0 {
+		price = mustParseFloat(order.Price)
+	}
+	source := req.Source
+	if source == "" {
+		source = "manual"
+	}
+	ev := LinkFillEvent{
+		Time:         time.Now(),
+		Source:       source,
+		Symbol:       req.Symbol,
+		Side:         string(req.Side),
+		PositionSide: string(req.PositionSide),
+		Price:        price,
+		Quantity:     mustParseFloat(order.ExecutedQuantity),
+	}
+	linker.mu.Lock()
+	linker.fills = append(linker.fills, ev)
+	if len(linker.fills) > 200 {
+		linker.fills = linker.fills[len(linker.fills)-200:]
+	}
+	linker.mu.Unlock()
+}
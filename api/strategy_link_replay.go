@@ -0,0 +1,486 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// ========== 联动规则历史回放 ==========
+// 按 K 线逐根求值条件树，统计规则在历史上会触发多少次（计入冷却期）
+// 可回放的触发源：rsi_buy/rsi_sell、price_cross、funding_high、oi_change（交易所仅保留约 30 天）、
+// liq_spike、var_breach、strategy_fill、pnl_threshold(daily)（后四者读取本地 DB 历史）
+// 其余触发源没有历史数据，回放时视为不成立并在结果中列出
+
+const (
+	linkReplayMaxBars  = 3000
+	linkReplayMaxEvent = 200
+)
+
+// LinkReplayReq 回放请求
+type LinkReplayReq struct {
+	Rule     StrategyLinkRule `json:"rule"`
+	Symbol   string           `json:"symbol"`   // 时间轴基准币种，默认取条件树中第一个带币种的叶子
+	Interval string           `json:"interval"` // 默认 1h
+	Bars     int              `json:"bars"`     // 默认 500，最多 3000
+}
+
+// LinkReplayEvent 回放中的一次触发
+type LinkReplayEvent struct {
+	Time   string `json:"time"`
+	Detail string `json:"detail"`
+}
+
+// LinkReplayResult 回放结果
+type LinkReplayResult struct {
+	RuleID      string            `json:"ruleId"`
+	Symbol      string            `json:"symbol"`
+	Interval    string            `json:"interval"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Bars        int               `json:"bars"`
+	Matches     int               `json:"matches"`     // 条件成立的 K 线数
+	Fires       int               `json:"fires"`       // 计入冷却后的触发次数
+	FiresPerDay float64           `json:"firesPerDay"` // 日均触发次数
+	LeafHits    map[string]int    `json:"leafHits"`    // 叶子路径 → 成立次数
+	Unsupported []string          `json:"unsupported,omitempty"`
+	Events      []LinkReplayEvent `json:"events"`
+}
+
+// linkReplayer 回放上下文：按需加载各数据源并缓存
+type linkReplayer struct {
+	ctx      context.Context
+	interval string
+	barDur   time.Duration
+	times    []int64 // 每根 K 线收盘时间（毫秒）
+	bars     int
+
+	closes      map[string][]float64 // symbol → 与 times 对齐的收盘价
+	rsi         map[string][]float64 // symbol|period → 与 times 对齐的 RSI
+	funding     map[string][]fundingPoint
+	oi          map[string][]oiPoint
+	liq         []LiquidationStatRecord
+	vars        []VarSnapshot
+	trades      []TradeRecord
+	dbLoaded    bool
+	unsupported map[string]bool
+	loadErr     error
+}
+
+type fundingPoint struct {
+	Time int64
+	Rate float64
+}
+
+type oiPoint struct {
+	Time  int64
+	Value float64
+}
+
+// ReplayLinkRule 在历史数据上回放规则
+func ReplayLinkRule(ctx context.Context, req LinkReplayReq) (*LinkReplayResult, error) {
+	rules := []StrategyLinkRule{req.Rule}
+	if err := normalizeLinkRules(rules); err != nil {
+		return nil, err
+	}
+	rule := rules[0]
+	if req.Interval == "" {
+		req.Interval = "1h"
+	}
+	if req.Bars <= 0 {
+		req.Bars = 500
+	}
+	if req.Bars > linkReplayMaxBars {
+		req.Bars = linkReplayMaxBars
+	}
+	if req.Symbol == "" {
+		req.Symbol = firstLinkSymbol(rule.Condition)
+	}
+	if req.Symbol == "" {
+		req.Symbol = "BTCUSDT"
+	}
+
+	klines, err := fetchIntervalKlines(ctx, req.Symbol, req.Interval, req.Bars)
+	if err != nil {
+		return nil, fmt.Errorf("fetch klines: %w", err)
+	}
+	if len(klines) < 2 {
+		return nil, fmt.Errorf("not enough klines for %s", req.Symbol)
+	}
+
+	r := &linkReplayer{
+		ctx:         ctx,
+		interval:    req.Interval,
+		barDur:      klineToCheckInterval(req.Interval),
+		closes:      make(map[string][]float64),
+		rsi:         make(map[string][]float64),
+		funding:     make(map[string][]fundingPoint),
+		oi:          make(map[string][]oiPoint),
+		unsupported: make(map[string]bool),
+	}
+	base := make([]float64, len(klines))
+	for i, k := range klines {
+		r.times = append(r.times, k.CloseTime)
+		base[i], _ = strconv.ParseFloat(k.Close, 64)
+	}
+	r.bars = len(r.times)
+	r.closes[req.Symbol] = base
+
+	res := &LinkReplayResult{
+		RuleID:   rule.ID,
+		Symbol:   req.Symbol,
+		Interval: req.Interval,
+		From:     time.UnixMilli(r.times[0]).Format(time.RFC3339),
+		To:       time.UnixMilli(r.times[r.bars-1]).Format(time.RFC3339),
+		Bars:     r.bars,
+		LeafHits: make(map[string]int),
+		Events:   []LinkReplayEvent{},
+	}
+	cooldown := int64(rule.CooldownSec) * 1000
+	var lastFire int64
+	for i := 0; i < r.bars; i++ {
+		ev := evalLinkTree(rule.ID, rule.Condition, func(path string, leaf *LinkCondition) linkEval {
+			e := r.leaf(leaf, req.Symbol, i)
+			if e.Matched {
+				res.LeafHits[path]++
+			}
+			return e
+		})
+		if r.loadErr != nil {
+			return nil, r.loadErr
+		}
+		if !ev.Matched {
+			continue
+		}
+		res.Matches++
+		t := r.times[i]
+		if lastFire > 0 && t-lastFire < cooldown {
+			continue
+		}
+		lastFire = t
+		res.Fires++
+		if len(res.Events) < linkReplayMaxEvent {
+			res.Events = append(res.Events, LinkReplayEvent{Time: time.UnixMilli(t).Format(time.RFC3339), Detail: ev.Detail})
+		}
+	}
+	if days := float64(r.times[r.bars-1]-r.times[0]) / float64(24*time.Hour/time.Millisecond); days > 0 {
+		res.FiresPerDay = roundFloat(float64(res.Fires)/days, 3)
+	}
+	for t := range r.unsupported {
+		res.Unsupported = append(res.Unsupported, t)
+	}
+	sort.Strings(res.Unsupported)
+	return res, nil
+}
+
+// firstLinkSymbol 条件树中第一个带币种的叶子
+func firstLinkSymbol(c *LinkCondition) string {
+	if c == nil {
+		return ""
+	}
+	if c.Op == "" {
+		return c.Symbol
+	}
+	for i := range c.Children {
+		if s := firstLinkSymbol(&c.Children[i]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// leaf 在第 i 根 K 线处求值单个触发源
+func (r *linkReplayer) leaf(leaf *LinkCondition, baseSymbol string, i int) linkEval {
+	sym := leaf.Symbol
+	if sym == "" {
+		sym = baseSymbol
+	}
+	var ok bool
+	var detail string
+	switch leaf.Trigger {
+	case TriggerRSIBuy, TriggerRSISell:
+		ok, detail = r.rsiLeaf(leaf, sym, i)
+	case TriggerPriceCross:
+		ok, detail = r.priceCrossLeaf(leaf, sym, i)
+	case TriggerFundingHigh:
+		ok, detail = r.fundingLeaf(leaf, sym, i)
+	case TriggerOIChange:
+		ok, detail = r.oiLeaf(leaf, sym, i)
+	case TriggerLiqSpike, TriggerVarBreach, TriggerStrategyFill, TriggerPnlThreshold:
+		if !r.loadDB() {
+			r.unsupported[string(leaf.Trigger)+"(no db)"] = true
+			return linkEval{}
+		}
+		ok, detail = r.dbLeaf(leaf, i)
+	default:
+		r.unsupported[string(leaf.Trigger)] = true
+		return linkEval{}
+	}
+	if !ok {
+		return linkEval{}
+	}
+	return linkEval{Matched: true, Detail: detail, Symbol: sym, Side: linkSideHint(leaf)}
+}
+
+// symbolCloses 取与时间轴对齐的收盘价（缺失处沿用上一根）
+func (r *linkReplayer) symbolCloses(sym string) []float64 {
+	if c, ok := r.closes[sym]; ok {
+		return c
+	}
+	klines, err := fetchIntervalKlines(r.ctx, sym, r.interval, r.bars)
+	if err != nil {
+		r.loadErr = fmt.Errorf("fetch klines %s: %w", sym, err)
+		r.closes[sym] = nil
+		return nil
+	}
+	byTime := make(map[int64]float64, len(klines))
+	for _, k := range klines {
+		byTime[k.CloseTime], _ = strconv.ParseFloat(k.Close, 64)
+	}
+	out := make([]float64, r.bars)
+	var last float64
+	for i, t := range r.times {
+		if v, ok := byTime[t]; ok && v > 0 {
+			last = v
+		}
+		out[i] = last
+	}
+	r.closes[sym] = out
+	return out
+}
+
+func (r *linkReplayer) rsiLeaf(leaf *LinkCondition, sym string, i int) (bool, string) {
+	period := int(linkParamFloat(leaf.Params, "rsiPeriod", 14))
+	if period < 2 {
+		period = 14
+	}
+	key := fmt.Sprintf("%s|%d", sym, period)
+	series, ok := r.rsi[key]
+	if !ok {
+		closes := r.symbolCloses(sym)
+		series = make([]float64, len(closes))
+		if len(closes) > period {
+			vals := calcRSI(closes, period)
+			for j, v := range vals {
+				series[j+period] = v
+			}
+		}
+		r.rsi[key] = series
+	}
+	if i >= len(series) || i < period {
+		return false, ""
+	}
+	v := series[i]
+	if leaf.Trigger == TriggerRSIBuy {
+		th := linkParamFloat(leaf.Params, "oversold", 30)
+		if v <= th {
+			return true, fmt.Sprintf("%s RSI=%.1f ≤ %.0f", sym, v, th)
+		}
+		return false, ""
+	}
+	th := linkParamFloat(leaf.Params, "overbought", 70)
+	if v >= th {
+		return true, fmt.Sprintf("%s RSI=%.1f ≥ %.0f", sym, v, th)
+	}
+	return false, ""
+}
+
+func (r *linkReplayer) priceCrossLeaf(leaf *LinkCondition, sym string, i int) (bool, string) {
+	level := linkParamFloat(leaf.Params, "level", 0)
+	closes := r.symbolCloses(sym)
+	if level <= 0 || i == 0 || i >= len(closes) || closes[i-1] <= 0 {
+		return false, ""
+	}
+	prev, cur := closes[i-1], closes[i]
+	if strings.EqualFold(leaf.Params["direction"], "below") {
+		if prev > level && cur <= level {
+			return true, fmt.Sprintf("%s 下穿 %.6g", sym, level)
+		}
+		return false, ""
+	}
+	if prev < level && cur >= level {
+		return true, fmt.Sprintf("%s 上穿 %.6g", sym, level)
+	}
+	return false, ""
+}
+
+// fundingLeaf 最近一次已结算费率绝对值 ≥ thresholdPct（%，默认 0.1）
+func (r *linkReplayer) fundingLeaf(leaf *LinkCondition, sym string, i int) (bool, string) {
+	points, ok := r.funding[sym]
+	if !ok {
+		start := r.times[0] - int64(8*time.Hour/time.Millisecond)
+		for {
+			list, err := Client.NewFundingRateService().Symbol(sym).StartTime(start).Limit(1000).Do(r.ctx)
+			if err != nil {
+				r.loadErr = fmt.Errorf("funding history %s: %w", sym, err)
+				break
+			}
+			for _, f := range list {
+				points = append(points, fundingPoint{Time: f.FundingTime, Rate: mustParseFloat(f.FundingRate)})
+			}
+			if len(list) < 1000 {
+				break
+			}
+			start = list[len(list)-1].FundingTime + 1
+		}
+		r.funding[sym] = points
+	}
+	t := r.times[i]
+	idx := sort.Search(len(points), func(k int) bool { return points[k].Time > t }) - 1
+	if idx < 0 {
+		return false, ""
+	}
+	th := linkParamFloat(leaf.Params, "thresholdPct", 0.1) / 100
+	rate := points[idx].Rate
+	if rate >= th || rate <= -th {
+		return true, fmt.Sprintf("%s 费率 %.4f%%", sym, rate*100)
+	}
+	return false, ""
+}
+
+// oiLeaf 持仓量在 lookback 个统计周期内变化超过 changePct；交易所仅提供最近约 30 天
+func (r *linkReplayer) oiLeaf(leaf *LinkCondition, sym string, i int) (bool, string) {
+	period := leaf.Params["period"]
+	if period == "" {
+		period = r.interval
+	}
+	key := sym + "|" + period
+	points, ok := r.oi[key]
+	if !ok {
+		stats, err := Client.NewOpenInterestStatisticsService().Symbol(sym).Period(period).Limit(500).Do(r.ctx)
+		if err != nil {
+			r.unsupported[string(TriggerOIChange)+"("+period+")"] = true
+		}
+		for _, s := range stats {
+			points = append(points, oiPoint{Time: s.Timestamp, Value: mustParseFloat(s.SumOpenInterestValue)})
+		}
+		r.oi[key] = points
+	}
+	lookback := int(linkParamFloat(leaf.Params, "lookback", 12))
+	threshold := linkParamFloat(leaf.Params, "changePct", 5)
+	if lookback < 1 || threshold == 0 {
+		return false, ""
+	}
+	t := r.times[i]
+	idx := sort.Search(len(points), func(k int) bool { return points[k].Time > t }) - 1
+	if idx < lookback || points[idx-lookback].Value <= 0 {
+		return false, ""
+	}
+	first := points[idx-lookback].Value
+	change := (points[idx].Value - first) / first * 100
+	if (threshold > 0 && change >= threshold) || (threshold < 0 && change <= threshold) {
+		return true, fmt.Sprintf("%s 持仓量变化 %+.2f%%", sym, change)
+	}
+	return false, ""
+}
+
+// loadDB 一次性加载回放区间内的本地历史数据
+func (r *linkReplayer) loadDB() bool {
+	if DB == nil {
+		return false
+	}
+	if r.dbLoaded {
+		return true
+	}
+	r.dbLoaded = true
+	from := time.UnixMilli(r.times[0]).Add(-24 * time.Hour)
+	to := time.UnixMilli(r.times[r.bars-1])
+	DB.Where("bucket_interval = ? AND start_time >= ? AND start_time <= ?", liquidationIntervalH1, from.UnixMilli(), to.UnixMilli()).
+		Order("start_time ASC").Find(&r.liq)
+	DB.Where("created_at >= ? AND created_at <= ?", from, to).Order("created_at ASC").Find(&r.vars)
+	DB.Where("(created_at >= ? AND created_at <= ?) OR (closed_at >= ? AND closed_at <= ?)", from, to, from, to).
+		Order("created_at ASC").Find(&r.trades)
+	return true
+}
+
+func (r *linkReplayer) dbLeaf(leaf *LinkCondition, i int) (bool, string) {
+	t := r.times[i]
+	tt := time.UnixMilli(t)
+	switch leaf.Trigger {
+	case TriggerLiqSpike:
+		th := linkParamFloat(leaf.Params, "liqThresholdM", 50)
+		idx := sort.Search(len(r.liq), func(k int) bool { return r.liq[k].StartTime > t }) - 1
+		if idx < 0 || t-r.liq[idx].StartTime > int64(time.Hour/time.Millisecond) {
+			return false, ""
+		}
+		totalM := r.liq[idx].TotalNotional / 1e6
+		if totalM >= th {
+			return true, fmt.Sprintf("1h爆仓总额 %.1fM USD", totalM)
+		}
+	case TriggerVarBreach:
+		idx := sort.Search(len(r.vars), func(k int) bool { return r.vars[k].CreatedAt.After(tt) }) - 1
+		if idx < 0 {
+			return false, ""
+		}
+		v := r.vars[idx]
+		used := linkParamFloat(leaf.Params, "usedPct", 0)
+		if v.Breached || (used > 0 && v.RiskBudgetUsedPct >= used) {
+			return true, fmt.Sprintf("VaR95 %.2f，预算占用 %.1f%%", v.TotalVar95, v.RiskBudgetUsedPct)
+		}
+	case TriggerStrategyFill:
+		source := strings.ToLower(leaf.Params["source"])
+		side := strings.ToUpper(leaf.Params["side"])
+		prev := tt.Add(-r.barDur)
+		for _, tr := range r.trades {
+			if !tr.CreatedAt.After(prev) || tr.CreatedAt.After(tt) || tr.Source == "strategy_link" {
+				continue
+			}
+			if source != "" && !strings.HasPrefix(strings.ToLower(tr.Source), source) {
+				continue
+			}
+			if leaf.Symbol != "" && tr.Symbol != leaf.Symbol {
+				continue
+			}
+			if side != "" && tr.Side != side {
+				continue
+			}
+			return true, fmt.Sprintf("%s 成交 %s %s", tr.Source, tr.Symbol, tr.Side)
+		}
+	case TriggerPnlThreshold:
+		if strings.EqualFold(leaf.Params["scope"], "position") {
+			r.unsupported[string(TriggerPnlThreshold)+"(position)"] = true
+			return false, ""
+		}
+		dayStart := time.Date(tt.Year(), tt.Month(), tt.Day(), 0, 0, 0, 0, tt.Location())
+		var pnl float64
+		for _, tr := range r.trades {
+			if tr.ClosedAt == nil || tr.ClosedAt.Before(dayStart) || tr.ClosedAt.After(tt) {
+				continue
+			}
+			pnl += tr.RealizedPnl
+		}
+		if s, ok := leaf.Params["above"]; ok {
+			if th, err := strconv.ParseFloat(s, 64); err == nil && pnl >= th {
+				return true, fmt.Sprintf("当日已实现盈亏 %.2f ≥ %.2f", pnl, th)
+			}
+		}
+		if s, ok := leaf.Params["below"]; ok {
+			if th, err := strconv.ParseFloat(s, 64); err == nil && pnl <= th {
+				return true, fmt.Sprintf("当日已实现盈亏 %.2f ≤ %.2f", pnl, th)
+			}
+		}
+	}
+	return false, ""
+}
+
+// HandleReplayLinkRule POST /tool/link/replay
+func HandleReplayLinkRule(c context.Context, ctx *app.RequestContext) {
+	var req LinkReplayReq
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	res, err := ReplayLinkRule(c, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": res})
+}
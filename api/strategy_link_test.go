@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestNormalizeLinkRules_LegacyConversion(t *testing.T) {
+	rules := []StrategyLinkRule{{
+		Enabled:       true,
+		Trigger:       TriggerRSIBuy,
+		TriggerSymbol: "BTCUSDT",
+		Action:        ActionPlaceOrder,
+		ActionSymbol:  "ETHUSDT",
+		ActionParams:  map[string]string{"amountPerOrder": "10"},
+	}}
+	if err := normalizeLinkRules(rules); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := rules[0]
+	if r.ID != "rule_1" || r.CooldownSec != 300 {
+		t.Fatalf("defaults not applied: id=%s cooldown=%d", r.ID, r.CooldownSec)
+	}
+	if r.Condition == nil || r.Condition.Trigger != TriggerRSIBuy || r.Condition.Symbol != "BTCUSDT" {
+		t.Fatalf("condition not converted: %+v", r.Condition)
+	}
+	if len(r.Actions) != 1 || r.Actions[0].Symbol != "ETHUSDT" || r.Actions[0].Params["amountPerOrder"] != "10" {
+		t.Fatalf("actions not converted: %+v", r.Actions)
+	}
+}
+
+func TestNormalizeLinkRules_Validation(t *testing.T) {
+	cases := []StrategyLinkRule{
+		{ID: "a", Condition: &LinkCondition{Op: "n_of_m", N: 3, Children: []LinkCondition{{Trigger: TriggerVarBreach}}}, Actions: []LinkAction{{Action: ActionNotify}}},
+		{ID: "b", Condition: &LinkCondition{Op: "not"}, Actions: []LinkAction{{Action: ActionNotify}}},
+		{ID: "c", Condition: &LinkCondition{Trigger: "bogus"}, Actions: []LinkAction{{Action: ActionNotify}}},
+		{ID: "d", Condition: &LinkCondition{Trigger: TriggerVarBreach}, Actions: []LinkAction{{Action: "bogus"}}},
+		{ID: "e", Condition: &LinkCondition{Trigger: TriggerVarBreach}},
+	}
+	for _, c := range cases {
+		if err := normalizeLinkRules([]StrategyLinkRule{c}); err == nil {
+			t.Fatalf("rule %s: expected validation error", c.ID)
+		}
+	}
+	dup := []StrategyLinkRule{
+		{ID: "x", Trigger: TriggerVarBreach, Action: ActionNotify},
+		{ID: "x", Trigger: TriggerVarBreach, Action: ActionNotify},
+	}
+	if err := normalizeLinkRules(dup); err == nil {
+		t.Fatalf("expected duplicate id error")
+	}
+}
+
+func TestEvalLinkCondition_Combinators(t *testing.T) {
+	// 风控未启用时当日盈亏为 0
+	yes := LinkCondition{Trigger: TriggerPnlThreshold, Params: map[string]string{"above": "-1"}}
+	no := LinkCondition{Trigger: TriggerPnlThreshold, Params: map[string]string{"below": "-1"}}
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		cond LinkCondition
+		want bool
+	}{
+		{"and", LinkCondition{Op: "and", Children: []LinkCondition{yes, no}}, false},
+		{"or", LinkCondition{Op: "or", Children: []LinkCondition{yes, no}}, true},
+		{"not", LinkCondition{Op: "not", Children: []LinkCondition{no}}, true},
+		{"2-of-3", LinkCondition{Op: "n_of_m", N: 2, Children: []LinkCondition{yes, no, yes}}, true},
+		{"3-of-3", LinkCondition{Op: "n_of_m", N: 3, Children: []LinkCondition{yes, no, yes}}, false},
+		{"nested", LinkCondition{Op: "and", Children: []LinkCondition{yes, {Op: "not", Children: []LinkCondition{no}}}}, true},
+	}
+	for _, c := range cases {
+		ev := evalLinkCondition(ctx, newLinkEvalCache(), "test_"+c.name, &c.cond)
+		if ev.Matched != c.want {
+			t.Fatalf("%s: matched=%v want %v (%s)", c.name, ev.Matched, c.want, ev.Detail)
+		}
+	}
+}
+
+func TestStrategyFillTrigger(t *testing.T) {
+	leaf := LinkCondition{Trigger: TriggerStrategyFill, Params: map[string]string{"source": "grid"}}
+	path := "fill_test/strategy_fill"
+
+	// 首次求值只建立基准
+	if ok, _, _ := checkStrategyFillTrigger(path, &leaf); ok {
+		t.Fatalf("first evaluation should not fire")
+	}
+
+	order := &futures.CreateOrderResponse{AvgPrice: "100", ExecutedQuantity: "0.5"}
+	recordLinkFill(PlaceOrderReq{Source: "dca", Symbol: "BTCUSDT", Side: futures.SideTypeBuy}, order)
+	if ok, _, _ := checkStrategyFillTrigger(path, &leaf); ok {
+		t.Fatalf("fill from other source should not fire")
+	}
+
+	recordLinkFill(PlaceOrderReq{Source: "grid", Symbol: "ETHUSDT", Side: futures.SideTypeSell}, order)
+	ok, _, sym := checkStrategyFillTrigger(path, &leaf)
+	if !ok || sym != "ETHUSDT" {
+		t.Fatalf("grid fill should fire with symbol, got ok=%v sym=%s", ok, sym)
+	}
+	if ok, _, _ := checkStrategyFillTrigger(path, &leaf); ok {
+		t.Fatalf("same fill should not fire twice")
+	}
+}
+
+func newTestReplayer(closes []float64) *linkReplayer {
+	r := &linkReplayer{
+		ctx:         context.Background(),
+		interval:    "1h",
+		barDur:      time.Hour,
+		closes:      map[string][]float64{"BTCUSDT": closes},
+		rsi:         make(map[string][]float64),
+		unsupported: make(map[string]bool),
+	}
+	for i := range closes {
+		r.times = append(r.times, int64(i+1)*3600_000)
+	}
+	r.bars = len(closes)
+	return r
+}
+
+func TestLinkReplay_PriceCrossAndUnsupported(t *testing.T) {
+	r := newTestReplayer([]float64{90, 95, 101, 99, 102})
+	cond := LinkCondition{Op: "and", Children: []LinkCondition{
+		{Trigger: TriggerPriceCross, Symbol: "BTCUSDT", Params: map[string]string{"level": "100"}},
+		{Op: "not", Children: []LinkCondition{{Trigger: TriggerWhaleWall, Symbol: "BTCUSDT"}}},
+	}}
+	var hits []int
+	for i := 0; i < r.bars; i++ {
+		ev := evalLinkTree("replay", &cond, func(path string, leaf *LinkCondition) linkEval {
+			return r.leaf(leaf, "BTCUSDT", i)
+		})
+		if ev.Matched {
+			hits = append(hits, i)
+		}
+	}
+	if len(hits) != 2 || hits[0] != 2 || hits[1] != 4 {
+		t.Fatalf("price cross hits = %v, want [2 4]", hits)
+	}
+	if !r.unsupported[string(TriggerWhaleWall)] {
+		t.Fatalf("whale_wall should be reported as unsupported")
+	}
+}
+
+func TestLinkReplay_RSI(t *testing.T) {
+	closes := make([]float64, 40)
+	for i := range closes {
+		closes[i] = 100 - float64(i) // 单边下跌，RSI 趋近 0
+	}
+	r := newTestReplayer(closes)
+	leaf := &LinkCondition{Trigger: TriggerRSIBuy, Symbol: "BTCUSDT"}
+	if ok, _ := r.rsiLeaf(leaf, "BTCUSDT", 5); ok {
+		t.Fatalf("RSI should not be available before warm-up")
+	}
+	if ok, _ := r.rsiLeaf(leaf, "BTCUSDT", 30); !ok {
+		t.Fatalf("falling series should be oversold")
+	}
+	sell := &LinkCondition{Trigger: TriggerRSISell, Symbol: "BTCUSDT"}
+	if ok, _ := r.rsiLeaf(sell, "BTCUSDT", 30); ok {
+		t.Fatalf("falling series should not be overbought")
+	}
+}
+
+func TestDescribeLinkActions(t *testing.T) {
+	got := describeLinkActions([]LinkAction{{Action: ActionReduceLeverage, Symbol: "BTCUSDT"}, {Action: ActionNotify}})
+	if got != "reduce_leverage(BTCUSDT) → notify" {
+		t.Fatalf("describeLinkActions = %q", got)
+	}
+}
@@ -18,6 +18,7 @@ type StrategyState struct {
 	StartedAt    time.Time  `json:"startedAt"`
 	StoppedAt    *time.Time `json:"stoppedAt,omitempty"`
 	Demoted      bool       `json:"demoted"`
+	RuntimeJSON  string     `gorm:"type:text" json:"runtimeJson,omitempty"` // 运行时状态（挂单/层级/成交进度），用于重启后从中断处恢复
 	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
 }
@@ -75,6 +76,12 @@ func SaveStrategyState(strategyType, symbol string, config interface{}) {
 		}
 	}
 
+	// 无 DB 时保留 Redis 中已有的运行时状态，避免更新配置时被覆盖
+	if state.RuntimeJSON == "" {
+		if old, err := getStrategyStateRedisOne(strategyType, symbol); err == nil && old != nil {
+			state.RuntimeJSON = old.RuntimeJSON
+		}
+	}
 	upsertStrategyStateRedis(state)
 	log.Printf("[StrategyPersist] Saved %s/%s as ACTIVE", strategyType, symbol)
 }
@@ -125,6 +132,64 @@ func MarkStrategyStopped(strategyType, symbol string) {
 	log.Printf("[StrategyPersist] Marked %s/%s as STOPPED", strategyType, symbol)
 }
 
+// SaveStrategyRuntime 保存策略运行时状态，runtime 为 nil 时清空
+func SaveStrategyRuntime(strategyType, symbol string, runtime interface{}) {
+	strategyType = strings.ToLower(strings.TrimSpace(strategyType))
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if strategyType == "" || symbol == "" {
+		return
+	}
+
+	runtimeJSON := ""
+	if runtime != nil {
+		b, err := json.Marshal(runtime)
+		if err != nil {
+			log.Printf("[StrategyPersist] Failed to marshal runtime for %s/%s: %v", strategyType, symbol, err)
+			return
+		}
+		runtimeJSON = string(b)
+	}
+
+	if DB != nil {
+		if err := DB.Model(&StrategyState{}).
+			Where("strategy_type = ? AND symbol = ?", strategyType, symbol).
+			Update("runtime_json", runtimeJSON).Error; err != nil {
+			log.Printf("[StrategyPersist] Failed to save runtime for %s/%s to DB: %v", strategyType, symbol, err)
+		}
+	}
+
+	if s, err := getStrategyStateRedisOne(strategyType, symbol); err == nil && s != nil {
+		s.RuntimeJSON = runtimeJSON
+		s.UpdatedAt = time.Now()
+		upsertStrategyStateRedis(*s)
+	}
+}
+
+// LoadStrategyRuntime 读取策略运行时状态到 out，不存在或解析失败时返回 false
+func LoadStrategyRuntime(strategyType, symbol string, out interface{}) bool {
+	strategyType = strings.ToLower(strings.TrimSpace(strategyType))
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	runtimeJSON := ""
+	if s, err := getStrategyStateRedisOne(strategyType, symbol); err == nil && s != nil {
+		runtimeJSON = s.RuntimeJSON
+	}
+	if runtimeJSON == "" && DB != nil {
+		var dbState StrategyState
+		if err := DB.Where("strategy_type = ? AND symbol = ?", strategyType, symbol).First(&dbState).Error; err == nil {
+			runtimeJSON = dbState.RuntimeJSON
+		}
+	}
+	if runtimeJSON == "" {
+		return false
+	}
+	if err := json.Unmarshal([]byte(runtimeJSON), out); err != nil {
+		log.Printf("[StrategyPersist] Failed to decode runtime for %s/%s: %v", strategyType, symbol, err)
+		return false
+	}
+	return true
+}
+
 // RecoverStrategies 恢复所有 ACTIVE 策略（程序启动时调用）
 func RecoverStrategies() {
 	states := make([]StrategyState, 0)
@@ -182,6 +247,18 @@ func RecoverStrategies() {
 			if json.Unmarshal([]byte(state.ConfigJSON), &cfg) == nil {
 				err = StartSignalStrategy(cfg)
 			}
+		case "strategy_link":
+			err = RecoverStrategyLink()
+		case "pairs":
+			var cfg PairsConfig
+			if json.Unmarshal([]byte(state.ConfigJSON), &cfg) == nil {
+				err = RecoverPairs(cfg)
+			}
+		case "basket":
+			var cfg BasketConfig
+			if json.Unmarshal([]byte(state.ConfigJSON), &cfg) == nil {
+				err = RecoverBasket(cfg)
+			}
 		case "doji":
 			var cfg DojiConfig
 			if json.Unmarshal([]byte(state.ConfigJSON), &cfg) == nil {
@@ -190,12 +267,12 @@ func RecoverStrategies() {
 		case "grid":
 			var cfg GridConfig
 			if json.Unmarshal([]byte(state.ConfigJSON), &cfg) == nil {
-				err = StartGrid(cfg)
+				err = RecoverGrid(cfg)
 			}
 		case "dca":
 			var cfg DCAConfig
 			if json.Unmarshal([]byte(state.ConfigJSON), &cfg) == nil {
-				err = StartDCA(cfg)
+				err = RecoverDCA(cfg)
 			}
 		case "autoscale":
 			var cfg AutoScaleConfig
@@ -121,6 +121,9 @@ func handleUserDataEvent(event *futures.WsUserDataEvent) {
 // handleOrderUpdate 处理订单更新事件
 // 当订单成交、部分成交或被取消时，更新数据库中对应的交易记录
 func handleOrderUpdate(update futures.WsOrderTradeUpdate) {
+	// 网格挂单成交 → 挂出对手单（不依赖数据库）
+	handleGridOrderUpdate(update)
+
 	if DB == nil {
 		return
 	}
@@ -239,7 +242,7 @@ func createCloseTradeRecordFromUpdate(update futures.WsOrderTradeUpdate) error {
 	}
 
 	if pnl != 0 {
-		AddDailyPnl(pnl)
+		AddDailyPnl(pnl, record.Source, record.Symbol)
 	}
 	log.Printf("[UserStream] Created close trade record: orderId=%d, symbol=%s, pnl=%.8f", update.ID, record.Symbol, pnl)
 	return nil
@@ -403,17 +406,18 @@ func updateOpenTradeWithPnl(update futures.WsOrderTradeUpdate) {
 	// 通知风控模块
 	pnlFloat := parseNumeric(realizedPnl)
 	if pnlFloat != 0 {
-		AddDailyPnl(pnlFloat)
+		AddDailyPnl(pnlFloat, record.Source, record.Symbol)
 	}
 }
 
 // handleAccountUpdate 处理账户更新事件（余额变动等）
-// 目前用于日志记录，后续可用于风控
+// 钱包余额同步给风控用于权益峰谷回撤，出入金/划转按原因调整峰值
 func handleAccountUpdate(update futures.WsAccountUpdate) {
 	for _, b := range update.Balances {
 		if b.Asset == "USDT" {
-			log.Printf("[UserStream] Balance update: USDT balance=%s, crossWallet=%s",
-				b.Balance, b.CrossWalletBalance)
+			log.Printf("[UserStream] Balance update (%s): USDT balance=%s, crossWallet=%s, change=%s",
+				update.Reason, b.Balance, b.CrossWalletBalance, b.ChangeBalance)
+			UpdateRiskEquityForReason(update.Reason, parseNumeric(b.Balance), parseNumeric(b.ChangeBalance))
 		}
 	}
 }
@@ -0,0 +1,506 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+const (
+	varDefaultInterval    = "1d"
+	varDefaultLookback    = 365
+	varDefaultLambda      = 0.94
+	varDefaultSimulations = 10000
+	varDefaultDoF         = 5
+	varMinObservations    = 30
+	varBacktestMaxSims    = 2000 // 回测每步的蒙特卡洛路径上限
+	varReturnsCacheTTL    = 10 * time.Minute
+)
+
+// VarModelReq VaR 模型计算请求；未填字段使用 varRisk 配置或默认值
+type VarModelReq struct {
+	Model          string          `json:"model,omitempty"`          // parametric / historical / fhs / montecarlo
+	Models         []string        `json:"models,omitempty"`         // 回测时比较的模型，默认全部
+	Confidence     float64         `json:"confidence,omitempty"`     // 默认 0.95
+	Interval       string          `json:"interval,omitempty"`       // 收益率周期，默认 1d
+	Lookback       int             `json:"lookback,omitempty"`       // 回看 K 线数，默认 365
+	VolMethod      string          `json:"volMethod,omitempty"`      // fhs: ewma / garch
+	Lambda         float64         `json:"lambda,omitempty"`         // EWMA 衰减，默认 0.94
+	Simulations    int             `json:"simulations,omitempty"`    // 蒙特卡洛路径数，默认 10000
+	DoF            float64         `json:"dof,omitempty"`            // t Copula 自由度，默认 5
+	Seed           uint64          `json:"seed,omitempty"`           // 随机种子，0=按时间
+	BacktestWindow int             `json:"backtestWindow,omitempty"` // 回测估计窗口，默认 lookback/2
+	Positions      []VarPositionIn `json:"positions,omitempty"`      // 为空时使用当前持仓
+}
+
+// VarPositionIn 假设持仓
+type VarPositionIn struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`     // LONG / SHORT
+	Notional float64 `json:"notional"` // 名义价值(USDT)，正数
+}
+
+// VarPositionContribution 单个持仓的风险贡献
+type VarPositionContribution struct {
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	Notional      float64 `json:"notional"`
+	StandaloneVar float64 `json:"standaloneVar"` // 单独持有时的 VaR
+	MarginalVar   float64 `json:"marginalVar"`   // 沿持仓方向每增加 1 USDT 名义的 VaR 变化
+	ComponentVar  float64 `json:"componentVar"`  // 成分 VaR，各持仓之和等于组合 VaR
+	ComponentPct  float64 `json:"componentPct"`
+}
+
+// VarModelResult VaR 模型结果
+type VarModelResult struct {
+	Model        string                    `json:"model"`
+	Confidence   float64                   `json:"confidence"`
+	Interval     string                    `json:"interval"`
+	Observations int                       `json:"observations"`
+	Var          float64                   `json:"var"`
+	CVar         float64                   `json:"cvar"`
+	Shrinkage    float64                   `json:"shrinkage,omitempty"`
+	Positions    []VarPositionContribution `json:"positions"`
+}
+
+// VarBacktestResult 单个模型的 VaR 例外回测
+type VarBacktestResult struct {
+	Model         string  `json:"model"`
+	Observations  int     `json:"observations"`
+	Exceptions    int     `json:"exceptions"`
+	Expected      float64 `json:"expected"`
+	ExceptionRate float64 `json:"exceptionRate"`
+	KupiecLR      float64 `json:"kupiecLR"`
+	PValue        float64 `json:"pValue"`
+	Calibrated    bool    `json:"calibrated"` // 5% 显著性下不拒绝
+	Error         string  `json:"error,omitempty"`
+}
+
+// varInput 模型输入：对齐后的币种收益矩阵与持仓敞口
+type varInput struct {
+	positions []positionForVar
+	returns   [][]float64
+	symIdx    []int
+	exposures []float64
+}
+
+type varReturnsCacheEntry struct {
+	at     time.Time
+	closes map[int64]float64
+}
+
+var (
+	varReturnsMu    sync.Mutex
+	varReturnsCache = map[string]varReturnsCacheEntry{}
+
+	// varCloseLoader 收盘价加载（测试注入点）
+	varCloseLoader = loadVarCloses
+)
+
+// normalizeVarModelReq 合并配置与默认值
+func normalizeVarModelReq(req *VarModelReq) (varModelParams, error) {
+	varMu.RLock()
+	cfg := varCfg
+	varMu.RUnlock()
+
+	if req.Model == "" {
+		req.Model = cfg.Model
+	}
+	if req.Model == "" {
+		req.Model = VarModelHistorical
+	}
+	req.Model = strings.ToLower(req.Model)
+	for i, m := range req.Models {
+		req.Models[i] = strings.ToLower(strings.TrimSpace(m))
+	}
+	if len(req.Models) == 0 {
+		req.Models = append([]string(nil), varModels...)
+	}
+	for _, m := range append([]string{req.Model}, req.Models...) {
+		if !containsString(varModels, m) {
+			return varModelParams{}, fmt.Errorf("unknown model %q, want one of %s", m, strings.Join(varModels, "/"))
+		}
+	}
+	if req.Confidence == 0 {
+		req.Confidence = 0.95
+	}
+	if req.Confidence <= 0.5 || req.Confidence >= 1 {
+		return varModelParams{}, fmt.Errorf("confidence must be in (0.5, 1)")
+	}
+	if req.Interval == "" {
+		req.Interval = cfg.ReturnInterval
+	}
+	if req.Interval == "" {
+		req.Interval = varDefaultInterval
+	}
+	if req.Lookback <= 0 {
+		req.Lookback = cfg.LookbackBars
+	}
+	if req.Lookback <= 0 {
+		req.Lookback = varDefaultLookback
+	}
+	if req.Lookback < varMinObservations || req.Lookback > 1500 {
+		return varModelParams{}, fmt.Errorf("lookback must be between %d and 1500", varMinObservations)
+	}
+	if req.VolMethod == "" {
+		req.VolMethod = cfg.VolMethod
+	}
+	if req.VolMethod == "" {
+		req.VolMethod = "ewma"
+	}
+	req.VolMethod = strings.ToLower(req.VolMethod)
+	if req.VolMethod != "ewma" && req.VolMethod != "garch" {
+		return varModelParams{}, fmt.Errorf("volMethod must be ewma or garch")
+	}
+	if req.Lambda == 0 {
+		req.Lambda = cfg.EWMALambda
+	}
+	if req.Lambda == 0 {
+		req.Lambda = varDefaultLambda
+	}
+	if req.Lambda <= 0 || req.Lambda >= 1 {
+		return varModelParams{}, fmt.Errorf("lambda must be in (0, 1)")
+	}
+	if req.Simulations <= 0 {
+		req.Simulations = cfg.MCSimulations
+	}
+	if req.Simulations <= 0 {
+		req.Simulations = varDefaultSimulations
+	}
+	req.Simulations = min(req.Simulations, 100000)
+	if req.DoF == 0 {
+		req.DoF = cfg.MCDegreesOfFreedom
+	}
+	if req.DoF == 0 {
+		req.DoF = varDefaultDoF
+	}
+	if req.DoF <= 2 {
+		return varModelParams{}, fmt.Errorf("dof must be greater than 2")
+	}
+	if req.Seed == 0 {
+		req.Seed = uint64(time.Now().UnixNano())
+	}
+	return varModelParams{
+		Confidence:  req.Confidence,
+		VolMethod:   req.VolMethod,
+		Lambda:      req.Lambda,
+		Simulations: req.Simulations,
+		DoF:         req.DoF,
+		Seed:        req.Seed,
+	}, nil
+}
+
+// collectVarPositions 当前非零持仓
+func collectVarPositions(ctx context.Context) ([]positionForVar, error) {
+	positions, err := GetPositions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []positionForVar
+	for _, p := range positions {
+		amt, _ := strconv.ParseFloat(p.PositionAmt, 64)
+		if amt == 0 {
+			continue
+		}
+		mark, _ := strconv.ParseFloat(p.MarkPrice, 64)
+		side := "LONG"
+		if amt < 0 {
+			side = "SHORT"
+		}
+		out = append(out, positionForVar{Symbol: p.Symbol, Notional: math.Abs(amt) * mark, Side: side})
+	}
+	return out, nil
+}
+
+// buildVarInput 拉取收益序列并对齐持仓
+func buildVarInput(ctx context.Context, positions []positionForVar, interval string, lookback int) (*varInput, error) {
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("no positions")
+	}
+	in := &varInput{positions: positions}
+	var symbols []string
+	col := map[string]int{}
+	for _, p := range positions {
+		sym := strings.ToUpper(p.Symbol)
+		if _, ok := col[sym]; !ok {
+			col[sym] = len(symbols)
+			symbols = append(symbols, sym)
+		}
+		in.symIdx = append(in.symIdx, col[sym])
+		exp := p.Notional
+		if strings.EqualFold(p.Side, "SHORT") {
+			exp = -exp
+		}
+		in.exposures = append(in.exposures, exp)
+	}
+
+	returns, err := loadVarReturns(ctx, symbols, interval, lookback)
+	if err != nil {
+		return nil, err
+	}
+	in.returns = returns
+	return in, nil
+}
+
+// loadVarReturns 各币种对齐后的收益矩阵 [t][symbol]
+func loadVarReturns(ctx context.Context, symbols []string, interval string, lookback int) ([][]float64, error) {
+	closes := make([]map[int64]float64, len(symbols))
+	for i, sym := range symbols {
+		c, err := varCloseLoader(ctx, sym, interval, lookback+1)
+		if err != nil {
+			return nil, fmt.Errorf("load %s klines: %w", sym, err)
+		}
+		closes[i] = c
+	}
+	returns := alignedReturns(closes)
+	if len(returns) < varMinObservations {
+		return nil, fmt.Errorf("insufficient aligned history: %d observations", len(returns))
+	}
+	return returns, nil
+}
+
+// loadVarCloses 收盘价（按开盘时间），带短期缓存避免定时刷新频繁拉 K 线
+func loadVarCloses(ctx context.Context, symbol, interval string, limit int) (map[int64]float64, error) {
+	key := fmt.Sprintf("%s|%s|%d", symbol, interval, limit)
+	varReturnsMu.Lock()
+	if e, ok := varReturnsCache[key]; ok && time.Since(e.at) < varReturnsCacheTTL {
+		varReturnsMu.Unlock()
+		return e.closes, nil
+	}
+	varReturnsMu.Unlock()
+
+	if Client == nil {
+		return nil, fmt.Errorf("binance client not initialized")
+	}
+	klines, err := fetchIntervalKlines(ctx, symbol, interval, limit)
+	if err != nil {
+		return nil, err
+	}
+	closes := make(map[int64]float64, len(klines))
+	for _, k := range klines {
+		if c, _ := strconv.ParseFloat(k.Close, 64); c > 0 {
+			closes[k.OpenTime] = c
+		}
+	}
+	varReturnsMu.Lock()
+	varReturnsCache[key] = varReturnsCacheEntry{at: time.Now(), closes: closes}
+	varReturnsMu.Unlock()
+	return closes, nil
+}
+
+// alignedReturns 取各币种共有的时间点，计算相邻时间点的简单收益率
+func alignedReturns(closes []map[int64]float64) [][]float64 {
+	var times []int64
+	for ts := range closes[0] {
+		shared := true
+		for _, c := range closes[1:] {
+			if _, ok := c[ts]; !ok {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			times = append(times, ts)
+		}
+	}
+	slices.Sort(times)
+	var out [][]float64
+	for t := 1; t < len(times); t++ {
+		row := make([]float64, len(closes))
+		for i, c := range closes {
+			row[i] = c[times[t]]/c[times[t-1]] - 1
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// varResult 组装结果：成分/边际 VaR
+func varResult(req VarModelReq, in *varInput, est *varEstimate) *VarModelResult {
+	res := &VarModelResult{
+		Model:        req.Model,
+		Confidence:   req.Confidence,
+		Interval:     req.Interval,
+		Observations: len(in.returns),
+		Var:          roundFloat(est.Var, 2),
+		CVar:         roundFloat(est.CVar, 2),
+		Shrinkage:    roundFloat(est.Shrinkage, 4),
+	}
+	for j, p := range in.positions {
+		c := VarPositionContribution{
+			Symbol:        p.Symbol,
+			Side:          p.Side,
+			Notional:      roundFloat(p.Notional, 2),
+			StandaloneVar: roundFloat(est.Standalone[j], 2),
+			ComponentVar:  roundFloat(est.Components[j], 2),
+		}
+		if p.Notional > 0 {
+			c.MarginalVar = roundFloat(est.Components[j]/p.Notional, 6)
+		}
+		if est.Var != 0 {
+			c.ComponentPct = roundFloat(est.Components[j]/est.Var*100, 2)
+		}
+		res.Positions = append(res.Positions, c)
+	}
+	return res
+}
+
+// resolveVarPositions 请求中的假设持仓或当前持仓
+func resolveVarPositions(ctx context.Context, req VarModelReq) ([]positionForVar, error) {
+	if len(req.Positions) == 0 {
+		if Client == nil {
+			return nil, fmt.Errorf("binance client not initialized")
+		}
+		return collectVarPositions(ctx)
+	}
+	out := make([]positionForVar, 0, len(req.Positions))
+	for _, p := range req.Positions {
+		side := strings.ToUpper(p.Side)
+		if p.Symbol == "" || p.Notional <= 0 || (side != "LONG" && side != "SHORT") {
+			return nil, fmt.Errorf("invalid position %+v", p)
+		}
+		out = append(out, positionForVar{Symbol: strings.ToUpper(p.Symbol), Side: side, Notional: p.Notional})
+	}
+	return out, nil
+}
+
+// RunVarModel 按指定模型计算组合 VaR/CVaR 与各持仓贡献
+func RunVarModel(ctx context.Context, req VarModelReq) (*VarModelResult, error) {
+	params, err := normalizeVarModelReq(&req)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := resolveVarPositions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	in, err := buildVarInput(ctx, positions, req.Interval, req.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	est, err := estimateVar(req.Model, in.returns, in.symIdx, in.exposures, params)
+	if err != nil {
+		return nil, err
+	}
+	return varResult(req, in, est), nil
+}
+
+// RunVarBacktest 对各模型做滚动 VaR 例外回测（Kupiec POF）
+func RunVarBacktest(ctx context.Context, req VarModelReq) ([]VarBacktestResult, error) {
+	params, err := normalizeVarModelReq(&req)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := resolveVarPositions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	in, err := buildVarInput(ctx, positions, req.Interval, req.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	window := req.BacktestWindow
+	if window <= 0 {
+		window = len(in.returns) / 2
+	}
+	if window < varMinObservations || window >= len(in.returns) {
+		return nil, fmt.Errorf("backtestWindow must be between %d and %d", varMinObservations, len(in.returns)-1)
+	}
+	params.Simulations = min(params.Simulations, varBacktestMaxSims)
+	return backtestVarModels(req.Models, in, params, window), nil
+}
+
+func backtestVarModels(models []string, in *varInput, params varModelParams, window int) []VarBacktestResult {
+	p := 1 - params.Confidence
+	out := make([]VarBacktestResult, 0, len(models))
+	for _, m := range models {
+		r := VarBacktestResult{Model: m}
+		exceptions, n, err := varBacktestExceptions(m, in.returns, in.symIdx, in.exposures, params, window)
+		if err != nil {
+			r.Error = err.Error()
+			out = append(out, r)
+			continue
+		}
+		lr, pValue := kupiecTest(exceptions, n, p)
+		r.Observations = n
+		r.Exceptions = exceptions
+		r.Expected = roundFloat(p*float64(n), 2)
+		if n > 0 {
+			r.ExceptionRate = roundFloat(float64(exceptions)/float64(n), 4)
+		}
+		r.KupiecLR = roundFloat(lr, 4)
+		r.PValue = roundFloat(pValue, 4)
+		r.Calibrated = pValue >= 0.05
+		out = append(out, r)
+	}
+	return out
+}
+
+// modelVarSnapshot 用配置的模型计算 95%/99% VaR 快照
+func modelVarSnapshot(ctx context.Context, positions []positionForVar, model string) (*VarSnapshot, error) {
+	req := VarModelReq{Model: model}
+	params, err := normalizeVarModelReq(&req)
+	if err != nil {
+		return nil, err
+	}
+	in, err := buildVarInput(ctx, positions, req.Interval, req.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	est95, err := estimateVar(req.Model, in.returns, in.symIdx, in.exposures, params)
+	if err != nil {
+		return nil, err
+	}
+	params.Confidence = 0.99
+	est99, err := estimateVar(req.Model, in.returns, in.symIdx, in.exposures, params)
+	if err != nil {
+		return nil, err
+	}
+	detail, _ := json.Marshal(varResult(req, in, est95).Positions)
+	return &VarSnapshot{
+		TotalVar95:  roundFloat(est95.Var, 2),
+		TotalCVar95: roundFloat(est95.CVar, 2),
+		TotalVar99:  roundFloat(est99.Var, 2),
+		DetailJSON:  string(detail),
+	}, nil
+}
+
+// HandleVarModel POST /tool/risk/var/model
+func HandleVarModel(c context.Context, ctx *app.RequestContext) {
+	var req VarModelReq
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	res, err := RunVarModel(c, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": res})
+}
+
+// HandleVarBacktest POST /tool/risk/var/backtest
+func HandleVarBacktest(c context.Context, ctx *app.RequestContext) {
+	var req VarModelReq
+	if err := ctx.BindAndValidate(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	res, err := RunVarBacktest(c, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, utils.H{"data": res})
+}
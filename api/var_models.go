@@ -0,0 +1,530 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// VaR 模型
+const (
+	VarModelParametric = "parametric" // 正态参数法 + 收缩协方差
+	VarModelHistorical = "historical" // 历史模拟
+	VarModelFHS        = "fhs"        // 过滤历史模拟（EWMA/GARCH 波动率缩放）
+	VarModelMonteCarlo = "montecarlo" // Student-t Copula 蒙特卡洛 + 经验边际分布
+)
+
+var varModels = []string{VarModelParametric, VarModelHistorical, VarModelFHS, VarModelMonteCarlo}
+
+// varModelParams 模型参数（已填默认值）
+type varModelParams struct {
+	Confidence  float64
+	VolMethod   string  // fhs: ewma / garch
+	Lambda      float64 // EWMA 衰减系数
+	Simulations int     // 蒙特卡洛路径数
+	DoF         float64 // t Copula 自由度
+	Seed        uint64
+}
+
+// varEstimate 单次 VaR 估计；Components/Standalone 与持仓一一对应
+type varEstimate struct {
+	Var        float64
+	CVar       float64
+	Components []float64 // 成分 VaR，和为 Var
+	Standalone []float64 // 单独持有的 VaR
+	Shrinkage  float64   // Ledoit-Wolf 收缩强度（参数法/蒙特卡洛）
+}
+
+// estimateVar 估计组合 VaR/CVaR。
+// returns 为币种收益矩阵 [t][symbol]，symIdx 把持仓映射到币种列，w 为带方向的名义敞口（空头为负）。
+func estimateVar(model string, returns [][]float64, symIdx []int, w []float64, p varModelParams) (*varEstimate, error) {
+	if len(returns) < 2 || len(w) == 0 {
+		return nil, fmt.Errorf("insufficient data for VaR")
+	}
+	switch model {
+	case VarModelParametric:
+		return parametricVar(returns, symIdx, w, p.Confidence), nil
+	case VarModelHistorical:
+		return scenarioVar(expandScenarios(returns, symIdx), w, p.Confidence), nil
+	case VarModelFHS:
+		scen := filteredScenarios(returns, p.VolMethod, p.Lambda)
+		return scenarioVar(expandScenarios(scen, symIdx), w, p.Confidence), nil
+	case VarModelMonteCarlo:
+		scen, delta := tCopulaScenarios(returns, p.Simulations, p.DoF, p.Seed)
+		est := scenarioVar(expandScenarios(scen, symIdx), w, p.Confidence)
+		est.Shrinkage = delta
+		return est, nil
+	}
+	return nil, fmt.Errorf("unknown VaR model: %s", model)
+}
+
+// expandScenarios 把币种情景展开成持仓列（同币种多空双向持仓共用一列收益）
+func expandScenarios(scen [][]float64, symIdx []int) [][]float64 {
+	out := make([][]float64, len(scen))
+	for t, row := range scen {
+		out[t] = make([]float64, len(symIdx))
+		for j, i := range symIdx {
+			out[t][j] = row[i]
+		}
+	}
+	return out
+}
+
+// tailVar 损失序列的 VaR（经验分位数）、CVaR 以及尾部情景下标
+func tailVar(losses []float64, conf float64) (float64, float64, []int) {
+	n := len(losses)
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return losses[idx[a]] < losses[idx[b]] })
+	k := int(math.Ceil(conf*float64(n))) - 1
+	k = max(0, min(k, n-1))
+	v := losses[idx[k]]
+	tail := idx[k:]
+	var sum float64
+	for _, t := range tail {
+		sum += losses[t]
+	}
+	return v, sum / float64(len(tail)), tail
+}
+
+// scenarioVar 情景法 VaR；成分 VaR 用尾部条件期望按 VaR/CVaR 缩放（Euler 分配，和为 VaR）
+func scenarioVar(scen [][]float64, w []float64, conf float64) *varEstimate {
+	losses := make([]float64, len(scen))
+	for t, row := range scen {
+		for j, r := range row {
+			losses[t] -= w[j] * r
+		}
+	}
+	v, cvar, tail := tailVar(losses, conf)
+	est := &varEstimate{
+		Var:        v,
+		CVar:       cvar,
+		Components: make([]float64, len(w)),
+		Standalone: make([]float64, len(w)),
+	}
+	if cvar != 0 {
+		for j := range w {
+			var sum float64
+			for _, t := range tail {
+				sum -= w[j] * scen[t][j]
+			}
+			est.Components[j] = sum / float64(len(tail)) * v / cvar
+		}
+	}
+	single := make([]float64, len(scen))
+	for j := range w {
+		for t, row := range scen {
+			single[t] = -w[j] * row[j]
+		}
+		est.Standalone[j], _, _ = tailVar(single, conf)
+	}
+	return est
+}
+
+// parametricVar 正态参数法（零均值），协方差使用 Ledoit-Wolf 收缩
+func parametricVar(returns [][]float64, symIdx []int, w []float64, conf float64) *varEstimate {
+	corr, vols, delta := ledoitWolfCorrelation(returns)
+	cov := func(a, b int) float64 { return corr[symIdx[a]][symIdx[b]] * vols[symIdx[a]] * vols[symIdx[b]] }
+
+	sigmaW := make([]float64, len(w))
+	var variance float64
+	for a := range w {
+		for b := range w {
+			sigmaW[a] += cov(a, b) * w[b]
+		}
+		variance += w[a] * sigmaW[a]
+	}
+	sd := math.Sqrt(math.Max(variance, 0))
+	z := normQuantile(conf)
+	est := &varEstimate{
+		Var:        z * sd,
+		CVar:       sd * normPDF(z) / (1 - conf),
+		Components: make([]float64, len(w)),
+		Standalone: make([]float64, len(w)),
+		Shrinkage:  delta,
+	}
+	for a := range w {
+		if sd > 0 {
+			est.Components[a] = z * w[a] * sigmaW[a] / sd
+		}
+		est.Standalone[a] = z * math.Abs(w[a]) * vols[symIdx[a]]
+	}
+	return est
+}
+
+// ledoitWolfCorrelation 标准化收益的样本相关矩阵向单位阵做 Ledoit-Wolf 收缩，返回收缩后相关矩阵、波动率与收缩强度
+func ledoitWolfCorrelation(returns [][]float64) ([][]float64, []float64, float64) {
+	n, p := len(returns), len(returns[0])
+	means := make([]float64, p)
+	vols := make([]float64, p)
+	for _, row := range returns {
+		for i, r := range row {
+			means[i] += r / float64(n)
+		}
+	}
+	for _, row := range returns {
+		for i, r := range row {
+			vols[i] += (r - means[i]) * (r - means[i]) / float64(n)
+		}
+	}
+	for i := range vols {
+		vols[i] = math.Sqrt(vols[i])
+	}
+	x := make([][]float64, n)
+	for t, row := range returns {
+		x[t] = make([]float64, p)
+		for i, r := range row {
+			if vols[i] > 0 {
+				x[t][i] = (r - means[i]) / vols[i]
+			}
+		}
+	}
+
+	s := make([][]float64, p)
+	for i := range s {
+		s[i] = make([]float64, p)
+		for j := range s[i] {
+			for t := range x {
+				s[i][j] += x[t][i] * x[t][j] / float64(n)
+			}
+		}
+	}
+	var d2, b2 float64
+	for i := range s {
+		for j := range s[i] {
+			target := 0.0
+			if i == j {
+				target = 1
+			}
+			d2 += (s[i][j] - target) * (s[i][j] - target)
+		}
+	}
+	for t := range x {
+		for i := range s {
+			for j := range s[i] {
+				diff := x[t][i]*x[t][j] - s[i][j]
+				b2 += diff * diff
+			}
+		}
+	}
+	b2 /= float64(n) * float64(n)
+	delta := 1.0
+	if d2 > 0 {
+		delta = math.Min(b2, d2) / d2
+	}
+	for i := range s {
+		for j := range s[i] {
+			if i == j {
+				s[i][j] = 1
+			} else {
+				s[i][j] *= 1 - delta
+			}
+		}
+	}
+	return s, vols, delta
+}
+
+// filteredScenarios 过滤历史模拟：用条件波动率标准化历史收益，再按最新波动率预测重新缩放
+func filteredScenarios(returns [][]float64, method string, lambda float64) [][]float64 {
+	n, p := len(returns), len(returns[0])
+	scen := make([][]float64, n)
+	for t := range scen {
+		scen[t] = make([]float64, p)
+	}
+	series := make([]float64, n)
+	for i := 0; i < p; i++ {
+		for t := range returns {
+			series[t] = returns[t][i]
+		}
+		var v []float64
+		if method == "garch" {
+			omega, alpha, beta := fitGarch11(series)
+			v = garchVariances(series, omega, alpha, beta)
+		} else {
+			v = ewmaVariances(series, lambda)
+		}
+		forecast := math.Sqrt(v[n])
+		for t := range series {
+			if v[t] > 0 {
+				scen[t][i] = series[t] / math.Sqrt(v[t]) * forecast
+			}
+		}
+	}
+	return scen
+}
+
+// seedVariance 波动率递推初值：前 20 个样本的平方均值
+func seedVariance(r []float64) float64 {
+	m := min(len(r), 20)
+	var v float64
+	for _, x := range r[:m] {
+		v += x * x / float64(m)
+	}
+	if v <= 0 {
+		v = 1e-8
+	}
+	return v
+}
+
+// ewmaVariances RiskMetrics EWMA，v[t] 为第 t 个收益的条件方差，v[n] 为下一期预测
+func ewmaVariances(r []float64, lambda float64) []float64 {
+	v := make([]float64, len(r)+1)
+	v[0] = seedVariance(r)
+	for t, x := range r {
+		v[t+1] = lambda*v[t] + (1-lambda)*x*x
+	}
+	return v
+}
+
+// garchVariances GARCH(1,1) 条件方差递推
+func garchVariances(r []float64, omega, alpha, beta float64) []float64 {
+	v := make([]float64, len(r)+1)
+	v[0] = seedVariance(r)
+	for t, x := range r {
+		v[t+1] = omega + alpha*x*x + beta*v[t]
+	}
+	return v
+}
+
+// fitGarch11 方差目标法 + 网格搜索最大化高斯似然
+func fitGarch11(r []float64) (omega, alpha, beta float64) {
+	var uncond float64
+	for _, x := range r {
+		uncond += x * x / float64(len(r))
+	}
+	if uncond <= 0 {
+		uncond = 1e-8
+	}
+	best := math.Inf(-1)
+	alpha, beta = 0.06, 0.93
+	for a := 2; a <= 30; a += 2 {
+		for b := 50; b <= 98; b++ {
+			ta, tb := float64(a)/100, float64(b)/100
+			if ta+tb >= 0.999 {
+				continue
+			}
+			to := uncond * (1 - ta - tb)
+			v := garchVariances(r, to, ta, tb)
+			var ll float64
+			for t, x := range r {
+				ll -= 0.5 * (math.Log(v[t]) + x*x/v[t])
+			}
+			if ll > best {
+				best, alpha, beta = ll, ta, tb
+			}
+		}
+	}
+	return uncond * (1 - alpha - beta), alpha, beta
+}
+
+// tCopulaScenarios Student-t Copula 蒙特卡洛：收缩相关矩阵刻画依赖，各币种使用经验边际分布
+func tCopulaScenarios(returns [][]float64, sims int, dof float64, seed uint64) ([][]float64, float64) {
+	p := len(returns[0])
+	corr, _, delta := ledoitWolfCorrelation(returns)
+	l := choleskyWithJitter(corr)
+
+	sorted := make([][]float64, p)
+	for i := range sorted {
+		sorted[i] = make([]float64, len(returns))
+		for t := range returns {
+			sorted[i][t] = returns[t][i]
+		}
+		sort.Float64s(sorted[i])
+	}
+
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	scen := make([][]float64, sims)
+	z := make([]float64, p)
+	for s := range scen {
+		for i := range z {
+			z[i] = rng.NormFloat64()
+		}
+		scale := math.Sqrt(dof / (2 * gammaSample(rng, dof/2)))
+		scen[s] = make([]float64, p)
+		for i := 0; i < p; i++ {
+			var x float64
+			for k := 0; k <= i; k++ {
+				x += l[i][k] * z[k]
+			}
+			u := studentTCDF(x*scale, dof)
+			scen[s][i] = empiricalQuantile(sorted[i], u)
+		}
+	}
+	return scen, delta
+}
+
+// choleskyWithJitter 下三角 Cholesky 分解，非正定时对角线逐步加扰动
+func choleskyWithJitter(a [][]float64) [][]float64 {
+	n := len(a)
+	for jitter := 0.0; ; jitter = math.Max(jitter*10, 1e-10) {
+		l := make([][]float64, n)
+		ok := true
+		for i := 0; i < n && ok; i++ {
+			l[i] = make([]float64, n)
+			for j := 0; j <= i; j++ {
+				sum := a[i][j]
+				if i == j {
+					sum += jitter
+				}
+				for k := 0; k < j; k++ {
+					sum -= l[i][k] * l[j][k]
+				}
+				if i == j {
+					if sum <= 0 {
+						ok = false
+						break
+					}
+					l[i][i] = math.Sqrt(sum)
+				} else {
+					l[i][j] = sum / l[j][j]
+				}
+			}
+		}
+		if ok || jitter > 1 {
+			return l
+		}
+	}
+}
+
+// empiricalQuantile 已排序样本的线性插值分位数
+func empiricalQuantile(sorted []float64, u float64) float64 {
+	n := len(sorted)
+	pos := u * float64(n-1)
+	lo := int(math.Floor(pos))
+	if lo <= 0 {
+		return sorted[0]
+	}
+	if lo >= n-1 {
+		return sorted[n-1]
+	}
+	frac := pos - float64(lo)
+	return sorted[lo]*(1-frac) + sorted[lo+1]*frac
+}
+
+// gammaSample Marsaglia-Tsang Gamma(shape, 1) 采样
+func gammaSample(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		return gammaSample(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// studentTCDF Student-t 分布函数
+func studentTCDF(t, dof float64) float64 {
+	ib := regIncBeta(dof/2, 0.5, dof/(dof+t*t))
+	if t > 0 {
+		return 1 - 0.5*ib
+	}
+	return 0.5 * ib
+}
+
+// regIncBeta 正则化不完全 Beta 函数（连分式展开）
+func regIncBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lab, _ := math.Lgamma(a + b)
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+func betaCF(a, b, x float64) float64 {
+	const fpmin = 1e-300
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < fpmin {
+			return fpmin
+		}
+		return v
+	}
+	qab, qap, qam := a+b, a+1, a-1
+	c := 1.0
+	d := 1 / clamp(1-qab*x/qap)
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		aa := fm * (b - fm) * x / ((qam + 2*fm) * (a + 2*fm))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		h *= d * c
+		aa = -(a + fm) * (qab + fm) * x / ((a + 2*fm) * (qap + 2*fm))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 3e-14 {
+			break
+		}
+	}
+	return h
+}
+
+func normQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+func normPDF(z float64) float64 {
+	return math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+}
+
+// kupiecTest Kupiec POF 检验：返回似然比统计量与 p 值（χ²(1)）
+func kupiecTest(exceptions, n int, p float64) (float64, float64) {
+	if n <= 0 {
+		return 0, 1
+	}
+	x, fn := float64(exceptions), float64(n)
+	null := (fn-x)*math.Log(1-p) + x*math.Log(p)
+	var alt float64
+	rate := x / fn
+	if exceptions > 0 {
+		alt += x * math.Log(rate)
+	}
+	if exceptions < n {
+		alt += (fn - x) * math.Log(1-rate)
+	}
+	lr := math.Max(-2*(null-alt), 0)
+	return lr, math.Erfc(math.Sqrt(lr / 2))
+}
+
+// varBacktestExceptions 滚动窗口回测：用前 window 个样本估计 VaR，与下一期实际损失比较
+func varBacktestExceptions(model string, returns [][]float64, symIdx []int, w []float64, p varModelParams, window int) (int, int, error) {
+	exceptions, n := 0, 0
+	for t := window; t < len(returns); t++ {
+		est, err := estimateVar(model, returns[t-window:t], symIdx, w, p)
+		if err != nil {
+			return 0, 0, err
+		}
+		var loss float64
+		for j, i := range symIdx {
+			loss -= w[j] * returns[t][i]
+		}
+		if loss > est.Var {
+			exceptions++
+		}
+		n++
+	}
+	return exceptions, n, nil
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// syntheticReturns 两个相关币种的 t 分布收益
+func syntheticReturns(n int, seed uint64) [][]float64 {
+	rng := rand.New(rand.NewPCG(seed, seed+1))
+	out := make([][]float64, n)
+	for t := range out {
+		common := rng.NormFloat64()
+		scale := math.Sqrt(4 / (2 * gammaSample(rng, 2)))
+		out[t] = []float64{
+			0.03 * scale * common,
+			0.04 * scale * (0.6*common + 0.8*rng.NormFloat64()),
+		}
+	}
+	return out
+}
+
+func TestTailVar(t *testing.T) {
+	losses := make([]float64, 100)
+	for i := range losses {
+		losses[i] = float64(i + 1)
+	}
+	v, cvar, tail := tailVar(losses, 0.95)
+	if v != 95 || cvar != 97.5 || len(tail) != 6 {
+		t.Fatalf("var=%v cvar=%v tail=%d", v, cvar, len(tail))
+	}
+}
+
+func TestComponentVarSumsToTotal(t *testing.T) {
+	returns := syntheticReturns(400, 7)
+	symIdx := []int{0, 1, 1}
+	w := []float64{1000, -500, 300}
+	params := varModelParams{Confidence: 0.95, VolMethod: "ewma", Lambda: 0.94, Simulations: 5000, DoF: 5, Seed: 42}
+	for _, m := range varModels {
+		est, err := estimateVar(m, returns, symIdx, w, params)
+		if err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+		var sum float64
+		for _, c := range est.Components {
+			sum += c
+		}
+		if est.Var <= 0 || est.CVar < est.Var || math.Abs(sum-est.Var) > 1e-6*math.Max(1, est.Var) {
+			t.Fatalf("%s: var=%v cvar=%v components=%v", m, est.Var, est.CVar, est.Components)
+		}
+		for j, s := range est.Standalone {
+			if s <= 0 {
+				t.Fatalf("%s: standalone[%d]=%v", m, j, s)
+			}
+		}
+	}
+	if _, err := estimateVar("delta", returns, symIdx, w, params); err == nil {
+		t.Fatal("expected unknown model error")
+	}
+}
+
+func TestLedoitWolfCorrelation(t *testing.T) {
+	corr, vols, delta := ledoitWolfCorrelation(syntheticReturns(60, 3))
+	if delta < 0 || delta > 1 || vols[0] <= 0 {
+		t.Fatalf("delta=%v vols=%v", delta, vols)
+	}
+	if corr[0][0] != 1 || corr[0][1] != corr[1][0] || math.Abs(corr[0][1]) >= 1 {
+		t.Fatalf("corr=%v", corr)
+	}
+	// 样本越多收缩越小
+	_, _, large := ledoitWolfCorrelation(syntheticReturns(2000, 3))
+	if large >= delta {
+		t.Fatalf("shrinkage should decrease with sample size: %v >= %v", large, delta)
+	}
+}
+
+func TestFilteredScenariosScaleToCurrentVol(t *testing.T) {
+	// 前半段低波动、后半段高波动：FHS 按最新波动率放大历史情景
+	returns := syntheticReturns(300, 11)
+	for t := 200; t < 300; t++ {
+		returns[t][0] *= 3
+		returns[t][1] *= 3
+	}
+	params := varModelParams{Confidence: 0.99, VolMethod: "ewma", Lambda: 0.94}
+	hs, _ := estimateVar(VarModelHistorical, returns, []int{0}, []float64{1000}, params)
+	fhs, _ := estimateVar(VarModelFHS, returns, []int{0}, []float64{1000}, params)
+	params.VolMethod = "garch"
+	garch, _ := estimateVar(VarModelFHS, returns, []int{0}, []float64{1000}, params)
+	if fhs.Var <= hs.Var || garch.Var <= hs.Var {
+		t.Fatalf("hs=%v fhs(ewma)=%v fhs(garch)=%v", hs.Var, fhs.Var, garch.Var)
+	}
+}
+
+func TestStudentTCDF(t *testing.T) {
+	if v := studentTCDF(0, 5); math.Abs(v-0.5) > 1e-12 {
+		t.Fatalf("cdf(0)=%v", v)
+	}
+	// 自由度 1 即柯西分布：F(1)=0.75
+	if v := studentTCDF(1, 1); math.Abs(v-0.75) > 1e-9 {
+		t.Fatalf("cauchy cdf(1)=%v", v)
+	}
+	// t(10) 的 97.5% 分位数约 2.228
+	if v := studentTCDF(2.228, 10); math.Abs(v-0.975) > 1e-4 {
+		t.Fatalf("t10 cdf(2.228)=%v", v)
+	}
+}
+
+func TestKupiecTest(t *testing.T) {
+	lr, p := kupiecTest(8, 250, 0.01)
+	if math.Abs(lr-7.7335) > 1e-3 || p >= 0.01 {
+		t.Fatalf("lr=%v p=%v", lr, p)
+	}
+	if lr, p := kupiecTest(3, 250, 0.01); lr > 1 || p < 0.05 {
+		t.Fatalf("expected calibrated: lr=%v p=%v", lr, p)
+	}
+	if lr, _ := kupiecTest(0, 250, 0.01); math.Abs(lr-(-2*250*math.Log(0.99))) > 1e-9 {
+		t.Fatalf("zero exceptions lr=%v", lr)
+	}
+}
+
+func TestBacktestVarModels(t *testing.T) {
+	in := &varInput{
+		positions: []positionForVar{{Symbol: "BTCUSDT", Side: "LONG", Notional: 1000}},
+		returns:   syntheticReturns(200, 5),
+		symIdx:    []int{0},
+		exposures: []float64{1000},
+	}
+	params := varModelParams{Confidence: 0.95, VolMethod: "ewma", Lambda: 0.94, Simulations: 500, DoF: 5, Seed: 1}
+	res := backtestVarModels([]string{VarModelHistorical, VarModelParametric}, in, params, 100)
+	if len(res) != 2 {
+		t.Fatalf("res=%+v", res)
+	}
+	for _, r := range res {
+		if r.Error != "" || r.Observations != 100 || r.Expected != 5 || r.PValue < 0 || r.PValue > 1 {
+			t.Fatalf("backtest=%+v", r)
+		}
+	}
+}
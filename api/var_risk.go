@@ -22,6 +22,15 @@ type VarRiskConfig struct {
 	BreachAction       string  `json:"breachAction"`        // "warn" / "lock"
 	RefreshIntervalSec int     `json:"refreshIntervalSec"`  // 刷新间隔
 	ConfidenceLevel    float64 `json:"confidenceLevel"`     // 0.95 or 0.99
+
+	// 定时刷新使用的模型：为空时沿用简化参数法；parametric / historical / fhs / montecarlo
+	Model              string  `json:"model"`
+	ReturnInterval     string  `json:"returnInterval"`     // 收益率周期，默认 1d
+	LookbackBars       int     `json:"lookbackBars"`       // 回看 K 线数，默认 365
+	VolMethod          string  `json:"volMethod"`          // 过滤历史模拟波动率：ewma / garch
+	EWMALambda         float64 `json:"ewmaLambda"`         // 默认 0.94
+	MCSimulations      int     `json:"mcSimulations"`      // 蒙特卡洛路径数，默认 10000
+	MCDegreesOfFreedom float64 `json:"mcDegreesOfFreedom"` // t Copula 自由度，默认 5
 }
 
 // VarSnapshot VaR 快照（数据库模型）
@@ -93,44 +102,43 @@ func refreshVar() {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	positions, err := GetPositions(ctx)
+	posForVar, err := collectVarPositions(ctx)
 	if err != nil {
 		return
 	}
 
-	var posForVar []positionForVar
+	if len(posForVar) == 0 {
+		snap := &VarSnapshot{}
+		varMu.Lock()
+		varLatest = snap
+		varMu.Unlock()
+		return
+	}
+
 	var symbols []string
 	symbolSet := map[string]bool{}
-
-	for _, p := range positions {
-		amt, _ := strconv.ParseFloat(p.PositionAmt, 64)
-		if amt == 0 {
-			continue
-		}
-		mark, _ := strconv.ParseFloat(p.MarkPrice, 64)
-		notional := math.Abs(amt) * mark
-		side := "LONG"
-		if amt < 0 {
-			side = "SHORT"
-		}
-		posForVar = append(posForVar, positionForVar{
-			Symbol: p.Symbol, Notional: notional, Side: side,
-		})
+	for _, p := range posForVar {
 		if !symbolSet[p.Symbol] {
 			symbols = append(symbols, p.Symbol)
 			symbolSet[p.Symbol] = true
 		}
 	}
 
-	if len(posForVar) == 0 {
-		snap := &VarSnapshot{}
-		varMu.Lock()
-		varLatest = snap
-		varMu.Unlock()
-		return
+	varMu.RLock()
+	model := varCfg.Model
+	varMu.RUnlock()
+
+	var snap *VarSnapshot
+	if model != "" {
+		// 配置了模型时用历史/蒙特卡洛等方法，失败回退简化参数法
+		snap, err = modelVarSnapshot(ctx, posForVar, model)
+		if err != nil {
+			log.Printf("[VarRisk] %s model failed, fallback to simplified parametric: %v", model, err)
+		}
+	}
+	if snap == nil {
+		snap = calcPortfolioVar(posForVar, symbols)
 	}
-
-	snap := calcPortfolioVar(posForVar, symbols)
 
 	varMu.Lock()
 	budget := varCfg.RiskBudgetUSDT
@@ -5,6 +5,7 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -487,6 +488,7 @@ func handleLiquidationMessage(raw []byte) {
 	notional := price * qty
 
 	liquidationStore.addEvent(ts, event.Order.Symbol, event.Order.Side, notional)
+	onLiqCascadeEvent(ts, event.Order.Symbol, event.Order.Side, notional)
 }
 
 func (s *liquidationStatsStore) addEvent(ts int64, symbol string, side string, notional float64) {
@@ -596,6 +598,40 @@ func (s *liquidationStatsStore) pruneLocked(now time.Time) {
 	}
 }
 
+// liquidationSpike 单币种 1h 爆仓额超过阈值的时间桶
+type liquidationSpike struct {
+	Symbol    string
+	StartTime int64 // 桶起始（毫秒）
+	Notional  float64
+	Count     int64
+}
+
+// symbolSpikes 返回 [from, to) 内单币种 1h 爆仓额 ≥ minNotional 的桶，按时间升序；symbol 为空表示全部币种
+func (s *liquidationStatsStore) symbolSpikes(symbol string, minNotional float64, from, to int64) []liquidationSpike {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []liquidationSpike
+	for start, sb := range s.symbolH1 {
+		if start < from || start >= to {
+			continue
+		}
+		for sym, v := range sb.data {
+			if (symbol != "" && sym != symbol) || v.Notional < minNotional {
+				continue
+			}
+			out = append(out, liquidationSpike{Symbol: sym, StartTime: start, Notional: v.Notional, Count: v.Count})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].StartTime != out[j].StartTime {
+			return out[i].StartTime < out[j].StartTime
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
 func (s *liquidationStatsStore) currentVersion() uint64 {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
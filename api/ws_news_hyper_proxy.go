@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html"
@@ -59,13 +60,19 @@ type newsPayload struct {
 }
 
 type newsSourceHealthItem struct {
-	Key         string `json:"key"`
-	Name        string `json:"name"`
-	URL         string `json:"url"`
-	Reachable   bool   `json:"reachable"`
-	StatusCode  int    `json:"statusCode"`
-	ContentType string `json:"contentType,omitempty"`
-	Error       string `json:"error,omitempty"`
+	Key           string `json:"key"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	URL           string `json:"url"`
+	Disabled      bool   `json:"disabled,omitempty"`
+	Reachable     bool   `json:"reachable"`
+	StatusCode    int    `json:"statusCode"`
+	ItemCount     int    `json:"itemCount"`
+	Error         string `json:"error,omitempty"`
+	IntervalSec   int    `json:"intervalSec"`
+	Failures      int    `json:"failures"`              // 连续失败次数（决定退避时长）
+	NextFetchAt   int64  `json:"nextFetchAt,omitempty"` // 下次轮询时间（退避中则为退避结束时间）
+	LastSuccessAt int64  `json:"lastSuccessAt,omitempty"`
 }
 
 type newsSourceHealthReport struct {
@@ -179,8 +186,6 @@ var (
 			},
 		},
 	}
-	newsSources = cloneNewsSources(defaultNewsSources)
-
 	newsClient = &http.Client{Timeout: newsHTTPTimeout}
 	hyperHTTP  = &http.Client{Timeout: hyperHTTPTimeout}
 
@@ -199,9 +204,9 @@ var (
 		clients: make(map[*wsClient]bool),
 	}
 
-	newsHealthClient = &http.Client{Timeout: 15 * time.Second}
-	newsHealthOnce   sync.Once
-	newsHealthState  struct {
+	newsHealthTimeout = 15 * time.Second
+	newsHealthOnce    sync.Once
+	newsHealthState   struct {
 		mu     sync.RWMutex
 		report newsSourceHealthReport
 	}
@@ -217,17 +222,23 @@ var (
 	hyperMonitorClientsMu sync.RWMutex
 )
 
-// InitNewsSourcesFromConfig 根据配置初始化资讯源（固定源 + RSSHub 路由 + TG 频道）。
+// InitNewsSourcesFromConfig 根据配置初始化资讯源（固定源 + RSSHub 路由 + TG 频道 + 自定义源）。
 func InitNewsSourcesFromConfig(cfg NewsConfig) {
 	sources := cloneNewsSources(defaultNewsSources)
 	rsshubSources := buildRSSHubNewsSources(cfg)
+	tgSources := buildTelegramNewsSources(cfg)
 	sources = append(sources, rsshubSources...)
-	sources = append(sources, buildTelegramNewsSources(cfg)...)
+	sources = append(sources, tgSources...)
+
+	specs := make([]NewsSourceSpec, 0, len(sources)+len(cfg.Sources))
+	for _, src := range sources {
+		specs = append(specs, src.spec(cfg.DefaultIntervalSec))
+	}
+	specs = append(specs, cfg.Sources...)
+	setConfigNewsSources(specs)
 
-	newsSources = sources
-	rsshubCount := len(rsshubSources)
-	tgCount := len(newsSources) - len(defaultNewsSources) - rsshubCount
-	log.Printf("[WsNews] configured sources: total=%d fixed=%d rsshub=%d tg=%d", len(newsSources), len(defaultNewsSources), rsshubCount, tgCount)
+	log.Printf("[WsNews] configured sources: total=%d fixed=%d rsshub=%d tg=%d custom=%d",
+		len(specs), len(defaultNewsSources), len(rsshubSources), len(tgSources), len(cfg.Sources))
 }
 
 // StartNewsSourceHealthMonitor 每小时检测当前资讯源可用性。
@@ -239,6 +250,7 @@ func StartNewsSourceHealthMonitor() {
 
 // StartNewsBackgroundFetcher 启动常驻资讯抓取循环（与客户端连接无关）。
 func StartNewsBackgroundFetcher() {
+	loadRuntimeNewsSources()
 	preloadNewsSnapshotFromRedis()
 	nHub.ensureRunning()
 }
@@ -357,10 +369,32 @@ func GetRecentNews(limit int) []NewsDigest {
 	sort.SliceStable(digests, func(i, j int) bool {
 		return digests[i].Timestamp.After(digests[j].Timestamp)
 	})
-	if len(digests) > limit {
-		digests = digests[:limit]
+	return dedupNewsDigests(digests, limit)
+}
+
+// dedupNewsDigests 多个来源转载的近似重复标题只保留最新一条，最多返回 limit 条
+func dedupNewsDigests(digests []NewsDigest, limit int) []NewsDigest {
+	out := digests[:0]
+	var kept []uint64
+	for _, d := range digests {
+		if len(out) >= limit {
+			break
+		}
+		h := newsSimhash(d.Title)
+		dup := false
+		for _, k := range kept {
+			if newsHamming(h, k) <= newsSimhashThreshold {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			continue
+		}
+		kept = append(kept, h)
+		out = append(out, d)
 	}
-	return digests
+	return out
 }
 
 func runNewsSourceHealthMonitor() {
@@ -373,36 +407,41 @@ func runNewsSourceHealthMonitor() {
 	}
 }
 
+// checkNewsSourceHealthOnce 逐个拉取资讯源检测可用性；结果同时计入轮询调度：
+// 成功清除退避立即恢复正常间隔，失败延长退避
 func checkNewsSourceHealthOnce() {
 	report := newsSourceHealthReport{
 		CheckedAt: time.Now().UnixMilli(),
 	}
 
-	type result struct {
-		item newsSourceHealthItem
-	}
-	ch := make(chan result, len(newsSources))
+	sources := newsSourceSnapshot()
+	ch := make(chan newsSourceHealthItem, len(sources))
 	var wg sync.WaitGroup
 
-	for _, source := range newsSources {
-		s := source
+	for _, source := range sources {
+		src := source
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			ch <- result{item: checkNewsFeedAvailability(s)}
+			ch <- checkNewsSourceAvailability(src)
 		}()
 	}
 
 	wg.Wait()
 	close(ch)
 
-	report.Sources = make([]newsSourceHealthItem, 0, len(newsSources))
+	report.Sources = make([]newsSourceHealthItem, 0, len(sources))
 	reachable := 0
-	for res := range ch {
-		if res.item.Reachable {
+	for item := range ch {
+		if view, ok := getNewsSourceView(item.Key); ok {
+			item.Failures = view.Failures
+			item.NextFetchAt = view.NextFetchAt
+			item.LastSuccessAt = view.LastSuccessAt
+		}
+		if item.Reachable {
 			reachable++
 		}
-		report.Sources = append(report.Sources, res.item)
+		report.Sources = append(report.Sources, item)
 	}
 	sort.Slice(report.Sources, func(i, j int) bool { return report.Sources[i].Key < report.Sources[j].Key })
 	report.ReachableSourceCount = reachable
@@ -434,6 +473,18 @@ func cloneNewsSources(in []newsFeedSource) []newsFeedSource {
 	return out
 }
 
+// spec 转为 rss 类型资讯源配置
+func (src newsFeedSource) spec(intervalSec int) NewsSourceSpec {
+	return NewsSourceSpec{
+		Key:         src.Key,
+		Name:        src.Name,
+		Type:        newsSourceTypeRSS,
+		URL:         src.URL,
+		Headers:     src.Headers,
+		IntervalSec: intervalSec,
+	}
+}
+
 func getRSSHubBaseURL(cfg NewsConfig) string {
 	baseURL := strings.TrimRight(strings.TrimSpace(cfg.RSSHubBaseURL), "/")
 	if baseURL == "" {
@@ -631,50 +682,43 @@ func normalizeTelegramChannel(raw string) string {
 	return s
 }
 
-func checkNewsFeedAvailability(source newsFeedSource) newsSourceHealthItem {
+func checkNewsSourceAvailability(source NewsSource) newsSourceHealthItem {
+	spec := source.Spec()
 	item := newsSourceHealthItem{
-		Key:  source.Key,
-		Name: source.Name,
-		URL:  source.URL,
-	}
-
-	req, err := http.NewRequest(http.MethodGet, source.URL, nil)
-	if err != nil {
-		item.Error = err.Error()
+		Key:         spec.Key,
+		Name:        spec.Name,
+		Type:        spec.Type,
+		URL:         spec.URL,
+		Disabled:    spec.Disabled,
+		IntervalSec: spec.IntervalSec,
+	}
+	if spec.Disabled {
+		item.Error = "disabled"
 		return item
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; NewsHealthCheck/1.0)")
-	req.Header.Set("Accept", "application/rss+xml, application/xml, text/xml, */*")
-	for k, v := range source.Headers {
-		req.Header.Set(k, v)
+	// webhook 源被动接收推送，收到过推送即视为可用
+	if spec.Type == newsSourceTypeWebhook {
+		if view, ok := getNewsSourceView(spec.Key); ok && view.LastSuccessAt > 0 {
+			item.Reachable = true
+			item.ItemCount = view.LastCount
+		} else {
+			item.Error = "no webhook push received yet"
+		}
+		return item
 	}
 
-	resp, err := newsHealthClient.Do(req)
+	ctx, cancel := context.WithTimeout(context.Background(), newsHealthTimeout)
+	defer cancel()
+	items, err := source.Fetch(ctx)
+	recordNewsSourceResult(spec.Key, len(items), err, time.Now())
 	if err != nil {
+		item.StatusCode = newsHTTPStatusCode(err)
 		item.Error = err.Error()
 		return item
 	}
-	defer resp.Body.Close()
-
-	item.StatusCode = resp.StatusCode
-	item.ContentType = resp.Header.Get("Content-Type")
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		item.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
-		return item
-	}
-
-	body, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
-	if readErr != nil {
-		item.Error = readErr.Error()
-		return item
-	}
-	low := strings.ToLower(string(body))
-	if strings.Contains(low, "<rss") || strings.Contains(low, "<feed") || strings.Contains(low, "<rdf") || strings.Contains(low, "<item") {
-		item.Reachable = true
-		return item
-	}
-
-	item.Error = "not rss/atom payload"
+	item.StatusCode = http.StatusOK
+	item.ItemCount = len(items)
+	item.Reachable = true
 	return item
 }
 
@@ -783,6 +827,16 @@ func (h *newsHub) run() {
 
 func (h *newsHub) fetchAndBroadcast() {
 	latest, failures, err := fetchNewsSnapshot()
+	if len(latest) == 0 && err == nil {
+		// 没有到达轮询时间的资讯源
+		return
+	}
+	h.applyAndBroadcast(latest, failures, err)
+}
+
+// applyAndBroadcast 合并新抓取/推送的条目到快照，持久化并推送给订阅客户端
+func (h *newsHub) applyAndBroadcast(latest map[string][]newsItem, failures []string, err error) {
+	newsMergeMu.Lock()
 	existing, _ := cloneNewsSnapshot()
 	merged := mergeNewsSnapshots(existing, latest, newsHistoryMaxPerSource)
 	payload := newsPayload{
@@ -796,6 +850,8 @@ func (h *newsHub) fetchAndBroadcast() {
 	}
 	storeNewsSnapshot(merged, payload.Time)
 	persistNewsSnapshotToRedis(merged, payload.Time)
+	newsMergeMu.Unlock()
+	archiveNews(latest)
 
 	raw, marshalErr := json.Marshal(payload)
 	if marshalErr != nil {
@@ -885,6 +941,7 @@ func mergeNewsSnapshots(existing, latest map[string][]newsItem, maxPerSource int
 	return out
 }
 
+// fetchNewsSnapshot 拉取到达轮询时间的资讯源；失败的源记录退避，返回数据只含本轮拉取的源
 func fetchNewsSnapshot() (map[string][]newsItem, []string, error) {
 	type fetchResult struct {
 		key  string
@@ -892,41 +949,45 @@ func fetchNewsSnapshot() (map[string][]newsItem, []string, error) {
 		err  error
 	}
 
-	results := make(chan fetchResult, len(newsSources))
+	due := dueNewsSources(time.Now())
+	if len(due) == 0 {
+		return map[string][]newsItem{}, nil, nil
+	}
+
+	results := make(chan fetchResult, len(due))
 	var wg sync.WaitGroup
 
-	for _, source := range newsSources {
+	for _, source := range due {
 		s := source
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
-			list, err := fetchNewsFeed(s)
-			results <- fetchResult{key: s.Key, list: list, err: err}
+			ctx, cancel := context.WithTimeout(context.Background(), newsHTTPTimeout)
+			defer cancel()
+			list, err := s.Fetch(ctx)
+			recordNewsSourceResult(s.Spec().Key, len(list), err, time.Now())
+			results <- fetchResult{key: s.Spec().Key, list: list, err: err}
 		}()
 	}
 
 	wg.Wait()
 	close(results)
 
-	data := make(map[string][]newsItem, len(newsSources))
-	failures := make([]string, 0, len(newsSources))
+	data := make(map[string][]newsItem, len(due))
+	failures := make([]string, 0, len(due))
 	success := 0
 
 	for res := range results {
 		if res.err != nil {
 			failures = append(failures, fmt.Sprintf("%s: %v", res.key, res.err))
+			data[res.key] = []newsItem{}
 			continue
 		}
 		success++
 		data[res.key] = normalizeNewsList(res.list)
 	}
-
-	for _, source := range newsSources {
-		if _, ok := data[source.Key]; !ok {
-			data[source.Key] = []newsItem{}
-		}
-	}
+	sort.Strings(failures)
 
 	if success == 0 {
 		return data, failures, fmt.Errorf("all news feeds failed")
@@ -934,38 +995,6 @@ func fetchNewsSnapshot() (map[string][]newsItem, []string, error) {
 	return data, failures, nil
 }
 
-func fetchNewsFeed(source newsFeedSource) ([]newsItem, error) {
-	req, err := http.NewRequest(http.MethodGet, source.URL, nil)
-	if err != nil {
-		return nil, err
-	}
-	// 兜底默认 Header，防止被目标站 403
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; NewsBot/1.0)")
-	req.Header.Set("Accept", "application/rss+xml, application/xml, text/xml, */*")
-	// 源自定义 Header 覆盖默认值
-	for k, v := range source.Headers {
-		req.Header.Set(k, v)
-	}
-
-	resp, err := newsClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, proxyHTTPResponseMaxSize))
-	if err != nil {
-		return nil, err
-	}
-
-	list := parseRSSContent(string(body), source.Name)
-	return list, nil
-}
-
 func parseRSSContent(xmlText string, defaultSource string) []newsItem {
 	items := make([]newsItem, 0, 32)
 	blocks := reItem.FindAllString(xmlText, -1)
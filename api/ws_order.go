@@ -89,6 +89,16 @@ func PlaceOrderViaWs(ctx context.Context, req PlaceOrderReq) (*PlaceOrderResult,
 		req.StopPrice = normalizedStopPrice
 	}
 
+	// 统一下单前风控网关（模拟盘同样生效）
+	if err := CheckPreTrade(req); err != nil {
+		if rej, ok := err.(*PreTradeRejection); ok {
+			rejectPreTrade(req, rej)
+			RecordOrderMetric(false, time.Since(orderStartTime).Milliseconds())
+			return nil, rej
+		}
+		return fail("PLACE_ORDER", err)
+	}
+
 	// DryRun 模拟交易模式：不实际下单，用虚拟资金撮合
 	if IsDryRun() {
 		qtyStr, qtyErr := calculateQuantityFromUSDT(ctx, req)
@@ -197,7 +207,10 @@ func PlaceOrderViaWs(ctx context.Context, req PlaceOrderReq) (*PlaceOrderResult,
 			source = "manual"
 		}
 		latencyMs := time.Since(orderStartTime).Milliseconds()
-		RecordExecutionQuality(req.Symbol, orderIDStr, string(req.Side), source, arrivalPrice, executedPrice, qty, latencyMs)
+		// 跟单以带单者成交价为基准单独记录（见 hyper_follow_fidelity.go），这里不重复记录
+		if source != hyperFollowSource {
+			RecordExecutionQuality(req.Symbol, orderIDStr, string(req.Side), source, arrivalPrice, executedPrice, qty, latencyMs)
+		}
 		RecordOrderMetric(true, latencyMs)
 	}()
 
@@ -237,6 +250,7 @@ func persistTradeRecordAsync(req PlaceOrderReq, result *PlaceOrderResult) {
 	if result == nil || result.Order == nil {
 		return
 	}
+	recordLinkFill(req, result.Order)
 
 	reqCopy := req
 	orderCopy := *result.Order
@@ -406,6 +420,9 @@ func wsPlaceOrder(wsClient *ws.WsClient, req PlaceOrderReq, quantity string) (*f
 	if req.ReduceOnly {
 		params.ReduceOnly = "true"
 	}
+	if req.ClientOrderID != "" {
+		params.NewClientOrderId = req.ClientOrderID
+	}
 
 	result, err := wsClient.PlaceOrder(params)
 	if err != nil {
@@ -443,6 +460,9 @@ func restPlaceOrder(ctx context.Context, req PlaceOrderReq, quantity string) (*f
 	if req.ReduceOnly {
 		service.ReduceOnly(req.ReduceOnly)
 	}
+	if req.ClientOrderID != "" {
+		service.NewClientOrderID(req.ClientOrderID)
+	}
 
 	return service.Do(ctx)
 }
@@ -61,6 +61,9 @@ func main() {
 	// 初始化组合风控
 	api.InitPortfolioRisk(api.Cfg.PortfolioRisk)
 
+	// 初始化下单前风控网关
+	api.InitPreTradeGateway(api.Cfg.PreTrade)
+
 	// 启动异常波动守卫（如果配置启用）
 	api.StartVolatilityGuard(api.Cfg.VolatilityGuard)
 
@@ -72,6 +75,7 @@ func main() {
 	api.InitDataFallback(api.FallbackConfig{})
 	api.StartRegimeDetector("BTCUSDT", 60)
 	api.StartRecommendSignalEvaluator(30)
+	api.StartNewsArchive()
 
 	// 初始化 WebSocket 订单客户端（异步，不阻塞启动）
 	go api.InitWsClient()
@@ -98,6 +102,9 @@ func main() {
 	// 启动后台爆仓采集（无监控前端也持续更新，供策略与分析使用）
 	api.StartLiquidationCollectorBackground()
 
+	// Hyperliquid 带单地址观察列表
+	api.StartHyperLeaderWatcher()
+
 	// 启动本地止盈止损监控器（从DB恢复ACTIVE条件）
 	api.StartLocalTPSLMonitor()
 
@@ -155,9 +162,15 @@ func main() {
 		apiGroup.GET("/analytics/journal", api.HandleGetAnalyticsJournal)
 		apiGroup.GET("/analytics/attribution", api.HandleGetAnalyticsAttribution)
 		apiGroup.GET("/analytics/sentiment", api.HandleGetAnalyticsSentiment)
+		apiGroup.POST("/analytics/event-study", api.HandleEventStudy)
 		apiGroup.POST("/hyper/follow/start", api.HandleStartHyperFollow)
 		apiGroup.POST("/hyper/follow/stop", api.HandleStopHyperFollow)
 		apiGroup.GET("/hyper/follow/status", api.HandleHyperFollowStatus)
+		apiGroup.GET("/hyper/follow/fidelity", api.HandleGetHyperFollowFidelity)
+		apiGroup.GET("/hyper/leaders/:address/stats", api.HandleGetHyperLeaderStats)
+		apiGroup.GET("/hyper/watchlist", api.HandleGetHyperWatchlist)
+		apiGroup.POST("/hyper/watchlist", api.HandleAddHyperWatch)
+		apiGroup.DELETE("/hyper/watchlist", api.HandleRemoveHyperWatch)
 
 		// 浮盈加仓
 		apiGroup.POST("/autoscale/start", api.HandleStartAutoScale)
@@ -203,6 +216,14 @@ func main() {
 		apiGroup.POST("/link/stop", api.HandleStopStrategyLink)
 		apiGroup.GET("/link/status", api.HandleStrategyLinkStatus)
 		apiGroup.POST("/link/rules", api.HandleUpdateStrategyLinkRules)
+		apiGroup.GET("/link/rules", api.HandleGetStrategyLinkRules)
+		apiGroup.GET("/link/audit", api.HandleGetLinkAudit)
+		apiGroup.POST("/link/evaluate", api.HandleEvaluateLinkRules)
+		apiGroup.POST("/link/replay", api.HandleReplayLinkRule)
+
+		// 交易分析助手（大模型）
+		apiGroup.POST("/agent", api.HandleAgent)
+		apiGroup.POST("/agent/execute", api.HandleAgentExecute)
 
 		// 支撑/阻力位
 		apiGroup.GET("/sr/levels", api.HandleGetSRLevels)
@@ -224,6 +245,18 @@ func main() {
 		apiGroup.POST("/scalp/stop", api.HandleStopScalp)
 		apiGroup.GET("/scalp/status", api.HandleScalpStatus)
 
+		// 多币种篮子（scalp / signal）
+		apiGroup.POST("/basket/start", api.HandleStartBasket)
+		apiGroup.POST("/basket/stop", api.HandleStopBasket)
+		apiGroup.GET("/basket/status", api.HandleBasketStatus)
+
+		// 配对交易 / 统计套利
+		apiGroup.GET("/pairs/scan", api.HandleScanPairs)
+		apiGroup.POST("/pairs/backtest", api.HandlePairsBacktest)
+		apiGroup.POST("/pairs/start", api.HandleStartPairs)
+		apiGroup.POST("/pairs/stop", api.HandleStopPairs)
+		apiGroup.GET("/pairs/status", api.HandlePairsStatus)
+
 		// 模拟交易（Paper Trading / DryRun）
 		apiGroup.GET("/paper/status", api.HandleGetPaperStatus)
 		apiGroup.POST("/paper/reset", api.HandleResetPaper)
@@ -243,6 +276,13 @@ func main() {
 		apiGroup.GET("/news-sentiment/status", api.HandleNewsSentimentStatus)
 		apiGroup.GET("/news/sources/status", api.HandleGetNewsSourceStatus)
 		apiGroup.GET("/news/page", api.HandleGetNewsPage)
+		apiGroup.GET("/news/symbol/:symbol", api.HandleGetSymbolNews)
+		apiGroup.GET("/news/sources", api.HandleListNewsSources)
+		apiGroup.POST("/news/sources", api.HandleUpsertNewsSource)
+		apiGroup.DELETE("/news/sources", api.HandleRemoveNewsSource)
+		apiGroup.POST("/news/ingest", api.HandleNewsIngest)
+		apiGroup.GET("/news/search", api.HandleSearchNews)
+		apiGroup.GET("/news/impact", api.HandleNewsSourceImpact)
 
 		// 爆仓级联交易策略
 		apiGroup.POST("/liq-cascade/start", api.HandleStartLiqCascade)
@@ -272,6 +312,9 @@ func main() {
 
 		// VaR 风控
 		apiGroup.GET("/risk/var", api.HandleGetVarStatus)
+		apiGroup.POST("/risk/var/model", api.HandleVarModel)
+		apiGroup.POST("/risk/var/backtest", api.HandleVarBacktest)
+		apiGroup.POST("/risk/whatif", api.HandleRiskWhatIf)
 
 		// 冲击测试
 		apiGroup.POST("/risk/stress-test", api.HandleRunStressTest)